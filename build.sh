@@ -8,6 +8,8 @@ set -e
 PLUGIN_NAME="stash-compreface-rpc"
 BUILD_DIR="gorpc"
 VERSION="2.0.0"
+COMMIT=$(git rev-parse --short HEAD 2>/dev/null || echo "unknown")
+VERSION_PKG="github.com/smegmarip/stash-compreface-plugin/internal/version"
 
 # Colors for output
 RED='\033[0;31m'
@@ -37,7 +39,7 @@ rm -f ${PLUGIN_NAME}*
 
 # Build for Linux (Stash runs in Linux container)
 echo -e "${GREEN}Building for Linux (amd64)...${NC}"
-GOOS=linux GOARCH=amd64 TMPDIR=/Users/x/tmp GOTMPDIR=/Users/x/tmp go build -o ${PLUGIN_NAME} -ldflags "-s -w" .
+GOOS=linux GOARCH=amd64 TMPDIR=/Users/x/tmp GOTMPDIR=/Users/x/tmp go build -o ${PLUGIN_NAME} -ldflags "-s -w -X ${VERSION_PKG}.Version=${VERSION} -X ${VERSION_PKG}.Commit=${COMMIT}" .
 if [ $? -eq 0 ]; then
     BINARY_SIZE=$(du -h ${PLUGIN_NAME} | awk '{print $1}')
     echo -e "${GREEN}✓ Built ${PLUGIN_NAME} (${BINARY_SIZE})${NC}"
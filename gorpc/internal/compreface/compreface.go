@@ -2,23 +2,37 @@ package compreface
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/jpeg"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/stashapp/stash/pkg/plugin/common/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/throttle"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
 )
 
 // ============================================================================
 // Compreface HTTP Client - API Operations
 // ============================================================================
 
+// DefaultFacePlugins are the face plugins requested on recognition unless
+// configured otherwise
+var DefaultFacePlugins = []string{"landmarks", "gender", "age", "calculator", "mask"}
+
+// demographicPlugins are the face plugins estimating age and gender
+var demographicPlugins = map[string]bool{"age": true, "gender": true}
+
 // NewClient creates a new Compreface API client
 func NewClient(baseURL string, recognitionKey string, detectionKey string, verificationKey string, minSimilarity float64) *Client {
 	return &Client{
@@ -27,16 +41,60 @@ func NewClient(baseURL string, recognitionKey string, detectionKey string, verif
 		DetectionKey:    detectionKey,
 		VerificationKey: verificationKey,
 		MinSimilarity:   minSimilarity,
+		FacePlugins:     append([]string{}, DefaultFacePlugins...),
 		httpClient: &http.Client{
 			Timeout: 60 * time.Second,
 		},
 	}
 }
 
+// WithoutDemographics returns plugins without the age and gender plugins
+func WithoutDemographics(plugins []string) []string {
+	kept := []string{}
+	for _, plugin := range plugins {
+		if !demographicPlugins[strings.ToLower(plugin)] {
+			kept = append(kept, plugin)
+		}
+	}
+	return kept
+}
+
+// endpoint builds an API URL from the base URL, preserving any path prefix
+// (e.g. Compreface mounted under /compreface behind a reverse proxy)
+func (c *Client) endpoint(format string, args ...interface{}) string {
+	return strings.TrimRight(c.BaseURL, "/") + fmt.Sprintf(format, args...)
+}
+
+// SetRateLimit limits requests to perSecond per second (<= 0 = unlimited)
+func (c *Client) SetRateLimit(perSecond float64) {
+	c.limiter = throttle.NewRateLimiter(perSecond)
+}
+
+// SetContext cancels the client's requests in flight, and fails later ones,
+// once ctx is done
+func (c *Client) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
+
+// do sends the request once the rate limit allows, tagged with the active
+// processing trace ID
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	c.limiter.Wait()
+	if c.ctx != nil {
+		req = req.WithContext(c.ctx)
+	}
+	trace.SetHeader(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, transportError(err)
+	}
+	return resp, nil
+}
+
 // DetectFaces detects faces in an image file
 // POST /api/v1/detection/detect
 func (c *Client) DetectFaces(imagePath string) (*DetectionResponse, error) {
-	url := fmt.Sprintf("%s/api/v1/detection/detect", c.BaseURL)
+	url := c.endpoint("/api/v1/detection/detect")
 
 	// Read image file
 	imageData, err := os.ReadFile(imagePath)
@@ -74,7 +132,7 @@ func (c *Client) DetectFaces(imagePath string) (*DetectionResponse, error) {
 
 	// Send request
 	log.Tracef("DetectFaces: POST %s", url)
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -88,7 +146,7 @@ func (c *Client) DetectFaces(imagePath string) (*DetectionResponse, error) {
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp.StatusCode, respBody)
 	}
 
 	// Parse response
@@ -104,7 +162,7 @@ func (c *Client) DetectFaces(imagePath string) (*DetectionResponse, error) {
 
 // DetectFacesFromBytes detects faces in image bytes
 func (c *Client) DetectFacesFromBytes(imageBytes []byte, filename string) (*DetectionResponse, error) {
-	url := fmt.Sprintf("%s/api/v1/detection/detect", c.BaseURL)
+	url := c.endpoint("/api/v1/detection/detect")
 
 	// Create multipart form
 	body := &bytes.Buffer{}
@@ -136,7 +194,7 @@ func (c *Client) DetectFacesFromBytes(imageBytes []byte, filename string) (*Dete
 
 	// Send request
 	log.Tracef("DetectFacesFromBytes: POST %s", url)
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -150,7 +208,7 @@ func (c *Client) DetectFacesFromBytes(imageBytes []byte, filename string) (*Dete
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp.StatusCode, respBody)
 	}
 
 	// Parse response
@@ -182,8 +240,17 @@ func (c *Client) RecognizeFaces(imagePath string) (*RecognitionResponse, error)
 
 // RecognizeFacesFromBytes recognizes faces in image bytes
 func (c *Client) RecognizeFacesFromBytes(imageBytes []byte, filename string) (*RecognitionResponse, error) {
-	pluginArgs := "landmarks,gender,age,calculator,mask"
-	url := fmt.Sprintf("%s/api/v1/recognition/recognize?face_plugins=%s", c.BaseURL, url.QueryEscape(pluginArgs))
+	query := url.Values{}
+	if len(c.FacePlugins) > 0 {
+		query.Set("face_plugins", strings.Join(c.FacePlugins, ","))
+	}
+	if c.PredictionCount > 0 {
+		query.Set("prediction_count", strconv.Itoa(c.PredictionCount))
+	}
+	url := c.endpoint("/api/v1/recognition/recognize")
+	if len(query) > 0 {
+		url += "?" + query.Encode()
+	}
 
 	// Create multipart form
 	body := &bytes.Buffer{}
@@ -215,7 +282,7 @@ func (c *Client) RecognizeFacesFromBytes(imageBytes []byte, filename string) (*R
 
 	// Send request
 	log.Tracef("RecognizeFaces: POST %s", url)
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -229,7 +296,7 @@ func (c *Client) RecognizeFacesFromBytes(imageBytes []byte, filename string) (*R
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp.StatusCode, respBody)
 	}
 
 	// Parse response
@@ -243,6 +310,82 @@ func (c *Client) RecognizeFacesFromBytes(imageBytes []byte, filename string) (*R
 	return &recognition, nil
 }
 
+// DetectModel returns the recognition model (calculator plugin version) of
+// the Compreface instance, e.g. "insightface.Calculator@arcface-r100-msfdb".
+// A blank image is recognized with face detection disabled, so no face is
+// needed and no subject can match.
+// POST /api/v1/recognition/recognize?detect_faces=false&status=true
+func (c *Client) DetectModel() (string, error) {
+	var blank bytes.Buffer
+	if err := jpeg.Encode(&blank, image.NewGray(image.Rect(0, 0, 112, 112)), nil); err != nil {
+		return "", fmt.Errorf("failed to encode probe image: %w", err)
+	}
+
+	url := c.endpoint("/api/v1/recognition/recognize?detect_faces=false&status=true&prediction_count=1")
+
+	// Create multipart form
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", "probe.jpg")
+	if err != nil {
+		return "", fmt.Errorf("failed to create form file: %w", err)
+	}
+
+	_, err = part.Write(blank.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("failed to write image data: %w", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to close writer: %w", err)
+	}
+
+	// Create request
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("x-api-key", c.RecognitionKey)
+
+	// Send request
+	log.Tracef("DetectModel: POST %s", url)
+	resp, err := c.do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError(resp.StatusCode, respBody)
+	}
+
+	// Parse response
+	var recognition RecognitionResponse
+	err = json.Unmarshal(respBody, &recognition)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	model := recognition.PluginsVersions["calculator"]
+	if model == "" {
+		return "", fmt.Errorf("compreface did not report its recognition model")
+	}
+
+	log.Debugf("DetectModel: %s", model)
+	return model, nil
+}
+
 // AddSubject adds a new subject with an image
 // POST /api/v1/recognition/faces?subject={subject}
 func (c *Client) AddSubject(subjectName string, imagePath string) (*AddSubjectResponse, error) {
@@ -256,7 +399,7 @@ func (c *Client) AddSubject(subjectName string, imagePath string) (*AddSubjectRe
 
 // AddSubjectFromBytes adds a new subject with image bytes
 func (c *Client) AddSubjectFromBytes(subjectName string, imageBytes []byte, filename string) (*AddSubjectResponse, error) {
-	reqURL := fmt.Sprintf("%s/api/v1/recognition/faces?subject=%s", c.BaseURL, url.QueryEscape(subjectName))
+	reqURL := c.endpoint("/api/v1/recognition/faces?subject=%s", url.QueryEscape(subjectName))
 
 	// Create multipart form
 	body := &bytes.Buffer{}
@@ -288,7 +431,7 @@ func (c *Client) AddSubjectFromBytes(subjectName string, imageBytes []byte, file
 
 	// Send request
 	log.Tracef("AddSubject: POST %s", reqURL)
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -302,7 +445,7 @@ func (c *Client) AddSubjectFromBytes(subjectName string, imageBytes []byte, file
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp.StatusCode, respBody)
 	}
 
 	// Parse response
@@ -319,7 +462,7 @@ func (c *Client) AddSubjectFromBytes(subjectName string, imageBytes []byte, file
 // ListSubjects lists all subjects
 // GET /api/v1/recognition/subjects
 func (c *Client) ListSubjects() ([]string, error) {
-	url := fmt.Sprintf("%s/api/v1/recognition/subjects", c.BaseURL)
+	url := c.endpoint("/api/v1/recognition/subjects")
 
 	// Create request
 	req, err := http.NewRequest("GET", url, nil)
@@ -331,7 +474,7 @@ func (c *Client) ListSubjects() ([]string, error) {
 
 	// Send request
 	log.Tracef("ListSubjects: GET %s", url)
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -345,7 +488,7 @@ func (c *Client) ListSubjects() ([]string, error) {
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp.StatusCode, respBody)
 	}
 
 	// Parse response
@@ -362,7 +505,7 @@ func (c *Client) ListSubjects() ([]string, error) {
 // DeleteSubject deletes a subject
 // DELETE /api/v1/recognition/subjects/{subject}
 func (c *Client) DeleteSubject(subjectName string) error {
-	url := fmt.Sprintf("%s/api/v1/recognition/subjects/%s", c.BaseURL, subjectName)
+	url := c.endpoint("/api/v1/recognition/subjects/%s", url.PathEscape(subjectName))
 
 	// Create request
 	req, err := http.NewRequest("DELETE", url, nil)
@@ -374,7 +517,7 @@ func (c *Client) DeleteSubject(subjectName string) error {
 
 	// Send request
 	log.Tracef("DeleteSubject: DELETE %s", url)
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -388,17 +531,60 @@ func (c *Client) DeleteSubject(subjectName string) error {
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+		return newAPIError(resp.StatusCode, respBody)
 	}
 
 	log.Infof("DeleteSubject: Deleted subject '%s'", subjectName)
 	return nil
 }
 
+// RenameSubject renames a subject, merging its faces into newName when that
+// subject already exists
+// PUT /api/v1/recognition/subjects/{subject}
+func (c *Client) RenameSubject(subjectName string, newName string) error {
+	reqURL := c.endpoint("/api/v1/recognition/subjects/%s", url.PathEscape(subjectName))
+
+	payload, err := json.Marshal(map[string]string{"subject": newName})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create request
+	req, err := http.NewRequest("PUT", reqURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", c.RecognitionKey)
+
+	// Send request
+	log.Tracef("RenameSubject: PUT %s", reqURL)
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp.StatusCode, respBody)
+	}
+
+	log.Infof("RenameSubject: Renamed subject '%s' to '%s'", subjectName, newName)
+	return nil
+}
+
 // ListFaces lists all faces for a subject
 // GET /api/v1/recognition/faces?subject={subject}
 func (c *Client) ListFaces(subjectName string) ([]FaceListItem, error) {
-	url := fmt.Sprintf("%s/api/v1/recognition/faces?subject=%s", c.BaseURL, url.QueryEscape(subjectName))
+	url := c.endpoint("/api/v1/recognition/faces?subject=%s", url.QueryEscape(subjectName))
 
 	// Create request
 	req, err := http.NewRequest("GET", url, nil)
@@ -410,7 +596,7 @@ func (c *Client) ListFaces(subjectName string) ([]FaceListItem, error) {
 
 	// Send request
 	log.Tracef("ListFaces: GET %s", url)
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -424,7 +610,7 @@ func (c *Client) ListFaces(subjectName string) ([]FaceListItem, error) {
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp.StatusCode, respBody)
 	}
 
 	// Parse response
@@ -441,7 +627,7 @@ func (c *Client) ListFaces(subjectName string) ([]FaceListItem, error) {
 // DeleteFace deletes a specific face image
 // DELETE /api/v1/recognition/faces/{image_id}
 func (c *Client) DeleteFace(imageID string) error {
-	url := fmt.Sprintf("%s/api/v1/recognition/faces/%s", c.BaseURL, imageID)
+	url := c.endpoint("/api/v1/recognition/faces/%s", url.PathEscape(imageID))
 
 	// Create request
 	req, err := http.NewRequest("DELETE", url, nil)
@@ -453,7 +639,7 @@ func (c *Client) DeleteFace(imageID string) error {
 
 	// Send request
 	log.Tracef("DeleteFace: DELETE %s", url)
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -467,17 +653,202 @@ func (c *Client) DeleteFace(imageID string) error {
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+		return newAPIError(resp.StatusCode, respBody)
 	}
 
 	log.Infof("DeleteFace: Deleted face image_id=%s", imageID)
 	return nil
 }
 
-// SubjectImageURL constructs the URL to access a subject's image by image ID
-func (c *Client) SubjectImageURL(imageID string) string {
-	return fmt.Sprintf("%s/api/v1/static/%s/images/%s",
-		c.BaseURL, c.RecognitionKey, imageID)
+// VerifyFaceFromBytes compares a face image with a stored subject example and
+// returns the highest similarity among faces found in the probe image
+// POST /api/v1/recognition/faces/{image_id}/verify
+func (c *Client) VerifyFaceFromBytes(imageID string, imageBytes []byte, filename string) (float64, error) {
+	url := c.endpoint("/api/v1/recognition/faces/%s/verify", url.PathEscape(imageID))
+
+	// Create multipart form
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create form file: %w", err)
+	}
+
+	_, err = part.Write(imageBytes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write image data: %w", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to close writer: %w", err)
+	}
+
+	// Create request
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("x-api-key", c.RecognitionKey)
+
+	// Send request
+	log.Tracef("VerifyFace: POST %s", url)
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		return 0, newAPIError(resp.StatusCode, respBody)
+	}
+
+	// Parse response
+	var verification VerificationResponse
+	err = json.Unmarshal(respBody, &verification)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	best := 0.0
+	for _, result := range verification.Result {
+		if result.Similarity > best {
+			best = result.Similarity
+		}
+	}
+
+	log.Debugf("VerifyFace: image_id=%s similarity=%.2f", imageID, best)
+	return best, nil
+}
+
+// VerifyFaces compares the faces of two images with the verification service
+// and returns the highest similarity between a source face and a target face
+// POST /api/v1/verification/verify
+func (c *Client) VerifyFaces(sourceImage []byte, targetImage []byte) (float64, error) {
+	if c.VerificationKey == "" {
+		return 0, fmt.Errorf("verification API key is not configured")
+	}
+
+	url := c.endpoint("/api/v1/verification/verify")
+
+	// Create multipart form
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for _, file := range []struct {
+		field string
+		data  []byte
+	}{
+		{"source_image", sourceImage},
+		{"target_image", targetImage},
+	} {
+		part, err := writer.CreateFormFile(file.field, file.field+".jpg")
+		if err != nil {
+			return 0, fmt.Errorf("failed to create form file: %w", err)
+		}
+		if _, err := part.Write(file.data); err != nil {
+			return 0, fmt.Errorf("failed to write image data: %w", err)
+		}
+	}
+
+	err := writer.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to close writer: %w", err)
+	}
+
+	// Create request
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("x-api-key", c.VerificationKey)
+
+	// Send request
+	log.Tracef("VerifyFaces: POST %s", url)
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		return 0, newAPIError(resp.StatusCode, respBody)
+	}
+
+	// Parse response
+	var verification FaceVerificationResponse
+	err = json.Unmarshal(respBody, &verification)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	best := 0.0
+	for _, result := range verification.Result {
+		for _, match := range result.FaceMatches {
+			if match.Similarity > best {
+				best = match.Similarity
+			}
+		}
+	}
+
+	log.Debugf("VerifyFaces: similarity=%.2f", best)
+	return best, nil
+}
+
+// FaceImageURL returns the URL of a stored subject example image. Fetching it
+// requires the recognition key in the x-api-key header, so unlike the static
+// image URL it never carries the API key.
+// GET /api/v1/recognition/faces/{image_id}/img
+func (c *Client) FaceImageURL(imageID string) string {
+	return c.endpoint("/api/v1/recognition/faces/%s/img", url.PathEscape(imageID))
+}
+
+// DownloadFaceImage downloads a stored subject example image
+// GET /api/v1/recognition/faces/{image_id}/img
+func (c *Client) DownloadFaceImage(imageID string) ([]byte, error) {
+	req, err := http.NewRequest("GET", c.FaceImageURL(imageID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("x-api-key", c.RecognitionKey)
+
+	log.Tracef("DownloadFaceImage: GET image_id=%s", imageID)
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp.StatusCode, respBody)
+	}
+
+	return respBody, nil
 }
 
 // ============================================================================
@@ -493,7 +864,7 @@ func (c *Client) RecognizeEmbedding(embedding []float64, predictionCount int) (*
 // RecognizeEmbeddings performs batch recognition for multiple embeddings
 // POST /api/v1/recognition/embeddings/recognize?prediction_count=<n>
 func (c *Client) RecognizeEmbeddings(embeddings [][]float64, predictionCount int) (*EmbeddingRecognitionResponse, error) {
-	reqURL := fmt.Sprintf("%s/api/v1/recognition/embeddings/recognize?prediction_count=%d", c.BaseURL, predictionCount)
+	reqURL := c.endpoint("/api/v1/recognition/embeddings/recognize?prediction_count=%d", predictionCount)
 
 	// Create request body
 	reqBody := EmbeddingRecognitionRequest{
@@ -516,7 +887,7 @@ func (c *Client) RecognizeEmbeddings(embeddings [][]float64, predictionCount int
 
 	// Send request
 	log.Tracef("RecognizeEmbeddings: POST %s (%d embeddings)", reqURL, len(embeddings))
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -530,7 +901,7 @@ func (c *Client) RecognizeEmbeddings(embeddings [][]float64, predictionCount int
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp.StatusCode, respBody)
 	}
 
 	// Parse response
@@ -0,0 +1,146 @@
+package compreface
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ============================================================================
+// Compreface Domain - double-take Import
+// ============================================================================
+//
+// double-take (the Frigate face recognition frontend) can use Compreface as
+// its detector, so its subjects already exist in Compreface under the names
+// given in double-take. Its data is exported as the JSON response of its
+// /api/match endpoint, optionally with the /api/train list added under a
+// "train" key:
+//
+//	{"matches": [{"filename": "...", "response": [{"detector": "compreface",
+//	  "results": [{"name": "jane_doe", "match": true, "confidence": 97.3}]}]}],
+//	 "train": [{"name": "jane_doe", "filename": "..."}]}
+//
+// Matches and training images are consolidated per file name, which is
+// what Stash files are found by.
+//
+// ============================================================================
+
+// DoubleTakeExport is a double-take match history and training list
+type DoubleTakeExport struct {
+	Matches []DoubleTakeMatch `json:"matches"`
+	Train   []DoubleTakeTrain `json:"train"`
+}
+
+// DoubleTakeMatch is a file double-take ran its detectors on
+type DoubleTakeMatch struct {
+	Filename string `json:"filename"`
+	File     struct {
+		Filename string `json:"filename"`
+	} `json:"file"`
+	Response []struct {
+		Detector string             `json:"detector"`
+		Results  []DoubleTakeResult `json:"results"`
+	} `json:"response"`
+}
+
+// DoubleTakeResult is a face found by a double-take detector
+type DoubleTakeResult struct {
+	Name       string  `json:"name"`
+	Match      bool    `json:"match"`
+	Confidence float64 `json:"confidence"` // Percent (0-100)
+}
+
+// DoubleTakeTrain is an image a double-take subject was trained with
+type DoubleTakeTrain struct {
+	Name     string `json:"name"`
+	Filename string `json:"filename"`
+}
+
+// DoubleTakeSubject is a subject double-take matched in a file
+type DoubleTakeSubject struct {
+	Name       string
+	Similarity float64 // Best match similarity (0-1); 1 for training images
+}
+
+// DoubleTakeFile is a file with the subjects double-take matched or trained
+// in it
+type DoubleTakeFile struct {
+	Filename string // Base name
+	Faces    int    // Most faces any detector found (at least len(Subjects))
+	Subjects []DoubleTakeSubject
+}
+
+// ParseDoubleTakeExport parses a double-take export into the files with
+// matched or trained subjects, sorted by file name
+func ParseDoubleTakeExport(data []byte) ([]DoubleTakeFile, error) {
+	var export DoubleTakeExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("failed to parse double-take export: %w", err)
+	}
+
+	files := map[string]*DoubleTakeFile{}
+	similarities := map[string]map[string]float64{}
+	record := func(filename string, faces int, subject string, similarity float64) {
+		filename = filepath.Base(strings.ReplaceAll(filename, `\`, "/"))
+		if filename == "." || filename == "/" {
+			return
+		}
+		file, ok := files[filename]
+		if !ok {
+			file = &DoubleTakeFile{Filename: filename}
+			files[filename] = file
+			similarities[filename] = map[string]float64{}
+		}
+		file.Faces = max(file.Faces, faces)
+		if best, ok := similarities[filename][subject]; !ok || similarity > best {
+			similarities[filename][subject] = similarity
+		}
+	}
+
+	for _, match := range export.Matches {
+		filename := match.Filename
+		if filename == "" {
+			filename = match.File.Filename
+		}
+		for _, response := range match.Response {
+			for _, result := range response.Results {
+				if result.Match && result.Name != "" && result.Name != "unknown" {
+					record(filename, len(response.Results), result.Name, result.Confidence/100)
+				}
+			}
+		}
+	}
+	for _, train := range export.Train {
+		if train.Name != "" {
+			record(train.Filename, 1, train.Name, 1)
+		}
+	}
+
+	result := make([]DoubleTakeFile, 0, len(files))
+	for filename, file := range files {
+		for name, similarity := range similarities[filename] {
+			file.Subjects = append(file.Subjects, DoubleTakeSubject{Name: name, Similarity: similarity})
+		}
+		sort.Slice(file.Subjects, func(i, j int) bool { return file.Subjects[i].Name < file.Subjects[j].Name })
+		file.Faces = max(file.Faces, len(file.Subjects))
+		result = append(result, *file)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Filename < result[j].Filename })
+	return result, nil
+}
+
+// DoubleTakeDisplayName turns a double-take subject name such as "jane_doe"
+// into a performer name ("Jane Doe"). Words already containing capitals are
+// kept as they are.
+func DoubleTakeDisplayName(subject string) string {
+	words := strings.Fields(strings.ReplaceAll(subject, "_", " "))
+	for i, word := range words {
+		if word == strings.ToLower(word) {
+			runes := []rune(word)
+			words[i] = strings.ToUpper(string(runes[0])) + string(runes[1:])
+		}
+	}
+	return strings.Join(words, " ")
+}
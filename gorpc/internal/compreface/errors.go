@@ -0,0 +1,95 @@
+package compreface
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ============================================================================
+// API Errors
+// ============================================================================
+//
+// Compreface answers failed requests with a JSON body holding a message and
+// a numeric code. Every non-success response is returned as an *APIError,
+// which matches the sentinel errors below through errors.Is, so callers
+// never inspect error strings.
+//
+// ============================================================================
+
+var (
+	// ErrNoFaceFound is matched by errors for images Compreface finds no face in
+	ErrNoFaceFound = errors.New("no face found")
+
+	// ErrSubjectExists is matched by errors for a subject name already in use
+	ErrSubjectExists = errors.New("subject already exists")
+
+	// ErrServiceUnavailable is matched by errors from requests Compreface
+	// could not serve: connection failures and 502, 503 and 504 responses
+	ErrServiceUnavailable = errors.New("compreface unavailable")
+)
+
+// codeNoFaceFound is Compreface's error code for an image without faces
+const codeNoFaceFound = 28
+
+// APIError is a non-success response from the Compreface API
+type APIError struct {
+	StatusCode int
+	Code       int    // Compreface error code, 0 when the body has none
+	Message    string // Compreface error message, empty when the body has none
+	Body       string
+}
+
+// newAPIError builds the error for a non-success response
+func newAPIError(statusCode int, body []byte) *APIError {
+	e := &APIError{StatusCode: statusCode, Body: string(body)}
+	var payload struct {
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		e.Code, e.Message = payload.Code, payload.Message
+	}
+	return e
+}
+
+// Error implements error
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error %d: %s", e.StatusCode, e.Body)
+}
+
+// Is matches the sentinel errors the response stands for. Compreface
+// reports a subject name in use by its message only.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNoFaceFound:
+		return e.Code == codeNoFaceFound
+	case ErrSubjectExists:
+		return strings.Contains(strings.ToLower(e.Message), "already")
+	case ErrServiceUnavailable:
+		return unavailableStatus(e.StatusCode)
+	}
+	return false
+}
+
+// unavailableStatus reports whether an HTTP status means the service, rather
+// than the request, failed
+func unavailableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// transportError marks a request that never got a response as
+// ErrServiceUnavailable, unless it was cancelled
+func transportError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrServiceUnavailable, err)
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"math/rand"
 	"regexp"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
@@ -18,10 +20,75 @@ var (
 	// This is critical for backward compatibility with existing Compreface subjects
 	personAliasPattern = regexp.MustCompile(`^Person .*$`)
 
-	// rng is the random number generator for subject name generation
-	rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	// subjectNamePattern matches the "Person {id} {random}" subject names
+	// generated by CreateSubjectName, capturing the ID
+	subjectNamePattern = regexp.MustCompile(`^Person (\d+) [A-Z0-9]+$`)
+
+	// names generates the random part of subject names
+	names NameGenerator = NewRandomNameGenerator(time.Now().UnixNano())
 )
 
+// NameGenerator generates the random part of subject names. Implementations
+// must be safe for concurrent use.
+type NameGenerator interface {
+	Suffix(length int) string
+}
+
+// randomNameGenerator draws suffixes from a seeded random source
+type randomNameGenerator struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewRandomNameGenerator returns a generator of random suffixes; the same
+// seed always yields the same sequence of names
+func NewRandomNameGenerator(seed int64) NameGenerator {
+	return &randomNameGenerator{rng: rand.New(rand.NewSource(seed))}
+}
+
+// Suffix returns length random uppercase letters and digits
+func (g *randomNameGenerator) Suffix(length int) string {
+	const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = charset[g.rng.Intn(len(charset))]
+	}
+	return string(b)
+}
+
+// sequentialNameGenerator numbers suffixes from 1
+type sequentialNameGenerator struct {
+	mu   sync.Mutex
+	next int
+}
+
+// NewSequentialNameGenerator returns a generator of zero-padded sequence
+// numbers, so tests can predict the subjects a run creates
+//
+// Example: the first suffix of length 16 is "0000000000000001"
+func NewSequentialNameGenerator() NameGenerator {
+	return &sequentialNameGenerator{}
+}
+
+// Suffix returns the next sequence number, zero-padded to length
+func (g *sequentialNameGenerator) Suffix(length int) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.next++
+	return fmt.Sprintf("%0*d", length, g.next)
+}
+
+// SetNameGenerator replaces the generator of subject name suffixes. A nil
+// generator restores the default, time-seeded random generator.
+func SetNameGenerator(generator NameGenerator) {
+	if generator == nil {
+		generator = NewRandomNameGenerator(time.Now().UnixNano())
+	}
+	names = generator
+}
+
 // randomSubject generates a random subject name with the specified format.
 // Maintains exact compatibility with Python implementation:
 //
@@ -33,16 +100,12 @@ var (
 //   - length: Number of random characters to generate (typically 16)
 //   - prefix: Prefix string (e.g., "Person 12345 ")
 //
-// Returns: Prefix + random alphanumeric string
+// Returns: Prefix + a suffix from the current NameGenerator, random
+// alphanumerics unless SetNameGenerator installed another
 //
 // Example: randomSubject(16, "Person 12345 ") → "Person 12345 ABC123XYZ456GHIJ"
 func randomSubject(length int, prefix string) string {
-	const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[rng.Intn(len(charset))]
-	}
-	return prefix + string(b)
+	return prefix + names.Suffix(length)
 }
 
 // createSubjectName creates a subject name for Compreface in the standard format.
@@ -61,6 +124,25 @@ func CreateSubjectName(imageID string) string {
 	return randomSubject(16, fmt.Sprintf("Person %s ", imageID))
 }
 
+// IsGeneratedSubjectName reports whether name follows the "Person ..." format
+// of subjects (and performers) created by the plugin rather than named by a user
+func IsGeneratedSubjectName(name string) bool {
+	return personAliasPattern.MatchString(name)
+}
+
+// SubjectSourceID returns the ID embedded in a generated subject name: the
+// performer ID of synced performers, or the image or scene ID the face was
+// first found in. Returns false for other names.
+//
+// Example: SubjectSourceID("Person 12345 ABC123XYZ456GHIJ") → "12345", true
+func SubjectSourceID(name string) (string, bool) {
+	match := subjectNamePattern.FindStringSubmatch(name)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
 // findPersonAlias searches performer aliases for "Person ..." pattern.
 // This is used during performer synchronization to find performers that
 // were previously created by the plugin.
@@ -94,3 +176,44 @@ func FindPersonAlias(performer *stash.Performer) string {
 
 	return ""
 }
+
+// CloseSubjects returns the distinct subjects of a recognized face that
+// reach minSimilarity and are within margin of the most similar one, most
+// similar first. Compreface lists a subject once per matching example, so
+// repeats of a subject keep their highest similarity. Returns nil when no
+// subject reaches minSimilarity; a single subject means the match is clear.
+func CloseSubjects(subjects []FaceRecognition, minSimilarity float64, margin float64) []FaceRecognition {
+	best := map[string]FaceRecognition{}
+	var order []string
+	for _, subject := range subjects {
+		if subject.Similarity < minSimilarity {
+			continue
+		}
+		seen, ok := best[subject.Subject]
+		if !ok {
+			order = append(order, subject.Subject)
+		}
+		if !ok || subject.Similarity > seen.Similarity {
+			best[subject.Subject] = subject
+		}
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	distinct := make([]FaceRecognition, 0, len(order))
+	for _, name := range order {
+		distinct = append(distinct, best[name])
+	}
+	sort.SliceStable(distinct, func(i, j int) bool {
+		return distinct[i].Similarity > distinct[j].Similarity
+	})
+
+	contenders := distinct[:1]
+	for _, subject := range distinct[1:] {
+		if distinct[0].Similarity-subject.Similarity < margin {
+			contenders = append(contenders, subject)
+		}
+	}
+	return contenders
+}
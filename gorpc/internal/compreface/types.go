@@ -1,6 +1,11 @@
 package compreface
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/throttle"
+)
 
 // Client handles API calls to Compreface service
 type Client struct {
@@ -9,7 +14,11 @@ type Client struct {
 	DetectionKey    string
 	VerificationKey string
 	MinSimilarity   float64
+	PredictionCount int      // Subjects returned per recognized face (0 = Compreface default of 1)
+	FacePlugins     []string // face_plugins requested on recognition (empty = none)
 	httpClient      *http.Client
+	limiter         *throttle.RateLimiter // Request rate limit, nil = unlimited
+	ctx             context.Context       // Cancels requests once done, nil = never
 }
 
 // FaceDetection represents a detected face from Compreface
@@ -80,6 +89,36 @@ type RecognitionResponse struct {
 	PluginsVersions map[string]string   `json:"plugins_versions"`
 }
 
+// VerificationResult is the similarity of one face in the probe image to a stored example
+type VerificationResult struct {
+	Box        BoundingBox `json:"box"`
+	Similarity float64     `json:"similarity"`
+}
+
+// VerificationResponse is the response from verifying a face against a stored example
+type VerificationResponse struct {
+	Result []VerificationResult `json:"result"`
+}
+
+// FaceMatch is the similarity of one face in the target image to the source face
+type FaceMatch struct {
+	Box        BoundingBox `json:"box"`
+	Similarity float64     `json:"similarity"`
+}
+
+// FaceVerification is the result for one face found in the source image
+type FaceVerification struct {
+	SourceImageFace struct {
+		Box BoundingBox `json:"box"`
+	} `json:"source_image_face"`
+	FaceMatches []FaceMatch `json:"face_matches"`
+}
+
+// FaceVerificationResponse is the response from the verification service
+type FaceVerificationResponse struct {
+	Result []FaceVerification `json:"result"`
+}
+
 // AddSubjectResponse is the response from adding a subject
 type AddSubjectResponse struct {
 	ImageID string `json:"image_id"`
@@ -18,20 +18,55 @@ import (
 func Load(input common.PluginInput) (*PluginConfig, error) {
 	config := &PluginConfig{
 		// Default values
-		CooldownSeconds:            10,
-		MaxBatchSize:               20,
-		MinSimilarity:              0.81,
-		MinFaceSize:                64,
-		MinConfidenceScore:         0.7,
-		MinQualityScore:            0, // 0 = use component gates (size, pose, occlusion)
-		MinProcessingQualityScore:  0, // 0 = use component gates (size, pose, occlusion)
-		EnhanceQualityScoreTrigger: 0.5,
-		EnableEmbeddingRecognition: false, // Embedding recognition disabled by default due to Compreface format incompatibility
-		ScannedTagName:             "Compreface Scanned",
-		MatchedTagName:             "Compreface Matched",
-		PartialTagName:             "Compreface Partial",
-		CompleteTagName:            "Compreface Complete",
-		SyncedTagName:              "Compreface Synced",
+		MaxBatchSize:                20,
+		MaxConcurrency:              1,
+		ErrorBudgetRate:             0.5,
+		ErrorBudgetWindow:           100,
+		ComprefaceRequestsPerSecond: 10,
+		VisionMaxConcurrentJobs:     1,
+		AdaptiveVisionPacing:        true,
+		VisionImageJobTimeout:       300,
+		VisionSceneJobTimeout:       3600,
+		StashWritesPerSecond:        10,
+		MinSimilarity:               0.81,
+		MergeSimilarity:             0.9,
+		MatchMargin:                 0.03,
+		MinFaceSize:                 64,
+		MaxImageDimension:           4096,
+		ImageDetector:               ImageDetectorAuto,
+		MinConfidenceScore:          0.7,
+		MinQualityScore:             0, // 0 = use component gates (size, pose, occlusion)
+		MinProcessingQualityScore:   0, // 0 = use component gates (size, pose, occlusion)
+		EnhanceQualityScoreTrigger:  0.5,
+		JPEGPassthroughMaxKB:        4096,
+		EnhanceEnabled:              true,
+		EnhanceModel:                "codeformer",
+		EnhanceFidelityWeight:       0.25,
+		EnableEmbeddingRecognition:  false, // Embedding recognition disabled by default due to Compreface format incompatibility
+		OcclusionStrategy:           OcclusionStrategyProcess,
+		MinClusterSize:              1,
+		SubjectExampleCount:         1,
+		ScannedTagName:              "Compreface Scanned",
+		MatchedTagName:              "Compreface Matched",
+		PartialTagName:              "Compreface Partial",
+		CompleteTagName:             "Compreface Complete",
+		SyncedTagName:               "Compreface Synced",
+		MissingFileTagName:          "Compreface Missing File",
+		FacesDetectedTagName:        "Compreface Faces Detected",
+		ReviewTagName:               "Compreface Review",
+		SceneMarkerTagName:          "Compreface Face",
+		MarkerMinGap:                10,
+		ParentTagName:               "Compreface",
+		GalleryTitleMode:            GalleryTitleOff,
+		BirthdateStrategy:           BirthdateMidpoint,
+		BatchOrder:                  BatchOrderDefault,
+		HighConfidenceTagName:       "Compreface High Confidence",
+		LowConfidenceTagName:        "Compreface Low Confidence",
+		ExcludeTagName:              "Compreface Ignore",
+		FaceGalleryMinQuality:       0.7,
+		FaceGalleryMaxCrops:         20,
+		EnableConfidenceTags:        false,
+		HighConfidenceThreshold:     0.9,
 	}
 
 	// Fetch plugin configuration from Stash
@@ -44,6 +79,13 @@ func Load(input common.PluginInput) (*PluginConfig, error) {
 		if val := getStringSetting(pluginConfig, "comprefaceUrl"); val != "" {
 			config.ComprefaceURL = val
 		}
+		if plugins := getStringListSetting(pluginConfig, "comprefaceFacePlugins"); len(plugins) > 0 {
+			config.ComprefaceFacePlugins = plugins
+			if len(plugins) == 1 && strings.EqualFold(plugins[0], "none") {
+				config.ComprefaceFacePlugins = []string{} // Recognition only
+			}
+		}
+		config.SkipDemographics = getBoolSetting(pluginConfig, "skipDemographics")
 		if val := getStringSetting(pluginConfig, "recognitionApiKey"); val != "" {
 			config.RecognitionAPIKey = val
 		}
@@ -53,18 +95,66 @@ func Load(input common.PluginInput) (*PluginConfig, error) {
 		if val := getStringSetting(pluginConfig, "verificationApiKey"); val != "" {
 			config.VerificationAPIKey = val
 		}
-		if val := getIntSetting(pluginConfig, "cooldownSeconds"); val > 0 {
-			config.CooldownSeconds = val
-		}
 		if val := getIntSetting(pluginConfig, "maxBatchSize"); val > 0 {
 			config.MaxBatchSize = val
 		}
+		if val := getIntSetting(pluginConfig, "maxConcurrency"); val > 0 {
+			config.MaxConcurrency = val
+		}
+		if val := getFloatSetting(pluginConfig, "errorBudgetRate"); val > 0 {
+			config.ErrorBudgetRate = val
+		}
+		if val := getIntSetting(pluginConfig, "errorBudgetWindow"); val > 0 {
+			config.ErrorBudgetWindow = val
+		}
+		if val := getFloatSetting(pluginConfig, "comprefaceRequestsPerSecond"); val > 0 {
+			config.ComprefaceRequestsPerSecond = val
+		}
+		if val := getIntSetting(pluginConfig, "visionMaxConcurrentJobs"); val > 0 {
+			config.VisionMaxConcurrentJobs = val
+		}
+		config.AdaptiveVisionPacing = getBoolSettingDefault(pluginConfig, "adaptiveVisionPacing", config.AdaptiveVisionPacing)
+		if val := getIntSetting(pluginConfig, "visionImageJobTimeout"); val > 0 {
+			config.VisionImageJobTimeout = val
+		}
+		if val := getIntSetting(pluginConfig, "visionSceneJobTimeout"); val > 0 {
+			config.VisionSceneJobTimeout = val
+		}
+		if val := getFloatSetting(pluginConfig, "stashWritesPerSecond"); val > 0 {
+			config.StashWritesPerSecond = val
+		}
 		if val := getFloatSetting(pluginConfig, "minSimilarity"); val > 0 {
 			config.MinSimilarity = val
 		}
+		if val := getFloatSetting(pluginConfig, "mergeSimilarity"); val > 0 {
+			config.MergeSimilarity = val
+		}
+		if val := getFloatSettingDefault(pluginConfig, "matchMargin", config.MatchMargin); val >= 0 {
+			config.MatchMargin = val
+		}
 		if val := getIntSetting(pluginConfig, "minFaceSize"); val > 0 {
 			config.MinFaceSize = val
 		}
+		if val := getIntSetting(pluginConfig, "maxImageDimension"); val > 0 {
+			config.MaxImageDimension = val
+		}
+		if val := getIntSettingDefault(pluginConfig, "jpegPassthroughMaxKB", config.JPEGPassthroughMaxKB); val >= 0 {
+			config.JPEGPassthroughMaxKB = val
+		}
+		if val := getStringSetting(pluginConfig, "visionTempDir"); val != "" {
+			config.VisionTempDir = val
+		}
+		if val := getStringSetting(pluginConfig, "doubleTakeExportPath"); val != "" {
+			config.DoubleTakeExportPath = val
+		}
+		config.ReportsDir = getStringSetting(pluginConfig, "reportsDir")
+		config.FaceGalleryDir = getStringSetting(pluginConfig, "faceGalleryDir")
+		if val := getFloatSetting(pluginConfig, "faceGalleryMinQuality"); val > 0 {
+			config.FaceGalleryMinQuality = val
+		}
+		if val := getIntSetting(pluginConfig, "faceGalleryMaxCrops"); val > 0 {
+			config.FaceGalleryMaxCrops = val
+		}
 		if val := getFloatSetting(pluginConfig, "minConfidenceScore"); val > 0 {
 			config.MinConfidenceScore = val
 		}
@@ -74,21 +164,115 @@ func Load(input common.PluginInput) (*PluginConfig, error) {
 		if val := getFloatSetting(pluginConfig, "minProcessingQualityScore"); val > 0 {
 			config.MinProcessingQualityScore = val
 		}
+		if val := getFloatSetting(pluginConfig, "highConfidenceThreshold"); val > 0 {
+			config.HighConfidenceThreshold = val
+		}
+		config.EnhanceEnabled = getBoolSettingDefault(pluginConfig, "enhanceEnabled", config.EnhanceEnabled)
+		if val := getStringSetting(pluginConfig, "enhanceModel"); strings.TrimSpace(val) != "" {
+			config.EnhanceModel = strings.ToLower(strings.TrimSpace(val))
+		}
+		if val := getFloatSetting(pluginConfig, "enhanceFidelityWeight"); val > 0 {
+			if val > 1 {
+				log.Warnf("Enhancement fidelity weight %.2f is above 1.0, using 1.0", val)
+				val = 1
+			}
+			config.EnhanceFidelityWeight = val
+		}
+		config.EnableConfidenceTags = getBoolSetting(pluginConfig, "confidenceTags")
+		config.CreateSceneMarkers = getBoolSetting(pluginConfig, "createSceneMarkers")
+		config.OverlayOCR = getBoolSetting(pluginConfig, "overlayOcr")
+		config.OverlayOCRLanguages = getStringListSetting(pluginConfig, "overlayOcrLanguages")
+		if val := getFloatSettingDefault(pluginConfig, "markerMinGap", config.MarkerMinGap); val >= 0 {
+			config.MarkerMinGap = val
+		}
+		config.TriggerMetadataScan = getBoolSetting(pluginConfig, "triggerMetadataScan")
+		config.EmbeddingStore = getBoolSetting(pluginConfig, "embeddingStore")
+		config.ReviewNewFaces = getBoolSetting(pluginConfig, "reviewNewFaces")
+		if val := getIntSetting(pluginConfig, "creationMinRating"); val > 0 {
+			config.CreationMinRating = val
+		}
+		config.CreationOrganized = getBoolSetting(pluginConfig, "creationOrganized")
+		if val := getIntSetting(pluginConfig, "minClusterSize"); val > 0 {
+			config.MinClusterSize = val
+		}
+		if val := getIntSetting(pluginConfig, "subjectExampleCount"); val > 0 {
+			config.SubjectExampleCount = val
+		}
+		if val := getStringSetting(pluginConfig, "occlusionStrategy"); val != "" {
+			config.OcclusionStrategy = parseOcclusionStrategy(val)
+		}
+		if val := getStringSetting(pluginConfig, "sceneFaceRules"); val != "" {
+			config.SceneFaceRules = ParseSceneFaceRules(val)
+		}
+		if val := getStringSetting(pluginConfig, "similarityPresets"); val != "" {
+			config.SimilarityPresets = ParseSimilarityPresets(val)
+		}
+		if val := getStringSetting(pluginConfig, "imageDetector"); val != "" {
+			config.ImageDetector = parseImageDetector(val)
+		}
+		if val := getStringSetting(pluginConfig, "galleryTitleMode"); val != "" {
+			config.GalleryTitleMode = parseGalleryTitleMode(val)
+		}
+		if val := getStringSetting(pluginConfig, "birthdateStrategy"); val != "" {
+			config.BirthdateStrategy = parseBirthdateStrategy(val)
+		}
+		if val := getStringSetting(pluginConfig, "batchOrder"); val != "" {
+			config.BatchOrder = parseBatchOrder(val)
+		}
 		if val := getStringSetting(pluginConfig, "scannedTagName"); val != "" {
 			config.ScannedTagName = val
 		}
 		if val := getStringSetting(pluginConfig, "matchedTagName"); val != "" {
 			config.MatchedTagName = val
 		}
+		if val := getStringSetting(pluginConfig, "missingFileTagName"); val != "" {
+			config.MissingFileTagName = val
+		}
+		if val := getStringSetting(pluginConfig, "reviewTagName"); val != "" {
+			config.ReviewTagName = val
+		}
+		if val := getStringSetting(pluginConfig, "parentTagName"); val != "" {
+			config.ParentTagName = val
+			if strings.EqualFold(val, "none") {
+				config.ParentTagName = "" // Top-level tags
+			}
+		}
 		if val := getStringSetting(pluginConfig, "visionServiceUrl"); val != "" {
 			config.VisionServiceURL = val
 		}
+		if val := getStringSetting(pluginConfig, "visionServiceToken"); val != "" {
+			config.VisionServiceToken = val
+		}
 		if val := getStringSetting(pluginConfig, "frameServerUrl"); val != "" {
 			config.FrameServerURL = val
 		}
 		if val := getStringSetting(pluginConfig, "stashHostUrl"); val != "" {
 			config.StashHostURL = val
 		}
+		if val := getStringSetting(pluginConfig, "externalStashUrl"); val != "" {
+			if external, err := ParseExternalStashURL(val); err != nil {
+				log.Warnf("Ignoring external Stash URL: %v", err)
+			} else {
+				config.ExternalStashURL = external
+			}
+		}
+		config.OTLPEndpoint = getStringSetting(pluginConfig, "otlpEndpoint")
+		config.WebhookURL = getStringSetting(pluginConfig, "webhookUrl")
+		config.PrioritizeUnidentified = getBoolSetting(pluginConfig, "prioritizeUnidentified")
+		if val := getStringSetting(pluginConfig, "excludeTagName"); val != "" {
+			config.ExcludeTagName = val
+		}
+		config.ExclusionTagNames = getStringListSetting(pluginConfig, "exclusionTags")
+		config.StashBoxEndpoints = getStringListSetting(pluginConfig, "stashBoxEndpoints")
+		config.AnonymizationMode = getBoolSetting(pluginConfig, "anonymizationMode")
+		config.BackgroundFriendly = getBoolSetting(pluginConfig, "backgroundFriendly")
+		config.RandomSeed = getIntSetting(pluginConfig, "randomSeed")
+		config.TestMode = getBoolSetting(pluginConfig, "testMode")
+	}
+
+	if config.BackgroundFriendly {
+		config.ApplyBackgroundFriendly()
+		log.Info("Background-friendly mode: one job at a time, pausing between faces")
 	}
 
 	// Resolve Compreface URL with auto-detection
@@ -111,7 +295,11 @@ func Load(input common.PluginInput) (*PluginConfig, error) {
 		log.Infof("Frame Server not configured, using default: %s", config.FrameServerURL)
 	}
 
-	if config.StashHostURL != "" {
+	if config.ExternalStashURL != "" {
+		// Used as given: the proxy's scheme, port and name are what clients see
+		config.StashHostURL = config.ExternalStashURL
+		log.Infof("Stash Host URL set to external URL: %s", config.StashHostURL)
+	} else if config.StashHostURL != "" {
 		config.StashHostURL = resolveServiceURL(config.StashHostURL, "host.docker.internal", "9999")
 		log.Infof("Stash Host URL configured at: %s", config.StashHostURL)
 	} else {
@@ -119,6 +307,12 @@ func Load(input common.PluginInput) (*PluginConfig, error) {
 		config.StashHostURL = fmt.Sprintf("%s://%s:%d", input.ServerConnection.Scheme, input.ServerConnection.Host, input.ServerConnection.Port)
 	}
 
+	// Test mode replaces Compreface and Vision with in-process fakes, so API keys are not needed
+	if config.TestMode {
+		log.Warn("Test mode enabled: Compreface and Vision Service will be replaced with fake clients")
+		return config, nil
+	}
+
 	// Validate required settings
 	if config.RecognitionAPIKey == "" {
 		return nil, fmt.Errorf("recognition API key is required")
@@ -130,6 +324,183 @@ func Load(input common.PluginInput) (*PluginConfig, error) {
 	return config, nil
 }
 
+// ParseExternalStashURL validates an external Stash URL: an absolute http or
+// https URL with a host and no query or fragment. The path is kept as a
+// reverse proxy prefix, without its trailing slash.
+func ParseExternalStashURL(val string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(val))
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", val, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("URL %q must start with http:// or https://", val)
+	}
+	if parsed.Host == "" {
+		return "", fmt.Errorf("URL %q has no host", val)
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "", fmt.Errorf("URL %q must not have a query or fragment", val)
+	}
+	parsed.Path = strings.TrimRight(parsed.Path, "/")
+	parsed.RawPath = ""
+	return parsed.String(), nil
+}
+
+// parseOcclusionStrategy normalizes an occlusion strategy setting, falling back
+// to OcclusionStrategyProcess for unrecognized values
+func parseOcclusionStrategy(val string) string {
+	switch strategy := strings.ToLower(strings.TrimSpace(val)); strategy {
+	case OcclusionStrategyProcess, OcclusionStrategyAlternate, OcclusionStrategyEnhance, OcclusionStrategySkip:
+		return strategy
+	default:
+		log.Warnf("Unknown occlusion strategy '%s', using '%s'", val, OcclusionStrategyProcess)
+		return OcclusionStrategyProcess
+	}
+}
+
+// parseImageDetector normalizes an image detector setting, falling back to
+// ImageDetectorAuto for unrecognized values
+func parseImageDetector(val string) string {
+	switch detector := strings.ToLower(strings.TrimSpace(val)); detector {
+	case ImageDetectorAuto, ImageDetectorVision, ImageDetectorCompreface:
+		return detector
+	default:
+		log.Warnf("Unknown image detector '%s', using '%s'", val, ImageDetectorAuto)
+		return ImageDetectorAuto
+	}
+}
+
+// parseGalleryTitleMode normalizes a gallery title mode setting, falling back
+// to GalleryTitleOff for unrecognized values
+func parseGalleryTitleMode(val string) string {
+	switch mode := strings.ToLower(strings.TrimSpace(val)); mode {
+	case GalleryTitleOff, GalleryTitleSuggest, GalleryTitleSet:
+		return mode
+	default:
+		log.Warnf("Unknown gallery title mode '%s', using '%s'", val, GalleryTitleOff)
+		return GalleryTitleOff
+	}
+}
+
+// parseBatchOrder normalizes a batch order setting, falling back to
+// BatchOrderDefault for unrecognized values
+func parseBatchOrder(val string) string {
+	switch order := strings.ToLower(strings.TrimSpace(val)); order {
+	case BatchOrderDefault, BatchOrderRandom, BatchOrderOldest, BatchOrderNewest, BatchOrderFailures:
+		return order
+	default:
+		log.Warnf("Unknown batch order '%s', using '%s'", val, BatchOrderDefault)
+		return BatchOrderDefault
+	}
+}
+
+// parseBirthdateStrategy normalizes a birthdate strategy setting, falling
+// back to BirthdateMidpoint for unrecognized values
+func parseBirthdateStrategy(val string) string {
+	switch strategy := strings.ToLower(strings.TrimSpace(val)); strategy {
+	case BirthdateMidpoint, BirthdateLowerBound, BirthdateNone:
+		return strategy
+	default:
+		log.Warnf("Unknown birthdate strategy '%s', using '%s'", val, BirthdateMidpoint)
+		return BirthdateMidpoint
+	}
+}
+
+// ParseSceneFaceRules parses semicolon-separated scene face rules of the form
+// "<performers>[+]:<param>=<value>,..." (e.g. "1:maxFaces=10; 4+:maxFaces=100").
+// Supported parameters are maxFaces, samplingInterval and minConfidence.
+// Malformed rules are logged and skipped.
+func ParseSceneFaceRules(val string) []SceneFaceRule {
+	var rules []SceneFaceRule
+	for _, raw := range strings.Split(val, ";") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		rule, err := parseSceneFaceRule(raw)
+		if err != nil {
+			log.Warnf("Ignoring scene face rule '%s': %v", raw, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// parseSceneFaceRule parses a single "<performers>[+]:<param>=<value>,..." rule
+func parseSceneFaceRule(raw string) (SceneFaceRule, error) {
+	var rule SceneFaceRule
+
+	count, params, ok := strings.Cut(raw, ":")
+	if !ok {
+		return rule, fmt.Errorf("expected <performers>:<param>=<value>")
+	}
+	count = strings.TrimSpace(count)
+	if strings.HasSuffix(count, "+") {
+		rule.OrMore = true
+		count = strings.TrimSuffix(count, "+")
+	}
+	performers, err := strconv.Atoi(count)
+	if err != nil || performers < 0 {
+		return rule, fmt.Errorf("invalid performer count '%s'", count)
+	}
+	rule.Performers = performers
+
+	for _, param := range strings.Split(params, ",") {
+		key, value, ok := strings.Cut(param, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return rule, fmt.Errorf("expected <param>=<value>, got '%s'", strings.TrimSpace(param))
+		}
+
+		switch key {
+		case "maxFaces":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return rule, fmt.Errorf("maxFaces must be a positive integer")
+			}
+			rule.MaxFaces = n
+		case "samplingInterval":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil || f <= 0 {
+				return rule, fmt.Errorf("samplingInterval must be a positive number of seconds")
+			}
+			rule.SamplingInterval = f
+		case "minConfidence":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil || f <= 0 || f > 1 {
+				return rule, fmt.Errorf("minConfidence must be between 0 and 1")
+			}
+			rule.MinConfidence = f
+		default:
+			return rule, fmt.Errorf("unknown parameter '%s'", key)
+		}
+	}
+	return rule, nil
+}
+
+// ParseSimilarityPresets parses semicolon-separated similarity presets of the
+// form "<model>=<similarity>" (e.g. "arcface-r100=0.75; facenet=0.81").
+// Malformed presets are logged and skipped.
+func ParseSimilarityPresets(val string) []SimilarityPreset {
+	var presets []SimilarityPreset
+	for _, raw := range strings.Split(val, ";") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		model, value, ok := strings.Cut(raw, "=")
+		model = strings.TrimSpace(model)
+		similarity, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || model == "" || err != nil || similarity <= 0 || similarity > 1 {
+			log.Warnf("Ignoring similarity preset '%s': expected <model>=<similarity between 0 and 1>", raw)
+			continue
+		}
+		presets = append(presets, SimilarityPreset{Model: model, MinSimilarity: similarity})
+	}
+	return presets
+}
+
 // getPluginConfiguration fetches plugin configuration from Stash via GraphQL HTTP request
 func getPluginConfiguration(serverConnection common.StashServerConnection) (map[string]interface{}, error) {
 	// Build Stash GraphQL URL
@@ -202,6 +573,52 @@ func getStringSetting(config map[string]interface{}, key string) string {
 	return ""
 }
 
+// getStringListSetting retrieves a comma-separated list setting from plugin config
+func getStringListSetting(config map[string]interface{}, key string) []string {
+	var values []string
+	for _, item := range strings.Split(getStringSetting(config, key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// getBoolSetting retrieves a boolean setting from plugin config
+func getBoolSetting(config map[string]interface{}, key string) bool {
+	val, ok := config[key]
+	if !ok || val == nil {
+		return false
+	}
+	switch v := val.(type) {
+	case bool:
+		return v
+	case string:
+		b, err := strconv.ParseBool(v)
+		return err == nil && b
+	default:
+		return getIntSetting(config, key) != 0
+	}
+}
+
+// getBoolSettingDefault retrieves a boolean setting from plugin config,
+// returning def when the setting was never saved
+func getBoolSettingDefault(config map[string]interface{}, key string, def bool) bool {
+	if val, ok := config[key]; !ok || val == nil {
+		return def
+	}
+	return getBoolSetting(config, key)
+}
+
+// getIntSettingDefault retrieves an integer setting from plugin config,
+// returning def when the setting was never saved
+func getIntSettingDefault(config map[string]interface{}, key string, def int) int {
+	if val, ok := config[key]; !ok || val == nil || val == "" {
+		return def
+	}
+	return getIntSetting(config, key)
+}
+
 // getIntSetting retrieves an integer setting from plugin config
 func getIntSetting(config map[string]interface{}, key string) int {
 	val, ok := config[key]
@@ -254,6 +671,15 @@ func getIntSetting(config map[string]interface{}, key string) int {
 	}
 }
 
+// getFloatSettingDefault retrieves a float setting from plugin config,
+// returning def when the setting was never saved
+func getFloatSettingDefault(config map[string]interface{}, key string, def float64) float64 {
+	if val, ok := config[key]; !ok || val == nil || val == "" {
+		return def
+	}
+	return getFloatSetting(config, key)
+}
+
 // getFloatSetting retrieves a float setting from plugin config
 func getFloatSetting(config map[string]interface{}, key string) float64 {
 	val, ok := config[key]
@@ -305,6 +731,7 @@ func getFloatSetting(config map[string]interface{}, key string) float64 {
 
 // resolveServiceURL resolves the service URL with proper DNS lookup.
 // Handles IP addresses, hostnames, container names, and localhost.
+// Any path component (e.g. a reverse proxy prefix like /compreface) is kept.
 //
 // Based on auto-caption pattern for Docker Compose compatibility.
 //
@@ -334,6 +761,7 @@ func resolveServiceURL(configuredURL string, defaultContainerName string, defaul
 	hostname := parsedURL.Hostname()
 	port := parsedURL.Port()
 	scheme := parsedURL.Scheme
+	path := strings.TrimRight(parsedURL.Path, "/") // Preserve reverse proxy path prefix
 
 	// Default scheme if not specified
 	if scheme == "" {
@@ -347,21 +775,21 @@ func resolveServiceURL(configuredURL string, defaultContainerName string, defaul
 
 	// Case 1: localhost - use as-is
 	if hostname == "localhost" || hostname == "127.0.0.1" {
-		resolvedURL := fmt.Sprintf("%s://%s:%s", scheme, hostname, port)
+		resolvedURL := fmt.Sprintf("%s://%s:%s%s", scheme, hostname, port, path)
 		log.Infof("Using localhost service URL: %s", resolvedURL)
 		return resolvedURL
 	}
 
 	// Case 1b: host.docker.internal - use as-is (Docker special hostname, no DNS resolution)
 	if hostname == "host.docker.internal" {
-		resolvedURL := fmt.Sprintf("%s://%s:%s", scheme, hostname, port)
+		resolvedURL := fmt.Sprintf("%s://%s:%s%s", scheme, hostname, port, path)
 		log.Infof("Using Docker host gateway URL: %s", resolvedURL)
 		return resolvedURL
 	}
 
 	// Case 2: Already an IP address - use as-is
 	if net.ParseIP(hostname) != nil {
-		resolvedURL := fmt.Sprintf("%s://%s:%s", scheme, hostname, port)
+		resolvedURL := fmt.Sprintf("%s://%s:%s%s", scheme, hostname, port, path)
 		log.Infof("Using IP-based service URL: %s", resolvedURL)
 		return resolvedURL
 	}
@@ -372,19 +800,19 @@ func resolveServiceURL(configuredURL string, defaultContainerName string, defaul
 	if err != nil {
 		log.Warnf("DNS lookup failed for '%s': %v, using hostname as-is", hostname, err)
 		// Return original URL even if DNS fails - it might still work
-		resolvedURL := fmt.Sprintf("%s://%s:%s", scheme, hostname, port)
+		resolvedURL := fmt.Sprintf("%s://%s:%s%s", scheme, hostname, port, path)
 		return resolvedURL
 	}
 
 	if len(addrs) == 0 {
 		log.Warnf("No IP addresses found for hostname '%s', using hostname as-is", hostname)
-		resolvedURL := fmt.Sprintf("%s://%s:%s", scheme, hostname, port)
+		resolvedURL := fmt.Sprintf("%s://%s:%s%s", scheme, hostname, port, path)
 		return resolvedURL
 	}
 
 	// Use the first resolved IP address
 	resolvedIP := addrs[0].String()
-	resolvedURL := fmt.Sprintf("%s://%s:%s", scheme, resolvedIP, port)
+	resolvedURL := fmt.Sprintf("%s://%s:%s%s", scheme, resolvedIP, port, path)
 	log.Infof("Resolved '%s' to %s", hostname, resolvedURL)
 	return resolvedURL
 }
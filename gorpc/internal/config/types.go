@@ -1,26 +1,189 @@
 package config
 
+import "strings"
+
 // PluginConfig holds plugin settings from Stash
 type PluginConfig struct {
-	ComprefaceURL                  string
-	RecognitionAPIKey              string
-	DetectionAPIKey                string
-	VerificationAPIKey             string
-	VisionServiceURL               string
-	FrameServerURL                 string
-	StashHostURL                   string
-	CooldownSeconds                int
-	MaxBatchSize                   int
-	MinSimilarity                  float64
-	MinFaceSize                    int
-	MinConfidenceScore        float64 // Minimum confidence score for face detection
-	MinQualityScore           float64 // Minimum composite quality for subject creation (0=use component gates)
-	MinProcessingQualityScore float64 // Minimum composite quality for recognition (0=use component gates)
-	EnhanceQualityScoreTrigger     float64 // Quality score threshold to trigger enhancement
-	EnableEmbeddingRecognition     bool    // Enable embedding-based recognition (default: false, requires compatible embeddings)
-	ScannedTagName                 string
-	MatchedTagName                 string
-	PartialTagName                 string
-	CompleteTagName                string
-	SyncedTagName                  string
+	ComprefaceURL               string
+	RecognitionAPIKey           string
+	DetectionAPIKey             string
+	VerificationAPIKey          string
+	VisionServiceURL            string
+	FrameServerURL              string
+	VisionServiceToken          string // Optional bearer token for Vision Service and frame server (auth proxy)
+	StashHostURL                string
+	ExternalStashURL            string // Stash's URL behind a TLS-terminating proxy; overrides StashHostURL and the server connection's address
+	MaxBatchSize                int
+	MaxConcurrency              int      // Images recognized in parallel by batch image recognition
+	ErrorBudgetRate             float64  // Failure rate above which a batch task aborts (>=1 disables)
+	ErrorBudgetWindow           int      // Number of most recent items the failure rate is measured over
+	ComprefaceRequestsPerSecond float64  // Compreface API request rate limit
+	ComprefaceFacePlugins       []string // face_plugins requested on Compreface recognition; nil uses the client default, empty requests none
+	SkipDemographics            bool     // Request no age and gender estimates from Compreface or the Vision Service
+	VisionMaxConcurrentJobs     int      // Vision Service jobs in flight at once
+	AdaptiveVisionPacing        bool     // Delay Vision jobs and lower their concurrency while the Vision Service reports GPU or queue pressure
+	VisionImageJobTimeout       int      // Seconds to wait for an image Vision Service job before cancelling it
+	VisionSceneJobTimeout       int      // Seconds to wait for a scene Vision Service job before cancelling and retrying it
+	StashWritesPerSecond        float64  // Queued Stash write rate limit
+	MinSimilarity               float64
+	SimilarityPresets           []SimilarityPreset // MinSimilarity overrides by detected Compreface recognition model
+	MergeSimilarity             float64            // Minimum verification similarity for mergeDuplicatePerformers to treat two subjects as one person
+	MatchMargin                 float64            // Similarity within which a runner-up subject makes a match close enough to tie-break (0 = always take the best)
+	MinFaceSize                 int
+	MaxImageDimension           int             // Longest side (px) above which images are downscaled before Vision submission
+	JPEGPassthroughMaxKB        int             // Largest upright sRGB JPEG (KB) used as-is instead of re-encoded; 0 always re-encodes
+	VisionTempDir               string          // Directory shared with the Vision Service for downscaled images
+	ImageDetector               string          // Face detector for image identification: auto, vision, compreface (default: auto)
+	MinConfidenceScore          float64         // Minimum confidence score for face detection
+	MinQualityScore             float64         // Minimum composite quality for subject creation (0=use component gates)
+	MinProcessingQualityScore   float64         // Minimum composite quality for recognition (0=use component gates)
+	EnhanceQualityScoreTrigger  float64         // Quality score threshold to trigger enhancement
+	EnhanceEnabled              bool            // Request face enhancement from the Vision Service (default: true)
+	EnhanceModel                string          // Face enhancement model: codeformer, gfpgan (default: codeformer)
+	EnhanceFidelityWeight       float64         // Enhancement fidelity vs quality tradeoff 0.0-1.0 (default: 0.25)
+	EnableEmbeddingRecognition  bool            // Enable embedding-based recognition (default: false, requires compatible embeddings)
+	EmbeddingStore              bool            // Remember face match decisions and embeddings locally across runs
+	ReviewNewFaces              bool            // Queue unmatched faces for review instead of creating subjects and performers
+	CreationMinRating           int             // Batch tasks only create subjects and performers from media rated at least this (1-100); 0 disables the gate
+	CreationOrganized           bool            // Batch tasks only create subjects and performers from organized media (or media passing CreationMinRating)
+	OcclusionStrategy           string          // How to handle occluded faces: process, alternate, enhance, skip (default: process)
+	MinClusterSize              int             // Minimum detections backing a scene face cluster before it is processed
+	SceneFaceRules              []SceneFaceRule // Scene face detection overrides by the scene's existing performer count
+	SubjectExampleCount         int             // Distinct detections stored as examples when creating a subject from a scene face cluster
+	ScannedTagName              string
+	MatchedTagName              string
+	PartialTagName              string
+	CompleteTagName             string
+	SyncedTagName               string
+	MissingFileTagName          string // Tag applied to media whose file is missing on disk
+	FacesDetectedTagName        string // Tag applied in anonymization mode when faces are detected
+	ReviewTagName               string // Tag applied to gallery images that conflict with the gallery majority, and to media with faces awaiting review
+	SceneMarkerTagName          string // Primary tag of the scene markers created at performer appearances
+	ParentTagName               string // Parent tag the plugin's tags are nested under; empty keeps them at the top level
+	GalleryTitleMode            string // Gallery title from dominant performers after identification: off, suggest, set (default: off)
+	BirthdateStrategy           string // Birthdate of created performers from the estimated age range: midpoint, lower, none (default: midpoint)
+	HighConfidenceTagName       string
+	LowConfidenceTagName        string
+	EnableConfidenceTags        bool     // Tag media by the worst match similarity among associated performers
+	CreateSceneMarkers          bool     // Create scene markers where each recognized performer appears
+	OverlayOCR                  bool     // Read on-screen text at scene faces' frames (Vision semantics module) and favour performers it names
+	OverlayOCRLanguages         []string // OCR language codes (e.g. "en", "ja"); empty uses the Vision Service default
+	MarkerMinGap                float64  // Appearances less than this many seconds apart share one scene marker (default: 10)
+	TriggerMetadataScan         bool     // Rescan processed scenes' files in Stash after scene recognition
+	HighConfidenceThreshold     float64  // Worst match similarity at or above this is tagged high confidence
+	PrioritizeUnidentified      bool     // Process media with no performers before media that already has performers
+	BatchOrder                  string   // Order batch recognition fetches media in: default, random, oldest, newest, failures (default: default)
+	AnonymizationMode           bool     // Detect and tag only; never store crops, embeddings or subjects
+	BackgroundFriendly          bool     // Run at low priority beside Stash playback: one job at a time, pauses between faces, smaller JPEGs
+	DoubleTakeExportPath        string   // double-take match/train export read by importDoubleTake (relative to the plugin directory)
+	ReportsDir                  string   // Directory receiving a JSON report per batch run (relative to the plugin directory); empty disables reports
+	FaceGalleryDir              string   // Folder inside a Stash library receiving recognized face crops, one subfolder per performer; empty disables the face gallery
+	FaceGalleryMinQuality       float64  // Minimum composite quality of matched face crops saved to the face gallery (default: 0.7)
+	FaceGalleryMaxCrops         int      // Crops kept per performer in the face gallery(default: 20)
+	ExcludeTagName              string   // Tag exempting an image, scene, gallery or studio (with its media) from batch tasks; looked up only, never created
+	ExclusionTagNames           []string // Shared exclusion tags (e.g. "AI: Exclude"); tagged items are left out of every task filter
+	StashBoxEndpoints           []string // stash-box endpoints (as configured in Stash) searched before creating a performer for a new face
+	OTLPEndpoint                string   // OpenTelemetry collector (OTLP/HTTP) receiving pipeline stage spans; empty disables export
+	WebhookURL                  string   // URL receiving each batch task's result as JSON; empty disables notifications
+	RandomSeed                  int      // Seeds subject names and the random batch order so runs are reproducible; 0 seeds from the clock
+	TestMode                    bool     // Replace Compreface and Vision Service with in-process fakes (CI/testing only)
+}
+
+// Limits applied by background-friendly mode
+const (
+	BackgroundMaxImageDimension = 2048 // Longest side (px) of images submitted to the Vision Service
+	BackgroundJPEGQuality       = 75   // Quality of JPEGs encoded for Compreface and the Vision Service
+)
+
+// ApplyBackgroundFriendly lowers concurrency and image sizes so tasks can run
+// continuously on the Stash host without starving playback
+func (c *PluginConfig) ApplyBackgroundFriendly() {
+	c.MaxConcurrency = 1
+	c.VisionMaxConcurrentJobs = 1
+	if c.MaxImageDimension <= 0 || c.MaxImageDimension > BackgroundMaxImageDimension {
+		c.MaxImageDimension = BackgroundMaxImageDimension
+	}
+}
+
+// CreatesFromMedia reports whether unmatched faces of a media item pass the
+// creation gate: true when neither CreationMinRating nor CreationOrganized
+// is set, or when the item is rated at least CreationMinRating or organized
+// with CreationOrganized. rating is the item's rating100, nil when unrated.
+func (c *PluginConfig) CreatesFromMedia(rating *int, organized bool) bool {
+	if c.CreationMinRating <= 0 && !c.CreationOrganized {
+		return true
+	}
+	if c.CreationMinRating > 0 && rating != nil && *rating >= c.CreationMinRating {
+		return true
+	}
+	return c.CreationOrganized && organized
+}
+
+// SimilarityPreset overrides MinSimilarity when the Compreface recognition
+// model (calculator plugin) contains Model, e.g. "arcface-r100"
+type SimilarityPreset struct {
+	Model         string
+	MinSimilarity float64
+}
+
+// Matches reports whether the preset applies to a recognition model
+func (p SimilarityPreset) Matches(model string) bool {
+	return strings.Contains(strings.ToLower(model), strings.ToLower(p.Model))
 }
+
+// SceneFaceRule overrides scene face detection parameters for scenes with a
+// given number of performers already tagged. Zero overrides keep the default.
+type SceneFaceRule struct {
+	Performers       int     // Performer count the rule applies to
+	OrMore           bool    // Also apply to scenes with more performers
+	MaxFaces         int     // Maximum unique faces to extract
+	SamplingInterval float64 // Seconds between sampled frames
+	MinConfidence    float64 // Minimum detection confidence
+}
+
+// Matches reports whether the rule applies to a scene with the given number
+// of performers
+func (r SceneFaceRule) Matches(performers int) bool {
+	if r.OrMore {
+		return performers >= r.Performers
+	}
+	return performers == r.Performers
+}
+
+// Face detectors for image identification
+const (
+	ImageDetectorAuto       = "auto"       // Vision Service when healthy, Compreface otherwise
+	ImageDetectorVision     = "vision"     // Vision Service only; fail when unavailable
+	ImageDetectorCompreface = "compreface" // Compreface recognition only
+)
+
+// Gallery title modes applied after gallery identification
+const (
+	GalleryTitleOff     = "off"     // Leave gallery titles alone
+	GalleryTitleSuggest = "suggest" // Log a suggested title
+	GalleryTitleSet     = "set"     // Update the gallery title
+)
+
+// Orders batch recognition fetches media in
+const (
+	BatchOrderDefault  = "default"  // Stash's default order (images) or ID order (scenes)
+	BatchOrderRandom   = "random"   // Shuffled, with a new shuffle each run
+	BatchOrderOldest   = "oldest"   // Oldest media first
+	BatchOrderNewest   = "newest"   // Newest media first
+	BatchOrderFailures = "failures" // Default order, media that failed before last, fewest failures first
+)
+
+// Birthdate strategies for performers created from an estimated age range
+const (
+	BirthdateMidpoint   = "midpoint" // Birthdate from the middle of the range
+	BirthdateLowerBound = "lower"    // Birthdate from the youngest age in the range
+	BirthdateNone       = "none"     // Leave the birthdate unset
+)
+
+// Occlusion strategies for faces flagged as occluded by the Vision Service
+const (
+	OcclusionStrategyProcess   = "process"   // Process the occluded representative frame as-is
+	OcclusionStrategyAlternate = "alternate" // Use the least-occluded alternative detection, skip if none
+	OcclusionStrategyEnhance   = "enhance"   // Re-extract the frame with face enhancement enabled
+	OcclusionStrategySkip      = "skip"      // Skip occluded faces entirely
+)
@@ -0,0 +1,460 @@
+package fake
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/stashapp/stash/pkg/plugin/common/log"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+)
+
+// ============================================================================
+// Fake Compreface Service - Offline Test Mode
+// ============================================================================
+//
+// In-process HTTP server implementing the subset of the Compreface REST API
+// used by compreface.Client. Subjects are held in memory for the lifetime of
+// the server.
+//
+// Matching is deterministic: a recognized face matches a subject only if the
+// exact same face bytes were previously added to that subject. This lets the
+// full rpc pipeline exercise both the "create new subject" and "match existing
+// subject" paths within a single task run.
+//
+// ============================================================================
+
+// FakeSimilarity is the similarity reported for a matched face
+const FakeSimilarity = 0.99
+
+// FakeModel is the recognition model reported with status=true
+const FakeModel = "fake.Calculator@test"
+
+// ComprefaceServer is an in-memory fake of the Compreface API
+type ComprefaceServer struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	subjects map[string][]string // subject -> image IDs
+	faces    map[string]string   // face hash -> subject
+	images   map[string][]byte   // image ID -> face bytes
+	nextID   int
+}
+
+// NewComprefaceServer starts a fake Compreface server
+func NewComprefaceServer() *ComprefaceServer {
+	f := &ComprefaceServer{
+		subjects: make(map[string][]string),
+		faces:    make(map[string]string),
+		images:   make(map[string][]byte),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/recognition/recognize", f.handleRecognize)
+	mux.HandleFunc("/api/v1/recognition/faces", f.handleFaces)
+	mux.HandleFunc("/api/v1/recognition/faces/", f.handleFace)
+	mux.HandleFunc("/api/v1/recognition/subjects", f.handleListSubjects)
+	mux.HandleFunc("/api/v1/recognition/subjects/", f.handleSubject)
+	mux.HandleFunc("/api/v1/recognition/embeddings/recognize", f.handleRecognizeEmbeddings)
+	mux.HandleFunc("/api/v1/detection/detect", f.handleDetect)
+	mux.HandleFunc("/api/v1/verification/verify", f.handleVerifyFaces)
+	mux.HandleFunc("/api/v1/static/", f.handleStatic)
+
+	f.server = httptest.NewServer(mux)
+	log.Infof("Fake Compreface server listening at %s", f.server.URL)
+	return f
+}
+
+// URL returns the base URL of the fake server
+func (f *ComprefaceServer) URL() string {
+	return f.server.URL
+}
+
+// Close shuts down the fake server
+func (f *ComprefaceServer) Close() {
+	f.server.Close()
+}
+
+// Subjects returns the names of all subjects currently stored
+func (f *ComprefaceServer) Subjects() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	subjects := make([]string, 0, len(f.subjects))
+	for subject := range f.subjects {
+		subjects = append(subjects, subject)
+	}
+	return subjects
+}
+
+// handleRecognize handles POST /api/v1/recognition/recognize
+func (f *ComprefaceServer) handleRecognize(w http.ResponseWriter, r *http.Request) {
+	faceBytes, err := readUpload(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	f.mu.Lock()
+	subject, matched := f.faces[hashBytes(faceBytes)]
+	f.mu.Unlock()
+
+	result := compreface.RecognitionResult{
+		Box:      cannedComprefaceBox(),
+		Subjects: []compreface.FaceRecognition{},
+		Age:      compreface.AgeRange{Low: 25, High: 35, Probability: 0.9},
+		Gender:   compreface.Gender{Value: "female", Probability: 0.9},
+		Mask:     compreface.Mask{Value: "without_mask", Probability: 0.99},
+	}
+	if matched {
+		result.Subjects = append(result.Subjects, compreface.FaceRecognition{
+			Subject:    subject,
+			Similarity: FakeSimilarity,
+		})
+	}
+
+	response := compreface.RecognitionResponse{
+		Result: []compreface.RecognitionResult{result},
+	}
+	if r.URL.Query().Get("status") == "true" {
+		response.PluginsVersions = map[string]string{"calculator": FakeModel}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleFaces handles POST (add) and GET (list) on /api/v1/recognition/faces
+func (f *ComprefaceServer) handleFaces(w http.ResponseWriter, r *http.Request) {
+	subject := r.URL.Query().Get("subject")
+
+	switch r.Method {
+	case http.MethodPost:
+		if subject == "" {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("subject is required"))
+			return
+		}
+		faceBytes, err := readUpload(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		f.mu.Lock()
+		f.nextID++
+		imageID := fmt.Sprintf("fake-%08d", f.nextID)
+		f.subjects[subject] = append(f.subjects[subject], imageID)
+		f.faces[hashBytes(faceBytes)] = subject
+		f.images[imageID] = faceBytes
+		f.mu.Unlock()
+
+		writeJSON(w, http.StatusCreated, compreface.AddSubjectResponse{
+			ImageID: imageID,
+			Subject: subject,
+		})
+
+	case http.MethodGet:
+		f.mu.Lock()
+		faces := []compreface.FaceListItem{}
+		for name, imageIDs := range f.subjects {
+			if subject != "" && name != subject {
+				continue
+			}
+			for _, imageID := range imageIDs {
+				faces = append(faces, compreface.FaceListItem{ImageID: imageID, Subject: name})
+			}
+		}
+		f.mu.Unlock()
+
+		writeJSON(w, http.StatusOK, compreface.FaceListResponse{Faces: faces})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleFace routes requests for a single stored face image
+func (f *ComprefaceServer) handleFace(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/verify") {
+		f.handleVerifyFace(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/img") {
+		f.handleFaceImage(w, r)
+		return
+	}
+	f.handleDeleteFace(w, r)
+}
+
+// handleVerifyFace handles POST /api/v1/recognition/faces/{image_id}/verify.
+// The probe matches only if it is byte-identical to the stored example.
+func (f *ComprefaceServer) handleVerifyFace(w http.ResponseWriter, r *http.Request) {
+	imageID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/recognition/faces/"), "/verify")
+
+	faceBytes, err := readUpload(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	f.mu.Lock()
+	stored, ok := f.images[imageID]
+	f.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("image %s not found", imageID))
+		return
+	}
+
+	similarity := 0.0
+	if hashBytes(stored) == hashBytes(faceBytes) {
+		similarity = FakeSimilarity
+	}
+
+	writeJSON(w, http.StatusOK, compreface.VerificationResponse{
+		Result: []compreface.VerificationResult{{Box: cannedComprefaceBox(), Similarity: similarity}},
+	})
+}
+
+// handleVerifyFaces handles POST /api/v1/verification/verify. The images
+// match only if they are byte-identical.
+func (f *ComprefaceServer) handleVerifyFaces(w http.ResponseWriter, r *http.Request) {
+	images := make([][]byte, 2)
+	for i, field := range []string{"source_image", "target_image"} {
+		file, _, err := r.FormFile(field)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("failed to read %s: %w", field, err))
+			return
+		}
+		images[i], err = io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	similarity := 0.0
+	if hashBytes(images[0]) == hashBytes(images[1]) {
+		similarity = FakeSimilarity
+	}
+
+	result := compreface.FaceVerification{
+		FaceMatches: []compreface.FaceMatch{{Box: cannedComprefaceBox(), Similarity: similarity}},
+	}
+	result.SourceImageFace.Box = cannedComprefaceBox()
+	writeJSON(w, http.StatusOK, compreface.FaceVerificationResponse{Result: []compreface.FaceVerification{result}})
+}
+
+// handleFaceImage handles GET /api/v1/recognition/faces/{image_id}/img
+func (f *ComprefaceServer) handleFaceImage(w http.ResponseWriter, r *http.Request) {
+	imageID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/recognition/faces/"), "/img")
+
+	f.mu.Lock()
+	faceBytes, ok := f.images[imageID]
+	f.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("image %s not found", imageID))
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Write(faceBytes)
+}
+
+// handleDeleteFace handles DELETE /api/v1/recognition/faces/{image_id}
+func (f *ComprefaceServer) handleDeleteFace(w http.ResponseWriter, r *http.Request) {
+	imageID := strings.TrimPrefix(r.URL.Path, "/api/v1/recognition/faces/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	faceBytes, ok := f.images[imageID]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("image %s not found", imageID))
+		return
+	}
+	delete(f.images, imageID)
+	delete(f.faces, hashBytes(faceBytes))
+	for subject, imageIDs := range f.subjects {
+		f.subjects[subject] = removeString(imageIDs, imageID)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"image_id": imageID})
+}
+
+// handleListSubjects handles GET /api/v1/recognition/subjects
+func (f *ComprefaceServer) handleListSubjects(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, compreface.SubjectListResponse{Subjects: f.Subjects()})
+}
+
+// handleSubject handles DELETE and PUT (rename) on
+// /api/v1/recognition/subjects/{subject}
+func (f *ComprefaceServer) handleSubject(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		f.handleRenameSubject(w, r)
+		return
+	}
+	f.handleDeleteSubject(w, r)
+}
+
+// handleRenameSubject renames a subject, merging into an existing one
+func (f *ComprefaceServer) handleRenameSubject(w http.ResponseWriter, r *http.Request) {
+	subject := strings.TrimPrefix(r.URL.Path, "/api/v1/recognition/subjects/")
+
+	var body struct {
+		Subject string `json:"subject"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Subject == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid rename request"))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	imageIDs, ok := f.subjects[subject]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("subject %s not found", subject))
+		return
+	}
+	for hash, name := range f.faces {
+		if name == subject {
+			f.faces[hash] = body.Subject
+		}
+	}
+	delete(f.subjects, subject)
+	f.subjects[body.Subject] = append(f.subjects[body.Subject], imageIDs...)
+
+	writeJSON(w, http.StatusOK, map[string]bool{"updated": true})
+}
+
+// handleDeleteSubject handles DELETE /api/v1/recognition/subjects/{subject}
+func (f *ComprefaceServer) handleDeleteSubject(w http.ResponseWriter, r *http.Request) {
+	subject := strings.TrimPrefix(r.URL.Path, "/api/v1/recognition/subjects/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	imageIDs, ok := f.subjects[subject]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("subject %s not found", subject))
+		return
+	}
+	for _, imageID := range imageIDs {
+		delete(f.faces, hashBytes(f.images[imageID]))
+		delete(f.images, imageID)
+	}
+	delete(f.subjects, subject)
+
+	writeJSON(w, http.StatusOK, map[string]string{"subject": subject})
+}
+
+// handleRecognizeEmbeddings handles POST /api/v1/recognition/embeddings/recognize.
+// Embeddings never match in test mode.
+func (f *ComprefaceServer) handleRecognizeEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req compreface.EmbeddingRecognitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	resp := compreface.EmbeddingRecognitionResponse{Result: []compreface.EmbeddingResult{}}
+	for _, embedding := range req.Embeddings {
+		resp.Result = append(resp.Result, compreface.EmbeddingResult{
+			Embedding:    embedding,
+			Similarities: []compreface.EmbeddingSimilarity{},
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleDetect handles POST /api/v1/detection/detect
+func (f *ComprefaceServer) handleDetect(w http.ResponseWriter, r *http.Request) {
+	if _, err := readUpload(r); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, compreface.DetectionResponse{
+		Result: []compreface.FaceDetection{{
+			Box:        cannedComprefaceBox(),
+			Confidence: 0.99,
+			Age:        compreface.AgeRange{Low: 25, High: 35, Probability: 0.9},
+			Gender:     compreface.Gender{Value: "female", Probability: 0.9},
+			Mask:       compreface.Mask{Value: "without_mask", Probability: 0.99},
+		}},
+	})
+}
+
+// handleStatic handles GET /api/v1/static/{key}/images/{image_id}
+func (f *ComprefaceServer) handleStatic(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	imageID := parts[len(parts)-1]
+
+	f.mu.Lock()
+	faceBytes, ok := f.images[imageID]
+	f.mu.Unlock()
+
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Write(faceBytes)
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+// cannedComprefaceBox returns the bounding box reported for every fake face
+func cannedComprefaceBox() compreface.BoundingBox {
+	return compreface.BoundingBox{XMin: 0, YMin: 0, XMax: 100, YMax: 100, Probability: 0.99}
+}
+
+// readUpload reads the "file" part of a multipart upload
+func readUpload(r *http.Request) ([]byte, error) {
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// hashBytes returns the hex SHA-256 of data
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// removeString returns values without target
+func removeString(values []string, target string) []string {
+	result := []string{}
+	for _, v := range values {
+		if v != target {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a Compreface-style error response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]interface{}{
+		"message": err.Error(),
+		"code":    status,
+	})
+}
@@ -0,0 +1,309 @@
+package fake
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stashapp/stash/pkg/plugin/common/log"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// ============================================================================
+// Fake Vision Service - Offline Test Mode
+// ============================================================================
+//
+// In-process HTTP servers implementing the Vision Service job API and the
+// frame server's /extract-frame and /extract-frames endpoints.
+//
+// Every job completes immediately and reports a single canned face centered
+// in the source. Extracted frames are generated JPEGs whose colour is derived
+// from the video path, so each scene yields a distinct (but stable) face crop.
+//
+// ============================================================================
+
+const (
+	fakeFrameWidth  = 640
+	fakeFrameHeight = 480
+	fakeEmbedding   = 512
+)
+
+// VisionServer is an in-memory fake of the Vision Service and frame server
+type VisionServer struct {
+	server      *httptest.Server
+	frameServer *httptest.Server
+	mu          sync.Mutex
+	jobs        map[string]vision.AnalyzeRequest
+	nextID      int
+}
+
+// NewVisionServer starts a fake Vision Service and frame server
+func NewVisionServer() *VisionServer {
+	f := &VisionServer{
+		jobs: make(map[string]vision.AnalyzeRequest),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/vision/health", f.handleHealth)
+	mux.HandleFunc("/vision/analyze", f.handleAnalyze)
+	mux.HandleFunc("/vision/jobs/", f.handleJob)
+	f.server = httptest.NewServer(mux)
+
+	frameMux := http.NewServeMux()
+	frameMux.HandleFunc("/health", f.handleFrameServerHealth)
+	frameMux.HandleFunc("/extract-frame", f.handleExtractFrame)
+	frameMux.HandleFunc("/extract-frames", f.handleExtractFrames)
+	f.frameServer = httptest.NewServer(frameMux)
+
+	log.Infof("Fake Vision Service listening at %s (frame server %s)", f.server.URL, f.frameServer.URL)
+	return f
+}
+
+// URL returns the base URL of the fake Vision Service
+func (f *VisionServer) URL() string {
+	return f.server.URL
+}
+
+// FrameServerURL returns the base URL of the fake frame server
+func (f *VisionServer) FrameServerURL() string {
+	return f.frameServer.URL
+}
+
+// Close shuts down both fake servers
+func (f *VisionServer) Close() {
+	f.server.Close()
+	f.frameServer.Close()
+}
+
+// handleHealth handles GET /vision/health
+func (f *VisionServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "healthy", "version": "1.0.0"})
+}
+
+// handleAnalyze handles POST /vision/analyze
+func (f *VisionServer) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	var req vision.AnalyzeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	f.mu.Lock()
+	f.nextID++
+	jobID := req.JobID
+	if jobID == "" {
+		jobID = fmt.Sprintf("fake-job-%d", f.nextID)
+	}
+	f.jobs[jobID] = req
+	f.mu.Unlock()
+
+	writeJSON(w, http.StatusAccepted, vision.JobResponse{
+		JobID:     jobID,
+		Status:    "queued",
+		CreatedAt: time.Now(),
+	})
+}
+
+// handleJob handles GET /vision/jobs/{job_id}/status and /vision/jobs/{job_id}/results
+func (f *VisionServer) handleJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/vision/jobs/"), "/")
+	if len(parts) != 2 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	jobID, action := parts[0], parts[1]
+
+	f.mu.Lock()
+	req, ok := f.jobs[jobID]
+	f.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("job %s not found", jobID))
+		return
+	}
+
+	switch action {
+	case "status":
+		now := time.Now()
+		writeJSON(w, http.StatusOK, vision.JobStatus{
+			JobID:       jobID,
+			Status:      "completed",
+			Progress:    1.0,
+			CreatedAt:   now,
+			StartedAt:   &now,
+			CompletedAt: &now,
+		})
+	case "results":
+		writeJSON(w, http.StatusOK, cannedResults(jobID, req))
+	case "cancel":
+		writeJSON(w, http.StatusOK, map[string]string{"job_id": jobID, "status": "cancelled"})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// handleFrameServerHealth handles GET /health on the frame server
+func (f *VisionServer) handleFrameServerHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, vision.FrameServerHealth{
+		Status:      "healthy",
+		Enhancement: &vision.EnhancementCapabilities{Available: true, Models: []string{"codeformer", "gfpgan"}},
+	})
+}
+
+// handleExtractFrame handles GET /extract-frame
+func (f *VisionServer) handleExtractFrame(w http.ResponseWriter, r *http.Request) {
+	frame, err := generateFrame(r.URL.Query().Get("video_path"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Write(frame)
+}
+
+// handleExtractFrames handles POST /extract-frames
+func (f *VisionServer) handleExtractFrames(w http.ResponseWriter, r *http.Request) {
+	var req vision.BatchExtractRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	frame, err := generateFrame(req.VideoPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := vision.BatchExtractResponse{Frames: []vision.ExtractedFrame{}}
+	for _, timestamp := range req.Timestamps {
+		resp.Frames = append(resp.Frames, vision.ExtractedFrame{Timestamp: timestamp, Data: frame})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// ============================================================================
+// Canned Data
+// ============================================================================
+
+// cannedResults builds a single-face result for the given job
+func cannedResults(jobID string, req vision.AnalyzeRequest) vision.AnalyzeResults {
+	width, height := sourceDimensions(req.Source)
+
+	// Centered square box covering half of the shorter side
+	size := height / 2
+	if width < height {
+		size = width / 2
+	}
+	bbox := vision.VisionBoundingBox{
+		XMin: (width - size) / 2,
+		YMin: (height - size) / 2,
+		XMax: (width + size) / 2,
+		YMax: (height + size) / 2,
+	}
+
+	detection := vision.VisionDetection{
+		FrameIndex: 0,
+		Timestamp:  0,
+		BBox:       bbox,
+		Confidence: 0.99,
+		Quality: &vision.QualityResult{
+			Composite: 0.9,
+			Components: vision.QualityComponents{
+				Size:      0.9,
+				Pose:      0.9,
+				Occlusion: 0.9,
+				Sharpness: 0.9,
+			},
+		},
+		Pose:      "front",
+		Occlusion: &vision.OcclusionResult{Occluded: false, Probability: 0.01},
+	}
+
+	face := vision.VisionFace{
+		FaceID:                  fmt.Sprintf("%s-face-0", jobID),
+		Embedding:               cannedEmbedding(req.Source),
+		Demographics:            &vision.Demographics{Age: 30, Gender: "F", Emotion: "neutral"},
+		Detections:              []vision.VisionDetection{detection},
+		RepresentativeDetection: detection,
+	}
+
+	return vision.AnalyzeResults{
+		JobID:    jobID,
+		SourceID: req.SourceID,
+		Status:   "completed",
+		Faces: &vision.FacesResults{
+			JobID:    jobID,
+			SourceID: req.SourceID,
+			Status:   "completed",
+			Faces:    []vision.VisionFace{face},
+			Metadata: vision.ResultMetadata{
+				Source:          req.Source,
+				TotalFrames:     1,
+				FramesProcessed: 1,
+				UniqueFaces:     1,
+				TotalDetections: 1,
+				Method:          "fake",
+				Model:           "fake",
+			},
+		},
+	}
+}
+
+// sourceDimensions returns the dimensions of a local image source, falling
+// back to the generated frame size for videos and unreadable sources
+func sourceDimensions(source string) (int, int) {
+	file, err := os.Open(source)
+	if err != nil {
+		return fakeFrameWidth, fakeFrameHeight
+	}
+	defer file.Close()
+
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil || cfg.Width == 0 || cfg.Height == 0 {
+		return fakeFrameWidth, fakeFrameHeight
+	}
+	return cfg.Width, cfg.Height
+}
+
+// cannedEmbedding returns a stable unit-scale embedding derived from source
+func cannedEmbedding(source string) []float64 {
+	seed := sha256.Sum256([]byte(source))
+	embedding := make([]float64, fakeEmbedding)
+	for i := range embedding {
+		embedding[i] = float64(seed[i%len(seed)]) / 255.0
+	}
+	return embedding
+}
+
+// generateFrame renders a solid JPEG frame whose colour is derived from videoPath
+func generateFrame(videoPath string) ([]byte, error) {
+	seed := sha256.Sum256([]byte(videoPath))
+	fill := color.RGBA{R: seed[0], G: seed[1], B: seed[2], A: 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, fakeFrameWidth, fakeFrameHeight))
+	for y := 0; y < fakeFrameHeight; y++ {
+		for x := 0; x < fakeFrameWidth; x++ {
+			img.Set(x, y, fill)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		return nil, fmt.Errorf("failed to encode frame: %w", err)
+	}
+	return buf.Bytes(), nil
+}
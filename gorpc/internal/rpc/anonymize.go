@@ -0,0 +1,61 @@
+package rpc
+
+import (
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Anonymization Mode
+// ============================================================================
+//
+// When enabled, recognition tasks only detect faces and tag media with the
+// result. Nothing biometric is kept: no crops are sent to Compreface, no
+// subjects or performers are created, embeddings returned by the Vision
+// Service are discarded, and Vision Service results are cached for the
+// shortest possible time. Modes that inherently store face data are refused.
+//
+// ============================================================================
+
+// anonymizedCacheDuration is the Vision Service result cache (seconds) requested
+// in anonymization mode, so detections and embeddings are not retained
+const anonymizedCacheDuration = 1
+
+// storesBiometricData reports whether a task mode uploads faces to Compreface
+// or creates subjects, and is therefore unavailable in anonymization mode
+func storesBiometricData(mode string) bool {
+	switch mode {
+	case "synchronizePerformers",
+		"identifyImage",
+		"identifyScene",
+		"identifyImagesAll",
+		"identifyImagesNew",
+		"createPerformerFromImage",
+		"createPerformerFromScene",
+		"identifyGallery",
+		"verifyPerformerImage",
+		"trainPerformerFaces",
+		"approvePendingFace",
+		"assignCluster",
+		"importSubjectMappings":
+		return true
+	}
+	return false
+}
+
+// facesDetectedTags returns the tag changes recording whether an anonymized
+// scan found any processable faces
+func (s *Service) facesDetectedTags(facesDetected int) (add, remove []graphql.ID) {
+	tagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.FacesDetectedTagName, "Compreface Faces Detected")
+	if err != nil {
+		log.Warnf("Failed to get faces detected tag: %v", err)
+		return nil, nil
+	}
+
+	if facesDetected > 0 {
+		return []graphql.ID{tagID}, nil
+	}
+	return nil, []graphql.ID{tagID}
+}
@@ -0,0 +1,451 @@
+package rpc
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Task Argument Schema
+// ============================================================================
+//
+// Stash passes task arguments as loosely typed JSON: integers arrive as
+// float64, booleans may arrive as strings, and UI plugins send IDs as either.
+// Each mode declares the arguments it accepts; they are parsed and validated
+// once, with defaults applied, before the mode runs. Arguments a mode does
+// not declare are rejected, so a misspelt argument fails the task instead of
+// silently falling back to its default.
+//
+// ============================================================================
+
+// argKind is the type of a task argument
+type argKind int
+
+const (
+	argID     argKind = iota // Stash object ID (positive integer, kept as string)
+	argBool                  // Boolean, also accepting "true"/"false" and 0/1
+	argInt                   // Integer, also accepting integral floats and numeric strings
+	argEnum                  // String restricted to a set of values
+	argString                // Free-form string, trimmed
+	argFloat                 // Number, also accepting numeric strings
+	argDate                  // Date (2006-01-02) or RFC 3339 timestamp, kept as given
+)
+
+// argSpec declares a single task argument
+type argSpec struct {
+	name     string
+	kind     argKind
+	required bool
+	def      interface{} // Default when absent (string, bool, int or float64 by kind)
+	min      int         // Lower bound for argInt and argFloat
+	max      int         // Upper bound for argInt and argFloat; 0 for none
+	values   []string    // Allowed values for argEnum
+}
+
+// Arguments shared by several modes
+var (
+	limitArg             = argSpec{name: "limit", kind: argInt, def: 0, min: 0}
+	createNewSubjectsArg = argSpec{name: "createNewSubjects", kind: argBool, def: true}
+	createPerformerArg   = argSpec{name: "createPerformer", kind: argBool, def: false}
+	resumeArg            = argSpec{name: "resume", kind: argBool, def: false}
+	minSimilarityArg     = argSpec{name: "minSimilarity", kind: argFloat, min: 0, max: 1}
+	minQualityScoreArg   = argSpec{name: "minQualityScore", kind: argFloat, min: 0, max: 1}
+
+	// scopeArgs limit the media of a batch task (see contentScope)
+	scopeArgs = []argSpec{
+		{name: "studioId", kind: argID},
+		{name: "tagId", kind: argID},
+		{name: "createdAfter", kind: argDate},
+		{name: "createdBefore", kind: argDate},
+		{name: "pathPrefix", kind: argString},
+	}
+)
+
+// withScopeArgs returns a batch task's arguments followed by scopeArgs
+func withScopeArgs(args ...argSpec) []argSpec {
+	return append(args, scopeArgs...)
+}
+
+// taskArgSchemas lists the arguments accepted by each task mode
+var taskArgSchemas = map[string][]argSpec{
+	"synchronizePerformers":        {limitArg},
+	"recognizeImages":              withScopeArgs(limitArg, createNewSubjectsArg, resumeArg, minSimilarityArg, minQualityScoreArg),
+	"identifyImagesAll":            withScopeArgs(limitArg, resumeArg, minSimilarityArg, minQualityScoreArg),
+	"identifyImagesNew":            withScopeArgs(limitArg, resumeArg, minSimilarityArg, minQualityScoreArg),
+	"resetUnmatchedImages":         {limitArg},
+	"recognizeNewScenes":           withScopeArgs(limitArg, createNewSubjectsArg, resumeArg, minSimilarityArg, minQualityScoreArg),
+	"recognizePartialScenes":       withScopeArgs(limitArg, createNewSubjectsArg, resumeArg, minSimilarityArg, minQualityScoreArg),
+	"recognizeAllScenes":           withScopeArgs(limitArg, createNewSubjectsArg, resumeArg, minSimilarityArg, minQualityScoreArg),
+	"recognizeNewSceneSprites":     withScopeArgs(limitArg, createNewSubjectsArg, resumeArg, minSimilarityArg, minQualityScoreArg),
+	"recognizePartialSceneSprites": withScopeArgs(limitArg, createNewSubjectsArg, resumeArg, minSimilarityArg, minQualityScoreArg),
+	"recognizeAllSceneSprites":     withScopeArgs(limitArg, createNewSubjectsArg, resumeArg, minSimilarityArg, minQualityScoreArg),
+	"resetUnmatchedScenes":         {limitArg},
+	"dedupeAliases":                {limitArg},
+	"reportTagDrift":               {limitArg},
+	"repairSubjectLinks":           {limitArg},
+	"mergeDuplicatePerformers":     {limitArg},
+	"importDoubleTake":             {limitArg, createPerformerArg},
+	"fullPipeline":                 {limitArg, createNewSubjectsArg, minSimilarityArg, minQualityScoreArg},
+	"status":                       {},
+	"capabilities":                 {},
+	"benchmark": {
+		{name: "path", kind: argString, required: true},
+		{name: "iterations", kind: argInt, def: 3, min: 1},
+		limitArg,
+	},
+	"identifyImage": {
+		{name: "imageId", kind: argID, required: true},
+		createPerformerArg,
+		{name: "associateExisting", kind: argBool, def: false},
+		minSimilarityArg,
+		minQualityScoreArg,
+	},
+	"createPerformerFromImage": {
+		{name: "imageId", kind: argID, required: true},
+		{name: "faceIndex", kind: argInt, def: 0, min: 0},
+	},
+	"identifyScene": {
+		{name: "sceneId", kind: argID, required: true},
+		createPerformerArg,
+		{name: "associateExisting", kind: argBool, def: false},
+		{name: "useSprites", kind: argBool, def: false},
+		minSimilarityArg,
+		minQualityScoreArg,
+	},
+	"identifyGallery": {
+		{name: "galleryId", kind: argID, required: true},
+		createPerformerArg,
+		limitArg,
+		{name: "full", kind: argBool, def: false},
+		minSimilarityArg,
+		minQualityScoreArg,
+	},
+	"verifyPerformerImage": {
+		{name: "imageId", kind: argID, required: true},
+		{name: "performerId", kind: argID, required: true},
+		{name: "associate", kind: argBool, def: false},
+	},
+	"deleteSubjectForPerformer": {
+		{name: "performerId", kind: argID, required: true},
+		{name: "deletePerformer", kind: argBool, def: false},
+	},
+	"cleanupOrphanSubjects": {
+		{name: "dryRun", kind: argBool, def: false},
+		limitArg,
+	},
+	"pruneAutoPerformers": {
+		{name: "days", kind: argInt, def: 30, min: 0},
+		{name: "dryRun", kind: argBool, def: false},
+		limitArg,
+	},
+	"trainPerformerFaces": {
+		{name: "performerId", kind: argID},
+		{name: "examples", kind: argInt, def: 10, min: 2},
+		limitArg,
+	},
+	"listPendingFaces": {limitArg},
+	"approvePendingFace": {
+		{name: "faceId", kind: argString, required: true},
+		{name: "performerId", kind: argID},
+		{name: "reject", kind: argBool, def: false},
+	},
+	"listUnmatchedClusters": {
+		{name: "sceneId", kind: argID},
+		limitArg,
+	},
+	"assignCluster": {
+		{name: "clusterId", kind: argString, required: true},
+		{name: "performerId", kind: argID, required: true},
+	},
+	"restoreBackup": {
+		{name: "backup", kind: argString},
+	},
+	"exportSubjectMappings": {
+		{name: "path", kind: argString},
+		{name: "includeExamples", kind: argBool, def: true},
+	},
+	"importSubjectMappings": {
+		{name: "path", kind: argString, required: true},
+	},
+	"createPerformerFromScene": {
+		{name: "sceneId", kind: argID, required: true},
+		{name: "timestamp", kind: argFloat, required: true, min: 0},
+		{name: "faceIndex", kind: argInt, def: 0, min: 0},
+	},
+}
+
+// taskArgs holds parsed argument values keyed by name
+type taskArgs map[string]interface{}
+
+// taskModes returns the known task modes, sorted
+func taskModes() []string {
+	modes := make([]string, 0, len(taskArgSchemas))
+	for mode := range taskArgSchemas {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+	return modes
+}
+
+// ValidateTaskArgs checks the raw arguments of a task against its mode's
+// schema, returning the mode
+func ValidateTaskArgs(raw map[string]interface{}) (string, error) {
+	mode, _, err := parseTaskArgs(raw)
+	return mode, err
+}
+
+// parseTaskArgs validates the raw arguments of a task against its mode's
+// schema. Returns the mode and parsed arguments with defaults applied.
+func parseTaskArgs(raw map[string]interface{}) (string, taskArgs, error) {
+	modeSpec := argSpec{name: "mode", kind: argEnum, required: true, values: taskModes()}
+	mode, err := parseArg(modeSpec, raw)
+	if err != nil {
+		return "", nil, err
+	}
+
+	schema := taskArgSchemas[mode.(string)]
+	if err := checkUnknownArgs(mode.(string), schema, raw); err != nil {
+		return "", nil, err
+	}
+
+	args := taskArgs{}
+	for _, spec := range schema {
+		value, err := parseArg(spec, raw)
+		if err != nil {
+			return "", nil, err
+		}
+		args[spec.name] = value
+	}
+	return mode.(string), args, nil
+}
+
+// checkUnknownArgs rejects raw arguments the mode's schema does not declare
+func checkUnknownArgs(mode string, schema []argSpec, raw map[string]interface{}) error {
+	declared := map[string]bool{"mode": true}
+	for _, spec := range schema {
+		declared[spec.name] = true
+	}
+
+	var unknown []string
+	for name := range raw {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("unknown argument(s) %s for mode %s", strings.Join(unknown, ", "), mode)
+}
+
+// parseArg parses a single argument, applying its default when absent
+func parseArg(spec argSpec, raw map[string]interface{}) (interface{}, error) {
+	value, ok := raw[spec.name]
+	if !ok || value == nil || value == "" {
+		if spec.required {
+			return nil, fmt.Errorf("missing required argument %s", spec.name)
+		}
+		return spec.def, nil
+	}
+
+	switch spec.kind {
+	case argID:
+		return parseIDArg(spec.name, value)
+	case argBool:
+		return parseBoolArg(spec.name, value)
+	case argInt:
+		return parseIntArg(spec, value)
+	case argEnum:
+		return parseEnumArg(spec, value)
+	case argString:
+		return parseStringArg(spec.name, value)
+	case argFloat:
+		return parseFloatArg(spec, value)
+	case argDate:
+		return parseDateArg(spec.name, value)
+	}
+	return nil, fmt.Errorf("argument %s has unknown kind %d", spec.name, spec.kind)
+}
+
+// parseIDArg parses a Stash object ID
+func parseIDArg(name string, value interface{}) (string, error) {
+	var id int64
+	switch v := value.(type) {
+	case float64:
+		if v != math.Trunc(v) {
+			return "", fmt.Errorf("invalid %s %v: expected a whole number", name, v)
+		}
+		id = int64(v)
+	case int:
+		id = int64(v)
+	case string:
+		parsed, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s %q: expected a numeric ID", name, v)
+		}
+		id = parsed
+	default:
+		return "", fmt.Errorf("invalid %s: expected an ID, got %T", name, value)
+	}
+
+	if id <= 0 {
+		return "", fmt.Errorf("invalid %s %d: must be positive", name, id)
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+// parseBoolArg parses a boolean
+func parseBoolArg(name string, value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case float64:
+		if v == 0 || v == 1 {
+			return v == 1, nil
+		}
+	case int:
+		if v == 0 || v == 1 {
+			return v == 1, nil
+		}
+	case string:
+		if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+			return b, nil
+		}
+	}
+	return false, fmt.Errorf("invalid %s %v: expected true or false", name, value)
+}
+
+// parseIntArg parses an integer, enforcing the spec's lower bound
+func parseIntArg(spec argSpec, value interface{}) (int, error) {
+	var n int
+	switch v := value.(type) {
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("invalid %s %v: expected a whole number", spec.name, v)
+		}
+		n = int(v)
+	case int:
+		n = v
+	case string:
+		parsed, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q: expected a whole number", spec.name, v)
+		}
+		n = parsed
+	default:
+		return 0, fmt.Errorf("invalid %s: expected a number, got %T", spec.name, value)
+	}
+
+	if n < spec.min {
+		return 0, fmt.Errorf("invalid %s %d: must be at least %d", spec.name, n, spec.min)
+	}
+	if spec.max > 0 && n > spec.max {
+		return 0, fmt.Errorf("invalid %s %d: must be at most %d", spec.name, n, spec.max)
+	}
+	return n, nil
+}
+
+// parseFloatArg parses a number, enforcing the spec's lower bound
+func parseFloatArg(spec argSpec, value interface{}) (float64, error) {
+	var f float64
+	switch v := value.(type) {
+	case float64:
+		f = v
+	case int:
+		f = float64(v)
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q: expected a number", spec.name, v)
+		}
+		f = parsed
+	default:
+		return 0, fmt.Errorf("invalid %s: expected a number, got %T", spec.name, value)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid %s %v: expected a finite number", spec.name, f)
+	}
+	if f < float64(spec.min) {
+		return 0, fmt.Errorf("invalid %s %v: must be at least %d", spec.name, f, spec.min)
+	}
+	if spec.max > 0 && f > float64(spec.max) {
+		return 0, fmt.Errorf("invalid %s %v: must be at most %d", spec.name, f, spec.max)
+	}
+	return f, nil
+}
+
+// parseEnumArg parses a string restricted to the spec's values
+func parseEnumArg(spec argSpec, value interface{}) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid %s: expected a string, got %T", spec.name, value)
+	}
+	for _, allowed := range spec.values {
+		if s == allowed {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("invalid %s %q: expected one of %s", spec.name, s, strings.Join(spec.values, ", "))
+}
+
+// parseStringArg parses a free-form string
+func parseStringArg(name string, value interface{}) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid %s: expected a string, got %T", name, value)
+	}
+	if s = strings.TrimSpace(s); s == "" {
+		return "", fmt.Errorf("invalid %s: must not be blank", name)
+	}
+	return s, nil
+}
+
+// parseDateArg parses a date or RFC 3339 timestamp
+func parseDateArg(name string, value interface{}) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid %s: expected a date, got %T", name, value)
+	}
+	s = strings.TrimSpace(s)
+	if _, err := time.Parse("2006-01-02", s); err == nil {
+		return s, nil
+	}
+	if _, err := time.Parse(time.RFC3339, s); err == nil {
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid %s %q: expected a date (2006-01-02) or RFC 3339 timestamp", name, s)
+}
+
+// Has reports whether an argument without a default was given
+func (a taskArgs) Has(name string) bool {
+	return a[name] != nil
+}
+
+// String returns a parsed ID, enum, string or date argument
+func (a taskArgs) String(name string) string {
+	s, _ := a[name].(string)
+	return s
+}
+
+// Bool returns a parsed boolean argument
+func (a taskArgs) Bool(name string) bool {
+	b, _ := a[name].(bool)
+	return b
+}
+
+// Int returns a parsed integer argument
+func (a taskArgs) Int(name string) int {
+	n, _ := a[name].(int)
+	return n
+}
+
+// Float returns a parsed number argument
+func (a taskArgs) Float(name string) float64 {
+	f, _ := a[name].(float64)
+	return f
+}
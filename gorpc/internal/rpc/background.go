@@ -0,0 +1,55 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/config"
+	"github.com/smegmarip/stash-compreface-plugin/internal/throttle"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Background-Friendly Mode
+// ============================================================================
+//
+// Long batch tasks compete with Stash for CPU and disk on the same host and
+// can stutter playback. In background-friendly mode the process runs at low
+// priority, images and Vision jobs are processed one at a time (see
+// config.ApplyBackgroundFriendly), each face is followed by a short pause,
+// and JPEGs are encoded smaller.
+//
+// ============================================================================
+
+// backgroundFacePause is the pause before each face in background-friendly mode
+const backgroundFacePause = 250 * time.Millisecond
+
+// lowerPriority renices the plugin process in background-friendly mode
+func (s *Service) lowerPriority() {
+	if !s.config.BackgroundFriendly {
+		return
+	}
+	if err := throttle.LowerProcessPriority(); err != nil {
+		log.Warnf("Failed to lower process priority: %v", err)
+	}
+}
+
+// pauseBeforeFace yields to other work on the host in background-friendly
+// mode, returning early once the task is stopped
+func (s *Service) pauseBeforeFace() {
+	if !s.config.BackgroundFriendly {
+		return
+	}
+	select {
+	case <-time.After(backgroundFacePause):
+	case <-s.ctx.Done():
+	}
+}
+
+// jpegQuality returns the quality to encode JPEGs at: quality, lowered in
+// background-friendly mode
+func (s *Service) jpegQuality(quality int) int {
+	if s.config.BackgroundFriendly && quality > config.BackgroundJPEGQuality {
+		return config.BackgroundJPEGQuality
+	}
+	return quality
+}
@@ -0,0 +1,458 @@
+package rpc
+
+import (
+	"fmt"
+	"path/filepath"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/pkg/utils"
+)
+
+// ============================================================================
+// State Backups
+// ============================================================================
+//
+// resetUnmatchedImages, resetUnmatchedScenes, mergeDuplicatePerformers,
+// deleteSubjectForPerformer, cleanupOrphanSubjects and pruneAutoPerformers
+// back up what they change before changing it: the tags and performers of
+// the affected images, scenes and galleries, the affected performers, and
+// their subjects with the examples' image IDs (and, for a deleted subject,
+// the examples themselves). A task whose backup fails does not run.
+//
+// restoreBackup replays a backup. Performers are restored first (recreated
+// when deleted, with their recorded names, aliases and birthdate), subjects
+// next (examples moved back out of the subject they were merged into, or
+// re-added from the backup), then each item gets its recorded tags back and
+// its recorded performers in place of the backed-up performers it has now.
+// Tags and performers not in the backup are left alone. A backup is restored
+// only once.
+//
+// ============================================================================
+
+// backupsDir is the backup directory under the plugin directory
+const backupsDir = "data/backups"
+
+// restoreReport counts the work done restoring a backup
+type restoreReport struct {
+	performers int
+	recreated  int
+	subjects   int
+	faces      int
+	items      int
+	failed     int
+}
+
+// String summarizes the report
+func (r *restoreReport) String() string {
+	return fmt.Sprintf("%s performers restored (%s recreated), %s subjects restored with %s examples, %s items restored, %s failed",
+		formatCount(r.performers), formatCount(r.recreated), formatCount(r.subjects), formatCount(r.faces), formatCount(r.items), formatCount(r.failed))
+}
+
+// backupRoot returns the directory backups are kept in
+func (s *Service) backupRoot() string {
+	return filepath.Join(s.serverConnection.PluginDir, filepath.FromSlash(backupsDir))
+}
+
+// backupState exports the state a task is about to change, as written by fn,
+// to a new backup. An unfinished backup is deleted and fails the task.
+func (s *Service) backupState(task string, fn func(backup *store.BackupWriter) error) error {
+	backup, err := store.CreateBackup(s.backupRoot(), task)
+	if err != nil {
+		return fmt.Errorf("failed to back up state: %w", err)
+	}
+	if err := fn(backup); err != nil {
+		if discardErr := backup.Discard(); discardErr != nil {
+			log.Warnf("Failed to delete unfinished backup %s: %v", backup.Name(), discardErr)
+		}
+		return fmt.Errorf("failed to back up state: %w", err)
+	}
+	if err := backup.Close(); err != nil {
+		return fmt.Errorf("failed to back up state: %w", err)
+	}
+	log.Infof("Backed up state to %s; undo this task with restoreBackup", backup.Name())
+	return nil
+}
+
+// backupPerformer records a performer's names, tags and birthdate
+func backupPerformer(backup *store.BackupWriter, performer *stash.Performer) error {
+	return backup.Add(store.BackupRecord{
+		Kind:      store.BackupPerformer,
+		ID:        string(performer.ID),
+		Name:      performer.Name,
+		Aliases:   performer.AliasList,
+		Gender:    performer.Gender,
+		Birthdate: performer.Birthdate,
+		Tags:      backupTagIDs(performer.Tags),
+	})
+}
+
+// backupSubject records a subject, its performer and its examples' image IDs.
+// mergedInto is the subject the examples are about to be merged into; with
+// saveFaces set, the examples themselves are saved (outside anonymization
+// mode), so a deleted subject can be rebuilt.
+func (s *Service) backupSubject(backup *store.BackupWriter, subject string, performerID graphql.ID, mergedInto string, saveFaces bool) error {
+	faces, err := s.comprefaceClient.ListFaces(subject)
+	if err != nil {
+		return fmt.Errorf("failed to list faces of %s: %w", subject, err)
+	}
+
+	record := store.BackupRecord{Kind: store.BackupSubject, Subject: subject, PerformerID: string(performerID), MergedInto: mergedInto}
+	for _, face := range faces {
+		record.Faces = append(record.Faces, face.ImageID)
+		if !saveFaces || s.config.AnonymizationMode {
+			continue
+		}
+		data, err := s.comprefaceClient.DownloadFaceImage(face.ImageID)
+		if err != nil {
+			return fmt.Errorf("failed to download example %s of %s: %w", face.ImageID, subject, err)
+		}
+		if err := backup.AddFace(face.ImageID, data); err != nil {
+			return err
+		}
+	}
+	return backup.Add(record)
+}
+
+// backupPerformerItems records the tags and performers of every image, scene
+// and gallery of a performer. Items in seen are skipped, and added to it.
+func (s *Service) backupPerformerItems(backup *store.BackupWriter, performerID graphql.ID, seen map[string]bool) error {
+	performed := &stash.MultiCriterionInput{Value: []string{string(performerID)}, Modifier: stash.CriterionModifierIncludes}
+
+	err := stash.FindAllImages(s.graphqlClient, &stash.ImageFilterType{Performers: performed}, stash.DefaultPageSize, func(images []stash.Image, count int) error {
+		return backupImages(backup, images, seen)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query images: %w", err)
+	}
+	err = stash.FindAllScenes(s.graphqlClient, &stash.SceneFilterType{Performers: performed}, stash.DefaultPageSize, func(scenes []stash.Scene, count int) error {
+		return backupScenes(backup, scenes, seen)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query scenes: %w", err)
+	}
+	err = stash.FindAllGalleries(s.graphqlClient, &stash.GalleryFilterType{Performers: performed}, stash.DefaultPageSize, func(galleries []stash.Gallery, count int) error {
+		for _, gallery := range galleries {
+			if err := backupItem(backup, store.BackupGallery, gallery.ID, gallery.Tags, gallery.Performers, seen); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query galleries: %w", err)
+	}
+	return nil
+}
+
+// backupImages records the tags and performers of images
+func backupImages(backup *store.BackupWriter, images []stash.Image, seen map[string]bool) error {
+	for _, image := range images {
+		if err := backupItem(backup, store.BackupImage, image.ID, image.Tags, image.Performers, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupScenes records the tags and performers of scenes
+func backupScenes(backup *store.BackupWriter, scenes []stash.Scene, seen map[string]bool) error {
+	for _, scene := range scenes {
+		if err := backupItem(backup, store.BackupScene, scene.ID, scene.Tags, scene.Performers, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupItem records the tags and performers of an image, scene or gallery,
+// unless seen (nil records every item)
+func backupItem(backup *store.BackupWriter, kind string, id graphql.ID, tags []stash.Tag, performers []stash.Performer, seen map[string]bool) error {
+	key := kind + ":" + string(id)
+	if seen != nil {
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+	}
+
+	performerIDs := make([]string, len(performers))
+	for i, performer := range performers {
+		performerIDs[i] = string(performer.ID)
+	}
+	return backup.Add(store.BackupRecord{Kind: kind, ID: string(id), Tags: backupTagIDs(tags), Performers: performerIDs})
+}
+
+// backupTagIDs returns the IDs of tags
+func backupTagIDs(tags []stash.Tag) []string {
+	ids := make([]string, len(tags))
+	for i, tag := range tags {
+		ids[i] = string(tag.ID)
+	}
+	return ids
+}
+
+// restoreBackup restores the backup with the given name, or the newest one
+// when name is empty
+func (s *Service) restoreBackup(name string) (string, error) {
+	if s.stopped() {
+		return "", fmt.Errorf("operation cancelled")
+	}
+
+	backup, err := store.OpenBackup(s.backupRoot(), name)
+	if err != nil {
+		return "", err
+	}
+	manifest := backup.Manifest
+	if manifest.Restored != nil {
+		return "", fmt.Errorf("backup %s was already restored on %s", manifest.Name, manifest.Restored.Format("2006-01-02 15:04:05"))
+	}
+	log.Infof("Restoring backup %s (%s, %s records)", manifest.Name, manifest.Task, formatCount(manifest.Records))
+
+	report := &restoreReport{}
+	performerIDs := map[string]graphql.ID{} // Backed-up performer IDs to their restored IDs
+	done := 0
+	err = backup.Each(func(records []store.BackupRecord) error {
+		for _, record := range records {
+			if s.stopped() {
+				return fmt.Errorf("operation cancelled")
+			}
+			s.reportProgress(float64(done) / float64(manifest.Records))
+			done++
+
+			var err error
+			switch record.Kind {
+			case store.BackupPerformer:
+				err = s.restorePerformer(record, performerIDs, report)
+			case store.BackupSubject:
+				err = s.restoreSubject(backup, record, report)
+			case store.BackupImage, store.BackupScene, store.BackupGallery:
+				err = s.restoreItem(record, performerIDs, report)
+			default:
+				err = fmt.Errorf("unknown record kind %q", record.Kind)
+			}
+			if err != nil {
+				id := record.ID
+				if record.Kind == store.BackupSubject {
+					id = record.Subject
+				}
+				log.Warnf("Failed to restore %s %s: %v", record.Kind, id, err)
+				s.itemFailed(record.Kind, graphql.ID(id), err)
+				report.failed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to restore backup %s: %w", manifest.Name, err)
+	}
+
+	if err := backup.MarkRestored(); err != nil {
+		log.Warnf("Failed to mark backup %s restored: %v", manifest.Name, err)
+	}
+
+	s.reportProgress(1.0)
+	summary := fmt.Sprintf("Restored backup %s: %s", manifest.Name, report)
+	log.Info(summary)
+	return summary, nil
+}
+
+// restorePerformer sets a performer's names and birthdate back and re-adds
+// its tags, recreating it when it was deleted. The performer's restored ID is
+// recorded in performerIDs.
+func (s *Service) restorePerformer(record store.BackupRecord, performerIDs map[string]graphql.ID, report *restoreReport) error {
+	performer, err := stash.GetPerformerByID(s.graphqlClient, graphql.ID(record.ID))
+	if err != nil {
+		return fmt.Errorf("failed to get performer: %w", err)
+	}
+
+	id := graphql.ID(record.ID)
+	tagIDs := record.Tags
+	if performer.ID == "" {
+		id, err = stash.CreatePerformer(s.graphqlClient, stash.PerformerSubject{Name: record.Name, Aliases: record.Aliases, Gender: record.Gender})
+		if err != nil {
+			return fmt.Errorf("failed to recreate performer: %w", err)
+		}
+		s.performerCreated(id)
+		log.Infof("Recreated performer %s as %s", record.Name, id)
+		report.recreated++
+	} else {
+		tagIDs = append(backupTagIDs(performer.Tags), tagIDs...)
+	}
+	performerIDs[record.ID] = id
+
+	input := stash.PerformerUpdateInput{
+		ID:        string(id),
+		Name:      &record.Name,
+		AliasList: stash.DedupeAliases(record.Aliases),
+		TagIds:    utils.DeduplicateStrings(tagIDs),
+	}
+	if record.Birthdate != "" {
+		input.Birthdate = &record.Birthdate
+	}
+	if err := stash.UpdatePerformer(s.graphqlClient, id, input); err != nil {
+		return fmt.Errorf("failed to update performer: %w", err)
+	}
+	report.performers++
+	return nil
+}
+
+// restoreSubject moves a subject's examples back out of the subject they
+// were merged into, or re-adds the examples saved in the backup when the
+// subject was deleted
+func (s *Service) restoreSubject(backup *store.Backup, record store.BackupRecord, report *restoreReport) error {
+	if s.config.AnonymizationMode {
+		return fmt.Errorf("subjects are not restored in anonymization mode")
+	}
+
+	if record.MergedInto != "" {
+		moved := 0
+		for _, imageID := range record.Faces {
+			data, err := s.comprefaceClient.DownloadFaceImage(imageID)
+			if err != nil {
+				log.Warnf("Example %s of %s is no longer in %s: %v", imageID, record.Subject, record.MergedInto, err)
+				continue
+			}
+			if _, err := s.comprefaceClient.AddSubjectFromBytes(record.Subject, data, "face.jpg"); err != nil {
+				return fmt.Errorf("failed to add example to subject %s: %w", record.Subject, err)
+			}
+			if err := s.comprefaceClient.DeleteFace(imageID); err != nil {
+				return fmt.Errorf("failed to remove example %s from %s: %w", imageID, record.MergedInto, err)
+			}
+			moved++
+		}
+		log.Infof("Moved %d examples from %s back to subject %s (performer %s)", moved, record.MergedInto, record.Subject, record.PerformerID)
+		report.subjects++
+		report.faces += moved
+		return nil
+	}
+
+	existing, err := s.comprefaceClient.ListFaces(record.Subject)
+	if err != nil {
+		return fmt.Errorf("failed to list faces of %s: %w", record.Subject, err)
+	}
+	if len(existing) > 0 {
+		log.Debugf("Subject %s still exists; nothing to restore", record.Subject)
+		return nil
+	}
+
+	added := 0
+	for _, imageID := range record.Faces {
+		data, err := backup.Face(imageID)
+		if err != nil {
+			log.Debugf("Example %s of %s was not saved: %v", imageID, record.Subject, err)
+			continue
+		}
+		if _, err := s.comprefaceClient.AddSubjectFromBytes(record.Subject, data, "face.jpg"); err != nil {
+			return fmt.Errorf("failed to add example to subject %s: %w", record.Subject, err)
+		}
+		added++
+	}
+	if added == 0 {
+		return fmt.Errorf("no examples of subject %s were saved", record.Subject)
+	}
+	log.Infof("Recreated subject %s (performer %s) with %d examples", record.Subject, record.PerformerID, added)
+	report.subjects++
+	report.faces += added
+	return nil
+}
+
+// restoreItem re-adds an image's, scene's or gallery's recorded tags and
+// replaces the backed-up performers it has now with its recorded performers
+func (s *Service) restoreItem(record store.BackupRecord, performerIDs map[string]graphql.ID, report *restoreReport) error {
+	id := graphql.ID(record.ID)
+	tagIDs := make([]graphql.ID, len(record.Tags))
+	for i, tagID := range record.Tags {
+		tagIDs[i] = graphql.ID(tagID)
+	}
+
+	switch record.Kind {
+	case store.BackupImage:
+		image, err := stash.GetImage(s.graphqlClient, id)
+		if err != nil {
+			return fmt.Errorf("failed to get image: %w", err)
+		}
+		if err := stash.UpdateImageTagsIfChanged(s.graphqlClient, image, tagIDs, nil); err != nil {
+			return err
+		}
+		if performers, changed := restoredPerformers(image.Performers, record.Performers, performerIDs); changed {
+			ids := make([]string, len(performers))
+			for i, performerID := range performers {
+				ids[i] = string(performerID)
+			}
+			if err := stash.UpdateImage(s.graphqlClient, id, stash.ImageUpdateInput{ID: record.ID, PerformerIds: ids}); err != nil {
+				return err
+			}
+		}
+	case store.BackupScene:
+		scene, err := stash.GetScene(s.graphqlClient, id)
+		if err != nil {
+			return fmt.Errorf("failed to get scene: %w", err)
+		}
+		if err := stash.UpdateSceneTagsIfChanged(s.graphqlClient, scene, tagIDs, nil); err != nil {
+			return err
+		}
+		if performers, changed := restoredPerformers(scene.Performers, record.Performers, performerIDs); changed {
+			if err := stash.UpdateScenePerformers(s.graphqlClient, id, performers); err != nil {
+				return err
+			}
+		}
+	case store.BackupGallery:
+		gallery, err := stash.GetGallery(s.graphqlClient, id)
+		if err != nil {
+			return fmt.Errorf("failed to get gallery: %w", err)
+		}
+		if err := stash.UpdateGalleryTagsIfChanged(s.graphqlClient, gallery, tagIDs, nil); err != nil {
+			return err
+		}
+		if performers, changed := restoredPerformers(gallery.Performers, record.Performers, performerIDs); changed {
+			if err := stash.UpdateGalleryPerformers(s.graphqlClient, id, performers); err != nil {
+				return err
+			}
+		}
+	}
+	report.items++
+	return nil
+}
+
+// restoredPerformers returns an item's recorded performers (by their restored
+// IDs) plus its current performers that the backup does not cover, and
+// whether that differs from its current performers
+func restoredPerformers(current []stash.Performer, recorded []string, performerIDs map[string]graphql.ID) ([]graphql.ID, bool) {
+	covered := map[graphql.ID]bool{}
+	for _, id := range performerIDs {
+		covered[id] = true
+	}
+
+	var restored []graphql.ID
+	for _, id := range recorded {
+		if restoredID, ok := performerIDs[id]; ok {
+			restored = append(restored, restoredID)
+		} else {
+			restored = append(restored, graphql.ID(id))
+		}
+	}
+	for _, performer := range current {
+		if !covered[performer.ID] {
+			restored = append(restored, performer.ID)
+		}
+	}
+	restored = utils.DeduplicateIDs(restored)
+
+	if len(restored) != len(current) {
+		return restored, true
+	}
+	has := map[graphql.ID]bool{}
+	for _, performer := range current {
+		has[performer.ID] = true
+	}
+	for _, id := range restored {
+		if !has[id] {
+			return restored, true
+		}
+	}
+	return restored, false
+}
@@ -0,0 +1,273 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/pkg/utils"
+)
+
+// ============================================================================
+// Throughput Benchmark
+// ============================================================================
+//
+// The benchmark mode sizes hardware and compares configuration changes
+// without touching the library. It runs the stages of image recognition on
+// every image of a directory, a number of times over, and reports latency
+// percentiles per stage: loading and encoding the image, the Vision Service
+// image job, Compreface detection and recognition, and a Stash image query.
+// Nothing is written anywhere: no subjects, performers or tags. Stages whose
+// service is unavailable are skipped, and Compreface is not called in
+// anonymization mode.
+//
+// ============================================================================
+
+// Benchmark stages, in report order
+const (
+	BenchmarkStageLoad      = "load"                 // Read, orient and encode the image
+	BenchmarkStageVision    = "vision_image_job"     // Vision Service image job, submitted to completed
+	BenchmarkStageDetect    = "compreface_detect"    // Compreface detection
+	BenchmarkStageRecognize = "compreface_recognize" // Compreface recognition
+	BenchmarkStageStash     = "stash_query"          // One-image Stash query
+)
+
+// benchmarkStages lists the stages in report order
+var benchmarkStages = []string{
+	BenchmarkStageLoad,
+	BenchmarkStageVision,
+	BenchmarkStageDetect,
+	BenchmarkStageRecognize,
+	BenchmarkStageStash,
+}
+
+// benchmarkExtensions are the image files a benchmark directory is read for
+var benchmarkExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".webp": true,
+}
+
+// BenchmarkResult is the response of the benchmark mode
+type BenchmarkResult struct {
+	Path       string         `json:"path"`
+	Images     int            `json:"images"`
+	Iterations int            `json:"iterations"`
+	DurationMs int64          `json:"duration_ms"`
+	Stages     []StageLatency `json:"stages"` // Stages that ran, in pipeline order
+}
+
+// StageLatency summarizes the latency of one benchmark stage
+type StageLatency struct {
+	Stage    string  `json:"stage"`
+	Count    int     `json:"count"` // Successful calls
+	Failures int     `json:"failures"`
+	MeanMs   float64 `json:"mean_ms"`
+	P50Ms    float64 `json:"p50_ms"`
+	P90Ms    float64 `json:"p90_ms"`
+	P99Ms    float64 `json:"p99_ms"`
+	MaxMs    float64 `json:"max_ms"`
+}
+
+// benchmarkTimings collects the durations of each stage
+type benchmarkTimings struct {
+	durations map[string][]time.Duration
+	failures  map[string]int
+}
+
+// measure runs a stage call and records its duration, or its failure
+func (t *benchmarkTimings) measure(stage string, call func() error) error {
+	start := time.Now()
+	err := call()
+	if err != nil {
+		t.failures[stage]++
+		log.Debugf("Benchmark %s failed: %v", stage, err)
+		return err
+	}
+	t.durations[stage] = append(t.durations[stage], time.Since(start))
+	return nil
+}
+
+// benchmark runs the recognition stages iterations times over the images in
+// dir (relative to the plugin directory), at most limit images when set
+func (s *Service) benchmark(dir string, iterations int, limit int) (*BenchmarkResult, error) {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(s.serverConnection.PluginDir, dir)
+	}
+	images, err := benchmarkImages(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("no images (%s) in %s", strings.Join(sortedKeys(benchmarkExtensions), ", "), dir)
+	}
+	if limit > 0 && limit < len(images) {
+		images = images[:limit]
+	}
+	log.Infof("Benchmarking %d image(s) from %s, %d iteration(s)", len(images), dir, iterations)
+
+	visionClient := s.createVisionClient()
+	useCompreface := !s.config.AnonymizationMode
+	if !useCompreface {
+		log.Info("Anonymization mode: Compreface stages are skipped")
+	}
+
+	timings := &benchmarkTimings{durations: map[string][]time.Duration{}, failures: map[string]int{}}
+	started := time.Now()
+	total := iterations * len(images)
+	for iteration := 0; iteration < iterations; iteration++ {
+		for i, imagePath := range images {
+			if s.stopped() {
+				return nil, fmt.Errorf("operation cancelled")
+			}
+			s.reportProgress(float64(iteration*len(images)+i) / float64(total))
+
+			var imageBytes []byte
+			err := timings.measure(BenchmarkStageLoad, func() error {
+				var err error
+				imageBytes, err = s.loadImageBytes(imagePath)
+				return err
+			})
+			if err != nil {
+				log.Warnf("Benchmark: failed to load %s: %v", imagePath, err)
+				continue
+			}
+
+			if visionClient != nil {
+				imageID := fmt.Sprintf("benchmark-%d-%d", iteration+1, i+1)
+				timings.measure(BenchmarkStageVision, func() error {
+					_, err := s.SubmitImageJob(visionClient, imagePath, imageID)
+					return err
+				})
+			}
+
+			if useCompreface {
+				name := filepath.Base(imagePath)
+				timings.measure(BenchmarkStageDetect, func() error {
+					_, err := s.comprefaceClient.DetectFacesFromBytes(imageBytes, name)
+					return noFaceIsSuccess(err)
+				})
+				timings.measure(BenchmarkStageRecognize, func() error {
+					_, err := s.comprefaceClient.RecognizeFacesFromBytes(imageBytes, name)
+					return noFaceIsSuccess(err)
+				})
+			}
+
+			timings.measure(BenchmarkStageStash, func() error {
+				_, _, err := stash.FindImages(s.graphqlClient, nil, 1, 1)
+				return err
+			})
+		}
+	}
+	s.reportProgress(1.0)
+
+	result := &BenchmarkResult{
+		Path:       dir,
+		Images:     len(images),
+		Iterations: iterations,
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	for _, stage := range benchmarkStages {
+		if latency, ok := timings.latency(stage); ok {
+			result.Stages = append(result.Stages, latency)
+		}
+	}
+	log.Info(result.String())
+	return result, nil
+}
+
+// noFaceIsSuccess treats Compreface finding no face as a completed call
+func noFaceIsSuccess(err error) error {
+	if errors.Is(err, compreface.ErrNoFaceFound) {
+		return nil
+	}
+	return err
+}
+
+// latency summarizes a stage; false when the stage never ran
+func (t *benchmarkTimings) latency(stage string) (StageLatency, bool) {
+	durations := append([]time.Duration{}, t.durations[stage]...)
+	failures := t.failures[stage]
+	if len(durations) == 0 && failures == 0 {
+		return StageLatency{}, false
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	latency := StageLatency{Stage: stage, Count: len(durations), Failures: failures}
+	if len(durations) == 0 {
+		return latency, true
+	}
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+	latency.MeanMs = milliseconds(sum / time.Duration(len(durations)))
+	latency.P50Ms = milliseconds(utils.Percentile(durations, 50))
+	latency.P90Ms = milliseconds(utils.Percentile(durations, 90))
+	latency.P99Ms = milliseconds(utils.Percentile(durations, 99))
+	latency.MaxMs = milliseconds(durations[len(durations)-1])
+	return latency, true
+}
+
+// String summarizes the result, one line per stage
+func (r *BenchmarkResult) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Benchmark: %d image(s) x %d iteration(s) in %s",
+		r.Images, r.Iterations, (time.Duration(r.DurationMs) * time.Millisecond).Round(time.Second))
+	for _, stage := range r.Stages {
+		fmt.Fprintf(&b, "\n  - %s: p50 %.0fms, p90 %.0fms, p99 %.0fms (%d ok, %d failed)",
+			stage.Stage, stage.P50Ms, stage.P90Ms, stage.P99Ms, stage.Count, stage.Failures)
+	}
+	return b.String()
+}
+
+// benchmarkImages lists the image files under dir, sorted
+func benchmarkImages(dir string) ([]string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("benchmark directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("benchmark path %s is not a directory", dir)
+	}
+
+	var images []string
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && benchmarkExtensions[strings.ToLower(filepath.Ext(path))] {
+			images = append(images, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read benchmark directory: %w", err)
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// sortedKeys returns the keys of a set, sorted
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,69 @@
+package rpc
+
+import (
+	"fmt"
+)
+
+// ============================================================================
+// Batch Error Budget
+// ============================================================================
+//
+// A misconfigured deployment (unmounted media, wrong API key, unreachable
+// service) makes every item of a batch fail. Rather than spend hours tagging
+// nothing, batch tasks track the outcome of their most recent items and abort
+// once the failure rate can no longer fall within budget.
+//
+// ============================================================================
+
+// errorBudget tracks item outcomes over a sliding window
+type errorBudget struct {
+	rate     float64
+	window   []bool // ring buffer of outcomes; true = failed
+	next     int
+	filled   int
+	failures int
+	lastErr  error
+}
+
+// newErrorBudget creates an error budget from the plugin configuration.
+// Returns nil (never exhausted) when the budget is disabled.
+func (s *Service) newErrorBudget() *errorBudget {
+	if s.config.ErrorBudgetRate >= 1 || s.config.ErrorBudgetWindow <= 0 {
+		return nil
+	}
+	return &errorBudget{
+		rate:   s.config.ErrorBudgetRate,
+		window: make([]bool, s.config.ErrorBudgetWindow),
+	}
+}
+
+// record adds an item outcome (err == nil is a success) and returns an error
+// describing the failures once the budget is exhausted
+func (b *errorBudget) record(err error) error {
+	if b == nil {
+		return nil
+	}
+
+	if b.filled == len(b.window) {
+		if b.window[b.next] {
+			b.failures--
+		}
+	} else {
+		b.filled++
+	}
+	b.window[b.next] = err != nil
+	b.next = (b.next + 1) % len(b.window)
+	if err != nil {
+		b.failures++
+		b.lastErr = err
+	}
+
+	// Exhausted once the failures alone exceed the budget for a full window,
+	// so a failing start aborts without waiting for the window to fill
+	if float64(b.failures) <= b.rate*float64(len(b.window)) {
+		return nil
+	}
+	return fmt.Errorf("aborting batch: %d of the last %d items failed (budget %.0f%%), last error: %v; "+
+		"check that media paths are mounted, service URLs and API keys are correct and the services are running",
+		b.failures, b.filled, b.rate*100, b.lastErr)
+}
@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Similarity Calibration
+// ============================================================================
+//
+// Compreface recognition models (FaceNet, ArcFace variants) spread similarity
+// scores differently, so a threshold tuned for one model silently loosens or
+// tightens matching after the model is switched. When similarity presets are
+// configured, the model is detected at task start and the first matching
+// preset replaces MinSimilarity for the run. The minSimilarity and
+// minQualityScore task arguments override both the settings and the preset
+// for a single run, e.g. to rerun a gallery permissively.
+//
+// ============================================================================
+
+// calibrateSimilarity applies the similarity preset for the detected
+// Compreface recognition model. Detection failures keep MinSimilarity.
+func (s *Service) calibrateSimilarity() {
+	if len(s.config.SimilarityPresets) == 0 {
+		return
+	}
+
+	model, err := s.comprefaceClient.DetectModel()
+	if err != nil {
+		log.Warnf("Failed to detect Compreface recognition model, using similarity threshold %.2f: %v", s.config.MinSimilarity, err)
+		return
+	}
+
+	for _, preset := range s.config.SimilarityPresets {
+		if preset.Matches(model) {
+			log.Infof("Compreface model %s: using similarity threshold %.2f (preset '%s')", model, preset.MinSimilarity, preset.Model)
+			s.config.MinSimilarity = preset.MinSimilarity
+			s.comprefaceClient.MinSimilarity = preset.MinSimilarity
+			return
+		}
+	}
+	log.Infof("Compreface model %s: no similarity preset, using threshold %.2f", model, s.config.MinSimilarity)
+}
+
+// overrideThresholds applies the minSimilarity and minQualityScore task
+// arguments to this run's configuration
+func (s *Service) overrideThresholds(args taskArgs) {
+	if args.Has("minSimilarity") {
+		similarity := args.Float("minSimilarity")
+		log.Infof("Using similarity threshold %.2f for this run (configured %.2f)", similarity, s.config.MinSimilarity)
+		s.config.MinSimilarity = similarity
+		s.comprefaceClient.MinSimilarity = similarity
+	}
+	if args.Has("minQualityScore") {
+		quality := args.Float("minQualityScore")
+		log.Infof("Using minimum quality score %.2f for this run (configured %.2f)", quality, s.config.MinQualityScore)
+		s.config.MinQualityScore = quality
+	}
+}
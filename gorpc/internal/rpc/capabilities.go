@@ -0,0 +1,157 @@
+package rpc
+
+import (
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/version"
+)
+
+// ============================================================================
+// Capability Manifest
+// ============================================================================
+//
+// Companion userscripts and UIs call the capabilities mode to learn what this
+// plugin build supports before showing their buttons: every task mode with
+// its arguments (from the argument schema, so it cannot drift from what Run
+// accepts), the features the configuration turns on, whether the Vision
+// Service answers, and the plugin version. Secrets and URLs are never
+// included; features only say whether they are configured.
+//
+// ============================================================================
+
+// Capabilities is the response of the capabilities mode
+type Capabilities struct {
+	Version  string           `json:"version"`
+	Commit   string           `json:"commit"`
+	Modes    []ModeCapability `json:"modes"` // Sorted by name
+	Features FeatureSet       `json:"features"`
+}
+
+// ModeCapability is a task mode and the arguments it accepts
+type ModeCapability struct {
+	Mode      string          `json:"mode"`
+	Batch     bool            `json:"batch"`              // Walks the library; one run at a time
+	Disabled  bool            `json:"disabled,omitempty"` // Refused in anonymization mode
+	Arguments []ArgCapability `json:"arguments"`
+}
+
+// ArgCapability describes a task argument
+type ArgCapability struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"` // id, bool, int, enum, string, float or date
+	Required bool        `json:"required"`
+	Default  interface{} `json:"default,omitempty"`
+	Min      *int        `json:"min,omitempty"`    // Lower bound of int and float arguments
+	Max      *int        `json:"max,omitempty"`    // Upper bound of int and float arguments, when bounded
+	Values   []string    `json:"values,omitempty"` // Allowed values of enum arguments
+}
+
+// FeatureSet reports the optional features this run has available
+type FeatureSet struct {
+	Vision               bool   `json:"vision"` // Vision Service configured and healthy
+	VisionVersion        string `json:"vision_version,omitempty"`
+	Enhancement          bool   `json:"enhancement"` // Face enhancement enabled and supported by the frame server
+	EnhancementModel     string `json:"enhancement_model,omitempty"`
+	QualityGates         bool   `json:"quality_gates"`         // Composite quality scores gate recognition or subject creation
+	EmbeddingRecognition bool   `json:"embedding_recognition"` // Vision embeddings recognized directly
+	FaceStore            bool   `json:"face_store"`
+	ReviewQueue          bool   `json:"review_queue"` // New faces queued for review
+	OverlayOCR           bool   `json:"overlay_ocr"`
+	StashBox             bool   `json:"stash_box"`
+	Webhook              bool   `json:"webhook"`
+	RunReports           bool   `json:"run_reports"`
+	FaceGallery          bool   `json:"face_gallery"`
+	Traces               bool   `json:"traces"` // OpenTelemetry span export
+	Anonymization        bool   `json:"anonymization"`
+	TestMode             bool   `json:"test_mode"`
+}
+
+// argKindNames names argument kinds in the manifest
+var argKindNames = map[argKind]string{
+	argID:     "id",
+	argBool:   "bool",
+	argInt:    "int",
+	argEnum:   "enum",
+	argString: "string",
+	argFloat:  "float",
+	argDate:   "date",
+}
+
+// capabilities reports the modes, arguments and features of this plugin build
+func (s *Service) capabilities() (*Capabilities, error) {
+	caps := &Capabilities{
+		Version:  version.Version,
+		Commit:   version.Commit,
+		Modes:    s.modeCapabilities(),
+		Features: s.features(),
+	}
+	log.Infof("Capabilities: %d modes, Vision Service available: %t", len(caps.Modes), caps.Features.Vision)
+	return caps, nil
+}
+
+// modeCapabilities describes every task mode from the argument schema
+func (s *Service) modeCapabilities() []ModeCapability {
+	modes := make([]ModeCapability, 0, len(taskArgSchemas))
+	for _, mode := range taskModes() {
+		entry := ModeCapability{
+			Mode:      mode,
+			Batch:     isBatchMode(mode),
+			Disabled:  s.config.AnonymizationMode && storesBiometricData(mode),
+			Arguments: []ArgCapability{},
+		}
+		for _, spec := range taskArgSchemas[mode] {
+			arg := ArgCapability{
+				Name:     spec.name,
+				Type:     argKindNames[spec.kind],
+				Required: spec.required,
+				Default:  spec.def,
+				Values:   spec.values,
+			}
+			if spec.kind == argInt || spec.kind == argFloat {
+				lower := spec.min
+				arg.Min = &lower
+				if spec.max > 0 {
+					upper := spec.max
+					arg.Max = &upper
+				}
+			}
+			entry.Arguments = append(entry.Arguments, arg)
+		}
+		modes = append(modes, entry)
+	}
+	return modes
+}
+
+// features reports the optional features of this run. Only the Vision
+// Service and the frame server are probed.
+func (s *Service) features() FeatureSet {
+	cfg := s.config
+	features := FeatureSet{
+		QualityGates:         cfg.MinQualityScore > 0 || cfg.MinProcessingQualityScore > 0,
+		EmbeddingRecognition: cfg.EnableEmbeddingRecognition,
+		FaceStore:            cfg.EmbeddingStore && !cfg.AnonymizationMode,
+		ReviewQueue:          cfg.ReviewNewFaces && !cfg.AnonymizationMode,
+		OverlayOCR:           cfg.OverlayOCR,
+		StashBox:             len(cfg.StashBoxEndpoints) > 0,
+		Webhook:              cfg.WebhookURL != "",
+		RunReports:           cfg.ReportsDir != "",
+		FaceGallery:          s.faceGalleryDir() != "",
+		Traces:               cfg.OTLPEndpoint != "",
+		Anonymization:        cfg.AnonymizationMode,
+		TestMode:             cfg.TestMode,
+	}
+
+	if cfg.VisionServiceURL == "" {
+		return features
+	}
+	health, err := s.newVisionClient().Health()
+	if err != nil {
+		log.Debugf("Vision Service unavailable: %v", err)
+		return features
+	}
+	features.Vision = true
+	features.VisionVersion, _ = health["version"].(string)
+	if features.Enhancement = s.enhancementAvailable(); features.Enhancement {
+		features.EnhancementModel = cfg.EnhanceModel
+	}
+	return features
+}
@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"path/filepath"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// checkpointsFile is the batch checkpoint file's path under the plugin directory
+const checkpointsFile = "data/checkpoints.jsonl"
+
+// openCheckpoints opens the batch checkpoints. Failures are logged and leave
+// runs unresumable.
+func (s *Service) openCheckpoints() func() {
+	path := filepath.Join(s.serverConnection.PluginDir, filepath.FromSlash(checkpointsFile))
+	checkpoints, err := store.OpenCheckpoints(path)
+	if err != nil {
+		log.Warnf("Batch checkpoints disabled: %v", err)
+		return func() {}
+	}
+
+	s.checkpoints = checkpoints
+	return func() {
+		s.checkpoints = nil
+		if err := checkpoints.Close(); err != nil {
+			log.Warnf("Failed to close batch checkpoints: %v", err)
+		}
+	}
+}
+
+// resumePoint returns the checkpoint a resumed run of task continues after.
+// Returns the zero checkpoint (start from the first page) unless the task
+// was started with resume=true and has a checkpoint.
+func (s *Service) resumePoint(task string) store.Checkpoint {
+	task = s.scopedTask(task)
+	if !s.resume || s.checkpoints == nil {
+		return store.Checkpoint{}
+	}
+	checkpoint, ok := s.checkpoints.Get(task)
+	if !ok {
+		log.Infof("No checkpoint for %s, starting from the first page", task)
+		return store.Checkpoint{}
+	}
+	log.Infof("Resuming %s in pass %d after page %d, ID %d (%d items processed by the interrupted run, %s)",
+		task, checkpoint.Pass+1, checkpoint.Page, checkpoint.LastID, checkpoint.Processed, checkpoint.Updated.Format("2006-01-02 15:04"))
+	return checkpoint
+}
+
+// checkpoint records where a task's current run has got to
+func (s *Service) checkpoint(checkpoint store.Checkpoint) {
+	if s.checkpoints == nil {
+		return
+	}
+	checkpoint.Task = s.scopedTask(checkpoint.Task)
+	if err := s.checkpoints.Put(checkpoint); err != nil {
+		log.Warnf("Failed to record checkpoint for %s: %v", checkpoint.Task, err)
+	}
+}
+
+// clearCheckpoint drops the checkpoint of a task that ran to completion
+func (s *Service) clearCheckpoint(task string) {
+	if s.checkpoints == nil {
+		return
+	}
+	task = s.scopedTask(task)
+	if err := s.checkpoints.Clear(task); err != nil {
+		log.Warnf("Failed to clear checkpoint for %s: %v", task, err)
+	}
+}
@@ -0,0 +1,109 @@
+package rpc
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// ============================================================================
+// Image Clips
+// ============================================================================
+//
+// Stash images can be short video clips (webm, mp4) or animated GIF/WebP
+// files, which still-image detection cannot decode. recognizeImageFaces
+// routes them through the video pipeline instead: the Vision Service samples
+// the clip like a scene, and each face is cropped from the frame the frame
+// server extracts at its representative timestamp. Results are written to the
+// image exactly as for stills.
+//
+// ============================================================================
+
+// clipExtensions are image file extensions always treated as video clips
+var clipExtensions = map[string]bool{
+	".webm": true,
+	".mp4":  true,
+	".m4v":  true,
+	".mov":  true,
+	".mkv":  true,
+	".avi":  true,
+	".wmv":  true,
+}
+
+// clipSamplingInterval is the seconds between sampled frames of a clip;
+// clips are short, so they are sampled more densely than scenes
+const clipSamplingInterval = 0.5
+
+// isImageClip reports whether an image is a video clip or animated image:
+// Stash stores it as a video, its extension is a video format, it is a GIF
+// with more than one frame, or it cannot be decoded as a still image
+func (s *Service) isImageClip(img *stash.Image, imagePath string) bool {
+	ext := strings.ToLower(filepath.Ext(imagePath))
+	if img.IsClip() || clipExtensions[ext] {
+		return true
+	}
+
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return false // Reported by the still-image path
+	}
+	defer file.Close()
+
+	if ext == ".gif" {
+		animation, err := gif.DecodeAll(file)
+		return err == nil && len(animation.Image) > 1
+	}
+	if _, _, err := image.DecodeConfig(file); err != nil {
+		log.Debugf("Unable to decode %s as a still image (%v), treating it as a clip", imagePath, err)
+		return true
+	}
+	return false
+}
+
+// SubmitClipJob submits an image clip to the Vision Service as a video and
+// waits for results
+func (s *Service) SubmitClipJob(visionClient *vision.VisionServiceClient, clipPath string, imageID string) (*vision.AnalyzeResults, error) {
+	enhancementParams := s.buildEnhancementParameters()
+	parameters := vision.FacesParameters{
+		FaceMinConfidence:            s.config.MinConfidenceScore,
+		FaceMinQuality:               s.config.MinProcessingQualityScore,
+		MaxFaces:                     10, // Clips typically hold as few faces as images
+		SamplingInterval:             clipSamplingInterval,
+		EnableDeduplication:          true, // One face per person across the clip
+		EmbeddingSimilarityThreshold: 0.6,
+		DetectDemographics:           !s.config.SkipDemographics,
+		Enhancement:                  &enhancementParams,
+	}
+	if s.config.AnonymizationMode {
+		parameters.DetectDemographics = false
+		parameters.CacheDuration = anonymizedCacheDuration
+	}
+
+	request := vision.BuildAnalyzeRequest(clipPath, imageID, parameters)
+	request.SourceType = "video"
+
+	s.paceVisionJob(visionClient)
+	jobResp, err := visionClient.SubmitJob(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit job: %w", err)
+	}
+
+	log.Debugf("Image %s: Vision Service clip job submitted (job_id=%s)", imageID, jobResp.JobID)
+
+	timeout := time.Duration(s.config.VisionSceneJobTimeout) * time.Second
+	results, err := visionClient.WaitForCompletion(jobResp.JobID, timeout, func(p float64) {
+		log.Context(visionClient.Context).Debugf("Image %s: Vision Service progress: %.1f%%", imageID, p*100)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("vision service job failed: %w", err)
+	}
+	return results, nil
+}
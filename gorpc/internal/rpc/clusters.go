@@ -0,0 +1,286 @@
+package rpc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// ============================================================================
+// Unmatched Scene Clusters
+// ============================================================================
+//
+// Scene recognition matches a performer only from the angles their examples
+// cover; a profile or a face half turned away usually ends up as a cluster
+// without a performer. processScene keeps each such cluster (when faces are
+// not queued for review) with a thumbnail and its best detections.
+// listUnmatchedClusters returns them for a review UI, and assignCluster maps
+// one to a chosen performer: frames are extracted again at the cluster's
+// best detections and the crops are added as examples of the performer's
+// subject, so later scans recognize that angle.
+//
+// ============================================================================
+
+// clustersFile is the unmatched cluster index's path under the plugin directory
+const clustersFile = "data/clusters.jsonl"
+
+// Detections kept per unmatched cluster, and the minimum number of examples
+// added when one is assigned
+const (
+	clusterMaxDetections   = 10
+	clusterAssignExamples  = 3
+	clusterThumbnailMargin = 20 // Crop padding, in pixels
+)
+
+// openClusters opens the index of unmatched scene clusters. Failures are
+// logged and leave unmatched clusters unrecorded.
+func (s *Service) openClusters() func() {
+	if s.config.AnonymizationMode {
+		return func() {}
+	}
+
+	path := filepath.Join(s.serverConnection.PluginDir, filepath.FromSlash(clustersFile))
+	clusters, err := store.OpenClusters(path)
+	if err != nil {
+		log.Warnf("Unmatched cluster index disabled: %v", err)
+		return func() {}
+	}
+
+	s.clusters = clusters
+	return func() {
+		s.clusters = nil
+		if err := clusters.Close(); err != nil {
+			log.Warnf("Failed to close unmatched cluster index: %v", err)
+		}
+	}
+}
+
+// clearUnmatchedClusters forgets the unassigned clusters of a scene before
+// its faces are processed again
+func (s *Service) clearUnmatchedClusters(sceneID graphql.ID) {
+	if s.clusters == nil {
+		return
+	}
+	if err := s.clusters.ClearSource("scene:" + string(sceneID)); err != nil {
+		log.Warnf("Failed to clear unmatched clusters of scene %s: %v", sceneID, err)
+	}
+}
+
+// recordUnmatchedCluster keeps a scene face cluster left without a performer,
+// returning its cluster ID, or "" when it was not recorded
+func (s *Service) recordUnmatchedCluster(visionClient *vision.VisionServiceClient, ctx FaceProcessingContext, face vision.VisionFace, metadata vision.ResultMetadata) string {
+	if s.clusters == nil || ctx.Scene == nil {
+		return ""
+	}
+
+	frame, err := s.extractFrameBytesFromContext(visionClient, ctx, face, metadata)
+	if err != nil {
+		log.Debugf("Face %s: not recording unmatched cluster: %v", face.FaceID, err)
+		return ""
+	}
+	thumbnail, err := s.cropFaceFromFrame(frame, face.RepresentativeDetection.BBox, clusterThumbnailMargin)
+	if err != nil {
+		log.Debugf("Face %s: not recording unmatched cluster: %v", face.FaceID, err)
+		return ""
+	}
+
+	detections := []vision.VisionDetection{face.RepresentativeDetection}
+	detections = append(detections, s.selectExampleDetections(face)...)
+	if len(detections) > clusterMaxDetections {
+		detections = detections[:clusterMaxDetections]
+	}
+
+	cluster := store.UnmatchedCluster{
+		Source: "scene:" + ctx.SourceID,
+		Face:   face.FaceID,
+		Method: metadata.Method,
+	}
+	for _, det := range detections {
+		detection := store.ClusterDetection{
+			Timestamp: det.Timestamp,
+			XMin:      det.BBox.XMin,
+			YMin:      det.BBox.YMin,
+			XMax:      det.BBox.XMax,
+			YMax:      det.BBox.YMax,
+			Enhanced:  det.Enhanced,
+		}
+		if det.Quality != nil {
+			detection.Quality = det.Quality.Composite
+		}
+		cluster.Detections = append(cluster.Detections, detection)
+	}
+	if face.Demographics != nil {
+		cluster.Age = face.Demographics.Age
+		cluster.Gender = face.Demographics.Gender
+	}
+
+	recorded, added, err := s.clusters.Add(cluster, thumbnail)
+	if err != nil {
+		log.Warnf("Face %s: failed to record unmatched cluster: %v", face.FaceID, err)
+		return ""
+	}
+	if !added {
+		log.Debugf("Face %s: cluster %s was already assigned to performer %s", face.FaceID, recorded.ID, recorded.PerformerID)
+		return ""
+	}
+	log.Debugf("Face %s: recorded unmatched cluster %s", face.FaceID, recorded.ID)
+	return recorded.ID
+}
+
+// listUnmatchedClusters returns up to limit unassigned clusters of a scene,
+// or of every scene when sceneID is empty
+func (s *Service) listUnmatchedClusters(sceneID string, limit int) (*UnmatchedClustersResponse, error) {
+	if s.clusters == nil {
+		return nil, fmt.Errorf("unmatched cluster index is unavailable")
+	}
+
+	source := ""
+	if sceneID != "" {
+		source = "scene:" + sceneID
+	}
+	clusters := s.clusters.Unmatched(source)
+	response := &UnmatchedClustersResponse{Total: len(clusters), Clusters: []UnmatchedClusterEntry{}}
+	for _, cluster := range clusters {
+		if limit > 0 && len(response.Clusters) >= limit {
+			break
+		}
+		entry := UnmatchedClusterEntry{UnmatchedCluster: cluster}
+		if thumbnail, err := s.clusters.Thumbnail(cluster.ID); err == nil {
+			entry.Image = "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(thumbnail)
+		} else {
+			log.Warnf("Unmatched cluster %s: %v", cluster.ID, err)
+		}
+		response.Clusters = append(response.Clusters, entry)
+	}
+
+	log.Infof("%d unmatched cluster(s)", len(clusters))
+	return response, nil
+}
+
+// assignCluster adds the best detections of an unmatched cluster as examples
+// of a performer's subject, creating the subject for performers without
+// one, and adds the performer to the cluster's scene
+func (s *Service) assignCluster(clusterID string, performerID string) (string, error) {
+	if s.clusters == nil {
+		return "", fmt.Errorf("unmatched cluster index is unavailable")
+	}
+	cluster, ok := s.clusters.Get(clusterID)
+	if !ok {
+		return "", fmt.Errorf("unmatched cluster %s not found", clusterID)
+	}
+	if cluster.Status != store.ClusterOpen {
+		return "", fmt.Errorf("cluster %s was already assigned to performer %s", clusterID, cluster.PerformerID)
+	}
+
+	performer, err := stash.GetPerformerByID(s.graphqlClient, graphql.ID(performerID))
+	if err != nil {
+		return "", fmt.Errorf("failed to get performer: %w", err)
+	}
+	if performer.ID == "" {
+		return "", fmt.Errorf("performer %s not found", performerID)
+	}
+
+	_, sceneID, _ := strings.Cut(cluster.Source, ":")
+	scene, err := stash.GetScene(s.graphqlClient, graphql.ID(sceneID))
+	if err != nil {
+		return "", fmt.Errorf("failed to get scene: %w", err)
+	}
+
+	subject := compreface.FindPersonAlias(performer)
+	newSubject := subject == ""
+	if newSubject {
+		subject = compreface.CreateSubjectName(string(performer.ID))
+	}
+
+	added, err := s.addClusterExamples(cluster, scene, subject)
+	if err != nil {
+		return "", err
+	}
+
+	if newSubject {
+		input := stash.PerformerUpdateInput{
+			ID:        string(performer.ID),
+			AliasList: append(performer.AliasList, subject),
+		}
+		if err := stash.UpdatePerformer(s.graphqlClient, performer.ID, input); err != nil {
+			return "", fmt.Errorf("failed to add alias %s to performer %s: %w", subject, performer.ID, err)
+		}
+		log.Infof("Created subject %s for performer %s (%s)", subject, performer.Name, performer.ID)
+	}
+
+	if err := s.clusters.Assign(clusterID, string(performer.ID)); err != nil {
+		return "", err
+	}
+	if err := s.associateReviewedPerformer("scene", scene.ID, performer.ID); err != nil {
+		log.Warnf("Failed to associate performer %s with %s: %v", performer.ID, cluster.Source, err)
+	}
+
+	summary := fmt.Sprintf("Assigned cluster %s of %s to performer %s (%s): %d example(s) added to subject %s",
+		clusterID, cluster.Source, performer.ID, performer.Name, added, subject)
+	log.Info(summary)
+	return summary, nil
+}
+
+// addClusterExamples crops the face at the best detections of a cluster from
+// freshly extracted frames and adds the distinct crops as examples of a
+// subject. Fails when no example could be added.
+func (s *Service) addClusterExamples(cluster store.UnmatchedCluster, scene *stash.Scene, subject string) (int, error) {
+	visionClient := s.newVisionClient()
+	enhancement := s.buildEnhancementParameters()
+	metadata := vision.ResultMetadata{Method: cluster.Method, FrameEnhancement: &enhancement}
+	ctx := FaceProcessingContext{Scene: scene, SourceID: string(scene.ID)}
+
+	examples := max(s.config.SubjectExampleCount, clusterAssignExamples)
+	var stored [][]byte
+	var lastErr error
+	for _, detection := range cluster.Detections {
+		if len(stored) >= examples || s.stopped() {
+			break
+		}
+
+		bbox := vision.VisionBoundingBox{XMin: detection.XMin, YMin: detection.YMin, XMax: detection.XMax, YMax: detection.YMax}
+		face := vision.VisionFace{
+			FaceID:                  cluster.Face,
+			RepresentativeDetection: vision.VisionDetection{Timestamp: detection.Timestamp, BBox: bbox, Enhanced: detection.Enhanced},
+		}
+		frame, err := s.extractFrameBytesFromContext(visionClient, ctx, face, metadata)
+		if err != nil {
+			lastErr = err
+			log.Debugf("Cluster %s: failed to extract example at %.2fs: %v", cluster.ID, detection.Timestamp, err)
+			continue
+		}
+		crop, err := s.cropFaceFromFrame(frame, bbox, clusterThumbnailMargin)
+		if err != nil {
+			lastErr = err
+			log.Debugf("Cluster %s: failed to crop example at %.2fs: %v", cluster.ID, detection.Timestamp, err)
+			continue
+		}
+		if containsBytes(stored, crop) {
+			continue
+		}
+
+		if _, err := s.comprefaceClient.AddSubjectFromBytes(subject, crop, "face.jpg"); err != nil {
+			lastErr = err
+			log.Warnf("Cluster %s: failed to add example to subject %s: %v", cluster.ID, subject, err)
+			continue
+		}
+		stored = append(stored, crop)
+	}
+
+	if len(stored) == 0 {
+		if lastErr != nil {
+			return 0, fmt.Errorf("no example of cluster %s could be added: %w", cluster.ID, lastErr)
+		}
+		return 0, fmt.Errorf("no example of cluster %s could be added", cluster.ID)
+	}
+	return len(stored), nil
+}
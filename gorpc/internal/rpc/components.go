@@ -0,0 +1,186 @@
+package rpc
+
+import (
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/config"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// ============================================================================
+// Service Components
+// ============================================================================
+//
+// Task modes are routed to four feature areas behind interfaces instead of
+// straight to Service methods: images, scenes, performers and status. Each
+// component is built once the task's configuration is loaded, from the
+// clients it works through, so Run and fullPipeline only see the interfaces
+// and an area can be replaced (e.g. by a stub while another area is under
+// development) without touching the others.
+//
+// The status reporter only needs its clients. The image, scene and performer
+// components also share the task's Stash writer, local stores and run report,
+// so their defaults run the Service's workflows.
+//
+// ============================================================================
+
+// Clients are the services a component works through, set up for the task
+type Clients struct {
+	Stash      *graphql.Client
+	Compreface *compreface.Client
+	Vision     func() *vision.VisionServiceClient // New Vision Service client bound to the task
+	Config     *config.PluginConfig
+}
+
+// ImagePipeline recognizes and identifies faces in images and galleries
+type ImagePipeline interface {
+	RecognizeImages(limit int, createNewSubjects bool) error
+	IdentifyImages(newOnly bool, limit int) error
+	IdentifyImage(imageID string, createPerformer bool, associateExisting bool, faceIndex *int) (*[]FaceIdentity, error)
+	IdentifyGallery(galleryID string, createPerformer bool, limit int, full bool) (*GallerySummary, error)
+	VerifyPerformerImage(imageID string, performerID string, associate bool) (*PerformerImageVerification, error)
+	ResetUnmatchedImages(limit int) error
+}
+
+// ScenePipeline recognizes and identifies faces in scenes
+type ScenePipeline interface {
+	RecognizeScenes(useSprites bool, scope SceneScope, limit int, createNewSubjects bool) error
+	IdentifyScene(sceneID string, createPerformer bool, associateExisting bool, useSprites bool) (*SceneIdentification, error)
+	CreatePerformerFromScene(sceneID string, timestamp float64, faceIndex int) (string, error)
+	ResetUnmatchedScenes(limit int) error
+}
+
+// PerformerSync keeps Stash performers and Compreface subjects in step
+type PerformerSync interface {
+	SynchronizePerformers(limit int) error
+	DeleteSubjectForPerformer(performerID string, deletePerformer bool) error
+	DedupeAliases(limit int) error
+	RepairSubjectLinks(limit int) (string, error)
+	MergeDuplicatePerformers(limit int) (string, error)
+}
+
+// StatusReporter reports the plugin version and the state of its services
+type StatusReporter interface {
+	Status() (string, error)
+}
+
+// Components build the feature areas task modes are routed to from the
+// task's clients. Nil fields build the default components.
+type Components struct {
+	Images     func(Clients) ImagePipeline
+	Scenes     func(Clients) ScenePipeline
+	Performers func(Clients) PerformerSync
+	Status     func(Clients) StatusReporter
+}
+
+// components are the feature areas of the current task
+type components struct {
+	images     ImagePipeline
+	scenes     ScenePipeline
+	performers PerformerSync
+	status     StatusReporter
+}
+
+// clients returns the clients set up for the current task
+func (s *Service) clients() Clients {
+	return Clients{
+		Stash:      s.graphqlClient,
+		Compreface: s.comprefaceClient,
+		Vision:     s.newVisionClient,
+		Config:     s.config,
+	}
+}
+
+// buildComponents builds the task's components from its clients, using the
+// defaults where none were injected
+func (s *Service) buildComponents() {
+	clients := s.clients()
+	c := s.injected
+
+	s.components.images = imagePipeline{s}
+	if c.Images != nil {
+		s.components.images = c.Images(clients)
+	}
+	s.components.scenes = scenePipeline{s}
+	if c.Scenes != nil {
+		s.components.scenes = c.Scenes(clients)
+	}
+	s.components.performers = performerSync{s}
+	if c.Performers != nil {
+		s.components.performers = c.Performers(clients)
+	}
+	s.components.status = statusReporter{clients}
+	if c.Status != nil {
+		s.components.status = c.Status(clients)
+	}
+}
+
+// imagePipeline is the default ImagePipeline
+type imagePipeline struct{ s *Service }
+
+func (p imagePipeline) RecognizeImages(limit int, createNewSubjects bool) error {
+	return p.s.recognizeImages(limit, createNewSubjects)
+}
+
+func (p imagePipeline) IdentifyImages(newOnly bool, limit int) error {
+	return p.s.identifyImages(newOnly, limit)
+}
+
+func (p imagePipeline) IdentifyImage(imageID string, createPerformer bool, associateExisting bool, faceIndex *int) (*[]FaceIdentity, error) {
+	return p.s.identifyImage(imageID, createPerformer, associateExisting, faceIndex)
+}
+
+func (p imagePipeline) IdentifyGallery(galleryID string, createPerformer bool, limit int, full bool) (*GallerySummary, error) {
+	return p.s.identifyGallery(galleryID, createPerformer, limit, full)
+}
+
+func (p imagePipeline) VerifyPerformerImage(imageID string, performerID string, associate bool) (*PerformerImageVerification, error) {
+	return p.s.verifyPerformerImage(imageID, performerID, associate)
+}
+
+func (p imagePipeline) ResetUnmatchedImages(limit int) error {
+	return p.s.resetUnmatchedImages(limit)
+}
+
+// scenePipeline is the default ScenePipeline
+type scenePipeline struct{ s *Service }
+
+func (p scenePipeline) RecognizeScenes(useSprites bool, scope SceneScope, limit int, createNewSubjects bool) error {
+	return p.s.recognizeScenes(useSprites, scope, limit, createNewSubjects)
+}
+
+func (p scenePipeline) IdentifyScene(sceneID string, createPerformer bool, associateExisting bool, useSprites bool) (*SceneIdentification, error) {
+	return p.s.identifyScene(sceneID, createPerformer, associateExisting, useSprites)
+}
+
+func (p scenePipeline) CreatePerformerFromScene(sceneID string, timestamp float64, faceIndex int) (string, error) {
+	return p.s.createPerformerFromScene(sceneID, timestamp, faceIndex)
+}
+
+func (p scenePipeline) ResetUnmatchedScenes(limit int) error {
+	return p.s.resetUnmatchedScenes(limit)
+}
+
+// performerSync is the default PerformerSync
+type performerSync struct{ s *Service }
+
+func (p performerSync) SynchronizePerformers(limit int) error {
+	return p.s.synchronizePerformers(limit)
+}
+
+func (p performerSync) DeleteSubjectForPerformer(performerID string, deletePerformer bool) error {
+	return p.s.deleteSubjectForPerformer(performerID, deletePerformer)
+}
+
+func (p performerSync) DedupeAliases(limit int) error {
+	return p.s.dedupeAliases(limit)
+}
+
+func (p performerSync) RepairSubjectLinks(limit int) (string, error) {
+	return p.s.repairSubjectLinks(limit)
+}
+
+func (p performerSync) MergeDuplicatePerformers(limit int) (string, error) {
+	return p.s.mergeDuplicatePerformers(limit)
+}
@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Confidence Bands (Service Layer)
+// ============================================================================
+
+// worstSimilarity tracks the lowest match similarity among associated performers
+type worstSimilarity struct {
+	value float64
+	set   bool
+}
+
+// add records a match similarity
+func (w *worstSimilarity) add(similarity float64) {
+	if !w.set || similarity < w.value {
+		w.value = similarity
+		w.set = true
+	}
+}
+
+// confidenceBandTags resolves the confidence band tag to add and the opposite
+// band tag to remove for media whose worst match similarity is worst.
+// Returns nil slices when confidence tags are disabled or nothing matched.
+func (s *Service) confidenceBandTags(worst worstSimilarity) ([]graphql.ID, []graphql.ID) {
+	if !s.config.EnableConfidenceTags || !worst.set {
+		return nil, nil
+	}
+
+	bandTag := s.config.LowConfidenceTagName
+	removeTag := s.config.HighConfidenceTagName
+	if worst.value >= s.config.HighConfidenceThreshold {
+		bandTag, removeTag = removeTag, bandTag
+	}
+
+	bandTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, bandTag, bandTag)
+	if err != nil {
+		log.Warnf("Failed to get confidence tag: %v", err)
+		return nil, nil
+	}
+
+	removeTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, removeTag, removeTag)
+	if err != nil {
+		log.Warnf("Failed to get confidence tag: %v", err)
+		return []graphql.ID{bandTagID}, nil
+	}
+
+	log.Debugf("Worst match similarity %.2f - tagging as %s", worst.value, bandTag)
+	return []graphql.ID{bandTagID}, []graphql.ID{removeTagID}
+}
@@ -0,0 +1,31 @@
+package rpc
+
+import (
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Creation Gate
+// ============================================================================
+//
+// Bulk imports are full of low-signal media whose unmatched faces turn into
+// junk performers. With creationMinRating or creationOrganized set, batch
+// recognition and gallery identification only create subjects and performers
+// from media passing one of the configured conditions: rated at least
+// creationMinRating, or marked organized. Other media is still matched
+// against existing subjects. Single-item tasks create as asked, since the
+// user picked the item.
+//
+// ============================================================================
+
+// createsFromMedia reports whether unmatched faces of a media item may create
+// subjects and performers. rating is the item's rating100, nil when unrated.
+func (s *Service) createsFromMedia(kind string, id graphql.ID, rating *int, organized bool) bool {
+	if s.config.CreatesFromMedia(rating, organized) {
+		return true
+	}
+	log.Debugf("%s %s is below the creation threshold, matching existing subjects only", kind, id)
+	return false
+}
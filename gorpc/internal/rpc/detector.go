@@ -0,0 +1,58 @@
+package rpc
+
+import (
+	"fmt"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/config"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Image Face Detector Selection
+// ============================================================================
+//
+// Single-image identification can detect faces with the Vision Service
+// (better detection, quality gating) or with Compreface's own recognition
+// endpoint. ImageDetector picks one explicitly, or "auto" prefers the Vision
+// Service and falls back to Compreface when it is unavailable or fails. Every
+// detector returns a FaceDetectionResult so identification handles them alike.
+//
+// ============================================================================
+
+// detectImageFaces detects and identifies the faces in an image with the
+// configured detector. Returns nil when Compreface finds no faces; the image
+// is then already marked scanned.
+func (s *Service) detectImageFaces(imageID string, imagePath string, createPerformer bool, faceIndex *int) (*FaceDetectionResult, error) {
+	imagePath, cleanup, err := s.rawPreviewPath(imagePath)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	switch s.config.ImageDetector {
+	case config.ImageDetectorVision:
+		if s.config.VisionServiceURL == "" {
+			return nil, fmt.Errorf("image detector is %q but vision service URL not configured", config.ImageDetectorVision)
+		}
+		visionClient := s.newVisionClient()
+		if err := visionClient.HealthCheck(); err != nil {
+			return nil, fmt.Errorf("vision service health check failed: %w", err)
+		}
+		log.Infof("Using Vision Service for face detection: %s", imagePath)
+		return s.identifyImageViaVision(visionClient, imageID, imagePath, createPerformer, faceIndex)
+
+	case config.ImageDetectorCompreface:
+		return s.identifyImageViaCompreface(imageID, imagePath, createPerformer, faceIndex)
+	}
+
+	// Auto: prefer the Vision Service, fall back to Compreface
+	if visionClient := s.createVisionClient(); visionClient != nil {
+		log.Infof("Using Vision Service for face detection: %s", imagePath)
+		result, err := s.identifyImageViaVision(visionClient, imageID, imagePath, createPerformer, faceIndex)
+		if err == nil {
+			return result, nil
+		}
+		log.Warnf("Vision Service identification failed, falling back to Compreface: %v", err)
+	}
+	return s.identifyImageViaCompreface(imageID, imagePath, createPerformer, faceIndex)
+}
@@ -0,0 +1,206 @@
+package rpc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// double-take Import
+// ============================================================================
+//
+// Users migrating from double-take already have Compreface subjects and a
+// match history. The import reads a double-take export (see
+// compreface.ParseDoubleTakeExport) and, for every Stash image whose file
+// name appears in it:
+//
+//   - resolves each matched subject to a performer by name or alias, seeding
+//     the subject as an alias when the performer is only found by its display
+//     name ("jane_doe" -> "Jane Doe"), or creating the performer when asked
+//   - associates the performers with the image
+//   - applies the scanned, matched and completion tags, so batch tasks treat
+//     the image as already processed
+//
+// Subjects stay in Compreface as they are; later recognition finds the
+// performers through the seeded aliases.
+//
+// ============================================================================
+
+// doubleTakeReport counts what an import did
+type doubleTakeReport struct {
+	files      int
+	found      int
+	images     int
+	aliased    int
+	created    int
+	unresolved map[string]bool
+}
+
+// String summarizes the report
+func (r *doubleTakeReport) String() string {
+	summary := fmt.Sprintf("Imported double-take matches: %s of %s files found in Stash, %s images updated, %s aliases seeded, %s performers created",
+		formatCount(r.found), formatCount(r.files), formatCount(r.images), formatCount(r.aliased), formatCount(r.created))
+	if len(r.unresolved) > 0 {
+		subjects := make([]string, 0, len(r.unresolved))
+		for subject := range r.unresolved {
+			subjects = append(subjects, subject)
+		}
+		sort.Strings(subjects)
+		summary += fmt.Sprintf(", %s subjects without a performer (%s)", formatCount(len(subjects)), strings.Join(subjects, ", "))
+	}
+	return summary
+}
+
+// importDoubleTake seeds performer aliases, image associations and status
+// tags from the configured double-take export, up to limit files
+func (s *Service) importDoubleTake(limit int, createPerformer bool) (string, error) {
+	if s.stopped() {
+		return "", fmt.Errorf("operation cancelled")
+	}
+	if s.config.DoubleTakeExportPath == "" {
+		return "", fmt.Errorf("no double-take export configured (set doubleTakeExportPath)")
+	}
+
+	path := s.config.DoubleTakeExportPath
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.serverConnection.PluginDir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read double-take export: %w", err)
+	}
+	files, err := compreface.ParseDoubleTakeExport(data)
+	if err != nil {
+		return "", err
+	}
+	if limit > 0 && len(files) > limit {
+		files = files[:limit]
+	}
+	log.Infof("Importing %d files from double-take export %s", len(files), path)
+
+	report := &doubleTakeReport{files: len(files), unresolved: map[string]bool{}}
+	performers := map[string]graphql.ID{}
+	for i, file := range files {
+		if s.stopped() {
+			return "", fmt.Errorf("operation cancelled")
+		}
+		s.reportProgress(float64(i) / float64(len(files)))
+
+		if err := s.importDoubleTakeFile(file, createPerformer, performers, report); err != nil {
+			log.Warnf("Failed to import %s: %v", file.Filename, err)
+		}
+	}
+
+	s.reportProgress(1.0)
+	summary := report.String()
+	log.Info(summary)
+	return summary, nil
+}
+
+// importDoubleTakeFile associates a file's subjects with the Stash images of
+// that file name and tags them as processed
+func (s *Service) importDoubleTakeFile(file compreface.DoubleTakeFile, createPerformer bool, performers map[string]graphql.ID, report *doubleTakeReport) error {
+	images, err := stash.FindImagesByFileName(s.graphqlClient, file.Filename)
+	if err != nil {
+		return err
+	}
+	if len(images) == 0 {
+		log.Debugf("%s: not in Stash, skipping", file.Filename)
+		return nil
+	}
+	report.found++
+
+	var performerIDs []graphql.ID
+	var worst worstSimilarity
+	for _, subject := range file.Subjects {
+		performerID, ok := performers[subject.Name]
+		if !ok {
+			performerID, err = s.resolveDoubleTakeSubject(subject.Name, createPerformer, report)
+			if err != nil {
+				return err
+			}
+			performers[subject.Name] = performerID
+		}
+		if performerID == "" {
+			report.unresolved[subject.Name] = true
+			continue
+		}
+		performerIDs = append(performerIDs, performerID)
+		worst.add(subject.Similarity)
+	}
+	if len(performerIDs) == 0 {
+		return nil
+	}
+
+	for _, image := range images {
+		if err := s.associateExistingPerformers(image, performerIDs); err != nil {
+			return err
+		}
+		if err := s.updateImageStatuses(string(image.ID), true, file.Faces, performerIDs, worst); err != nil {
+			return err
+		}
+		log.Infof("Image %s (%s): associated %d double-take subject(s)", image.ID, file.Filename, len(performerIDs))
+		report.images++
+	}
+	return nil
+}
+
+// resolveDoubleTakeSubject finds the performer of a double-take subject by
+// the subject name, then by its display name (seeding the subject as an
+// alias), and otherwise creates it when createPerformer is set. Returns ""
+// when the subject has no performer.
+func (s *Service) resolveDoubleTakeSubject(subject string, createPerformer bool, report *doubleTakeReport) (graphql.ID, error) {
+	performerID, err := stash.FindPerformerBySubjectName(s.graphqlClient, subject)
+	if err != nil || performerID != "" {
+		return performerID, err
+	}
+
+	name := compreface.DoubleTakeDisplayName(subject)
+	if name != subject {
+		performerID, err = stash.FindPerformerBySubjectName(s.graphqlClient, name)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if performerID != "" {
+		performer, err := stash.GetPerformerByID(s.graphqlClient, performerID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get performer %s: %w", performerID, err)
+		}
+		input := stash.PerformerUpdateInput{
+			ID:        string(performer.ID),
+			AliasList: append(performer.AliasList, subject),
+		}
+		if err := stash.UpdatePerformer(s.graphqlClient, performer.ID, input); err != nil {
+			return "", fmt.Errorf("failed to add alias %s to performer %s: %w", subject, performer.ID, err)
+		}
+		log.Infof("Subject %s: seeded alias on performer %s (%s)", subject, performer.Name, performer.ID)
+		report.aliased++
+		return performer.ID, nil
+	}
+
+	if !createPerformer || name == "" {
+		return "", nil
+	}
+	performerSubject := stash.PerformerSubject{Name: name}
+	if name != subject {
+		performerSubject.Aliases = []string{subject}
+	}
+	performerID, err = stash.CreatePerformer(s.graphqlClient, performerSubject)
+	if err != nil {
+		return "", err
+	}
+	report.created++
+	s.performerCreated(performerID)
+	return performerID, nil
+}
@@ -0,0 +1,94 @@
+package rpc
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/pkg/utils"
+)
+
+// ============================================================================
+// Oversized Image Guard
+// ============================================================================
+//
+// Ultra-high-resolution scans can exhaust Vision Service memory. Images whose
+// longest side exceeds MaxImageDimension are downscaled to a temporary JPEG
+// in VisionTempDir (which must be visible to the Vision Service at the same
+// path) and detections are mapped back to original coordinates.
+//
+// ============================================================================
+
+// prepareVisionImage returns the path to submit to the Vision Service for
+// imagePath, the factor mapping coordinates on that image back to the
+// original, and a cleanup function removing any temporary file.
+func (s *Service) prepareVisionImage(imagePath string) (string, float64, func(), error) {
+	noop := func() {}
+
+	maxDimension := s.config.MaxImageDimension
+	if maxDimension <= 0 {
+		return imagePath, 1, noop, nil
+	}
+
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return "", 0, noop, fmt.Errorf("failed to open image: %w", err)
+	}
+	cfg, _, err := image.DecodeConfig(file)
+	file.Close()
+	if err != nil {
+		// Let the Vision Service handle formats we cannot inspect
+		log.Debugf("Unable to read dimensions of %s: %v", imagePath, err)
+		return imagePath, 1, noop, nil
+	}
+
+	if cfg.Width <= maxDimension && cfg.Height <= maxDimension {
+		return imagePath, 1, noop, nil
+	}
+
+	// Decode with EXIF orientation applied so coordinates match face cropping
+	imageBytes, err := s.loadImageBytes(imagePath)
+	if err != nil {
+		return "", 0, noop, err
+	}
+	img, _, err := utils.DecodeImageSRGB(imageBytes)
+	if err != nil {
+		return "", 0, noop, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	var resized image.Image
+	if bounds.Dx() >= bounds.Dy() {
+		resized = imaging.Resize(img, maxDimension, 0, imaging.Lanczos)
+	} else {
+		resized = imaging.Resize(img, 0, maxDimension, imaging.Lanczos)
+	}
+	scale := float64(bounds.Dx()) / float64(resized.Bounds().Dx())
+
+	tmp, err := os.CreateTemp(s.config.VisionTempDir, "compreface-vision-*.jpg")
+	if err != nil {
+		return "", 0, noop, fmt.Errorf("failed to create temporary image: %w", err)
+	}
+	cleanup := func() {
+		if err := os.Remove(tmp.Name()); err != nil {
+			log.Warnf("Failed to remove temporary image %s: %v", tmp.Name(), err)
+		}
+	}
+
+	err = jpeg.Encode(tmp, resized, &jpeg.Options{Quality: s.jpegQuality(95)})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", 0, noop, fmt.Errorf("failed to write temporary image: %w", err)
+	}
+
+	log.Infof("Downscaled %s from %dx%d to %dx%d for Vision Service",
+		imagePath, bounds.Dx(), bounds.Dy(), resized.Bounds().Dx(), resized.Bounds().Dy())
+	return tmp.Name(), scale, cleanup, nil
+}
@@ -0,0 +1,220 @@
+package rpc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Tag Drift Report
+// ============================================================================
+//
+// The plugin's status tags always satisfy a few invariants: Matched, Partial
+// and Complete are only applied together with Scanned, Matched media has
+// performers, Partial and Complete are exclusive, and Synced performers keep
+// their "Person ..." alias. Media and performers breaking an invariant had
+// plugin tags, performers or aliases edited by hand. They are reported, never
+// changed, so automation does not silently fight manual curation.
+//
+// ============================================================================
+
+// Tag drift kinds
+const (
+	driftScannedRemoved    = "status tag without Scanned (Scanned removed)"
+	driftPerformersRemoved = "Matched without performers (performers removed)"
+	driftStatusConflict    = "both Partial and Complete"
+	driftAliasRemoved      = "Synced without a 'Person ...' alias (alias removed)"
+)
+
+// pluginTagIDs holds the IDs of the plugin's status tags; "" when a tag does
+// not exist in Stash
+type pluginTagIDs struct {
+	scanned, matched, partial, complete, synced graphql.ID
+}
+
+// tagDriftReport counts drifted items by kind
+type tagDriftReport struct {
+	counts  map[string]int
+	checked int
+}
+
+// add records a drifted item
+func (r *tagDriftReport) add(kind string, item string) {
+	r.counts[kind]++
+	log.Warnf("Tag drift: %s: %s", item, kind)
+}
+
+// String summarizes the report
+func (r *tagDriftReport) String() string {
+	if len(r.counts) == 0 {
+		return fmt.Sprintf("No tag drift found in %s items", formatCount(r.checked))
+	}
+
+	kinds := make([]string, 0, len(r.counts))
+	for kind := range r.counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	parts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		parts = append(parts, fmt.Sprintf("%s: %s", kind, formatCount(r.counts[kind])))
+	}
+	return fmt.Sprintf("Tag drift in %s items checked - %s", formatCount(r.checked), strings.Join(parts, "; "))
+}
+
+// reportTagDrift checks plugin-tagged images, scenes and performers against
+// the tag invariants and reports items that were edited by hand
+func (s *Service) reportTagDrift(limit int) (string, error) {
+	if s.stopped() {
+		return "", fmt.Errorf("operation cancelled")
+	}
+
+	tags, err := s.lookupPluginTags()
+	if err != nil {
+		return "", err
+	}
+	statusTags := nonEmptyIDs(tags.scanned, tags.matched, tags.partial, tags.complete)
+
+	report := &tagDriftReport{counts: map[string]int{}}
+	checkMedia := func(item string, mediaTags []stash.Tag, performers int) error {
+		if s.stopped() {
+			return fmt.Errorf("operation cancelled")
+		}
+		if limit > 0 && report.checked >= limit {
+			return stash.ErrStopPaging
+		}
+		report.checked++
+		for _, kind := range tags.mediaDrift(mediaTags, performers) {
+			report.add(kind, item)
+		}
+		return nil
+	}
+
+	if len(statusTags) > 0 {
+		tagged := &stash.HierarchicalMultiCriterionInput{Value: statusTags, Modifier: stash.CriterionModifierIncludes}
+
+		log.Info("Checking images for tag drift")
+		err = stash.FindAllImages(s.graphqlClient, &stash.ImageFilterType{Tags: tagged}, stash.DefaultPageSize, func(images []stash.Image, total int) error {
+			for _, image := range images {
+				if err := checkMedia("image "+string(image.ID), image.Tags, len(image.Performers)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to query images: %w", err)
+		}
+
+		log.Info("Checking scenes for tag drift")
+		err = stash.FindAllScenes(s.graphqlClient, &stash.SceneFilterType{Tags: tagged}, stash.DefaultPageSize, func(scenes []stash.Scene, total int) error {
+			for _, scene := range scenes {
+				if err := checkMedia("scene "+string(scene.ID), scene.Tags, len(scene.Performers)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to query scenes: %w", err)
+		}
+	}
+
+	if tags.synced != "" {
+		log.Info("Checking performers for tag drift")
+		synced := &stash.HierarchicalMultiCriterionInput{Value: []string{string(tags.synced)}, Modifier: stash.CriterionModifierIncludes}
+		err = stash.FindAllPerformers(s.graphqlClient, &stash.PerformerFilterType{Tags: synced}, stash.DefaultPageSize, func(performers []stash.Performer, total int) error {
+			for i := range performers {
+				if s.stopped() {
+					return fmt.Errorf("operation cancelled")
+				}
+				if limit > 0 && report.checked >= limit {
+					return stash.ErrStopPaging
+				}
+				report.checked++
+				if compreface.FindPersonAlias(&performers[i]) == "" {
+					report.add(driftAliasRemoved, fmt.Sprintf("performer %s (%s)", performers[i].ID, performers[i].Name))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to query performers: %w", err)
+		}
+	}
+
+	summary := report.String()
+	log.Info(summary)
+	return summary, nil
+}
+
+// lookupPluginTags finds the plugin's status tags without creating them
+func (s *Service) lookupPluginTags() (pluginTagIDs, error) {
+	var tags pluginTagIDs
+	for _, t := range []struct {
+		name string
+		id   *graphql.ID
+	}{
+		{s.config.ScannedTagName, &tags.scanned},
+		{s.config.MatchedTagName, &tags.matched},
+		{s.config.PartialTagName, &tags.partial},
+		{s.config.CompleteTagName, &tags.complete},
+		{s.config.SyncedTagName, &tags.synced},
+	} {
+		ids, err := stash.FindTagIDs(s.graphqlClient, s.tagCache, []string{t.name})
+		if err != nil {
+			return tags, fmt.Errorf("failed to find tag %s: %w", t.name, err)
+		}
+		if len(ids) > 0 {
+			*t.id = ids[0]
+		}
+	}
+	return tags, nil
+}
+
+// mediaDrift returns the invariants an image or scene with the given tags
+// and performer count breaks
+func (t pluginTagIDs) mediaDrift(tags []stash.Tag, performers int) []string {
+	has := func(id graphql.ID) bool {
+		if id == "" {
+			return false
+		}
+		for _, tag := range tags {
+			if tag.ID == id {
+				return true
+			}
+		}
+		return false
+	}
+
+	var drift []string
+	if !has(t.scanned) && (has(t.matched) || has(t.partial) || has(t.complete)) {
+		drift = append(drift, driftScannedRemoved)
+	}
+	if has(t.matched) && performers == 0 {
+		drift = append(drift, driftPerformersRemoved)
+	}
+	if has(t.partial) && has(t.complete) {
+		drift = append(drift, driftStatusConflict)
+	}
+	return drift
+}
+
+// nonEmptyIDs returns the non-empty IDs as strings
+func nonEmptyIDs(ids ...graphql.ID) []string {
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != "" {
+			values = append(values, string(id))
+		}
+	}
+	return values
+}
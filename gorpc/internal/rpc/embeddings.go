@@ -0,0 +1,118 @@
+package rpc
+
+import (
+	"sync"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// ============================================================================
+// Batched Embedding Recognition
+// ============================================================================
+//
+// Embedding recognition used to cost one Compreface request per face. Before
+// the faces of an image or scene are processed, prefetchEmbeddingMatches
+// recognizes every 512-D Vision embedding of the item in a single
+// RecognizeEmbeddings request and keeps each face's best subject, so the
+// per-face lookups (recognizeByEmbedding, match tie-breaking) are answered
+// without a request. Faces missing from the batch, or items whose batch
+// failed, fall back to a request per face.
+//
+// ============================================================================
+
+// embeddingMatches holds the best subject recognized for the prefetched
+// embeddings of the items in flight, keyed by face hash
+type embeddingMatches struct {
+	mu      sync.Mutex
+	matches map[string]compreface.EmbeddingSimilarity // Empty Subject: no subject matched
+}
+
+// prefetchEmbeddingMatches recognizes the 512-D embeddings of an item's faces
+// in one request. The returned function forgets them once the item is done.
+func (s *Service) prefetchEmbeddingMatches(faces []vision.VisionFace) func() {
+	noop := func() {}
+	if !s.config.EnableEmbeddingRecognition {
+		return noop
+	}
+
+	var embeddings [][]float64
+	var keys []string
+	seen := map[string]bool{}
+	for _, face := range faces {
+		if len(face.Embedding) != 512 {
+			continue
+		}
+		key := store.FaceHash(face.Embedding)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		embeddings = append(embeddings, face.Embedding)
+		keys = append(keys, key)
+	}
+	if len(embeddings) < 2 {
+		return noop // A single face costs the same request either way
+	}
+
+	span := trace.StartSpan(trace.StageRecognize)
+	span.SetAttribute("recognition.method", "embedding batch")
+	resp, err := s.comprefaceClient.RecognizeEmbeddings(embeddings, 1)
+	span.End(err)
+	if err != nil {
+		log.Debugf("Batched embedding recognition failed, recognizing faces one at a time: %v", err)
+		return noop
+	}
+	if len(resp.Result) != len(embeddings) {
+		log.Debugf("Batched embedding recognition returned %d results for %d embeddings, recognizing faces one at a time",
+			len(resp.Result), len(embeddings))
+		return noop
+	}
+
+	s.embeddingMatches.mu.Lock()
+	if s.embeddingMatches.matches == nil {
+		s.embeddingMatches.matches = map[string]compreface.EmbeddingSimilarity{}
+	}
+	for i, result := range resp.Result {
+		var best compreface.EmbeddingSimilarity
+		if len(result.Similarities) > 0 {
+			best = result.Similarities[0]
+		}
+		s.embeddingMatches.matches[keys[i]] = best
+	}
+	s.embeddingMatches.mu.Unlock()
+	log.Debugf("Recognized %d face embeddings in one request", len(embeddings))
+
+	return func() {
+		s.embeddingMatches.mu.Lock()
+		defer s.embeddingMatches.mu.Unlock()
+		for _, key := range keys {
+			delete(s.embeddingMatches.matches, key)
+		}
+	}
+}
+
+// bestEmbeddingMatch returns the subject most similar to an embedding, from
+// the prefetched batch when available. An empty Subject means no subject
+// matched.
+func (s *Service) bestEmbeddingMatch(embedding []float64) (compreface.EmbeddingSimilarity, error) {
+	key := store.FaceHash(embedding)
+	s.embeddingMatches.mu.Lock()
+	best, ok := s.embeddingMatches.matches[key]
+	s.embeddingMatches.mu.Unlock()
+	if ok {
+		return best, nil
+	}
+
+	resp, err := s.comprefaceClient.RecognizeEmbedding(embedding, 1)
+	if err != nil {
+		return compreface.EmbeddingSimilarity{}, err
+	}
+	if len(resp.Result) > 0 && len(resp.Result[0].Similarities) > 0 {
+		best = resp.Result[0].Similarities[0]
+	}
+	return best, nil
+}
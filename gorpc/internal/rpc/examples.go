@@ -0,0 +1,179 @@
+package rpc
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// ============================================================================
+// Subject Examples
+// ============================================================================
+
+// addSubjectExamples stores up to SubjectExampleCount-1 additional detections
+// from a scene face cluster as examples of a newly created subject. The
+// representative detection is already stored by createComprefaceSubject.
+// Failures are logged and do not affect the created subject.
+func (s *Service) addSubjectExamples(visionClient *vision.VisionServiceClient, ctx FaceProcessingContext, face vision.VisionFace, metadata vision.ResultMetadata, subject string, representativeCrop []byte) {
+	extra := s.config.SubjectExampleCount - 1
+	if extra <= 0 || ctx.Scene == nil {
+		return
+	}
+
+	candidates := s.selectExampleDetections(face)
+
+	// Sprite thumbnails can repeat across timestamps, so identical crops are skipped
+	stored := [][]byte{representativeCrop}
+	added := 0
+
+	for _, det := range candidates {
+		if added >= extra || s.stopped() {
+			break
+		}
+
+		exampleFace := face
+		exampleFace.RepresentativeDetection = det
+
+		frameBytes, err := s.extractFrameBytesFromContext(visionClient, ctx, exampleFace, metadata)
+		if err != nil {
+			log.Debugf("Face %s: failed to extract example at %.2fs: %v", face.FaceID, det.Timestamp, err)
+			continue
+		}
+
+		crop, err := s.cropFaceFromFrame(frameBytes, det.BBox, 20)
+		if err != nil {
+			log.Debugf("Face %s: failed to crop example at %.2fs: %v", face.FaceID, det.Timestamp, err)
+			continue
+		}
+
+		if containsBytes(stored, crop) {
+			continue
+		}
+
+		if _, err := s.comprefaceClient.AddSubjectFromBytes(subject, crop, fmt.Sprintf("face_%d.jpg", det.FrameIndex)); err != nil {
+			log.Warnf("Face %s: failed to add example to subject %s: %v", face.FaceID, subject, err)
+			continue
+		}
+
+		stored = append(stored, crop)
+		added++
+	}
+
+	log.Debugf("Face %s: stored %d additional examples for subject %s", face.FaceID, added, subject)
+}
+
+// selectExampleDetections returns the detections other than the
+// representative, best composite quality first. Only non-occluded detections
+// that pass the subject creation quality gates and fall on distinct
+// timestamps are considered.
+func (s *Service) selectExampleDetections(face vision.VisionFace) []vision.VisionDetection {
+	type candidate struct {
+		det       vision.VisionDetection
+		composite float64
+	}
+
+	seen := map[float64]bool{face.RepresentativeDetection.Timestamp: true}
+	var candidates []candidate
+
+	for _, det := range face.Detections {
+		if seen[det.Timestamp] {
+			continue
+		}
+		if det.Occlusion != nil && det.Occlusion.Occluded {
+			continue
+		}
+		qr := s.assessFaceQuality(det.Quality, s.config.MinQualityScore)
+		if !qr.Acceptable {
+			continue
+		}
+		seen[det.Timestamp] = true
+		candidates = append(candidates, candidate{det: det, composite: qr.Composite})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].composite > candidates[j].composite
+	})
+
+	detections := make([]vision.VisionDetection, len(candidates))
+	for i, c := range candidates {
+		detections[i] = c.det
+	}
+	return detections
+}
+
+// containsBytes reports whether data equals any entry of list
+func containsBytes(list [][]byte, data []byte) bool {
+	for _, item := range list {
+		if bytes.Equal(item, data) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxVerifiedExamples caps the verification calls made to explain one match
+const maxVerifiedExamples = 10
+
+// matchedExample identifies which stored example of subject best matches
+// faceCrop, so identify responses can show what the subject looks like.
+// A subject with a single example needs no verification; otherwise each
+// example (up to maxVerifiedExamples) is verified against the crop.
+// Returns nil when the example cannot be determined.
+func (s *Service) matchedExample(subject string, faceCrop []byte) *MatchedExample {
+	faces, err := s.comprefaceClient.ListFaces(subject)
+	if err != nil {
+		log.Debugf("Failed to list examples of subject %s: %v", subject, err)
+		return nil
+	}
+	if len(faces) == 0 {
+		return nil
+	}
+
+	if len(faces) == 1 {
+		return s.withExampleImage(&MatchedExample{Subject: subject, ImageID: faces[0].ImageID})
+	}
+
+	if faceCrop == nil {
+		return nil
+	}
+	if len(faces) > maxVerifiedExamples {
+		faces = faces[:maxVerifiedExamples]
+	}
+
+	var best *MatchedExample
+	for _, face := range faces {
+		similarity, err := s.comprefaceClient.VerifyFaceFromBytes(face.ImageID, faceCrop, "face.jpg")
+		if err != nil {
+			log.Debugf("Failed to verify face against example %s: %v", face.ImageID, err)
+			continue
+		}
+		if best == nil || similarity > *best.Similarity {
+			similarity := similarity
+			best = &MatchedExample{
+				Subject:    subject,
+				ImageID:    face.ImageID,
+				Similarity: &similarity,
+			}
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return s.withExampleImage(best)
+}
+
+// withExampleImage embeds a matched example's image. Compreface only serves
+// examples with the API key, which responses must not carry, so the image is
+// downloaded and returned as a data URI.
+func (s *Service) withExampleImage(example *MatchedExample) *MatchedExample {
+	image, err := s.faceImageDataURI(example.ImageID)
+	if err != nil {
+		log.Debugf("Failed to download example %s of subject %s: %v", example.ImageID, example.Subject, err)
+		return example
+	}
+	example.Image = image
+	return example
+}
@@ -0,0 +1,144 @@
+package rpc
+
+import (
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Shared Exclusion Tags
+// ============================================================================
+//
+// Other AI plugins honour a user-maintained exclusion tag such as
+// "AI: Exclude". The configured exclusion tags are merged into every task
+// filter so one tag governs all AI tooling. Missing tags are never created.
+//
+// The plugin's own exclude tag (excludeTagName, "Compreface Ignore") is one
+// of them, and reaches further in batch tasks: an image or scene is also
+// skipped when a gallery it belongs to or its studio carries the tag, so a
+// whole gallery or studio can be exempted from scanning with one tag.
+//
+// ============================================================================
+
+// sharedExclusionTagIDs resolves the configured exclusion tags once per task
+func (s *Service) sharedExclusionTagIDs() []string {
+	if s.exclusionTagIDs != nil {
+		return s.exclusionTagIDs
+	}
+
+	s.exclusionTagIDs = append([]string{}, s.excludeTagIDs()...)
+	if len(s.config.ExclusionTagNames) == 0 {
+		return s.exclusionTagIDs
+	}
+
+	tagIDs, err := stash.FindTagIDs(s.graphqlClient, s.tagCache, s.config.ExclusionTagNames)
+	if err != nil {
+		log.Warnf("Failed to resolve exclusion tags %v: %v", s.config.ExclusionTagNames, err)
+		return s.exclusionTagIDs
+	}
+
+	for _, tagID := range tagIDs {
+		s.exclusionTagIDs = append(s.exclusionTagIDs, string(tagID))
+	}
+	log.Debugf("Excluding items tagged with %d shared exclusion tags", len(s.exclusionTagIDs))
+	return s.exclusionTagIDs
+}
+
+// excludeTagIDs resolves the exclude tag once per task
+func (s *Service) excludeTagIDs() []string {
+	if s.ignoreTagIDs != nil {
+		return s.ignoreTagIDs
+	}
+
+	s.ignoreTagIDs = []string{}
+	if s.config.ExcludeTagName == "" {
+		return s.ignoreTagIDs
+	}
+
+	tagIDs, err := stash.FindTagIDs(s.graphqlClient, s.tagCache, []string{s.config.ExcludeTagName})
+	if err != nil {
+		log.Warnf("Failed to resolve exclude tag %s: %v", s.config.ExcludeTagName, err)
+		return s.ignoreTagIDs
+	}
+
+	for _, tagID := range tagIDs {
+		s.ignoreTagIDs = append(s.ignoreTagIDs, string(tagID))
+	}
+	return s.ignoreTagIDs
+}
+
+// excludeTagsCriterion builds an EXCLUDES tag criterion for the given tags plus
+// the shared exclusion tags. Returns nil when there is nothing to exclude.
+func (s *Service) excludeTagsCriterion(tagIDs ...graphql.ID) *stash.HierarchicalMultiCriterionInput {
+	values := make([]string, 0, len(tagIDs))
+	for _, tagID := range tagIDs {
+		values = append(values, string(tagID))
+	}
+	values = append(values, s.sharedExclusionTagIDs()...)
+
+	if len(values) == 0 {
+		return nil
+	}
+	return &stash.HierarchicalMultiCriterionInput{
+		Value:    values,
+		Modifier: stash.CriterionModifierExcludes,
+	}
+}
+
+// excludedContainersCriterion returns the criterion matching the exclude tag,
+// or nil when the tag does not exist
+func (s *Service) excludedContainersCriterion() *stash.HierarchicalMultiCriterionInput {
+	tagIDs := s.excludeTagIDs()
+	if len(tagIDs) == 0 {
+		return nil
+	}
+	return &stash.HierarchicalMultiCriterionInput{
+		Value:    tagIDs,
+		Modifier: stash.CriterionModifierIncludes,
+	}
+}
+
+// excludeIgnoredImages narrows an image filter to images whose galleries and
+// studio do not carry the exclude tag, and that are not face gallery crops.
+// A nil filter is allocated when needed.
+func (s *Service) excludeIgnoredImages(filter *stash.ImageFilterType) *stash.ImageFilterType {
+	var excluded []*stash.ImageFilterType
+	if tagged := s.excludedContainersCriterion(); tagged != nil {
+		excluded = append(excluded,
+			&stash.ImageFilterType{GalleriesFilter: &stash.GalleryFilterType{Tags: tagged}},
+			&stash.ImageFilterType{StudiosFilter: &stash.StudioFilterType{Tags: tagged}})
+	}
+	if root := s.faceGalleryDir(); root != "" {
+		excluded = append(excluded, &stash.ImageFilterType{Path: faceGalleryPathCriterion(root)})
+	}
+	if len(excluded) == 0 {
+		return filter
+	}
+	if filter == nil {
+		filter = &stash.ImageFilterType{}
+	}
+
+	// Chain the exclusions: NOT (a OR b OR ...)
+	for i := len(excluded) - 1; i > 0; i-- {
+		excluded[i-1].OperatorFilter.Or = excluded[i]
+	}
+	filter.OperatorFilter.Not = excluded[0]
+	return filter
+}
+
+// excludeIgnoredScenes narrows a scene filter to scenes whose galleries and
+// studio do not carry the exclude tag
+func (s *Service) excludeIgnoredScenes(filter *stash.SceneFilterType) {
+	tagged := s.excludedContainersCriterion()
+	if tagged == nil {
+		return
+	}
+	filter.OperatorFilter.Not = &stash.SceneFilterType{
+		GalleriesFilter: &stash.GalleryFilterType{Tags: tagged},
+		OperatorFilter: stash.OperatorFilter[stash.SceneFilterType]{
+			Or: &stash.SceneFilterType{StudiosFilter: &stash.StudioFilterType{Tags: tagged}},
+		},
+	}
+}
@@ -0,0 +1,200 @@
+package rpc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Face Gallery
+// ============================================================================
+//
+// Batch recognition crops every face it sends to Compreface, and only the
+// crop of a newly created performer survives, as its image. With
+// faceGalleryDir set (a folder inside a Stash library), the crops of
+// created performers and of faces matched at or above
+// faceGalleryMinQuality are also written to one folder per performer,
+// "performer-<id>", up to faceGalleryMaxCrops each. The end of the run asks
+// Stash to scan the folders written to, and links the images Stash found
+// in earlier scans to their folder's performer, so each performer gathers a
+// visual history of recognized appearances (one folder-based gallery per
+// performer).
+//
+// Face gallery images are left out of batch recognition. Nothing is written
+// in anonymization mode.
+//
+// ============================================================================
+
+// faceGalleryFolderPattern matches a performer folder and captures its ID
+var faceGalleryFolderPattern = regexp.MustCompile(`^performer-(\d+)$`)
+
+// faceGalleryUnsafe matches the characters replaced in crop file names
+var faceGalleryUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// faceGalleryWrites tracks the crops written by the current task
+type faceGalleryWrites struct {
+	mu     sync.Mutex
+	counts map[graphql.ID]int // Crops per performer folder, read from disk on first use
+	dirs   map[string]bool    // Folders written to
+}
+
+// faceGalleryDir returns the face gallery directory, "" when disabled
+func (s *Service) faceGalleryDir() string {
+	dir := s.config.FaceGalleryDir
+	if dir == "" || s.config.AnonymizationMode {
+		return ""
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(s.serverConnection.PluginDir, dir)
+	}
+	return dir
+}
+
+// saveFaceCrop writes a face crop to its performer's folder of the face
+// gallery. Crops of performers whose folder is full, or already written by
+// an earlier run, are skipped. Failures are logged, never returned: the
+// gallery is a by-product of recognition.
+func (s *Service) saveFaceCrop(ctx FaceProcessingContext, faceID string, performerID graphql.ID, faceCrop []byte) {
+	root := s.faceGalleryDir()
+	if root == "" || performerID == "" || len(faceCrop) == 0 {
+		return
+	}
+
+	kind := "image"
+	if ctx.Scene != nil {
+		kind = "scene"
+	}
+	dir := filepath.Join(root, fmt.Sprintf("performer-%s", performerID))
+	name := fmt.Sprintf("%s-%s-%s.jpg", kind, faceGalleryUnsafe.ReplaceAllString(ctx.SourceID, "_"), faceGalleryUnsafe.ReplaceAllString(faceID, "_"))
+	path := filepath.Join(dir, name)
+
+	g := &s.faceGallery
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.counts == nil {
+		g.counts = map[graphql.ID]int{}
+		g.dirs = map[string]bool{}
+	}
+	count, ok := g.counts[performerID]
+	if !ok {
+		count = countFaceCrops(dir)
+	}
+	if count >= s.config.FaceGalleryMaxCrops {
+		g.counts[performerID] = count
+		return
+	}
+	if _, err := os.Stat(path); err == nil {
+		g.counts[performerID] = count
+		return
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Warnf("Failed to create face gallery folder: %v", err)
+		return
+	}
+	if err := os.WriteFile(path, faceCrop, 0o644); err != nil {
+		log.Warnf("Failed to write face crop: %v", err)
+		return
+	}
+	g.counts[performerID] = count + 1
+	g.dirs[dir] = true
+	log.Debugf("Saved face %s to the face gallery of performer %s", faceID, performerID)
+}
+
+// countFaceCrops counts the crops in a performer folder, 0 when missing
+func countFaceCrops(dir string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".jpg") {
+			count++
+		}
+	}
+	return count
+}
+
+// finishFaceGallery links the face gallery images Stash has scanned to their
+// performers, then asks Stash to scan the folders written by this task
+func (s *Service) finishFaceGallery() {
+	root := s.faceGalleryDir()
+	if root == "" {
+		return
+	}
+	s.linkFaceGalleryImages(root)
+
+	s.faceGallery.mu.Lock()
+	dirs := make([]string, 0, len(s.faceGallery.dirs))
+	for dir := range s.faceGallery.dirs {
+		dirs = append(dirs, dir)
+	}
+	s.faceGallery.mu.Unlock()
+	if len(dirs) == 0 {
+		return
+	}
+	sort.Strings(dirs)
+
+	s.flushWrites()
+	log.Infof("Saved face crops to %d performer folder(s) of the face gallery", len(dirs))
+	if err := stash.TriggerMetadataScan(s.graphqlClient, dirs); err != nil {
+		log.Warnf("Failed to scan the face gallery: %v", err)
+	}
+}
+
+// linkFaceGalleryImages sets the performer of face gallery images that have
+// none, from the folder they were written to
+func (s *Service) linkFaceGalleryImages(root string) {
+	filter := &stash.ImageFilterType{
+		Path:           faceGalleryPathCriterion(root),
+		PerformerCount: &stash.IntCriterionInput{Value: 0, Modifier: stash.CriterionModifierEquals},
+	}
+
+	// Collect first: linking removes images from the filter
+	links := map[graphql.ID]string{}
+	err := stash.FindAllImages(s.graphqlClient, filter, 0, func(images []stash.Image, count int) error {
+		for _, image := range images {
+			folder := filepath.Base(filepath.Dir(image.PrimaryPath()))
+			if match := faceGalleryFolderPattern.FindStringSubmatch(folder); match != nil {
+				links[image.ID] = match[1]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warnf("Failed to query face gallery images: %v", err)
+		return
+	}
+
+	linked := 0
+	for imageID, performerID := range links {
+		input := stash.ImageUpdateInput{ID: string(imageID), PerformerIds: []string{performerID}}
+		if err := stash.UpdateImage(s.graphqlClient, imageID, input); err != nil {
+			log.Warnf("Failed to link face gallery image %s to performer %s: %v", imageID, performerID, err)
+			continue
+		}
+		linked++
+	}
+	if linked > 0 {
+		log.Infof("Linked %d face gallery image(s) to their performers", linked)
+	}
+}
+
+// faceGalleryPathCriterion matches the paths under the face gallery
+func faceGalleryPathCriterion(root string) *stash.StringCriterionInput {
+	return &stash.StringCriterionInput{
+		Value:    "^" + regexp.QuoteMeta(filepath.Clean(root)+string(filepath.Separator)),
+		Modifier: stash.CriterionModifierMatchesRegex,
+	}
+}
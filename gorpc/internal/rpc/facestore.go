@@ -0,0 +1,108 @@
+package rpc
+
+import (
+	"path/filepath"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// faceStoreFile is the face store's path under the plugin directory
+const faceStoreFile = "data/faces.jsonl"
+
+// openFaceStore opens the local face store when enabled. Failures are logged
+// and leave the store disabled, so recognition falls back to Compreface.
+func (s *Service) openFaceStore() func() {
+	if !s.config.EmbeddingStore || s.config.AnonymizationMode {
+		return func() {}
+	}
+
+	path := filepath.Join(s.serverConnection.PluginDir, filepath.FromSlash(faceStoreFile))
+	faceStore, err := store.Open(path)
+	if err != nil {
+		log.Warnf("Face store disabled: %v", err)
+		return func() {}
+	}
+	log.Debugf("Face store %s: %d faces", path, faceStore.Len())
+
+	s.faceStore = faceStore
+	return func() {
+		s.faceStore = nil
+		if err := faceStore.Close(); err != nil {
+			log.Warnf("Failed to close face store: %v", err)
+		}
+	}
+}
+
+// faceSource identifies the image or scene a face belongs to in the store
+func faceSource(ctx FaceProcessingContext) string {
+	if ctx.Scene != nil {
+		return "scene:" + ctx.SourceID
+	}
+	return "image:" + ctx.SourceID
+}
+
+// recallFace returns the performer remembered for a face: the decision made
+// for this face of this source by an earlier run, or else the performer of
+// the most similar remembered embedding. Returns "" when nothing is known.
+func (s *Service) recallFace(ctx FaceProcessingContext, face vision.VisionFace) (graphql.ID, float64) {
+	if s.faceStore == nil || len(face.Embedding) == 0 {
+		return "", 0
+	}
+
+	known, ok := s.faceStore.Get(faceSource(ctx), store.FaceHash(face.Embedding))
+	similarity := known.Similarity
+	if !ok || known.PerformerID == "" {
+		known, similarity, ok = s.faceStore.Nearest(face.Embedding, s.config.MinSimilarity)
+		if !ok {
+			return "", 0
+		}
+	}
+
+	// Performers deleted from Stash are forgotten
+	performer, err := stash.GetPerformerByID(s.graphqlClient, graphql.ID(known.PerformerID))
+	if err != nil {
+		log.Debugf("Face %s: failed to verify remembered performer %s: %v", face.FaceID, known.PerformerID, err)
+		return "", 0
+	}
+	if performer.ID == "" {
+		s.forgetPerformer(known.PerformerID)
+		return "", 0
+	}
+
+	log.Infof("Face %s: Matched from face store (name: %s, similarity: %.2f)", face.FaceID, performer.Name, similarity)
+	s.summary.facesMatched.Add(1)
+	return performer.ID, similarity
+}
+
+// rememberFace records a face's match decision in the store
+func (s *Service) rememberFace(ctx FaceProcessingContext, face vision.VisionFace, performerID graphql.ID, similarity float64) {
+	if s.faceStore == nil || len(face.Embedding) == 0 || performerID == "" {
+		return
+	}
+
+	err := s.faceStore.Put(store.Face{
+		Source:      faceSource(ctx),
+		Hash:        store.FaceHash(face.Embedding),
+		Embedding:   face.Embedding,
+		PerformerID: string(performerID),
+		Similarity:  similarity,
+	})
+	if err != nil {
+		log.Warnf("Failed to remember face %s: %v", face.FaceID, err)
+	}
+}
+
+// forgetPerformer drops a performer's faces from the store
+func (s *Service) forgetPerformer(performerID string) {
+	if s.faceStore == nil {
+		return
+	}
+	if err := s.faceStore.ForgetPerformer(performerID); err != nil {
+		log.Warnf("Failed to forget performer %s in face store: %v", performerID, err)
+	}
+}
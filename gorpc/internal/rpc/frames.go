@@ -0,0 +1,102 @@
+package rpc
+
+import (
+	"errors"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// ============================================================================
+// Scene Frame Prefetching
+// ============================================================================
+//
+// Video scenes need one frame per face. When the frame server supports batch
+// extraction, the representative frames of a scene are fetched in one request
+// per enhancement setting instead of one request per face. Frames that were
+// not prefetched (alternate detections, extra subject examples) fall back to
+// single-frame extraction.
+//
+// ============================================================================
+
+// sceneFrames holds prefetched frames keyed by vision.FrameKey
+type sceneFrames struct {
+	plain    map[string][]byte
+	enhanced map[string][]byte
+}
+
+// get returns the prefetched frame at timestamp, if any
+func (f *sceneFrames) get(timestamp float64, enhanced bool) ([]byte, bool) {
+	if f == nil {
+		return nil, false
+	}
+	frames := f.plain
+	if enhanced {
+		frames = f.enhanced
+	}
+	frame, ok := frames[vision.FrameKey(timestamp)]
+	return frame, ok
+}
+
+// prefetchSceneFrames batch-extracts the representative frames of every
+// processable face in a video scene. Returns nil when batching does not apply
+// or is unsupported, in which case frames are extracted one at a time.
+func (s *Service) prefetchSceneFrames(visionClient *vision.VisionServiceClient, scene *stash.Scene, faces []vision.VisionFace, metadata vision.ResultMetadata) *sceneFrames {
+	if metadata.Method == "sprites" {
+		return nil
+	}
+	videoFile := stash.CanonicalVideoFile(scene)
+	if videoFile == nil {
+		return nil
+	}
+
+	// Group timestamps by whether the frame is extracted enhanced
+	var plain, enhanced []float64
+	seen := map[string]bool{}
+	for _, face := range faces {
+		det := face.RepresentativeDetection
+		if !s.assessFaceQuality(det.Quality, s.config.MinProcessingQualityScore).Acceptable {
+			continue
+		}
+		isEnhanced := det.Enhanced && metadata.FrameEnhancement != nil
+		key := vision.FrameKey(det.Timestamp)
+		if isEnhanced {
+			key = "e" + key
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if isEnhanced {
+			enhanced = append(enhanced, det.Timestamp)
+		} else {
+			plain = append(plain, det.Timestamp)
+		}
+	}
+
+	// A single frame gains nothing from batching
+	if len(plain)+len(enhanced) < 2 {
+		return nil
+	}
+
+	frames := &sceneFrames{}
+	var err error
+	if len(plain) > 0 {
+		frames.plain, err = visionClient.BatchExtractFrames(videoFile.Path, plain, nil)
+	}
+	if err == nil && len(enhanced) > 0 {
+		frames.enhanced, err = visionClient.BatchExtractFrames(videoFile.Path, enhanced, metadata.FrameEnhancement)
+	}
+
+	if errors.Is(err, vision.ErrBatchExtractionUnsupported) {
+		log.Debugf("Scene %s: %v, extracting frames individually", scene.ID, err)
+		return nil
+	}
+	if err != nil {
+		log.Warnf("Scene %s: batch frame extraction failed: %v", scene.ID, err)
+	}
+
+	log.Debugf("Scene %s: prefetched %d/%d frames", scene.ID, len(frames.plain)+len(frames.enhanced), len(plain)+len(enhanced))
+	return frames
+}
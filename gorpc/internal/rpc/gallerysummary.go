@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+)
+
+// ============================================================================
+// Gallery Recognition Summary
+// ============================================================================
+//
+// identifyGallery only logged success and failure counts, so what a run found
+// was lost once the task log rotated. The run's results are aggregated into a
+// GallerySummary, returned as the task output for the UI plugin, and written
+// as a delimited section of the gallery's details. Re-runs replace the section
+// and leave the rest of the details untouched.
+//
+// ============================================================================
+
+// gallerySummaryMarker delimits the summary section in gallery details
+const gallerySummaryMarker = "Compreface"
+
+// newGallerySummary starts the summary of a gallery run
+func newGallerySummary(galleryID string) *GallerySummary {
+	return &GallerySummary{
+		GalleryID:         galleryID,
+		PerformersMatched: []GalleryPerformer{},
+		Failures:          []GalleryImageFailure{},
+	}
+}
+
+// addImage records the identities found in an image
+func (g *GallerySummary) addImage(identities *[]FaceIdentity) {
+	g.ImagesProcessed++
+	if identities == nil {
+		return
+	}
+
+	g.FacesDetected += len(*identities)
+	seen := map[string]bool{}
+	for _, identity := range *identities {
+		if identity.Performer.ID == nil || *identity.Performer.ID == "" {
+			continue
+		}
+		id := *identity.Performer.ID
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		g.addPerformer(id, identity.Performer.Name)
+	}
+}
+
+// addPerformer counts an image a performer was matched in
+func (g *GallerySummary) addPerformer(id string, name string) {
+	for i := range g.PerformersMatched {
+		if g.PerformersMatched[i].ID == id {
+			g.PerformersMatched[i].Images++
+			return
+		}
+	}
+	g.PerformersMatched = append(g.PerformersMatched, GalleryPerformer{ID: id, Name: name, Images: 1})
+}
+
+// addFailure records an image that could not be identified
+func (g *GallerySummary) addFailure(imageID string, err error) {
+	g.Failures = append(g.Failures, GalleryImageFailure{ImageID: imageID, Error: err.Error()})
+}
+
+// finish orders the matched performers, most frequent first
+func (g *GallerySummary) finish() {
+	sort.SliceStable(g.PerformersMatched, func(i, j int) bool {
+		return g.PerformersMatched[i].Images > g.PerformersMatched[j].Images
+	})
+}
+
+// detailsSection formats the summary for the gallery's details
+func (g *GallerySummary) detailsSection(now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Face recognition (%s)\n", now.Format("2006-01-02"))
+	fmt.Fprintf(&b, "Images: %d processed, %d failed\n", g.ImagesProcessed, len(g.Failures))
+	if g.ImagesSkipped > 0 {
+		fmt.Fprintf(&b, "Identified by earlier runs: %d\n", g.ImagesSkipped)
+	}
+	if g.Complete {
+		b.WriteString("Status: complete\n")
+	} else {
+		fmt.Fprintf(&b, "Status: %d image(s) left\n", g.ImagesRemaining+len(g.Failures))
+	}
+	fmt.Fprintf(&b, "Faces detected: %d\n", g.FacesDetected)
+
+	if len(g.PerformersMatched) > 0 {
+		names := make([]string, len(g.PerformersMatched))
+		for i, performer := range g.PerformersMatched {
+			names[i] = fmt.Sprintf("%s (%d)", performer.Name, performer.Images)
+		}
+		fmt.Fprintf(&b, "Performers: %s\n", strings.Join(names, ", "))
+	}
+	if g.PerformersCreated > 0 {
+		fmt.Fprintf(&b, "New performers: %d\n", g.PerformersCreated)
+	}
+	if len(g.Failures) > 0 {
+		ids := make([]string, len(g.Failures))
+		for i, failure := range g.Failures {
+			ids[i] = failure.ImageID
+		}
+		fmt.Fprintf(&b, "Failed images: %s\n", strings.Join(ids, ", "))
+	}
+	return b.String()
+}
+
+// writeGallerySummary writes the summary section into the gallery's details
+func (s *Service) writeGallerySummary(gallery *stash.Gallery, summary *GallerySummary) error {
+	details := stash.ReplaceDetailsSection(gallery.Details, gallerySummaryMarker, summary.detailsSection(time.Now()))
+	if details == gallery.Details {
+		return nil
+	}
+	return stash.UpdateGalleryDetails(s.graphqlClient, gallery.ID, details)
+}
@@ -0,0 +1,110 @@
+package rpc
+
+import (
+	"path/filepath"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Incremental Gallery Sync
+// ============================================================================
+//
+// identifyGallery records every image it identifies, per gallery, so the
+// next run of the same gallery only processes the images added since (the
+// full argument reprocesses every image). Failed images are not recorded and
+// are retried. Images identified by earlier runs still count towards the
+// consistency review and title through the performers Stash has on them.
+//
+// A gallery is tagged Complete once every image has been identified without
+// failures, and the tag is removed when a later run finds it incomplete.
+//
+// ============================================================================
+
+// processedGalleriesFile is the processed gallery image file's path under
+// the plugin directory
+const processedGalleriesFile = "data/galleries.jsonl"
+
+// openProcessedGalleries opens the processed gallery images. Failures are
+// logged and leave gallery runs processing every image.
+func (s *Service) openProcessedGalleries() func() {
+	path := filepath.Join(s.serverConnection.PluginDir, filepath.FromSlash(processedGalleriesFile))
+	galleries, err := store.OpenProcessedGalleries(path)
+	if err != nil {
+		log.Warnf("Incremental gallery sync disabled: %v", err)
+		return func() {}
+	}
+
+	s.processedGalleries = galleries
+	return func() {
+		s.processedGalleries = nil
+		if err := galleries.Close(); err != nil {
+			log.Warnf("Failed to close processed galleries: %v", err)
+		}
+	}
+}
+
+// splitGalleryImages separates a gallery's images into those still to be
+// identified and those identified by earlier runs. With full set, every
+// image is pending and the gallery's record is reset.
+func (s *Service) splitGalleryImages(galleryID string, images []stash.Image, full bool) (pending []stash.Image, processed []stash.Image) {
+	if s.processedGalleries == nil {
+		return images, nil
+	}
+	if full {
+		if err := s.processedGalleries.Reset(galleryID); err != nil {
+			log.Warnf("Failed to reset processed images of gallery %s: %v", galleryID, err)
+		}
+		return images, nil
+	}
+
+	for _, image := range images {
+		if s.processedGalleries.Processed(galleryID, string(image.ID)) {
+			processed = append(processed, image)
+		} else {
+			pending = append(pending, image)
+		}
+	}
+	return pending, processed
+}
+
+// markGalleryImage records a gallery image as identified
+func (s *Service) markGalleryImage(galleryID string, imageID graphql.ID) {
+	if s.processedGalleries == nil {
+		return
+	}
+	if err := s.processedGalleries.Add(galleryID, []string{string(imageID)}); err != nil {
+		log.Warnf("Failed to record image %s of gallery %s: %v", imageID, galleryID, err)
+	}
+}
+
+// existingGalleryMatches returns the performers Stash has on images
+// identified by earlier runs
+func existingGalleryMatches(images []stash.Image) []galleryImageMatches {
+	matches := make([]galleryImageMatches, 0, len(images))
+	for _, image := range images {
+		performers := make([]graphql.ID, len(image.Performers))
+		for i, performer := range image.Performers {
+			performers[i] = performer.ID
+		}
+		matches = append(matches, galleryImageMatches{image: image, performers: performers})
+	}
+	return matches
+}
+
+// updateGalleryCompletionStatus tags the gallery Complete when the summary
+// leaves no image to identify, and removes the tag otherwise
+func (s *Service) updateGalleryCompletionStatus(gallery *stash.Gallery, summary *GallerySummary) error {
+	completeTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.CompleteTagName, "Compreface Complete")
+	if err != nil {
+		return err
+	}
+	if summary.Complete {
+		return stash.UpdateGalleryTagsIfChanged(s.graphqlClient, gallery, []graphql.ID{completeTagID}, nil)
+	}
+	return stash.UpdateGalleryTagsIfChanged(s.graphqlClient, gallery, nil, []graphql.ID{completeTagID})
+}
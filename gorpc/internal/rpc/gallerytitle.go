@@ -0,0 +1,156 @@
+package rpc
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/config"
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Gallery Title Suggestions
+// ============================================================================
+//
+// Unnamed imports are hard to organize. After a gallery is identified, a title
+// such as "Jane D. & John S. – {original title}" is built from the gallery's
+// dominant performers and either logged as a suggestion or applied, depending
+// on GalleryTitleMode. Performers still carrying a generated "Person ..." name
+// are left out.
+//
+// ============================================================================
+
+const (
+	// maxTitlePerformers is the number of performers named in a gallery title
+	maxTitlePerformers = 3
+	// titleSeparator separates the performer names from the original title
+	titleSeparator = " – "
+)
+
+// applyGalleryTitle suggests or sets a gallery title from its dominant performers
+func (s *Service) applyGalleryTitle(gallery *stash.Gallery, matches []galleryImageMatches) error {
+	mode := s.config.GalleryTitleMode
+	if mode != config.GalleryTitleSuggest && mode != config.GalleryTitleSet {
+		return nil
+	}
+
+	names := s.dominantPerformerNames(matches)
+	if len(names) == 0 {
+		log.Debugf("Gallery '%s': no named dominant performers, no title to suggest", gallery.Title)
+		return nil
+	}
+
+	prefix := joinPerformerNames(names)
+	original := galleryDisplayTitle(gallery)
+	if strings.HasPrefix(original, prefix) {
+		log.Debugf("Gallery '%s' already titled with its performers", original)
+		return nil
+	}
+
+	title := prefix
+	if original != "" {
+		title = prefix + titleSeparator + original
+	}
+
+	if mode == config.GalleryTitleSuggest {
+		log.Infof("Gallery %s: suggested title %q", gallery.ID, title)
+		return nil
+	}
+
+	if err := stash.UpdateGalleryTitle(s.graphqlClient, gallery.ID, title); err != nil {
+		return err
+	}
+	log.Infof("Gallery %s: renamed %q to %q", gallery.ID, gallery.Title, title)
+	return nil
+}
+
+// dominantPerformerNames returns the abbreviated names of the gallery's
+// dominant performers, most frequently matched first
+func (s *Service) dominantPerformerNames(matches []galleryImageMatches) []string {
+	dominant := dominantPerformers(matches)
+
+	counts := map[graphql.ID]int{}
+	for _, m := range matches {
+		for _, id := range m.performers {
+			if dominant[id] {
+				counts[id]++
+			}
+		}
+	}
+
+	type performerName struct {
+		name  string
+		count int
+	}
+	var performers []performerName
+	for id, count := range counts {
+		performer, err := stash.GetPerformerByID(s.graphqlClient, id)
+		if err != nil || performer == nil {
+			log.Debugf("Failed to get performer %s for gallery title: %v", id, err)
+			continue
+		}
+		if compreface.IsGeneratedSubjectName(performer.Name) {
+			continue
+		}
+		performers = append(performers, performerName{name: abbreviateName(performer.Name), count: count})
+	}
+
+	sort.Slice(performers, func(i, j int) bool {
+		if performers[i].count != performers[j].count {
+			return performers[i].count > performers[j].count
+		}
+		return performers[i].name < performers[j].name
+	})
+
+	names := make([]string, 0, len(performers))
+	for i, p := range performers {
+		if i == maxTitlePerformers {
+			break
+		}
+		names = append(names, p.name)
+	}
+	return names
+}
+
+// abbreviateName shortens a full name to first name and last initial
+// ("Jane Doe" -> "Jane D."); single names are kept as-is
+func abbreviateName(name string) string {
+	words := strings.Fields(name)
+	if len(words) < 2 {
+		return strings.TrimSpace(name)
+	}
+	last, _ := utf8.DecodeRuneInString(words[len(words)-1])
+	return fmt.Sprintf("%s %c.", words[0], unicode.ToUpper(last))
+}
+
+// joinPerformerNames joins names as "A", "A & B" or "A, B & C"
+func joinPerformerNames(names []string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " & " + names[len(names)-1]
+}
+
+// galleryDisplayTitle returns the gallery title, or the folder or file name
+// Stash displays for untitled galleries
+func galleryDisplayTitle(gallery *stash.Gallery) string {
+	if gallery.Title != "" {
+		return gallery.Title
+	}
+	if gallery.Folder != nil && gallery.Folder.Path != "" {
+		return filepath.Base(gallery.Folder.Path)
+	}
+	if len(gallery.Files) > 0 {
+		base := filepath.Base(gallery.Files[0].Path)
+		return strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	return ""
+}
@@ -3,18 +3,24 @@ package rpc
 import (
 	"encoding/json"
 	"fmt"
-	"strconv"
+	"os"
+	"time"
 
 	"github.com/stashapp/stash/pkg/plugin/common"
-	"github.com/stashapp/stash/pkg/plugin/common/log"
 
 	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
 	"github.com/smegmarip/stash-compreface-plugin/internal/config"
+	"github.com/smegmarip/stash-compreface-plugin/internal/runlock"
 	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/throttle"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
 )
 
 // Run handles RPC task execution
 func (s *Service) Run(input common.PluginInput, output *common.PluginOutput) error {
+	started := time.Now()
+
 	// Initialize GraphQL client and tag cache
 	s.serverConnection = input.ServerConnection
 	s.graphqlClient = stash.Client(input.ServerConnection)
@@ -26,6 +32,37 @@ func (s *Service) Run(input common.PluginInput, output *common.PluginOutput) err
 		return s.errorOutput(output, fmt.Errorf("failed to load config: %w", err))
 	}
 	s.config = cfg
+	s.tagCache.SetParentTag(cfg.ParentTagName)
+	s.lowerPriority()
+
+	// Keep API keys and tokens out of logs and task output
+	for _, secret := range []string{cfg.RecognitionAPIKey, cfg.DetectionAPIKey, cfg.VerificationAPIKey, cfg.VisionServiceToken, cfg.WebhookURL} {
+		log.RegisterSecret(secret)
+	}
+
+	// Export pipeline stage spans to an OpenTelemetry collector
+	if cfg.OTLPEndpoint != "" {
+		trace.EnableExport(cfg.OTLPEndpoint)
+		defer func() {
+			if err := trace.StopExport(); err != nil {
+				log.Warnf("Some spans were not exported: %v", err)
+			}
+		}()
+	}
+
+	// Swap external services for in-process fakes in test mode
+	if cfg.TestMode {
+		stopFakes := s.startFakeServices()
+		defer stopFakes()
+	}
+
+	// Make created subject names reproducible: seeded, or numbered in test mode
+	switch {
+	case cfg.RandomSeed != 0:
+		compreface.SetNameGenerator(compreface.NewRandomNameGenerator(int64(cfg.RandomSeed)))
+	case cfg.TestMode:
+		compreface.SetNameGenerator(compreface.NewSequentialNameGenerator())
+	}
 
 	// Initialize Compreface client
 	s.comprefaceClient = compreface.NewClient(
@@ -35,167 +72,379 @@ func (s *Service) Run(input common.PluginInput, output *common.PluginOutput) err
 		cfg.VerificationAPIKey,
 		cfg.MinSimilarity,
 	)
+	s.comprefaceClient.SetRateLimit(cfg.ComprefaceRequestsPerSecond)
+	s.comprefaceClient.SetContext(s.ctx)
+	if cfg.MatchMargin > 0 {
+		s.comprefaceClient.PredictionCount = matchCandidates
+	}
+	if cfg.ComprefaceFacePlugins != nil {
+		s.comprefaceClient.FacePlugins = cfg.ComprefaceFacePlugins
+	}
+	if cfg.SkipDemographics {
+		s.comprefaceClient.FacePlugins = compreface.WithoutDemographics(s.comprefaceClient.FacePlugins)
+	}
+	s.calibrateSimilarity()
 
-	log.Infof("Compreface plugin started - mode: %s", input.Args.String("mode"))
-	log.Debugf("Configuration: URL=%s, BatchSize=%d, Cooldown=%ds",
-		cfg.ComprefaceURL, cfg.MaxBatchSize, cfg.CooldownSeconds)
-
-	mode := input.Args.String("mode")
-
-	// Parse limit parameter (Stash sends integers as float64 in JSON)
-	limit := 0
-	argsMap := input.Args.ToMap()
-	if limitVal, ok := argsMap["limit"]; ok {
-		switch v := limitVal.(type) {
-		case float64:
-			limit = int(v)
-		case int:
-			limit = v
-		case string:
-			// Try parsing string as int
-			if val, err := strconv.Atoi(v); err == nil {
-				limit = val
-			}
-		}
+	// Throttle each service independently
+	s.visionJobSlots = throttle.NewSemaphore(cfg.VisionMaxConcurrentJobs)
+	s.stashWriteLimiter = throttle.NewRateLimiter(cfg.StashWritesPerSecond)
+
+	// Build the feature areas from the task's clients
+	s.buildComponents()
+
+	// Validate task arguments against the mode's schema
+	mode, args, err := parseTaskArgs(input.Args.ToMap())
+	if err != nil {
+		return s.errorOutput(output, err)
+	}
+	limit := args.Int("limit")
+	createNewSubjects := args.Bool("createNewSubjects")
+	s.resume = args.Bool("resume")
+	s.scope = contentScope{
+		studioID:      args.String("studioId"),
+		tagID:         args.String("tagId"),
+		createdAfter:  args.String("createdAfter"),
+		createdBefore: args.String("createdBefore"),
+		pathPrefix:    args.String("pathPrefix"),
 	}
+	s.overrideThresholds(args)
+
+	log.Infof("Compreface plugin started - mode: %s", mode)
+	log.Debugf("Configuration: URL=%s, BatchSize=%d, Compreface=%.1f req/s, Vision jobs=%d, Stash writes=%.1f/s",
+		cfg.ComprefaceURL, cfg.MaxBatchSize, cfg.ComprefaceRequestsPerSecond, cfg.VisionMaxConcurrentJobs, cfg.StashWritesPerSecond)
 	log.Debugf("Mode: %s, Limit: %d", mode, limit)
+	if scope := s.scope.String(); scope != "" {
+		log.Infof("Limiting %s to %s", mode, scope)
+	}
+
+	if cfg.AnonymizationMode && storesBiometricData(mode) {
+		return s.errorOutput(output, fmt.Errorf("mode %s stores face data and is disabled in anonymization mode", mode))
+	}
+
+	// Refuse to overlap another run of the same batch mode
+	if isBatchMode(mode) {
+		lock, err := runlock.Acquire(os.TempDir(), "compreface-rpc-"+mode)
+		if err != nil {
+			return s.errorOutput(output, fmt.Errorf("%w; wait for it to finish or stop it before starting another", err))
+		}
+		defer lock.Release()
+		s.startRunReport()
+	}
+
+	closeFaceStore := s.openFaceStore()
+	defer closeFaceStore()
+	closeJobProfiles := s.openJobProfiles()
+	defer closeJobProfiles()
+	closeCheckpoints := s.openCheckpoints()
+	defer closeCheckpoints()
+	closeFailures := s.openFailures()
+	defer closeFailures()
+	closeReviewQueue := s.openReviewQueue()
+	defer closeReviewQueue()
+	closeClusters := s.openClusters()
+	defer closeClusters()
+	closeProcessedGalleries := s.openProcessedGalleries()
+	defer closeProcessedGalleries()
 
-	var outputStr string = "Unknown mode"
+	var outputStr string
+	var response interface{} // Mode-specific response, nested in the task result
 
+	// parseTaskArgs only accepts modes with a schema, each handled below
 	switch mode {
 	case "synchronizePerformers":
 		log.Infof("Starting performer synchronization (limit=%d)", limit)
-		err = s.synchronizePerformers(limit)
+		err = s.components.performers.SynchronizePerformers(limit)
 		outputStr = "Performer synchronization completed"
 
 	case "recognizeImages":
-		log.Infof("Starting image recognition (limit=%d)", limit)
-		err = s.recognizeImages(limit)
+		log.Infof("Starting image recognition (limit=%d, createNewSubjects=%v)", limit, createNewSubjects)
+		err = s.components.images.RecognizeImages(limit, createNewSubjects)
 		outputStr = "Image recognition completed"
 
 	case "identifyImagesAll":
 		log.Infof("Starting image identification (all, limit=%d)", limit)
-		err = s.identifyImages(false, limit) // newOnly=false
+		err = s.components.images.IdentifyImages(false, limit) // newOnly=false
 		outputStr = "Image identification completed"
 
 	case "identifyImagesNew":
 		log.Infof("Starting image identification (new only, limit=%d)", limit)
-		err = s.identifyImages(true, limit) // newOnly=true
+		err = s.components.images.IdentifyImages(true, limit) // newOnly=true
 		outputStr = "New image identification completed"
 
 	case "resetUnmatchedImages":
 		log.Infof("Resetting unmatched images (limit=%d)", limit)
-		err = s.resetUnmatchedImages(limit)
+		err = s.components.images.ResetUnmatchedImages(limit)
 		outputStr = "Unmatched images reset"
 
 	case "recognizeNewScenes":
-		log.Infof("Starting scene recognition (limit=%d)", limit)
-		err = s.recognizeScenes(false, false, limit) // useSprites=false scanPartial=false
+		log.Infof("Starting scene recognition (new, limit=%d, createNewSubjects=%v)", limit, createNewSubjects)
+		err = s.components.scenes.RecognizeScenes(false, SceneScopeNew, limit, createNewSubjects)
+		outputStr = "Scene recognition completed"
+
+	case "recognizePartialScenes":
+		log.Infof("Starting scene recognition (partial, limit=%d, createNewSubjects=%v)", limit, createNewSubjects)
+		err = s.components.scenes.RecognizeScenes(false, SceneScopePartial, limit, createNewSubjects)
 		outputStr = "Scene recognition completed"
 
 	case "recognizeAllScenes":
-		log.Infof("Starting scene recognition (limit=%d)", limit)
-		err = s.recognizeScenes(false, true, limit) // useSprites=false scanPartial=true
+		log.Infof("Starting scene recognition (all, limit=%d, createNewSubjects=%v)", limit, createNewSubjects)
+		err = s.components.scenes.RecognizeScenes(false, SceneScopeAll, limit, createNewSubjects)
 		outputStr = "Scene recognition completed"
 
 	case "recognizeNewSceneSprites":
-		log.Infof("Starting scene sprite recognition (limit=%d)", limit)
-		err = s.recognizeScenes(true, false, limit) // useSprites=true scanPartial=false
+		log.Infof("Starting scene sprite recognition (new, limit=%d, createNewSubjects=%v)", limit, createNewSubjects)
+		err = s.components.scenes.RecognizeScenes(true, SceneScopeNew, limit, createNewSubjects)
+		outputStr = "Scene sprite recognition completed"
+
+	case "recognizePartialSceneSprites":
+		log.Infof("Starting scene sprite recognition (partial, limit=%d, createNewSubjects=%v)", limit, createNewSubjects)
+		err = s.components.scenes.RecognizeScenes(true, SceneScopePartial, limit, createNewSubjects)
 		outputStr = "Scene sprite recognition completed"
 
 	case "recognizeAllSceneSprites":
-		log.Infof("Starting scene sprite recognition (limit=%d)", limit)
-		err = s.recognizeScenes(true, true, limit) // useSprites=true scanPartial=true
+		log.Infof("Starting scene sprite recognition (all, limit=%d, createNewSubjects=%v)", limit, createNewSubjects)
+		err = s.components.scenes.RecognizeScenes(true, SceneScopeAll, limit, createNewSubjects)
 		outputStr = "Scene sprite recognition completed"
 
 	case "identifyImage":
-		// Parse imageId (Stash sends integers as float64 in JSON)
-		imageID := ""
-		if imageVal, ok := argsMap["imageId"]; ok {
-			switch v := imageVal.(type) {
-			case float64:
-				imageID = fmt.Sprintf("%.0f", v)
-			case int:
-				imageID = fmt.Sprintf("%d", v)
-			case string:
-				imageID = v
-			}
-		}
 		var _res *[]FaceIdentity
-		createPerformer := input.Args.Bool("createPerformer")
-		associateExisting := input.Args.Bool("associateExisting")
+		imageID := args.String("imageId")
+		createPerformer := args.Bool("createPerformer")
+		associateExisting := args.Bool("associateExisting")
 		log.Infof("Identifying image: %s (createPerformer=%v associateExisting=%v)", imageID, createPerformer, associateExisting)
-		_res, err = s.identifyImage(imageID, createPerformer, associateExisting, nil)
-		response := IdentifyImageResponse{Result: _res}
-		res, _err := json.Marshal(response)
+		_res, err = s.components.images.IdentifyImage(imageID, createPerformer, associateExisting, nil)
+		identifyResponse := IdentifyImageResponse{Result: _res}
+		res, _err := json.Marshal(identifyResponse)
 		if _err == nil {
 			log.Infof("identifyImage=%s", string(res))
 		}
+		response = identifyResponse
 		outputStr = "Image identification completed"
 
 	case "createPerformerFromImage":
-		// Parse imageId (Stash sends integers as float64 in JSON)
-		imageID := ""
-		if imageVal, ok := argsMap["imageId"]; ok {
-			switch v := imageVal.(type) {
-			case float64:
-				imageID = fmt.Sprintf("%.0f", v)
-			case int:
-				imageID = fmt.Sprintf("%d", v)
-			case string:
-				imageID = v
-			}
-		}
-		faceIndex := 0
-		if indexVal, ok := argsMap["faceIndex"]; ok {
-			switch v := indexVal.(type) {
-			case float64:
-				faceIndex = int(v)
-			case int:
-				faceIndex = v
-			case string:
-				faceIndex, _ = strconv.Atoi(v)
-			}
-		}
+		imageID := args.String("imageId")
+		faceIndex := args.Int("faceIndex")
 		log.Infof("Creating performer from image: %s (faceIndex=%d)", imageID, faceIndex)
 		// When creating a performer, always associate with the image
-		_, err = s.identifyImage(imageID, true, true, &faceIndex)
+		_, err = s.components.images.IdentifyImage(imageID, true, true, &faceIndex)
 		outputStr = "Performer created from image"
 
-	case "identifyGallery":
-		// Parse galleryId (Stash sends integers as float64 in JSON)
-		galleryID := ""
-		if galleryVal, ok := argsMap["galleryId"]; ok {
-			switch v := galleryVal.(type) {
-			case float64:
-				galleryID = fmt.Sprintf("%.0f", v)
-			case int:
-				galleryID = fmt.Sprintf("%d", v)
-			case string:
-				galleryID = v
+	case "createPerformerFromScene":
+		sceneID := args.String("sceneId")
+		timestamp := args.Float("timestamp")
+		faceIndex := args.Int("faceIndex")
+		log.Infof("Creating performer from scene: %s at %.2fs (faceIndex=%d)", sceneID, timestamp, faceIndex)
+		outputStr, err = s.components.scenes.CreatePerformerFromScene(sceneID, timestamp, faceIndex)
+
+	case "identifyScene":
+		var result *SceneIdentification
+		sceneID := args.String("sceneId")
+		createPerformer := args.Bool("createPerformer")
+		associateExisting := args.Bool("associateExisting")
+		useSprites := args.Bool("useSprites")
+		log.Infof("Identifying scene: %s (createPerformer=%v associateExisting=%v useSprites=%v)", sceneID, createPerformer, associateExisting, useSprites)
+		result, err = s.components.scenes.IdentifyScene(sceneID, createPerformer, associateExisting, useSprites)
+		outputStr = "Scene identification completed"
+		if err == nil {
+			res, _err := json.Marshal(result)
+			if _err == nil {
+				log.Infof("identifyScene=%s", string(res))
 			}
+			response = result
 		}
-		createPerformer := input.Args.Bool("createPerformer")
-		log.Infof("Identifying gallery: %s (createPerformer=%v, limit=%d)", galleryID, createPerformer, limit)
-		err = s.identifyGallery(galleryID, createPerformer, limit)
+
+	case "identifyGallery":
+		galleryID := args.String("galleryId")
+		createPerformer := args.Bool("createPerformer")
+		full := args.Bool("full")
+		log.Infof("Identifying gallery: %s (createPerformer=%v, limit=%d, full=%v)", galleryID, createPerformer, limit, full)
+		var summary *GallerySummary
+		summary, err = s.components.images.IdentifyGallery(galleryID, createPerformer, limit, full)
 		outputStr = "Gallery identification completed"
+		if err == nil {
+			res, _err := json.Marshal(summary)
+			if _err == nil {
+				log.Infof("identifyGallery=%s", string(res))
+			}
+			response = summary
+		}
+
+	case "verifyPerformerImage":
+		imageID := args.String("imageId")
+		performerID := args.String("performerId")
+		associate := args.Bool("associate")
+		log.Infof("Verifying image %s against performer %s (associate=%v)", imageID, performerID, associate)
+		var result *PerformerImageVerification
+		result, err = s.components.images.VerifyPerformerImage(imageID, performerID, associate)
+		outputStr = "Performer image verification completed"
+		if err == nil {
+			res, _err := json.Marshal(result)
+			if _err == nil {
+				log.Infof("verifyPerformerImage=%s", string(res))
+			}
+			response = result
+		}
+
+	case "deleteSubjectForPerformer":
+		performerID := args.String("performerId")
+		deletePerformer := args.Bool("deletePerformer")
+		log.Infof("Deleting subject for performer: %s (deletePerformer=%v)", performerID, deletePerformer)
+		err = s.components.performers.DeleteSubjectForPerformer(performerID, deletePerformer)
+		outputStr = "Performer subject deleted"
+
+	case "status":
+		outputStr, err = s.components.status.Status()
+
+	case "benchmark":
+		var result *BenchmarkResult
+		path := args.String("path")
+		iterations := args.Int("iterations")
+		log.Infof("Starting benchmark (path=%s, iterations=%d, limit=%d)", path, iterations, limit)
+		if result, err = s.benchmark(path, iterations, limit); err == nil {
+			response = result
+			outputStr = result.String()
+		}
+
+	case "capabilities":
+		var caps *Capabilities
+		if caps, err = s.capabilities(); err == nil {
+			response = caps
+			outputStr = "Plugin capabilities reported"
+		}
+
+	case "fullPipeline":
+		log.Infof("Starting full pipeline (limit=%d, createNewSubjects=%v)", limit, createNewSubjects)
+		outputStr, err = s.fullPipeline(limit, createNewSubjects)
 
 	case "resetUnmatchedScenes":
 		log.Infof("Resetting unmatched scenes (limit=%d)", limit)
-		err = s.resetUnmatchedScenes(limit)
+		err = s.components.scenes.ResetUnmatchedScenes(limit)
 		outputStr = "Unmatched scenes reset"
 
-	default:
-		err = fmt.Errorf("unknown mode: %s", mode)
+	case "dedupeAliases":
+		err = s.components.performers.DedupeAliases(limit)
+		outputStr = "Performer aliases deduplicated"
+
+	case "reportTagDrift":
+		outputStr, err = s.reportTagDrift(limit)
+
+	case "repairSubjectLinks":
+		log.Infof("Repairing subject links (limit=%d)", limit)
+		outputStr, err = s.components.performers.RepairSubjectLinks(limit)
+
+	case "cleanupOrphanSubjects":
+		dryRun := args.Bool("dryRun")
+		log.Infof("Cleaning up orphan subjects (dryRun=%v, limit=%d)", dryRun, limit)
+		outputStr, err = s.cleanupOrphanSubjects(dryRun, limit)
+
+	case "pruneAutoPerformers":
+		days := args.Int("days")
+		dryRun := args.Bool("dryRun")
+		log.Infof("Pruning auto-created performers (days=%d, dryRun=%v, limit=%d)", days, dryRun, limit)
+		outputStr, err = s.pruneAutoPerformers(days, dryRun, limit)
+
+	case "mergeDuplicatePerformers":
+		log.Infof("Merging duplicate performers (limit=%d)", limit)
+		outputStr, err = s.components.performers.MergeDuplicatePerformers(limit)
+
+	case "trainPerformerFaces":
+		performerID := args.String("performerId")
+		examples := args.Int("examples")
+		log.Infof("Training performer faces (performerId=%q, examples=%d, limit=%d)", performerID, examples, limit)
+		outputStr, err = s.trainPerformerFaces(performerID, examples, limit)
+
+	case "listPendingFaces":
+		var pending *PendingFacesResponse
+		pending, err = s.listPendingFaces(limit)
+		outputStr = "Pending faces listed"
+		if err == nil {
+			response = pending
+		}
+
+	case "approvePendingFace":
+		faceID := args.String("faceId")
+		performerID := args.String("performerId")
+		reject := args.Bool("reject")
+		log.Infof("Reviewing pending face %s (performerId=%q, reject=%v)", faceID, performerID, reject)
+		outputStr, err = s.approvePendingFace(faceID, performerID, reject)
+
+	case "listUnmatchedClusters":
+		var clusters *UnmatchedClustersResponse
+		sceneID := args.String("sceneId")
+		clusters, err = s.listUnmatchedClusters(sceneID, limit)
+		outputStr = "Unmatched clusters listed"
+		if err == nil {
+			response = clusters
+		}
+
+	case "assignCluster":
+		clusterID := args.String("clusterId")
+		performerID := args.String("performerId")
+		log.Infof("Assigning cluster %s to performer %s", clusterID, performerID)
+		outputStr, err = s.assignCluster(clusterID, performerID)
+
+	case "restoreBackup":
+		backup := args.String("backup")
+		log.Infof("Restoring backup %q", backup)
+		outputStr, err = s.restoreBackup(backup)
+
+	case "exportSubjectMappings":
+		path := args.String("path")
+		includeExamples := args.Bool("includeExamples")
+		log.Infof("Exporting subject mappings (path=%q, includeExamples=%v)", path, includeExamples)
+		outputStr, err = s.exportSubjectMappings(path, includeExamples)
+
+	case "importSubjectMappings":
+		path := args.String("path")
+		log.Infof("Importing subject mappings from %q", path)
+		outputStr, err = s.importSubjectMappings(path)
+
+	case "importDoubleTake":
+		createPerformer := args.Bool("createPerformer")
+		log.Infof("Importing double-take matches (limit=%d, createPerformer=%v)", limit, createPerformer)
+		outputStr, err = s.importDoubleTake(limit, createPerformer)
 	}
 
-	if err != nil {
-		return s.errorOutput(output, err)
+	if isBatchMode(mode) {
+		s.finishFaceGallery()
 	}
 
-	*output = common.PluginOutput{
-		Output: &outputStr,
+	// Surface what the task did, even when it failed part-way
+	if summary := s.summary.String(); summary != "" {
+		log.Info(summary)
 	}
 
-	return nil
+	if err != nil {
+		outputStr = s.withSummary(fmt.Sprintf("%s failed: %s", mode, log.Scrub(err.Error())))
+	} else if mode != "status" {
+		outputStr = s.withSummary(outputStr)
+	}
+	result := s.taskResult(mode, outputStr, response, started, err)
+	if isBatchMode(mode) {
+		s.writeRunReport(result, started)
+		s.notifyWebhook(result)
+	}
+	return s.resultOutput(output, result)
+}
+
+// isBatchMode reports whether a task mode walks the library in batches, and
+// so must not run twice at once
+func isBatchMode(mode string) bool {
+	switch mode {
+	case "identifyImage",
+		"createPerformerFromImage",
+		"createPerformerFromScene",
+		"identifyScene",
+		"identifyGallery",
+		"verifyPerformerImage",
+		"deleteSubjectForPerformer",
+		"listPendingFaces",
+		"approvePendingFace",
+		"listUnmatchedClusters",
+		"assignCluster",
+		"status",
+		"capabilities":
+		return false
+	}
+	return true
 }
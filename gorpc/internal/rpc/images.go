@@ -3,23 +3,33 @@ package rpc
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"image"
 	_ "image/gif" // Register GIF format
 	"image/jpeg"
 	_ "image/png" // Register PNG format
+	"io/fs"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 
 	_ "golang.org/x/image/bmp"  // Register BMP format
 	_ "golang.org/x/image/webp" // Register WEBP format
 
 	graphql "github.com/hasura/go-graphql-client"
-	"github.com/stashapp/stash/pkg/plugin/common/log"
 
 	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/config"
 	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/throttle"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
 	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
 	"github.com/smegmarip/stash-compreface-plugin/pkg/utils"
 )
@@ -28,9 +38,11 @@ import (
 // Image Business Logic (Service Layer)
 // ============================================================================
 
-// recognizeImages performs batch face recognition on images using Vision Service
-func (s *Service) recognizeImages(limit int) error {
-	if s.stopping {
+// recognizeImages performs batch face recognition on images using Vision Service.
+// When createNewSubjects is false, unmatched faces are skipped instead of
+// creating new subjects and performers.
+func (s *Service) recognizeImages(limit int, createNewSubjects bool) error {
+	if s.stopped() {
 		return fmt.Errorf("operation cancelled")
 	}
 
@@ -40,7 +52,7 @@ func (s *Service) recognizeImages(limit int) error {
 	}
 
 	// Initialize Vision Service client
-	visionClient := vision.NewVisionServiceClient(s.config.VisionServiceURL, s.config.FrameServerURL)
+	visionClient := s.newVisionClient()
 
 	// Health check
 	if err := visionClient.HealthCheck(); err != nil {
@@ -50,6 +62,9 @@ func (s *Service) recognizeImages(limit int) error {
 
 	log.Infof("Starting batch image recognition")
 
+	// Probe frame server capabilities once, before workers share the result
+	s.enhancementAvailable()
+
 	// Get scanned tag ID for filtering
 	scannedTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.ScannedTagName, "Compreface Scanned")
 	if err != nil {
@@ -63,110 +78,304 @@ func (s *Service) recognizeImages(limit int) error {
 	}
 
 	batchSize := s.config.MaxBatchSize
-	page := 0
-	total := 0
 	processedCount := 0
 	successCount := 0
 	failureCount := 0
+	var missingFiles []*MissingFileError
 
-	for {
-		if s.stopping {
-			return fmt.Errorf("operation cancelled")
-		}
+	// Fetch unscanned images (excluding scanned AND complete)
+	imageFilter := func(performerCount *stash.IntCriterionInput) *stash.ImageFilterType {
+		return s.scopeImages(s.excludeIgnoredImages(&stash.ImageFilterType{
+			Tags:           s.excludeTagsCriterion(scannedTagID, completeTagID),
+			PerformerCount: performerCount,
+		}))
+	}
 
-		page++
+	// Count all candidates up front so progress spans every pass
+	_, total, err := stash.FindImages(s.graphqlClient, imageFilter(nil), 1, 1)
+	if err != nil {
+		return fmt.Errorf("failed to query images: %w", err)
+	}
 
-		// Fetch unscanned images (excluding scanned AND complete)
-		tagsFilter := stash.HierarchicalMultiCriterionInput{
-			Value:    []string{string(scannedTagID), string(completeTagID)},
-			Modifier: stash.CriterionModifierExcludes,
-		}
-		filter := &stash.ImageFilterType{
-			Tags: &tagsFilter,
-		}
-		images, count, err := stash.FindImages(s.graphqlClient, filter, page, batchSize)
-		if err != nil {
-			return fmt.Errorf("failed to query images: %w", err)
-		}
+	// Apply limit if specified
+	if limit > 0 && limit < total {
+		log.Infof("Found %d images, limiting to %d", total, limit)
+		total = limit
+	} else {
+		log.Infof("Found %d images to process", total)
+	}
+
+	// Stash writes for each image overlap detection of the next
+	stopWriter := s.startWriter()
+	defer stopWriter()
+
+	budget := s.newErrorBudget()
+	resumed := s.resumePoint("recognizeImages")
+	seed := s.batchSeed(resumed)
+	imageID := func(img stash.Image) graphql.ID { return img.ID }
+
+	// processBatch processes images, up to MaxConcurrency at once. Each
+	// image holds its trace's turn while it runs and yields it while its
+	// Vision job is in flight, so detection overlaps between images.
+	processBatch := func(images []stash.Image) error {
+		var mu sync.Mutex
+		var budgetErr error
+		throttle.ForEach(images, s.config.MaxConcurrency, func(img stash.Image) {
+			ctx := trace.NewContext(s.ctx, trace.New("img", string(img.ID)))
+			defer trace.Begin(ctx)()
+
+			mu.Lock()
+			if s.stopped() || budgetErr != nil {
+				mu.Unlock()
+				return
+			}
+			processedCount++
+			log.Infof("Processing image %d/%d: %s", processedCount, total, img.ID)
+			mu.Unlock()
 
-		if page == 1 {
-			total = count
+			var err error
+			if s.tooSmallForFaces(&img) {
+				s.markImageWithoutFaces(string(img.ID))
+			} else {
+				create := createNewSubjects && s.createsFromMedia("Image", img.ID, img.Rating, img.Organized)
+				err = s.recognizeImageFaces(ctx, visionClient, string(img.ID), create)
+			}
 
-			// Apply limit if specified
-			if limit > 0 && limit < total {
-				total = limit
-				log.Infof("Found %d images, limiting to %d", count, limit)
+			mu.Lock()
+			defer mu.Unlock()
+
+			var missing *MissingFileError
+			if errors.As(err, &missing) {
+				log.Warnf("Skipping image %s: %v", img.ID, err)
+				missingFiles = append(missingFiles, missing)
+				failureCount++
+				s.itemFailed("image", img.ID, err)
+			} else if err != nil {
+				log.Warnf("Failed to recognize faces in image %s: %v", img.ID, err)
+				failureCount++
+				s.itemFailed("image", img.ID, err)
 			} else {
-				log.Infof("Found %d images to process", total)
+				successCount++
+				s.clearFailures("image", img.ID)
 			}
-		}
+			s.reportProgress(float64(successCount+failureCount) / float64(total))
 
-		if len(images) == 0 {
-			break
+			if exceeded := budget.record(err); exceeded != nil && budgetErr == nil {
+				budgetErr = exceeded
+			}
+		})
+
+		if s.stopped() {
+			return fmt.Errorf("operation cancelled")
+		}
+		if budgetErr != nil {
+			log.Errorf("Batch recognition: %d processed, %d succeeded, %d failed", processedCount, successCount, failureCount)
+			reportMissingFiles(missingFiles)
+			return budgetErr
 		}
+		return nil
+	}
 
-		log.Infof("Processing batch %d: %d images", page, len(images))
+	for pass, performerCount := range s.performerCountPasses() {
+		if pass < resumed.Pass {
+			continue
+		}
+		logPerformerCountPass(performerCount, "images")
+		page := 0
+		lastID := 0 // Batches continue after the last image processed
+		if pass == resumed.Pass {
+			page = resumed.Page
+			lastID = resumed.LastID
+		}
+		shuffled := map[graphql.ID]bool{} // Images handed out by the random order
+		var deferred []stash.Image        // Images that failed in earlier runs, processed last
 
-		// Process each image in the batch
-		for _, img := range images {
-			if s.stopping {
+		for {
+			if s.stopped() {
 				return fmt.Errorf("operation cancelled")
 			}
 
-			// Check if limit reached
-			if limit > 0 && processedCount >= limit {
-				log.Infof("Reached limit of %d images, stopping", limit)
+			page++
+
+			// Apply pending writes so the query sees updated tags
+			s.flushWrites()
+
+			// Query images: newest first pages down by ID, random reads the
+			// start of the shuffle, other orders page up by ID
+			filter := imageFilter(performerCount)
+			var images []stash.Image
+			var err error
+			switch s.config.BatchOrder {
+			case config.BatchOrderNewest:
+				images, _, err = stash.FindImagesBefore(s.graphqlClient, filter, lastID, batchSize)
+			case config.BatchOrderRandom:
+				images, err = findShuffled(batchSize, shuffled, imageID, func(perPage int) ([]stash.Image, error) {
+					images, _, err := stash.FindImagesSorted(s.graphqlClient, filter, shuffleSort(seed), 1, perPage)
+					return images, err
+				})
+			default:
+				images, _, err = stash.FindImagesAfter(s.graphqlClient, filter, lastID, batchSize)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to query images: %w", err)
+			}
+
+			if len(images) == 0 {
 				break
 			}
 
-			processedCount++
-			progress := float64(processedCount) / float64(total)
-			log.Progress(progress)
+			log.Infof("Processing batch %d: %d images", page, len(images))
+			lastID, _ = strconv.Atoi(string(images[len(images)-1].ID))
+			images = deferFailed(s, "image", images, imageID, &deferred)
+
+			// Check if limit reached; a truncated batch resumes after its last image
+			if limit > 0 && processedCount+len(images) > limit {
+				images = images[:limit-processedCount]
+				if len(images) > 0 {
+					lastID, _ = strconv.Atoi(string(images[len(images)-1].ID))
+				}
+				log.Infof("Reached limit of %d images, stopping after this batch", limit)
+			}
 
-			log.Infof("Processing image %d/%d: %s", processedCount, total, img.ID)
+			if err := processBatch(images); err != nil {
+				return err
+			}
+			s.checkpoint(store.Checkpoint{Task: "recognizeImages", Pass: pass, Page: page, LastID: lastID, Seed: seed, Processed: processedCount})
 
-			err := s.recognizeImageFaces(visionClient, string(img.ID))
-			if err != nil {
-				log.Warnf("Failed to recognize faces in image %s: %v", img.ID, err)
-				failureCount++
-			} else {
-				successCount++
+			// Break outer loop if limit reached
+			if limit > 0 && processedCount >= limit {
+				break
+			}
+		}
+
+		// Images that failed before get their turn once the rest are done
+		if len(deferred) > 0 && (limit == 0 || processedCount < limit) {
+			sortByFailures(s, "image", deferred, imageID)
+			if limit > 0 && processedCount+len(deferred) > limit {
+				deferred = deferred[:limit-processedCount]
+			}
+			log.Infof("Processing %d images that failed in earlier runs, fewest failures first", len(deferred))
+			if err := processBatch(deferred); err != nil {
+				return err
 			}
 		}
 
-		// Break outer loop if limit reached
 		if limit > 0 && processedCount >= limit {
 			break
 		}
+	}
 
-		// Apply cooldown after processing batch
-		if len(images) == batchSize && processedCount < total {
-			s.applyCooldown()
-		}
+	// A run stopped by its limit keeps its checkpoint for the next resume
+	if limit == 0 || processedCount < limit {
+		s.clearCheckpoint("recognizeImages")
 	}
 
-	log.Progress(1.0)
+	s.reportProgress(1.0)
 	log.Infof("Batch recognition complete: %d processed, %d succeeded, %d failed", processedCount, successCount, failureCount)
+	reportMissingFiles(missingFiles)
+
+	return nil
+}
+
+// checkImageFile verifies the image file exists on disk. Missing files are
+// tagged with the missing file tag and reported as a *MissingFileError; the
+// tag is removed again once the file is found.
+func (s *Service) checkImageFile(img *stash.Image, imagePath string) error {
+	missingTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.MissingFileTagName, "Compreface Missing File")
+	if err != nil {
+		return fmt.Errorf("failed to get missing file tag: %w", err)
+	}
 
+	if _, err := os.Stat(imagePath); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to stat image file: %w", err)
+		}
+		if err := stash.UpdateImageTagsIfChanged(s.graphqlClient, img, []graphql.ID{missingTagID}, nil); err != nil {
+			log.Warnf("Failed to add missing file tag to image %s: %v", img.ID, err)
+		}
+		return &MissingFileError{SourceID: string(img.ID), Path: imagePath, TraceID: trace.Current()}
+	}
+
+	if err := stash.UpdateImageTagsIfChanged(s.graphqlClient, img, nil, []graphql.ID{missingTagID}); err != nil {
+		log.Warnf("Failed to remove missing file tag from image %s: %v", img.ID, err)
+	}
 	return nil
 }
 
-// recognizeImageFaces detects and recognizes faces in an image using Vision Service
-func (s *Service) recognizeImageFaces(visionClient *vision.VisionServiceClient, imageID string) error {
+// tooSmallForFaces reports whether an image's recorded dimensions are below
+// MinFaceSize, so no acceptable face can be found without reading the file
+func (s *Service) tooSmallForFaces(img *stash.Image) bool {
+	dims := img.Dimensions()
+	if dims.Width <= 0 || dims.Height <= 0 {
+		return false
+	}
+	if dims.Width < s.config.MinFaceSize || dims.Height < s.config.MinFaceSize {
+		log.Infof("Skipping image %s: %dx%d is smaller than the minimum face size (%dpx)", img.ID, dims.Width, dims.Height, s.config.MinFaceSize)
+		s.reportSkipped("image", img.ID, fmt.Sprintf("%dx%d is smaller than the minimum face size", dims.Width, dims.Height))
+		return true
+	}
+	return false
+}
+
+// reportMissingFiles logs the media skipped due to missing files so broken
+// mounts can be fixed
+func reportMissingFiles(missingFiles []*MissingFileError) {
+	if len(missingFiles) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	for _, missing := range missingFiles {
+		fmt.Fprintf(&b, "\n  - %s: %s [%s]", missing.SourceID, missing.Path, missing.TraceID)
+	}
+	log.Warnf("%d file(s) missing on disk (check library mounts):%s", len(missingFiles), b.String())
+}
+
+// recognizeImageFaces detects and recognizes faces in an image using Vision
+// Service, as the item traced by ctx
+func (s *Service) recognizeImageFaces(ctx context.Context, visionClient *vision.VisionServiceClient, imageID string, createNewSubjects bool) error {
+	itemTrace := trace.FromContext(ctx)
+	visionClient = visionClient.WithContext(ctx)
+	s.summary.imagesProcessed.Add(1)
+
 	// Step 1: Get image from Stash
 	img, err := stash.GetImage(s.graphqlClient, graphql.ID(imageID))
 	if err != nil {
 		return fmt.Errorf("failed to get image: %w", err)
 	}
 
-	if len(img.Files) == 0 {
+	imagePath := img.PrimaryPath()
+	if imagePath == "" {
 		return fmt.Errorf("image %s has no files", imageID)
 	}
 
-	imagePath := img.Files[0].Path
+	// Skip quickly when the file is missing (e.g. broken mount)
+	if err := s.checkImageFile(img, imagePath); err != nil {
+		return err
+	}
 
-	// Step 2: Submit to Vision Service for face detection
-	results, err := s.SubmitImageJob(visionClient, imagePath, imageID)
+	// Camera RAW files are processed through their embedded preview
+	imagePath, cleanup, err := s.rawPreviewPath(imagePath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Step 2: Submit to Vision Service for face detection; clips and
+	// animated images are sampled as video
+	var clipPath string
+	if s.isImageClip(img, imagePath) {
+		clipPath = imagePath
+		log.Infof("Image %s is a clip or animated image, sampling it as video", imageID)
+	}
+	span := trace.StartSpanContext(ctx, trace.StageDetect)
+	var results *vision.AnalyzeResults
+	if clipPath != "" {
+		results, err = s.SubmitClipJob(visionClient, clipPath, imageID)
+	} else {
+		results, err = s.SubmitImageJob(visionClient, imagePath, imageID)
+	}
+	span.End(err)
 	if err != nil {
 		return fmt.Errorf("vision service failed: %w", err)
 	}
@@ -174,14 +383,19 @@ func (s *Service) recognizeImageFaces(visionClient *vision.VisionServiceClient,
 	// Step 3: Add scanned tag regardless of results
 	scannedTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.ScannedTagName, "Compreface Scanned")
 	if err == nil {
-		stash.AddTagToImage(s.graphqlClient, graphql.ID(imageID), scannedTagID)
+		s.writeAsync("add scanned tag to image "+imageID, func() error {
+			return stash.UpdateImageTagsIfChanged(s.graphqlClient, img, []graphql.ID{scannedTagID}, nil)
+		})
 	}
 
 	// Check if faces were found
 	if results.Faces == nil || len(results.Faces.Faces) == 0 {
 		log.Debugf("No faces detected in image %s", imageID)
+		s.reportFacesDetected("image", graphql.ID(imageID), 0)
 		// Mark as complete (no faces to match)
-		s.updateImageCompletionStatus(graphql.ID(imageID), 0, 0)
+		s.writeAsync("update image "+imageID+" completion status", func() error {
+			return s.updateImageCompletionStatus(img, 0, 0, nil, nil)
+		})
 		return nil
 	}
 
@@ -195,35 +409,67 @@ func (s *Service) recognizeImageFaces(visionClient *vision.VisionServiceClient,
 		}
 	}
 	log.Infof("Image %s: Found %d processable faces out of %d total faces", imageID, facesDetected, len(results.Faces.Faces))
+	s.reportFacesDetected("image", graphql.ID(imageID), facesDetected)
+
+	// Anonymization mode records detection only; no face data leaves the plugin
+	if s.config.AnonymizationMode {
+		addTags, removeTags := s.facesDetectedTags(facesDetected)
+		s.writeAsync("update image "+imageID+" detection tags", func() error {
+			return stash.UpdateImageTagsIfChanged(s.graphqlClient, img, addTags, removeTags)
+		})
+		return nil
+	}
 
-	// Step 4: Load image bytes for face cropping
-	imageBytes, err := LoadImageBytes(imagePath)
-	if err != nil {
-		return fmt.Errorf("failed to load image bytes: %w", err)
+	// Step 4: Load image bytes for face cropping (clip faces are cropped from
+	// frames extracted at their timestamps instead)
+	var imageBytes []byte
+	if clipPath == "" {
+		span = trace.StartSpanContext(ctx, trace.StageFetch)
+		imageBytes, err = s.loadImageBytes(imagePath)
+		span.End(err)
+		if err != nil {
+			return fmt.Errorf("failed to load image bytes: %w", err)
+		}
 	}
 
 	// Step 5: Process each face
 	requestMetadata := results.Faces.Metadata
 	matchedPerformers := []graphql.ID{}
+	var extraPerformers []graphql.ID // Matched by extra faces in face crops
 	facesProcessed := 0
+	var worst worstSimilarity
 
-	for _, face := range results.Faces.Faces {
+	// Recognize the faces' embeddings in one request
+	defer s.prefetchEmbeddingMatches(results.Faces.Faces)()
+
+	for i, face := range results.Faces.Faces {
+		trace.Set(trace.Face(itemTrace, i))
 		ctx := FaceProcessingContext{
-			ImageBytes: imageBytes,
-			SourceID:   imageID,
+			ImageBytes:        imageBytes,
+			ClipPath:          clipPath,
+			SourceID:          imageID,
+			ExtraPerformers:   &extraPerformers,
+			CreateNewSubjects: createNewSubjects,
+			ReviewNewSubjects: createNewSubjects && s.reviewsNewFaces(),
 		}
-		performerID, err := s.processFace(visionClient, ctx, face, requestMetadata)
+		performerID, similarity, err := s.processFace(visionClient, ctx, face, requestMetadata)
 		if err != nil {
 			log.Warnf("Failed to process face %s: %v", face.FaceID, err)
+			s.reportFace(ctx, face, ReportFace{Status: ReportFaceFailed, Reason: err.Error()})
 			continue
 		}
 		if performerID != "" {
 			matchedPerformers = append(matchedPerformers, performerID)
+			worst.add(similarity)
 			facesProcessed++
 		}
 	}
+	trace.Set(itemTrace)
+	matchedPerformers = append(matchedPerformers, extraPerformersOf(extraPerformers, matchedPerformers)...)
 
 	// Step 6: Update image with matched performers
+	statusTags, removeTags := s.confidenceBandTags(worst)
+	var performerInput *stash.ImageUpdateInput
 	if len(matchedPerformers) > 0 {
 		log.Infof("Image %s: Matched/created %d performers", imageID, len(matchedPerformers))
 
@@ -242,27 +488,29 @@ func (s *Service) recognizeImageFaces(visionClient *vision.VisionServiceClient,
 			performerIDStrs[i] = string(id)
 		}
 
-		input := stash.ImageUpdateInput{
+		performerInput = &stash.ImageUpdateInput{
 			ID:           imageID,
 			PerformerIds: performerIDStrs,
 		}
-		err = stash.UpdateImage(s.graphqlClient, graphql.ID(imageID), input)
-		if err != nil {
-			log.Warnf("Failed to update image performers: %v", err)
-		}
 
-		// Add matched tag
+		// Add matched tag (applied together with completion status)
 		matchedTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.MatchedTagName, "Compreface Matched")
 		if err == nil {
-			stash.AddTagToImage(s.graphqlClient, graphql.ID(imageID), matchedTagID)
+			statusTags = append(statusTags, matchedTagID)
 		}
 	}
 
-	// Step 7: Update completion status
-	err = s.updateImageCompletionStatus(graphql.ID(imageID), facesDetected, facesProcessed)
-	if err != nil {
-		log.Warnf("Failed to update completion status: %v", err)
-	}
+	statusTags = append(statusTags, s.pendingReviewTags("image:"+imageID)...)
+
+	// Step 7: Write performers and completion status
+	s.writeAsync("update image "+imageID, func() error {
+		if performerInput != nil {
+			if err := stash.UpdateImage(s.graphqlClient, graphql.ID(imageID), *performerInput); err != nil {
+				log.Warnf("Failed to update image performers: %v", err)
+			}
+		}
+		return s.updateImageCompletionStatus(img, facesDetected, facesProcessed, statusTags, removeTags)
+	})
 
 	log.Infof("Image %s: %d subjects processed", imageID, facesProcessed)
 
@@ -271,7 +519,11 @@ func (s *Service) recognizeImageFaces(visionClient *vision.VisionServiceClient,
 
 // identifyImage identifies faces in a single image and optionally creates performers
 func (s *Service) identifyImage(imageID string, createPerformer bool, associateExisting bool, faceIndex *int) (*[]FaceIdentity, error) {
-	if s.stopping {
+	itemTrace := trace.New("img", imageID)
+	defer trace.Start(itemTrace)()
+	s.summary.imagesProcessed.Add(1)
+
+	if s.stopped() {
 		return nil, fmt.Errorf("operation cancelled")
 	}
 
@@ -288,130 +540,38 @@ func (s *Service) identifyImage(imageID string, createPerformer bool, associateE
 	imagePath := image.Files[0].Path
 	log.Debugf("Image path: %s", imagePath)
 
-	// Step 2: Detect faces - try Vision Service first, fall back to Compreface
-	var identities *[]FaceIdentity
-	var performerIDs []graphql.ID
-	var foundMatch bool
-	var recognitionResp *compreface.RecognitionResponse
-	var facesToProcess []compreface.RecognitionResult
-	var facesDetected int
-
-	// Check if Vision Service is available
-	visionClient := s.createVisionClient()
-	if visionClient != nil {
-		// VISION SERVICE PATH (preferred)
-		log.Infof("Using Vision Service for face detection: %s", imagePath)
-		visionIdentities, visionFacesDetected, visionErr := s.identifyImageViaVision(visionClient, imageID, imagePath, createPerformer, faceIndex)
-		if visionErr != nil {
-			log.Warnf("Vision Service identification failed, falling back to Compreface: %v", visionErr)
-		} else {
-			identities = visionIdentities
-			facesDetected = visionFacesDetected
-			goto handleAssociation // Skip to association logic
-		}
-	}
-
-	// Step 3: Fallback to Compreface Recognition
-	recognitionResp, err = s.processComprefaceRecognition(imageID, imagePath)
-	if err != nil || recognitionResp == nil {
+	// Step 2: Detect and identify faces with the configured detector
+	detection, err := s.detectImageFaces(imageID, imagePath, createPerformer, faceIndex)
+	if err != nil || detection == nil {
 		return nil, err
 	}
+	identities := &detection.Identities
+	facesDetected := detection.FacesDetected
+	s.reportIdentities(imageID, facesDetected, *identities)
 
-	identities = &[]FaceIdentity{}
-	log.Infof("Found %d face(s) in image %s", len(recognitionResp.Result), imageID)
-
-	// Step 4: Process faces (or specific face if faceIndex is provided)
-	facesToProcess = recognitionResp.Result
-	facesDetected = len(facesToProcess)
-	if faceIndex != nil {
-		if *faceIndex >= facesDetected {
-			return nil, fmt.Errorf("face index %d out of range (detected %d faces)", *faceIndex, len(facesToProcess))
-		}
-		facesToProcess = []compreface.RecognitionResult{facesToProcess[*faceIndex]}
-		log.Infof("Processing only face index %d", *faceIndex)
-	}
-
-	for i, result := range facesToProcess {
-		log.Debugf("Processing face %d/%d", i+1, len(facesToProcess))
-
-		// Check if we have a match above threshold
-		// Note: Compreface ALWAYS returns results even for low similarities
-		// We must check the similarity score to determine if it's a valid match
-		var matchedSubject string
-		var matchedSimilarity float64
-
-		if len(result.Subjects) > 0 {
-			bestMatch := result.Subjects[0]
-			matchedSimilarity = bestMatch.Similarity
-
-			// Only consider it a match if similarity is above threshold
-			if bestMatch.Similarity >= s.config.MinSimilarity {
-				matchedSubject = bestMatch.Subject
-				log.Infof("Face %d: Matched subject '%s' with similarity %.2f",
-					i, matchedSubject, matchedSimilarity)
-			} else {
-				log.Debugf("Face %d: Best match '%s' below threshold (%.2f < %.2f)",
-					i, bestMatch.Subject, bestMatch.Similarity, s.config.MinSimilarity)
-			}
-		} else {
-			log.Debugf("Face %d: No subjects returned from Compreface", i)
-		}
-
-		// Capture bounding box for client-side cropping
-		boundingBox := result.Box
-
-		// Calculate confidence as percentage
-		confidence := matchedSimilarity * 100
-
-		// If no match above threshold and createPerformer is true, create new subject/performer
-		if matchedSubject == "" {
-			// Create new identity
-			identity, err := s.createNewIdentity(imageID, imagePath, i, result, createPerformer)
-			if err != nil || identity == nil {
-				continue
-			}
-			if createPerformer && identity.Performer.ID != nil {
-				performerIDs = append(performerIDs, graphql.ID(*identity.Performer.ID))
-				foundMatch = true
-			}
-			*identities = append(*identities, *identity)
-			continue
-		}
-
-		// If we have a matched subject above threshold, find the performer
-		if matchedSubject != "" {
-			// Create an identity for the existing match
-			identity, err := s.createExistingIdentity(matchedSubject, imageID, i, boundingBox, confidence, result)
-			if err != nil || identity == nil {
-				continue
-			}
-			performerID := graphql.ID(*identity.Performer.ID)
-			performerIDs = append(performerIDs, performerID)
+	// Step 3: Collect matched performers (new performers when createPerformer is true)
+	var performerIDs []graphql.ID
+	foundMatch := false
+	for _, identity := range *identities {
+		if identity.Performer.ID != nil && *identity.Performer.ID != "" {
+			performerIDs = append(performerIDs, graphql.ID(*identity.Performer.ID))
 			foundMatch = true
-			*identities = append(*identities, *identity)
-		}
-	}
-
-handleAssociation:
-	// Extract matched performer IDs from identities (for Vision path)
-	// For Compreface path, performerIDs is already populated above
-	if len(performerIDs) == 0 {
-		// Vision path - extract from identities
-		for _, identity := range *identities {
-			if identity.Performer.ID != nil && *identity.Performer.ID != "" {
-				performerIDs = append(performerIDs, graphql.ID(*identity.Performer.ID))
-				foundMatch = true
-			}
 		}
 	}
 
-	// Steps 5-8: Only update Stash tags (scanned, matched, completion) if associateExisting is true
+	// Steps 4-7: Only update Stash tags (scanned, matched, completion) if associateExisting is true
 	if associateExisting {
-		// Step 5: Update image with matched performers
+		// Step 4: Update image with matched performers
 		_ = s.associateExistingPerformers(*image, performerIDs)
 
-		// Steps 6-8: Add scanned, matched or completion tags
-		_ = s.updateImageStatuses(imageID, foundMatch, facesDetected, performerIDs)
+		// Steps 5-7: Add scanned, matched, completion or confidence tags
+		var worst worstSimilarity
+		for _, identity := range *identities {
+			if identity.Performer.ID != nil && identity.Confidence != nil {
+				worst.add(*identity.Confidence / 100)
+			}
+		}
+		_ = s.updateImageStatuses(imageID, foundMatch, facesDetected, performerIDs, worst)
 
 		log.Infof("Successfully processed image %s (%d performer(s) matched)", imageID, len(performerIDs))
 	} else {
@@ -420,10 +580,19 @@ handleAssociation:
 	return identities, nil
 }
 
+// newVisionClient builds a Vision Service client from the plugin configuration
+func (s *Service) newVisionClient() *vision.VisionServiceClient {
+	visionClient := vision.NewVisionServiceClient(s.config.VisionServiceURL, s.config.FrameServerURL)
+	visionClient.Token = s.config.VisionServiceToken
+	visionClient.JobSlots = s.visionJobSlots
+	visionClient.Context = s.ctx
+	return visionClient
+}
+
 // createVisionClient initializes and returns a Vision Service client if available
 func (s *Service) createVisionClient() *vision.VisionServiceClient {
 	if s.config.VisionServiceURL != "" {
-		visionClient := vision.NewVisionServiceClient(s.config.VisionServiceURL, s.config.FrameServerURL)
+		visionClient := s.newVisionClient()
 		if healthErr := visionClient.HealthCheck(); healthErr == nil {
 			// VISION SERVICE PATH (preferred)
 			log.Infof("Vision Service is available.")
@@ -440,18 +609,13 @@ func (s *Service) createVisionClient() *vision.VisionServiceClient {
 // processComprefaceRecognition processes face recognition using Compreface for a single image.
 func (s *Service) processComprefaceRecognition(imageID string, imagePath string) (*compreface.RecognitionResponse, error) {
 	log.Infof("Recognizing faces in image using Compreface: %s", imagePath)
+	span := trace.StartSpan(trace.StageRecognize)
 	recognitionResp, err := s.comprefaceClient.RecognizeFaces(imagePath)
+	span.End(err)
 	if err != nil {
-		// Check if error is "No face is found" (code 28)
-		if strings.Contains(err.Error(), "No face is found") || strings.Contains(err.Error(), "code\" : 28") {
+		if errors.Is(err, compreface.ErrNoFaceFound) {
 			log.Infof("No faces detected in image %s", imageID)
-			// Still add scanned tag
-			scannedTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.ScannedTagName, "Compreface Scanned")
-			if err == nil {
-				stash.AddTagToImage(s.graphqlClient, graphql.ID(imageID), scannedTagID)
-			}
-			// Mark as complete (no faces to match)
-			s.updateImageCompletionStatus(graphql.ID(imageID), 0, 0)
+			s.markImageWithoutFaces(imageID)
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to recognize faces: %w", err)
@@ -459,18 +623,31 @@ func (s *Service) processComprefaceRecognition(imageID string, imagePath string)
 
 	if len(recognitionResp.Result) == 0 {
 		log.Infof("No faces detected in image %s", imageID)
-		// Still add scanned tag
-		scannedTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.ScannedTagName, "Compreface Scanned")
-		if err == nil {
-			stash.AddTagToImage(s.graphqlClient, graphql.ID(imageID), scannedTagID)
-		}
-		// Mark as complete (no faces to match)
-		s.updateImageCompletionStatus(graphql.ID(imageID), 0, 0)
+		s.markImageWithoutFaces(imageID)
 		return nil, nil
 	}
 	return recognitionResp, nil
 }
 
+// markImageWithoutFaces tags an image as scanned and complete (no faces to match)
+func (s *Service) markImageWithoutFaces(imageID string) {
+	image, err := stash.GetImage(s.graphqlClient, graphql.ID(imageID))
+	if err != nil {
+		log.Warnf("Failed to get image %s: %v", imageID, err)
+		return
+	}
+
+	var statusTags []graphql.ID
+	scannedTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.ScannedTagName, "Compreface Scanned")
+	if err == nil {
+		statusTags = append(statusTags, scannedTagID)
+	}
+
+	if err := s.updateImageCompletionStatus(image, 0, 0, statusTags, nil); err != nil {
+		log.Warnf("Failed to update completion status: %v", err)
+	}
+}
+
 // createComprefaceSubjectFromRecognitionResult creates a new Compreface subject from a recognition result
 func (s *Service) createComprefaceSubjectFromRecognitionResult(
 	subjectName string,
@@ -485,7 +662,9 @@ func (s *Service) createComprefaceSubjectFromRecognitionResult(
 		return nil, err
 	}
 
+	span := trace.StartSpan(trace.StageCrop)
 	faceCrop, err := s.cropFaceBytes(imageBytes, result.Box, 20)
+	span.End(err)
 	if err != nil {
 		log.Warnf("Failed to crop face %d: %v", faceIndex, err)
 		return nil, err
@@ -502,24 +681,57 @@ func (s *Service) createComprefaceSubjectFromRecognitionResult(
 	return addResp, nil
 }
 
-// createStashPerformerFromComprefaceResponse creates a Stash performer from a Compreface subject response
+// subjectImageDataURI downloads a stored subject example as a data URI for a
+// performer image. Compreface's static image URLs embed the API key, so they
+// are never handed to Stash. Returns "" (no image) when the download fails.
+func (s *Service) subjectImageDataURI(imageID string) string {
+	dataURI, err := s.faceImageDataURI(imageID)
+	if err != nil {
+		log.Warnf("Failed to download subject image %s, creating performer without image: %v", imageID, err)
+		return ""
+	}
+	return dataURI
+}
+
+// faceImageDataURI downloads a stored subject example as a data URI
+func (s *Service) faceImageDataURI(imageID string) (string, error) {
+	imageBytes, err := s.comprefaceClient.DownloadFaceImage(imageID)
+	if err != nil {
+		return "", err
+	}
+	if len(imageBytes) == 0 {
+		return "", fmt.Errorf("subject image %s is empty", imageID)
+	}
+	return "data:" + http.DetectContentType(imageBytes) + ";base64," + base64.StdEncoding.EncodeToString(imageBytes), nil
+}
+
+// createStashPerformerFromComprefaceResponse creates a Stash performer from a Compreface subject response,
+// or links the subject to the stash-box performer the face verifies as
 func (s *Service) createStashPerformerFromComprefaceResponse(
+	imageID string,
 	response compreface.AddSubjectResponse,
 	result compreface.RecognitionResult,
 ) (graphql.ID, error) {
 	subjectName := response.Subject
-	age := int((result.Age.Low + result.Age.High) / 2)
 	gender := result.Gender.Value
-	// Construct Compreface image URL
-	imageURL := s.comprefaceClient.SubjectImageURL(response.ImageID)
-	log.Debugf("Compreface face image URL: %s", imageURL)
+
+	if s.stashBoxEnabled() {
+		example, err := s.comprefaceClient.DownloadFaceImage(response.ImageID)
+		if err != nil {
+			log.Warnf("Failed to download subject image %s for stash-box lookup: %v", response.ImageID, err)
+		} else if performerID, ok := s.stashBoxPerformer("image:"+imageID, example, subjectName, gender, result.Age.Low, result.Age.High); ok {
+			return performerID, nil
+		}
+	}
 
 	// Create performer in Stash with face image from Compreface
 	performerSubject := stash.PerformerSubject{
-		Name:   subjectName,
-		Age:    age,
-		Image:  imageURL,
-		Gender: gender,
+		Name:    subjectName,
+		Age:     s.birthdateAge(result.Age.Low, result.Age.High),
+		AgeLow:  result.Age.Low,
+		AgeHigh: result.Age.High,
+		Image:   s.subjectImageDataURI(response.ImageID),
+		Gender:  gender,
 	}
 
 	performerID, err := stash.CreatePerformerWithImage(s.graphqlClient, performerSubject)
@@ -527,6 +739,7 @@ func (s *Service) createStashPerformerFromComprefaceResponse(
 		log.Warnf("Failed to create performer for subject '%s': %v", subjectName, err)
 		return "", err
 	}
+	s.performerCreated(performerID)
 	return performerID, nil
 }
 
@@ -569,7 +782,7 @@ func (s *Service) createNewIdentity(
 		}
 
 		// Create Stash performer from Compreface response
-		performerID, err := s.createStashPerformerFromComprefaceResponse(*addResp, result)
+		performerID, err := s.createStashPerformerFromComprefaceResponse(imageID, *addResp, result)
 		if err != nil {
 			return nil, err
 		}
@@ -611,6 +824,7 @@ func (s *Service) createExistingIdentity(
 
 	if performerID != "" {
 		log.Infof("Face %d: Associated with performer %s", faceIndex, performerID)
+		s.summary.facesMatched.Add(1)
 		performerIDStr := string(performerID)
 		performer.ID = &performerIDStr
 		performer.Name = matchedSubject
@@ -623,7 +837,7 @@ func (s *Service) createExistingIdentity(
 		return &identity, nil
 	} else {
 		err = fmt.Errorf("face %d: subject '%s' exists in compreface but no matching performer found in stash", faceIndex, matchedSubject)
-		log.Warnf(err.Error())
+		log.Warn(err)
 		return nil, err
 	}
 }
@@ -663,7 +877,7 @@ func (s *Service) associateExistingPerformers(image stash.Image, performerIDs []
 		return nil
 	}
 	err := fmt.Errorf("no performer IDs to associate with image %s", imageID)
-	log.Warnf(err.Error())
+	log.Warn(err)
 	return err
 }
 
@@ -673,12 +887,21 @@ func (s *Service) updateImageStatuses(
 	foundMatching bool,
 	facesDetected int,
 	performerIDs []graphql.ID,
+	worst worstSimilarity,
 ) error {
 	hasError := false
-	// Add scanned tag
+
+	// Load current tags once; all status tags are applied in a single update
+	image, err := stash.GetImage(s.graphqlClient, graphql.ID(imageID))
+	if err != nil {
+		return fmt.Errorf("failed to get image %s: %w", imageID, err)
+	}
+
+	// Add scanned tag (and confidence band, if enabled)
+	statusTags, removeTags := s.confidenceBandTags(worst)
 	scannedTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.ScannedTagName, "Compreface Scanned")
 	if err == nil {
-		stash.AddTagToImage(s.graphqlClient, graphql.ID(imageID), scannedTagID)
+		statusTags = append(statusTags, scannedTagID)
 	} else {
 		hasError = true
 		log.Warnf("Failed to add scanned tag to image %s: %v", imageID, err)
@@ -688,16 +911,18 @@ func (s *Service) updateImageStatuses(
 	if foundMatching {
 		matchedTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.MatchedTagName, "Compreface Matched")
 		if err == nil {
-			stash.AddTagToImage(s.graphqlClient, graphql.ID(imageID), matchedTagID)
+			statusTags = append(statusTags, matchedTagID)
 		} else {
 			hasError = true
 			log.Warnf("Failed to add matched tag to image %s: %v", imageID, err)
 		}
 	}
 
+	statusTags = append(statusTags, s.pendingReviewTags("image:"+imageID)...)
+
 	// Update completion status
 	facesMatched := len(performerIDs)
-	err = s.updateImageCompletionStatus(graphql.ID(imageID), facesDetected, facesMatched)
+	err = s.updateImageCompletionStatus(image, facesDetected, facesMatched, statusTags, removeTags)
 	if err != nil {
 		hasError = true
 		log.Warnf("Failed to update completion status: %v", err)
@@ -717,17 +942,19 @@ func (s *Service) identifyImageViaVision(
 	imagePath string,
 	createPerformer bool,
 	faceIndex *int,
-) (*[]FaceIdentity, int, error) {
+) (*FaceDetectionResult, error) {
 	// Submit image to Vision Service
+	span := trace.StartSpan(trace.StageDetect)
 	results, err := s.SubmitImageJob(visionClient, imagePath, imageID)
+	span.End(err)
 	if err != nil {
-		return nil, 0, fmt.Errorf("vision service job failed: %w", err)
+		return nil, fmt.Errorf("vision service job failed: %w", err)
 	}
 
 	// Handle no faces detected
 	if results.Faces == nil || len(results.Faces.Faces) == 0 {
 		log.Infof("No faces detected in image %s by Vision Service", imageID)
-		return &[]FaceIdentity{}, 0, nil
+		return &FaceDetectionResult{Detector: config.ImageDetectorVision, Identities: []FaceIdentity{}}, nil
 	}
 
 	// Filter by faceIndex if specified
@@ -735,7 +962,7 @@ func (s *Service) identifyImageViaVision(
 	facesDetected := len(facesToProcess)
 	if faceIndex != nil {
 		if *faceIndex >= len(facesToProcess) {
-			return nil, 0, fmt.Errorf("face index %d out of range (Vision detected %d faces)",
+			return nil, fmt.Errorf("face index %d out of range (Vision detected %d faces)",
 				*faceIndex, facesDetected)
 		}
 		facesToProcess = []vision.VisionFace{facesToProcess[*faceIndex]}
@@ -743,21 +970,35 @@ func (s *Service) identifyImageViaVision(
 	}
 
 	// Load image bytes for face cropping
-	imageBytes, err := LoadImageBytes(imagePath)
+	span = trace.StartSpan(trace.StageFetch)
+	imageBytes, err := s.loadImageBytes(imagePath)
+	span.End(err)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to load image bytes: %w", err)
+		return nil, fmt.Errorf("failed to load image bytes: %w", err)
 	}
 
 	log.Infof("Image %s: Found %d face(s) via Vision Service", imageID, facesDetected)
 
 	// Process each detected face
-	identities := &[]FaceIdentity{}
+	identities := []FaceIdentity{}
 	ctx := FaceProcessingContext{
-		ImageBytes: imageBytes,
-		SourceID:   imageID,
+		ImageBytes:        imageBytes,
+		SourceID:          imageID,
+		ReviewNewSubjects: createPerformer && faceIndex == nil && s.reviewsNewFaces(),
 	}
 
+	itemTrace := trace.Current()
+	defer trace.Set(itemTrace)
+
+	// Recognize the faces' embeddings in one request
+	defer s.prefetchEmbeddingMatches(facesToProcess)()
+
 	for i, face := range facesToProcess {
+		if faceIndex != nil {
+			trace.Set(trace.Face(itemTrace, *faceIndex))
+		} else {
+			trace.Set(trace.Face(itemTrace, i))
+		}
 		log.Debugf("Processing face %d/%d: %s", i+1, len(facesToProcess), face.FaceID)
 
 		identity, err := s.processFaceForIdentification(
@@ -769,58 +1010,201 @@ func (s *Service) identifyImageViaVision(
 		}
 
 		if identity != nil {
-			*identities = append(*identities, *identity)
+			identity.TraceID = trace.Current()
+			identities = append(identities, *identity)
 		}
 	}
+	trace.Set(itemTrace)
 
-	log.Infof("Image %s: Identified %d faces", imageID, len(*identities))
-	return identities, facesDetected, nil
+	log.Infof("Image %s: Identified %d faces", imageID, len(identities))
+	return &FaceDetectionResult{
+		Detector:      config.ImageDetectorVision,
+		FacesDetected: facesDetected,
+		Identities:    identities,
+	}, nil
 }
 
-// identifyGallery processes all images in a gallery
-func (s *Service) identifyGallery(galleryID string, createPerformer bool, limit int) error {
-	if s.stopping {
-		return fmt.Errorf("operation cancelled")
+// identifyImageViaCompreface processes a single image through Compreface
+// recognition, which both detects and matches faces. Returns nil when no faces
+// are found; the image is then already marked scanned.
+func (s *Service) identifyImageViaCompreface(
+	imageID string,
+	imagePath string,
+	createPerformer bool,
+	faceIndex *int,
+) (*FaceDetectionResult, error) {
+	recognitionResp, err := s.processComprefaceRecognition(imageID, imagePath)
+	if err != nil || recognitionResp == nil {
+		return nil, err
 	}
 
-	log.Infof("Starting gallery identification: %s (createPerformer=%v, limit=%d)", galleryID, createPerformer, limit)
+	log.Infof("Found %d face(s) in image %s", len(recognitionResp.Result), imageID)
+
+	// Process faces (or specific face if faceIndex is provided)
+	facesToProcess := recognitionResp.Result
+	facesDetected := len(facesToProcess)
+	if faceIndex != nil {
+		if *faceIndex >= facesDetected {
+			return nil, fmt.Errorf("face index %d out of range (detected %d faces)", *faceIndex, len(facesToProcess))
+		}
+		facesToProcess = []compreface.RecognitionResult{facesToProcess[*faceIndex]}
+		log.Infof("Processing only face index %d", *faceIndex)
+	}
+
+	identities := []FaceIdentity{}
+	var imageBytes []byte // Loaded on first match to explain which subject example matched
+	review := createPerformer && faceIndex == nil && s.reviewsNewFaces()
+
+	itemTrace := trace.Current()
+	defer trace.Set(itemTrace)
+
+	for i, result := range facesToProcess {
+		if faceIndex != nil {
+			trace.Set(trace.Face(itemTrace, *faceIndex))
+		} else {
+			trace.Set(trace.Face(itemTrace, i))
+		}
+		log.Debugf("Processing face %d/%d", i+1, len(facesToProcess))
+		s.pauseBeforeFace()
+
+		// Check if we have a match above threshold
+		// Note: Compreface ALWAYS returns results even for low similarities
+		// We must check the similarity score to determine if it's a valid match
+		var matchedSubject string
+		var matchedSimilarity float64
+		var candidates []string // Set when the match was too close to call
+
+		if len(result.Subjects) > 0 {
+			bestMatch := result.Subjects[0]
+
+			// Only consider it a match if similarity is above threshold
+			if match, ok := s.selectMatch(result.Subjects, nil, nil); !ok {
+				log.Debugf("Face %d: Best match '%s' below threshold (%.2f < %.2f)",
+					i, bestMatch.Subject, bestMatch.Similarity, s.config.MinSimilarity)
+			} else if match.ambiguous && review {
+				candidates = match.candidates
+			} else {
+				matchedSubject = match.subject.Subject
+				matchedSimilarity = match.subject.Similarity
+				log.Infof("Face %d: Matched subject '%s' with similarity %.2f",
+					i, matchedSubject, matchedSimilarity)
+			}
+		} else {
+			log.Debugf("Face %d: No subjects returned from Compreface", i)
+		}
+
+		// Capture bounding box for client-side cropping
+		boundingBox := result.Box
+
+		// Calculate confidence as percentage
+		confidence := matchedSimilarity * 100
+
+		// If no match above threshold and createPerformer is true, create new subject/performer
+		if matchedSubject == "" {
+			if review {
+				if imageBytes == nil {
+					if imageBytes, err = s.loadImageBytes(imagePath); err != nil {
+						log.Warnf("Failed to load image %s for review: %v", imageID, err)
+					}
+				}
+				if err := s.reviewComprefaceFace(imageID, imageBytes, i, result, candidates); err != nil {
+					log.Warnf("Face %d: %v", i, err)
+				}
+			}
+
+			// Create new identity (unmatched when the face was queued for review)
+			identity, err := s.createNewIdentity(imageID, imagePath, i, result, createPerformer && !review)
+			if err != nil || identity == nil {
+				continue
+			}
+			identity.TraceID = trace.Current()
+			identities = append(identities, *identity)
+			continue
+		}
+
+		// If we have a matched subject above threshold, find the performer
+		if matchedSubject != "" {
+			// Create an identity for the existing match
+			identity, err := s.createExistingIdentity(matchedSubject, imageID, i, boundingBox, confidence, result)
+			if err != nil || identity == nil {
+				continue
+			}
+			if imageBytes == nil {
+				if imageBytes, err = s.loadImageBytes(imagePath); err != nil {
+					log.Debugf("Failed to load image %s for match explanation: %v", imageID, err)
+				}
+			}
+			faceCrop, _ := s.cropFaceBytes(imageBytes, boundingBox, 20)
+			identity.MatchedExample = s.matchedExample(matchedSubject, faceCrop)
+			identity.TraceID = trace.Current()
+			identities = append(identities, *identity)
+		}
+	}
+	trace.Set(itemTrace)
+
+	return &FaceDetectionResult{
+		Detector:      config.ImageDetectorCompreface,
+		FacesDetected: facesDetected,
+		Identities:    identities,
+	}, nil
+}
+
+// identifyGallery processes the images of a gallery not identified by an
+// earlier run (all of them when full is set) and returns a summary of the
+// results
+func (s *Service) identifyGallery(galleryID string, createPerformer bool, limit int, full bool) (*GallerySummary, error) {
+	if s.stopped() {
+		return nil, fmt.Errorf("operation cancelled")
+	}
+
+	log.Infof("Starting gallery identification: %s (createPerformer=%v, limit=%d, full=%v)", galleryID, createPerformer, limit, full)
 
 	// Step 1: Get gallery info first
 	gallery, err := stash.GetGallery(s.graphqlClient, graphql.ID(galleryID))
 	if err != nil {
-		return fmt.Errorf("failed to get gallery: %w", err)
+		return nil, fmt.Errorf("failed to get gallery: %w", err)
 	}
 
+	summary := newGallerySummary(galleryID)
 	if gallery.ImageCount == 0 {
 		log.Infof("Gallery %s has no images", galleryID)
-		return nil
+		return summary, nil
 	}
 
-	page := 1
-	totalImages := gallery.ImageCount
-	if limit > 0 && limit < totalImages {
-		totalImages = limit
-	}
-
-	log.Infof("Gallery '%s' has %d images (will process %d)", gallery.Title, gallery.ImageCount, totalImages)
-
-	// Step 2: Query images in gallery using findImages with gallery filter
-	// Only images without scanned tag
+	// Step 2: Query images in gallery using findImages with gallery filter,
+	// and keep those not identified by an earlier run
 	galleryFilter := stash.MultiCriterionInput{
 		Value:    []string{string(galleryID)},
 		Modifier: stash.CriterionModifierIncludes,
 	}
 	filter := &stash.ImageFilterType{
 		Galleries: &galleryFilter,
+		Tags:      s.excludeTagsCriterion(),
 	}
-	images, _, err := stash.FindImages(s.graphqlClient, filter, page, totalImages)
+	var galleryImages []stash.Image
+	err = stash.FindAllImages(s.graphqlClient, filter, s.config.MaxBatchSize, func(page []stash.Image, count int) error {
+		galleryImages = append(galleryImages, page...)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to query gallery images: %w", err)
+		return nil, fmt.Errorf("failed to query gallery images: %w", err)
+	}
+
+	images, processed := s.splitGalleryImages(galleryID, galleryImages, full)
+	summary.ImagesSkipped = len(processed)
+	if limit > 0 && limit < len(images) {
+		summary.ImagesRemaining = len(images) - limit
+		images = images[:limit]
 	}
+	log.Infof("Gallery '%s' has %d images: %d identified by earlier runs, will process %d", gallery.Title, len(galleryImages), len(processed), len(images))
 
 	if len(images) == 0 {
-		log.Infof("Gallery %s has no images to process", galleryID)
-		return nil
+		log.Infof("Gallery %s has no new images to process", galleryID)
+		summary.Complete = true
+		if err := s.updateGalleryCompletionStatus(gallery, summary); err != nil {
+			log.Warnf("Gallery completion status update failed: %v", err)
+		}
+		return summary, nil
 	}
 
 	log.Infof("Processing %d images from gallery '%s'", len(images), gallery.Title)
@@ -828,42 +1212,72 @@ func (s *Service) identifyGallery(galleryID string, createPerformer bool, limit
 	// Step 3: Process each image in the gallery
 	successCount := 0
 	failureCount := 0
+	matches := existingGalleryMatches(processed)
+	createdBefore := s.summary.performersCreated.Load()
 
 	for i, image := range images {
-		if s.stopping {
-			return fmt.Errorf("operation cancelled")
+		if s.stopped() {
+			return nil, fmt.Errorf("operation cancelled")
 		}
 
 		progress := float64(i+1) / float64(len(images))
-		log.Progress(progress)
+		s.reportProgress(progress)
 
 		log.Infof("Processing image %d/%d: %s", i+1, len(images), image.ID)
 
 		// Batch processing always associates performers
-		_, err := s.identifyImage(string(image.ID), createPerformer, true, nil)
+		create := createPerformer && s.createsFromMedia("Image", image.ID, image.Rating, image.Organized)
+		identities, err := s.identifyImage(string(image.ID), create, true, nil)
 		if err != nil {
 			log.Warnf("Failed to identify image %s: %v", image.ID, err)
 			failureCount++
+			summary.addFailure(string(image.ID), err)
+			s.itemFailed("image", image.ID, err)
 		} else {
 			successCount++
+			summary.addImage(identities)
+			matches = append(matches, galleryImageMatches{image: image, performers: matchedPerformerIDs(identities)})
+			s.markGalleryImage(galleryID, image.ID)
 		}
 	}
 
-	log.Progress(1.0)
+	s.reportProgress(1.0)
 	log.Infof("Gallery identification complete: %d succeeded, %d failed", successCount, failureCount)
+	summary.PerformersCreated = int(s.summary.performersCreated.Load() - createdBefore)
+	summary.Complete = failureCount == 0 && summary.ImagesRemaining == 0
+	summary.finish()
 
-	return nil
+	if err := s.updateGalleryCompletionStatus(gallery, summary); err != nil {
+		log.Warnf("Gallery completion status update failed: %v", err)
+	}
+
+	// Step 4: Flag images that conflict with the gallery's dominant performers
+	if err := s.reviewGalleryConsistency(gallery, matches); err != nil {
+		log.Warnf("Gallery consistency review failed: %v", err)
+	}
+
+	// Step 5: Suggest or set a title naming the dominant performers
+	if err := s.applyGalleryTitle(gallery, matches); err != nil {
+		log.Warnf("Gallery title update failed: %v", err)
+	}
+
+	// Step 6: Record the summary in the gallery's details
+	if err := s.writeGallerySummary(gallery, summary); err != nil {
+		log.Warnf("Gallery summary update failed: %v", err)
+	}
+
+	return summary, nil
 }
 
 // identifyImages performs batch identification of images
 func (s *Service) identifyImages(newOnly bool, limit int) error {
-	if s.stopping {
+	if s.stopped() {
 		return fmt.Errorf("operation cancelled")
 	}
 
-	mode := "all images"
+	mode, task := "all images", "identifyImagesAll"
 	if newOnly {
-		mode = "unscanned images only"
+		mode, task = "unscanned images only", "identifyImagesNew"
 	}
 	log.Infof("Starting batch image identification (%s, limit=%d)", mode, limit)
 
@@ -874,38 +1288,44 @@ func (s *Service) identifyImages(newOnly bool, limit int) error {
 	}
 
 	batchSize := s.config.MaxBatchSize
-	page := 0
+	resumed := s.resumePoint(task)
+	page := resumed.Page
+	lastID := resumed.LastID // Batches continue after the last image processed
 	total := 0
 	processedCount := 0
 	successCount := 0
 	failureCount := 0
+	budget := s.newErrorBudget()
 
 	for {
-		if s.stopping {
+		if s.stopped() {
 			return fmt.Errorf("operation cancelled")
 		}
 
 		page++
 
 		// Build query based on mode
-		var filter *stash.ImageFilterType
+		var excludeTagIDs []graphql.ID
 		if newOnly {
 			// Only images without scanned tag
-			tagsFilter := stash.HierarchicalMultiCriterionInput{
-				Value:    []string{string(scannedTagID)},
-				Modifier: stash.CriterionModifierExcludes,
-			}
+			excludeTagIDs = append(excludeTagIDs, scannedTagID)
+		}
+		var filter *stash.ImageFilterType
+		if tags := s.excludeTagsCriterion(excludeTagIDs...); tags != nil {
 			filter = &stash.ImageFilterType{
-				Tags: &tagsFilter,
+				Tags: tags,
 			}
 		}
+		filter = s.scopeImages(s.excludeIgnoredImages(filter))
 
-		images, count, err := stash.FindImages(s.graphqlClient, filter, page, batchSize)
+		// Apply pending writes so the query sees updated tags
+		s.flushWrites()
+		images, count, err := stash.FindImagesAfter(s.graphqlClient, filter, lastID, batchSize)
 		if err != nil {
 			return fmt.Errorf("failed to query images: %w", err)
 		}
 
-		if page == 1 {
+		if total == 0 {
 			total = count
 
 			// Apply limit if specified
@@ -925,7 +1345,7 @@ func (s *Service) identifyImages(newOnly bool, limit int) error {
 
 		// Process each image in the batch
 		for _, image := range images {
-			if s.stopping {
+			if s.stopped() {
 				return fmt.Errorf("operation cancelled")
 			}
 
@@ -936,33 +1356,49 @@ func (s *Service) identifyImages(newOnly bool, limit int) error {
 			}
 
 			processedCount++
+			lastID, _ = strconv.Atoi(string(image.ID))
 			progress := float64(processedCount) / float64(total)
-			log.Progress(progress)
+			s.reportProgress(progress)
 
 			log.Infof("Processing image %d/%d: %s", processedCount, total, image.ID)
 
+			if s.tooSmallForFaces(&image) {
+				s.markImageWithoutFaces(string(image.ID))
+				successCount++
+				budget.record(nil)
+				continue
+			}
+
 			// Batch processing always associates performers
 			_, err := s.identifyImage(string(image.ID), false, true, nil)
 			if err != nil {
 				log.Warnf("Failed to identify image %s: %v", image.ID, err)
 				failureCount++
+				s.itemFailed("image", image.ID, err)
 			} else {
 				successCount++
 			}
+
+			if budgetErr := budget.record(err); budgetErr != nil {
+				log.Errorf("Batch identification: %d processed, %d succeeded, %d failed", processedCount, successCount, failureCount)
+				return budgetErr
+			}
 		}
 
+		s.checkpoint(store.Checkpoint{Task: task, Page: page, LastID: lastID, Processed: processedCount})
+
 		// Break outer loop if limit reached
 		if limit > 0 && processedCount >= limit {
 			break
 		}
+	}
 
-		// Apply cooldown after processing batch
-		if len(images) == batchSize && processedCount < total {
-			s.applyCooldown()
-		}
+	// A run stopped by its limit keeps its checkpoint for the next resume
+	if limit == 0 || processedCount < limit {
+		s.clearCheckpoint(task)
 	}
 
-	log.Progress(1.0)
+	s.reportProgress(1.0)
 	log.Infof("Batch identification complete: %d processed, %d succeeded, %d failed", processedCount, successCount, failureCount)
 
 	return nil
@@ -970,7 +1406,7 @@ func (s *Service) identifyImages(newOnly bool, limit int) error {
 
 // resetUnmatchedImages removes scanned tags from unmatched images
 func (s *Service) resetUnmatchedImages(limit int) error {
-	if s.stopping {
+	if s.stopped() {
 		return fmt.Errorf("operation cancelled")
 	}
 
@@ -987,11 +1423,6 @@ func (s *Service) resetUnmatchedImages(limit int) error {
 		return fmt.Errorf("failed to get matched tag: %w", err)
 	}
 
-	var perPage int = -1
-	if limit > 0 {
-		perPage = limit
-	}
-
 	log.Infof("Searching for unmatched images (scanned but not matched)")
 
 	// Step 2: Find images with scanned tag but no matched tag
@@ -1003,50 +1434,71 @@ func (s *Service) resetUnmatchedImages(limit int) error {
 	input := stash.ImageFilterType{
 		Tags: &tagFilter,
 	}
-	images, count, err := stash.FindImages(s.graphqlClient, &input, 1, perPage)
+
+	// Step 3: Collect all matching image IDs before modifying tags, since
+	// removing the scanned tag shifts the filtered result pages
+	var unmatchedImages []stash.Image
+	count := 0
+	err = stash.FindAllImages(s.graphqlClient, &input, stash.DefaultPageSize, func(images []stash.Image, total int) error {
+		if s.stopped() {
+			return fmt.Errorf("operation cancelled")
+		}
+		count = total
+		for _, image := range images {
+			if limit > 0 && len(unmatchedImages) >= limit {
+				return stash.ErrStopPaging
+			}
+			unmatchedImages = append(unmatchedImages, image)
+		}
+		return nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to query images: %w", err)
 	}
 
-	log.Infof("Found %d scanned, unmatched images", count)
-
-	if len(images) == 0 {
+	if len(unmatchedImages) == 0 {
 		log.Info("No unmatched images found")
 		return nil
 	}
 
-	total := len(images)
-
-	// Apply limit if specified
-	if perPage > 0 && perPage < total {
-		log.Infof("Found %d unmatched images out of %d", total, count)
+	if len(unmatchedImages) < count {
+		log.Infof("Found %d unmatched images, limiting to %d", count, len(unmatchedImages))
 	} else {
-		log.Infof("Found %d unmatched images to reset", total)
+		log.Infof("Found %d unmatched images to reset", count)
 	}
 
-	// Step 4: Remove scanned tag from unmatched images
+	// Step 4: Back up the images' tags, so the reset can be undone
+	err = s.backupState("resetUnmatchedImages", func(backup *store.BackupWriter) error {
+		return backupImages(backup, unmatchedImages, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Step 5: Remove scanned tag from unmatched images
 	resetCount := 0
-	for i, image := range images {
-		if s.stopping {
+	for i := range unmatchedImages {
+		if s.stopped() {
 			return fmt.Errorf("operation cancelled")
 		}
 
+		image := &unmatchedImages[i]
 		imageID := image.ID
 
-		progress := float64(i) / float64(len(images))
-		log.Progress(progress)
+		progress := float64(i) / float64(len(unmatchedImages))
+		s.reportProgress(progress)
 
-		err := stash.RemoveTagFromImage(s.graphqlClient, imageID, scannedTagID)
+		err := stash.UpdateImageTagsIfChanged(s.graphqlClient, image, nil, []graphql.ID{scannedTagID})
 		if err != nil {
 			log.Warnf("Failed to remove tag from image %s: %v", imageID, err)
 			continue
 		}
 
 		resetCount++
-		log.Debugf("Reset image %s (%d/%d)", imageID, i+1, len(images))
+		log.Debugf("Reset image %s (%d/%d)", imageID, i+1, len(unmatchedImages))
 	}
 
-	log.Progress(1.0)
+	s.reportProgress(1.0)
 	log.Infof("Reset complete: %d images processed", resetCount)
 
 	return nil
@@ -1056,9 +1508,13 @@ func (s *Service) resetUnmatchedImages(limit int) error {
 // Helper Functions
 // ============================================================================
 
-// updateImageCompletionStatus updates the completion status tag for an image
-// based on how many faces were detected vs matched
-func (s *Service) updateImageCompletionStatus(imageID graphql.ID, facesDetected int, facesMatched int) error {
+// updateImageCompletionStatus updates the completion status tag for an image,
+// applying any extra status tags to add or remove in the same update
+// based on how many faces were detected vs matched. Any extraTags are added in
+// the same update, which is skipped entirely if the image already has them.
+func (s *Service) updateImageCompletionStatus(image *stash.Image, facesDetected int, facesMatched int, extraTags []graphql.ID, extraRemoveTags []graphql.ID) error {
+	imageID := image.ID
+
 	var completionTag string
 	var removeTag string
 
@@ -1080,10 +1536,10 @@ func (s *Service) updateImageCompletionStatus(imageID graphql.ID, facesDetected
 	}
 
 	// Remove the opposite status tag if it exists
+	removeTags := append([]graphql.ID{}, extraRemoveTags...)
 	removeTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, removeTag, removeTag)
 	if err == nil {
-		// Try to remove, but don't fail if it doesn't exist
-		stash.RemoveTagFromImage(s.graphqlClient, imageID, removeTagID)
+		removeTags = append(removeTags, removeTagID)
 	}
 
 	// Add the appropriate completion tag
@@ -1092,7 +1548,8 @@ func (s *Service) updateImageCompletionStatus(imageID graphql.ID, facesDetected
 		return fmt.Errorf("failed to get/create completion tag: %w", err)
 	}
 
-	err = stash.AddTagToImage(s.graphqlClient, imageID, completionTagID)
+	addTags := append(append([]graphql.ID{}, extraTags...), completionTagID)
+	err = stash.UpdateImageTagsIfChanged(s.graphqlClient, image, addTags, removeTags)
 	if err != nil {
 		return fmt.Errorf("failed to add completion tag: %w", err)
 	}
@@ -1101,15 +1558,14 @@ func (s *Service) updateImageCompletionStatus(imageID graphql.ID, facesDetected
 	return nil
 }
 
-// convertToJPEG opens an image from disk and ensures it’s in JPEG format.
+// convertToJPEG opens an image from disk and decodes it as sRGB.
 func (s *Service) convertToJPEG(imagePath string) (image.Image, error) {
-	file, err := os.Open(imagePath)
+	data, err := os.ReadFile(imagePath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	img, _, err := image.Decode(file)
+	img, _, err := utils.DecodeImageSRGB(data)
 	if err != nil {
 		return nil, err
 	}
@@ -1149,7 +1605,7 @@ func (s *Service) extractBoxImage(img image.Image, box compreface.BoundingBox, p
 // imageToBase64 encodes the image to JPEG and Base64.
 func (s *Service) convertImageToBase64(img image.Image) (string, error) {
 	buf := new(bytes.Buffer)
-	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 90}); err != nil {
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: s.jpegQuality(90)}); err != nil {
 		return "", err
 	}
 	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
@@ -1178,7 +1634,7 @@ func (s *Service) extractBase64FaceImage(imagePath string, box compreface.Boundi
 // cropFaceBytes extracts a face region from image bytes and returns JPEG bytes.
 // Used for submitting individual faces to Compreface from multi-face images.
 func (s *Service) cropFaceBytes(imageBytes []byte, box compreface.BoundingBox, padding int) ([]byte, error) {
-	img, _, err := image.Decode(bytes.NewReader(imageBytes))
+	img, _, err := utils.DecodeImageSRGB(imageBytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
@@ -1189,7 +1645,7 @@ func (s *Service) cropFaceBytes(imageBytes []byte, box compreface.BoundingBox, p
 	}
 
 	var buf bytes.Buffer
-	if err := jpeg.Encode(&buf, cropped, &jpeg.Options{Quality: 90}); err != nil {
+	if err := jpeg.Encode(&buf, cropped, &jpeg.Options{Quality: s.jpegQuality(90)}); err != nil {
 		return nil, fmt.Errorf("failed to encode cropped face: %w", err)
 	}
 
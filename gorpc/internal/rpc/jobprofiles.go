@@ -0,0 +1,68 @@
+package rpc
+
+import (
+	"path/filepath"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// jobProfilesFile is the job profile file's path under the plugin directory
+const jobProfilesFile = "data/job_profiles.jsonl"
+
+// openJobProfiles opens the remembered Vision job profiles. Failures are
+// logged and leave retries unrecorded.
+func (s *Service) openJobProfiles() func() {
+	path := filepath.Join(s.serverConnection.PluginDir, filepath.FromSlash(jobProfilesFile))
+	profiles, err := store.OpenJobProfiles(path)
+	if err != nil {
+		log.Warnf("Vision job profiles disabled: %v", err)
+		return func() {}
+	}
+
+	s.jobProfiles = profiles
+	return func() {
+		s.jobProfiles = nil
+		if err := profiles.Close(); err != nil {
+			log.Warnf("Failed to close Vision job profiles: %v", err)
+		}
+	}
+}
+
+// applyJobProfile replaces parameters with those a previous retry succeeded
+// with for source. Returns false when none are remembered.
+func (s *Service) applyJobProfile(source string, parameters *vision.FacesParameters) bool {
+	if s.jobProfiles == nil {
+		return false
+	}
+	profile, ok := s.jobProfiles.Get(source)
+	if !ok {
+		return false
+	}
+
+	parameters.SamplingInterval = profile.SamplingInterval
+	parameters.MaxFaces = profile.MaxFaces
+	if parameters.Enhancement != nil && !profile.Enhancement {
+		enhancement := *parameters.Enhancement
+		enhancement.Enabled = false
+		parameters.Enhancement = &enhancement
+	}
+	return true
+}
+
+// rememberJobProfile records the parameters a retry succeeded with for source
+func (s *Service) rememberJobProfile(source string, parameters vision.FacesParameters) {
+	if s.jobProfiles == nil {
+		return
+	}
+	profile := store.JobProfile{
+		Source:           source,
+		SamplingInterval: parameters.SamplingInterval,
+		MaxFaces:         parameters.MaxFaces,
+		Enhancement:      parameters.Enhancement != nil && parameters.Enhancement.Enabled,
+	}
+	if err := s.jobProfiles.Put(profile); err != nil {
+		log.Warnf("Failed to remember Vision job profile for %s: %v", source, err)
+	}
+}
@@ -0,0 +1,305 @@
+package rpc
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Subject Mapping Export and Import
+// ============================================================================
+//
+// exportSubjectMappings writes every Compreface subject, the performer it
+// maps to and its examples to a JSON file (see store.MappingWriter).
+// importSubjectMappings replays such a file against the current Stash and
+// Compreface, e.g. after moving to a new Compreface instance or resetting a
+// database:
+//
+//   - the performer is the exported performer ID when that performer still
+//     has the exported name or already carries the subject, otherwise the
+//     performer found by the subject or exported name, otherwise a new
+//     performer with the exported name; the subject is added as an alias
+//     where missing
+//   - a subject Compreface no longer has is recreated from the exported
+//     examples; existing subjects are left as they are
+//
+// ============================================================================
+
+// exportsDir is the default export directory under the plugin directory
+const exportsDir = "data/exports"
+
+// mappingReport counts the work done importing subject mappings
+type mappingReport struct {
+	mappings   int
+	linked     int
+	aliased    int
+	created    int
+	subjects   int
+	examples   int
+	unresolved []string
+}
+
+// unresolvedMapping records a mapping that could not be imported
+func (r *mappingReport) unresolvedMapping(subject string, reason string) {
+	r.unresolved = append(r.unresolved, subject)
+	log.Warnf("Subject %s: cannot import mapping: %s", subject, reason)
+}
+
+// String summarizes the report
+func (r *mappingReport) String() string {
+	summary := fmt.Sprintf("Imported subject mappings: %s read, %s performers linked (%s aliases added, %s created), %s subjects recreated with %s examples, %s unresolved",
+		formatCount(r.mappings), formatCount(r.linked), formatCount(r.aliased), formatCount(r.created),
+		formatCount(r.subjects), formatCount(r.examples), formatCount(len(r.unresolved)))
+	if len(r.unresolved) > 0 {
+		summary += " (" + strings.Join(r.unresolved, ", ") + ")"
+	}
+	return summary
+}
+
+// mappingPath resolves an export path relative to the plugin directory
+func (s *Service) mappingPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(s.serverConnection.PluginDir, path)
+}
+
+// exportSubjectMappings writes every subject with its performer and examples
+// to path, by default a new file in data/exports. Examples are left out when
+// includeExamples is false or in anonymization mode.
+func (s *Service) exportSubjectMappings(path string, includeExamples bool) (string, error) {
+	if s.stopped() {
+		return "", fmt.Errorf("operation cancelled")
+	}
+	if path == "" {
+		path = filepath.Join(filepath.FromSlash(exportsDir), "subject-mappings-"+time.Now().Format("20060102-150405")+".json")
+	}
+	includeExamples = includeExamples && !s.config.AnonymizationMode
+
+	subjects, err := s.comprefaceClient.ListSubjects()
+	if err != nil {
+		return "", fmt.Errorf("failed to list subjects: %w", err)
+	}
+	sort.Strings(subjects)
+
+	writer, err := store.CreateMappingExport(s.mappingPath(path))
+	if err != nil {
+		return "", err
+	}
+
+	linked, examples := 0, 0
+	for i, subject := range subjects {
+		if s.stopped() {
+			writer.Discard()
+			return "", fmt.Errorf("operation cancelled")
+		}
+		s.reportProgress(float64(i) / float64(len(subjects)))
+
+		mapping, err := s.subjectMapping(subject, includeExamples)
+		if err == nil {
+			err = writer.Add(mapping)
+		}
+		if err != nil {
+			writer.Discard()
+			return "", err
+		}
+		if mapping.PerformerID != "" {
+			linked++
+		}
+		examples += len(mapping.Examples)
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	s.reportProgress(1.0)
+	summary := fmt.Sprintf("Exported %s subject mappings (%s with a performer, %s examples) to %s",
+		formatCount(writer.Count()), formatCount(linked), formatCount(examples), writer.Path())
+	log.Info(summary)
+	return summary, nil
+}
+
+// subjectMapping collects a subject's performer and examples
+func (s *Service) subjectMapping(subject string, includeExamples bool) (store.SubjectMapping, error) {
+	mapping := store.SubjectMapping{Subject: subject}
+
+	performerID, err := stash.FindPerformerBySubjectName(s.graphqlClient, subject)
+	if err != nil {
+		return mapping, fmt.Errorf("failed to find performer for subject %s: %w", subject, err)
+	}
+	if performerID != "" {
+		performer, err := stash.GetPerformerByID(s.graphqlClient, performerID)
+		if err != nil {
+			return mapping, fmt.Errorf("failed to get performer %s: %w", performerID, err)
+		}
+		mapping.PerformerID, mapping.PerformerName = string(performer.ID), performer.Name
+	}
+
+	faces, err := s.comprefaceClient.ListFaces(subject)
+	if err != nil {
+		return mapping, fmt.Errorf("failed to list faces of %s: %w", subject, err)
+	}
+	for _, face := range faces {
+		mapping.Faces = append(mapping.Faces, face.ImageID)
+		if !includeExamples {
+			continue
+		}
+		data, err := s.comprefaceClient.DownloadFaceImage(face.ImageID)
+		if err != nil {
+			log.Warnf("Failed to download example %s of %s, exporting its image ID only: %v", face.ImageID, subject, err)
+			continue
+		}
+		mapping.Examples = append(mapping.Examples, data)
+	}
+	return mapping, nil
+}
+
+// importSubjectMappings links the performers and recreates the missing
+// subjects of an export
+func (s *Service) importSubjectMappings(path string) (string, error) {
+	if s.stopped() {
+		return "", fmt.Errorf("operation cancelled")
+	}
+
+	export, err := store.ReadMappingExport(s.mappingPath(path))
+	if err != nil {
+		return "", err
+	}
+	log.Infof("Importing %d subject mappings exported %s", len(export.Mappings), export.Exported.Format(time.RFC3339))
+
+	report := &mappingReport{}
+	for i, mapping := range export.Mappings {
+		if s.stopped() {
+			return "", fmt.Errorf("operation cancelled")
+		}
+		s.reportProgress(float64(i) / float64(len(export.Mappings)))
+		report.mappings++
+
+		if err := s.importSubject(mapping, report); err != nil {
+			report.unresolvedMapping(mapping.Subject, err.Error())
+			continue
+		}
+		if mapping.PerformerID == "" && mapping.PerformerName == "" {
+			continue // Exported without a performer
+		}
+		if err := s.importPerformerLink(mapping, report); err != nil {
+			report.unresolvedMapping(mapping.Subject, err.Error())
+		}
+	}
+
+	s.reportProgress(1.0)
+	summary := report.String()
+	log.Info(summary)
+	return summary, nil
+}
+
+// importSubject recreates a subject Compreface no longer has from its
+// exported examples
+func (s *Service) importSubject(mapping store.SubjectMapping, report *mappingReport) error {
+	existing, err := s.comprefaceClient.ListFaces(mapping.Subject)
+	if err != nil {
+		return fmt.Errorf("failed to list faces: %w", err)
+	}
+	if len(existing) > 0 {
+		return nil
+	}
+	if len(mapping.Examples) == 0 {
+		return fmt.Errorf("subject is missing and no examples were exported")
+	}
+
+	for _, example := range mapping.Examples {
+		if _, err := s.comprefaceClient.AddSubjectFromBytes(mapping.Subject, example, "face.jpg"); err != nil {
+			return fmt.Errorf("failed to add example: %w", err)
+		}
+		report.examples++
+	}
+	log.Infof("Recreated subject %s with %d examples", mapping.Subject, len(mapping.Examples))
+	report.subjects++
+	return nil
+}
+
+// importPerformerLink finds or creates the performer of a mapping and makes
+// sure it carries the subject as an alias
+func (s *Service) importPerformerLink(mapping store.SubjectMapping, report *mappingReport) error {
+	performer, err := s.mappedPerformer(mapping)
+	if err != nil {
+		return err
+	}
+
+	if performer == nil {
+		if mapping.PerformerName == "" {
+			return fmt.Errorf("performer %s no longer exists", mapping.PerformerID)
+		}
+		performerID, err := stash.CreatePerformer(s.graphqlClient, stash.PerformerSubject{
+			Name:    mapping.PerformerName,
+			Aliases: []string{mapping.Subject},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create performer: %w", err)
+		}
+		s.performerCreated(performerID)
+		log.Infof("Subject %s: created performer %s (%s)", mapping.Subject, mapping.PerformerName, performerID)
+		report.created++
+		report.linked++
+		return nil
+	}
+
+	if performer.Name != mapping.Subject && !slices.Contains(performer.AliasList, mapping.Subject) {
+		input := stash.PerformerUpdateInput{
+			ID:        string(performer.ID),
+			AliasList: stash.DedupeAliases(append(performer.AliasList, mapping.Subject)),
+		}
+		if err := stash.UpdatePerformer(s.graphqlClient, performer.ID, input); err != nil {
+			return fmt.Errorf("failed to add alias to performer %s: %w", performer.ID, err)
+		}
+		log.Infof("Subject %s: added alias to performer %s (%s)", mapping.Subject, performer.Name, performer.ID)
+		report.aliased++
+	}
+	report.linked++
+	return nil
+}
+
+// mappedPerformer returns the performer a mapping refers to, or nil when
+// none is found. The exported ID is only trusted while its performer keeps
+// the exported name or carries the subject, since IDs are reissued after a
+// database reset.
+func (s *Service) mappedPerformer(mapping store.SubjectMapping) (*stash.Performer, error) {
+	if mapping.PerformerID != "" {
+		performer, err := stash.GetPerformerByID(s.graphqlClient, graphql.ID(mapping.PerformerID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get performer %s: %w", mapping.PerformerID, err)
+		}
+		if performer.ID != "" && (performer.Name == mapping.PerformerName || slices.Contains(performer.AliasList, mapping.Subject)) {
+			return performer, nil
+		}
+	}
+
+	for _, name := range []string{mapping.Subject, mapping.PerformerName} {
+		if name == "" {
+			continue
+		}
+		performerID, err := stash.FindPerformerBySubjectName(s.graphqlClient, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find performer %s: %w", name, err)
+		}
+		if performerID == "" {
+			continue
+		}
+		performer, err := stash.GetPerformerByID(s.graphqlClient, performerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get performer %s: %w", performerID, err)
+		}
+		return performer, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,103 @@
+package rpc
+
+import (
+	"fmt"
+	"sort"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// ============================================================================
+// Scene Markers
+// ============================================================================
+//
+// With createSceneMarkers enabled, each recognized performer gets a scene
+// marker per appearance: detections of the performer's face clusters closer
+// than markerMinGap seconds (at least two sampling intervals) apart are
+// merged into one span, so a performer on screen throughout a scene does not
+// flood it with markers. Markers carry
+// the performer's name as title and tag, under the SceneMarkerTagName primary
+// tag. Markers already on the scene are not created again on a rescan.
+//
+// ============================================================================
+
+// createSceneMarkers creates a marker for every appearance of each performer
+// in performerFaces, merging detections up to maxGap seconds, or the
+// configured minimum marker gap when wider, apart
+func (s *Service) createSceneMarkers(sceneID graphql.ID, performerFaces map[graphql.ID][]vision.VisionFace, maxGap float64) error {
+	maxGap = max(maxGap, s.config.MarkerMinGap)
+
+	markerTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.SceneMarkerTagName, "Compreface Face")
+	if err != nil {
+		return fmt.Errorf("failed to get marker tag: %w", err)
+	}
+
+	markers, err := stash.FindSceneMarkers(s.graphqlClient, sceneID)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool, len(markers))
+	for _, marker := range markers {
+		existing[marker.Title+"@"+vision.FrameKey(marker.Seconds)] = true
+	}
+
+	performerIDs := make([]graphql.ID, 0, len(performerFaces))
+	for performerID := range performerFaces {
+		performerIDs = append(performerIDs, performerID)
+	}
+	sort.Slice(performerIDs, func(i, j int) bool { return performerIDs[i] < performerIDs[j] })
+
+	created := 0
+	for _, performerID := range performerIDs {
+		performer, err := stash.GetPerformerByID(s.graphqlClient, performerID)
+		if err != nil {
+			log.Warnf("Scene %s: failed to get performer %s for markers: %v", sceneID, performerID, err)
+			continue
+		}
+		performerTagID, err := stash.GetOrCreateFlatTag(s.graphqlClient, s.tagCache, performer.Name)
+		if err != nil {
+			log.Warnf("Scene %s: failed to get tag for performer %s: %v", sceneID, performer.Name, err)
+			continue
+		}
+
+		// Clusters matched to the same performer are merged into one timeline
+		var merged vision.VisionFace
+		for _, face := range performerFaces[performerID] {
+			merged.Detections = append(merged.Detections, face.Detections...)
+			if len(face.Detections) == 0 {
+				merged.Detections = append(merged.Detections, face.RepresentativeDetection)
+			}
+		}
+
+		for _, appearance := range merged.Appearances(maxGap) {
+			if existing[performer.Name+"@"+vision.FrameKey(appearance.Start)] {
+				continue
+			}
+			input := stash.SceneMarkerCreateInput{
+				Title:        performer.Name,
+				Seconds:      appearance.Start,
+				SceneID:      sceneID,
+				PrimaryTagID: markerTagID,
+				TagIDs:       []graphql.ID{performerTagID},
+			}
+			if appearance.End > appearance.Start {
+				end := appearance.End
+				input.EndSeconds = &end
+			}
+			if _, err := stash.CreateSceneMarker(s.graphqlClient, input); err != nil {
+				log.Warnf("Scene %s: failed to create marker for %s at %.2fs: %v", sceneID, performer.Name, appearance.Start, err)
+				continue
+			}
+			created++
+		}
+	}
+
+	if created > 0 {
+		log.Infof("Scene %s: Created %d performer markers", sceneID, created)
+	}
+	return nil
+}
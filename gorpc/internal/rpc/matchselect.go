@@ -0,0 +1,140 @@
+package rpc
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Match Selection
+// ============================================================================
+//
+// Compreface ranks a face's subjects by similarity alone, and the best one
+// used to be taken even when the runner-up was nearly as similar. When other
+// distinct subjects above minSimilarity are within matchMargin of the best
+// (compreface.CloseSubjects; subjects below it never count), these
+// contenders are ranked by support instead: their similarity, plus a bonus
+// for the subject the face's Vision embedding also matches, plus a bonus for
+// subjects backed by more examples, plus a small bonus for subjects whose
+// performer is named in the scene's on-screen text (overlays.go). A winner
+// whose support does not lead the runner-up's by matchMargin is ambiguous;
+// tasks that queue new faces for review queue it there instead of
+// associating it.
+//
+// ============================================================================
+
+const (
+	// matchCandidates is the number of subjects requested per recognized face
+	matchCandidates = 3
+	// embeddingAgreementBonus is the support added to the subject the face's
+	// Vision embedding also matches
+	embeddingAgreementBonus = 0.05
+	// exampleSupportBonus is the support added to a subject with
+	// exampleSupportCap or more examples; fewer examples add proportionally less
+	exampleSupportBonus = 0.03
+	exampleSupportCap   = 10
+)
+
+// matchSelection is the subject chosen for a face
+type matchSelection struct {
+	subject    compreface.FaceRecognition
+	ambiguous  bool     // No candidate is clearly better supported
+	candidates []string // Close candidates, best supported first, when tie-breaking was needed
+}
+
+// subjectExampleCounts caches the number of examples per subject for a task
+type subjectExampleCounts struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// selectMatch chooses among the subjects Compreface returned for a face
+// (highest similarity first). embedding is the face's Vision embedding, nil
+// when unavailable; overlaySubjects are the subjects named on screen, nil
+// when none. Returns false when no subject reaches minSimilarity.
+func (s *Service) selectMatch(subjects []compreface.FaceRecognition, embedding []float64, overlaySubjects map[string]string) (matchSelection, bool) {
+	contenders := compreface.CloseSubjects(subjects, s.config.MinSimilarity, s.config.MatchMargin)
+	if len(contenders) == 0 {
+		return matchSelection{}, false
+	}
+
+	best := contenders[0]
+	if len(contenders) == 1 {
+		return matchSelection{subject: best}, true
+	}
+
+	embeddingSubject := s.embeddingSubject(embedding)
+	support := map[string]float64{}
+	for _, candidate := range contenders {
+		score := candidate.Similarity
+		if candidate.Subject == embeddingSubject {
+			score += embeddingAgreementBonus
+		}
+		if name, ok := overlaySubjects[candidate.Subject]; ok {
+			score += overlayNameBonus
+			log.Debugf("Subject '%s' is named on screen as %q", candidate.Subject, name)
+		}
+		examples := math.Min(float64(s.subjectExampleCount(candidate.Subject)), exampleSupportCap)
+		score += exampleSupportBonus * examples / exampleSupportCap
+		support[candidate.Subject] = score
+	}
+	sort.SliceStable(contenders, func(i, j int) bool {
+		return support[contenders[i].Subject] > support[contenders[j].Subject]
+	})
+
+	selection := matchSelection{
+		subject:   contenders[0],
+		ambiguous: support[contenders[0].Subject]-support[contenders[1].Subject] < s.config.MatchMargin,
+	}
+	for _, candidate := range contenders {
+		selection.candidates = append(selection.candidates, candidate.Subject)
+	}
+
+	if selection.ambiguous {
+		log.Infof("Ambiguous match between %v (support %.3f vs %.3f)",
+			selection.candidates, support[contenders[0].Subject], support[contenders[1].Subject])
+	} else if selection.subject.Subject != best.Subject {
+		log.Infof("Chose subject '%s' (similarity %.2f) over '%s' (similarity %.2f) on embedding, example and on-screen name support",
+			selection.subject.Subject, selection.subject.Similarity, best.Subject, best.Similarity)
+	}
+	return selection, true
+}
+
+// embeddingSubject returns the best subject for a Vision embedding when
+// embedding recognition is enabled, or "" when there is none
+func (s *Service) embeddingSubject(embedding []float64) string {
+	if !s.config.EnableEmbeddingRecognition || len(embedding) != 512 {
+		return ""
+	}
+	best, err := s.bestEmbeddingMatch(embedding)
+	if err != nil {
+		log.Debugf("Embedding recognition for tie-breaking failed: %v", err)
+		return ""
+	}
+	return best.Subject
+}
+
+// subjectExampleCount returns the number of examples stored for a subject,
+// listing them once per task. Returns 0 when they cannot be listed.
+func (s *Service) subjectExampleCount(subject string) int {
+	s.exampleCounts.mu.Lock()
+	defer s.exampleCounts.mu.Unlock()
+
+	if count, ok := s.exampleCounts.counts[subject]; ok {
+		return count
+	}
+	faces, err := s.comprefaceClient.ListFaces(subject)
+	if err != nil {
+		log.Debugf("Failed to list examples of %s: %v", subject, err)
+		return 0
+	}
+	if s.exampleCounts.counts == nil {
+		s.exampleCounts.counts = map[string]int{}
+	}
+	s.exampleCounts.counts[subject] = len(faces)
+	return len(faces)
+}
@@ -0,0 +1,367 @@
+package rpc
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Duplicate Performer Merging
+// ============================================================================
+//
+// Batch runs can create several "Person ..." performers for one person when
+// a face is first seen at a poor angle. Each generated subject's first
+// example is verified against the others' examples; subjects at or above
+// MergeSimilarity are clustered transitively. Verification is pairwise, so
+// use the limit argument on large libraries.
+//
+// Each cluster is merged into one performer: the only user-named performer,
+// or else the oldest (lowest ID). The other subjects are renamed into its
+// subject, which merges their faces in Compreface, and the other performers'
+// images, scenes, galleries and non-generated names are moved onto it before
+// they are deleted. Clusters with several user-named performers are reported
+// and left alone.
+//
+// ============================================================================
+
+// mergeCandidate is a generated subject linked to a performer, with the
+// example its similarity to other subjects is verified with
+type mergeCandidate struct {
+	subject   string
+	performer *stash.Performer
+	imageID   string // Compreface image ID of the subject's first example
+	example   []byte
+}
+
+// mergeDuplicatePerformers clusters generated subjects that verify as the
+// same person and merges each cluster's subjects and performers
+func (s *Service) mergeDuplicatePerformers(limit int) (string, error) {
+	if s.stopped() {
+		return "", fmt.Errorf("operation cancelled")
+	}
+
+	candidates, err := s.loadMergeCandidates(limit)
+	if err != nil {
+		return "", err
+	}
+	log.Infof("Verifying %d subjects against each other (threshold %.2f)", len(candidates), s.config.MergeSimilarity)
+
+	clusters, err := s.clusterMergeCandidates(candidates)
+	if err != nil {
+		return "", err
+	}
+
+	if len(clusters) > 0 {
+		err := s.backupState("mergeDuplicatePerformers", func(backup *store.BackupWriter) error {
+			return s.backupMergeClusters(backup, clusters)
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	merged, skipped := 0, 0
+	for _, cluster := range clusters {
+		if s.stopped() {
+			return "", fmt.Errorf("operation cancelled")
+		}
+		count, err := s.mergeCluster(cluster)
+		if err != nil {
+			log.Warnf("Failed to merge subjects %s: %v", clusterSubjects(cluster), err)
+			skipped++
+			continue
+		}
+		merged += count
+	}
+
+	summary := fmt.Sprintf("Merged %s duplicate performers in %s clusters of %s subjects checked",
+		formatCount(merged), formatCount(len(clusters)-skipped), formatCount(len(candidates)))
+	if skipped > 0 {
+		summary += fmt.Sprintf(" (%s clusters skipped)", formatCount(skipped))
+	}
+	log.Info(summary)
+	return summary, nil
+}
+
+// loadMergeCandidates loads the generated subjects linked to a performer,
+// with their first example, up to limit subjects
+func (s *Service) loadMergeCandidates(limit int) ([]mergeCandidate, error) {
+	subjects, err := s.comprefaceClient.ListSubjects()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subjects: %w", err)
+	}
+	sort.Strings(subjects)
+
+	var candidates []mergeCandidate
+	for _, subject := range subjects {
+		if s.stopped() {
+			return nil, fmt.Errorf("operation cancelled")
+		}
+		if limit > 0 && len(candidates) >= limit {
+			break
+		}
+		if !compreface.IsGeneratedSubjectName(subject) {
+			continue
+		}
+
+		performerID, err := stash.FindPerformerBySubjectName(s.graphqlClient, subject)
+		if err != nil {
+			return nil, err
+		}
+		if performerID == "" {
+			log.Debugf("Subject %s: no performer, skipping", subject)
+			continue
+		}
+		performer, err := stash.GetPerformerByID(s.graphqlClient, performerID)
+		if err != nil {
+			return nil, err
+		}
+
+		faces, err := s.comprefaceClient.ListFaces(subject)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list faces of %s: %w", subject, err)
+		}
+		if len(faces) == 0 {
+			log.Debugf("Subject %s: no examples, skipping", subject)
+			continue
+		}
+		example, err := s.comprefaceClient.DownloadFaceImage(faces[0].ImageID)
+		if err != nil {
+			log.Warnf("Subject %s: failed to download example: %v", subject, err)
+			continue
+		}
+
+		candidates = append(candidates, mergeCandidate{
+			subject:   subject,
+			performer: performer,
+			imageID:   faces[0].ImageID,
+			example:   example,
+		})
+	}
+	return candidates, nil
+}
+
+// clusterMergeCandidates groups candidates that verify as the same person,
+// directly or through other candidates. Only clusters of two or more are
+// returned.
+func (s *Service) clusterMergeCandidates(candidates []mergeCandidate) ([][]mergeCandidate, error) {
+	parent := make([]int, len(candidates))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	pairs := len(candidates) * (len(candidates) - 1) / 2
+	checked := 0
+	for i := range candidates {
+		for j := i + 1; j < len(candidates); j++ {
+			if s.stopped() {
+				return nil, fmt.Errorf("operation cancelled")
+			}
+			checked++
+			s.reportProgress(float64(checked) / float64(pairs))
+
+			if find(i) == find(j) {
+				continue
+			}
+			if candidates[i].performer.ID != candidates[j].performer.ID {
+				similarity, err := s.comprefaceClient.VerifyFaceFromBytes(candidates[i].imageID, candidates[j].example, "face.jpg")
+				if err != nil {
+					log.Warnf("Failed to verify %s against %s: %v", candidates[j].subject, candidates[i].subject, err)
+					continue
+				}
+				if similarity < s.config.MergeSimilarity {
+					continue
+				}
+				log.Infof("Subjects %s and %s match (similarity: %.2f)", candidates[i].subject, candidates[j].subject, similarity)
+			}
+			parent[find(j)] = find(i)
+		}
+	}
+
+	groups := map[int][]mergeCandidate{}
+	var roots []int
+	for i, candidate := range candidates {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], candidate)
+	}
+
+	var clusters [][]mergeCandidate
+	for _, root := range roots {
+		if len(groups[root]) > 1 {
+			clusters = append(clusters, groups[root])
+		}
+	}
+	return clusters, nil
+}
+
+// mergeCluster merges a cluster's subjects and performers into one. Returns
+// the number of performers merged away.
+func (s *Service) mergeCluster(cluster []mergeCandidate) (int, error) {
+	target, err := mergeTarget(cluster)
+	if err != nil {
+		return 0, err
+	}
+	targetSubject := compreface.FindPersonAlias(target)
+
+	var aliases []string
+	var birthdate string
+	merged := map[graphql.ID]bool{target.ID: true}
+	for _, member := range cluster {
+		if member.subject != targetSubject {
+			if err := s.comprefaceClient.RenameSubject(member.subject, targetSubject); err != nil {
+				return len(merged) - 1, fmt.Errorf("failed to merge subject %s: %w", member.subject, err)
+			}
+		}
+
+		performer := member.performer
+		if merged[performer.ID] {
+			continue
+		}
+		moved, err := stash.ReassignPerformer(s.graphqlClient, performer.ID, target.ID)
+		if err != nil {
+			return len(merged) - 1, fmt.Errorf("failed to reassign performer %s: %w", performer.ID, err)
+		}
+		if err := stash.DestroyPerformer(s.graphqlClient, performer.ID); err != nil {
+			return len(merged) - 1, fmt.Errorf("failed to delete performer %s: %w", performer.ID, err)
+		}
+		s.forgetPerformer(string(performer.ID))
+		merged[performer.ID] = true
+		log.Infof("Merged performer %s (%s) into %s (%s): %d items moved", performer.Name, performer.ID, target.Name, target.ID, moved)
+
+		for _, name := range append([]string{performer.Name}, performer.AliasList...) {
+			if !compreface.IsGeneratedSubjectName(name) && !strings.EqualFold(name, target.Name) {
+				aliases = append(aliases, name)
+			}
+		}
+		if birthdate == "" {
+			birthdate = performer.Birthdate
+		}
+	}
+
+	// Keep a merged performer's birthdate when the target has none
+	if target.Birthdate != "" {
+		birthdate = ""
+	}
+	if len(aliases) > 0 || birthdate != "" {
+		input := stash.PerformerUpdateInput{ID: string(target.ID)}
+		if len(aliases) > 0 {
+			input.AliasList = append(target.AliasList, aliases...)
+		}
+		if birthdate != "" {
+			input.Birthdate = &birthdate
+		}
+		if err := stash.UpdatePerformer(s.graphqlClient, target.ID, input); err != nil {
+			return len(merged) - 1, fmt.Errorf("failed to update performer %s: %w", target.ID, err)
+		}
+	}
+	return len(merged) - 1, nil
+}
+
+// backupMergeClusters backs up the performers and subjects of the clusters
+// about to be merged, then the items of those performers. Performers come
+// first so a restore knows their new IDs before it reassigns any item.
+func (s *Service) backupMergeClusters(backup *store.BackupWriter, clusters [][]mergeCandidate) error {
+	var performers []*stash.Performer
+	seen := map[graphql.ID]bool{}
+	for _, cluster := range clusters {
+		for _, member := range cluster {
+			if !seen[member.performer.ID] {
+				seen[member.performer.ID] = true
+				performers = append(performers, member.performer)
+			}
+		}
+	}
+
+	for _, performer := range performers {
+		if err := backupPerformer(backup, performer); err != nil {
+			return err
+		}
+	}
+	for _, cluster := range clusters {
+		target, err := mergeTarget(cluster)
+		if err != nil {
+			continue // Left alone by the merge
+		}
+		targetSubject := compreface.FindPersonAlias(target)
+		for _, member := range cluster {
+			if member.subject == targetSubject {
+				continue
+			}
+			if err := s.backupSubject(backup, member.subject, member.performer.ID, targetSubject, false); err != nil {
+				return err
+			}
+		}
+	}
+	items := map[string]bool{}
+	for _, performer := range performers {
+		if err := s.backupPerformerItems(backup, performer.ID, items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeTarget picks the performer a cluster is merged into: its only
+// user-named performer, or else the one with the lowest ID
+func mergeTarget(cluster []mergeCandidate) (*stash.Performer, error) {
+	var named, generated []*stash.Performer
+	seen := map[graphql.ID]bool{}
+	for _, member := range cluster {
+		performer := member.performer
+		if seen[performer.ID] {
+			continue
+		}
+		seen[performer.ID] = true
+		if compreface.IsGeneratedSubjectName(performer.Name) {
+			generated = append(generated, performer)
+		} else {
+			named = append(named, performer)
+		}
+	}
+
+	switch {
+	case len(named) == 1:
+		return named[0], nil
+	case len(named) > 1:
+		names := make([]string, len(named))
+		for i, performer := range named {
+			names[i] = performer.Name
+		}
+		return nil, fmt.Errorf("several named performers (%s)", strings.Join(names, ", "))
+	}
+
+	sort.Slice(generated, func(i, j int) bool {
+		a, _ := strconv.Atoi(string(generated[i].ID))
+		b, _ := strconv.Atoi(string(generated[j].ID))
+		return a < b
+	})
+	return generated[0], nil
+}
+
+// clusterSubjects lists a cluster's subjects for logging
+func clusterSubjects(cluster []mergeCandidate) string {
+	subjects := make([]string, len(cluster))
+	for i, member := range cluster {
+		subjects[i] = member.subject
+	}
+	return strings.Join(subjects, ", ")
+}
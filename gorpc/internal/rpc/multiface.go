@@ -0,0 +1,150 @@
+package rpc
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"math"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// ============================================================================
+// Multi-Face Crops
+// ============================================================================
+//
+// A face is cropped with padding before it is sent to Compreface, and now and
+// then a second face close by falls inside the crop. Compreface then returns
+// a result per face, in no particular order, and taking the first one could
+// recognize the neighbour instead. When a crop holds several faces, each is
+// cut out of the crop and recognized on its own. The face nearest the crop's
+// centre, which the crop was made for, is matched as usual. The extras are
+// matched to existing performers only, never created, and associated with
+// the item where the caller collects them.
+//
+// ============================================================================
+
+// recognizeFaceCrop recognizes the faces in a face crop, the face the crop
+// was made for first
+func (s *Service) recognizeFaceCrop(faceCrop []byte) ([]compreface.RecognitionResult, error) {
+	resp, err := s.comprefaceClient.RecognizeFacesFromBytes(faceCrop, "face.jpg")
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Result) <= 1 {
+		return resp.Result, nil
+	}
+
+	results := append([]compreface.RecognitionResult{}, resp.Result...)
+	primary := centralResult(results, faceCrop)
+	results[0], results[primary] = results[primary], results[0]
+	log.Infof("Face crop holds %d faces; recognizing each separately", len(results))
+
+	for i, result := range results {
+		subCrop, err := s.cropFaceBytes(faceCrop, result.Box, 0)
+		if err != nil {
+			log.Debugf("Failed to split face %d from crop: %v", i, err)
+			continue
+		}
+		subResp, err := s.comprefaceClient.RecognizeFacesFromBytes(subCrop, "face.jpg")
+		if errors.Is(err, compreface.ErrNoFaceFound) {
+			continue // Too tight a crop; keep the result from the whole crop
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(subResp.Result) > 0 {
+			box := result.Box
+			results[i] = subResp.Result[centralResult(subResp.Result, subCrop)]
+			results[i].Box = box // Keep the box in crop coordinates
+		}
+	}
+	return results, nil
+}
+
+// centralResult returns the index of the result whose box centre is nearest
+// the centre of the image, preferring the larger face on a tie
+func centralResult(results []compreface.RecognitionResult, imageBytes []byte) int {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(imageBytes))
+	if err != nil {
+		return largestResult(results)
+	}
+	cx, cy := float64(cfg.Width)/2, float64(cfg.Height)/2
+
+	best, bestDistance := 0, math.Inf(1)
+	for i, result := range results {
+		bx := float64(result.Box.XMin+result.Box.XMax) / 2
+		by := float64(result.Box.YMin+result.Box.YMax) / 2
+		distance := math.Hypot(bx-cx, by-cy)
+		if distance < bestDistance || (distance == bestDistance && boxArea(result.Box) > boxArea(results[best].Box)) {
+			best, bestDistance = i, distance
+		}
+	}
+	return best
+}
+
+// largestResult returns the index of the result with the largest box
+func largestResult(results []compreface.RecognitionResult) int {
+	best := 0
+	for i, result := range results {
+		if boxArea(result.Box) > boxArea(results[best].Box) {
+			best = i
+		}
+	}
+	return best
+}
+
+// boxArea returns the area of a bounding box in pixels
+func boxArea(box compreface.BoundingBox) int {
+	return (box.XMax - box.XMin) * (box.YMax - box.YMin)
+}
+
+// matchExtraFaces matches the extra faces found in a face crop to existing
+// performers and adds them to the performers the caller collects. Extras
+// without a clear match are only reported.
+func (s *Service) matchExtraFaces(ctx FaceProcessingContext, face vision.VisionFace, extras []compreface.RecognitionResult) {
+	for i, extra := range extras {
+		match, ok := s.selectMatch(extra.Subjects, nil, ctx.OverlaySubjects)
+		if !ok || match.ambiguous {
+			s.reportFace(ctx, face, ReportFace{Status: ReportFaceUnmatched, Reason: "extra face in crop"})
+			continue
+		}
+
+		performerID, err := s.findExistingStashPerformerBySubject(match.subject, face)
+		if err != nil {
+			log.Warnf("Face %s: extra face %d in crop: %v", face.FaceID, i+1, err)
+			continue
+		}
+		outcome := ReportFace{Status: ReportFaceMatched, Subject: match.subject.Subject, PerformerID: string(performerID), Similarity: match.subject.Similarity, Reason: "extra face in crop"}
+		if performerID == "" {
+			outcome.Status = ReportFaceUnmatched
+		}
+		s.reportFace(ctx, face, outcome)
+
+		if performerID != "" && ctx.ExtraPerformers != nil {
+			log.Infof("Face %s: extra face in crop matched performer %s", face.FaceID, performerID)
+			*ctx.ExtraPerformers = append(*ctx.ExtraPerformers, performerID)
+		}
+	}
+}
+
+// extraPerformersOf returns the performers matched by extra faces that are
+// not already in matched
+func extraPerformersOf(extras []graphql.ID, matched []graphql.ID) []graphql.ID {
+	seen := make(map[graphql.ID]bool, len(matched))
+	for _, id := range matched {
+		seen[id] = true
+	}
+	var added []graphql.ID
+	for _, id := range extras {
+		if !seen[id] {
+			seen[id] = true
+			added = append(added, id)
+		}
+	}
+	return added
+}
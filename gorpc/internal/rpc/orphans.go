@@ -0,0 +1,118 @@
+package rpc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Orphan Subject Cleanup
+// ============================================================================
+//
+// Deleting a performer in Stash leaves its Compreface subject behind, and
+// the subject keeps winning matches nobody can be associated with. Cleanup
+// deletes every subject no performer carries as its name or an alias. The
+// subjects are backed up with their examples first, so restoreBackup can
+// bring them back; a dry run only reports them.
+//
+// ============================================================================
+
+// orphanReport counts checked and orphaned subjects
+type orphanReport struct {
+	checked int
+	deleted int
+	orphans []string
+}
+
+// String summarizes the report
+func (r *orphanReport) String(dryRun bool) string {
+	if dryRun {
+		summary := fmt.Sprintf("Orphan subjects (dry run): %s checked, %s without a performer",
+			formatCount(r.checked), formatCount(len(r.orphans)))
+		if len(r.orphans) > 0 {
+			summary += " (" + strings.Join(r.orphans, ", ") + ")"
+		}
+		return summary
+	}
+	return fmt.Sprintf("Orphan subjects: %s checked, %s without a performer, %s deleted",
+		formatCount(r.checked), formatCount(len(r.orphans)), formatCount(r.deleted))
+}
+
+// cleanupOrphanSubjects deletes the Compreface subjects without a Stash
+// performer, or only reports them when dryRun is set. limit caps the
+// subjects checked.
+func (s *Service) cleanupOrphanSubjects(dryRun bool, limit int) (string, error) {
+	if s.stopped() {
+		return "", fmt.Errorf("operation cancelled")
+	}
+
+	subjects, err := s.comprefaceClient.ListSubjects()
+	if err != nil {
+		return "", fmt.Errorf("failed to list subjects: %w", err)
+	}
+	sort.Strings(subjects)
+
+	report := &orphanReport{}
+	for i, subject := range subjects {
+		if s.stopped() {
+			return "", fmt.Errorf("operation cancelled")
+		}
+		if limit > 0 && report.checked >= limit {
+			break
+		}
+		s.reportProgress(float64(i) / float64(len(subjects)) / 2)
+
+		performerID, err := stash.FindPerformerBySubjectName(s.graphqlClient, subject)
+		if err != nil {
+			return "", err
+		}
+		report.checked++
+		if performerID == "" {
+			log.Infof("Subject %s has no performer", subject)
+			report.orphans = append(report.orphans, subject)
+		}
+	}
+
+	if dryRun || len(report.orphans) == 0 {
+		s.reportProgress(1.0)
+		summary := report.String(dryRun)
+		log.Info(summary)
+		return summary, nil
+	}
+
+	err = s.backupState("cleanupOrphanSubjects", func(backup *store.BackupWriter) error {
+		for _, subject := range report.orphans {
+			if err := s.backupSubject(backup, subject, "", "", true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for i, subject := range report.orphans {
+		if s.stopped() {
+			return "", fmt.Errorf("operation cancelled")
+		}
+		s.reportProgress(0.5 + float64(i)/float64(len(report.orphans))/2)
+
+		if err := s.comprefaceClient.DeleteSubject(subject); err != nil {
+			log.Warnf("Failed to delete orphan subject %s: %v", subject, err)
+			continue
+		}
+		log.Infof("Deleted orphan subject %s", subject)
+		report.deleted++
+	}
+
+	s.reportProgress(1.0)
+	summary := report.String(false)
+	log.Info(summary)
+	return summary, nil
+}
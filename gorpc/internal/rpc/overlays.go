@@ -0,0 +1,141 @@
+package rpc
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// ============================================================================
+// On-Screen Name Hints
+// ============================================================================
+//
+// Some scenes overlay a performer's name on screen. With overlayOcr enabled,
+// the representative frames of a scene's faces are read by the Vision
+// semantics module's OCR, and every line of text that is exactly the name or
+// an alias of one Stash performer becomes a hint for that performer's
+// subject. Hints are a low-weight prior: they only add support to a subject
+// that is already among the close contenders of selectMatch, and never
+// create a match on their own. The names read are reported with the scene's
+// clusters. OCR failures only lose the hints.
+//
+// ============================================================================
+
+const (
+	// overlayNameBonus is the support added to a contender named on screen
+	overlayNameBonus = 0.02
+	// overlayMinConfidence is the OCR confidence a line needs to be looked up
+	overlayMinConfidence = 0.6
+	// overlayMaxFrames caps the frames read per scene
+	overlayMaxFrames = 20
+	// overlayMaxNameLength skips lines too long to be a name (titles, credits)
+	overlayMaxNameLength = 64
+)
+
+// overlayHints are the performers named on screen in a scene
+type overlayHints struct {
+	names    []OverlayName
+	subjects map[string]string // Subject -> text it was named by
+}
+
+// readOverlayNames reads the on-screen text at the representative frames of
+// a scene's faces and resolves it to performers. Returns empty hints when OCR
+// is disabled or fails.
+func (s *Service) readOverlayNames(visionClient *vision.VisionServiceClient, sceneID graphql.ID, videoPath string, faces []vision.VisionFace) overlayHints {
+	hints := overlayHints{subjects: map[string]string{}}
+	if !s.config.OverlayOCR || len(faces) == 0 {
+		return hints
+	}
+
+	texts, err := s.runOverlayOCR(visionClient, sceneID, videoPath, overlayTimestamps(faces))
+	if err != nil {
+		log.Warnf("Scene %s: on-screen text not read: %v", sceneID, err)
+		return hints
+	}
+
+	looked := map[string]bool{}
+	for _, text := range texts {
+		name := strings.Join(strings.Fields(text.Text), " ")
+		key := strings.ToLower(name)
+		if text.Confidence < overlayMinConfidence || len(name) < 3 || len(name) > overlayMaxNameLength || looked[key] {
+			continue
+		}
+		looked[key] = true
+
+		performers, err := stash.FindPerformersByName(s.graphqlClient, name)
+		if err != nil {
+			log.Warnf("Scene %s: failed to look up on-screen name %q: %v", sceneID, name, err)
+			continue
+		}
+		if len(performers) != 1 {
+			if len(performers) > 1 {
+				log.Debugf("Scene %s: on-screen name %q matches %d performers; ignoring it", sceneID, name, len(performers))
+			}
+			continue
+		}
+
+		performer := performers[0]
+		hints.names = append(hints.names, OverlayName{
+			Text:          name,
+			Timestamp:     text.Timestamp,
+			Confidence:    text.Confidence,
+			PerformerID:   string(performer.ID),
+			PerformerName: performer.Name,
+		})
+		if subject := compreface.FindPersonAlias(&performer); subject != "" {
+			hints.subjects[subject] = name
+		}
+		log.Infof("Scene %s: on-screen name %q at %.1fs names performer %s (%s)", sceneID, name, text.Timestamp, performer.Name, performer.ID)
+	}
+	return hints
+}
+
+// runOverlayOCR reads the text at the given frames of a scene
+func (s *Service) runOverlayOCR(visionClient *vision.VisionServiceClient, sceneID graphql.ID, videoPath string, timestamps []float64) ([]vision.TextDetection, error) {
+	request := vision.BuildOCRRequest(videoPath, string(sceneID), s.config.OverlayOCRLanguages, timestamps)
+
+	s.paceVisionJob(visionClient)
+	jobResp, err := visionClient.SubmitJob(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit OCR job: %w", err)
+	}
+	log.Debugf("Scene %s: OCR job submitted for %d frame(s) (job_id=%s)", sceneID, len(timestamps), jobResp.JobID)
+
+	timeout := time.Duration(s.config.VisionSceneJobTimeout) * time.Second
+	results, err := visionClient.WaitForCompletion(jobResp.JobID, timeout, nil)
+	if err != nil {
+		return nil, fmt.Errorf("OCR job failed: %w", err)
+	}
+	if results.Semantics == nil {
+		return nil, fmt.Errorf("no semantics results; is the semantics module enabled?")
+	}
+	return results.Semantics.Text, nil
+}
+
+// overlayTimestamps returns the distinct representative timestamps of faces,
+// in order, capped at overlayMaxFrames
+func overlayTimestamps(faces []vision.VisionFace) []float64 {
+	seen := map[float64]bool{}
+	var timestamps []float64
+	for _, face := range faces {
+		ts := math.Round(face.RepresentativeDetection.Timestamp*10) / 10
+		if !seen[ts] {
+			seen[ts] = true
+			timestamps = append(timestamps, ts)
+		}
+	}
+	sort.Float64s(timestamps)
+	if len(timestamps) > overlayMaxFrames {
+		timestamps = timestamps[:overlayMaxFrames]
+	}
+	return timestamps
+}
@@ -0,0 +1,107 @@
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/throttle"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// ============================================================================
+// Adaptive Vision Pacing
+// ============================================================================
+//
+// With adaptiveVisionPacing on, every Vision job submission first consults
+// the load the Vision Service health endpoint reports (GPU memory in use,
+// jobs queued), read at most every visionLoadCheckInterval. While the
+// service is under pressure, submissions are delayed, the delay doubling
+// from visionPacingMinDelay up to visionPacingMaxDelay as pressure persists,
+// and all but one of the visionMaxConcurrentJobs slots are held back so jobs
+// run one at a time. Both are undone at the first check without pressure.
+// Services that report neither figure are never paced.
+//
+// ============================================================================
+
+// Pacing limits and timing
+const (
+	visionGPUMemoryPressure = 0.9 // Fraction of GPU memory in use that counts as pressure
+	visionQueueHeadroom     = 3   // Jobs queued beyond visionMaxConcurrentJobs that count as pressure
+	visionLoadCheckInterval = 10 * time.Second
+	visionPacingMinDelay    = 5 * time.Second
+	visionPacingMaxDelay    = time.Minute
+)
+
+// visionPacing is the Vision Service load last reported and the pacing
+// applied for it
+type visionPacing struct {
+	mu        sync.Mutex
+	checked   time.Time     // Last health check, zero before the first
+	pressured bool          // Whether the last check reported pressure
+	reason    string        // What the pressure was
+	delay     time.Duration // Delay before the next submission while pressured
+	held      int           // visionJobSlots slots held back while pressured
+}
+
+// paceVisionJob delays a Vision job submission while the Vision Service
+// reports resource pressure, returning early once the task is stopped
+func (s *Service) paceVisionJob(visionClient *vision.VisionServiceClient) {
+	if !s.config.AdaptiveVisionPacing {
+		return
+	}
+
+	delay, reason := s.visionPacing.next(visionClient, s.visionJobSlots, s.config.VisionMaxConcurrentJobs)
+	if delay == 0 {
+		return
+	}
+	log.Infof("Vision Service under pressure (%s), delaying job submission by %s", reason, delay)
+	select {
+	case <-time.After(delay):
+	case <-s.ctx.Done():
+	}
+}
+
+// next refreshes the reported load when due and returns the delay before
+// the next submission, holding back or returning job slots as pressure
+// starts and ends
+func (p *visionPacing) next(visionClient *vision.VisionServiceClient, slots throttle.Semaphore, maxJobs int) (time.Duration, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if time.Since(p.checked) >= visionLoadCheckInterval {
+		p.checked = time.Now()
+		health, err := visionClient.Health()
+		if err != nil {
+			log.Debugf("Vision Service load unavailable, not pacing: %v", err)
+			p.pressured = false
+		} else {
+			load := vision.ParseBackendLoad(health)
+			p.pressured, p.reason = load.Pressured(visionGPUMemoryPressure, maxJobs+visionQueueHeadroom)
+		}
+
+		if !p.pressured && (p.held > 0 || p.delay > 0) {
+			log.Infof("Vision Service pressure eased, resuming normal pacing")
+			for ; p.held > 0; p.held-- {
+				slots.Release()
+			}
+			p.delay = 0
+		}
+	}
+	if !p.pressured {
+		return 0, ""
+	}
+
+	// Hold back every slot but one; slots busy with jobs in flight are
+	// taken at later submissions once they free up
+	for p.held < cap(slots)-1 && slots.TryAcquire() {
+		p.held++
+	}
+
+	if p.delay == 0 {
+		p.delay = visionPacingMinDelay
+	} else if p.delay < visionPacingMaxDelay {
+		p.delay = min(p.delay*2, visionPacingMaxDelay)
+	}
+	return p.delay, p.reason
+}
@@ -5,10 +5,12 @@ import (
 	"strings"
 
 	graphql "github.com/hasura/go-graphql-client"
-	"github.com/stashapp/stash/pkg/plugin/common/log"
 
 	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/config"
 	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
 )
 
 // ============================================================================
@@ -18,7 +20,7 @@ import (
 // synchronizePerformers syncs performers with Compreface subjects
 // It finds performers with "Person ..." aliases and adds their images to Compreface
 func (s *Service) synchronizePerformers(limit int) error {
-	if s.stopping {
+	if s.stopped() {
 		return fmt.Errorf("operation cancelled")
 	}
 
@@ -37,7 +39,7 @@ func (s *Service) synchronizePerformers(limit int) error {
 	processedCount := 0
 
 	for {
-		if s.stopping {
+		if s.stopped() {
 			return fmt.Errorf("operation cancelled")
 		}
 
@@ -47,14 +49,9 @@ func (s *Service) synchronizePerformers(limit int) error {
 			Value:    "Person ",
 			Modifier: stash.CriterionModifierIncludes,
 		}
-		tagsFilter := stash.HierarchicalMultiCriterionInput{
-			Value:    []string{string(syncTagID)},
-			Modifier: stash.CriterionModifierExcludes,
-		}
-
 		// Fetch performers with images that haven't been synced yet
 		filter := &stash.PerformerFilterType{
-			Tags: &tagsFilter,
+			Tags: s.excludeTagsCriterion(syncTagID),
 			OperatorFilter: stash.OperatorFilter[stash.PerformerFilterType]{
 				And: &stash.PerformerFilterType{
 					OperatorFilter: stash.OperatorFilter[stash.PerformerFilterType]{
@@ -104,7 +101,7 @@ func (s *Service) synchronizePerformers(limit int) error {
 
 		// Process each performer in the batch
 		for _, performer := range performers {
-			if s.stopping {
+			if s.stopped() {
 				return fmt.Errorf("operation cancelled")
 			}
 
@@ -116,30 +113,27 @@ func (s *Service) synchronizePerformers(limit int) error {
 
 			processedCount++
 			progress := float64(processedCount) / float64(total)
-			log.Progress(progress)
+			s.reportProgress(progress)
 
 			log.Infof("Processing performer %d/%d: %s (ID: %s)", processedCount, total, performer.Name, performer.ID)
 
 			err := s.syncPerformer(performer, syncTagID)
 			if err != nil {
 				log.Warnf("Failed to sync performer %s: %v", performer.ID, err)
+				s.itemFailed("performer", performer.ID, err)
 				// Continue with next performer
 				continue
 			}
+			s.summary.performersSynced.Add(1)
 		}
 
 		// Break outer loop if limit reached
 		if limit > 0 && processedCount >= limit {
 			break
 		}
-
-		// Apply cooldown after processing batch
-		if len(performers) == batchSize && processedCount < total {
-			s.applyCooldown()
-		}
 	}
 
-	log.Progress(1.0)
+	s.reportProgress(1.0)
 	log.Infof("Performer synchronization complete: %d performers processed", processedCount)
 
 	return nil
@@ -181,11 +175,7 @@ func (s *Service) syncPerformer(performer stash.Performer, syncTagID graphql.ID)
 
 	// Step 3: Get performer image URL and download image bytes
 	// Performer images are stored as blobs in Stash, accessible via /performer/{id}/image endpoint
-	imageURL := fmt.Sprintf("%s://%s:%d/performer/%s/image",
-		s.serverConnection.Scheme,
-		s.serverConnection.Host,
-		s.serverConnection.Port,
-		performer.ID)
+	imageURL := fmt.Sprintf("%s/performer/%s/image", s.stashBaseURL(), performer.ID)
 
 	log.Debugf("Downloading performer image from %s", imageURL)
 	imageBytes, err := stash.DownloadImage(imageURL, s.serverConnection.SessionCookie)
@@ -243,3 +233,193 @@ func (s *Service) syncPerformer(performer stash.Performer, syncTagID graphql.ID)
 
 	return nil
 }
+
+// deleteSubjectForPerformer removes a single performer's "Person ..." subject
+// from Compreface and clears the alias and synced tag from the performer, or
+// deletes the performer entirely when deletePerformer is true.
+func (s *Service) deleteSubjectForPerformer(performerID string, deletePerformer bool) error {
+	if s.stopped() {
+		return fmt.Errorf("operation cancelled")
+	}
+
+	if performerID == "" {
+		return fmt.Errorf("performerId is required")
+	}
+
+	// Step 1: Get performer and its subject alias
+	performer, err := stash.GetPerformerByID(s.graphqlClient, graphql.ID(performerID))
+	if err != nil {
+		return fmt.Errorf("failed to get performer: %w", err)
+	}
+
+	alias := compreface.FindPersonAlias(performer)
+	if alias == "" {
+		return fmt.Errorf("performer %s has no 'Person ...' alias", performerID)
+	}
+
+	// Step 2: Delete the subject from Compreface (if present)
+	subjects, err := s.comprefaceClient.ListSubjects()
+	if err != nil {
+		return fmt.Errorf("failed to list subjects: %w", err)
+	}
+
+	subjectExists := false
+	for _, subject := range subjects {
+		if subject == alias {
+			subjectExists = true
+			break
+		}
+	}
+
+	// Back up the performer, its subject's examples and, when the performer
+	// is deleted, its associations
+	err = s.backupState("deleteSubjectForPerformer", func(backup *store.BackupWriter) error {
+		if err := backupPerformer(backup, performer); err != nil {
+			return err
+		}
+		if subjectExists {
+			if err := s.backupSubject(backup, alias, performer.ID, "", true); err != nil {
+				return err
+			}
+		}
+		if deletePerformer {
+			return s.backupPerformerItems(backup, performer.ID, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if subjectExists {
+		if err := s.comprefaceClient.DeleteSubject(alias); err != nil {
+			return fmt.Errorf("failed to delete subject: %w", err)
+		}
+		log.Infof("Deleted subject '%s' from Compreface", alias)
+	} else {
+		log.Infof("Subject '%s' not found in Compreface, skipping delete", alias)
+	}
+
+	s.forgetPerformer(string(performer.ID))
+
+	// Step 3: Delete the performer, or clean up its alias and synced tag
+	if deletePerformer {
+		if err := stash.DestroyPerformer(s.graphqlClient, performer.ID); err != nil {
+			return err
+		}
+		log.Infof("Deleted performer %s (%s)", performer.Name, performer.ID)
+		return nil
+	}
+
+	syncTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.SyncedTagName, "Compreface Synced")
+	if err != nil {
+		return fmt.Errorf("failed to get sync tag: %w", err)
+	}
+
+	aliases := []string{}
+	for _, a := range performer.AliasList {
+		if a != alias {
+			aliases = append(aliases, a)
+		}
+	}
+
+	tagIDs := []string{}
+	for _, tag := range performer.Tags {
+		if tag.ID != syncTagID {
+			tagIDs = append(tagIDs, string(tag.ID))
+		}
+	}
+
+	input := stash.PerformerUpdateInput{
+		ID:        string(performer.ID),
+		AliasList: aliases,
+		TagIds:    tagIDs,
+	}
+	if err := stash.UpdatePerformer(s.graphqlClient, performer.ID, input); err != nil {
+		return fmt.Errorf("failed to update performer: %w", err)
+	}
+
+	if performer.Name == alias {
+		log.Warnf("Performer %s is named '%s'; rename it to avoid re-syncing as a subject", performer.ID, alias)
+	}
+
+	log.Infof("Removed alias '%s' and synced tag from performer %s", alias, performer.Name)
+	return nil
+}
+
+// dedupeAliases removes case-insensitive duplicate aliases left on performers
+// by repeated syncs. Only performers whose alias list changes are updated.
+func (s *Service) dedupeAliases(limit int) error {
+	if s.stopped() {
+		return fmt.Errorf("operation cancelled")
+	}
+
+	log.Infof("Deduplicating performer aliases (limit=%d)", limit)
+
+	var duplicated []stash.Performer
+	total := 0
+	err := stash.FindAllPerformers(s.graphqlClient, nil, stash.DefaultPageSize, func(performers []stash.Performer, count int) error {
+		if s.stopped() {
+			return fmt.Errorf("operation cancelled")
+		}
+		total = count
+		for _, performer := range performers {
+			if limit > 0 && len(duplicated) >= limit {
+				return stash.ErrStopPaging
+			}
+			if len(stash.DedupeAliases(performer.AliasList)) != len(performer.AliasList) {
+				duplicated = append(duplicated, performer)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query performers: %w", err)
+	}
+
+	if len(duplicated) == 0 {
+		log.Infof("No duplicate aliases found across %d performers", total)
+		return nil
+	}
+	log.Infof("Found %d performers with duplicate aliases", len(duplicated))
+
+	for i, performer := range duplicated {
+		if s.stopped() {
+			return fmt.Errorf("operation cancelled")
+		}
+
+		aliases := stash.DedupeAliases(performer.AliasList)
+		input := stash.PerformerUpdateInput{
+			ID:        string(performer.ID),
+			AliasList: aliases,
+		}
+		if err := stash.UpdatePerformer(s.graphqlClient, performer.ID, input); err != nil {
+			log.Warnf("Failed to deduplicate aliases for performer %s: %v", performer.Name, err)
+			continue
+		}
+		log.Infof("Performer %s: removed %d duplicate aliases", performer.Name, len(performer.AliasList)-len(aliases))
+
+		s.reportProgress(float64(i+1) / float64(len(duplicated)))
+	}
+
+	return nil
+}
+
+// birthdateAge picks the age a created performer's birthdate is estimated
+// from, per the birthdateStrategy setting. Returns 0 for no birthdate.
+func (s *Service) birthdateAge(low int, high int) int {
+	if high < low {
+		low, high = high, low
+	}
+	if low <= 0 {
+		low = high
+	}
+	switch s.config.BirthdateStrategy {
+	case config.BirthdateNone:
+		return 0
+	case config.BirthdateLowerBound:
+		return low
+	default:
+		return (low + high) / 2
+	}
+}
@@ -0,0 +1,135 @@
+package rpc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Full Pipeline (Service Layer)
+// ============================================================================
+
+// pipelineStage is a single step of the fullPipeline task
+type pipelineStage struct {
+	name      string
+	weight    float64 // Share of overall progress (weights sum to 1.0)
+	requires  string  // Required service ("vision" or empty)
+	anonymous bool    // Runs in anonymization mode
+	run       func() error
+}
+
+// fullPipeline runs library maintenance as a single task:
+// synchronizePerformers → recognizeImages → recognizeNewScenes → recognizePartialScenes.
+// Progress from each stage is weighted into a combined 0-1 progress value.
+// A failing stage is logged and recorded but does not stop later stages,
+// except for cancellation. Returns a consolidated summary of all stages.
+func (s *Service) fullPipeline(limit int, createNewSubjects bool) (string, error) {
+	stages := []pipelineStage{
+		{
+			name:   "Synchronize Performers",
+			weight: 0.10,
+			run:    func() error { return s.components.performers.SynchronizePerformers(limit) },
+		},
+		{
+			name:      "Recognize Images",
+			weight:    0.40,
+			requires:  "vision",
+			anonymous: true,
+			run:       func() error { return s.components.images.RecognizeImages(limit, createNewSubjects) },
+		},
+		{
+			name:      "Recognize New Scenes",
+			weight:    0.35,
+			requires:  "vision",
+			anonymous: true,
+			run: func() error {
+				return s.components.scenes.RecognizeScenes(false, SceneScopeNew, limit, createNewSubjects)
+			},
+		},
+		{
+			name:     "Rescan Partial Scenes",
+			weight:   0.15,
+			requires: "vision",
+			run: func() error {
+				return s.components.scenes.RecognizeScenes(false, SceneScopePartial, limit, createNewSubjects)
+			},
+		},
+	}
+
+	defer func() { s.progressStage = nil }()
+
+	results := []PipelineStageResult{}
+	offset := 0.0
+
+	for i, stage := range stages {
+		if s.stopped() {
+			return "", fmt.Errorf("operation cancelled")
+		}
+
+		if stage.requires == "vision" && s.config.VisionServiceURL == "" {
+			log.Infof("[Pipeline %d/%d] Skipping %s: vision service not configured", i+1, len(stages), stage.name)
+			results = append(results, PipelineStageResult{Name: stage.name, Err: fmt.Errorf("skipped: vision service not configured")})
+			offset += stage.weight
+			log.Progress(offset)
+			continue
+		}
+
+		if s.config.AnonymizationMode && !stage.anonymous {
+			log.Infof("[Pipeline %d/%d] Skipping %s: anonymization mode", i+1, len(stages), stage.name)
+			results = append(results, PipelineStageResult{Name: stage.name, Err: fmt.Errorf("skipped: anonymization mode")})
+			offset += stage.weight
+			log.Progress(offset)
+			continue
+		}
+
+		log.Infof("[Pipeline %d/%d] Starting %s", i+1, len(stages), stage.name)
+		s.progressStage = &progressStage{offset: offset, weight: stage.weight}
+
+		start := time.Now()
+		err := stage.run()
+		result := PipelineStageResult{Name: stage.name, Duration: time.Since(start), Err: err}
+		results = append(results, result)
+
+		if err != nil {
+			if s.stopped() {
+				return "", fmt.Errorf("operation cancelled")
+			}
+			log.Warnf("[Pipeline %d/%d] %s failed after %s: %v", i+1, len(stages), stage.name, result.Duration.Round(time.Second), err)
+		} else {
+			log.Infof("[Pipeline %d/%d] %s completed in %s", i+1, len(stages), stage.name, result.Duration.Round(time.Second))
+		}
+
+		offset += stage.weight
+		s.progressStage = nil
+		log.Progress(offset)
+	}
+
+	log.Progress(1.0)
+
+	summary := summarizePipeline(results)
+	log.Info(summary)
+	return summary, nil
+}
+
+// summarizePipeline builds a consolidated, human-readable summary of stage results
+func summarizePipeline(results []PipelineStageResult) string {
+	var b strings.Builder
+	failed := 0
+	var total time.Duration
+
+	for _, r := range results {
+		total += r.Duration
+		if r.Err != nil {
+			failed++
+			fmt.Fprintf(&b, "\n  - %s: %v", r.Name, r.Err)
+		} else {
+			fmt.Fprintf(&b, "\n  - %s: ok (%s)", r.Name, r.Duration.Round(time.Second))
+		}
+	}
+
+	return fmt.Sprintf("Full pipeline completed: %d/%d stages succeeded in %s%s",
+		len(results)-failed, len(results), total.Round(time.Second), b.String())
+}
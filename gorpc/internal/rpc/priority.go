@@ -0,0 +1,190 @@
+package rpc
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sort"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/config"
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Batch Ordering (Service Layer)
+// ============================================================================
+//
+// Batch queries take the first pages of their filtered set, so with a limit
+// in place a run that keeps failing on the same early items never reaches
+// the tail. batchOrder picks the order batches are fetched in: shuffled
+// (random_<seed> sort, the seed kept in the checkpoint for resumed runs),
+// oldest or newest first (by ID), or the default order with items that
+// failed in earlier runs deferred to the end of each pass, fewest failures
+// first.
+// Failure counts persist in data/failures.jsonl and a success clears them.
+//
+// ============================================================================
+
+// failuresFile is the item failure count file's path under the plugin directory
+const failuresFile = "data/failures.jsonl"
+
+// performerCountPasses returns the performer_count criteria batch recognition
+// iterates over, in order. With PrioritizeUnidentified enabled, media with no
+// performers (most valuable to identify) is processed before media that
+// already has performers. Otherwise a single unfiltered pass (nil) is returned.
+func (s *Service) performerCountPasses() []*stash.IntCriterionInput {
+	if !s.config.PrioritizeUnidentified {
+		return []*stash.IntCriterionInput{nil}
+	}
+
+	return []*stash.IntCriterionInput{
+		{Value: 0, Modifier: stash.CriterionModifierEquals},
+		{Value: 0, Modifier: stash.CriterionModifierGreaterThan},
+	}
+}
+
+// logPerformerCountPass logs the start of a prioritized batch pass
+func logPerformerCountPass(performerCount *stash.IntCriterionInput, mediaType string) {
+	if performerCount == nil {
+		return
+	}
+
+	if performerCount.Modifier == stash.CriterionModifierEquals {
+		log.Infof("Processing %s without performers first", mediaType)
+	} else {
+		log.Infof("Processing %s with existing performers", mediaType)
+	}
+}
+
+// openFailures opens the item failure counts. Failures are logged and leave
+// failures unrecorded.
+func (s *Service) openFailures() func() {
+	path := filepath.Join(s.serverConnection.PluginDir, filepath.FromSlash(failuresFile))
+	failures, err := store.OpenFailures(path)
+	if err != nil {
+		log.Warnf("Item failure counts disabled: %v", err)
+		return func() {}
+	}
+
+	s.failures = failures
+	return func() {
+		s.failures = nil
+		if err := failures.Close(); err != nil {
+			log.Warnf("Failed to close item failure counts: %v", err)
+		}
+	}
+}
+
+// recordFailure counts a failed attempt at an item
+func (s *Service) recordFailure(kind string, id graphql.ID) {
+	if s.failures == nil {
+		return
+	}
+	if err := s.failures.Record(fmt.Sprintf("%s:%s", kind, id)); err != nil {
+		log.Warnf("Failed to record failure of %s %s: %v", kind, id, err)
+	}
+}
+
+// clearFailures forgets the failed attempts at an item once it succeeds
+func (s *Service) clearFailures(kind string, id graphql.ID) {
+	if s.failures == nil {
+		return
+	}
+	if err := s.failures.Clear(fmt.Sprintf("%s:%s", kind, id)); err != nil {
+		log.Warnf("Failed to clear failures of %s %s: %v", kind, id, err)
+	}
+}
+
+// failureCount returns the number of earlier failed attempts at an item
+func (s *Service) failureCount(kind string, id graphql.ID) int {
+	if s.failures == nil {
+		return 0
+	}
+	return s.failures.Count(fmt.Sprintf("%s:%s", kind, id))
+}
+
+// batchSeed returns the shuffle of a random-order run: the resumed run's, the
+// configured random seed, or a new one. Zero for other orders.
+func (s *Service) batchSeed(resumed store.Checkpoint) int {
+	if s.config.BatchOrder != config.BatchOrderRandom {
+		return 0
+	}
+	if resumed.Seed != 0 {
+		return resumed.Seed
+	}
+	if s.config.RandomSeed != 0 {
+		return s.config.RandomSeed
+	}
+	return rand.Intn(100000000) + 1
+}
+
+// shuffleSort returns the sort of a random order run's shuffle
+func shuffleSort(seed int) stash.BatchSort {
+	return stash.BatchSort{Sort: fmt.Sprintf("random_%d", seed)}
+}
+
+// findShuffled returns the next batch of a random order pass, reading up to
+// perPage items from the start of the shuffle with find. The shuffle of a
+// seed is stable, and batches are read from its start rather than by page,
+// since processed items leaving the filter would shift later pages. Items
+// handed out earlier that are still in the filter (failed, deferred or
+// rescanned) hold the front, and the read widens past them. handed records
+// the items returned.
+func findShuffled[T any](batchSize int, handed map[graphql.ID]bool, id func(T) graphql.ID, find func(perPage int) ([]T, error)) ([]T, error) {
+	window := batchSize
+	for {
+		items, err := find(window)
+		if err != nil {
+			return nil, err
+		}
+
+		batch := items[:0:0]
+		for _, item := range items {
+			if !handed[id(item)] && len(batch) < batchSize {
+				batch = append(batch, item)
+			}
+		}
+		if len(batch) == batchSize || len(items) < window {
+			for _, item := range batch {
+				handed[id(item)] = true
+			}
+			return batch, nil
+		}
+		window = batchSize + len(items) - len(batch)
+	}
+}
+
+// deferFailed returns the items of a batch to process now. With the failures
+// batch order, items that failed in earlier runs are appended to deferred
+// instead, to be processed once the rest of the pass is done.
+func deferFailed[T any](s *Service, kind string, items []T, id func(T) graphql.ID, deferred *[]T) []T {
+	if s.config.BatchOrder != config.BatchOrderFailures {
+		return items
+	}
+
+	now := items[:0:0]
+	for _, item := range items {
+		if s.failureCount(kind, id(item)) > 0 {
+			*deferred = append(*deferred, item)
+			continue
+		}
+		now = append(now, item)
+	}
+	return now
+}
+
+// sortByFailures orders deferred items by their earlier failures, fewest
+// first, keeping the batch order among equals
+func sortByFailures[T any](s *Service, kind string, items []T, id func(T) graphql.ID) {
+	counts := make(map[graphql.ID]int, len(items))
+	for _, item := range items {
+		counts[id(item)] = s.failureCount(kind, id(item))
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return counts[id(items[i])] < counts[id(items[j])]
+	})
+}
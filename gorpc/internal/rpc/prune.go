@@ -0,0 +1,185 @@
+package rpc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Auto-Created Performer Pruning
+// ============================================================================
+//
+// Every unmatched face above the quality bar becomes a "Person ..." subject
+// and performer. Most are matched again in later media; the ones that never
+// are pile up as placeholders seen exactly once. Pruning deletes performers
+// created more than a number of days ago that carry a generated subject (as
+// their name, or as an alias of a performer that is not synced) and are in
+// exactly one image or scene, together with their subject. Synced performers
+// are the user's own and are never pruned. Everything deleted is backed up
+// first, so restoreBackup can bring it back; a dry run only reports.
+//
+// ============================================================================
+
+// pruneCandidate is an auto-created performer to prune and its subject
+type pruneCandidate struct {
+	performer stash.Performer
+	subject   string
+}
+
+// pruneReport counts checked and pruned performers
+type pruneReport struct {
+	checked int
+	deleted int
+	failed  int
+	names   []string
+}
+
+// String summarizes the report
+func (r *pruneReport) String(dryRun bool) string {
+	if dryRun {
+		summary := fmt.Sprintf("Auto-created performers (dry run): %s checked, %s seen only once",
+			formatCount(r.checked), formatCount(len(r.names)))
+		if len(r.names) > 0 {
+			summary += " (" + strings.Join(r.names, ", ") + ")"
+		}
+		return summary
+	}
+	return fmt.Sprintf("Pruned auto-created performers: %s checked, %s seen only once, %s deleted with their subjects, %s failed",
+		formatCount(r.checked), formatCount(len(r.names)), formatCount(r.deleted), formatCount(r.failed))
+}
+
+// pruneAutoPerformers deletes auto-created performers older than days that
+// are in a single image or scene, with their subjects, or only reports them
+// when dryRun is set. limit caps the performers pruned.
+func (s *Service) pruneAutoPerformers(days int, dryRun bool, limit int) (string, error) {
+	if s.stopped() {
+		return "", fmt.Errorf("operation cancelled")
+	}
+
+	tags, err := s.lookupPluginTags()
+	if err != nil {
+		return "", err
+	}
+
+	// Deleting shifts pages, so candidates are collected before any deletion
+	report := &pruneReport{}
+	var candidates []pruneCandidate
+	cutoff := time.Now().AddDate(0, 0, -days)
+	err = stash.FindAllPerformersCreatedBefore(s.graphqlClient, cutoff, stash.DefaultPageSize, func(performers []stash.PerformerUsage, count int) error {
+		for _, performer := range performers {
+			if s.stopped() {
+				return fmt.Errorf("operation cancelled")
+			}
+			if limit > 0 && len(candidates) >= limit {
+				return stash.ErrStopPaging
+			}
+			report.checked++
+			s.reportProgress(float64(report.checked) / float64(count) / 2)
+
+			if subject := autoCreatedSubject(performer, tags.synced); subject != "" {
+				log.Infof("Performer %s (%s) was seen once since %s", performer.Name, performer.ID, performer.CreatedAt.Format("2006-01-02"))
+				candidates = append(candidates, pruneCandidate{performer: performer.Performer, subject: subject})
+				report.names = append(report.names, performer.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to find performers: %w", err)
+	}
+
+	if dryRun || len(candidates) == 0 {
+		s.reportProgress(1.0)
+		summary := report.String(dryRun)
+		log.Info(summary)
+		return summary, nil
+	}
+
+	subjects, err := s.comprefaceClient.ListSubjects()
+	if err != nil {
+		return "", fmt.Errorf("failed to list subjects: %w", err)
+	}
+	existing := make(map[string]bool, len(subjects))
+	for _, subject := range subjects {
+		existing[subject] = true
+	}
+
+	err = s.backupState("pruneAutoPerformers", func(backup *store.BackupWriter) error {
+		for i := range candidates {
+			if err := backupPerformer(backup, &candidates[i].performer); err != nil {
+				return err
+			}
+		}
+		for _, candidate := range candidates {
+			if existing[candidate.subject] {
+				if err := s.backupSubject(backup, candidate.subject, candidate.performer.ID, "", true); err != nil {
+					return err
+				}
+			}
+			if err := s.backupPerformerItems(backup, candidate.performer.ID, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for i, candidate := range candidates {
+		if s.stopped() {
+			return "", fmt.Errorf("operation cancelled")
+		}
+		s.reportProgress(0.5 + float64(i)/float64(len(candidates))/2)
+
+		if err := s.prunePerformer(candidate, existing[candidate.subject]); err != nil {
+			log.Warnf("Failed to prune performer %s (%s): %v", candidate.performer.Name, candidate.performer.ID, err)
+			s.itemFailed("performer", candidate.performer.ID, err)
+			report.failed++
+			continue
+		}
+		report.deleted++
+	}
+
+	s.reportProgress(1.0)
+	summary := report.String(false)
+	log.Info(summary)
+	return summary, nil
+}
+
+// autoCreatedSubject returns the generated subject of a performer the plugin
+// created that is in exactly one image or scene, or "" for any other
+// performer
+func autoCreatedSubject(performer stash.PerformerUsage, syncedTagID graphql.ID) string {
+	if performer.ImageCount+performer.SceneCount != 1 {
+		return ""
+	}
+	if syncedTagID != "" && hasTag(performer.Tags, syncedTagID) {
+		return ""
+	}
+	return compreface.FindPersonAlias(&performer.Performer)
+}
+
+// prunePerformer deletes a performer and, when Compreface has it, its subject
+func (s *Service) prunePerformer(candidate pruneCandidate, subjectExists bool) error {
+	if subjectExists {
+		if err := s.comprefaceClient.DeleteSubject(candidate.subject); err != nil {
+			return fmt.Errorf("failed to delete subject %s: %w", candidate.subject, err)
+		}
+	}
+	s.forgetPerformer(string(candidate.performer.ID))
+
+	if err := stash.DestroyPerformer(s.graphqlClient, candidate.performer.ID); err != nil {
+		return err
+	}
+	log.Infof("Deleted performer %s (%s) and subject %s", candidate.performer.Name, candidate.performer.ID, candidate.subject)
+	return nil
+}
@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"os"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/pkg/utils"
+)
+
+// ============================================================================
+// Camera RAW Images
+// ============================================================================
+//
+// Neither the Vision Service nor Compreface can decode camera RAW files
+// (DNG, CR2, NEF, ...), so RAW images are processed through the JPEG preview
+// the camera embeds in them. The preview is rotated upright and written to a
+// temporary JPEG in VisionTempDir, which stands in for the RAW file for
+// detection, recognition and cropping; detections are therefore relative to
+// the preview. RAW files without a decodable preview fail as before.
+//
+// ============================================================================
+
+// RawPreviewJPEG returns the embedded preview of a RAW file as an upright
+// sRGB JPEG
+func RawPreviewJPEG(data []byte) ([]byte, error) {
+	preview, orientation, err := utils.ExtractRawPreview(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract RAW preview: %w", err)
+	}
+
+	img, _, err := utils.DecodeImageSRGB(preview)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode RAW preview: %w", err)
+	}
+	img = applyOrientation(img, orientation)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		return nil, fmt.Errorf("failed to encode RAW preview: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// rawPreviewPath returns the path to process in place of imagePath: a
+// temporary JPEG of the preview for RAW files, imagePath itself otherwise.
+// The cleanup function removes any temporary file.
+func (s *Service) rawPreviewPath(imagePath string) (string, func(), error) {
+	noop := func() {}
+	if !utils.IsRawImagePath(imagePath) {
+		return imagePath, noop, nil
+	}
+
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to read RAW image: %w", err)
+	}
+	preview, err := RawPreviewJPEG(data)
+	if err != nil {
+		return "", noop, err
+	}
+
+	tmp, err := os.CreateTemp(s.config.VisionTempDir, "compreface-raw-*.jpg")
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to create temporary image: %w", err)
+	}
+	cleanup := func() {
+		if err := os.Remove(tmp.Name()); err != nil {
+			log.Warnf("Failed to remove temporary image %s: %v", tmp.Name(), err)
+		}
+	}
+
+	_, err = tmp.Write(preview)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("failed to write temporary image: %w", err)
+	}
+
+	log.Debugf("Processing %s through its embedded JPEG preview", imagePath)
+	return tmp.Name(), cleanup, nil
+}
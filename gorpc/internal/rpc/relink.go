@@ -0,0 +1,208 @@
+package rpc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Subject Relink Repair
+// ============================================================================
+//
+// Matches find a performer through the "Person ..." alias equal to the
+// Compreface subject. When a user strips that alias, later matches to the
+// subject are silently orphaned. Repair walks the generated subjects without
+// a performer and recovers the performer from the other linkage the plugin
+// keeps:
+//
+//   - Synced performers: the subject name embeds the performer's own ID
+//   - Face-created performers: the face store remembers the performers matched
+//     to faces of the image or scene whose ID the subject name embeds
+//
+// A single candidate without a "Person ..." alias gets the subject restored
+// as an alias. A single candidate that has since gained another subject alias
+// has the orphan renamed into that subject, merging their faces. Everything
+// else is reported as unresolvable and left alone.
+//
+// ============================================================================
+
+// relinkReport counts repaired and unresolvable subjects
+type relinkReport struct {
+	checked      int
+	restored     int
+	renamed      int
+	unresolvable []string
+}
+
+// unresolved records a subject that could not be relinked
+func (r *relinkReport) unresolved(subject string, reason string) {
+	r.unresolvable = append(r.unresolvable, subject)
+	log.Warnf("Subject %s: cannot relink: %s", subject, reason)
+}
+
+// String summarizes the report
+func (r *relinkReport) String() string {
+	summary := fmt.Sprintf("Relinked subjects: %s checked, %s aliases restored, %s renamed, %s unresolvable",
+		formatCount(r.checked), formatCount(r.restored), formatCount(r.renamed), formatCount(len(r.unresolvable)))
+	if len(r.unresolvable) > 0 {
+		summary += " (" + strings.Join(r.unresolvable, ", ") + ")"
+	}
+	return summary
+}
+
+// repairSubjectLinks restores the performer link of generated Compreface
+// subjects no performer carries as an alias or name
+func (s *Service) repairSubjectLinks(limit int) (string, error) {
+	if s.stopped() {
+		return "", fmt.Errorf("operation cancelled")
+	}
+
+	subjects, err := s.comprefaceClient.ListSubjects()
+	if err != nil {
+		return "", fmt.Errorf("failed to list subjects: %w", err)
+	}
+	sort.Strings(subjects)
+
+	tags, err := s.lookupPluginTags()
+	if err != nil {
+		return "", err
+	}
+	if s.faceStore == nil {
+		log.Info("Face store disabled: only synced performers can be relinked")
+	}
+
+	report := &relinkReport{}
+	for i, subject := range subjects {
+		if s.stopped() {
+			return "", fmt.Errorf("operation cancelled")
+		}
+		if limit > 0 && report.checked >= limit {
+			break
+		}
+		s.reportProgress(float64(i) / float64(len(subjects)))
+
+		if !compreface.IsGeneratedSubjectName(subject) {
+			continue
+		}
+		performerID, err := stash.FindPerformerBySubjectName(s.graphqlClient, subject)
+		if err != nil {
+			return "", err
+		}
+		report.checked++
+		if performerID != "" {
+			continue
+		}
+
+		if err := s.relinkSubject(subject, tags.synced, report); err != nil {
+			report.unresolved(subject, err.Error())
+		}
+	}
+
+	s.reportProgress(1.0)
+	summary := report.String()
+	log.Info(summary)
+	return summary, nil
+}
+
+// relinkSubject restores the alias of, or renames into, the single performer
+// recovered for an orphaned subject
+func (s *Service) relinkSubject(subject string, syncedTagID graphql.ID, report *relinkReport) error {
+	candidates, err := s.subjectCandidates(subject, syncedTagID)
+	if err != nil {
+		return err
+	}
+
+	var unaliased, aliased []*stash.Performer
+	for _, performer := range candidates {
+		if compreface.FindPersonAlias(performer) == "" {
+			unaliased = append(unaliased, performer)
+		} else {
+			aliased = append(aliased, performer)
+		}
+	}
+
+	switch {
+	case len(unaliased) == 1 && len(aliased) == 0:
+		performer := unaliased[0]
+		input := stash.PerformerUpdateInput{
+			ID:        string(performer.ID),
+			AliasList: append(performer.AliasList, subject),
+		}
+		if err := stash.UpdatePerformer(s.graphqlClient, performer.ID, input); err != nil {
+			return fmt.Errorf("failed to restore alias: %w", err)
+		}
+		log.Infof("Subject %s: restored alias on performer %s (%s)", subject, performer.Name, performer.ID)
+		report.restored++
+
+	case len(unaliased) == 0 && len(aliased) == 1:
+		performer := aliased[0]
+		alias := compreface.FindPersonAlias(performer)
+		if err := s.comprefaceClient.RenameSubject(subject, alias); err != nil {
+			return fmt.Errorf("failed to rename subject: %w", err)
+		}
+		log.Infof("Subject %s: merged into %s of performer %s (%s)", subject, alias, performer.Name, performer.ID)
+		report.renamed++
+
+	case len(candidates) == 0:
+		report.unresolved(subject, "no linked performer found")
+
+	default:
+		ids := make([]string, len(candidates))
+		for i, performer := range candidates {
+			ids[i] = string(performer.ID)
+		}
+		report.unresolved(subject, "ambiguous performers "+strings.Join(ids, ", "))
+	}
+	return nil
+}
+
+// subjectCandidates returns the existing performers linked to a subject by
+// the ID its name embeds: the synced performer with that ID, and performers
+// the face store matched to faces of the image or scene with that ID
+func (s *Service) subjectCandidates(subject string, syncedTagID graphql.ID) ([]*stash.Performer, error) {
+	sourceID, ok := compreface.SubjectSourceID(subject)
+	if !ok {
+		return nil, nil
+	}
+
+	var candidates []*stash.Performer
+	seen := map[graphql.ID]bool{}
+	add := func(performerID string, syncedOnly bool) error {
+		if seen[graphql.ID(performerID)] {
+			return nil
+		}
+		performer, err := stash.GetPerformerByID(s.graphqlClient, graphql.ID(performerID))
+		if err != nil {
+			return fmt.Errorf("failed to get performer %s: %w", performerID, err)
+		}
+		if performer.ID == "" || (syncedOnly && !hasTag(performer.Tags, syncedTagID)) {
+			return nil
+		}
+		seen[performer.ID] = true
+		candidates = append(candidates, performer)
+		return nil
+	}
+
+	if syncedTagID != "" {
+		if err := add(sourceID, true); err != nil {
+			return nil, err
+		}
+	}
+	if s.faceStore != nil {
+		for _, source := range []string{"image:" + sourceID, "scene:" + sourceID} {
+			for _, performerID := range s.faceStore.Performers(source) {
+				if err := add(performerID, false); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return candidates, nil
+}
@@ -0,0 +1,161 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	graphql "github.com/hasura/go-graphql-client"
+	"github.com/stashapp/stash/pkg/plugin/common"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// ============================================================================
+// Task Results
+// ============================================================================
+//
+// Every mode returns a TaskResult serialized as JSON in the task output, so
+// UI plugins and scripts can read counts, failures and created performers
+// without parsing log lines. Modes with their own response (identifyImage,
+// identifyScene, ...) nest it under "result".
+//
+// ============================================================================
+
+// maxItemErrors bounds the per-item errors kept in a task result; the
+// failure count still covers all of them
+const maxItemErrors = 100
+
+// TaskResult is the machine-readable output of a task
+type TaskResult struct {
+	Mode              string          `json:"mode"`
+	Success           bool            `json:"success"`
+	Message           string          `json:"message"` // Human-readable output, with the run summary
+	Error             string          `json:"error,omitempty"`
+	DurationMs        int64           `json:"duration_ms"`
+	Counts            TaskCounts      `json:"counts"`
+	CreatedPerformers []string        `json:"created_performer_ids"`
+	Errors            []ItemError     `json:"errors"`
+	Result            json.RawMessage `json:"result,omitempty"` // Mode-specific response
+}
+
+// TaskCounts is the work done by a task
+type TaskCounts struct {
+	ImagesProcessed   int `json:"images_processed"`
+	ScenesProcessed   int `json:"scenes_processed"`
+	PerformersSynced  int `json:"performers_synced"`
+	PerformersCreated int `json:"performers_created"`
+	FacesMatched      int `json:"faces_matched"`
+	Failures          int `json:"failures"`
+}
+
+// ItemError is a failure of a single item that did not stop the task
+type ItemError struct {
+	Item  string `json:"item"` // e.g. "image:42"
+	Error string `json:"error"`
+}
+
+// taskItems collects the created performers and per-item errors of a task.
+// Safe for concurrent workers.
+type taskItems struct {
+	mu                sync.Mutex
+	createdPerformers []string
+	errors            []ItemError
+	failures          int
+}
+
+// performerCreated records a performer created by the task
+func (s *Service) performerCreated(performerID graphql.ID) {
+	s.summary.performersCreated.Add(1)
+
+	s.items.mu.Lock()
+	defer s.items.mu.Unlock()
+	s.items.createdPerformers = append(s.items.createdPerformers, string(performerID))
+}
+
+// itemFailed records an item the task failed on and moved past, and counts
+// the failure across runs unless the task was stopped while processing it or
+// a service it needed was unavailable
+func (s *Service) itemFailed(kind string, id graphql.ID, err error) {
+	if !errors.Is(err, context.Canceled) && !serviceUnavailable(err) {
+		s.recordFailure(kind, id)
+	}
+	s.reportItemFailed(kind, id, err)
+
+	s.items.mu.Lock()
+	defer s.items.mu.Unlock()
+	s.items.failures++
+	if len(s.items.errors) < maxItemErrors {
+		s.items.errors = append(s.items.errors, ItemError{
+			Item:  fmt.Sprintf("%s:%s", kind, id),
+			Error: log.Scrub(err.Error()),
+		})
+	}
+}
+
+// serviceUnavailable reports whether err came from Compreface, the Vision
+// Service or Stash being unreachable rather than from the item itself
+func serviceUnavailable(err error) bool {
+	return errors.Is(err, compreface.ErrServiceUnavailable) ||
+		errors.Is(err, vision.ErrServiceUnavailable) ||
+		errors.Is(err, stash.ErrServiceUnavailable)
+}
+
+// taskResult builds the result of a task from its message, mode-specific
+// response (nil when the mode has none) and error
+func (s *Service) taskResult(mode string, message string, response interface{}, started time.Time, err error) TaskResult {
+	s.items.mu.Lock()
+	defer s.items.mu.Unlock()
+
+	result := TaskResult{
+		Mode:       mode,
+		Success:    err == nil,
+		Message:    message,
+		DurationMs: time.Since(started).Milliseconds(),
+		Counts: TaskCounts{
+			ImagesProcessed:   int(s.summary.imagesProcessed.Load()),
+			ScenesProcessed:   int(s.summary.scenesProcessed.Load()),
+			PerformersSynced:  int(s.summary.performersSynced.Load()),
+			PerformersCreated: int(s.summary.performersCreated.Load()),
+			FacesMatched:      int(s.summary.facesMatched.Load()),
+			Failures:          s.items.failures,
+		},
+		CreatedPerformers: append([]string{}, s.items.createdPerformers...),
+		Errors:            append([]ItemError{}, s.items.errors...),
+	}
+	if err != nil {
+		result.Error = log.Scrub(err.Error())
+	}
+	if response != nil {
+		if raw, _err := json.Marshal(response); _err == nil {
+			result.Result = raw
+		} else {
+			log.Warnf("Failed to encode %s response: %v", mode, _err)
+		}
+	}
+	return result
+}
+
+// resultOutput writes a task result to the plugin output. Failed tasks also
+// set the output error, so Stash still reports them as failed.
+func (s *Service) resultOutput(output *common.PluginOutput, result TaskResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return s.errorOutput(output, fmt.Errorf("failed to encode task result: %w", err))
+	}
+	outputStr := string(data)
+	*output = common.PluginOutput{
+		Output: &outputStr,
+	}
+	if result.Error != "" {
+		errStr := result.Error
+		output.Error = &errStr
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package rpc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Gallery Consistency Review
+// ============================================================================
+//
+// Gallery images usually share a small set of performers. After a gallery is
+// identified, images whose matched performers share nothing with the gallery's
+// dominant performers are likely false positives (or the gallery is mixed),
+// and are tagged for manual review.
+//
+// ============================================================================
+
+const (
+	// minReviewImages is the number of matched images needed to judge a gallery
+	minReviewImages = 3
+	// dominantPerformerShare is the share of matched images a performer must
+	// appear in to count as one of the gallery's dominant performers
+	dominantPerformerShare = 0.5
+)
+
+// galleryImageMatches records the performers matched in one gallery image
+type galleryImageMatches struct {
+	image      stash.Image
+	performers []graphql.ID
+}
+
+// matchedPerformerIDs returns the distinct performer IDs among identities
+func matchedPerformerIDs(identities *[]FaceIdentity) []graphql.ID {
+	if identities == nil {
+		return nil
+	}
+	seen := map[graphql.ID]bool{}
+	var ids []graphql.ID
+	for _, identity := range *identities {
+		if identity.Performer.ID == nil || *identity.Performer.ID == "" {
+			continue
+		}
+		id := graphql.ID(*identity.Performer.ID)
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// dominantPerformers returns the performers matched in at least
+// dominantPerformerShare of the matched images
+func dominantPerformers(matches []galleryImageMatches) map[graphql.ID]bool {
+	counts := map[graphql.ID]int{}
+	matchedImages := 0
+	for _, m := range matches {
+		if len(m.performers) == 0 {
+			continue
+		}
+		matchedImages++
+		for _, id := range m.performers {
+			counts[id]++
+		}
+	}
+
+	dominant := map[graphql.ID]bool{}
+	for id, count := range counts {
+		if float64(count) >= dominantPerformerShare*float64(matchedImages) {
+			dominant[id] = true
+		}
+	}
+	return dominant
+}
+
+// reviewGalleryConsistency tags gallery images whose matched performers
+// conflict with the gallery majority and clears the tag from consistent images
+func (s *Service) reviewGalleryConsistency(gallery *stash.Gallery, matches []galleryImageMatches) error {
+	matchedImages := 0
+	for _, m := range matches {
+		if len(m.performers) > 0 {
+			matchedImages++
+		}
+	}
+	if matchedImages < minReviewImages {
+		log.Debugf("Gallery '%s': %d matched images, too few for consistency review", gallery.Title, matchedImages)
+		return nil
+	}
+
+	dominant := dominantPerformers(matches)
+	if len(dominant) == 0 {
+		log.Warnf("Gallery '%s': no performer appears in at least %.0f%% of %d matched images (possibly a mixed gallery)",
+			gallery.Title, dominantPerformerShare*100, matchedImages)
+		return nil
+	}
+
+	reviewTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.ReviewTagName, "Compreface Review")
+	if err != nil {
+		return fmt.Errorf("failed to get review tag: %w", err)
+	}
+
+	var flagged []string
+	for _, m := range matches {
+		if len(m.performers) == 0 {
+			continue
+		}
+
+		conflicting := true
+		for _, id := range m.performers {
+			if dominant[id] {
+				conflicting = false
+				break
+			}
+		}
+
+		var add, remove []graphql.ID
+		if conflicting {
+			flagged = append(flagged, string(m.image.ID))
+			add = []graphql.ID{reviewTagID}
+		} else if hasTag(m.image.Tags, reviewTagID) {
+			remove = []graphql.ID{reviewTagID}
+		} else {
+			continue
+		}
+
+		// Identification updated the image's tags, so work from fresh state
+		image, err := stash.GetImage(s.graphqlClient, m.image.ID)
+		if err != nil {
+			log.Warnf("Failed to get image %s for review tagging: %v", m.image.ID, err)
+			continue
+		}
+		if err := stash.UpdateImageTagsIfChanged(s.graphqlClient, image, add, remove); err != nil {
+			log.Warnf("Failed to update review tag on image %s: %v", m.image.ID, err)
+		}
+	}
+
+	if len(flagged) == 0 {
+		log.Infof("Gallery '%s': all %d matched images agree with the dominant performers", gallery.Title, matchedImages)
+		return nil
+	}
+
+	sort.Strings(flagged)
+	log.Warnf("Gallery '%s': %d of %d matched images conflict with the dominant performers and were tagged %q: %s",
+		gallery.Title, len(flagged), matchedImages, s.config.ReviewTagName, strings.Join(flagged, ", "))
+	return nil
+}
+
+// hasTag reports whether tags contains tagID
+func hasTag(tags []stash.Tag, tagID graphql.ID) bool {
+	for _, tag := range tags {
+		if tag.ID == tagID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,316 @@
+package rpc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+	"github.com/smegmarip/stash-compreface-plugin/pkg/utils"
+)
+
+// ============================================================================
+// New Face Review
+// ============================================================================
+//
+// With reviewNewFaces enabled, an unmatched face that passes the subject
+// creation quality gate is queued instead of becoming a new subject and
+// performer, and its image or scene is tagged for review. listPendingFaces
+// returns the queue with face crops; approvePendingFace creates the subject
+// and performer from an approved face (or records a rejection) and clears
+// the review tag once no face of the source is pending.
+//
+// ============================================================================
+
+// reviewQueueFile is the review queue's path under the plugin directory
+const reviewQueueFile = "data/review.jsonl"
+
+// openReviewQueue opens the queue of faces awaiting review. Failures are
+// logged and leave unmatched faces to be created as before.
+func (s *Service) openReviewQueue() func() {
+	if s.config.AnonymizationMode {
+		return func() {}
+	}
+
+	path := filepath.Join(s.serverConnection.PluginDir, filepath.FromSlash(reviewQueueFile))
+	queue, err := store.OpenReviewQueue(path)
+	if err != nil {
+		log.Warnf("Face review queue disabled: %v", err)
+		return func() {}
+	}
+
+	s.reviewQueue = queue
+	return func() {
+		s.reviewQueue = nil
+		if err := queue.Close(); err != nil {
+			log.Warnf("Failed to close face review queue: %v", err)
+		}
+	}
+}
+
+// reviewsNewFaces reports whether unmatched faces are queued for review
+// instead of being created
+func (s *Service) reviewsNewFaces() bool {
+	return s.config.ReviewNewFaces && s.reviewQueue != nil
+}
+
+// queueFaceForReview queues an unmatched or ambiguously matched face of a
+// source ("image:12", "scene:7") for review
+func (s *Service) queueFaceForReview(face store.PendingFace, crop []byte) error {
+	pending, added, err := s.reviewQueue.Add(face, crop)
+	if err != nil {
+		return fmt.Errorf("failed to queue face for review: %w", err)
+	}
+	if added {
+		log.Infof("Face %s of %s queued for review as %s", face.Face, face.Source, pending.ID)
+	} else {
+		log.Debugf("Face %s of %s already in the review queue (%s)", face.Face, face.Source, pending.Status)
+	}
+	return nil
+}
+
+// pendingReviewTags returns the review tag when a source has faces awaiting
+// review, for inclusion in the source's status tag update
+func (s *Service) pendingReviewTags(source string) []graphql.ID {
+	if s.reviewQueue == nil || s.reviewQueue.PendingForSource(source) == 0 {
+		return nil
+	}
+	tagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.ReviewTagName, "Compreface Review")
+	if err != nil {
+		log.Warnf("Failed to get review tag: %v", err)
+		return nil
+	}
+	return []graphql.ID{tagID}
+}
+
+// listPendingFaces returns up to limit faces awaiting review, oldest first
+func (s *Service) listPendingFaces(limit int) (*PendingFacesResponse, error) {
+	if s.reviewQueue == nil {
+		return nil, fmt.Errorf("face review queue is unavailable")
+	}
+
+	pending := s.reviewQueue.Pending()
+	response := &PendingFacesResponse{Total: len(pending), Faces: []PendingFaceEntry{}}
+	for _, face := range pending {
+		if limit > 0 && len(response.Faces) >= limit {
+			break
+		}
+		entry := PendingFaceEntry{PendingFace: face}
+		if crop, err := s.reviewQueue.Crop(face.ID); err == nil {
+			entry.Image = "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(crop)
+		} else {
+			log.Warnf("Pending face %s: %v", face.ID, err)
+		}
+		response.Faces = append(response.Faces, entry)
+	}
+
+	log.Infof("%d face(s) awaiting review", len(pending))
+	return response, nil
+}
+
+// approvePendingFace creates a subject and performer from a face awaiting
+// review and associates the performer with the face's image or scene. With
+// performerID set, the face is added to that performer's subject instead
+// (e.g. to settle an ambiguous match); with reject set, it is only marked
+// rejected.
+func (s *Service) approvePendingFace(faceID string, performerID string, reject bool) (string, error) {
+	if s.reviewQueue == nil {
+		return "", fmt.Errorf("face review queue is unavailable")
+	}
+
+	face, ok := s.reviewQueue.Get(faceID)
+	if !ok {
+		return "", fmt.Errorf("pending face %s not found", faceID)
+	}
+	if face.Status != store.ReviewPending {
+		return "", fmt.Errorf("face %s was already %s", faceID, face.Status)
+	}
+
+	if reject {
+		if err := s.reviewQueue.Resolve(faceID, store.ReviewRejected, ""); err != nil {
+			return "", err
+		}
+		s.clearReviewTag(face.Source)
+		summary := fmt.Sprintf("Rejected face %s of %s", faceID, face.Source)
+		log.Info(summary)
+		return summary, nil
+	}
+
+	crop, err := s.reviewQueue.Crop(faceID)
+	if err != nil {
+		return "", err
+	}
+
+	kind, sourceID, _ := strings.Cut(face.Source, ":")
+	if performerID != "" {
+		return s.assignPendingFace(face, crop, kind, sourceID, performerID)
+	}
+
+	addResp, err := s.comprefaceClient.AddSubjectFromBytes(compreface.CreateSubjectName(sourceID), crop, "face.jpg")
+	if err != nil {
+		return "", fmt.Errorf("failed to add subject to Compreface: %w", err)
+	}
+	log.Infof("Created Compreface subject '%s' (image_id: %s)", addResp.Subject, addResp.ImageID)
+
+	newPerformerID, ok := s.stashBoxPerformer(face.Source, crop, addResp.Subject, face.Gender, face.AgeLow, face.AgeHigh)
+	if !ok {
+		performer, err := s.createPerformerWithDetails(stash.PerformerSubject{
+			Name:    addResp.Subject,
+			Age:     s.birthdateAge(face.AgeLow, face.AgeHigh),
+			AgeLow:  face.AgeLow,
+			AgeHigh: face.AgeHigh,
+			Gender:  face.Gender,
+			Image:   s.subjectImageDataURI(addResp.ImageID),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to create performer: %w", err)
+		}
+		newPerformerID = performer.ID
+	}
+
+	if err := s.reviewQueue.Resolve(faceID, store.ReviewApproved, string(newPerformerID)); err != nil {
+		return "", err
+	}
+	if err := s.associateReviewedPerformer(kind, graphql.ID(sourceID), newPerformerID); err != nil {
+		log.Warnf("Failed to associate performer %s with %s: %v", newPerformerID, face.Source, err)
+	}
+	s.clearReviewTag(face.Source)
+
+	summary := fmt.Sprintf("Approved face %s of %s: subject %s, performer %s", faceID, face.Source, addResp.Subject, newPerformerID)
+	log.Info(summary)
+	return summary, nil
+}
+
+// assignPendingFace adds a face awaiting review to an existing performer's
+// subject as an example and associates the performer with the face's source
+func (s *Service) assignPendingFace(face store.PendingFace, crop []byte, kind string, sourceID string, performerID string) (string, error) {
+	performer, err := stash.GetPerformerByID(s.graphqlClient, graphql.ID(performerID))
+	if err != nil {
+		return "", fmt.Errorf("failed to get performer: %w", err)
+	}
+	if performer.ID == "" {
+		return "", fmt.Errorf("performer %s not found", performerID)
+	}
+	subject := compreface.FindPersonAlias(performer)
+	if subject == "" {
+		return "", fmt.Errorf("performer %s has no 'Person ...' alias; synchronize it first", performer.Name)
+	}
+
+	if _, err := s.comprefaceClient.AddSubjectFromBytes(subject, crop, "face.jpg"); err != nil {
+		return "", fmt.Errorf("failed to add example to subject %s: %w", subject, err)
+	}
+	if err := s.reviewQueue.Resolve(face.ID, store.ReviewApproved, performerID); err != nil {
+		return "", err
+	}
+	if err := s.associateReviewedPerformer(kind, graphql.ID(sourceID), performer.ID); err != nil {
+		log.Warnf("Failed to associate performer %s with %s: %v", performer.ID, face.Source, err)
+	}
+	s.clearReviewTag(face.Source)
+
+	summary := fmt.Sprintf("Assigned face %s of %s to performer %s (%s)", face.ID, face.Source, performer.ID, performer.Name)
+	log.Info(summary)
+	return summary, nil
+}
+
+// associateReviewedPerformer adds a performer, such as one created from an
+// approved face, to an image or scene
+func (s *Service) associateReviewedPerformer(kind string, sourceID graphql.ID, performerID graphql.ID) error {
+	switch kind {
+	case "image":
+		image, err := stash.GetImage(s.graphqlClient, sourceID)
+		if err != nil {
+			return fmt.Errorf("failed to get image: %w", err)
+		}
+		return s.associateExistingPerformers(*image, []graphql.ID{performerID})
+	case "scene":
+		scene, err := stash.GetScene(s.graphqlClient, sourceID)
+		if err != nil {
+			return fmt.Errorf("failed to get scene: %w", err)
+		}
+		performerIDs := []graphql.ID{performerID}
+		for _, p := range scene.Performers {
+			performerIDs = append(performerIDs, p.ID)
+		}
+		return stash.UpdateScenePerformers(s.graphqlClient, sourceID, utils.DeduplicateIDs(performerIDs))
+	}
+	return fmt.Errorf("unknown source kind %q", kind)
+}
+
+// clearReviewTag removes the review tag from a source once none of its faces
+// await review
+func (s *Service) clearReviewTag(source string) {
+	if s.reviewQueue.PendingForSource(source) > 0 {
+		return
+	}
+	tagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.ReviewTagName, "Compreface Review")
+	if err != nil {
+		log.Warnf("Failed to get review tag: %v", err)
+		return
+	}
+
+	kind, sourceID, _ := strings.Cut(source, ":")
+	switch kind {
+	case "image":
+		image, err := stash.GetImage(s.graphqlClient, graphql.ID(sourceID))
+		if err == nil {
+			err = stash.UpdateImageTagsIfChanged(s.graphqlClient, image, nil, []graphql.ID{tagID})
+		}
+		if err != nil {
+			log.Warnf("Failed to remove review tag from %s: %v", source, err)
+		}
+	case "scene":
+		scene, err := stash.GetScene(s.graphqlClient, graphql.ID(sourceID))
+		if err == nil {
+			err = stash.UpdateSceneTagsIfChanged(s.graphqlClient, scene, nil, []graphql.ID{tagID})
+		}
+		if err != nil {
+			log.Warnf("Failed to remove review tag from %s: %v", source, err)
+		}
+	}
+}
+
+// reviewVisionFace queues a Vision face for review: an unmatched face that
+// passes the subject creation quality gate, or an ambiguous match between
+// candidates
+func (s *Service) reviewVisionFace(ctx FaceProcessingContext, face vision.VisionFace, faceCrop []byte, candidates []string) error {
+	if len(candidates) == 0 {
+		qr := s.assessFaceQuality(face.RepresentativeDetection.Quality, s.config.MinQualityScore)
+		if !qr.Acceptable {
+			log.Debugf("Skipping face %s for review: %s", face.FaceID, qr.Reason)
+			return nil
+		}
+	}
+
+	pending := store.PendingFace{Source: faceSource(ctx), Face: face.FaceID, Candidates: candidates}
+	if face.Demographics != nil {
+		pending.AgeLow = face.Demographics.Age
+		pending.AgeHigh = face.Demographics.Age
+		pending.Gender = face.Demographics.Gender
+	}
+	return s.queueFaceForReview(pending, faceCrop)
+}
+
+// reviewComprefaceFace queues a face found by Compreface recognition for
+// review: unmatched, or an ambiguous match between candidates
+func (s *Service) reviewComprefaceFace(imageID string, imageBytes []byte, faceIndex int, result compreface.RecognitionResult, candidates []string) error {
+	faceCrop, err := s.cropFaceBytes(imageBytes, result.Box, 20)
+	if err != nil {
+		return fmt.Errorf("failed to crop face %d: %w", faceIndex, err)
+	}
+	return s.queueFaceForReview(store.PendingFace{
+		Source:     "image:" + imageID,
+		Face:       strconv.Itoa(faceIndex),
+		AgeLow:     result.Age.Low,
+		AgeHigh:    result.Age.High,
+		Gender:     result.Gender.Value,
+		Candidates: candidates,
+	}, faceCrop)
+}
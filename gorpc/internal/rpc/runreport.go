@@ -0,0 +1,212 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// ============================================================================
+// Run Reports
+// ============================================================================
+//
+// The task result only counts what a run did. With reportsDir set, every
+// batch run also writes a JSON report there listing each image, scene or
+// other item it touched: faces detected, and per face the matched subject
+// and performer, similarity, performers created and why faces were skipped,
+// so what the plugin did to thousands of items can be audited afterwards.
+// Reports are named after the mode and start time and are never pruned.
+//
+// ============================================================================
+
+// Item statuses in a run report
+const (
+	ReportItemProcessed = "processed"
+	ReportItemSkipped   = "skipped"
+	ReportItemFailed    = "failed"
+)
+
+// Face statuses in a run report
+const (
+	ReportFaceMatched   = "matched"   // Matched to an existing performer
+	ReportFaceCreated   = "created"   // New subject and performer created
+	ReportFaceUnmatched = "unmatched" // No performer; see Reason
+	ReportFaceQueued    = "queued"    // Queued for review
+	ReportFaceSkipped   = "skipped"   // Not recognized, e.g. below the quality bar
+	ReportFaceFailed    = "failed"
+)
+
+// RunReport is the report file written for a batch run
+type RunReport struct {
+	TaskResult
+	Started time.Time    `json:"started"`
+	Items   []ReportItem `json:"items"` // In the order items were first touched
+}
+
+// ReportItem is what a run did to one item
+type ReportItem struct {
+	Item          string       `json:"item"` // e.g. "image:42"
+	Status        string       `json:"status"`
+	Reason        string       `json:"reason,omitempty"` // Why it was skipped or failed
+	FacesDetected int          `json:"faces_detected"`   // Faces passing the recognition quality bar
+	Faces         []ReportFace `json:"faces"`
+}
+
+// ReportFace is the outcome of one face of an item
+type ReportFace struct {
+	FaceID      string  `json:"face_id,omitempty"`
+	Status      string  `json:"status"`
+	Subject     string  `json:"subject,omitempty"`
+	PerformerID string  `json:"performer_id,omitempty"`
+	Similarity  float64 `json:"similarity,omitempty"`
+	Reason      string  `json:"reason,omitempty"`
+}
+
+// runReport collects the items of a run. A nil runReport, used when reports
+// are disabled, ignores every call. Safe for concurrent workers.
+type runReport struct {
+	mu    sync.Mutex
+	items map[string]*ReportItem
+	order []string
+}
+
+// item returns the entry of an item, creating it; callers hold the lock
+func (r *runReport) item(kind string, id graphql.ID) *ReportItem {
+	key := fmt.Sprintf("%s:%s", kind, id)
+	if entry, ok := r.items[key]; ok {
+		return entry
+	}
+	entry := &ReportItem{Item: key, Status: ReportItemProcessed, Faces: []ReportFace{}}
+	r.items[key] = entry
+	r.order = append(r.order, key)
+	return entry
+}
+
+// startRunReport starts collecting a report for a batch run when reports are
+// enabled
+func (s *Service) startRunReport() {
+	if s.config.ReportsDir != "" {
+		s.report = &runReport{items: map[string]*ReportItem{}}
+	}
+}
+
+// reportFacesDetected records the faces detected in an item
+func (s *Service) reportFacesDetected(kind string, id graphql.ID, faces int) {
+	if s.report == nil {
+		return
+	}
+	s.report.mu.Lock()
+	defer s.report.mu.Unlock()
+	s.report.item(kind, id).FacesDetected = faces
+}
+
+// reportSkipped records an item the run skipped
+func (s *Service) reportSkipped(kind string, id graphql.ID, reason string) {
+	if s.report == nil {
+		return
+	}
+	s.report.mu.Lock()
+	defer s.report.mu.Unlock()
+	entry := s.report.item(kind, id)
+	entry.Status = ReportItemSkipped
+	entry.Reason = reason
+}
+
+// reportItemFailed records an item the run failed on
+func (s *Service) reportItemFailed(kind string, id graphql.ID, err error) {
+	if s.report == nil {
+		return
+	}
+	s.report.mu.Lock()
+	defer s.report.mu.Unlock()
+	entry := s.report.item(kind, id)
+	entry.Status = ReportItemFailed
+	entry.Reason = log.Scrub(err.Error())
+}
+
+// reportFace records the outcome of a face processed in a scene or image
+func (s *Service) reportFace(ctx FaceProcessingContext, face vision.VisionFace, outcome ReportFace) {
+	if s.report == nil {
+		return
+	}
+	kind := "image"
+	if ctx.Scene != nil {
+		kind = "scene"
+	}
+	outcome.FaceID = face.FaceID
+	outcome.Reason = log.Scrub(outcome.Reason)
+
+	s.report.mu.Lock()
+	defer s.report.mu.Unlock()
+	entry := s.report.item(kind, graphql.ID(ctx.SourceID))
+	entry.Faces = append(entry.Faces, outcome)
+}
+
+// reportIdentities records the faces identified in an image
+func (s *Service) reportIdentities(imageID string, facesDetected int, identities []FaceIdentity) {
+	if s.report == nil {
+		return
+	}
+	s.report.mu.Lock()
+	defer s.report.mu.Unlock()
+	entry := s.report.item("image", graphql.ID(imageID))
+	entry.FacesDetected = facesDetected
+	for _, identity := range identities {
+		outcome := ReportFace{Status: ReportFaceUnmatched}
+		if identity.Performer.ID != nil && *identity.Performer.ID != "" {
+			outcome.Status = ReportFaceMatched
+			outcome.PerformerID = *identity.Performer.ID
+		}
+		if identity.Confidence != nil {
+			outcome.Similarity = *identity.Confidence / 100
+		}
+		if identity.MatchedExample != nil {
+			outcome.Subject = identity.MatchedExample.Subject
+		}
+		entry.Faces = append(entry.Faces, outcome)
+	}
+}
+
+// writeRunReport writes the collected report of a run to the reports
+// directory. Failures are logged and never fail the task.
+func (s *Service) writeRunReport(result TaskResult, started time.Time) {
+	if s.report == nil {
+		return
+	}
+
+	s.report.mu.Lock()
+	report := RunReport{TaskResult: result, Started: started, Items: make([]ReportItem, 0, len(s.report.order))}
+	for _, key := range s.report.order {
+		report.Items = append(report.Items, *s.report.items[key])
+	}
+	s.report.mu.Unlock()
+
+	dir := s.config.ReportsDir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(s.serverConnection.PluginDir, dir)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Warnf("Failed to create reports directory: %v", err)
+		return
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Warnf("Failed to encode run report: %v", err)
+		return
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.json", result.Mode, started.Format("20060102-150405")))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Warnf("Failed to write run report: %v", err)
+		return
+	}
+	log.Infof("Run report written to %s (%d item(s))", path, len(report.Items))
+}
@@ -0,0 +1,150 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/pkg/utils"
+)
+
+// ============================================================================
+// Performers from Scene Frames
+// ============================================================================
+//
+// createPerformerFromScene is the scene counterpart of createPerformerFromImage
+// for manual curation: the user picks a moment of a scene, the frame server
+// extracts that frame, Compreface detects and recognizes its faces, and the
+// face at faceIndex (counted left to right) becomes a new subject and
+// performer added to the scene. A face that already matches a subject is
+// added to the scene as that subject's performer instead of a duplicate.
+//
+// ============================================================================
+
+// createPerformerFromScene creates a subject and performer from a face in the
+// frame of a scene at timestamp (seconds) and adds the performer to the scene
+func (s *Service) createPerformerFromScene(sceneID string, timestamp float64, faceIndex int) (string, error) {
+	itemTrace := trace.New("scn", sceneID)
+	defer trace.Start(itemTrace)()
+
+	scene, err := stash.GetScene(s.graphqlClient, graphql.ID(sceneID))
+	if err != nil {
+		return "", fmt.Errorf("failed to get scene: %w", err)
+	}
+	videoFile := stash.CanonicalVideoFile(scene)
+	if videoFile == nil {
+		return "", fmt.Errorf("scene %s has no files", sceneID)
+	}
+	if videoFile.Duration > 0 && timestamp > videoFile.Duration {
+		return "", fmt.Errorf("timestamp %.2fs is past the end of scene %s (%.2fs)", timestamp, sceneID, videoFile.Duration)
+	}
+
+	// Step 1: Extract the frame
+	span := trace.StartSpan(trace.StageFetch)
+	frame, err := s.newVisionClient().ExtractFrame(videoFile.Path, timestamp, nil)
+	span.End(err)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract frame at %.2fs: %w", timestamp, err)
+	}
+
+	// Step 2: Detect and recognize the frame's faces, left to right
+	span = trace.StartSpan(trace.StageRecognize)
+	recognitionResp, err := s.comprefaceClient.RecognizeFacesFromBytes(frame, "frame.jpg")
+	span.End(err)
+	if err != nil {
+		if errors.Is(err, compreface.ErrNoFaceFound) {
+			return "", fmt.Errorf("no faces found in scene %s at %.2fs", sceneID, timestamp)
+		}
+		return "", fmt.Errorf("failed to recognize faces: %w", err)
+	}
+	faces := recognitionResp.Result
+	if len(faces) == 0 {
+		return "", fmt.Errorf("no faces found in scene %s at %.2fs", sceneID, timestamp)
+	}
+	sort.SliceStable(faces, func(i, j int) bool {
+		return faces[i].Box.XMin < faces[j].Box.XMin
+	})
+	if faceIndex >= len(faces) {
+		return "", fmt.Errorf("face index %d out of range (detected %d faces at %.2fs)", faceIndex, len(faces), timestamp)
+	}
+	face := faces[faceIndex]
+	trace.Set(trace.Face(itemTrace, faceIndex))
+	log.Infof("Scene %s: %d face(s) at %.2fs, using face %d", sceneID, len(faces), timestamp, faceIndex)
+
+	if !utils.IsFaceSizeValid(face.Box, s.config.MinFaceSize) {
+		width, height := utils.GetFaceDimensions(face.Box)
+		return "", fmt.Errorf("face %d is too small (%dx%d, minimum %d)", faceIndex, width, height, s.config.MinFaceSize)
+	}
+
+	span = trace.StartSpan(trace.StageCrop)
+	crop, err := s.cropFaceBytes(frame, face.Box, 20)
+	span.End(err)
+	if err != nil {
+		return "", fmt.Errorf("failed to crop face %d: %w", faceIndex, err)
+	}
+
+	// Step 3: Reuse the performer of a face that already has a subject
+	var performerID graphql.ID
+	var subject string
+	if match, ok := s.selectMatch(face.Subjects, nil, nil); ok && !match.ambiguous {
+		existingID, err := stash.FindPerformerBySubjectName(s.graphqlClient, match.subject.Subject)
+		if err != nil {
+			return "", fmt.Errorf("failed to find performer for subject %s: %w", match.subject.Subject, err)
+		}
+		if existingID != "" {
+			performerID, subject = existingID, match.subject.Subject
+			log.Infof("Face %d matches subject '%s' (similarity %.2f), using its performer %s",
+				faceIndex, subject, match.subject.Similarity, performerID)
+		}
+	}
+
+	// Step 4: Otherwise create a subject and performer from the face
+	created := performerID == ""
+	if created {
+		addResp, err := s.comprefaceClient.AddSubjectFromBytes(compreface.CreateSubjectName(sceneID), crop, "face.jpg")
+		if err != nil {
+			return "", fmt.Errorf("failed to add subject to Compreface: %w", err)
+		}
+		subject = addResp.Subject
+		log.Infof("Created Compreface subject '%s' (image_id: %s)", subject, addResp.ImageID)
+
+		newPerformerID, ok := s.stashBoxPerformer("scene:"+sceneID, crop, subject, face.Gender.Value, face.Age.Low, face.Age.High)
+		if !ok {
+			performer, err := s.createPerformerWithDetails(stash.PerformerSubject{
+				Name:    subject,
+				Age:     s.birthdateAge(face.Age.Low, face.Age.High),
+				AgeLow:  face.Age.Low,
+				AgeHigh: face.Age.High,
+				Gender:  face.Gender.Value,
+				Image:   s.subjectImageDataURI(addResp.ImageID),
+			})
+			if err != nil {
+				return "", fmt.Errorf("failed to create performer: %w", err)
+			}
+			newPerformerID = performer.ID
+		}
+		performerID = newPerformerID
+	}
+
+	// Step 5: Add the performer to the scene
+	span = trace.StartSpan(trace.StageMutate)
+	err = s.associateReviewedPerformer("scene", scene.ID, performerID)
+	span.End(err)
+	if err != nil {
+		return "", fmt.Errorf("failed to add performer %s to scene %s: %w", performerID, sceneID, err)
+	}
+
+	verb := "Created"
+	if !created {
+		verb = "Matched"
+	}
+	summary := fmt.Sprintf("%s performer %s (subject %s) from face %d of scene %s at %.2fs", verb, performerID, subject, faceIndex, sceneID, timestamp)
+	log.Info(summary)
+	return summary, nil
+}
@@ -2,24 +2,42 @@ package rpc
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	graphql "github.com/hasura/go-graphql-client"
-	"github.com/stashapp/stash/pkg/plugin/common/log"
 
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/config"
 	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
 	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
 )
 
-// recognizeScenes performs face recognition on scenes using Vision Service
-func (s *Service) recognizeScenes(useSprites bool, scanPartial bool, limit int) error {
+// SceneScope selects the scenes a scene recognition run processes. Complete
+// scenes are excluded from every scope.
+type SceneScope string
+
+const (
+	SceneScopeNew     SceneScope = "new"     // Scenes never scanned
+	SceneScopePartial SceneScope = "partial" // Scanned scenes tagged Partial
+	SceneScopeAll     SceneScope = "all"     // Every scene not yet Complete
+)
+
+// recognizeScenes performs face recognition on the scenes of a scope using
+// Vision Service
+func (s *Service) recognizeScenes(useSprites bool, scope SceneScope, limit int, createNewSubjects bool) error {
 	// Check if Vision Service is configured
 	if s.config.VisionServiceURL == "" {
 		return fmt.Errorf("vision service URL not configured")
 	}
 
 	// Initialize Vision Service client
-	visionClient := vision.NewVisionServiceClient(s.config.VisionServiceURL, s.config.FrameServerURL)
+	visionClient := s.newVisionClient()
 
 	// Health check
 	if err := visionClient.HealthCheck(); err != nil {
@@ -29,7 +47,7 @@ func (s *Service) recognizeScenes(useSprites bool, scanPartial bool, limit int)
 
 	filterTagName := s.config.ScannedTagName
 
-	log.Debugf("Starting scene recognition (useSprites=%t, scanPartial=%t, limit=%d)", useSprites, scanPartial, limit)
+	log.Debugf("Starting scene recognition (useSprites=%t, scope=%s, limit=%d, createNewSubjects=%t)", useSprites, scope, limit, createNewSubjects)
 
 	// Get or create tags
 	scannedTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, filterTagName, "Compreface Scanned")
@@ -42,108 +60,266 @@ func (s *Service) recognizeScenes(useSprites bool, scanPartial bool, limit int)
 		return fmt.Errorf("failed to get matched tag: %w", err)
 	}
 
+	// Get completion tag ID for filtering (exclude already-complete scenes)
+	completeTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.CompleteTagName, "Compreface Complete")
+	if err != nil {
+		return fmt.Errorf("failed to get complete tag: %w", err)
+	}
+
+	excludeTags, err := s.sceneScopeTags(scope, scannedTagID, completeTagID)
+	if err != nil {
+		return err
+	}
+
+	// Count all candidates up front so progress spans every pass
+	_, total, err := s.findScenes(excludeTags, nil, 1, 1)
+	if err != nil {
+		return fmt.Errorf("failed to query scenes: %w", err)
+	}
+
+	// Apply limit if specified
+	if limit > 0 && limit < total {
+		log.Infof("Found %d scenes, limiting to %d", total, limit)
+		total = limit
+	} else {
+		log.Infof("Found %d scenes to process", total)
+	}
+
 	// Fetch scenes in batches
-	page := 0
 	batchSize := s.config.MaxBatchSize
 	processedCount := 0
-	total := 0
+	var scannedPaths []string // Files of successfully processed scenes, for the metadata scan
+
+	// Stash writes for each scene overlap detection of the next
+	stopWriter := s.startWriter()
+	defer stopWriter()
+
+	budget := s.newErrorBudget()
+	task := sceneTask(useSprites, scope)
+	resumed := s.resumePoint(task)
+	seed := s.batchSeed(resumed)
+	sceneID := func(scene stash.Scene) graphql.ID { return scene.ID }
 
-	for {
-		if s.stopping {
+	// processOne processes a scene, returning an error once the task has to stop
+	processOne := func(scene stash.Scene) error {
+		if s.stopped() {
 			return fmt.Errorf("task cancelled")
 		}
 
-		page++
+		processedCount++
+		progress := float64(processedCount) / float64(total)
+		s.reportProgress(progress)
 
-		// Query scenes
-		var scenes []stash.Scene
-		var sceneCount int
-		var err error
-		if scanPartial {
-			scenes, sceneCount, err = findScenes(s.graphqlClient, nil, 1, batchSize)
+		log.Infof("[%d/%d] Processing scene %s", processedCount, total, scene.ID)
+
+		create := createNewSubjects && s.createsFromMedia("Scene", scene.ID, scene.Rating, scene.Organized)
+		_, err := s.processScene(visionClient, scene, scannedTagID, matchedTagID, useSprites, create, true)
+		if err != nil {
+			log.Warnf("Failed to process scene %s: %v", scene.ID, err)
+			s.itemFailed("scene", scene.ID, err)
 		} else {
-			scenes, sceneCount, err = findScenes(s.graphqlClient, &scannedTagID, 1, batchSize)
+			s.clearFailures("scene", scene.ID)
+			if file := stash.CanonicalVideoFile(&scene); file != nil {
+				scannedPaths = append(scannedPaths, file.Path)
+			}
 		}
-		if err != nil {
-			return fmt.Errorf("failed to query scenes: %w", err)
+		if budgetErr := budget.record(err); budgetErr != nil {
+			log.Errorf("Scene recognition: %d scenes processed", processedCount)
+			return budgetErr
 		}
+		return nil
+	}
 
-		if page == 1 {
-			total = sceneCount
+	for pass, performerCount := range s.performerCountPasses() {
+		if pass < resumed.Pass {
+			continue
+		}
+		logPerformerCountPass(performerCount, "scenes")
+		page := 0
+		lastID := 0 // Batches continue after the last scene processed
+		if pass == resumed.Pass {
+			page = resumed.Page
+			lastID = resumed.LastID
+		}
+		shuffled := map[graphql.ID]bool{} // Scenes handed out by the random order
+		var deferred []stash.Scene        // Scenes that failed in earlier runs, processed last
 
-			// Apply limit if specified
-			if limit > 0 && limit < total {
-				total = limit
-				log.Infof("Found %d scenes, limiting to %d", sceneCount, limit)
-			} else {
-				log.Infof("Found %d scenes to process", total)
+		for {
+			if s.stopped() {
+				return fmt.Errorf("task cancelled")
 			}
-		}
 
-		if len(scenes) == 0 {
-			break
-		}
+			page++
+
+			// Apply pending writes so the query sees updated tags
+			s.flushWrites()
+
+			// Query scenes: newest first pages down by ID, random reads the
+			// start of the shuffle, other orders page up by ID
+			filter := stash.SceneFilterType{Tags: excludeTags, PerformerCount: performerCount}
+			s.excludeIgnoredScenes(&filter)
+			s.scopeScenes(&filter)
+			var scenes []stash.Scene
+			var err error
+			switch s.config.BatchOrder {
+			case config.BatchOrderNewest:
+				scenes, _, err = stash.FindScenesBefore(s.graphqlClient, &filter, lastID, batchSize)
+			case config.BatchOrderRandom:
+				scenes, err = findShuffled(batchSize, shuffled, sceneID, func(perPage int) ([]stash.Scene, error) {
+					scenes, _, err := stash.FindScenesSorted(s.graphqlClient, &filter, shuffleSort(seed), 1, perPage)
+					return scenes, err
+				})
+			default:
+				scenes, _, err = stash.FindScenesAfter(s.graphqlClient, &filter, lastID, batchSize)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to query scenes: %w", err)
+			}
 
-		log.Infof("Processing batch %d: %d scenes", page, len(scenes))
+			if len(scenes) == 0 {
+				break
+			}
 
-		// Process each scene
-		for _, scene := range scenes {
-			if s.stopping {
-				return fmt.Errorf("task cancelled")
+			log.Infof("Processing batch %d: %d scenes", page, len(scenes))
+
+			// Process each scene
+			for _, scene := range scenes {
+				// Check if limit reached
+				if limit > 0 && processedCount >= limit {
+					log.Infof("Reached limit of %d scenes, stopping", limit)
+					break
+				}
+
+				lastID, _ = strconv.Atoi(string(scene.ID))
+				if s.config.BatchOrder == config.BatchOrderFailures && s.failureCount("scene", scene.ID) > 0 {
+					deferred = append(deferred, scene)
+					continue
+				}
+				if err := processOne(scene); err != nil {
+					return err
+				}
+				s.checkpoint(store.Checkpoint{Task: task, Pass: pass, Page: page, LastID: lastID, Seed: seed, Processed: processedCount})
 			}
 
-			// Check if limit reached
+			// Break outer loop if limit reached
 			if limit > 0 && processedCount >= limit {
-				log.Infof("Reached limit of %d scenes, stopping", limit)
 				break
 			}
 
-			processedCount++
-			progress := float64(processedCount) / float64(total)
-			log.Progress(progress)
-
-			log.Infof("[%d/%d] Processing scene %s", processedCount, total, scene.ID)
+			if len(scenes) < batchSize {
+				break
+			}
+		}
 
-			err := s.processScene(visionClient, scene, scannedTagID, matchedTagID, useSprites)
-			if err != nil {
-				log.Warnf("Failed to process scene %s: %v", scene.ID, err)
-				continue
+		// Scenes that failed before get their turn once the rest are done
+		if len(deferred) > 0 && (limit == 0 || processedCount < limit) {
+			sortByFailures(s, "scene", deferred, sceneID)
+			log.Infof("Processing %d scenes that failed in earlier runs, fewest failures first", len(deferred))
+			for _, scene := range deferred {
+				if limit > 0 && processedCount >= limit {
+					break
+				}
+				if err := processOne(scene); err != nil {
+					return err
+				}
 			}
 		}
 
-		// Break outer loop if limit reached
 		if limit > 0 && processedCount >= limit {
 			break
 		}
+	}
 
-		// Apply cooldown after batch
-		if len(scenes) == batchSize && processedCount < total {
-			s.applyCooldown()
-		}
-
-		if len(scenes) < batchSize {
-			break
-		}
+	// A run stopped by its limit keeps its checkpoint for the next resume
+	if limit == 0 || processedCount < limit {
+		s.clearCheckpoint(task)
 	}
 
-	log.Progress(1.0)
+	s.reportProgress(1.0)
 	log.Infof("Scene recognition completed: %d scenes processed", processedCount)
 
-	// Trigger metadata scan
-	if err := stash.TriggerMetadataScan(s.graphqlClient); err != nil {
-		log.Warnf("Failed to trigger metadata scan: %v", err)
+	// Rescan the processed scenes' files when enabled
+	if s.config.TriggerMetadataScan && len(scannedPaths) > 0 {
+		s.flushWrites()
+		if err := stash.TriggerMetadataScan(s.graphqlClient, scannedPaths); err != nil {
+			log.Warnf("Failed to trigger metadata scan: %v", err)
+		}
 	}
 
 	return nil
 }
 
-// processScene processes a single scene through Vision Service
-func (s *Service) processScene(visionClient *vision.VisionServiceClient, scene stash.Scene, scannedTagID, matchedTagID graphql.ID, useSprites bool) error {
-	// Get video path from files
-	if len(scene.Files) == 0 {
-		return fmt.Errorf("scene %s has no files", scene.ID)
+// identifyScene recognizes the faces of a single scene like batch scene
+// recognition, and returns the outcome of every face cluster with the
+// matched performers' names. Matched performers and status tags are only
+// written to the scene when associateExisting is true.
+func (s *Service) identifyScene(sceneID string, createPerformer bool, associateExisting bool, useSprites bool) (*SceneIdentification, error) {
+	if s.config.VisionServiceURL == "" {
+		return nil, fmt.Errorf("vision service URL not configured")
+	}
+
+	visionClient := s.newVisionClient()
+	if err := visionClient.HealthCheck(); err != nil {
+		return nil, fmt.Errorf("vision service health check failed: %w", err)
+	}
+
+	scene, err := stash.GetScene(s.graphqlClient, graphql.ID(sceneID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scene: %w", err)
+	}
+
+	scannedTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.ScannedTagName, "Compreface Scanned")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scanned tag: %w", err)
+	}
+	matchedTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.MatchedTagName, "Compreface Matched")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get matched tag: %w", err)
+	}
+
+	result, err := s.processScene(visionClient, *scene, scannedTagID, matchedTagID, useSprites, createPerformer, associateExisting)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]string{}
+	for i := range result.Clusters {
+		performer := &result.Clusters[i].Performer
+		if performer.ID == nil {
+			continue
+		}
+		name, ok := names[*performer.ID]
+		if !ok {
+			if p, err := stash.GetPerformerByID(s.graphqlClient, graphql.ID(*performer.ID)); err == nil {
+				name = p.Name
+			}
+			names[*performer.ID] = name
+		}
+		performer.Name = name
+	}
+	return result, nil
+}
+
+// processScene processes a single scene through Vision Service, returning
+// the outcome for every face cluster found. Matched performers and status
+// tags are written to the scene only when associate is true.
+func (s *Service) processScene(visionClient *vision.VisionServiceClient, scene stash.Scene, scannedTagID, matchedTagID graphql.ID, useSprites bool, createNewSubjects bool, associate bool) (*SceneIdentification, error) {
+	itemTrace := trace.New("scn", string(scene.ID))
+	defer trace.Start(itemTrace)()
+	s.summary.scenesProcessed.Add(1)
+	result := &SceneIdentification{SceneID: string(scene.ID), Clusters: []SceneFaceCluster{}, TraceID: itemTrace}
+
+	// Get video path from files; alternate files (trailers, previews) are
+	// skipped in favour of the longest file so the scene is analyzed once
+	videoFile := stash.CanonicalVideoFile(&scene)
+	if videoFile == nil {
+		return nil, fmt.Errorf("scene %s has no files", scene.ID)
+	}
+	videoPath := videoFile.Path
+	if len(scene.Files) > 1 {
+		log.Infof("Scene %s: %d files, analyzing longest (%s, %.0fs) and skipping %d alternate(s)",
+			scene.ID, len(scene.Files), videoPath, videoFile.Duration, len(scene.Files)-1)
 	}
-	videoPath := scene.Files[0].Path
 
 	// Build Vision Service request
 	var spriteVTT, spriteImage string
@@ -154,14 +330,8 @@ func (s *Service) processScene(visionClient *vision.VisionServiceClient, scene s
 
 	minConfidence := s.config.MinConfidenceScore
 	minQuality := s.config.MinProcessingQualityScore
-	qualityTrigger := s.config.EnhanceQualityScoreTrigger
 
-	enhancementParams := vision.EnhancementParameters{
-		Enabled:        true,
-		QualityTrigger: qualityTrigger,
-		Model:          "codeformer",
-		FidelityWeight: 0.25,
-	}
+	enhancementParams := s.buildEnhancementParameters()
 
 	parameters := vision.FacesParameters{
 		FaceMinConfidence:            minConfidence, // Mid-High confidence detections only
@@ -171,45 +341,45 @@ func (s *Service) processScene(visionClient *vision.VisionServiceClient, scene s
 		UseSprites:                   useSprites,
 		SpriteVTTURL:                 spriteVTT,
 		SpriteImageURL:               spriteImage,
-		EnableDeduplication:          true,               // De-duplicate faces across video
-		EmbeddingSimilarityThreshold: 0.6,                // Cosine similarity threshold for clustering
-		DetectDemographics:           true,               // Detect age, gender, emotion
-		CacheDuration:                3600,               // Cache for 1 hour
-		Enhancement:                  &enhancementParams, // Enable face enhancement
+		EnableDeduplication:          true,                       // De-duplicate faces across video
+		EmbeddingSimilarityThreshold: 0.6,                        // Cosine similarity threshold for clustering
+		DetectDemographics:           !s.config.SkipDemographics, // Detect age, gender, emotion
+		CacheDuration:                3600,                       // Cache for 1 hour
+		Enhancement:                  &enhancementParams,         // Enable face enhancement
+	}
+	s.applySceneFaceRule(&scene, &parameters)
+	if s.config.AnonymizationMode {
+		parameters.DetectDemographics = false
+		parameters.CacheDuration = anonymizedCacheDuration
 	}
 
-	request := vision.BuildAnalyzeRequest(videoPath, string(scene.ID), parameters)
-
-	// marshall request into json for logging
-	requestData, _ := json.Marshal(request)
-
-	log.Debugf("Scene %s: Submitting request to Vision Service: %s", scene.ID, string(requestData))
-
-	// Submit job
-	jobResp, err := visionClient.SubmitJob(request)
+	span := trace.StartSpan(trace.StageDetect)
+	results, err := s.analyzeScene(visionClient, scene.ID, videoPath, parameters)
+	span.End(err)
 	if err != nil {
-		return fmt.Errorf("failed to submit job: %w", err)
+		return nil, err
+	}
+	if results.Faces != nil {
+		result.Method = results.Faces.Metadata.Method
 	}
 
-	log.Debugf("Scene %s: Vision Service job submitted (job_id=%s)", scene.ID, jobResp.JobID)
-
-	// Wait for completion with progress updates
-	results, err := visionClient.WaitForCompletion(jobResp.JobID, func(p float64) {
-		log.Debugf("Scene %s: Vision Service progress: %.1f%%", scene.ID, p*100)
-	})
-	log.Debugf("Error from Vision Service: %v", err)
-	if err != nil {
-		return fmt.Errorf("vision service job failed: %w", err)
+	// Drop clusters backed by too few detections (one-frame artifacts)
+	if results.Faces != nil {
+		results.Faces.Faces = s.filterSmallClusters(scene.ID, results.Faces.Faces)
 	}
 
 	// Check if faces were found
 	if results.Faces == nil || len(results.Faces.Faces) == 0 {
 		log.Infof("Scene %s: No faces detected", scene.ID)
-		// Add scanned tag
-		if err := addTagToScene(s.graphqlClient, scene.ID, scannedTagID); err != nil {
-			log.Warnf("Failed to add scanned tag to scene %s: %v", scene.ID, err)
+		s.reportFacesDetected("scene", scene.ID, 0)
+		if !associate {
+			return result, nil
 		}
-		return nil
+		// Add scanned tag
+		s.writeAsync(fmt.Sprintf("add scanned tag to scene %s", scene.ID), func() error {
+			return stash.UpdateSceneTagsIfChanged(s.graphqlClient, &scene, []graphql.ID{scannedTagID}, nil)
+		})
+		return result, nil
 	}
 
 	facesDetected := 0
@@ -221,62 +391,292 @@ func (s *Service) processScene(visionClient *vision.VisionServiceClient, scene s
 		}
 	}
 	log.Infof("Scene %s: Found %d processable faces out of %d total faces", scene.ID, facesDetected, len(results.Faces.Faces))
+	result.FacesDetected = facesDetected
+	s.reportFacesDetected("scene", scene.ID, facesDetected)
+
+	// Anonymization mode records detection only; no face data leaves the plugin
+	if s.config.AnonymizationMode {
+		if associate {
+			addTags, removeTags := s.facesDetectedTags(facesDetected)
+			addTags = append(addTags, scannedTagID)
+			s.writeAsync(fmt.Sprintf("update scene %s detection tags", scene.ID), func() error {
+				return stash.UpdateSceneTagsIfChanged(s.graphqlClient, &scene, addTags, removeTags)
+			})
+		}
+		return result, nil
+	}
 
 	// Get result requestMetadata
 	requestMetadata := results.Faces.Metadata
 
 	// Process each face and track results
 	matchedPerformers := []graphql.ID{}
+	var extraPerformers []graphql.ID // Matched by extra faces in face crops
+	performerFaces := map[graphql.ID][]vision.VisionFace{}
 	facesProcessed := 0 // Faces that were either matched or created as new subjects
+	var worst worstSimilarity
 
-	for _, face := range results.Faces.Faces {
+	// Fetch the scene's representative frames in as few requests as possible
+	span = trace.StartSpan(trace.StageFetch)
+	frames := s.prefetchSceneFrames(visionClient, &scene, results.Faces.Faces, requestMetadata)
+	span.End(nil)
+
+	// Recognize the faces' embeddings in one request
+	defer s.prefetchEmbeddingMatches(results.Faces.Faces)()
+
+	// Unassigned clusters from an earlier scan are replaced by this one's
+	s.clearUnmatchedClusters(scene.ID)
+
+	// Names overlaid on screen favour their performers in close matches
+	overlays := s.readOverlayNames(visionClient, scene.ID, videoPath, results.Faces.Faces)
+	result.OverlayNames = overlays.names
+
+	for i, face := range results.Faces.Faces {
+		trace.Set(trace.Face(itemTrace, i))
 		ctx := FaceProcessingContext{
-			Scene:    &scene,
-			SourceID: string(scene.ID),
+			Scene:             &scene,
+			SourceID:          string(scene.ID),
+			Frames:            frames,
+			OverlaySubjects:   overlays.subjects,
+			ExtraPerformers:   &extraPerformers,
+			CreateNewSubjects: createNewSubjects,
+			ReviewNewSubjects: createNewSubjects && s.reviewsNewFaces(),
+		}
+		performerID, similarity, err := s.processFace(visionClient, ctx, face, requestMetadata)
+		cluster := s.sceneFaceCluster(face, trace.Face(itemTrace, i), 2*parameters.SamplingInterval)
+		switch {
+		case err != nil:
+			cluster.Status = ClusterFailed
+			cluster.Error = err.Error()
+			s.reportFace(ctx, face, ReportFace{Status: ReportFaceFailed, Reason: err.Error()})
+		case performerID != "":
+			cluster.Status = ClusterMatched
+			id := string(performerID)
+			cluster.Performer.ID = &id
+			cluster.Similarity = &similarity
+		case cluster.QualityTier != QualityTierLow && !ctx.ReviewNewSubjects:
+			cluster.UnmatchedID = s.recordUnmatchedCluster(visionClient, ctx, face, requestMetadata)
 		}
-		performerID, err := s.processFace(visionClient, ctx, face, requestMetadata)
+		result.Clusters = append(result.Clusters, cluster)
 		if err != nil {
 			log.Warnf("Failed to process face %s: %v", face.FaceID, err)
 			continue
 		}
 		if performerID != "" {
 			matchedPerformers = append(matchedPerformers, performerID)
+			performerFaces[performerID] = append(performerFaces[performerID], face)
+			worst.add(similarity)
 			facesProcessed++
 		}
 	}
+	trace.Set(itemTrace)
+	matchedPerformers = append(matchedPerformers, extraPerformersOf(extraPerformers, matchedPerformers)...)
+
+	if !associate {
+		log.Infof("Identification complete for scene %s (%d face(s) matched, association skipped)", scene.ID, facesProcessed)
+		return result, nil
+	}
+
+	// Status tags are collected and applied in a single update
+	addTags, removeTags := s.confidenceBandTags(worst)
+	addTags = append(addTags, scannedTagID)
+	addTags = append(addTags, s.pendingReviewTags("scene:"+string(scene.ID))...)
 
 	// Update scene with matched performers
 	if len(matchedPerformers) > 0 {
 		log.Infof("Scene %s: Matched/created %d performers", scene.ID, len(matchedPerformers))
-		if err := updateScenePerformers(s.graphqlClient, scene.ID, matchedPerformers); err != nil {
-			log.Warnf("Failed to update scene performers: %v", err)
-		}
 
 		// Add matched tag
-		if err := addTagToScene(s.graphqlClient, scene.ID, matchedTagID); err != nil {
-			log.Warnf("Failed to add matched tag: %v", err)
+		addTags = append(addTags, matchedTagID)
+	}
+
+	// Apply partial/complete tagging logic
+	completionTagID, removeTagID, err := s.sceneCompletionTags(scene.ID, facesDetected, facesProcessed)
+	if err != nil {
+		log.Warnf("Failed to apply completion tags: %v", err)
+	} else if completionTagID != "" {
+		addTags = append(addTags, completionTagID)
+		removeTags = append(removeTags, removeTagID)
+	}
+
+	// Write performers and status tags
+	s.writeAsync(fmt.Sprintf("update scene %s", scene.ID), func() error {
+		if len(matchedPerformers) > 0 {
+			if err := updateScenePerformers(s.graphqlClient, scene.ID, matchedPerformers); err != nil {
+				log.Warnf("Failed to update scene performers: %v", err)
+			}
+		}
+		if s.config.CreateSceneMarkers && len(performerFaces) > 0 {
+			if err := s.createSceneMarkers(scene.ID, performerFaces, 2*parameters.SamplingInterval); err != nil {
+				log.Warnf("Failed to create scene markers: %v", err)
+			}
+		}
+		return stash.UpdateSceneTagsIfChanged(s.graphqlClient, &scene, addTags, removeTags)
+	})
+
+	return result, nil
+}
+
+// sceneFaceCluster describes a face cluster before its match outcome is known
+func (s *Service) sceneFaceCluster(face vision.VisionFace, traceID string, maxGap float64) SceneFaceCluster {
+	det := face.RepresentativeDetection
+	qr := s.assessFaceQuality(det.Quality, s.config.MinProcessingQualityScore)
+
+	tier := QualityTierLow
+	if qr.Acceptable {
+		tier = QualityTierRecognition
+		if s.assessFaceQuality(det.Quality, s.config.MinQualityScore).Acceptable {
+			tier = QualityTierSubject
 		}
 	}
 
-	// Add scanned tag
-	if err := addTagToScene(s.graphqlClient, scene.ID, scannedTagID); err != nil {
-		log.Warnf("Failed to add scanned tag: %v", err)
+	var performer PerformerData
+	if face.Demographics != nil {
+		performer.Age = face.Demographics.Age
+		performer.Gender = face.Demographics.Gender
+	}
+
+	return SceneFaceCluster{
+		FaceID: face.FaceID,
+		BoundingBox: &compreface.BoundingBox{
+			XMin: det.BBox.XMin,
+			YMin: det.BBox.YMin,
+			XMax: det.BBox.XMax,
+			YMax: det.BBox.YMax,
+		},
+		Performer:    performer,
+		Status:       ClusterUnmatched,
+		Quality:      qr.Composite,
+		QualityTier:  tier,
+		Detections:   len(face.Detections),
+		Timestamp:    det.Timestamp,
+		Appearances:  face.Appearances(maxGap),
+		HasEmbedding: len(face.Embedding) > 0,
+		TraceID:      traceID,
 	}
+}
 
-	// Apply partial/complete tagging logic
-	if err := s.applySceneCompletionTags(scene.ID, facesDetected, facesProcessed); err != nil {
-		log.Warnf("Failed to apply completion tags: %v", err)
+// analyzeScene submits a scene to the Vision Service and waits for results.
+// A job exceeding VisionSceneJobTimeout is cancelled and retried once with
+// double the sampling interval, trading detail for a job that can finish. A
+// job the Vision Service fails (out of memory, decode errors) is retried once
+// with degraded parameters. Parameters a retry succeeded with are remembered
+// and used first for the scene on later runs.
+func (s *Service) analyzeScene(visionClient *vision.VisionServiceClient, sceneID graphql.ID, videoPath string, parameters vision.FacesParameters) (*vision.AnalyzeResults, error) {
+	timeout := time.Duration(s.config.VisionSceneJobTimeout) * time.Second
+	source := "scene:" + string(sceneID)
+	if s.applyJobProfile(source, &parameters) {
+		log.Infof("Scene %s: using parameters a previous retry succeeded with (samplingInterval=%.1fs maxFaces=%d)",
+			sceneID, parameters.SamplingInterval, parameters.MaxFaces)
+	}
+
+	results, err := s.runSceneJob(visionClient, sceneID, videoPath, parameters, timeout)
+	retry := parameters
+	switch {
+	case err == nil:
+		return results, nil
+	case errors.Is(err, vision.ErrJobTimeout) && !parameters.UseSprites:
+		retry.SamplingInterval *= 2
+		log.Warnf("Scene %s: %v, retrying with sampling interval %.1fs", sceneID, err, retry.SamplingInterval)
+	case errors.Is(err, vision.ErrJobFailed):
+		retry = parameters.Degraded()
+		log.Warnf("Scene %s: %v, retrying with sampling interval %.1fs, maxFaces=%d and enhancement off",
+			sceneID, err, retry.SamplingInterval, retry.MaxFaces)
+	default:
+		return nil, err
 	}
 
-	return nil
+	results, err = s.runSceneJob(visionClient, sceneID, videoPath, retry, timeout)
+	if err != nil {
+		return nil, err
+	}
+	s.rememberJobProfile(source, retry)
+	return results, nil
+}
+
+// runSceneJob submits a single scene analysis job and waits up to timeout
+func (s *Service) runSceneJob(visionClient *vision.VisionServiceClient, sceneID graphql.ID, videoPath string, parameters vision.FacesParameters, timeout time.Duration) (*vision.AnalyzeResults, error) {
+	request := vision.BuildAnalyzeRequest(videoPath, string(sceneID), parameters)
+
+	// marshall request into json for logging
+	requestData, _ := json.Marshal(request)
+
+	log.Debugf("Scene %s: Submitting request to Vision Service: %s", sceneID, string(requestData))
+
+	// Submit job, paced while the Vision Service is under pressure
+	s.paceVisionJob(visionClient)
+	jobResp, err := visionClient.SubmitJob(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit job: %w", err)
+	}
+
+	log.Debugf("Scene %s: Vision Service job submitted (job_id=%s)", sceneID, jobResp.JobID)
+
+	// Wait for completion with progress updates
+	results, err := visionClient.WaitForCompletion(jobResp.JobID, timeout, func(p float64) {
+		log.Debugf("Scene %s: Vision Service progress: %.1f%%", sceneID, p*100)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("vision service job failed: %w", err)
+	}
+	return results, nil
 }
 
-// applySceneCompletionTags applies partial/complete tags based on face processing results
-func (s *Service) applySceneCompletionTags(sceneID graphql.ID, facesDetected, facesProcessed int) error {
+// applySceneFaceRule applies the first SceneFaceRules entry matching the
+// scene's existing performer count, so scenes expected to hold many faces can
+// be searched harder and solo scenes with less effort
+func (s *Service) applySceneFaceRule(scene *stash.Scene, parameters *vision.FacesParameters) {
+	performers := len(scene.Performers)
+	for _, rule := range s.config.SceneFaceRules {
+		if !rule.Matches(performers) {
+			continue
+		}
+		if rule.MaxFaces > 0 {
+			parameters.MaxFaces = rule.MaxFaces
+		}
+		if rule.SamplingInterval > 0 {
+			parameters.SamplingInterval = rule.SamplingInterval
+		}
+		if rule.MinConfidence > 0 {
+			parameters.FaceMinConfidence = rule.MinConfidence
+		}
+		log.Infof("Scene %s: %d performer(s) tagged, using maxFaces=%d samplingInterval=%.1fs minConfidence=%.2f",
+			scene.ID, performers, parameters.MaxFaces, parameters.SamplingInterval, parameters.FaceMinConfidence)
+		return
+	}
+}
+
+// filterSmallClusters removes face clusters with fewer than MinClusterSize
+// detections
+func (s *Service) filterSmallClusters(sceneID graphql.ID, faces []vision.VisionFace) []vision.VisionFace {
+	minSize := s.config.MinClusterSize
+	if minSize <= 1 {
+		return faces
+	}
+
+	kept := make([]vision.VisionFace, 0, len(faces))
+	for _, face := range faces {
+		if len(face.Detections) < minSize {
+			log.Debugf("Scene %s: Skipping face %s backed by %d detection(s) (minimum %d)",
+				sceneID, face.FaceID, len(face.Detections), minSize)
+			continue
+		}
+		kept = append(kept, face)
+	}
+	if dropped := len(faces) - len(kept); dropped > 0 {
+		log.Infof("Scene %s: Dropped %d face cluster(s) smaller than %d detections", sceneID, dropped, minSize)
+	}
+	return kept
+}
+
+// sceneCompletionTags resolves the partial/complete tag to add and the opposite
+// tag to remove based on face processing results. Returns empty IDs when no
+// completion tagging applies.
+func (s *Service) sceneCompletionTags(sceneID graphql.ID, facesDetected, facesProcessed int) (graphql.ID, graphql.ID, error) {
 	// Skip completion tagging if no faces were processed (all skipped due to quality or errors)
 	if facesProcessed == 0 {
 		log.Debugf("Scene %s: No faces processed, skipping partial/complete tagging", sceneID)
-		return nil
+		return "", "", nil
 	}
 
 	var completionTag string
@@ -295,70 +695,71 @@ func (s *Service) applySceneCompletionTags(sceneID graphql.ID, facesDetected, fa
 		log.Infof("Scene %s: %d/%d face(s) processed - marking as Partial", sceneID, facesProcessed, facesDetected)
 	}
 
-	// Get current scene to retrieve existing tags
-	scene, err := stash.GetScene(s.graphqlClient, sceneID)
-	if err != nil {
-		return fmt.Errorf("failed to get scene: %w", err)
-	}
-
-	// Build list of tag IDs, removing the opposite completion tag
 	removeTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, removeTag, removeTag)
 	if err != nil {
-		return fmt.Errorf("failed to get remove tag: %w", err)
+		return "", "", fmt.Errorf("failed to get remove tag: %w", err)
 	}
 
-	// Get completion tag ID
 	completionTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, completionTag, completionTag)
 	if err != nil {
-		return fmt.Errorf("failed to get completion tag: %w", err)
+		return "", "", fmt.Errorf("failed to get completion tag: %w", err)
 	}
 
-	// Build new tag list: existing tags minus removeTag, plus completionTag
-	tagIDs := []graphql.ID{}
-	hasCompletionTag := false
+	return completionTagID, removeTagID, nil
+}
 
-	for _, tag := range scene.Tags {
-		if tag.ID == removeTagID {
-			// Skip the tag we want to remove
-			continue
-		}
-		if tag.ID == completionTagID {
-			hasCompletionTag = true
+// sceneScopeTags returns the tag criterion selecting the scenes of a scope,
+// matching the image pipeline: Complete scenes are always excluded, new scenes
+// also exclude Scanned ones, and partial scenes must carry the Partial tag
+func (s *Service) sceneScopeTags(scope SceneScope, scannedTagID, completeTagID graphql.ID) (*stash.HierarchicalMultiCriterionInput, error) {
+	switch scope {
+	case SceneScopePartial:
+		partialTagID, err := stash.GetOrCreateTag(s.graphqlClient, s.tagCache, s.config.PartialTagName, "Compreface Partial")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get partial tag: %w", err)
 		}
-		tagIDs = append(tagIDs, tag.ID)
-	}
-
-	// Add completion tag if not already present
-	if !hasCompletionTag {
-		tagIDs = append(tagIDs, completionTagID)
-	}
-
-	// Update scene tags
-	return stash.UpdateSceneTags(s.graphqlClient, sceneID, tagIDs)
+		return &stash.HierarchicalMultiCriterionInput{
+			Value:    []string{string(partialTagID)},
+			Modifier: stash.CriterionModifierIncludesAll,
+			Excludes: append([]string{string(completeTagID)}, s.sharedExclusionTagIDs()...),
+		}, nil
+	case SceneScopeAll:
+		return s.excludeTagsCriterion(completeTagID), nil
+	}
+	return s.excludeTagsCriterion(scannedTagID, completeTagID), nil
 }
 
 // Helper functions for scene GraphQL operations
 
-// Find scenes with filtering
-func findScenes(client *graphql.Client, scannedTagID *graphql.ID, page, perPage int) ([]stash.Scene, int, error) {
-	var tagsFilter stash.HierarchicalMultiCriterionInput
-	var filter stash.SceneFilterType = stash.SceneFilterType{}
-
-	// Build filter to exclude already scanned scenes
-	if scannedTagID != nil {
-		tagsFilter = stash.HierarchicalMultiCriterionInput{
-			Value:    []string{string(*scannedTagID)},
-			Modifier: stash.CriterionModifierExcludes,
-		}
-		filter.Tags = &tagsFilter
+// Find scenes with filtering, optionally excluding tags and restricting by
+// performer count; scenes in excluded galleries and studios are left out
+func (s *Service) findScenes(excludeTags *stash.HierarchicalMultiCriterionInput, performerCount *stash.IntCriterionInput, page, perPage int) ([]stash.Scene, int, error) {
+	filter := stash.SceneFilterType{
+		Tags:           excludeTags,
+		PerformerCount: performerCount,
 	}
+	s.excludeIgnoredScenes(&filter)
+	s.scopeScenes(&filter)
 
-	return stash.FindScenes(client, &filter, page, perPage)
+	return stash.FindScenes(s.graphqlClient, &filter, page, perPage)
 }
 
-// Add tag to scene (preserving existing tags)
-func addTagToScene(client *graphql.Client, sceneID graphql.ID, tagID graphql.ID) error {
-	return stash.AddTagToScene(client, sceneID, tagID)
+// sceneTask returns the task mode of a scene recognition run, which keys its
+// checkpoint
+func sceneTask(useSprites bool, scope SceneScope) string {
+	var task string
+	switch scope {
+	case SceneScopePartial:
+		task = "recognizePartialScene"
+	case SceneScopeAll:
+		task = "recognizeAllScene"
+	default:
+		task = "recognizeNewScene"
+	}
+	if useSprites {
+		return task + "Sprites"
+	}
+	return task + "s"
 }
 
 // Update scene performers (preserving existing performers)
@@ -372,6 +773,7 @@ func (s *Service) createPerformerWithDetails(performerSubject stash.PerformerSub
 	if err != nil {
 		return nil, err
 	}
+	s.performerCreated(performerID)
 
 	return &stash.Performer{
 		ID:   performerID,
@@ -381,7 +783,7 @@ func (s *Service) createPerformerWithDetails(performerSubject stash.PerformerSub
 
 // resetUnmatchedScenes removes scanned tags from unmatched scenes
 func (s *Service) resetUnmatchedScenes(limit int) error {
-	if s.stopping {
+	if s.stopped() {
 		return fmt.Errorf("operation cancelled")
 	}
 
@@ -404,32 +806,31 @@ func (s *Service) resetUnmatchedScenes(limit int) error {
 	tagsFilter := stash.HierarchicalMultiCriterionInput{
 		Value:    []string{string(scannedTagID)},
 		Modifier: stash.CriterionModifierIncludesAll,
+		Excludes: []string{string(matchedTagID)},
 	}
 	filter := stash.SceneFilterType{
 		Tags: &tagsFilter,
 	}
 
-	scenes, count, err := stash.FindScenes(s.graphqlClient, &filter, 1, -1)
-	if err != nil {
-		return fmt.Errorf("failed to query scenes: %w", err)
-	}
-
-	log.Infof("Found %d scanned scenes", count)
-
-	// Step 3: Filter for images without matched tag
-	var unmatchedScenes []graphql.ID
-	for _, scene := range scenes {
-		hasMatchedTag := false
-		for _, tag := range scene.Tags {
-			if tag.ID == matchedTagID {
-				hasMatchedTag = true
-				break
-			}
+	// Step 3: Collect all matching scene IDs before modifying tags, since
+	// removing the scanned tag shifts the filtered result pages
+	var unmatchedScenes []stash.Scene
+	count := 0
+	err = stash.FindAllScenes(s.graphqlClient, &filter, stash.DefaultPageSize, func(scenes []stash.Scene, total int) error {
+		if s.stopped() {
+			return fmt.Errorf("operation cancelled")
 		}
-
-		if !hasMatchedTag {
-			unmatchedScenes = append(unmatchedScenes, scene.ID)
+		count = total
+		for _, scene := range scenes {
+			if limit > 0 && len(unmatchedScenes) >= limit {
+				return stash.ErrStopPaging
+			}
+			unmatchedScenes = append(unmatchedScenes, scene)
 		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query scenes: %w", err)
 	}
 
 	if len(unmatchedScenes) == 0 {
@@ -437,27 +838,34 @@ func (s *Service) resetUnmatchedScenes(limit int) error {
 		return nil
 	}
 
-	total := len(unmatchedScenes)
-
-	// Apply limit if specified
-	if limit > 0 && limit < total {
-		unmatchedScenes = unmatchedScenes[:limit]
-		log.Infof("Found %d unmatched scenes, limiting to %d", total, limit)
+	if len(unmatchedScenes) < count {
+		log.Infof("Found %d unmatched scenes, limiting to %d", count, len(unmatchedScenes))
 	} else {
-		log.Infof("Found %d unmatched scenes to reset", total)
+		log.Infof("Found %d unmatched scenes to reset", count)
+	}
+
+	// Step 4: Back up the scenes' tags, so the reset can be undone
+	err = s.backupState("resetUnmatchedScenes", func(backup *store.BackupWriter) error {
+		return backupScenes(backup, unmatchedScenes, nil)
+	})
+	if err != nil {
+		return err
 	}
 
-	// Step 4: Remove scanned tag from unmatched scenes
+	// Step 5: Remove scanned tag from unmatched scenes
 	resetCount := 0
-	for i, sceneID := range unmatchedScenes {
-		if s.stopping {
+	for i := range unmatchedScenes {
+		if s.stopped() {
 			return fmt.Errorf("operation cancelled")
 		}
 
+		scene := &unmatchedScenes[i]
+		sceneID := scene.ID
+
 		progress := float64(i) / float64(len(unmatchedScenes))
-		log.Progress(progress)
+		s.reportProgress(progress)
 
-		err := stash.RemoveTagFromScene(s.graphqlClient, sceneID, scannedTagID)
+		err := stash.UpdateSceneTagsIfChanged(s.graphqlClient, scene, nil, []graphql.ID{scannedTagID})
 		if err != nil {
 			log.Warnf("Failed to remove tag from scene %s: %v", sceneID, err)
 			continue
@@ -467,7 +875,7 @@ func (s *Service) resetUnmatchedScenes(limit int) error {
 		log.Debugf("Reset scene %s (%d/%d)", sceneID, i+1, len(unmatchedScenes))
 	}
 
-	log.Progress(1.0)
+	s.reportProgress(1.0)
 	log.Infof("Reset complete: %d scenes processed", resetCount)
 
 	return nil
@@ -0,0 +1,148 @@
+package rpc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+)
+
+// ============================================================================
+// Studio and Tag Scopes
+// ============================================================================
+//
+// The scope arguments of the image and scene batch tasks limit a run to the
+// media of one studio (studioId), with one content tag (tagId), added to
+// Stash in a date range (createdAfter, createdBefore) or under a folder
+// (pathPrefix), in any combination. The scope's tag is merged into the run's
+// tag criterion, so the Scanned, Complete and exclusion tags still apply.
+// Scoped runs keep their own checkpoints, so resuming one never continues
+// another scope's run.
+//
+// ============================================================================
+
+// contentScope restricts a batch run to a studio and/or tag
+type contentScope struct {
+	studioID      string
+	tagID         string
+	createdAfter  string // Date or timestamp
+	createdBefore string // Date or timestamp
+	pathPrefix    string
+}
+
+// String describes the scope for logs and checkpoint keys; empty when unscoped
+func (c contentScope) String() string {
+	var parts []string
+	if c.studioID != "" {
+		parts = append(parts, "studio:"+c.studioID)
+	}
+	if c.tagID != "" {
+		parts = append(parts, "tag:"+c.tagID)
+	}
+	if c.createdAfter != "" {
+		parts = append(parts, "after:"+c.createdAfter)
+	}
+	if c.createdBefore != "" {
+		parts = append(parts, "before:"+c.createdBefore)
+	}
+	if c.pathPrefix != "" {
+		parts = append(parts, "path:"+c.pathPrefix)
+	}
+	return strings.Join(parts, ",")
+}
+
+// scopedTask returns the checkpoint key of a task in the current scope
+func (s *Service) scopedTask(task string) string {
+	if scope := s.scope.String(); scope != "" {
+		return fmt.Sprintf("%s@%s", task, scope)
+	}
+	return task
+}
+
+// scopeTags adds the scope's tag to a tag criterion. An EXCLUDES criterion
+// becomes the excludes of an INCLUDES_ALL criterion for the tag.
+func (s *Service) scopeTags(tags *stash.HierarchicalMultiCriterionInput) *stash.HierarchicalMultiCriterionInput {
+	if s.scope.tagID == "" {
+		return tags
+	}
+	if tags == nil {
+		return &stash.HierarchicalMultiCriterionInput{
+			Value:    []string{s.scope.tagID},
+			Modifier: stash.CriterionModifierIncludesAll,
+		}
+	}
+	if tags.Modifier == stash.CriterionModifierExcludes {
+		return &stash.HierarchicalMultiCriterionInput{
+			Value:    []string{s.scope.tagID},
+			Modifier: stash.CriterionModifierIncludesAll,
+			Excludes: append(append([]string{}, tags.Excludes...), tags.Value...),
+		}
+	}
+	scoped := *tags
+	scoped.Value = append(append([]string{}, tags.Value...), s.scope.tagID)
+	return &scoped
+}
+
+// scopeStudios returns the studio criterion of the scope, nil when unscoped
+func (s *Service) scopeStudios() *stash.HierarchicalMultiCriterionInput {
+	if s.scope.studioID == "" {
+		return nil
+	}
+	return &stash.HierarchicalMultiCriterionInput{
+		Value:    []string{s.scope.studioID},
+		Modifier: stash.CriterionModifierIncludes,
+	}
+}
+
+// scopeCreated returns the creation time criterion of the scope, nil when
+// unscoped. Both bounds select the range between them.
+func (s *Service) scopeCreated() *stash.TimestampCriterionInput {
+	after, before := s.scope.createdAfter, s.scope.createdBefore
+	switch {
+	case after != "" && before != "":
+		return &stash.TimestampCriterionInput{Value: after, Value2: &before, Modifier: stash.CriterionModifierBetween}
+	case after != "":
+		return &stash.TimestampCriterionInput{Value: after, Modifier: stash.CriterionModifierGreaterThan}
+	case before != "":
+		return &stash.TimestampCriterionInput{Value: before, Modifier: stash.CriterionModifierLessThan}
+	}
+	return nil
+}
+
+// scopePath returns the path criterion of the scope, nil when unscoped.
+// Stash's INCLUDES matches anywhere in the path, so the prefix is anchored
+// as a regular expression.
+func (s *Service) scopePath() *stash.StringCriterionInput {
+	if s.scope.pathPrefix == "" {
+		return nil
+	}
+	return &stash.StringCriterionInput{
+		Value:    "^" + regexp.QuoteMeta(s.scope.pathPrefix),
+		Modifier: stash.CriterionModifierMatchesRegex,
+	}
+}
+
+// scopeImages narrows an image filter to the scope. A nil filter is
+// allocated when needed.
+func (s *Service) scopeImages(filter *stash.ImageFilterType) *stash.ImageFilterType {
+	if s.scope == (contentScope{}) {
+		return filter
+	}
+	if filter == nil {
+		filter = &stash.ImageFilterType{}
+	}
+	filter.Tags = s.scopeTags(filter.Tags)
+	filter.Studios = s.scopeStudios()
+	filter.CreatedAt = s.scopeCreated()
+	filter.Path = s.scopePath()
+	return filter
+}
+
+// scopeScenes narrows a scene filter to the scope
+func (s *Service) scopeScenes(filter *stash.SceneFilterType) {
+	filter.Tags = s.scopeTags(filter.Tags)
+	filter.Studios = s.scopeStudios()
+	filter.CreatedAt = s.scopeCreated()
+	filter.Path = s.scopePath()
+}
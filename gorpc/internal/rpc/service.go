@@ -1,38 +1,91 @@
 package rpc
 
 import (
-	"time"
+	"context"
 
 	"github.com/stashapp/stash/pkg/plugin/common"
-	"github.com/stashapp/stash/pkg/plugin/common/log"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/fake"
+	"github.com/smegmarip/stash-compreface-plugin/internal/sprite"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
 )
 
 // NewService creates a new RPC service instance
 func NewService() *Service {
-	return &Service{}
+	return NewServiceWithComponents(Components{})
+}
+
+// NewServiceWithComponents creates an RPC service that routes task modes to
+// the components built by the given constructors; unset ones build the
+// default components
+func NewServiceWithComponents(injected Components) *Service {
+	s := &Service{injected: injected}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
 }
 
 // Stop handles graceful shutdown of the plugin
 func (s *Service) Stop(input struct{}, output *bool) error {
 	log.Info("Stopping Compreface plugin...")
-	s.stopping = true
+	s.cancel()
 	*output = true
 	return nil
 }
 
-// applyCooldown applies the configured cooldown period
-func (s *Service) applyCooldown() {
-	if s.config.CooldownSeconds > 0 {
-		log.Infof("Cooling down for %d seconds to prevent hardware stress...", s.config.CooldownSeconds)
-		time.Sleep(time.Duration(s.config.CooldownSeconds) * time.Second)
+// stopped reports whether Stop has been called; loops check it between items
+// while requests and Vision job polling in flight end on s.ctx
+func (s *Service) stopped() bool {
+	return s.ctx.Err() != nil
+}
+
+// reportProgress reports task progress (0-1), scaled into the active
+// pipeline stage window when running as part of fullPipeline
+func (s *Service) reportProgress(progress float64) {
+	if s.progressStage != nil {
+		progress = s.progressStage.offset + progress*s.progressStage.weight
+	}
+	log.Progress(progress)
+}
+
+// sprites returns the task's sprite extractor, creating it on first use so
+// parsed VTTs and sprite images are cached across faces of the same scene
+func (s *Service) sprites() *sprite.Extractor {
+	if s.spriteExtractor == nil {
+		s.spriteExtractor = sprite.NewExtractor(nil)
 	}
+	return s.spriteExtractor
 }
 
-// errorOutput creates an error output for RPC response
+// errorOutput creates an error output for RPC response, with secrets redacted
 func (s *Service) errorOutput(output *common.PluginOutput, err error) error {
-	errStr := err.Error()
+	errStr := log.Scrub(err.Error())
 	*output = common.PluginOutput{
 		Error: &errStr,
 	}
 	return nil
 }
+
+// startFakeServices starts in-process Compreface and Vision Service fakes and
+// points the loaded configuration at them. The returned function stops both.
+func (s *Service) startFakeServices() func() {
+	comprefaceFake := fake.NewComprefaceServer()
+	visionFake := fake.NewVisionServer()
+
+	s.config.ComprefaceURL = comprefaceFake.URL()
+	s.config.VisionServiceURL = visionFake.URL()
+	s.config.FrameServerURL = visionFake.FrameServerURL()
+	if s.config.RecognitionAPIKey == "" {
+		s.config.RecognitionAPIKey = "test-mode"
+	}
+	if s.config.DetectionAPIKey == "" {
+		s.config.DetectionAPIKey = "test-mode"
+	}
+
+	log.Infof("Test mode: using fake Compreface at %s and fake Vision Service at %s",
+		comprefaceFake.URL(), visionFake.URL())
+
+	return func() {
+		comprefaceFake.Close()
+		visionFake.Close()
+	}
+}
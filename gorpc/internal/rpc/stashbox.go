@@ -0,0 +1,263 @@
+package rpc
+
+import (
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Stash-box Lookup
+// ============================================================================
+//
+// With stashBoxEndpoints set, a face about to become a "Person ..." placeholder
+// performer is first looked up on those endpoints. Candidates come from the
+// source: the performers of the stash-box scenes matching a scene's
+// fingerprints, or a name search for an image's title and folder name.
+// Candidates whose gender or age contradicts the face's estimate are dropped,
+// the rest are ranked by verifying the face against their stash-box images,
+// and the best one at or above minSimilarity is used: the Stash performer
+// already linked to it, or a new performer created from its stash-box data.
+// Either way the new subject's name is added as an alias. Without a verified
+// candidate the placeholder is created as before.
+//
+// ============================================================================
+
+const (
+	// stashBoxMaxCandidates caps the candidates verified per face
+	stashBoxMaxCandidates = 10
+	// stashBoxImagesPerCandidate caps the stash-box images verified per candidate
+	stashBoxImagesPerCandidate = 2
+	// stashBoxAgeTolerance is how far (years) a candidate's age may fall
+	// outside the face's estimated age range
+	stashBoxAgeTolerance = 10
+)
+
+// stashBoxCandidate is a stash-box performer considered for a new face
+type stashBoxCandidate struct {
+	endpoint   string
+	performer  stash.ScrapedPerformer
+	similarity float64 // Best verification similarity of the face to its images
+}
+
+// stashBoxEnabled reports whether new faces are looked up on stash-box
+func (s *Service) stashBoxEnabled() bool {
+	return len(s.config.StashBoxEndpoints) > 0 && !s.config.AnonymizationMode
+}
+
+// stashBoxPerformer looks up a new face of a source ("image:12", "scene:7")
+// on the configured stash-box endpoints and returns the Stash performer of
+// the best verified candidate, with subjectName added as an alias. Returns
+// false when stash-box lookups are disabled or no candidate verifies; lookup
+// failures are logged and also return false, so a placeholder is created.
+func (s *Service) stashBoxPerformer(source string, faceCrop []byte, subjectName string, gender string, ageLow int, ageHigh int) (graphql.ID, bool) {
+	if !s.stashBoxEnabled() || len(faceCrop) == 0 {
+		return "", false
+	}
+
+	candidates := s.stashBoxCandidates(source)
+	var plausible []stashBoxCandidate
+	for _, candidate := range candidates {
+		if stashBoxDemographicsAgree(candidate.performer, gender, ageLow, ageHigh) {
+			plausible = append(plausible, candidate)
+		} else {
+			log.Debugf("Stash-box candidate %s does not fit the face (gender %s, age %d-%d)", candidate.performer.Name, gender, ageLow, ageHigh)
+		}
+	}
+	if len(plausible) > stashBoxMaxCandidates {
+		plausible = plausible[:stashBoxMaxCandidates]
+	}
+	if len(plausible) == 0 {
+		return "", false
+	}
+
+	ranked := s.rankStashBoxCandidates(plausible, faceCrop)
+	best := ranked[0]
+	if best.similarity < s.config.MinSimilarity {
+		log.Infof("No stash-box candidate verifies for %s (best: %s, similarity %.2f)", source, best.performer.Name, best.similarity)
+		return "", false
+	}
+	log.Infof("Face of %s verifies as %s on %s (similarity %.2f)", source, best.performer.Name, best.endpoint, best.similarity)
+
+	performerID, err := s.linkStashBoxPerformer(best, subjectName)
+	if err != nil {
+		log.Warnf("Failed to use stash-box performer %s, creating a placeholder: %v", best.performer.Name, err)
+		return "", false
+	}
+	return performerID, true
+}
+
+// stashBoxCandidates collects the stash-box performers a source suggests,
+// from every configured endpoint, without duplicates
+func (s *Service) stashBoxCandidates(source string) []stashBoxCandidate {
+	kind, sourceID, _ := strings.Cut(source, ":")
+
+	var hints []string
+	if kind == "image" {
+		hints = s.stashBoxNameHints(graphql.ID(sourceID))
+		if len(hints) == 0 {
+			return nil
+		}
+	}
+
+	var candidates []stashBoxCandidate
+	seen := map[string]bool{}
+	add := func(endpoint string, performers []stash.ScrapedPerformer) {
+		for _, performer := range performers {
+			key := endpoint + "/" + performer.RemoteSiteID
+			if performer.RemoteSiteID == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			candidates = append(candidates, stashBoxCandidate{endpoint: endpoint, performer: performer})
+		}
+	}
+
+	for _, endpoint := range s.config.StashBoxEndpoints {
+		if kind == "scene" {
+			performers, err := stash.ScrapeStashBoxScenePerformers(s.graphqlClient, endpoint, graphql.ID(sourceID))
+			if err != nil {
+				log.Warnf("Stash-box lookup failed: %v", err)
+				continue
+			}
+			add(endpoint, performers)
+			continue
+		}
+		for _, hint := range hints {
+			performers, err := stash.ScrapeStashBoxPerformers(s.graphqlClient, endpoint, hint)
+			if err != nil {
+				log.Warnf("Stash-box lookup failed: %v", err)
+				continue
+			}
+			add(endpoint, performers)
+		}
+	}
+
+	log.Debugf("%d stash-box candidates for %s", len(candidates), source)
+	return candidates
+}
+
+// stashBoxNameHints returns the names an image suggests its performers go
+// by: its title and the name of its folder
+func (s *Service) stashBoxNameHints(imageID graphql.ID) []string {
+	image, err := stash.GetImage(s.graphqlClient, imageID)
+	if err != nil {
+		log.Warnf("Failed to get image %s for stash-box lookup: %v", imageID, err)
+		return nil
+	}
+
+	title := strings.TrimSpace(image.Title)
+	var folder string
+	if len(image.Files) > 0 {
+		path := image.Files[0].Path
+		base := filepath.Base(path)
+		if title == base || title == strings.TrimSuffix(base, filepath.Ext(base)) {
+			title = "" // Stash's default title is the file name
+		}
+		folder = filepath.Base(filepath.Dir(path))
+	}
+
+	var hints []string
+	for _, hint := range []string{title, folder} {
+		if strings.IndexFunc(hint, unicode.IsLetter) >= 0 && !slices.Contains(hints, hint) {
+			hints = append(hints, hint)
+		}
+	}
+	return hints
+}
+
+// stashBoxDemographicsAgree reports whether a stash-box performer's gender
+// and age fit a face's estimates. Unknown values never disagree.
+func stashBoxDemographicsAgree(performer stash.ScrapedPerformer, gender string, ageLow int, ageHigh int) bool {
+	if gender != "" && performer.Gender != "" {
+		faceGender, err1 := stash.ParseGenderEnum(gender)
+		performerGender, err2 := stash.ParseGenderEnum(performer.Gender)
+		if err1 == nil && err2 == nil && faceGender != performerGender {
+			return false
+		}
+	}
+	if ageHigh > 0 {
+		if age, err := stash.CaclulateAgeFromBirthday(performer.Birthdate); err == nil {
+			if age < ageLow-stashBoxAgeTolerance || age > ageHigh+stashBoxAgeTolerance {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// rankStashBoxCandidates verifies the face against each candidate's
+// stash-box images, best verified first
+func (s *Service) rankStashBoxCandidates(candidates []stashBoxCandidate, faceCrop []byte) []stashBoxCandidate {
+	for i := range candidates {
+		candidate := &candidates[i]
+		for j, imageURL := range candidate.performer.Images {
+			if j >= stashBoxImagesPerCandidate || s.stopped() {
+				break
+			}
+			imageBytes, err := stash.DownloadImage(imageURL, nil)
+			if err != nil {
+				log.Debugf("Failed to download stash-box image of %s: %v", candidate.performer.Name, err)
+				continue
+			}
+			similarity, err := s.comprefaceClient.VerifyFaces(imageBytes, faceCrop)
+			if err != nil {
+				log.Debugf("Failed to verify against %s: %v", candidate.performer.Name, err)
+				continue
+			}
+			if similarity > candidate.similarity {
+				candidate.similarity = similarity
+			}
+		}
+		log.Debugf("Stash-box candidate %s (%s): similarity %.2f", candidate.performer.Name, candidate.endpoint, candidate.similarity)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].similarity > candidates[j].similarity
+	})
+	return candidates
+}
+
+// linkStashBoxPerformer returns the Stash performer for a verified stash-box
+// candidate, creating it when none is linked yet, with subjectName added to
+// its aliases
+func (s *Service) linkStashBoxPerformer(candidate stashBoxCandidate, subjectName string) (graphql.ID, error) {
+	performerID := graphql.ID(candidate.performer.StoredID)
+	if performerID == "" {
+		id, err := stash.FindPerformerByStashID(s.graphqlClient, candidate.endpoint, candidate.performer.RemoteSiteID)
+		if err != nil {
+			return "", err
+		}
+		performerID = id
+	}
+
+	if performerID == "" {
+		id, err := stash.CreatePerformerFromStashBox(s.graphqlClient, candidate.endpoint, candidate.performer, []string{subjectName})
+		if err != nil {
+			return "", err
+		}
+		s.performerCreated(id)
+		return id, nil
+	}
+
+	performer, err := stash.GetPerformerByID(s.graphqlClient, performerID)
+	if err != nil {
+		return "", err
+	}
+	input := stash.PerformerUpdateInput{
+		ID:        string(performerID),
+		AliasList: append(performer.AliasList, subjectName),
+	}
+	if err := stash.UpdatePerformer(s.graphqlClient, performerID, input); err != nil {
+		return "", err
+	}
+	log.Infof("Linked subject %s to existing performer %s (%s)", subjectName, performer.Name, performerID)
+	return performerID, nil
+}
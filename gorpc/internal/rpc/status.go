@@ -0,0 +1,130 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/version"
+)
+
+// ============================================================================
+// Status Reporting (Service Layer)
+// ============================================================================
+
+// latestReleaseURL is the GitHub API endpoint for the latest plugin release
+const latestReleaseURL = "https://api.github.com/repos/smegmarip/stash-compreface-plugin/releases/latest"
+
+// statusReporter is the default StatusReporter
+type statusReporter struct {
+	clients Clients
+}
+
+// Status reports the plugin version and build commit, the versions reported
+// by connected services against the tested compatibility matrix, and whether
+// a newer plugin release is available. Services outside the tested matrix are
+// logged as warnings.
+func (r statusReporter) Status() (string, error) {
+	cfg := r.clients.Config
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Compreface plugin v%s (commit %s)", version.Version, version.Commit)
+
+	// Stash
+	if sv, err := stash.GetServerVersion(r.clients.Stash); err != nil {
+		fmt.Fprintf(&b, "\n  - Stash: unavailable (%v)", err)
+	} else {
+		fmt.Fprintf(&b, "\n  - Stash: %s%s", sv.Version, testedSuffix("Stash", sv.Version, version.TestedStashVersions))
+	}
+
+	// Vision Service
+	if cfg.VisionServiceURL == "" {
+		b.WriteString("\n  - Vision Service: not configured")
+	} else if health, err := r.clients.Vision().Health(); err != nil {
+		fmt.Fprintf(&b, "\n  - Vision Service: unavailable at %s (%v)", cfg.VisionServiceURL, err)
+	} else {
+		visionVersion, _ := health["version"].(string)
+		fmt.Fprintf(&b, "\n  - Vision Service: %s%s", versionOrUnknown(visionVersion),
+			testedSuffix("Vision Service", visionVersion, version.TestedVisionVersions))
+	}
+
+	// Compreface does not report its version through the REST API
+	if subjects, err := r.clients.Compreface.ListSubjects(); err != nil {
+		fmt.Fprintf(&b, "\n  - Compreface: unavailable at %s (%v)", cfg.ComprefaceURL, err)
+	} else {
+		fmt.Fprintf(&b, "\n  - Compreface: reachable, %d subject(s) (tested: %s)",
+			len(subjects), strings.Join(version.TestedComprefaceVersions, ", "))
+		if model, err := r.clients.Compreface.DetectModel(); err != nil {
+			log.Debugf("Compreface model detection failed: %v", err)
+		} else {
+			fmt.Fprintf(&b, "\n  - Compreface model: %s (similarity threshold %.2f)", model, cfg.MinSimilarity)
+		}
+	}
+
+	// Self-update check
+	if cfg.TestMode {
+		b.WriteString("\n  - Update check: skipped in test mode")
+	} else if latest, err := checkLatestRelease(); err != nil {
+		log.Debugf("Update check failed: %v", err)
+		b.WriteString("\n  - Update check: unavailable")
+	} else if version.Compare(latest, version.Version) > 0 {
+		log.Warnf("Compreface plugin %s is available (running v%s)", latest, version.Version)
+		fmt.Fprintf(&b, "\n  - Update available: %s", latest)
+	} else {
+		b.WriteString("\n  - Update check: up to date")
+	}
+
+	report := b.String()
+	log.Info(report)
+	return report, nil
+}
+
+// testedSuffix describes whether a service version is in the tested matrix,
+// warning when it is not
+func testedSuffix(service string, serviceVersion string, tested []string) string {
+	if serviceVersion == "" {
+		return fmt.Sprintf(" (tested: %s)", strings.Join(tested, ", "))
+	}
+	if version.IsTested(serviceVersion, tested) {
+		return " (tested)"
+	}
+
+	log.Warnf("%s version %s is outside the tested versions (%s)", service, serviceVersion, strings.Join(tested, ", "))
+	return fmt.Sprintf(" (untested, tested: %s)", strings.Join(tested, ", "))
+}
+
+// versionOrUnknown returns v, or "unknown version" when empty
+func versionOrUnknown(v string) string {
+	if v == "" {
+		return "unknown version"
+	}
+	return v
+}
+
+// checkLatestRelease returns the tag of the latest published plugin release
+func checkLatestRelease() (string, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	resp, err := client.Get(latestReleaseURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to query latest release: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", fmt.Errorf("failed to decode release: %w", err)
+	}
+
+	return release.TagName, nil
+}
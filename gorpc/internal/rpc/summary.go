@@ -0,0 +1,84 @@
+package rpc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ============================================================================
+// Run Summary
+// ============================================================================
+//
+// Tasks count what they did so a one-line summary ("Compreface: 1,234 images
+// processed, 56 performers created") can be returned as the task output and
+// logged, without users digging through the task log.
+//
+// ============================================================================
+
+// runSummary counts the work done by a task. Counters are atomic so
+// concurrent workers can update them.
+type runSummary struct {
+	imagesProcessed   atomic.Int64
+	scenesProcessed   atomic.Int64
+	performersSynced  atomic.Int64
+	performersCreated atomic.Int64
+	facesMatched      atomic.Int64
+}
+
+// String formats the non-zero counts, or "" when nothing was done
+func (r *runSummary) String() string {
+	var parts []string
+	add := func(counter *atomic.Int64, singular, plural string) {
+		count := int(counter.Load())
+		if count == 1 {
+			parts = append(parts, "1 "+singular)
+		} else if count > 1 {
+			parts = append(parts, formatCount(count)+" "+plural)
+		}
+	}
+	add(&r.imagesProcessed, "image processed", "images processed")
+	add(&r.scenesProcessed, "scene processed", "scenes processed")
+	add(&r.performersSynced, "performer synced", "performers synced")
+	add(&r.facesMatched, "face matched", "faces matched")
+	add(&r.performersCreated, "performer created", "performers created")
+
+	if len(parts) == 0 {
+		return ""
+	}
+	return "Compreface: " + strings.Join(parts, ", ")
+}
+
+// formatCount formats n with thousands separators
+func formatCount(n int) string {
+	digits := strconv.Itoa(n)
+	if len(digits) <= 3 {
+		return digits
+	}
+
+	var b strings.Builder
+	lead := len(digits) % 3
+	if lead > 0 {
+		b.WriteString(digits[:lead])
+	}
+	for i := lead; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
+
+// withSummary appends the run summary to a task's output message
+func (s *Service) withSummary(output string) string {
+	summary := s.summary.String()
+	if summary == "" {
+		return output
+	}
+	if strings.Contains(output, "\n") {
+		return output + "\n" + summary
+	}
+	return fmt.Sprintf("%s. %s", output, summary)
+}
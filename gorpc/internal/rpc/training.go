@@ -0,0 +1,268 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Performer Face Training
+// ============================================================================
+//
+// synchronizePerformers stores only a performer's profile image, so subjects
+// start with a single example. Training walks the images the performer is
+// associated with in Stash, crops each detected face, and adds the face that
+// verifies against the subject's existing examples as another example, until
+// the subject holds the requested number. Faces that do not verify are left
+// out, so a wrong association never poisons the subject; near-identical faces
+// (an image trained by an earlier run) are skipped.
+//
+// ============================================================================
+
+// trainingDuplicateSimilarity is the verification similarity above which a
+// face is taken to be an existing example
+const trainingDuplicateSimilarity = 0.99
+
+// trainingReport counts the work done by a training run
+type trainingReport struct {
+	performers int
+	full       int
+	images     int
+	added      int
+	rejected   int
+	duplicates int
+}
+
+// String summarizes the report
+func (r *trainingReport) String() string {
+	return fmt.Sprintf("Trained performers: %s trained, %s already at the example limit, %s images checked, %s examples added, %s images without a verified face, %s already examples",
+		formatCount(r.performers), formatCount(r.full), formatCount(r.images), formatCount(r.added), formatCount(r.rejected), formatCount(r.duplicates))
+}
+
+// trainPerformerFaces adds examples from a performer's images to its subject
+// until it holds maxExamples. With performerID empty, every synced performer
+// is trained, up to limit performers.
+func (s *Service) trainPerformerFaces(performerID string, maxExamples int, limit int) (string, error) {
+	if s.stopped() {
+		return "", fmt.Errorf("operation cancelled")
+	}
+
+	report := &trainingReport{}
+	if performerID != "" {
+		performer, err := stash.GetPerformerByID(s.graphqlClient, graphql.ID(performerID))
+		if err != nil {
+			return "", fmt.Errorf("failed to get performer: %w", err)
+		}
+		if performer.ID == "" {
+			return "", fmt.Errorf("performer %s not found", performerID)
+		}
+		if err := s.trainPerformer(performer, maxExamples, report); err != nil {
+			return "", err
+		}
+		summary := report.String()
+		log.Info(summary)
+		return summary, nil
+	}
+
+	performers, err := s.syncedPerformers(limit)
+	if err != nil {
+		return "", err
+	}
+	log.Infof("Training %d synced performers (up to %d examples each)", len(performers), maxExamples)
+
+	for i := range performers {
+		if s.stopped() {
+			return "", fmt.Errorf("operation cancelled")
+		}
+		s.reportProgress(float64(i) / float64(len(performers)))
+
+		performer := &performers[i]
+		if err := s.trainPerformer(performer, maxExamples, report); err != nil {
+			log.Warnf("Failed to train performer %s: %v", performer.ID, err)
+			s.itemFailed("performer", performer.ID, err)
+		}
+	}
+
+	s.reportProgress(1.0)
+	summary := report.String()
+	log.Info(summary)
+	return summary, nil
+}
+
+// syncedPerformers returns up to limit performers carrying the synced tag
+func (s *Service) syncedPerformers(limit int) ([]stash.Performer, error) {
+	tags, err := s.lookupPluginTags()
+	if err != nil {
+		return nil, err
+	}
+	if tags.synced == "" {
+		log.Infof("Tag %s does not exist yet; synchronize performers first", s.config.SyncedTagName)
+		return nil, nil
+	}
+
+	filter := &stash.PerformerFilterType{
+		Tags: &stash.HierarchicalMultiCriterionInput{Value: []string{string(tags.synced)}, Modifier: stash.CriterionModifierIncludes},
+	}
+	if excluded := s.excludeTagsCriterion(); excluded != nil {
+		filter.OperatorFilter = stash.OperatorFilter[stash.PerformerFilterType]{
+			And: &stash.PerformerFilterType{Tags: excluded},
+		}
+	}
+
+	var performers []stash.Performer
+	err = stash.FindAllPerformers(s.graphqlClient, filter, stash.DefaultPageSize, func(page []stash.Performer, count int) error {
+		for _, performer := range page {
+			if limit > 0 && len(performers) >= limit {
+				return stash.ErrStopPaging
+			}
+			performers = append(performers, performer)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query synced performers: %w", err)
+	}
+	return performers, nil
+}
+
+// trainPerformer adds verified faces from a performer's images to its subject
+func (s *Service) trainPerformer(performer *stash.Performer, maxExamples int, report *trainingReport) error {
+	itemTrace := trace.New("prf", string(performer.ID))
+	defer trace.Start(itemTrace)()
+
+	subject := compreface.FindPersonAlias(performer)
+	if subject == "" {
+		return fmt.Errorf("performer %s has no 'Person ...' alias; synchronize it first", performer.Name)
+	}
+
+	faces, err := s.comprefaceClient.ListFaces(subject)
+	if err != nil {
+		return fmt.Errorf("failed to list faces of %s: %w", subject, err)
+	}
+	if len(faces) == 0 {
+		return fmt.Errorf("subject %s has no examples", subject)
+	}
+	if len(faces) >= maxExamples {
+		log.Infof("Performer %s: subject %s already has %d examples", performer.Name, subject, len(faces))
+		report.full++
+		return nil
+	}
+
+	var references [][]byte
+	for _, face := range faces {
+		if len(references) >= maxVerifyExamples {
+			break
+		}
+		example, err := s.comprefaceClient.DownloadFaceImage(face.ImageID)
+		if err != nil {
+			log.Warnf("Failed to download example %s of %s: %v", face.ImageID, subject, err)
+			continue
+		}
+		references = append(references, example)
+	}
+	if len(references) == 0 {
+		return fmt.Errorf("no examples of subject %s could be downloaded", subject)
+	}
+
+	report.performers++
+	needed := maxExamples - len(faces)
+	added := 0
+	filter := &stash.ImageFilterType{
+		Performers: &stash.MultiCriterionInput{Value: []string{string(performer.ID)}, Modifier: stash.CriterionModifierIncludes},
+		Tags:       s.excludeTagsCriterion(),
+	}
+	err = stash.FindAllImages(s.graphqlClient, filter, stash.DefaultPageSize, func(images []stash.Image, total int) error {
+		for _, image := range images {
+			if s.stopped() {
+				return fmt.Errorf("operation cancelled")
+			}
+			if added >= needed {
+				return stash.ErrStopPaging
+			}
+			if len(image.Files) == 0 {
+				continue
+			}
+			report.images++
+
+			crop, similarity, err := s.bestVerifiedFace(image.Files[0].Path, references)
+			if err != nil {
+				log.Warnf("Performer %s: failed to check image %s: %v", performer.Name, image.ID, err)
+				continue
+			}
+			switch {
+			case similarity < s.config.MinSimilarity:
+				log.Debugf("Performer %s: no face in image %s verifies (best similarity %.2f)", performer.Name, image.ID, similarity)
+				report.rejected++
+			case similarity >= trainingDuplicateSimilarity:
+				log.Debugf("Performer %s: face in image %s is already an example", performer.Name, image.ID)
+				report.duplicates++
+			default:
+				if _, err := s.comprefaceClient.AddSubjectFromBytes(subject, crop, fmt.Sprintf("image_%s.jpg", image.ID)); err != nil {
+					return fmt.Errorf("failed to add example to subject %s: %w", subject, err)
+				}
+				log.Infof("Performer %s: added face from image %s to subject %s (similarity: %.2f)", performer.Name, image.ID, subject, similarity)
+				report.added++
+				added++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to train from images: %w", err)
+	}
+
+	log.Infof("Performer %s: subject %s now has %d examples", performer.Name, subject, len(faces)+added)
+	return nil
+}
+
+// bestVerifiedFace detects the faces in an image and returns the crop of the
+// one most similar to the references, with its similarity. Faces below the
+// minimum size are not considered; an image without any returns a similarity
+// of 0.
+func (s *Service) bestVerifiedFace(imagePath string, references [][]byte) ([]byte, float64, error) {
+	imageBytes, err := s.loadImageBytes(imagePath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	detection, err := s.comprefaceClient.DetectFacesFromBytes(imageBytes, "image.jpg")
+	if err != nil {
+		if errors.Is(err, compreface.ErrNoFaceFound) {
+			return nil, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to detect faces: %w", err)
+	}
+
+	var best []byte
+	bestSimilarity := 0.0
+	for _, face := range detection.Result {
+		width := face.Box.XMax - face.Box.XMin
+		height := face.Box.YMax - face.Box.YMin
+		if width < s.config.MinFaceSize || height < s.config.MinFaceSize {
+			continue
+		}
+
+		crop, err := s.cropFaceBytes(imageBytes, face.Box, 20)
+		if err != nil {
+			log.Debugf("Failed to crop face: %v", err)
+			continue
+		}
+		for _, reference := range references {
+			similarity, err := s.comprefaceClient.VerifyFaces(reference, crop)
+			if err != nil {
+				return nil, 0, fmt.Errorf("verification failed: %w", err)
+			}
+			if similarity > bestSimilarity {
+				best, bestSimilarity = crop, similarity
+			}
+		}
+	}
+	return best, bestSimilarity, nil
+}
@@ -1,22 +1,70 @@
 package rpc
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	graphql "github.com/hasura/go-graphql-client"
 	"github.com/stashapp/stash/pkg/plugin/common"
 
 	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
 	"github.com/smegmarip/stash-compreface-plugin/internal/config"
+	"github.com/smegmarip/stash-compreface-plugin/internal/sprite"
 	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+	"github.com/smegmarip/stash-compreface-plugin/internal/throttle"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
 )
 
 // Service is the main RPC service struct
 type Service struct {
-	stopping         bool
-	serverConnection common.StashServerConnection
-	graphqlClient    *graphql.Client
-	config           *config.PluginConfig
-	tagCache         *stash.TagCache
-	comprefaceClient *compreface.Client
+	ctx                  context.Context    // Done once Stop is called; cancels outstanding requests and job polling
+	cancel               context.CancelFunc // Cancels ctx
+	injected             Components         // Component constructors replacing the defaults
+	components           components         // Feature areas task modes are routed to
+	serverConnection     common.StashServerConnection
+	graphqlClient        *graphql.Client
+	config               *config.PluginConfig
+	tagCache             *stash.TagCache
+	comprefaceClient     *compreface.Client
+	progressStage        *progressStage // Active stage window when running a multi-stage pipeline
+	writer               *stashWriter   // Asynchronous Stash writer, active during batch tasks
+	spriteExtractor      *sprite.Extractor
+	exclusionTagIDs      []string                  // Resolved shared exclusion tags, nil until first use
+	ignoreTagIDs         []string                  // Resolved exclude tag, nil until first use
+	enhancementSupported *bool                     // Frame server enhancement capability, nil until probed
+	visionJobSlots       throttle.Semaphore        // Bounds Vision Service jobs in flight across clients
+	visionPacing         visionPacing              // Vision Service load and the pacing applied for it
+	stashWriteLimiter    *throttle.RateLimiter     // Paces queued Stash writes
+	summary              runSummary                // Work done by the current task
+	items                taskItems                 // Created performers and item failures of the current task
+	faceStore            *store.Store              // Local face match decisions, nil when disabled
+	jobProfiles          *store.JobProfiles        // Vision job parameters that succeeded on retry, nil when unavailable
+	checkpoints          *store.Checkpoints        // Last finished page of batch tasks, nil when unavailable
+	failures             *store.Failures           // Failed attempts per item across runs, nil when unavailable
+	reviewQueue          *store.ReviewQueue        // Unmatched faces awaiting review, nil when unavailable
+	clusters             *store.Clusters           // Unmatched scene clusters awaiting assignment, nil when unavailable
+	exampleCounts        subjectExampleCounts      // Examples per subject, listed for match tie-breaking
+	embeddingMatches     embeddingMatches          // Best subjects of the embeddings prefetched for items in flight
+	resume               bool                      // Continue batch tasks after their checkpoint (resume argument)
+	scope                contentScope              // Media batch tasks are limited to (studio, tag, creation date and path arguments)
+	report               *runReport                // Items of the current batch run, nil when run reports are disabled
+	processedGalleries   *store.ProcessedGalleries // Images identified per gallery, nil when unavailable
+	faceGallery          faceGalleryWrites         // Face crops written to the face gallery by the current task
+}
+
+// progressStage maps a stage's 0-1 progress onto a slice of the overall progress
+type progressStage struct {
+	offset float64
+	weight float64
+}
+
+// PipelineStageResult records the outcome of a single fullPipeline stage
+type PipelineStageResult struct {
+	Name     string
+	Duration time.Duration
+	Err      error
 }
 
 type PerformerData struct {
@@ -28,10 +76,28 @@ type PerformerData struct {
 
 // FaceIdentity represents a recognized face identity
 type FaceIdentity struct {
-	ImageID     string                  `json:"image_id"`
-	BoundingBox *compreface.BoundingBox `json:"bounding_box,omitempty"`
-	Performer   PerformerData           `json:"performer"`
-	Confidence  *float64                `json:"confidence"`
+	ImageID        string                  `json:"image_id"`
+	BoundingBox    *compreface.BoundingBox `json:"bounding_box,omitempty"`
+	Performer      PerformerData           `json:"performer"`
+	Confidence     *float64                `json:"confidence"`
+	MatchedExample *MatchedExample         `json:"matched_example,omitempty"`
+	TraceID        string                  `json:"trace_id,omitempty"` // Processing trace ID, as shown in logs
+}
+
+// FaceDetectionResult is the outcome of detecting and identifying the faces
+// in an image, whichever detector produced it
+type FaceDetectionResult struct {
+	Detector      string         // Detector that produced the result (config.ImageDetector*)
+	FacesDetected int            // Faces found in the image, before any faceIndex selection
+	Identities    []FaceIdentity // Identities of the processed faces
+}
+
+// MatchedExample is the stored subject example a face was matched against
+type MatchedExample struct {
+	Subject    string   `json:"subject"`
+	ImageID    string   `json:"image_id"`
+	Image      string   `json:"image,omitempty"`      // Example as a data URI; empty when it cannot be downloaded
+	Similarity *float64 `json:"similarity,omitempty"` // Nil when the subject has a single example (not verified)
 }
 
 // Response envelope for IdentifyImage RPC
@@ -39,6 +105,131 @@ type IdentifyImageResponse struct {
 	Result *[]FaceIdentity `json:"result"`
 }
 
+// PerformerImageVerification is the result of verifying a candidate image
+// against a performer's subject
+type PerformerImageVerification struct {
+	ImageID     string  `json:"image_id"`
+	PerformerID string  `json:"performer_id"`
+	Subject     string  `json:"subject"`
+	Similarity  float64 `json:"similarity"` // Best similarity to the subject's examples
+	Match       bool    `json:"match"`      // Similarity at or above the minimum similarity
+	Associated  bool    `json:"associated"` // Performer added to the image
+}
+
+// Scene face cluster statuses
+const (
+	ClusterMatched   = "matched"   // Matched to, or created as, a performer
+	ClusterUnmatched = "unmatched" // No performer; see QualityTier for skipped faces
+	ClusterFailed    = "failed"    // Processing the cluster failed; see Error
+)
+
+// Face quality tiers of a scene face cluster
+const (
+	QualityTierSubject     = "subject"     // Good enough to create a new subject
+	QualityTierRecognition = "recognition" // Good enough to match, not to create a subject
+	QualityTierLow         = "low"         // Below the recognition bar, skipped
+)
+
+// SceneIdentification is the complete result of identifying a scene's faces,
+// for review tools driving manual assignment
+type SceneIdentification struct {
+	SceneID       string             `json:"scene_id"`
+	Method        string             `json:"method"`         // Vision Service analysis method (e.g. "sprites")
+	FacesDetected int                `json:"faces_detected"` // Clusters passing the recognition quality bar
+	Clusters      []SceneFaceCluster `json:"clusters"`
+	OverlayNames  []OverlayName      `json:"overlay_names,omitempty"` // Performers named in on-screen text (overlayOcr)
+	TraceID       string             `json:"trace_id,omitempty"`
+}
+
+// OverlayName is a performer name read from a scene's on-screen text
+type OverlayName struct {
+	Text          string  `json:"text"`
+	Timestamp     float64 `json:"timestamp"`
+	Confidence    float64 `json:"confidence"` // OCR confidence
+	PerformerID   string  `json:"performer_id"`
+	PerformerName string  `json:"performer_name"`
+}
+
+// SceneFaceCluster is one unique face found in a scene
+type SceneFaceCluster struct {
+	FaceID       string                  `json:"face_id"`
+	BoundingBox  *compreface.BoundingBox `json:"bounding_box,omitempty"` // Representative detection, in frame pixels
+	Status       string                  `json:"status"`                 // Cluster* status
+	Performer    PerformerData           `json:"performer"`              // Demographics; ID and name when matched
+	Similarity   *float64                `json:"similarity,omitempty"`
+	Quality      float64                 `json:"quality"`      // Composite quality of the representative detection
+	QualityTier  string                  `json:"quality_tier"` // QualityTier* tier
+	Detections   int                     `json:"detections"`
+	Timestamp    float64                 `json:"timestamp"` // Representative detection
+	Appearances  []vision.Appearance     `json:"appearances"`
+	HasEmbedding bool                    `json:"has_embedding"`
+	Error        string                  `json:"error,omitempty"`
+	TraceID      string                  `json:"trace_id,omitempty"`
+	UnmatchedID  string                  `json:"unmatched_id,omitempty"` // Cluster ID for assignCluster when recorded
+}
+
+// GallerySummary is the aggregate result of identifying a gallery's images
+type GallerySummary struct {
+	GalleryID         string                `json:"gallery_id"`
+	ImagesProcessed   int                   `json:"images_processed"`
+	ImagesSkipped     int                   `json:"images_skipped"`   // Identified by earlier runs
+	ImagesRemaining   int                   `json:"images_remaining"` // Left for later runs by the limit
+	Complete          bool                  `json:"complete"`         // Every image identified without failures
+	FacesDetected     int                   `json:"faces_detected"`
+	PerformersMatched []GalleryPerformer    `json:"performers_matched"` // Distinct, most frequent first
+	PerformersCreated int                   `json:"performers_created"`
+	Failures          []GalleryImageFailure `json:"failures"`
+}
+
+// GalleryPerformer is a performer matched in a gallery
+type GalleryPerformer struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Images int    `json:"images"` // Images the performer was matched in
+}
+
+// GalleryImageFailure is a gallery image that could not be identified
+type GalleryImageFailure struct {
+	ImageID string `json:"image_id"`
+	Error   string `json:"error"`
+}
+
+// PendingFacesResponse lists the faces awaiting review
+type PendingFacesResponse struct {
+	Total int                `json:"total"` // All pending faces, including those beyond the limit
+	Faces []PendingFaceEntry `json:"faces"`
+}
+
+// PendingFaceEntry is a face awaiting review, with its crop
+type PendingFaceEntry struct {
+	store.PendingFace
+	Image string `json:"image"` // Face crop as a data URI
+}
+
+// UnmatchedClustersResponse lists the scene clusters awaiting assignment
+type UnmatchedClustersResponse struct {
+	Total    int                     `json:"total"` // All unmatched clusters, including those beyond the limit
+	Clusters []UnmatchedClusterEntry `json:"clusters"`
+}
+
+// UnmatchedClusterEntry is a scene cluster awaiting assignment, with its
+// thumbnail
+type UnmatchedClusterEntry struct {
+	store.UnmatchedCluster
+	Image string `json:"image"` // Face crop of the representative detection as a data URI
+}
+
+// MissingFileError reports a media file that does not exist on disk
+type MissingFileError struct {
+	SourceID string
+	Path     string
+	TraceID  string
+}
+
+func (e *MissingFileError) Error() string {
+	return fmt.Sprintf("file missing on disk: %s", e.Path)
+}
+
 // FaceQualityResult contains quality assessment outcome for CompreFace compatibility
 type FaceQualityResult struct {
 	Acceptable bool
@@ -51,9 +242,17 @@ type FaceQualityResult struct {
 }
 
 // FaceProcessingContext provides context for face processing.
-// Either Scene or ImageBytes must be provided.
+// Either Scene, ImageBytes or ClipPath must be provided.
 type FaceProcessingContext struct {
 	Scene      *stash.Scene // For scene processing (video/sprite extraction)
 	ImageBytes []byte       // For image processing (pre-loaded image data)
+	ClipPath   string       // For image clips (frames extracted from the clip file)
 	SourceID   string       // ID of the source (image ID or scene ID)
+	Frames     *sceneFrames // Frames prefetched for the scene (nil = extract individually)
+
+	OverlaySubjects map[string]string // Subjects named in the scene's on-screen text, a prior for tie-breaking
+	ExtraPerformers *[]graphql.ID     // Collects performers matched by extra faces in face crops (nil = report only)
+
+	CreateNewSubjects bool // Create subject+performer for unmatched faces (false = match-only)
+	ReviewNewSubjects bool // Queue unmatched faces for review instead of creating them
 }
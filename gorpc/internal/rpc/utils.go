@@ -2,8 +2,8 @@
 package rpc
 
 import (
+	"fmt"
 	"net/url"
-	"os"
 	"regexp"
 	"strings"
 
@@ -17,14 +17,37 @@ import (
 	_ "golang.org/x/image/webp" // Register WEBP format
 
 	"github.com/rwcarlsen/goexif/exif"
-	"github.com/stashapp/stash/pkg/plugin/common/log"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+	"github.com/smegmarip/stash-compreface-plugin/pkg/utils"
 )
 
+// stashBaseURL returns the base URL Stash is reached at: the external URL
+// when one is configured, otherwise the server connection's address.
+func (s *Service) stashBaseURL() string {
+	if s.config.ExternalStashURL != "" {
+		return s.config.ExternalStashURL
+	}
+	return s.serverURL()
+}
+
+// serverURL returns the address of the server connection Stash passed in
+func (s *Service) serverURL() string {
+	return fmt.Sprintf("%s://%s:%d", s.serverConnection.Scheme, s.serverConnection.Host, s.serverConnection.Port)
+}
+
 // NormalizeHost normalizes localhost IP addresses in the given URL to the configured Stash host URL.
+// With an external Stash URL configured, URLs on the server connection's address are rewritten to it too.
 func (s *Service) NormalizeHost(urlStr string) string {
 	log.Debugf("Normalizing URL host for: %s", urlStr)
 	hostName := "0.0.0.0"
 	config := s.config
+	if config.ExternalStashURL != "" && s.serverConnection.Host != "" {
+		if rest, ok := strings.CutPrefix(urlStr, s.serverURL()); ok && (rest == "" || strings.HasPrefix(rest, "/")) {
+			log.Debugf("Detected internal Stash address, normalizing to %s", config.ExternalStashURL)
+			return config.ExternalStashURL + rest
+		}
+	}
 	u, err := url.Parse(urlStr)
 	if err != nil {
 		log.Warnf("Failed to parse URL %s: %v", urlStr, err)
@@ -52,30 +75,8 @@ func (s *Service) NormalizeHost(urlStr string) string {
 // If no EXIF orientation is found or orientation == 1, returns original bytes unchanged.
 // If transformation fails, returns original bytes with warning log.
 func NormalizeImageOrientation(imageBytes []byte) ([]byte, error) {
-	// Parse EXIF from bytes (reads from EXIF IFD only, not XMP/TIFF)
-	reader := bytes.NewReader(imageBytes)
-	exifData, err := exif.Decode(reader)
-	if err != nil {
-		// No EXIF data or corrupt EXIF - return original bytes
-		log.Debugf("No EXIF data found or failed to decode: %v", err)
-		return imageBytes, nil
-	}
-
-	// Check orientation tag 274 in EXIF IFD0
-	orientationTag, err := exifData.Get(exif.Orientation)
-	if err != nil {
-		// No orientation tag - return original bytes
-		log.Debugf("No EXIF orientation tag found")
-		return imageBytes, nil
-	}
-
-	orientation, err := orientationTag.Int(0)
-	if err != nil {
-		log.Warnf("Failed to parse EXIF orientation value: %v", err)
-		return imageBytes, nil
-	}
-
 	// If orientation == 1 (normal), no transformation needed
+	orientation := exifOrientation(imageBytes)
 	if orientation == 1 {
 		log.Debugf("EXIF orientation is 1 (normal), no transformation needed")
 		return imageBytes, nil
@@ -92,6 +93,9 @@ func NormalizeImageOrientation(imageBytes []byte) ([]byte, error) {
 
 	log.Debugf("Decoded image format for EXIF normalization: %s", format)
 
+	// Convert to sRGB before re-encoding, since the ICC profile is not preserved
+	img = utils.ConvertToSRGB(img, utils.ExtractICCProfile(imageBytes))
+
 	// Apply transformation based on EXIF orientation value
 	transformedImg := applyOrientation(img, orientation)
 
@@ -106,6 +110,32 @@ func NormalizeImageOrientation(imageBytes []byte) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// exifOrientation returns the EXIF orientation tag 274 of an image, or 1
+// (normal) when the image has no EXIF data or orientation tag
+func exifOrientation(imageBytes []byte) int {
+	// Parse EXIF from bytes (reads from EXIF IFD only, not XMP/TIFF)
+	exifData, err := exif.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		// No EXIF data or corrupt EXIF
+		log.Debugf("No EXIF data found or failed to decode: %v", err)
+		return 1
+	}
+
+	// Check orientation tag 274 in EXIF IFD0
+	orientationTag, err := exifData.Get(exif.Orientation)
+	if err != nil {
+		log.Debugf("No EXIF orientation tag found")
+		return 1
+	}
+
+	orientation, err := orientationTag.Int(0)
+	if err != nil {
+		log.Warnf("Failed to parse EXIF orientation value: %v", err)
+		return 1
+	}
+	return orientation
+}
+
 // applyOrientation applies EXIF orientation transformation to image
 func applyOrientation(img image.Image, orientation int) image.Image {
 	switch orientation {
@@ -217,16 +247,3 @@ func flipVertical(img image.Image) image.Image {
 
 	return flipped
 }
-
-// saveImageBytesToFile saves image bytes to specified file path for debugging
-func saveImageBytesToFile(imageBytes []byte, filePath string) error {
-	// Save cropped face for debugging
-	err := os.WriteFile(filePath, imageBytes, 0644)
-	if err != nil {
-		log.Warnf("Failed to save debug cropped face to %s: %v", filePath, err)
-		return err
-	} else {
-		log.Debugf("Saved debug cropped face to %s", filePath)
-	}
-	return nil
-}
@@ -0,0 +1,102 @@
+package rpc
+
+import (
+	"fmt"
+
+	graphql "github.com/hasura/go-graphql-client"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Performer Image Verification
+// ============================================================================
+
+// maxVerifyExamples caps the subject examples a candidate image is verified
+// against
+const maxVerifyExamples = 3
+
+// verifyPerformerImage checks with the Compreface verification service whether
+// an image shows the performer, by comparing it with the examples stored for
+// the performer's subject. With associate set, a matching image gets the
+// performer added.
+func (s *Service) verifyPerformerImage(imageID string, performerID string, associate bool) (*PerformerImageVerification, error) {
+	itemTrace := trace.New("img", imageID)
+	defer trace.Start(itemTrace)()
+
+	if s.stopped() {
+		return nil, fmt.Errorf("operation cancelled")
+	}
+
+	performer, err := stash.GetPerformerByID(s.graphqlClient, graphql.ID(performerID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get performer: %w", err)
+	}
+	if performer.ID == "" {
+		return nil, fmt.Errorf("performer %s not found", performerID)
+	}
+	subject := compreface.FindPersonAlias(performer)
+	if subject == "" {
+		return nil, fmt.Errorf("performer %s has no 'Person ...' alias; synchronize it first", performer.Name)
+	}
+
+	faces, err := s.comprefaceClient.ListFaces(subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list faces of %s: %w", subject, err)
+	}
+	if len(faces) == 0 {
+		return nil, fmt.Errorf("subject %s has no examples", subject)
+	}
+
+	image, err := stash.GetImage(s.graphqlClient, graphql.ID(imageID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image: %w", err)
+	}
+	if len(image.Files) == 0 {
+		return nil, fmt.Errorf("image %s has no files", imageID)
+	}
+	imageBytes, err := s.loadImageBytes(image.Files[0].Path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PerformerImageVerification{
+		ImageID:     imageID,
+		PerformerID: performerID,
+		Subject:     subject,
+	}
+	for i, face := range faces {
+		if i >= maxVerifyExamples || result.Similarity >= s.config.MinSimilarity {
+			break
+		}
+		example, err := s.comprefaceClient.DownloadFaceImage(face.ImageID)
+		if err != nil {
+			log.Warnf("Failed to download example %s of %s: %v", face.ImageID, subject, err)
+			continue
+		}
+		similarity, err := s.comprefaceClient.VerifyFaces(example, imageBytes)
+		if err != nil {
+			return nil, fmt.Errorf("verification failed: %w", err)
+		}
+		if similarity > result.Similarity {
+			result.Similarity = similarity
+		}
+	}
+	result.Match = result.Similarity >= s.config.MinSimilarity
+	if !result.Match {
+		log.Infof("Image %s does not match performer %s (similarity: %.2f)", imageID, performer.Name, result.Similarity)
+		return result, nil
+	}
+	log.Infof("Image %s matches performer %s (similarity: %.2f)", imageID, performer.Name, result.Similarity)
+
+	if associate {
+		if err := s.associateExistingPerformers(*image, []graphql.ID{performer.ID}); err != nil {
+			return nil, err
+		}
+		result.Associated = true
+	}
+	return result, nil
+}
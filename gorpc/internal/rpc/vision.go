@@ -4,16 +4,19 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"image"
 	"image/jpeg"
 	"os"
+	"time"
 
 	graphql "github.com/hasura/go-graphql-client"
-	"github.com/stashapp/stash/pkg/plugin/common/log"
 
 	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/config"
 	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
 	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+	"github.com/smegmarip/stash-compreface-plugin/pkg/utils"
 )
 
 // ============================================================================
@@ -44,26 +47,65 @@ const (
 // Vision Service Job Submission
 // ============================================================================
 
+// buildEnhancementParameters returns the face enhancement settings used for
+// Vision Service jobs and enhanced frame extraction, from the enhanceModel and
+// enhanceFidelityWeight settings. Enhancement is disabled when turned off by
+// enhanceEnabled or when the frame server reports it cannot run the model.
+func (s *Service) buildEnhancementParameters() vision.EnhancementParameters {
+	return vision.EnhancementParameters{
+		Enabled:        s.enhancementAvailable(),
+		QualityTrigger: s.config.EnhanceQualityScoreTrigger,
+		Model:          s.config.EnhanceModel,
+		FidelityWeight: s.config.EnhanceFidelityWeight,
+	}
+}
+
+// enhancementAvailable probes the frame server capabilities once per task and
+// reports whether face enhancement can be requested. Servers that cannot be
+// probed or do not report capabilities are assumed to support it.
+func (s *Service) enhancementAvailable() bool {
+	if s.enhancementSupported != nil {
+		return *s.enhancementSupported
+	}
+
+	supported := true
+	if !s.config.EnhanceEnabled {
+		supported = false
+		log.Info("Face enhancement disabled by the enhanceEnabled setting")
+		s.enhancementSupported = &supported
+		return supported
+	}
+
+	health, err := s.newVisionClient().FrameServerHealth()
+	if err != nil {
+		log.Debugf("Frame server capabilities unknown, leaving enhancement enabled: %v", err)
+	} else if ok, reason := health.SupportsEnhancement(s.config.EnhanceModel); !ok {
+		supported = false
+		log.Infof("Face enhancement disabled: frame server reports %s", reason)
+	}
+
+	s.enhancementSupported = &supported
+	return supported
+}
+
 // BuildImageAnalyzeRequest creates a Vision Service request for image analysis
 func (s *Service) BuildImageAnalyzeRequest(imagePath string, imageID string) vision.AnalyzeRequest {
 	minConfidence := s.config.MinConfidenceScore
 	minQuality := s.config.MinProcessingQualityScore
-	qualityTrigger := s.config.EnhanceQualityScoreTrigger
 
-	enhancementParams := vision.EnhancementParameters{
-		Enabled:        true,
-		QualityTrigger: qualityTrigger,
-		Model:          "codeformer",
-		FidelityWeight: 0.25,
-	}
+	enhancementParams := s.buildEnhancementParameters()
 
 	parameters := vision.FacesParameters{
 		FaceMinConfidence:  minConfidence,
 		FaceMinQuality:     minQuality,
 		MaxFaces:           10, // Images typically have fewer faces than video
-		DetectDemographics: true,
+		DetectDemographics: !s.config.SkipDemographics,
 		Enhancement:        &enhancementParams,
 	}
+	if s.config.AnonymizationMode {
+		parameters.DetectDemographics = false
+		parameters.CacheDuration = anonymizedCacheDuration
+	}
 
 	return vision.AnalyzeRequest{
 		Source:         imagePath,
@@ -79,15 +121,24 @@ func (s *Service) BuildImageAnalyzeRequest(imagePath string, imageID string) vis
 	}
 }
 
-// SubmitImageJob submits an image to Vision Service and waits for results
+// SubmitImageJob submits an image to Vision Service and waits for results.
+// Oversized images are submitted downscaled; returned bounding boxes are
+// always in original image coordinates.
 func (s *Service) SubmitImageJob(visionClient *vision.VisionServiceClient, imagePath string, imageID string) (*vision.AnalyzeResults, error) {
-	request := s.BuildImageAnalyzeRequest(imagePath, imageID)
+	submitPath, scale, cleanup, err := s.prepareVisionImage(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare image: %w", err)
+	}
+	defer cleanup()
+
+	request := s.BuildImageAnalyzeRequest(submitPath, imageID)
 
 	// Log request for debugging
 	requestData, _ := json.Marshal(request)
 	log.Debugf("Image %s: Submitting request to Vision Service: %s", imageID, string(requestData))
 
-	// Submit job
+	// Submit job, paced while the Vision Service is under pressure
+	s.paceVisionJob(visionClient)
 	jobResp, err := visionClient.SubmitJob(request)
 	if err != nil {
 		return nil, fmt.Errorf("failed to submit job: %w", err)
@@ -96,13 +147,18 @@ func (s *Service) SubmitImageJob(visionClient *vision.VisionServiceClient, image
 	log.Debugf("Image %s: Vision Service job submitted (job_id=%s)", imageID, jobResp.JobID)
 
 	// Wait for completion
-	results, err := visionClient.WaitForCompletion(jobResp.JobID, func(p float64) {
-		log.Debugf("Image %s: Vision Service progress: %.1f%%", imageID, p*100)
+	timeout := time.Duration(s.config.VisionImageJobTimeout) * time.Second
+	results, err := visionClient.WaitForCompletion(jobResp.JobID, timeout, func(p float64) {
+		log.Context(visionClient.Context).Debugf("Image %s: Vision Service progress: %.1f%%", imageID, p*100)
 	})
 	if err != nil {
 		return nil, fmt.Errorf("vision service job failed: %w", err)
 	}
 
+	if scale != 1 {
+		results.ScaleBoundingBoxes(scale)
+	}
+
 	return results, nil
 }
 
@@ -110,16 +166,36 @@ func (s *Service) SubmitImageJob(visionClient *vision.VisionServiceClient, image
 // Image Loading Utilities
 // ============================================================================
 
-// LoadImageBytes loads an image file and returns it as JPEG bytes.
+// loadImageBytes loads an image file as sRGB JPEG bytes, passing upright
+// sRGB JPEGs up to the jpegPassthroughMaxKB setting through unchanged
+func (s *Service) loadImageBytes(imagePath string) ([]byte, error) {
+	return LoadImageBytes(imagePath, s.config.JPEGPassthroughMaxKB*1024)
+}
+
+// LoadImageBytes loads an image file and returns it as sRGB JPEG bytes.
 // Supports various formats: JPEG, PNG, GIF, BMP, WEBP.
+// Embedded ICC profiles and CMYK color models are converted to sRGB.
+// JPEGs of at most passthroughMaxBytes that are already sRGB and upright
+// (EXIF orientation 1 or none) are returned as read, without re-encoding;
+// 0 always re-encodes.
 // Note: Image format registration is done via blank imports in images.go
-func LoadImageBytes(imagePath string) ([]byte, error) {
+func LoadImageBytes(imagePath string, passthroughMaxBytes int) ([]byte, error) {
 	// Read original image bytes
 	imageBytes, err := os.ReadFile(imagePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read image: %w", err)
 	}
 
+	// Camera RAW files are read through their embedded preview
+	if utils.IsRawImagePath(imagePath) {
+		return RawPreviewJPEG(imageBytes)
+	}
+
+	if len(imageBytes) <= passthroughMaxBytes && utils.IsSRGBJPEG(imageBytes) && exifOrientation(imageBytes) == 1 {
+		log.Debugf("Using %s as-is: upright sRGB JPEG", imagePath)
+		return imageBytes, nil
+	}
+
 	// Normalize EXIF orientation (returns original if no transformation needed)
 	normalizedBytes, err := NormalizeImageOrientation(imageBytes)
 	if err != nil {
@@ -127,8 +203,8 @@ func LoadImageBytes(imagePath string) ([]byte, error) {
 		normalizedBytes = imageBytes
 	}
 
-	// Decode normalized image
-	img, format, err := image.Decode(bytes.NewReader(normalizedBytes))
+	// Decode normalized image, converting to sRGB
+	img, format, err := utils.DecodeImageSRGB(normalizedBytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
@@ -148,10 +224,25 @@ func LoadImageBytes(imagePath string) ([]byte, error) {
 // Face Processing
 // ============================================================================
 
+// embeddingMatchSimilarity is the similarity reported for embedding matches
+const embeddingMatchSimilarity = 0.95
+
 // processFace processes a single detected face from Vision Service.
 // Used by both image and scene processing pipelines.
-// Returns the performer ID if matched or created, empty string if skipped.
-func (s *Service) processFace(visionClient *vision.VisionServiceClient, ctx FaceProcessingContext, face vision.VisionFace, metadata vision.ResultMetadata) (graphql.ID, error) {
+// Returns the performer ID if matched or created, empty string if skipped,
+// along with the match similarity (1.0 for newly created subjects).
+// Unmatched faces only create a new subject when ctx.CreateNewSubjects is set,
+// and are queued for review instead when ctx.ReviewNewSubjects is set.
+func (s *Service) processFace(visionClient *vision.VisionServiceClient, ctx FaceProcessingContext, face vision.VisionFace, metadata vision.ResultMetadata) (graphql.ID, float64, error) {
+	s.pauseBeforeFace()
+
+	// Apply occlusion strategy (may swap detection or request enhancement)
+	face, metadata, ok := s.resolveOccludedFace(ctx, face, metadata)
+	if !ok {
+		s.reportFace(ctx, face, ReportFace{Status: ReportFaceSkipped, Reason: "occluded"})
+		return "", 0, nil
+	}
+
 	// Get the representative detection (best quality frame)
 	det := face.RepresentativeDetection
 
@@ -168,66 +259,112 @@ func (s *Service) processFace(visionClient *vision.VisionServiceClient, ctx Face
 
 	if !qr.Acceptable {
 		log.Debugf("Skipping face %s: %s", face.FaceID, qr.Reason)
-		return "", nil
+		s.reportFace(ctx, face, ReportFace{Status: ReportFaceSkipped, Reason: qr.Reason})
+		return "", 0, nil
+	}
+
+	// Reuse a decision from an earlier run, or a local embedding match
+	if performerID, similarity := s.recallFace(ctx, face); performerID != "" {
+		s.reportFace(ctx, face, ReportFace{Status: ReportFaceMatched, PerformerID: string(performerID), Similarity: similarity, Reason: "face store"})
+		return performerID, similarity, nil
 	}
 
 	// Try embedding-based recognition first (if enabled and 512-D embedding available)
 	if s.config.EnableEmbeddingRecognition && len(face.Embedding) == 512 {
 		performerID, _ := s.recognizeEmbeddedStashFace(face)
 		if performerID != "" {
-			return performerID, nil
+			s.rememberFace(ctx, face, performerID, embeddingMatchSimilarity)
+			s.reportFace(ctx, face, ReportFace{Status: ReportFaceMatched, PerformerID: string(performerID), Similarity: embeddingMatchSimilarity, Reason: "embedding"})
+			return performerID, embeddingMatchSimilarity, nil
 		}
 	}
 
 	// Extract frame/thumbnail based on context
+	span := trace.StartSpan(trace.StageFetch)
 	frameBytes, err := s.extractFrameBytesFromContext(visionClient, ctx, face, metadata)
+	span.End(err)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	// Crop face from frame using bounding box
+	span = trace.StartSpan(trace.StageCrop)
 	faceCrop, err := s.cropFaceFromFrame(frameBytes, det.BBox, 20)
+	span.End(err)
+	cropped := err == nil
 	if err != nil {
 		if faceCrop != nil {
 			log.Warnf("Using uncropped frame for face %s due to cropping error: %v", face.FaceID, err)
 		} else {
-			return "", fmt.Errorf("failed to crop face: %w", err)
+			return "", 0, fmt.Errorf("failed to crop face: %w", err)
 		}
 	}
 
-	log.Debugf("Extracted and cropped face from frame (%.0f bytes)", len(faceCrop))
+	log.Debugf("Extracted and cropped face from frame (%d bytes)", len(faceCrop))
 
 	// Try to recognize face in Compreface
-	recognitionResp, err := s.comprefaceClient.RecognizeFacesFromBytes(faceCrop, "face.jpg")
+	span = trace.StartSpan(trace.StageRecognize)
+	recognized, err := s.recognizeFaceCrop(faceCrop)
+	span.End(err)
 	if err != nil {
-		return "", fmt.Errorf("compreface recognition failed: %w", err)
+		return "", 0, fmt.Errorf("compreface recognition failed: %w", err)
+	}
+	if len(recognized) > 1 {
+		s.matchExtraFaces(ctx, face, recognized[1:])
 	}
 
 	// Check if face matched to existing subject
-	if len(recognitionResp.Result) > 0 && len(recognitionResp.Result[0].Subjects) > 0 {
-		// Face matched to existing subject
-		bestMatch := recognitionResp.Result[0].Subjects[0] // Highest similarity match
-		if bestMatch.Similarity < s.config.MinSimilarity {
-			// Similarity too low, treat as no match
-			goto createNewSubject
+	if len(recognized) > 0 {
+		if match, ok := s.selectMatch(recognized[0].Subjects, face.Embedding, ctx.OverlaySubjects); ok {
+			if match.ambiguous && ctx.ReviewNewSubjects {
+				s.reportFace(ctx, face, ReportFace{Status: ReportFaceQueued, Subject: match.subject.Subject, Similarity: match.subject.Similarity, Reason: "ambiguous match"})
+				return "", 0, s.reviewVisionFace(ctx, face, faceCrop, match.candidates)
+			}
+
+			// find and return existing performer by matched subject, or empty if not found
+			performerID, err := s.findExistingStashPerformerBySubject(match.subject, face)
+			if err == nil {
+				s.rememberFace(ctx, face, performerID, match.subject.Similarity)
+				if cropped && qr.Composite >= s.config.FaceGalleryMinQuality {
+					s.saveFaceCrop(ctx, face.FaceID, performerID, faceCrop)
+				}
+				outcome := ReportFace{Status: ReportFaceMatched, Subject: match.subject.Subject, PerformerID: string(performerID), Similarity: match.subject.Similarity}
+				if performerID == "" {
+					outcome.Status, outcome.Reason = ReportFaceUnmatched, "subject has no performer"
+				}
+				s.reportFace(ctx, face, outcome)
+			}
+			return performerID, match.subject.Similarity, err
 		}
+	}
 
-		// find and return existing performer by matched subject, or empty if not found
-		return s.findExistingStashPerformerBySubject(bestMatch, face)
+	if !ctx.CreateNewSubjects {
+		log.Debugf("Face %s: No match, subject creation disabled, skipping", face.FaceID)
+		s.reportFace(ctx, face, ReportFace{Status: ReportFaceUnmatched, Reason: "no match, subject creation disabled"})
+		return "", 0, nil
+	}
+	if ctx.ReviewNewSubjects {
+		s.reportFace(ctx, face, ReportFace{Status: ReportFaceQueued, Reason: "new face"})
+		return "", 0, s.reviewVisionFace(ctx, face, faceCrop, nil)
 	}
 
-createNewSubject:
 	// first, create Compreface subject
 	addResponse, err := s.createComprefaceSubject(faceCrop, ctx, face)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
+	s.addSubjectExamples(visionClient, ctx, face, metadata, addResponse.Subject, faceCrop)
 	// then, create Stash performer from Compreface subject
-	performerID, err := s.createStashPerformerFromComprefaceSubject(addResponse.ImageID, face, addResponse.Subject)
+	performerID, err := s.createStashPerformerFromComprefaceSubject(ctx, faceCrop, addResponse.ImageID, face, addResponse.Subject)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
-	return performerID, nil
+	s.rememberFace(ctx, face, performerID, 1.0)
+	if cropped {
+		s.saveFaceCrop(ctx, face.FaceID, performerID, faceCrop)
+	}
+	s.reportFace(ctx, face, ReportFace{Status: ReportFaceCreated, Subject: addResponse.Subject, PerformerID: string(performerID), Similarity: 1.0})
+	return performerID, 1.0, nil
 }
 
 // processFaceForIdentification processes a Vision-detected face for the identify workflow.
@@ -240,6 +377,13 @@ func (s *Service) processFaceForIdentification(
 	metadata vision.ResultMetadata,
 	createPerformer bool,
 ) (*FaceIdentity, error) {
+	s.pauseBeforeFace()
+
+	face, metadata, ok := s.resolveOccludedFace(ctx, face, metadata)
+	if !ok {
+		return nil, nil
+	}
+
 	det := face.RepresentativeDetection
 
 	// Quality check (lower bar for recognition attempt)
@@ -267,40 +411,58 @@ func (s *Service) processFaceForIdentification(
 
 	var performerID graphql.ID
 	var similarity float64
+	var matchedSubject string
+	var faceCrop []byte
 
 	// Step 1: Try embedding recognition (if enabled)
 	if s.config.EnableEmbeddingRecognition && len(face.Embedding) == 512 {
 		performerID, _ = s.recognizeEmbeddedStashFace(face)
 		if performerID != "" {
-			similarity = 0.95 // Embedding match is high confidence
+			similarity = embeddingMatchSimilarity // Embedding match is high confidence
 		}
 	}
 
 	// Step 2-6: If no embedding match, try image-based or create
 	if performerID == "" {
 		// Step 2: Extract frame and crop face
+		span := trace.StartSpan(trace.StageFetch)
 		frameBytes, err := s.extractFrameBytesFromContext(visionClient, ctx, face, metadata)
+		span.End(err)
 		if err != nil {
 			return nil, fmt.Errorf("failed to extract frame: %w", err)
 		}
 
-		faceCrop, err := s.cropFaceFromFrame(frameBytes, det.BBox, 20)
+		span = trace.StartSpan(trace.StageCrop)
+		faceCrop, err = s.cropFaceFromFrame(frameBytes, det.BBox, 20)
+		span.End(err)
 		if err != nil && faceCrop == nil {
 			return nil, fmt.Errorf("failed to crop face: %w", err)
 		}
 
 		// Step 3: Try image-based recognition
-		recognitionResp, err := s.comprefaceClient.RecognizeFacesFromBytes(faceCrop, "face.jpg")
+		span = trace.StartSpan(trace.StageRecognize)
+		recognized, err := s.recognizeFaceCrop(faceCrop)
+		span.End(err)
 		if err != nil {
 			return nil, fmt.Errorf("compreface recognition failed: %w", err)
 		}
+		if len(recognized) > 1 {
+			s.matchExtraFaces(ctx, face, recognized[1:])
+		}
 
 		// Step 4: Check if matched to existing subject
-		if len(recognitionResp.Result) > 0 && len(recognitionResp.Result[0].Subjects) > 0 {
-			bestMatch := recognitionResp.Result[0].Subjects[0]
-			if bestMatch.Similarity >= s.config.MinSimilarity {
-				performerID, _ = s.findExistingStashPerformerBySubject(bestMatch, face)
-				similarity = bestMatch.Similarity
+		var candidates []string // Set when the match was too close to call
+		if len(recognized) > 0 {
+			if match, ok := s.selectMatch(recognized[0].Subjects, face.Embedding, ctx.OverlaySubjects); ok {
+				if match.ambiguous && ctx.ReviewNewSubjects {
+					candidates = match.candidates
+				} else {
+					performerID, _ = s.findExistingStashPerformerBySubject(match.subject, face)
+					similarity = match.subject.Similarity
+					if performerID != "" {
+						matchedSubject = match.subject.Subject
+					}
+				}
 			}
 		}
 
@@ -315,6 +477,17 @@ func (s *Service) processFaceForIdentification(
 				return identity, nil
 			}
 
+			// Queue the face for review instead of creating (or guessing) it
+			if ctx.ReviewNewSubjects {
+				if err := s.reviewVisionFace(ctx, face, faceCrop, candidates); err != nil {
+					log.Warnf("Face %s: %v", face.FaceID, err)
+				}
+				identity.Performer.Name = createSubjectName(ctx.SourceID, face.FaceID)
+				conf := 0.0
+				identity.Confidence = &conf
+				return identity, nil
+			}
+
 			// Step 6: Create new subject and performer
 			addResponse, err := s.createComprefaceSubject(faceCrop, ctx, face)
 			if err != nil {
@@ -325,8 +498,9 @@ func (s *Service) processFaceForIdentification(
 				log.Debugf("Face %s: Failed to create subject: %v", face.FaceID, err)
 				return identity, nil
 			}
+			s.addSubjectExamples(visionClient, ctx, face, metadata, addResponse.Subject, faceCrop)
 
-			performerID, err = s.createStashPerformerFromComprefaceSubject(addResponse.ImageID, face, addResponse.Subject)
+			performerID, err = s.createStashPerformerFromComprefaceSubject(ctx, faceCrop, addResponse.ImageID, face, addResponse.Subject)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create performer: %w", err)
 			}
@@ -342,6 +516,9 @@ func (s *Service) processFaceForIdentification(
 		identity.Performer.Name = performer.Name
 		identity.Confidence = &confidence
 	}
+	if matchedSubject != "" {
+		identity.MatchedExample = s.matchedExample(matchedSubject, faceCrop)
+	}
 
 	return identity, nil
 }
@@ -350,7 +527,10 @@ func (s *Service) processFaceForIdentification(
 func (s *Service) recognizeEmbeddedStashFace(face vision.VisionFace) (graphql.ID, error) {
 	// Try embedding-based recognition first (if 512-D embedding available)
 	if len(face.Embedding) == 512 {
+		span := trace.StartSpan(trace.StageRecognize)
+		span.SetAttribute("recognition.method", "embedding")
 		performerID, similarity, err := s.recognizeByEmbedding(face.Embedding)
+		span.End(err)
 		if err != nil {
 			log.Debugf("Face %s: Embedding recognition failed: %v, trying image-based", face.FaceID, err)
 		} else if performerID != "" {
@@ -361,6 +541,7 @@ func (s *Service) recognizeEmbeddedStashFace(face vision.VisionFace) (graphql.ID
 				performerName = performer.Name
 			}
 			log.Infof("Face %s: Matched via embedding (name: %s, similarity: %.2f)", face.FaceID, performerName, similarity)
+			s.summary.facesMatched.Add(1)
 			return performerID, nil
 		} else {
 			log.Debugf("Face %s: No embedding match found, trying image-based", face.FaceID)
@@ -388,6 +569,12 @@ func (s *Service) extractFrameBytesFromContext(visionClient *vision.VisionServic
 	if ctx.ImageBytes != nil {
 		// Use pre-loaded image bytes (for image processing)
 		frameBytes = ctx.ImageBytes
+	} else if ctx.ClipPath != "" {
+		// Extract frame from an image clip at the representative detection timestamp
+		frameBytes, err = visionClient.ExtractFrame(ctx.ClipPath, det.Timestamp, frameEnhancement)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract clip frame at %.2fs: %w", det.Timestamp, err)
+		}
 	} else if metadata.Method == "sprites" && ctx.Scene != nil {
 		// Extract thumbnail from sprite image
 		spriteVTT := s.NormalizeHost(ctx.Scene.Paths.VTT)
@@ -395,14 +582,20 @@ func (s *Service) extractFrameBytesFromContext(visionClient *vision.VisionServic
 
 		log.Debugf("Extracting face from sprite: vtt=%s, sprite=%s, timestamp=%.2f",
 			spriteVTT, spriteImage, det.Timestamp)
-		frameBytes, err = ExtractFromSprite(spriteImage, spriteVTT, det.Timestamp)
+		frameBytes, err = s.sprites().Extract(spriteImage, spriteVTT, det.Timestamp)
 		if err != nil {
 			return nil, fmt.Errorf("failed to extract sprite thumbnail at %.2fs: %w", det.Timestamp, err)
 		}
 	} else if ctx.Scene != nil {
-		// Extract frame from video at the representative detection timestamp
-		videoPath := ctx.Scene.Files[0].Path
-		frameBytes, err = visionClient.ExtractFrame(videoPath, det.Timestamp, frameEnhancement)
+		// Extract frame from the analyzed video at the representative detection timestamp
+		videoFile := stash.CanonicalVideoFile(ctx.Scene)
+		if videoFile == nil {
+			return nil, fmt.Errorf("scene %s has no files", ctx.Scene.ID)
+		}
+		if frame, ok := ctx.Frames.get(det.Timestamp, frameEnhancement != nil); ok {
+			return frame, nil
+		}
+		frameBytes, err = visionClient.ExtractFrame(videoFile.Path, det.Timestamp, frameEnhancement)
 		if err != nil {
 			return nil, fmt.Errorf("failed to extract frame at %.2fs: %w", det.Timestamp, err)
 		}
@@ -434,6 +627,7 @@ func (s *Service) findExistingStashPerformerBySubject(recognitionResult comprefa
 		}
 		log.Infof("Matched face %s to performer (name: %s, subject: %s, similarity: %.2f)",
 			face.FaceID, performerName, subject, similarity)
+		s.summary.facesMatched.Add(1)
 		return performerID, nil
 	}
 
@@ -450,7 +644,7 @@ func (s *Service) createComprefaceSubject(faceImage []byte, ctx FaceProcessingCo
 	qrCreate := s.assessFaceQuality(det.Quality, s.config.MinQualityScore)
 	if !qrCreate.Acceptable {
 		err := fmt.Errorf("skipping face %s for subject creation: %s", face.FaceID, qrCreate.Reason)
-		log.Debugf(err.Error())
+		log.Debug(err)
 		return nil, err
 	}
 
@@ -470,8 +664,9 @@ func (s *Service) createComprefaceSubject(faceImage []byte, ctx FaceProcessingCo
 	return addResponse, nil
 }
 
-// createStashPerformerFromComprefaceSubject creates a new Stash performer from a Compreface subject.
-func (s *Service) createStashPerformerFromComprefaceSubject(comprefaceImageId string, face vision.VisionFace, subjectName string) (graphql.ID, error) {
+// createStashPerformerFromComprefaceSubject creates a new Stash performer from a Compreface subject,
+// or links the subject to the stash-box performer the face verifies as.
+func (s *Service) createStashPerformerFromComprefaceSubject(ctx FaceProcessingContext, faceCrop []byte, comprefaceImageId string, face vision.VisionFace, subjectName string) (graphql.ID, error) {
 
 	// Create performer in Stash with demographics if available
 	var gender string
@@ -481,11 +676,17 @@ func (s *Service) createStashPerformerFromComprefaceSubject(comprefaceImageId st
 		age = face.Demographics.Age
 	}
 
+	if performerID, ok := s.stashBoxPerformer(faceSource(ctx), faceCrop, subjectName, gender, age, age); ok {
+		return performerID, nil
+	}
+
 	performerSubject := stash.PerformerSubject{
-		Name:   subjectName,
-		Age:    age,
-		Gender: gender,
-		Image:  s.comprefaceClient.SubjectImageURL(comprefaceImageId),
+		Name:    subjectName,
+		Age:     s.birthdateAge(age, age),
+		AgeLow:  age,
+		AgeHigh: age,
+		Gender:  gender,
+		Image:   s.subjectImageDataURI(comprefaceImageId),
 	}
 
 	performer, err := s.createPerformerWithDetails(performerSubject)
@@ -501,8 +702,8 @@ func (s *Service) createStashPerformerFromComprefaceSubject(comprefaceImageId st
 
 // cropFaceFromFrame crops a face region from a frame using the bounding box
 func (s *Service) cropFaceFromFrame(frameBytes []byte, bbox vision.VisionBoundingBox, padding int) ([]byte, error) {
-	// Decode frame bytes to image.Image
-	img, _, err := image.Decode(bytes.NewReader(frameBytes))
+	// Decode frame bytes to image.Image (sRGB)
+	img, _, err := utils.DecodeImageSRGB(frameBytes)
 	if err != nil {
 		return frameBytes, fmt.Errorf("failed to decode frame: %w", err)
 	}
@@ -523,7 +724,7 @@ func (s *Service) cropFaceFromFrame(frameBytes []byte, bbox vision.VisionBoundin
 
 	// Encode cropped image back to JPEG bytes
 	buf := new(bytes.Buffer)
-	if err := jpeg.Encode(buf, cropped, &jpeg.Options{Quality: 90}); err != nil {
+	if err := jpeg.Encode(buf, cropped, &jpeg.Options{Quality: s.jpegQuality(90)}); err != nil {
 		return frameBytes, fmt.Errorf("failed to encode cropped face: %w", err)
 	}
 
@@ -594,6 +795,96 @@ func (s *Service) assessFaceQuality(quality *vision.QualityResult, minComposite
 	return result
 }
 
+// ============================================================================
+// Occlusion Handling
+// ============================================================================
+
+// resolveOccludedFace applies the configured occlusion strategy to a face whose
+// representative detection is flagged as occluded. Returns the (possibly
+// adjusted) face and metadata, and false if the face should be skipped.
+//
+// Strategies:
+//   - process:   use the occluded representative frame as-is
+//   - alternate: swap in the least-occluded acceptable detection from the cluster
+//   - enhance:   re-extract the representative frame with enhancement enabled
+//   - skip:      skip the face
+func (s *Service) resolveOccludedFace(ctx FaceProcessingContext, face vision.VisionFace, metadata vision.ResultMetadata) (vision.VisionFace, vision.ResultMetadata, bool) {
+	det := face.RepresentativeDetection
+	if det.Occlusion == nil || !det.Occlusion.Occluded {
+		return face, metadata, true
+	}
+
+	switch s.config.OcclusionStrategy {
+	case config.OcclusionStrategyAlternate:
+		alt, found := s.selectLeastOccludedDetection(face)
+		if !found {
+			log.Debugf("Skipping face %s: occluded (p=%.2f) with no acceptable alternative detection",
+				face.FaceID, det.Occlusion.Probability)
+			return face, metadata, false
+		}
+		log.Debugf("Face %s: representative detection occluded (p=%.2f), using alternative at %.2fs",
+			face.FaceID, det.Occlusion.Probability, alt.Timestamp)
+		face.RepresentativeDetection = alt
+		return face, metadata, true
+
+	case config.OcclusionStrategyEnhance:
+		// Enhanced extraction is only available through the frame server (scenes, clips)
+		if ctx.ImageBytes != nil || (ctx.Scene == nil && ctx.ClipPath == "") || metadata.Method == "sprites" || !s.enhancementAvailable() {
+			log.Debugf("Skipping face %s: occluded (p=%.2f) and enhancement unavailable for this source",
+				face.FaceID, det.Occlusion.Probability)
+			return face, metadata, false
+		}
+		if metadata.FrameEnhancement == nil || !metadata.FrameEnhancement.Enabled {
+			enhancement := s.buildEnhancementParameters()
+			metadata.FrameEnhancement = &enhancement
+		}
+		face.RepresentativeDetection.Enhanced = true
+		log.Debugf("Face %s: representative detection occluded (p=%.2f), retrying with enhanced frame",
+			face.FaceID, det.Occlusion.Probability)
+		return face, metadata, true
+
+	case config.OcclusionStrategySkip:
+		log.Debugf("Skipping face %s: occluded (p=%.2f)", face.FaceID, det.Occlusion.Probability)
+		return face, metadata, false
+	}
+
+	return face, metadata, true
+}
+
+// selectLeastOccludedDetection returns the non-occluded detection with the lowest
+// occlusion probability that passes the processing quality gates.
+// Ties are broken by composite quality.
+func (s *Service) selectLeastOccludedDetection(face vision.VisionFace) (vision.VisionDetection, bool) {
+	var best vision.VisionDetection
+	bestOcclusion := 2.0
+	bestQuality := -1.0
+	found := false
+
+	for _, det := range face.Detections {
+		occlusion := 0.0
+		if det.Occlusion != nil {
+			if det.Occlusion.Occluded {
+				continue
+			}
+			occlusion = det.Occlusion.Probability
+		}
+
+		qr := s.assessFaceQuality(det.Quality, s.config.MinProcessingQualityScore)
+		if !qr.Acceptable {
+			continue
+		}
+
+		if occlusion < bestOcclusion || (occlusion == bestOcclusion && qr.Composite > bestQuality) {
+			best = det
+			bestOcclusion = occlusion
+			bestQuality = qr.Composite
+			found = true
+		}
+	}
+
+	return best, found
+}
+
 // ============================================================================
 // Embedding-Based Recognition
 // ============================================================================
@@ -601,13 +892,12 @@ func (s *Service) assessFaceQuality(quality *vision.QualityResult, minComposite
 // recognizeByEmbedding attempts to match a face using its pre-computed embedding.
 // Returns performer ID and similarity if matched, empty string if no match.
 func (s *Service) recognizeByEmbedding(embedding []float64) (graphql.ID, float64, error) {
-	resp, err := s.comprefaceClient.RecognizeEmbedding(embedding, 1)
+	best, err := s.bestEmbeddingMatch(embedding)
 	if err != nil {
 		return "", 0, err
 	}
 
-	if len(resp.Result) > 0 && len(resp.Result[0].Similarities) > 0 {
-		best := resp.Result[0].Similarities[0]
+	if best.Subject != "" {
 		log.Debugf("Embedding recognition best match: subject=%s, similarity=%.2f", best.Subject, best.Similarity)
 		if best.Similarity >= s.config.MinSimilarity {
 			// Find performer by subject name
@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Completion Webhook
+// ============================================================================
+//
+// Batch tasks often run unattended overnight. With webhookUrl set, the result
+// of every batch task, successful or failed, is posted to the URL as JSON.
+// The payload is the task result plus a "content" line, which Discord
+// displays as the message; ntfy and generic receivers get the same JSON as
+// the body. A failed post is logged and never fails the task.
+//
+// ============================================================================
+
+// webhookTimeout bounds the webhook post, so an unreachable receiver cannot
+// hold up the end of a task
+const webhookTimeout = 10 * time.Second
+
+// webhookPayload is the JSON posted to the webhook
+type webhookPayload struct {
+	Content string `json:"content"` // One-line summary, Discord's message text
+	TaskResult
+}
+
+// notifyWebhook posts the result of a finished task to the configured webhook
+func (s *Service) notifyWebhook(result TaskResult) {
+	if s.config.WebhookURL == "" {
+		return
+	}
+
+	payload := webhookPayload{Content: webhookContent(result), TaskResult: result}
+	payload.Result = nil // Mode-specific responses can be large; batch modes have none worth sending
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Warnf("Failed to encode webhook payload: %v", err)
+		return
+	}
+
+	// Not tied to the task's context: stopped tasks are reported too
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(s.config.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Warnf("Failed to post task result to webhook: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Warnf("Webhook rejected task result: status %d", resp.StatusCode)
+		return
+	}
+	log.Debugf("Posted %s result to webhook", result.Mode)
+}
+
+// webhookContent summarizes a task result in one line
+func webhookContent(result TaskResult) string {
+	duration := (time.Duration(result.DurationMs) * time.Millisecond).Round(time.Second)
+	if !result.Success {
+		return fmt.Sprintf("Compreface %s failed after %s: %s", result.Mode, duration, result.Error)
+	}
+	return fmt.Sprintf("Compreface %s finished in %s: %s", result.Mode, duration, result.Message)
+}
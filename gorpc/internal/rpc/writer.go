@@ -0,0 +1,118 @@
+package rpc
+
+import (
+	"sync"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/throttle"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Asynchronous Stash Writer (Service Layer)
+// ============================================================================
+
+// stashWriter applies queued Stash mutations on a background goroutine so
+// batch tasks can run detection for the next item while the previous item's
+// tags and performers are written. Writes are applied in queue order.
+type stashWriter struct {
+	jobs    chan writeJob
+	pending sync.WaitGroup
+	done    chan struct{}
+	limiter *throttle.RateLimiter
+}
+
+// writeJob is a single queued Stash mutation
+type writeJob struct {
+	desc    string
+	traceID string // Trace of the item that queued the write
+	fn      func() error
+}
+
+// newStashWriter starts a writer that buffers up to size queued writes,
+// applying them no faster than limiter allows
+func newStashWriter(size int, limiter *throttle.RateLimiter) *stashWriter {
+	if size < 1 {
+		size = 1
+	}
+
+	w := &stashWriter{
+		jobs:    make(chan writeJob, size),
+		done:    make(chan struct{}),
+		limiter: limiter,
+	}
+
+	go func() {
+		defer close(w.done)
+		for job := range w.jobs {
+			w.limiter.Wait()
+			span := trace.StartSpanIn(job.traceID, trace.StageMutate)
+			span.SetAttribute("write", job.desc)
+			err := job.fn()
+			span.End(err)
+			if err != nil {
+				log.With(job.traceID).Warnf("Stash write failed (%s): %v", job.desc, err)
+			}
+			w.pending.Done()
+		}
+	}()
+
+	return w
+}
+
+// enqueue queues a write, blocking while the buffer is full
+func (w *stashWriter) enqueue(desc string, fn func() error) {
+	w.pending.Add(1)
+	w.jobs <- writeJob{desc: desc, traceID: trace.Current(), fn: fn}
+}
+
+// flush waits until every queued write has been applied
+func (w *stashWriter) flush() {
+	w.pending.Wait()
+}
+
+// close applies remaining writes and stops the writer
+func (w *stashWriter) close() {
+	close(w.jobs)
+	<-w.done
+}
+
+// startWriter starts the asynchronous Stash writer for a batch task.
+// The returned function applies pending writes and stops the writer.
+// If a writer is already running (e.g. within fullPipeline), it is reused.
+func (s *Service) startWriter() func() {
+	if s.writer != nil {
+		return s.flushWrites
+	}
+
+	s.writer = newStashWriter(s.config.MaxBatchSize, s.stashWriteLimiter)
+	return func() {
+		s.writer.close()
+		s.writer = nil
+	}
+}
+
+// writeAsync queues a Stash mutation on the active writer, or applies it
+// immediately when no writer is running (single-item tasks)
+func (s *Service) writeAsync(desc string, fn func() error) {
+	if s.writer == nil {
+		s.stashWriteLimiter.Wait()
+		span := trace.StartSpan(trace.StageMutate)
+		span.SetAttribute("write", desc)
+		err := fn()
+		span.End(err)
+		if err != nil {
+			log.Warnf("Stash write failed (%s): %v", desc, err)
+		}
+		return
+	}
+	s.writer.enqueue(desc, fn)
+}
+
+// flushWrites waits for queued Stash mutations to be applied. Call before
+// querying Stash for results that depend on earlier writes.
+func (s *Service) flushWrites() {
+	if s.writer != nil {
+		s.writer.flush()
+	}
+}
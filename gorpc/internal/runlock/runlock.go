@@ -0,0 +1,100 @@
+package runlock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Single-Flight Task Lock
+// ============================================================================
+//
+// Stash starts a separate plugin process for every task, so two overlapping
+// runs of the same batch mode would process the same items and create
+// duplicate subjects. A run holds a lease on a lock file that it refreshes
+// while running; a second run finds a fresh lease and aborts. A lease that
+// stops being refreshed (crashed or killed process) goes stale and is taken
+// over.
+//
+// ============================================================================
+
+const (
+	// RefreshInterval is how often a held lease is renewed
+	RefreshInterval = 30 * time.Second
+	// StaleAfter is how long an unrenewed lease is honored
+	StaleAfter = 2 * time.Minute
+)
+
+// ErrLocked is returned by Acquire when another run holds a fresh lease
+var ErrLocked = errors.New("already running")
+
+// Lock is a held lease on a named lock file
+type Lock struct {
+	path string
+	stop chan struct{}
+	once sync.Once
+}
+
+// Acquire takes the lease named name in dir, renewing it until Release.
+// Returns an error wrapping ErrLocked, with the holder's details, when another
+// run holds a fresh lease.
+func Acquire(dir string, name string) (*Lock, error) {
+	path := filepath.Join(dir, name+".lock")
+
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			fmt.Fprintf(file, "pid %d, started %s", os.Getpid(), time.Now().Format(time.RFC3339))
+			file.Close()
+
+			lock := &Lock{path: path, stop: make(chan struct{})}
+			go lock.refresh()
+			return lock, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			continue // Released between open and stat
+		}
+		if time.Since(info.ModTime()) < StaleAfter {
+			holder, _ := os.ReadFile(path)
+			return nil, fmt.Errorf("%s %w (%s)", name, ErrLocked, strings.TrimSpace(string(holder)))
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove stale lock file: %w", err)
+		}
+	}
+	return nil, fmt.Errorf("%s %w (lock file %s is contended)", name, ErrLocked, path)
+}
+
+// refresh renews the lease until Release
+func (l *Lock) refresh() {
+	ticker := time.NewTicker(RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			os.Chtimes(l.path, now, now)
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// Release gives up the lease. Safe to call more than once.
+func (l *Lock) Release() {
+	l.once.Do(func() {
+		close(l.stop)
+		os.Remove(l.path)
+	})
+}
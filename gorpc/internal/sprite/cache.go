@@ -0,0 +1,35 @@
+package sprite
+
+// boundedCache is a small FIFO cache that evicts the oldest entry once full.
+// Not safe for concurrent use; callers hold their own lock.
+type boundedCache[T any] struct {
+	max     int
+	order   []string
+	entries map[string]T
+}
+
+// newBoundedCache creates a cache holding up to max entries
+func newBoundedCache[T any](max int) *boundedCache[T] {
+	return &boundedCache[T]{
+		max:     max,
+		entries: make(map[string]T),
+	}
+}
+
+// get returns the cached value for key
+func (c *boundedCache[T]) get(key string) (T, bool) {
+	v, ok := c.entries[key]
+	return v, ok
+}
+
+// put stores a value, evicting the oldest entry when full
+func (c *boundedCache[T]) put(key string, value T) {
+	if _, ok := c.entries[key]; !ok {
+		if len(c.order) >= c.max {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = value
+}
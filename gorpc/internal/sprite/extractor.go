@@ -0,0 +1,200 @@
+package sprite
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png" // Register PNG decoder
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp" // Register WEBP decoder
+
+	"github.com/smegmarip/stash-compreface-plugin/pkg/utils"
+)
+
+// ============================================================================
+// Sprite Thumbnail Extraction
+// ============================================================================
+
+const (
+	maxCachedVTTs   = 16 // Parsed VTT files kept per extractor
+	maxCachedImages = 4  // Decoded sprite images kept per extractor (can be large)
+)
+
+// Extractor fetches sprite VTT files and images from HTTP(S) URLs or local
+// files and crops thumbnails for timestamps. Parsed VTTs and decoded sprite
+// images are cached, so consecutive faces from the same scene reuse them.
+// Safe for concurrent use.
+type Extractor struct {
+	httpClient *http.Client
+
+	mu     sync.Mutex
+	vtts   *boundedCache[[]Cue]
+	images *boundedCache[image.Image]
+}
+
+// NewExtractor creates a sprite extractor using httpClient for HTTP sources
+// (a client with a 60 second timeout if nil)
+func NewExtractor(httpClient *http.Client) *Extractor {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 60 * time.Second}
+	}
+	return &Extractor{
+		httpClient: httpClient,
+		vtts:       newBoundedCache[[]Cue](maxCachedVTTs),
+		images:     newBoundedCache[image.Image](maxCachedImages),
+	}
+}
+
+// Extract returns the JPEG thumbnail covering timestamp. spriteURL is used for
+// single-image sprites; when the VTT references multiple sprite images, each
+// cue's image is resolved relative to vttURL.
+func (e *Extractor) Extract(spriteURL, vttURL string, timestamp float64) ([]byte, error) {
+	cues, err := e.cues(vttURL)
+	if err != nil {
+		return nil, err
+	}
+
+	cue, err := FindCue(cues, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find cue: %w", err)
+	}
+
+	source := spriteURL
+	if (source == "" || multipleImages(cues)) && cue.Image != "" {
+		source, err = ResolveSource(vttURL, cue.Image)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if source == "" {
+		return nil, fmt.Errorf("no sprite image for cue at %.2fs", timestamp)
+	}
+
+	spriteImg, err := e.image(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return CropThumbnail(spriteImg, *cue)
+}
+
+// cues returns the parsed cues for a VTT source, using the cache
+func (e *Extractor) cues(vttURL string) ([]Cue, error) {
+	e.mu.Lock()
+	cached, ok := e.vtts.get(vttURL)
+	e.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	data, err := e.fetch(vttURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch VTT: %w", err)
+	}
+
+	cues, err := ParseVTT(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse VTT: %w", err)
+	}
+
+	e.mu.Lock()
+	e.vtts.put(vttURL, cues)
+	e.mu.Unlock()
+	return cues, nil
+}
+
+// image returns the decoded sprite image for a source, using the cache
+func (e *Extractor) image(source string) (image.Image, error) {
+	e.mu.Lock()
+	cached, ok := e.images.get(source)
+	e.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	data, err := e.fetch(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sprite image: %w", err)
+	}
+
+	img, _, err := utils.DecodeImageSRGB(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sprite image: %w", err)
+	}
+
+	e.mu.Lock()
+	e.images.put(source, img)
+	e.mu.Unlock()
+	return img, nil
+}
+
+// fetch reads a source from an HTTP(S) URL, a file:// URL or a local path
+func (e *Extractor) fetch(source string) ([]byte, error) {
+	u, err := url.Parse(source)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		resp, err := e.httpClient.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("status %d", resp.StatusCode)
+		}
+		return io.ReadAll(resp.Body)
+	}
+
+	if err == nil && u.Scheme == "file" {
+		source = u.Path
+	}
+	return os.ReadFile(source)
+}
+
+// ResolveSource resolves a sprite image reference from a VTT cue against the
+// VTT's own location (URL or local path). Absolute references are returned as-is.
+func ResolveSource(vttSource, ref string) (string, error) {
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid sprite reference %q: %w", ref, err)
+	}
+	if refURL.IsAbs() || filepath.IsAbs(ref) {
+		return ref, nil
+	}
+
+	base, err := url.Parse(vttSource)
+	if err == nil && (base.Scheme == "http" || base.Scheme == "https") {
+		return base.ResolveReference(refURL).String(), nil
+	}
+	if err == nil && base.Scheme == "file" {
+		vttSource = base.Path
+	}
+
+	return filepath.Join(filepath.Dir(vttSource), filepath.FromSlash(ref)), nil
+}
+
+// CropThumbnail crops a cue's region from a sprite image and encodes it as JPEG.
+// Regions extending beyond the sprite are clipped to its bounds.
+func CropThumbnail(spriteImg image.Image, cue Cue) ([]byte, error) {
+	rect := image.Rect(cue.X, cue.Y, cue.X+cue.Width, cue.Y+cue.Height).Intersect(spriteImg.Bounds())
+	if rect.Empty() {
+		return nil, fmt.Errorf("cue region %dx%d+%d+%d is outside the sprite image", cue.Width, cue.Height, cue.X, cue.Y)
+	}
+
+	thumbnail := imaging.Crop(spriteImg, rect)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: 95}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
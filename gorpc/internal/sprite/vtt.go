@@ -0,0 +1,187 @@
+package sprite
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// WebVTT Sprite Parsing
+// ============================================================================
+
+// Cue is a single sprite thumbnail cue from a WebVTT file
+type Cue struct {
+	StartTime float64
+	EndTime   float64
+	Image     string // Sprite image reference from the cue payload (may be empty or relative)
+	X         int
+	Y         int
+	Width     int
+	Height    int
+}
+
+var (
+	// timingRegex matches a cue timing line: [HH:]MM:SS.mmm --> [HH:]MM:SS.mmm [settings]
+	timingRegex = regexp.MustCompile(`^((?:\d+:)?\d{1,2}:\d{2}(?:\.\d+)?)\s+-->\s+((?:\d+:)?\d{1,2}:\d{2}(?:\.\d+)?)`)
+	// xywhRegex matches a media fragment: #xywh=x,y,w,h (optionally prefixed with "pixel:")
+	xywhRegex = regexp.MustCompile(`#xywh=(?:pixel:)?(\d+),(\d+),(\d+),(\d+)`)
+)
+
+// ParseVTT parses a WebVTT sprite file. Cues are blocks of an optional
+// identifier line, a timing line and a payload line of the form
+// "sprite.jpg#xywh=x,y,w,h". NOTE/STYLE/REGION blocks and cues without an
+// xywh fragment are ignored.
+func ParseVTT(content string) ([]Cue, error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	scanner := bufio.NewScanner(strings.NewReader(content))
+
+	var cues []Cue
+	var block []string
+	lineNum := 0
+	headerSeen := false
+
+	flush := func() error {
+		defer func() { block = block[:0] }()
+		if len(block) == 0 {
+			return nil
+		}
+
+		// Skip comment and metadata blocks
+		first := block[0]
+		if strings.HasPrefix(first, "NOTE") || first == "STYLE" || first == "REGION" {
+			return nil
+		}
+
+		// Timing line is the first or second line (after an optional identifier)
+		timingIdx := -1
+		for i := 0; i < len(block) && i < 2; i++ {
+			if strings.Contains(block[i], "-->") {
+				timingIdx = i
+				break
+			}
+		}
+		if timingIdx < 0 {
+			return nil
+		}
+
+		match := timingRegex.FindStringSubmatch(block[timingIdx])
+		if match == nil {
+			return fmt.Errorf("invalid cue timing near line %d: %q", lineNum, block[timingIdx])
+		}
+		start, err := parseTimestamp(match[1])
+		if err != nil {
+			return err
+		}
+		end, err := parseTimestamp(match[2])
+		if err != nil {
+			return err
+		}
+
+		for _, payload := range block[timingIdx+1:] {
+			cue, ok := parsePayload(payload)
+			if !ok {
+				continue
+			}
+			cue.StartTime = start
+			cue.EndTime = end
+			cues = append(cues, cue)
+			break
+		}
+		return nil
+	}
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+
+		if !headerSeen {
+			if line == "" {
+				continue
+			}
+			if !strings.HasPrefix(line, "WEBVTT") {
+				return nil, fmt.Errorf("missing WEBVTT header")
+			}
+			headerSeen = true
+			continue
+		}
+
+		if line == "" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		block = append(block, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning VTT: %w", err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return cues, nil
+}
+
+// parsePayload parses a cue payload "image#xywh=x,y,w,h"
+func parsePayload(payload string) (Cue, bool) {
+	loc := xywhRegex.FindStringSubmatchIndex(payload)
+	if loc == nil {
+		return Cue{}, false
+	}
+
+	values := make([]int, 4)
+	for i := range values {
+		values[i], _ = strconv.Atoi(payload[loc[2+i*2]:loc[3+i*2]])
+	}
+
+	return Cue{
+		Image:  strings.TrimSpace(payload[:loc[0]]),
+		X:      values[0],
+		Y:      values[1],
+		Width:  values[2],
+		Height: values[3],
+	}, true
+}
+
+// parseTimestamp parses [HH:]MM:SS[.mmm] into seconds
+func parseTimestamp(ts string) (float64, error) {
+	parts := strings.Split(ts, ":")
+	seconds := 0.0
+	for _, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q: %w", ts, err)
+		}
+		seconds = seconds*60 + v
+	}
+	return seconds, nil
+}
+
+// FindCue finds the cue covering timestamp. A timestamp at the very end of
+// the last cue resolves to that cue.
+func FindCue(cues []Cue, timestamp float64) (*Cue, error) {
+	for i := range cues {
+		if timestamp >= cues[i].StartTime && timestamp < cues[i].EndTime {
+			return &cues[i], nil
+		}
+	}
+	if n := len(cues); n > 0 && timestamp == cues[n-1].EndTime {
+		return &cues[n-1], nil
+	}
+	return nil, fmt.Errorf("no cue found for timestamp %.2f", timestamp)
+}
+
+// multipleImages reports whether cues reference more than one sprite image
+func multipleImages(cues []Cue) bool {
+	for i := 1; i < len(cues); i++ {
+		if cues[i].Image != cues[0].Image {
+			return true
+		}
+	}
+	return false
+}
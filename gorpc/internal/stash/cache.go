@@ -8,8 +8,10 @@ import (
 
 // TagCache provides thread-safe cached tag lookups by name
 type TagCache struct {
-	tags map[string]graphql.ID
-	mu   sync.RWMutex
+	tags   map[string]graphql.ID
+	parent string              // Parent tag of the plugin's tags, empty for top-level tags
+	nested map[graphql.ID]bool // Tags already verified to be children of parent
+	mu     sync.RWMutex
 }
 
 // NewTagCache creates a new tag cache
@@ -19,6 +21,38 @@ func NewTagCache() *TagCache {
 	}
 }
 
+// SetParentTag nests the tags created or found by GetOrCreateTag under the
+// named tag; an empty name keeps them at the top level
+func (tc *TagCache) SetParentTag(name string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.parent = name
+}
+
+// ParentTag returns the name of the parent tag of the plugin's tags
+func (tc *TagCache) ParentTag() string {
+	tc.mu.RLock()
+	defer tc.mu.RUnlock()
+	return tc.parent
+}
+
+// isNested reports whether a tag is known to be a child of the parent tag
+func (tc *TagCache) isNested(id graphql.ID) bool {
+	tc.mu.RLock()
+	defer tc.mu.RUnlock()
+	return tc.nested[id]
+}
+
+// setNested records that a tag is a child of the parent tag
+func (tc *TagCache) setNested(id graphql.ID) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.nested == nil {
+		tc.nested = make(map[graphql.ID]bool)
+	}
+	tc.nested[id] = true
+}
+
 // Get retrieves a cached tag ID by name
 func (tc *TagCache) Get(name string) (graphql.ID, bool) {
 	tc.mu.RLock()
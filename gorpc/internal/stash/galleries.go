@@ -3,9 +3,11 @@ package stash
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	graphql "github.com/hasura/go-graphql-client"
-	"github.com/stashapp/stash/pkg/plugin/common/log"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
 )
 
 // FindGalleries queries galleries with pagination
@@ -46,6 +48,29 @@ func FindGalleries(client *graphql.Client, filter *GalleryFilterType, page, perP
 	return query.FindGalleries.Galleries, query.FindGalleries.Count, nil
 }
 
+// FindAllGalleries iterates over every gallery matching filter, fetching
+// perPage galleries at a time (DefaultPageSize if perPage <= 0) and passing
+// each page to fn along with the total count. Return ErrStopPaging from fn to
+// stop early.
+//
+// fn must not change whether already-visited galleries match filter; collect
+// IDs first and mutate after iteration completes.
+func FindAllGalleries(client *graphql.Client, filter *GalleryFilterType, perPage int, fn func(galleries []Gallery, count int) error) error {
+	return paginate(perPage, func(page, perPage int) (int, int, error) {
+		galleries, count, err := FindGalleries(client, filter, page, perPage)
+		if err != nil {
+			return 0, 0, err
+		}
+		if len(galleries) == 0 {
+			return 0, count, nil
+		}
+		if err := fn(galleries, count); err != nil {
+			return len(galleries), count, err
+		}
+		return len(galleries), count, nil
+	})
+}
+
 // GetGallery retrieves a single gallery by ID
 func GetGallery(client *graphql.Client, galleryID graphql.ID) (*Gallery, error) {
 	ctx := context.Background()
@@ -92,24 +117,66 @@ func UpdateGallery(client *graphql.Client, galleryID graphql.ID, input GalleryUp
 	return nil
 }
 
-// AddTagToGallery adds a tag to a gallery
-func AddTagToGallery(client *graphql.Client, galleryID graphql.ID, tagID graphql.ID) error {
-	ctx := context.Background()
+// UpdateGalleryTitle sets a gallery's title
+func UpdateGalleryTitle(client *graphql.Client, galleryID graphql.ID, title string) error {
+	input := GalleryUpdateInput{
+		ID:    string(galleryID),
+		Title: &title,
+	}
 
-	var mutation struct {
-		GalleryUpdate GalleryUpdate `graphql:"galleryUpdate(input: $input)"`
+	if err := UpdateGallery(client, galleryID, input); err != nil {
+		return fmt.Errorf("failed to update gallery title: %w", err)
 	}
 
+	log.Debugf("Updated title for gallery %s", galleryID)
+	return nil
+}
+
+// UpdateGalleryDetails sets a gallery's details
+func UpdateGalleryDetails(client *graphql.Client, galleryID graphql.ID, details string) error {
 	input := GalleryUpdateInput{
-		ID:     string(galleryID),
-		TagIds: []string{string(tagID)},
+		ID:      string(galleryID),
+		Details: &details,
 	}
 
-	variables := map[string]interface{}{
-		"input": input,
+	if err := UpdateGallery(client, galleryID, input); err != nil {
+		return fmt.Errorf("failed to update gallery details: %w", err)
 	}
 
-	err := client.Mutate(ctx, &mutation, variables)
+	log.Debugf("Updated details for gallery %s", galleryID)
+	return nil
+}
+
+// ReplaceDetailsSection replaces the section of details delimited by
+// "[marker]" and "[/marker]" lines with section, appending it when details has
+// none. Text outside the section is preserved.
+func ReplaceDetailsSection(details string, marker string, section string) string {
+	openTag, closeTag := "["+marker+"]", "[/"+marker+"]"
+	block := openTag + "\n" + strings.TrimSpace(section) + "\n" + closeTag
+
+	start := strings.Index(details, openTag)
+	if start >= 0 {
+		if end := strings.Index(details[start:], closeTag); end >= 0 {
+			end += start + len(closeTag)
+			return details[:start] + block + details[end:]
+		}
+	}
+
+	details = strings.TrimRight(details, "\n ")
+	if details == "" {
+		return block
+	}
+	return details + "\n\n" + block
+}
+
+// AddTagToGallery adds a tag to a gallery (preserving existing tags)
+func AddTagToGallery(client *graphql.Client, galleryID graphql.ID, tagID graphql.ID) error {
+	gallery, err := GetGallery(client, galleryID)
+	if err != nil {
+		return fmt.Errorf("failed to get gallery: %w", err)
+	}
+
+	err = UpdateGalleryTagsIfChanged(client, gallery, []graphql.ID{tagID}, nil)
 	if err != nil {
 		return fmt.Errorf("failed to add tag to gallery: %w", err)
 	}
@@ -118,6 +185,24 @@ func AddTagToGallery(client *graphql.Client, galleryID graphql.ID, tagID graphql
 	return nil
 }
 
+// UpdateGalleryTagsIfChanged adds and removes tags using the tags already
+// loaded on gallery, skipping the mutation when nothing would change.
+// gallery.Tags is updated in place so repeated calls stay in sync.
+func UpdateGalleryTagsIfChanged(client *graphql.Client, gallery *Gallery, add []graphql.ID, remove []graphql.ID) error {
+	tagIDs, changed := MergeTagIDs(gallery.Tags, add, remove)
+	if !changed {
+		log.Tracef("Gallery %s tags unchanged, skipping update", gallery.ID)
+		return nil
+	}
+
+	if err := UpdateGalleryTags(client, gallery.ID, tagIDs); err != nil {
+		return err
+	}
+
+	gallery.Tags = tagsFromIDs(gallery.Tags, tagIDs)
+	return nil
+}
+
 // UpdateGalleryTags updates gallery tags (replaces all tags)
 func UpdateGalleryTags(client *graphql.Client, galleryID graphql.ID, tagIDs []graphql.ID) error {
 	galleryIDStr := string(galleryID)
@@ -148,15 +233,7 @@ func RemoveTagFromGallery(client *graphql.Client, galleryID graphql.ID, tagID gr
 		return fmt.Errorf("failed to get gallery: %w", err)
 	}
 
-	// Filter out the tag to remove
-	tagIDs := []graphql.ID{}
-	for _, tag := range gallery.Tags {
-		if tag.ID != tagID {
-			tagIDs = append(tagIDs, tag.ID)
-		}
-	}
-
-	err = UpdateGalleryTags(client, galleryID, tagIDs)
+	err = UpdateGalleryTagsIfChanged(client, gallery, nil, []graphql.ID{tagID})
 	if err != nil {
 		return fmt.Errorf("failed to remove tag from gallery: %w", err)
 	}
@@ -164,3 +241,44 @@ func RemoveTagFromGallery(client *graphql.Client, galleryID graphql.ID, tagID gr
 	log.Tracef("Removed tag %s from gallery %s", tagID, galleryID)
 	return nil
 }
+
+// UpdateGalleryPerformers updates a gallery's performers (replaces all performers)
+func UpdateGalleryPerformers(client *graphql.Client, galleryID graphql.ID, performerIDs []graphql.ID) error {
+	performerIDStrs := make([]string, len(performerIDs))
+	for i, id := range performerIDs {
+		performerIDStrs[i] = string(id)
+	}
+
+	input := GalleryUpdateInput{
+		ID:           string(galleryID),
+		PerformerIds: performerIDStrs,
+	}
+
+	err := UpdateGallery(client, galleryID, input)
+	if err != nil {
+		return fmt.Errorf("failed to update gallery performers: %w", err)
+	}
+
+	log.Infof("Successfully updated performers for gallery %s (%d performers)", galleryID, len(performerIDs))
+	return nil
+}
+
+// AddPerformerToGallery adds a performer to a gallery (preserving existing performers)
+func AddPerformerToGallery(client *graphql.Client, galleryID graphql.ID, performerID graphql.ID) error {
+	gallery, err := GetGallery(client, galleryID)
+	if err != nil {
+		return fmt.Errorf("failed to get gallery: %w", err)
+	}
+
+	performerIDs := make([]graphql.ID, 0, len(gallery.Performers)+1)
+	for _, performer := range gallery.Performers {
+		if performer.ID == performerID {
+			// Performer already present, no update needed
+			return nil
+		}
+		performerIDs = append(performerIDs, performer.ID)
+	}
+	performerIDs = append(performerIDs, performerID)
+
+	return UpdateGalleryPerformers(client, galleryID, performerIDs)
+}
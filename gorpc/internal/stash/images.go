@@ -2,12 +2,15 @@ package stash
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"path/filepath"
 
 	graphql "github.com/hasura/go-graphql-client"
-	"github.com/stashapp/stash/pkg/plugin/common/log"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
 )
 
 // ============================================================================
@@ -16,6 +19,11 @@ import (
 
 // FindImages finds images with optional filtering
 func FindImages(client *graphql.Client, filter *ImageFilterType, page int, perPage int) ([]Image, int, error) {
+	return FindImagesSorted(client, filter, BatchSort{}, page, perPage)
+}
+
+// FindImagesSorted finds images with optional filtering, in the given order
+func FindImagesSorted(client *graphql.Client, filter *ImageFilterType, order BatchSort, page int, perPage int) ([]Image, int, error) {
 	var query struct {
 		FindImages struct {
 			Count  int
@@ -29,6 +37,7 @@ func FindImages(client *graphql.Client, filter *ImageFilterType, page int, perPa
 		Page:    &pageInt,
 		PerPage: &perPageInt,
 	}
+	order.apply(filterInput)
 
 	variables := map[string]interface{}{
 		"filter": filterInput,
@@ -49,6 +58,101 @@ func FindImages(client *graphql.Client, filter *ImageFilterType, page int, perPa
 	return query.FindImages.Images, query.FindImages.Count, nil
 }
 
+// FindImagesAfter queries the first perPage images matching filter with an ID
+// above afterID, in ID order. Batches fetched after the last image processed
+// are unaffected by processed images leaving or staying in filter.
+func FindImagesAfter(client *graphql.Client, filter *ImageFilterType, afterID int, perPage int) ([]Image, int, error) {
+	var after ImageFilterType
+	if filter != nil {
+		after = *filter
+	}
+	after.ID = &IntCriterionInput{Value: afterID, Modifier: CriterionModifierGreaterThan}
+
+	images, count, err := findImagesByID(client, &after, SortDirectionAsc, perPage)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	log.Debugf("FindImagesAfter(%d) returned %d images (remaining: %d)", afterID, len(images), count)
+	return images, count, nil
+}
+
+// FindImagesBefore queries the first perPage images matching filter with an
+// ID below beforeID (any ID when beforeID is 0), newest first; the
+// descending counterpart of FindImagesAfter
+func FindImagesBefore(client *graphql.Client, filter *ImageFilterType, beforeID int, perPage int) ([]Image, int, error) {
+	var before ImageFilterType
+	if filter != nil {
+		before = *filter
+	}
+	if beforeID > 0 {
+		before.ID = &IntCriterionInput{Value: beforeID, Modifier: CriterionModifierLessThan}
+	}
+
+	images, count, err := findImagesByID(client, &before, SortDirectionDesc, perPage)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	log.Debugf("FindImagesBefore(%d) returned %d images (remaining: %d)", beforeID, len(images), count)
+	return images, count, nil
+}
+
+// findImagesByID queries the first perPage images matching filter in ID order
+func findImagesByID(client *graphql.Client, filter *ImageFilterType, direction SortDirectionEnum, perPage int) ([]Image, int, error) {
+	return FindImagesSorted(client, filter, BatchSort{Sort: "id", Direction: direction}, 1, perPage)
+}
+
+// FindAllImages iterates over every image matching filter, fetching perPage
+// images at a time (DefaultPageSize if perPage <= 0) and passing each page to
+// fn along with the total count. Return ErrStopPaging from fn to stop early.
+//
+// fn must not change whether already-visited images match filter (e.g. by
+// removing a filtered tag), as that shifts later pages; collect IDs first and
+// mutate after iteration completes.
+func FindAllImages(client *graphql.Client, filter *ImageFilterType, perPage int, fn func(images []Image, count int) error) error {
+	return paginate(perPage, func(page, perPage int) (int, int, error) {
+		images, count, err := FindImages(client, filter, page, perPage)
+		if err != nil {
+			return 0, 0, err
+		}
+		if len(images) == 0 {
+			return 0, count, nil
+		}
+		if err := fn(images, count); err != nil {
+			return len(images), count, err
+		}
+		return len(images), count, nil
+	})
+}
+
+// FindImagesByFileName finds the images with a file of the given base name
+func FindImagesByFileName(client *graphql.Client, fileName string) ([]Image, error) {
+	filter := &ImageFilterType{
+		Path: &StringCriterionInput{
+			Value:    fileName,
+			Modifier: CriterionModifierIncludes,
+		},
+	}
+
+	var matches []Image
+	err := FindAllImages(client, filter, 0, func(images []Image, count int) error {
+		for _, image := range images {
+			for _, file := range image.Files {
+				if filepath.Base(file.Path) == fileName {
+					matches = append(matches, image)
+					break
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find images named %s: %w", fileName, err)
+	}
+	return matches, nil
+}
+
 // GetImage retrieves a single image by ID
 func GetImage(client *graphql.Client, imageID graphql.ID) (*Image, error) {
 	var query struct {
@@ -96,42 +200,7 @@ func AddTagToImage(client *graphql.Client, imageID graphql.ID, tagID graphql.ID)
 		return fmt.Errorf("failed to get image: %w", err)
 	}
 
-	// Build tag ID list (existing + new)
-	tagIDs := []graphql.ID{}
-	hasTag := false
-	for _, tag := range image.Tags {
-		tagIDs = append(tagIDs, tag.ID)
-		if tag.ID == tagID {
-			hasTag = true
-		}
-	}
-
-	// If already has tag, nothing to do
-	if hasTag {
-		log.Tracef("Image %s already has tag %s", imageID, tagID)
-		return nil
-	}
-
-	tagIDs = append(tagIDs, tagID)
-
-	// Build tag_ids array as JSON
-	tagIDStrs := make([]string, len(tagIDs))
-	for i, id := range tagIDs {
-		tagIDStrs[i] = string(id)
-	}
-
-	input := ImageUpdateInput{
-		ID:     string(imageID),
-		TagIds: tagIDStrs,
-	}
-
-	err = UpdateImage(client, imageID, input)
-	if err != nil {
-		return fmt.Errorf("failed to add tag to image: %w", err)
-	}
-
-	log.Tracef("Added tag %s to image %s", tagID, imageID)
-	return nil
+	return UpdateImageTagsIfChanged(client, image, []graphql.ID{tagID}, nil)
 }
 
 // RemoveTagFromImage removes a tag from an image
@@ -142,28 +211,43 @@ func RemoveTagFromImage(client *graphql.Client, imageID graphql.ID, tagID graphq
 		return fmt.Errorf("failed to get image: %w", err)
 	}
 
-	// Filter out the tag to remove
-	tagIDs := []string{}
-	for _, tag := range image.Tags {
-		if tag.ID != tagID {
-			tagIDs = append(tagIDs, string(tag.ID))
-		}
+	return UpdateImageTagsIfChanged(client, image, nil, []graphql.ID{tagID})
+}
+
+// UpdateImageTagsIfChanged adds and removes tags using the tags already loaded
+// on image, skipping the mutation when nothing would change. image.Tags is
+// updated in place so repeated calls on the same image stay in sync.
+func UpdateImageTagsIfChanged(client *graphql.Client, image *Image, add []graphql.ID, remove []graphql.ID) error {
+	tagIDs, changed := MergeTagIDs(image.Tags, add, remove)
+	if !changed {
+		log.Tracef("Image %s tags unchanged, skipping update", image.ID)
+		return nil
+	}
+
+	tagIDStrs := make([]string, len(tagIDs))
+	for i, id := range tagIDs {
+		tagIDStrs[i] = string(id)
 	}
 
 	input := ImageUpdateInput{
-		ID:     string(imageID),
-		TagIds: tagIDs,
+		ID:     string(image.ID),
+		TagIds: tagIDStrs,
 	}
 
-	err = UpdateImage(client, imageID, input)
-	if err != nil {
-		return fmt.Errorf("failed to remove tag from image: %w", err)
+	if err := UpdateImage(client, image.ID, input); err != nil {
+		return fmt.Errorf("failed to update image tags: %w", err)
 	}
 
-	log.Tracef("Removed tag %s from image %s", tagID, imageID)
+	image.Tags = tagsFromIDs(image.Tags, tagIDs)
+	log.Tracef("Updated tags for image %s (+%v -%v)", image.ID, add, remove)
 	return nil
 }
 
+// ErrServiceUnavailable is matched by DownloadImage errors for servers that
+// could not serve the download: connection failures and 502, 503 and 504
+// responses
+var ErrServiceUnavailable = errors.New("image server unavailable")
+
 // DownloadImage downloads an image from Stash HTTP endpoint
 func DownloadImage(imageURL string, sessionCookie *http.Cookie) ([]byte, error) {
 	req, err := http.NewRequest("GET", imageURL, nil)
@@ -178,11 +262,15 @@ func DownloadImage(imageURL string, sessionCookie *http.Cookie) ([]byte, error)
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download image: %w", err)
+		return nil, fmt.Errorf("failed to download image: %w: %w", ErrServiceUnavailable, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return nil, fmt.Errorf("failed to download image: %w: status %d", ErrServiceUnavailable, resp.StatusCode)
+	default:
 		return nil, fmt.Errorf("failed to download image: status %d", resp.StatusCode)
 	}
 
@@ -193,3 +281,56 @@ func DownloadImage(imageURL string, sessionCookie *http.Cookie) ([]byte, error)
 
 	return imageBytes, nil
 }
+
+// Image orientations
+const (
+	OrientationUnknown   = "unknown"
+	OrientationLandscape = "landscape"
+	OrientationPortrait  = "portrait"
+	OrientationSquare    = "square"
+)
+
+// Dimensions returns the pixel size of the image's primary visual file, as
+// recorded by Stash, without reading the file. Zero when Stash has no size.
+func (img *Image) Dimensions() Dimensions {
+	if len(img.VisualFiles) == 0 {
+		return Dimensions{}
+	}
+	file := img.VisualFiles[0]
+	if file.ImageFile.Width > 0 && file.ImageFile.Height > 0 {
+		return file.ImageFile
+	}
+	return file.VideoFile
+}
+
+// IsClip reports whether Stash stores the image's primary file as a video
+func (img *Image) IsClip() bool {
+	return len(img.VisualFiles) > 0 && img.VisualFiles[0].Typename == "VideoFile"
+}
+
+// PrimaryPath returns the path of the image's primary file, or "" when it
+// has none. Clips stored as video files are missing from Files, so their
+// path comes from VisualFiles.
+func (img *Image) PrimaryPath() string {
+	if len(img.Files) > 0 {
+		return img.Files[0].Path
+	}
+	if len(img.VisualFiles) > 0 {
+		return img.VisualFiles[0].File.Path
+	}
+	return ""
+}
+
+// Orientation classifies the image by its recorded dimensions
+func (img *Image) Orientation() string {
+	dims := img.Dimensions()
+	switch {
+	case dims.Width <= 0 || dims.Height <= 0:
+		return OrientationUnknown
+	case dims.Width > dims.Height:
+		return OrientationLandscape
+	case dims.Width < dims.Height:
+		return OrientationPortrait
+	}
+	return OrientationSquare
+}
@@ -0,0 +1,42 @@
+package stash
+
+import (
+	"errors"
+)
+
+// ============================================================================
+// Pagination Helpers
+// ============================================================================
+
+// DefaultPageSize is the page size used by the FindAll* helpers when none is given
+const DefaultPageSize = 100
+
+// ErrStopPaging can be returned from a FindAll* callback to stop iterating
+// without reporting an error to the caller
+var ErrStopPaging = errors.New("stop paging")
+
+// paginate repeatedly calls fetch with increasing page numbers until every
+// item reported by the total count has been visited, fetch returns an empty
+// page, or fetch returns an error. fetch returns the number of items in the
+// page and the total count reported by Stash.
+func paginate(perPage int, fetch func(page, perPage int) (int, int, error)) error {
+	if perPage <= 0 {
+		perPage = DefaultPageSize
+	}
+
+	seen := 0
+	for page := 1; ; page++ {
+		n, count, err := fetch(page, perPage)
+		if errors.Is(err, ErrStopPaging) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		seen += n
+		if n == 0 || n < perPage || seen >= count {
+			return nil
+		}
+	}
+}
@@ -8,7 +8,8 @@ import (
 
 	graphql "github.com/hasura/go-graphql-client"
 	"github.com/stashapp/stash/pkg/models"
-	"github.com/stashapp/stash/pkg/plugin/common/log"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
 )
 
 // ============================================================================
@@ -76,6 +77,64 @@ func FindPerformers(client *graphql.Client, filter *PerformerFilterType, page in
 	return query.FindPerformers.Performers, query.FindPerformers.Count, nil
 }
 
+// FindAllPerformers iterates over every performer matching filter, fetching
+// perPage performers at a time (DefaultPageSize if perPage <= 0) and passing
+// each page to fn along with the total count. Return ErrStopPaging from fn to
+// stop early.
+func FindAllPerformers(client *graphql.Client, filter *PerformerFilterType, perPage int, fn func(performers []Performer, count int) error) error {
+	return paginate(perPage, func(page, perPage int) (int, int, error) {
+		performers, count, err := FindPerformers(client, filter, page, perPage)
+		if err != nil {
+			return 0, 0, err
+		}
+		if len(performers) == 0 {
+			return 0, count, nil
+		}
+		if err := fn(performers, count); err != nil {
+			return len(performers), count, err
+		}
+		return len(performers), count, nil
+	})
+}
+
+// FindAllPerformersCreatedBefore iterates over every performer created
+// before a time with its usage counts, fetching perPage performers at a time
+// (DefaultPageSize if perPage <= 0). Return ErrStopPaging from fn to stop
+// early.
+func FindAllPerformersCreatedBefore(client *graphql.Client, before time.Time, perPage int, fn func(performers []PerformerUsage, count int) error) error {
+	filter := &PerformerFilterType{
+		CreatedAt: &TimestampCriterionInput{
+			Value:    before.UTC().Format(time.RFC3339),
+			Modifier: CriterionModifierLessThan,
+		},
+	}
+
+	return paginate(perPage, func(page, perPage int) (int, int, error) {
+		var query struct {
+			FindPerformers struct {
+				Count      int
+				Performers []PerformerUsage
+			} `graphql:"findPerformers(performer_filter: $filter, filter: $page_filter)"`
+		}
+		variables := map[string]interface{}{
+			"page_filter": &FindFilterType{Page: &page, PerPage: &perPage},
+			"filter":      filter,
+		}
+		if err := client.Query(context.Background(), &query, variables); err != nil {
+			return 0, 0, fmt.Errorf("failed to query performers: %w", err)
+		}
+
+		performers := query.FindPerformers.Performers
+		if len(performers) == 0 {
+			return 0, query.FindPerformers.Count, nil
+		}
+		if err := fn(performers, query.FindPerformers.Count); err != nil {
+			return len(performers), query.FindPerformers.Count, err
+		}
+		return len(performers), query.FindPerformers.Count, nil
+	})
+}
+
 // CreatePerformer creates a new performer
 func CreatePerformer(client *graphql.Client, performerSubject PerformerSubject) (graphql.ID, error) {
 	return CreatePerformerWithImage(client, performerSubject)
@@ -103,7 +162,7 @@ func CreatePerformerWithImage(client *graphql.Client, performerSubject Performer
 	imageURL := performerSubject.Image
 
 	if len(aliases) > 0 {
-		input.AliasList = aliases
+		input.AliasList = DedupeAliases(aliases)
 	}
 
 	if age > 0 {
@@ -111,6 +170,10 @@ func CreatePerformerWithImage(client *graphql.Client, performerSubject Performer
 		input.Birthdate = &birthDay
 	}
 
+	if details := AgeRangeDetails(performerSubject.AgeLow, performerSubject.AgeHigh); details != "" {
+		input.Details = &details
+	}
+
 	if gender != "" {
 		stashGender, err := ParseGenderEnum(gender)
 		if err == nil {
@@ -145,6 +208,23 @@ func CreatePerformerWithImage(client *graphql.Client, performerSubject Performer
 func UpdatePerformer(client *graphql.Client, performerID graphql.ID, input PerformerUpdateInput) error {
 	ctx := context.Background()
 
+	// Repeated syncs must not grow the alias list
+	if input.AliasList != nil {
+		input.AliasList = DedupeAliases(input.AliasList)
+	}
+
+	// Estimated birthdates never replace one already set
+	if input.Birthdate != nil {
+		performer, err := GetPerformerByID(client, performerID)
+		if err != nil {
+			return fmt.Errorf("failed to get performer: %w", err)
+		}
+		if performer.Birthdate != "" {
+			log.Debugf("Performer %s already has birthdate %s, keeping it", performerID, performer.Birthdate)
+			input.Birthdate = nil
+		}
+	}
+
 	var mutation struct {
 		PerformerUpdate PerformerUpdateInput `graphql:"performerUpdate(input: $input)"`
 	}
@@ -162,6 +242,122 @@ func UpdatePerformer(client *graphql.Client, performerID graphql.ID, input Perfo
 	return nil
 }
 
+// DedupeAliases removes blank and case-insensitively duplicated aliases,
+// keeping the first spelling of each in its original order
+func DedupeAliases(aliases []string) []string {
+	seen := make(map[string]bool, len(aliases))
+	deduped := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		key := strings.ToLower(alias)
+		if alias == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		deduped = append(deduped, alias)
+	}
+	return deduped
+}
+
+// DestroyPerformer deletes a performer
+func DestroyPerformer(client *graphql.Client, performerID graphql.ID) error {
+	var mutation struct {
+		PerformerDestroy bool `graphql:"performerDestroy(input: $input)"`
+	}
+
+	variables := map[string]interface{}{
+		"input": PerformerDestroyInput{ID: performerID},
+	}
+
+	err := client.Mutate(context.Background(), &mutation, variables)
+	if err != nil {
+		return fmt.Errorf("failed to destroy performer: %w", err)
+	}
+
+	log.Debugf("Destroyed performer %s", performerID)
+	return nil
+}
+
+// ReplacePerformerID returns the IDs of performers with from replaced by to,
+// without duplicates
+func ReplacePerformerID(performers []Performer, from graphql.ID, to graphql.ID) []graphql.ID {
+	ids := make([]graphql.ID, 0, len(performers)+1)
+	seen := map[graphql.ID]bool{}
+	for _, performer := range performers {
+		id := performer.ID
+		if id == from {
+			id = to
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// ReassignPerformer moves every image, scene and gallery of performer from to
+// performer to, keeping their other performers. Returns the number of items
+// updated.
+func ReassignPerformer(client *graphql.Client, from graphql.ID, to graphql.ID) (int, error) {
+	performed := &MultiCriterionInput{Value: []string{string(from)}, Modifier: CriterionModifierIncludes}
+
+	// Collect first: each update removes the item from the filter's results
+	var images []Image
+	err := FindAllImages(client, &ImageFilterType{Performers: performed}, DefaultPageSize, func(page []Image, count int) error {
+		images = append(images, page...)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query images: %w", err)
+	}
+	var scenes []Scene
+	err = FindAllScenes(client, &SceneFilterType{Performers: performed}, DefaultPageSize, func(page []Scene, count int) error {
+		scenes = append(scenes, page...)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query scenes: %w", err)
+	}
+	var galleries []Gallery
+	err = FindAllGalleries(client, &GalleryFilterType{Performers: performed}, DefaultPageSize, func(page []Gallery, count int) error {
+		galleries = append(galleries, page...)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query galleries: %w", err)
+	}
+
+	updated := 0
+	for _, image := range images {
+		ids := ReplacePerformerID(image.Performers, from, to)
+		performerIDs := make([]string, len(ids))
+		for i, id := range ids {
+			performerIDs[i] = string(id)
+		}
+		input := ImageUpdateInput{ID: string(image.ID), PerformerIds: performerIDs}
+		if err := UpdateImage(client, image.ID, input); err != nil {
+			return updated, fmt.Errorf("failed to update image %s: %w", image.ID, err)
+		}
+		updated++
+	}
+	for _, scene := range scenes {
+		if err := UpdateScenePerformers(client, scene.ID, ReplacePerformerID(scene.Performers, from, to)); err != nil {
+			return updated, fmt.Errorf("failed to update scene %s: %w", scene.ID, err)
+		}
+		updated++
+	}
+	for _, gallery := range galleries {
+		if err := UpdateGalleryPerformers(client, gallery.ID, ReplacePerformerID(gallery.Performers, from, to)); err != nil {
+			return updated, fmt.Errorf("failed to update gallery %s: %w", gallery.ID, err)
+		}
+		updated++
+	}
+
+	log.Debugf("Reassigned %d items from performer %s to %s", updated, from, to)
+	return updated, nil
+}
+
 // AddTagToPerformer adds a tag to a performer
 func AddTagToPerformer(client *graphql.Client, performerID graphql.ID, tagID graphql.ID) error {
 	performer, err := GetPerformerByID(client, performerID)
@@ -234,7 +430,85 @@ func FindPerformerBySubjectName(client *graphql.Client, subjectName string) (gra
 		return aliased.ID, nil
 	}
 
-	return "", nil // Not found (not an error)
+	// Hand-edited names and aliases may differ in case or spacing
+	return findPerformerByLooseSubjectName(client, subjectName)
+}
+
+// looseMatchCandidates caps the performers compared per lookup in
+// FindPerformersByName
+const looseMatchCandidates = 25
+
+// findPerformerByLooseSubjectName finds the one performer whose name or alias
+// equals subjectName ignoring case and surrounding whitespace. Returns "" when
+// none or several match, so a subject is never linked to the wrong performer.
+func findPerformerByLooseSubjectName(client *graphql.Client, subjectName string) (graphql.ID, error) {
+	matched, err := FindPerformersByName(client, subjectName)
+	if err != nil {
+		return "", err
+	}
+
+	switch len(matched) {
+	case 0:
+		return "", nil // Not found (not an error)
+	case 1:
+		name, _ := MatchSubjectName(matched[0], subjectName)
+		log.Warnf("Subject '%s' linked to performer %s (%s) only by ignoring case and spacing of %q; restore the exact alias to keep the link reliable",
+			subjectName, matched[0].Name, matched[0].ID, name)
+		return matched[0].ID, nil
+	default:
+		ids := make([]string, len(matched))
+		for i, performer := range matched {
+			ids[i] = string(performer.ID)
+		}
+		log.Warnf("Subject '%s' loosely matches %d performers (%s); leaving it unlinked", subjectName, len(matched), strings.Join(ids, ", "))
+		return "", nil
+	}
+}
+
+// FindPerformersByName returns the performers whose name or an alias equals
+// name ignoring case and surrounding whitespace
+func FindPerformersByName(client *graphql.Client, name string) ([]Performer, error) {
+	key := strings.TrimSpace(name)
+	if key == "" {
+		return nil, nil
+	}
+
+	var matched []Performer
+	seen := map[graphql.ID]bool{}
+	for _, filter := range []PerformerFilterType{
+		{Name: &StringCriterionInput{Value: key, Modifier: CriterionModifierIncludes}},
+		{Aliases: &StringCriterionInput{Value: key, Modifier: CriterionModifierIncludes}},
+	} {
+		candidates, _, err := FindPerformers(client, &filter, 1, looseMatchCandidates)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query performer: %w", err)
+		}
+		for _, performer := range candidates {
+			if seen[performer.ID] {
+				continue
+			}
+			seen[performer.ID] = true
+			if _, ok := MatchSubjectName(performer, name); ok {
+				matched = append(matched, performer)
+			}
+		}
+	}
+	return matched, nil
+}
+
+// MatchSubjectName returns the performer's name or alias that equals
+// subjectName ignoring case and surrounding whitespace
+func MatchSubjectName(performer Performer, subjectName string) (string, bool) {
+	key := strings.TrimSpace(subjectName)
+	if key == "" {
+		return "", false
+	}
+	for _, name := range append([]string{performer.Name}, performer.AliasList...) {
+		if strings.EqualFold(strings.TrimSpace(name), key) {
+			return name, true
+		}
+	}
+	return "", false
 }
 
 // Converts a string to GenderEnum
@@ -266,6 +540,21 @@ func CalculateBirthdayFromAge(age int) string {
 	return birthDate.Format("2006-01-02")
 }
 
+// AgeRangeDetails describes an estimated age range for a performer's details,
+// e.g. "Estimated age: 25-32 (Compreface)". Returns "" when unknown.
+func AgeRangeDetails(low int, high int) string {
+	if low <= 0 && high <= 0 {
+		return ""
+	}
+	if low <= 0 || low == high {
+		return fmt.Sprintf("Estimated age: %d (Compreface)", max(low, high))
+	}
+	if high < low {
+		low, high = high, low
+	}
+	return fmt.Sprintf("Estimated age: %d-%d (Compreface)", low, high)
+}
+
 // CaclulateAgeFromBirthday calculates age in years from a birthdate string (YYYY-MM-DD)
 func CaclulateAgeFromBirthday(birthdate string) (int, error) {
 	if birthdate == "" {
@@ -5,11 +5,17 @@ import (
 	"fmt"
 
 	graphql "github.com/hasura/go-graphql-client"
-	"github.com/stashapp/stash/pkg/plugin/common/log"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
 )
 
 // FindScenes queries scenes with pagination
 func FindScenes(client *graphql.Client, filter *SceneFilterType, page, perPage int) ([]Scene, int, error) {
+	return FindScenesSorted(client, filter, BatchSort{}, page, perPage)
+}
+
+// FindScenesSorted queries scenes with pagination, in the given order
+func FindScenesSorted(client *graphql.Client, filter *SceneFilterType, order BatchSort, page, perPage int) ([]Scene, int, error) {
 	ctx := context.Background()
 
 	var query struct {
@@ -25,6 +31,7 @@ func FindScenes(client *graphql.Client, filter *SceneFilterType, page, perPage i
 		Page:    &pageInt,
 		PerPage: &perPageInt,
 	}
+	order.apply(filterInput)
 
 	variables := map[string]interface{}{
 		"filter":       filterInput,
@@ -41,6 +48,103 @@ func FindScenes(client *graphql.Client, filter *SceneFilterType, page, perPage i
 	return query.FindScenes.Scenes, query.FindScenes.Count, nil
 }
 
+// FindScenesAfter queries the first perPage scenes matching filter with an ID
+// above afterID, in ID order. Batches fetched after the last scene processed
+// are unaffected by processed scenes leaving or staying in filter.
+func FindScenesAfter(client *graphql.Client, filter *SceneFilterType, afterID int, perPage int) ([]Scene, int, error) {
+	after := *filter
+	after.ID = &IntCriterionInput{Value: afterID, Modifier: CriterionModifierGreaterThan}
+
+	scenes, count, err := findScenesByID(client, &after, SortDirectionAsc, perPage)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	log.Debugf("FindScenesAfter(%d) returned %d scenes (remaining: %d)", afterID, len(scenes), count)
+	return scenes, count, nil
+}
+
+// FindScenesBefore queries the first perPage scenes matching filter with an
+// ID below beforeID (any ID when beforeID is 0), newest first; the
+// descending counterpart of FindScenesAfter
+func FindScenesBefore(client *graphql.Client, filter *SceneFilterType, beforeID int, perPage int) ([]Scene, int, error) {
+	before := *filter
+	if beforeID > 0 {
+		before.ID = &IntCriterionInput{Value: beforeID, Modifier: CriterionModifierLessThan}
+	}
+
+	scenes, count, err := findScenesByID(client, &before, SortDirectionDesc, perPage)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	log.Debugf("FindScenesBefore(%d) returned %d scenes (remaining: %d)", beforeID, len(scenes), count)
+	return scenes, count, nil
+}
+
+// findScenesByID queries the first perPage scenes matching filter in ID order
+func findScenesByID(client *graphql.Client, filter *SceneFilterType, direction SortDirectionEnum, perPage int) ([]Scene, int, error) {
+	var query struct {
+		FindScenes struct {
+			Count  int     `graphql:"count"`
+			Scenes []Scene `graphql:"scenes"`
+		} `graphql:"findScenes(filter: $filter, scene_filter: $scene_filter)"`
+	}
+
+	page := 1
+	sort := "id"
+	variables := map[string]interface{}{
+		"filter": &FindFilterType{
+			Page:      &page,
+			PerPage:   &perPage,
+			Sort:      &sort,
+			Direction: &direction,
+		},
+		"scene_filter": filter,
+	}
+
+	if err := client.Query(context.Background(), &query, variables); err != nil {
+		return nil, 0, fmt.Errorf("failed to query scenes: %w", err)
+	}
+	return query.FindScenes.Scenes, query.FindScenes.Count, nil
+}
+
+// FindAllScenes iterates over every scene matching filter, fetching perPage
+// scenes at a time (DefaultPageSize if perPage <= 0) and passing each page to
+// fn along with the total count. Return ErrStopPaging from fn to stop early.
+//
+// fn must not change whether already-visited scenes match filter (e.g. by
+// removing a filtered tag), as that shifts later pages; collect IDs first and
+// mutate after iteration completes.
+func FindAllScenes(client *graphql.Client, filter *SceneFilterType, perPage int, fn func(scenes []Scene, count int) error) error {
+	return paginate(perPage, func(page, perPage int) (int, int, error) {
+		scenes, count, err := FindScenes(client, filter, page, perPage)
+		if err != nil {
+			return 0, 0, err
+		}
+		if len(scenes) == 0 {
+			return 0, count, nil
+		}
+		if err := fn(scenes, count); err != nil {
+			return len(scenes), count, err
+		}
+		return len(scenes), count, nil
+	})
+}
+
+// CanonicalVideoFile returns the file to analyze for a scene with multiple
+// files (e.g. full video plus trailer): the longest file, or the first file
+// when durations are equal. Returns nil if the scene has no files.
+func CanonicalVideoFile(scene *Scene) *VideoFile {
+	var canonical *VideoFile
+	for i := range scene.Files {
+		if canonical == nil || scene.Files[i].Duration > canonical.Duration {
+			canonical = &scene.Files[i]
+		}
+	}
+	return canonical
+}
+
 // GetScene retrieves a single scene by ID
 func GetScene(client *graphql.Client, sceneID graphql.ID) (*Scene, error) {
 	ctx := context.Background()
@@ -94,24 +198,25 @@ func AddTagToScene(client *graphql.Client, sceneID graphql.ID, tagID graphql.ID)
 		return fmt.Errorf("failed to get scene: %w", err)
 	}
 
-	// Build list of existing tag IDs
-	tagIDs := []graphql.ID{}
-	for _, tag := range scene.Tags {
-		tagIDs = append(tagIDs, tag.ID)
-	}
+	return UpdateSceneTagsIfChanged(client, scene, []graphql.ID{tagID}, nil)
+}
 
-	// Check if tag already exists
-	for _, existingTagID := range tagIDs {
-		if existingTagID == tagID {
-			// Tag already present, no update needed
-			return nil
-		}
+// UpdateSceneTagsIfChanged adds and removes tags using the tags already loaded
+// on scene, skipping the mutation when nothing would change. scene.Tags is
+// updated in place so repeated calls on the same scene stay in sync.
+func UpdateSceneTagsIfChanged(client *graphql.Client, scene *Scene, add []graphql.ID, remove []graphql.ID) error {
+	tagIDs, changed := MergeTagIDs(scene.Tags, add, remove)
+	if !changed {
+		log.Tracef("Scene %s tags unchanged, skipping update", scene.ID)
+		return nil
 	}
 
-	// Add the new tag
-	tagIDs = append(tagIDs, tagID)
+	if err := UpdateSceneTags(client, scene.ID, tagIDs); err != nil {
+		return err
+	}
 
-	return UpdateSceneTags(client, sceneID, tagIDs)
+	scene.Tags = tagsFromIDs(scene.Tags, tagIDs)
+	return nil
 }
 
 // UpdateSceneTags updates a scene's tags (replaces all tags)
@@ -144,15 +249,7 @@ func RemoveTagFromScene(client *graphql.Client, sceneID graphql.ID, tagID graphq
 		return fmt.Errorf("failed to get scene: %w", err)
 	}
 
-	// Filter out the tag to remove
-	tagIDs := []graphql.ID{}
-	for _, tag := range scene.Tags {
-		if tag.ID != tagID {
-			tagIDs = append(tagIDs, tag.ID)
-		}
-	}
-
-	err = UpdateSceneTags(client, sceneID, tagIDs)
+	err = UpdateSceneTagsIfChanged(client, scene, nil, []graphql.ID{tagID})
 	if err != nil {
 		return fmt.Errorf("failed to remove tag from scene: %w", err)
 	}
@@ -222,3 +319,52 @@ func AddPerformerToScene(client *graphql.Client, sceneID graphql.ID, performerID
 
 	return UpdateScenePerformers(client, sceneID, performerIDs)
 }
+
+// FindSceneMarkers retrieves the markers of a scene
+func FindSceneMarkers(client *graphql.Client, sceneID graphql.ID) ([]SceneMarker, error) {
+	ctx := context.Background()
+
+	var query struct {
+		FindScene *struct {
+			SceneMarkers []SceneMarker `graphql:"scene_markers"`
+		} `graphql:"findScene(id: $id)"`
+	}
+
+	variables := map[string]interface{}{
+		"id": sceneID,
+	}
+
+	err := client.Query(ctx, &query, variables)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scene markers: %w", err)
+	}
+
+	if query.FindScene == nil {
+		return nil, fmt.Errorf("scene not found")
+	}
+
+	return query.FindScene.SceneMarkers, nil
+}
+
+// CreateSceneMarker creates a scene marker
+func CreateSceneMarker(client *graphql.Client, input SceneMarkerCreateInput) (graphql.ID, error) {
+	ctx := context.Background()
+
+	var mutation struct {
+		SceneMarkerCreate struct {
+			ID graphql.ID `graphql:"id"`
+		} `graphql:"sceneMarkerCreate(input: $input)"`
+	}
+
+	variables := map[string]interface{}{
+		"input": input,
+	}
+
+	err := client.Mutate(ctx, &mutation, variables)
+	if err != nil {
+		return "", fmt.Errorf("scene marker create mutation failed: %w", err)
+	}
+
+	log.Debugf("Created marker '%s' at %.2fs on scene %s", input.Title, input.Seconds, input.SceneID)
+	return mutation.SceneMarkerCreate.ID, nil
+}
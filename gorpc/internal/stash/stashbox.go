@@ -0,0 +1,165 @@
+package stash
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	graphql "github.com/hasura/go-graphql-client"
+	"github.com/stashapp/stash/pkg/models"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+// ============================================================================
+// Stash-box Lookups (Repository Layer)
+// ============================================================================
+//
+// Stash-box endpoints are queried through Stash's own scrapers, so the API
+// keys configured in Stash are used and never seen by the plugin.
+//
+// ============================================================================
+
+// ScrapedPerformer is a performer found on a stash-box endpoint
+type ScrapedPerformer struct {
+	StoredID       string   `graphql:"stored_id"` // Stash performer already linked to it, if any
+	Name           string   `graphql:"name"`
+	Disambiguation string   `graphql:"disambiguation"`
+	Gender         string   `graphql:"gender"`
+	Birthdate      string   `graphql:"birthdate"`
+	Aliases        string   `graphql:"aliases"` // Comma-separated
+	Images         []string `graphql:"images"`
+	RemoteSiteID   string   `graphql:"remote_site_id"` // Performer ID on the stash-box endpoint
+}
+
+// AliasList returns the performer's aliases
+func (p ScrapedPerformer) AliasList() []string {
+	var aliases []string
+	for _, alias := range strings.Split(p.Aliases, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// scraperSource selects the stash-box endpoint a scrape queries
+type scraperSource struct {
+	StashBoxEndpoint string `json:"stash_box_endpoint"`
+}
+
+func (scraperSource) GetGraphQLType() string { return "ScraperSourceInput" }
+
+// scrapePerformerInput is a performer name search
+type scrapePerformerInput struct {
+	Query string `json:"query"`
+}
+
+func (scrapePerformerInput) GetGraphQLType() string { return "ScrapeSinglePerformerInput" }
+
+// scrapeSceneInput identifies a scene by its fingerprints
+type scrapeSceneInput struct {
+	SceneID string `json:"scene_id"`
+}
+
+func (scrapeSceneInput) GetGraphQLType() string { return "ScrapeSingleSceneInput" }
+
+// ScrapeStashBoxPerformers searches a stash-box endpoint for performers by name
+func ScrapeStashBoxPerformers(client *graphql.Client, endpoint string, name string) ([]ScrapedPerformer, error) {
+	var query struct {
+		ScrapeSinglePerformer []ScrapedPerformer `graphql:"scrapeSinglePerformer(source: $source, input: $input)"`
+	}
+
+	variables := map[string]interface{}{
+		"source": scraperSource{StashBoxEndpoint: endpoint},
+		"input":  scrapePerformerInput{Query: name},
+	}
+
+	if err := client.Query(context.Background(), &query, variables); err != nil {
+		return nil, fmt.Errorf("failed to search %s for performers: %w", endpoint, err)
+	}
+
+	log.Debugf("Found %d performers named %q on %s", len(query.ScrapeSinglePerformer), name, endpoint)
+	return query.ScrapeSinglePerformer, nil
+}
+
+// ScrapeStashBoxScenePerformers returns the performers of the scenes a
+// stash-box endpoint matches to a scene's fingerprints
+func ScrapeStashBoxScenePerformers(client *graphql.Client, endpoint string, sceneID graphql.ID) ([]ScrapedPerformer, error) {
+	var query struct {
+		ScrapeSingleScene []struct {
+			Performers []ScrapedPerformer `graphql:"performers"`
+		} `graphql:"scrapeSingleScene(source: $source, input: $input)"`
+	}
+
+	variables := map[string]interface{}{
+		"source": scraperSource{StashBoxEndpoint: endpoint},
+		"input":  scrapeSceneInput{SceneID: string(sceneID)},
+	}
+
+	if err := client.Query(context.Background(), &query, variables); err != nil {
+		return nil, fmt.Errorf("failed to match scene %s on %s: %w", sceneID, endpoint, err)
+	}
+
+	var performers []ScrapedPerformer
+	for _, scene := range query.ScrapeSingleScene {
+		performers = append(performers, scene.Performers...)
+	}
+	log.Debugf("Scene %s matched %d scenes with %d performers on %s", sceneID, len(query.ScrapeSingleScene), len(performers), endpoint)
+	return performers, nil
+}
+
+// FindPerformerByStashID returns the Stash performer linked to a stash-box
+// performer, or "" when there is none
+func FindPerformerByStashID(client *graphql.Client, endpoint string, stashID string) (graphql.ID, error) {
+	performer, err := FindPerformer(client, PerformerFilterType{
+		StashIDEndpoint: &StashIDCriterionInput{
+			Endpoint: &endpoint,
+			StashID:  &stashID,
+			Modifier: CriterionModifierEquals,
+		},
+	})
+	if err != nil || performer == nil {
+		return "", err
+	}
+	return performer.ID, nil
+}
+
+// CreatePerformerFromStashBox creates a performer from a stash-box performer,
+// linked to it by stash ID, with extra aliases (e.g. its subject name)
+func CreatePerformerFromStashBox(client *graphql.Client, endpoint string, scraped ScrapedPerformer, aliases []string) (graphql.ID, error) {
+	input := PerformerCreateInput{
+		Name:      scraped.Name,
+		AliasList: DedupeAliases(append(scraped.AliasList(), aliases...)),
+		StashIds:  []models.StashIDInput{{Endpoint: endpoint, StashID: scraped.RemoteSiteID}},
+	}
+	if scraped.Disambiguation != "" {
+		input.Disambiguation = &scraped.Disambiguation
+	}
+	if gender, err := ParseGenderEnum(scraped.Gender); err == nil {
+		modelGender := models.GenderEnum(gender)
+		input.Gender = &modelGender
+	}
+	if scraped.Birthdate != "" {
+		input.Birthdate = &scraped.Birthdate
+	}
+	if len(scraped.Images) > 0 {
+		input.Image = &scraped.Images[0]
+	}
+
+	var mutation struct {
+		PerformerCreate PerformerCreate `graphql:"performerCreate(input: $input)"`
+	}
+
+	variables := map[string]interface{}{
+		"input": input,
+	}
+
+	if err := client.Mutate(context.Background(), &mutation, variables); err != nil {
+		return "", fmt.Errorf("failed to create performer: %w", err)
+	}
+
+	performerID := mutation.PerformerCreate.ID
+	log.Infof("Created performer '%s' from %s: %s", scraped.Name, endpoint, performerID)
+	return performerID, nil
+}
@@ -5,11 +5,12 @@ import (
 	"fmt"
 
 	graphql "github.com/hasura/go-graphql-client"
-	"github.com/stashapp/stash/pkg/plugin/common/log"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
 )
 
-// findOrCreateTag finds a tag by name or creates it if it doesn't exist
-func findOrCreateTag(client *graphql.Client, cache *TagCache, tagName string) (graphql.ID, error) {
+// findTag finds a tag by name, returning an empty ID if it doesn't exist
+func findTag(client *graphql.Client, cache *TagCache, tagName string) (graphql.ID, error) {
 	// Check cache first
 	if id, ok := cache.Get(tagName); ok {
 		log.Tracef("Tag '%s' found in cache: %s", tagName, id)
@@ -51,6 +52,16 @@ func findOrCreateTag(client *graphql.Client, cache *TagCache, tagName string) (g
 		return tagID, nil
 	}
 
+	return "", nil
+}
+
+// findOrCreateTag finds a tag by name or creates it if it doesn't exist
+func findOrCreateTag(client *graphql.Client, cache *TagCache, tagName string) (graphql.ID, error) {
+	tagID, err := findTag(client, cache, tagName)
+	if err != nil || tagID != "" {
+		return tagID, err
+	}
+
 	// Create new tag
 	var mutation struct {
 		TagCreate struct {
@@ -72,31 +83,181 @@ func findOrCreateTag(client *graphql.Client, cache *TagCache, tagName string) (g
 		return "", fmt.Errorf("failed to create tag: %w", err)
 	}
 
-	tagID := mutation.TagCreate.ID
+	tagID = mutation.TagCreate.ID
 	cache.Set(tagName, tagID)
 	log.Infof("Created tag '%s': %s", tagName, tagID)
 	return tagID, nil
 }
 
-// GetOrCreateTag gets or creates a tag by name (convenience wrapper)
+// GetOrCreateTag gets or creates one of the plugin's tags by name, nested
+// under the cache's parent tag when one is set
 func GetOrCreateTag(client *graphql.Client, cache *TagCache, tagName string, defaultName string) (graphql.ID, error) {
 	if tagName == "" {
 		tagName = defaultName
 	}
+	tagID, err := findOrCreateTag(client, cache, tagName)
+	if err != nil {
+		return "", err
+	}
+
+	// A tag that can't be nested still works at the top level
+	if err := EnsureTagHierarchy(client, cache, tagName, tagID); err != nil {
+		log.Warnf("Failed to nest tag '%s' under '%s': %v", tagName, cache.ParentTag(), err)
+	}
+	return tagID, nil
+}
+
+// GetOrCreateFlatTag gets or creates a tag by name without nesting it under
+// the parent tag, for tags shared with the user (e.g. performer names)
+func GetOrCreateFlatTag(client *graphql.Client, cache *TagCache, tagName string) (graphql.ID, error) {
 	return findOrCreateTag(client, cache, tagName)
 }
 
-// TriggerMetadataScan triggers a metadata scan
-func TriggerMetadataScan(client *graphql.Client) error {
+// EnsureTagHierarchy creates the cache's parent tag if needed and adds it to
+// the parents of a tag, keeping the tag's other parents. Each tag is checked
+// once per cache.
+func EnsureTagHierarchy(client *graphql.Client, cache *TagCache, tagName string, tagID graphql.ID) error {
+	parentName := cache.ParentTag()
+	if parentName == "" || tagName == parentName || cache.isNested(tagID) {
+		return nil
+	}
+
+	parentID, err := findOrCreateTag(client, cache, parentName)
+	if err != nil {
+		return err
+	}
+
+	var query struct {
+		FindTag *struct {
+			Parents []struct {
+				ID graphql.ID
+			}
+		} `graphql:"findTag(id: $id)"`
+	}
+	variables := map[string]interface{}{
+		"id": tagID,
+	}
+	if err := client.Query(context.Background(), &query, variables); err != nil {
+		return fmt.Errorf("failed to query tag parents: %w", err)
+	}
+	if query.FindTag == nil {
+		return fmt.Errorf("tag %s not found", tagID)
+	}
+
+	parentIDs := make([]graphql.ID, 0, len(query.FindTag.Parents)+1)
+	for _, parent := range query.FindTag.Parents {
+		if parent.ID == parentID {
+			cache.setNested(tagID)
+			return nil
+		}
+		parentIDs = append(parentIDs, parent.ID)
+	}
+	parentIDs = append(parentIDs, parentID)
+
 	var mutation struct {
-		MetadataScan graphql.String `graphql:"metadataScan(input: {})"`
+		TagUpdate struct {
+			ID graphql.ID
+		} `graphql:"tagUpdate(input: $input)"`
+	}
+	updateVars := map[string]interface{}{
+		"input": TagUpdateInput{ID: tagID, ParentIDs: parentIDs},
+	}
+	if err := client.Mutate(context.Background(), &mutation, updateVars); err != nil {
+		return fmt.Errorf("failed to update tag parents: %w", err)
+	}
+
+	cache.setNested(tagID)
+	log.Infof("Nested tag '%s' under '%s'", tagName, parentName)
+	return nil
+}
+
+// FindTagIDs looks up existing tags by name without creating missing ones.
+// Names with no matching tag are skipped.
+func FindTagIDs(client *graphql.Client, cache *TagCache, tagNames []string) ([]graphql.ID, error) {
+	tagIDs := make([]graphql.ID, 0, len(tagNames))
+	for _, tagName := range tagNames {
+		tagID, err := findTag(client, cache, tagName)
+		if err != nil {
+			return nil, err
+		}
+		if tagID == "" {
+			log.Debugf("Tag '%s' not found, skipping", tagName)
+			continue
+		}
+		tagIDs = append(tagIDs, tagID)
+	}
+	return tagIDs, nil
+}
+
+// TriggerMetadataScan triggers a metadata scan of the given paths, or of the
+// whole library when paths is empty
+func TriggerMetadataScan(client *graphql.Client, paths []string) error {
+	var mutation struct {
+		MetadataScan graphql.String `graphql:"metadataScan(input: $input)"`
+	}
+
+	variables := map[string]interface{}{
+		"input": ScanMetadataInput{Paths: paths},
 	}
 
-	err := client.Mutate(context.Background(), &mutation, nil)
+	err := client.Mutate(context.Background(), &mutation, variables)
 	if err != nil {
 		return fmt.Errorf("failed to trigger metadata scan: %w", err)
 	}
 
-	log.Info("Triggered metadata scan")
+	if len(paths) > 0 {
+		log.Infof("Triggered metadata scan of %d paths", len(paths))
+	} else {
+		log.Info("Triggered metadata scan")
+	}
 	return nil
 }
+
+// MergeTagIDs applies tag additions and removals to an existing tag list.
+// Returns the resulting tag IDs and whether they differ from current, so
+// callers can skip no-op mutations.
+func MergeTagIDs(current []Tag, add []graphql.ID, remove []graphql.ID) ([]graphql.ID, bool) {
+	removeSet := make(map[graphql.ID]bool, len(remove))
+	for _, id := range remove {
+		removeSet[id] = true
+	}
+
+	changed := false
+	present := make(map[graphql.ID]bool, len(current)+len(add))
+	tagIDs := make([]graphql.ID, 0, len(current)+len(add))
+	for _, tag := range current {
+		if removeSet[tag.ID] {
+			changed = true
+			continue
+		}
+		if !present[tag.ID] {
+			present[tag.ID] = true
+			tagIDs = append(tagIDs, tag.ID)
+		}
+	}
+
+	for _, id := range add {
+		if id == "" || present[id] || removeSet[id] {
+			continue
+		}
+		present[id] = true
+		tagIDs = append(tagIDs, id)
+		changed = true
+	}
+
+	return tagIDs, changed
+}
+
+// tagsFromIDs builds a Tag list from IDs, keeping names known from current
+func tagsFromIDs(current []Tag, tagIDs []graphql.ID) []Tag {
+	names := make(map[graphql.ID]string, len(current))
+	for _, tag := range current {
+		names[tag.ID] = tag.Name
+	}
+
+	tags := make([]Tag, len(tagIDs))
+	for i, id := range tagIDs {
+		tags[i] = Tag{ID: id, Name: names[id]}
+	}
+	return tags
+}
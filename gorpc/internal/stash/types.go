@@ -1,6 +1,8 @@
 package stash
 
 import (
+	"time"
+
 	graphql "github.com/hasura/go-graphql-client"
 	"github.com/stashapp/stash/pkg/models"
 )
@@ -16,6 +18,16 @@ type Performer struct {
 	Tags      []Tag      `graphql:"tags"`
 }
 
+// PerformerUsage is a performer with its creation time and the number of
+// images, scenes and galleries it is associated with
+type PerformerUsage struct {
+	Performer
+	CreatedAt    time.Time `graphql:"created_at"`
+	ImageCount   int       `graphql:"image_count"`
+	SceneCount   int       `graphql:"scene_count"`
+	GalleryCount int       `graphql:"gallery_count"`
+}
+
 // ImagePaths represents the paths for an image
 type ImagePaths struct {
 	Image string `graphql:"image"`
@@ -26,14 +38,37 @@ type ImageFile struct {
 	Path string `graphql:"path"`
 }
 
+// Dimensions represents the pixel size of a visual file
+type Dimensions struct {
+	Width  int `graphql:"width"`
+	Height int `graphql:"height"`
+}
+
+// BaseFile holds the fields shared by every file type
+type BaseFile struct {
+	Path string `graphql:"path"`
+}
+
+// VisualFile represents the primary file of an image, which Stash reports as
+// either an image or a video (image clips)
+type VisualFile struct {
+	Typename  string     `graphql:"__typename"` // "ImageFile" or "VideoFile"
+	File      BaseFile   `graphql:"... on BaseFile"`
+	ImageFile Dimensions `graphql:"... on ImageFile"`
+	VideoFile Dimensions `graphql:"... on VideoFile"`
+}
+
 // Image represents a Stash image
 type Image struct {
-	ID         graphql.ID  `graphql:"id"`
-	Title      string      `graphql:"title"`
-	Paths      ImagePaths  `graphql:"paths"`
-	Files      []ImageFile `graphql:"files"`
-	Tags       []Tag       `graphql:"tags"`
-	Performers []Performer `graphql:"performers"`
+	ID          graphql.ID   `graphql:"id"`
+	Title       string       `graphql:"title"`
+	Paths       ImagePaths   `graphql:"paths"`
+	Files       []ImageFile  `graphql:"files"`
+	VisualFiles []VisualFile `graphql:"visual_files"`
+	Tags        []Tag        `graphql:"tags"`
+	Performers  []Performer  `graphql:"performers"`
+	Rating      *int         `graphql:"rating100"` // 1-100, nil when unrated
+	Organized   bool         `graphql:"organized"`
 }
 
 // ScenePaths represents the paths for a scene
@@ -44,7 +79,8 @@ type ScenePaths struct {
 
 // VideoFile represents a video file
 type VideoFile struct {
-	Path string `graphql:"path"`
+	Path     string  `graphql:"path"`
+	Duration float64 `graphql:"duration"`
 }
 
 // Scene represents a Stash scene
@@ -55,6 +91,8 @@ type Scene struct {
 	Paths      ScenePaths  `graphql:"paths"`
 	Tags       []Tag       `graphql:"tags"`
 	Performers []Performer `graphql:"performers"`
+	Rating     *int        `graphql:"rating100"` // 1-100, nil when unrated
+	Organized  bool        `graphql:"organized"`
 }
 
 // Tag represents a Stash tag
@@ -171,6 +209,34 @@ const (
 	CriterionModifierNotBetween      = models.CriterionModifierNotBetween
 )
 
+// SortDirectionEnum is the direction of a sort
+type SortDirectionEnum = models.SortDirectionEnum
+
+// Sort directions
+const (
+	SortDirectionAsc  = models.SortDirectionEnumAsc
+	SortDirectionDesc = models.SortDirectionEnumDesc
+)
+
+// BatchSort is the order a batch query returns media in; the zero value
+// keeps Stash's default order
+type BatchSort struct {
+	Sort      string            // Sort field, e.g. "created_at" or "random_<seed>"
+	Direction SortDirectionEnum // Empty for Stash's default direction
+}
+
+// apply sets the sort of a find filter
+func (b BatchSort) apply(filter *FindFilterType) {
+	if b.Sort != "" {
+		sort := b.Sort
+		filter.Sort = &sort
+	}
+	if b.Direction != "" {
+		direction := b.Direction
+		filter.Direction = &direction
+	}
+}
+
 type (
 	GenderEnum = string
 )
@@ -184,11 +250,37 @@ const (
 	GenderEnumNonBinary         GenderEnum = "NON_BINARY"
 )
 
+// PerformerDestroyInput represents input for deleting a performer
+type PerformerDestroyInput struct {
+	ID graphql.ID `graphql:"id" json:"id"`
+}
+
 // TagCreateInput represents input for creating a tag
 type TagCreateInput struct {
 	Name graphql.String `graphql:"name" json:"name"`
 }
 
+// TagUpdateInput represents input for updating a tag's parents
+type TagUpdateInput struct {
+	ID        graphql.ID   `graphql:"id" json:"id"`
+	ParentIDs []graphql.ID `graphql:"parent_ids" json:"parent_ids"`
+}
+
+// ScanMetadataInput represents input for a metadata scan
+type ScanMetadataInput struct {
+	Paths []string `graphql:"paths" json:"paths,omitempty"`
+}
+
+// SceneMarkerCreateInput represents input for creating a scene marker
+type SceneMarkerCreateInput struct {
+	Title        string       `graphql:"title" json:"title"`
+	Seconds      float64      `graphql:"seconds" json:"seconds"`
+	EndSeconds   *float64     `graphql:"end_seconds" json:"end_seconds,omitempty"`
+	SceneID      graphql.ID   `graphql:"scene_id" json:"scene_id"`
+	PrimaryTagID graphql.ID   `graphql:"primary_tag_id" json:"primary_tag_id"`
+	TagIDs       []graphql.ID `graphql:"tag_ids" json:"tag_ids,omitempty"`
+}
+
 // PluginConfigResult represents the configuration result for a plugin
 type PluginConfigResult [][2]interface{}
 
@@ -227,6 +319,13 @@ type SceneUpdate struct {
 	ID graphql.ID `graphql:"id"`
 }
 
+// SceneMarker represents a Stash scene marker
+type SceneMarker struct {
+	ID      graphql.ID `graphql:"id"`
+	Title   string     `graphql:"title"`
+	Seconds float64    `graphql:"seconds"`
+}
+
 // GalleryUpdate represents the result of updating a gallery
 type GalleryUpdate struct {
 	ID graphql.ID `graphql:"id"`
@@ -237,7 +336,9 @@ type PerformerSubject struct {
 	ID      string   `graphql:"id"`
 	Name    string   `graphql:"name"`
 	Aliases []string `graphql:"aliases"`
-	Age     int      `graphql:"age"`
+	Age     int      `graphql:"age"`      // Age the birthdate is estimated from; 0 leaves it unset
+	AgeLow  int      `graphql:"age_low"`  // Estimated age range, recorded in the details; 0 when unknown
+	AgeHigh int      `graphql:"age_high"` // Estimated age range, recorded in the details; 0 when unknown
 	Gender  string   `graphql:"gender"`
 	Image   string   `graphql:"image"`
 }
@@ -0,0 +1,33 @@
+package stash
+
+import (
+	"context"
+	"fmt"
+
+	graphql "github.com/hasura/go-graphql-client"
+)
+
+// ============================================================================
+// Server Version (Repository Layer)
+// ============================================================================
+
+// ServerVersion holds the Stash server version details
+type ServerVersion struct {
+	Version   string `graphql:"version"`
+	Hash      string `graphql:"hash"`
+	BuildTime string `graphql:"build_time"`
+}
+
+// GetServerVersion retrieves the connected Stash server version
+func GetServerVersion(client *graphql.Client) (*ServerVersion, error) {
+	var query struct {
+		Version ServerVersion `graphql:"version"`
+	}
+
+	err := client.Query(context.Background(), &query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query version: %w", err)
+	}
+
+	return &query.Version, nil
+}
@@ -0,0 +1,317 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ============================================================================
+// State Backups
+// ============================================================================
+//
+// Destructive maintenance tasks (resetting unmatched media, merging duplicate
+// performers, deleting subjects) first export the state they are about to
+// change, so a bad run can be undone with restoreBackup. Each backup is a
+// directory named after its time and task, holding the records in JSON lines
+// chunks of backupChunkRecords, the subject examples it saved (faces/), and a
+// manifest written last: a backup without a complete manifest was interrupted
+// and is never restored. Records are read back in the order they were added.
+//
+// ============================================================================
+
+// Backup record kinds
+const (
+	BackupPerformer = "performer" // A performer's names, tags and birthdate
+	BackupSubject   = "subject"   // A Compreface subject, its performer and examples
+	BackupImage     = "image"     // An image's tags and performers
+	BackupScene     = "scene"     // A scene's tags and performers
+	BackupGallery   = "gallery"   // A gallery's tags and performers
+)
+
+// backupChunkRecords is the number of records per chunk file
+const backupChunkRecords = 500
+
+// backupManifestFile is the manifest's name in a backup directory
+const backupManifestFile = "manifest.json"
+
+// BackupRecord is a piece of exported state. Fields not relevant to the
+// record's kind are empty.
+type BackupRecord struct {
+	Kind        string   `json:"kind"`                   // Backup* kind
+	ID          string   `json:"id,omitempty"`           // Stash ID of the performer, image, scene or gallery
+	Name        string   `json:"name,omitempty"`         // Performer name
+	Aliases     []string `json:"aliases,omitempty"`      // Performer aliases
+	Gender      string   `json:"gender,omitempty"`       // Performer gender
+	Birthdate   string   `json:"birthdate,omitempty"`    // Performer birthdate
+	Tags        []string `json:"tags,omitempty"`         // Tag IDs
+	Performers  []string `json:"performers,omitempty"`   // Performer IDs of an image, scene or gallery
+	Subject     string   `json:"subject,omitempty"`      // Subject name
+	PerformerID string   `json:"performer_id,omitempty"` // Performer a subject is linked to
+	MergedInto  string   `json:"merged_into,omitempty"`  // Subject a subject's examples are merged into
+	Faces       []string `json:"faces,omitempty"`        // Compreface image IDs of a subject's examples
+}
+
+// BackupManifest describes a backup
+type BackupManifest struct {
+	Name     string     `json:"name"` // Directory name, e.g. "20260102-150405-mergeDuplicatePerformers"
+	Task     string     `json:"task"`
+	Created  time.Time  `json:"created"`
+	Records  int        `json:"records"`
+	Chunks   int        `json:"chunks"`
+	Faces    int        `json:"faces"` // Subject examples saved
+	Complete bool       `json:"complete"`
+	Restored *time.Time `json:"restored,omitempty"`
+}
+
+// BackupWriter exports records to a new backup
+type BackupWriter struct {
+	dir      string
+	manifest BackupManifest
+	chunk    *os.File
+	inChunk  int
+}
+
+// CreateBackup starts a backup of a task's state in a new directory under root
+func CreateBackup(root string, task string) (*BackupWriter, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	created := time.Now()
+	name := created.Format("20060102-150405") + "-" + task
+	dir := filepath.Join(root, name)
+	for i := 2; ; i++ {
+		err := os.Mkdir(dir, 0o755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create backup directory: %w", err)
+		}
+		name = fmt.Sprintf("%s-%s-%d", created.Format("20060102-150405"), task, i)
+		dir = filepath.Join(root, name)
+	}
+
+	return &BackupWriter{dir: dir, manifest: BackupManifest{Name: name, Task: task, Created: created}}, nil
+}
+
+// Name returns the backup's name, as passed to restoreBackup
+func (w *BackupWriter) Name() string {
+	return w.manifest.Name
+}
+
+// Add appends a record, starting a new chunk when the current one is full
+func (w *BackupWriter) Add(record BackupRecord) error {
+	if w.chunk == nil || w.inChunk >= backupChunkRecords {
+		if err := w.closeChunk(); err != nil {
+			return err
+		}
+		w.manifest.Chunks++
+		chunk, err := os.Create(filepath.Join(w.dir, chunkFileName(w.manifest.Chunks)))
+		if err != nil {
+			return fmt.Errorf("failed to create backup chunk: %w", err)
+		}
+		w.chunk, w.inChunk = chunk, 0
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode backup record: %w", err)
+	}
+	if _, err := w.chunk.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write backup record: %w", err)
+	}
+	w.inChunk++
+	w.manifest.Records++
+	return nil
+}
+
+// AddFace saves a subject example under its Compreface image ID
+func (w *BackupWriter) AddFace(imageID string, data []byte) error {
+	facesDir := filepath.Join(w.dir, "faces")
+	if err := os.MkdirAll(facesDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create backup faces directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(facesDir, imageID+".jpg"), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write backup face: %w", err)
+	}
+	w.manifest.Faces++
+	return nil
+}
+
+// Close finishes the backup by writing its manifest
+func (w *BackupWriter) Close() error {
+	if err := w.closeChunk(); err != nil {
+		return err
+	}
+	w.manifest.Complete = true
+	return writeManifest(w.dir, w.manifest)
+}
+
+// Discard closes and deletes an unfinished backup
+func (w *BackupWriter) Discard() error {
+	w.closeChunk()
+	if err := os.RemoveAll(w.dir); err != nil {
+		return fmt.Errorf("failed to delete backup: %w", err)
+	}
+	return nil
+}
+
+// closeChunk closes the chunk being written, if any
+func (w *BackupWriter) closeChunk() error {
+	if w.chunk == nil {
+		return nil
+	}
+	err := w.chunk.Close()
+	w.chunk = nil
+	if err != nil {
+		return fmt.Errorf("failed to close backup chunk: %w", err)
+	}
+	return nil
+}
+
+// Backup is a finished backup opened for restoring
+type Backup struct {
+	dir      string
+	Manifest BackupManifest
+}
+
+// ListBackups returns the manifests of the complete backups under root,
+// newest first
+func ListBackups(root string) ([]BackupManifest, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var manifests []BackupManifest
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		manifest, err := readManifest(filepath.Join(root, entry.Name()))
+		if err != nil || !manifest.Complete {
+			continue
+		}
+		manifests = append(manifests, manifest)
+	}
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].Created.After(manifests[j].Created)
+	})
+	return manifests, nil
+}
+
+// OpenBackup opens the complete backup with the given name under root, or
+// the newest one when name is empty
+func OpenBackup(root string, name string) (*Backup, error) {
+	if name == "" {
+		manifests, err := ListBackups(root)
+		if err != nil {
+			return nil, err
+		}
+		if len(manifests) == 0 {
+			return nil, fmt.Errorf("no backups found")
+		}
+		name = manifests[0].Name
+	}
+
+	dir := filepath.Join(root, filepath.Base(name))
+	manifest, err := readManifest(dir)
+	if err != nil {
+		return nil, fmt.Errorf("backup %s not found: %w", name, err)
+	}
+	if !manifest.Complete {
+		return nil, fmt.Errorf("backup %s is incomplete", name)
+	}
+	return &Backup{dir: dir, Manifest: manifest}, nil
+}
+
+// Each calls fn with the records of each chunk in turn
+func (b *Backup) Each(fn func(records []BackupRecord) error) error {
+	for n := 1; n <= b.Manifest.Chunks; n++ {
+		file, err := os.Open(filepath.Join(b.dir, chunkFileName(n)))
+		if err != nil {
+			return fmt.Errorf("failed to open backup chunk: %w", err)
+		}
+
+		var records []BackupRecord
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var record BackupRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				file.Close()
+				return fmt.Errorf("failed to decode backup record in chunk %d: %w", n, err)
+			}
+			records = append(records, record)
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read backup chunk: %w", err)
+		}
+
+		if err := fn(records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Face returns a subject example saved in the backup
+func (b *Backup) Face(imageID string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(b.dir, "faces", filepath.Base(imageID)+".jpg"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup face: %w", err)
+	}
+	return data, nil
+}
+
+// MarkRestored records that the backup was restored
+func (b *Backup) MarkRestored() error {
+	now := time.Now()
+	b.Manifest.Restored = &now
+	return writeManifest(b.dir, b.Manifest)
+}
+
+// chunkFileName returns the name of the nth chunk file (from 1)
+func chunkFileName(n int) string {
+	return fmt.Sprintf("chunk-%04d.jsonl", n)
+}
+
+// readManifest reads the manifest of the backup in dir
+func readManifest(dir string) (BackupManifest, error) {
+	var manifest BackupManifest
+	data, err := os.ReadFile(filepath.Join(dir, backupManifestFile))
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("failed to decode backup manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// writeManifest replaces the manifest of the backup in dir
+func writeManifest(dir string, manifest BackupManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backup manifest: %w", err)
+	}
+	tmp := filepath.Join(dir, backupManifestFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, backupManifestFile)); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	return nil
+}
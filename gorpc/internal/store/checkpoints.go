@@ -0,0 +1,120 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Batch Checkpoints
+// ============================================================================
+//
+// Cancelled batch runs used to start over from the first page. Batch tasks
+// record, per task, the last page they finished (or, for tasks paging by ID,
+// the last item they processed), so a run started with resume=true continues
+// after it. The file is append-only JSON lines like
+// the face store; later lines supersede earlier ones for the same task, and
+// a done line clears the task's checkpoint.
+//
+// ============================================================================
+
+// Checkpoint is where a batch task stopped
+type Checkpoint struct {
+	Task      string    `json:"task"`              // Task mode, e.g. "recognizeImages"
+	Pass      int       `json:"pass"`              // Index of the performer count pass
+	Page      int       `json:"page"`              // Pages finished in the pass
+	LastID    int       `json:"last_id,omitempty"` // Last item processed in the pass, for tasks paging by ID
+	Seed      int       `json:"seed,omitempty"`    // Shuffle of a random batch order, so a resumed run pages the same order
+	Processed int       `json:"processed"`
+	Done      bool      `json:"done,omitempty"` // Task ran to completion; clears the checkpoint
+	Updated   time.Time `json:"updated"`
+}
+
+// Checkpoints is a persistent index of batch checkpoints by task
+type Checkpoints struct {
+	mu          sync.RWMutex
+	file        *os.File
+	checkpoints map[string]Checkpoint
+}
+
+// OpenCheckpoints loads the checkpoints at path, creating the file (and its
+// directory) if missing
+func OpenCheckpoints(path string) (*Checkpoints, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoints: %w", err)
+	}
+
+	c := &Checkpoints{file: file, checkpoints: map[string]Checkpoint{}}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var checkpoint Checkpoint
+		if err := json.Unmarshal(scanner.Bytes(), &checkpoint); err != nil {
+			continue // Torn line from an interrupted write
+		}
+		c.apply(checkpoint)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+	}
+	return c, nil
+}
+
+// Close closes the checkpoint file
+func (c *Checkpoints) Close() error {
+	return c.file.Close()
+}
+
+// Get returns the checkpoint of a task
+func (c *Checkpoints) Get(task string) (Checkpoint, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	checkpoint, ok := c.checkpoints[task]
+	return checkpoint, ok
+}
+
+// Put records the last page a task finished
+func (c *Checkpoints) Put(checkpoint Checkpoint) error {
+	if checkpoint.Updated.IsZero() {
+		checkpoint.Updated = time.Now()
+	}
+	line, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	c.apply(checkpoint)
+	return nil
+}
+
+// Clear drops a task's checkpoint, e.g. once it has run to completion
+func (c *Checkpoints) Clear(task string) error {
+	if _, ok := c.Get(task); !ok {
+		return nil
+	}
+	return c.Put(Checkpoint{Task: task, Done: true})
+}
+
+// apply updates the index with a checkpoint; callers hold the lock or own c
+func (c *Checkpoints) apply(checkpoint Checkpoint) {
+	if checkpoint.Done {
+		delete(c.checkpoints, checkpoint.Task)
+		return
+	}
+	c.checkpoints[checkpoint.Task] = checkpoint
+}
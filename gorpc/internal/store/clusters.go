@@ -0,0 +1,246 @@
+package store
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Unmatched Scene Clusters
+// ============================================================================
+//
+// A scene face cluster nobody is matched to is often a performer seen from
+// an angle their examples do not cover. Such clusters are kept, with a
+// thumbnail and their best detections, so someone can assign them to the
+// right performer later and the detections become new examples. Rescanning
+// a scene replaces its unassigned clusters. The file is append-only JSON
+// lines like the review queue; later lines supersede earlier ones for the
+// same cluster.
+//
+// ============================================================================
+
+// Unmatched cluster statuses
+const (
+	ClusterOpen     = "unmatched"
+	ClusterAssigned = "assigned"
+	clusterRemoved  = "removed" // Superseded by a rescan; dropped on load
+)
+
+// ClusterDetection is a detection of an unmatched cluster, enough to extract
+// and crop its face again
+type ClusterDetection struct {
+	Timestamp float64 `json:"timestamp"`
+	XMin      int     `json:"x_min"`
+	YMin      int     `json:"y_min"`
+	XMax      int     `json:"x_max"`
+	YMax      int     `json:"y_max"`
+	Quality   float64 `json:"quality,omitempty"`  // Composite quality
+	Enhanced  bool    `json:"enhanced,omitempty"` // Detected on an enhanced frame
+}
+
+// UnmatchedCluster is a scene face cluster without a performer
+type UnmatchedCluster struct {
+	ID          string             `json:"id"`     // ClusterID of the source and representative detection
+	Source      string             `json:"source"` // e.g. "scene:7"
+	Face        string             `json:"face"`   // Vision face ID within the analysis
+	Status      string             `json:"status"`
+	Method      string             `json:"method,omitempty"`       // Vision analysis method, e.g. "sprites"
+	Detections  []ClusterDetection `json:"detections"`             // Best first; the first is the representative
+	Age         int                `json:"age,omitempty"`          // Estimated age
+	Gender      string             `json:"gender,omitempty"`       // Estimated gender
+	PerformerID string             `json:"performer_id,omitempty"` // Performer assigned
+	Created     time.Time          `json:"created"`
+	Updated     time.Time          `json:"updated"`
+}
+
+// Clusters is a persistent index of unmatched scene clusters
+type Clusters struct {
+	mu        sync.RWMutex
+	file      *os.File
+	thumbsDir string
+	clusters  map[string]UnmatchedCluster
+}
+
+// OpenClusters loads the unmatched clusters at path, creating the file (and
+// its directory) if missing. Thumbnails are kept in a "clusters" directory
+// beside it.
+func OpenClusters(path string) (*Clusters, error) {
+	thumbsDir := filepath.Join(filepath.Dir(path), "clusters")
+	if err := os.MkdirAll(thumbsDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cluster directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open unmatched clusters: %w", err)
+	}
+
+	c := &Clusters{file: file, thumbsDir: thumbsDir, clusters: map[string]UnmatchedCluster{}}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var cluster UnmatchedCluster
+		if err := json.Unmarshal(scanner.Bytes(), &cluster); err != nil {
+			continue // Torn line from an interrupted write
+		}
+		c.apply(cluster)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read unmatched clusters: %w", err)
+	}
+	return c, nil
+}
+
+// Close closes the cluster file
+func (c *Clusters) Close() error {
+	return c.file.Close()
+}
+
+// ClusterID identifies a cluster by its source and representative detection,
+// which stay the same when a scene is rescanned with the same settings
+func ClusterID(source string, representative ClusterDetection) string {
+	key := fmt.Sprintf("%s/%.3f/%d,%d,%d,%d", source, representative.Timestamp,
+		representative.XMin, representative.YMin, representative.XMax, representative.YMax)
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Add records an unmatched cluster with its thumbnail. A cluster already
+// assigned is left as it is; Add then reports false.
+func (c *Clusters) Add(cluster UnmatchedCluster, thumbnail []byte) (UnmatchedCluster, bool, error) {
+	if len(cluster.Detections) == 0 {
+		return UnmatchedCluster{}, false, fmt.Errorf("cluster %s of %s has no detections", cluster.Face, cluster.Source)
+	}
+	cluster.ID = ClusterID(cluster.Source, cluster.Detections[0])
+	if existing, ok := c.Get(cluster.ID); ok && existing.Status == ClusterAssigned {
+		return existing, false, nil
+	}
+
+	if err := os.WriteFile(c.ThumbnailPath(cluster.ID), thumbnail, 0o644); err != nil {
+		return UnmatchedCluster{}, false, fmt.Errorf("failed to write cluster thumbnail: %w", err)
+	}
+	cluster.Status = ClusterOpen
+	if cluster.Created.IsZero() {
+		cluster.Created = time.Now()
+	}
+	cluster.Updated = cluster.Created
+	if err := c.put(cluster); err != nil {
+		return UnmatchedCluster{}, false, err
+	}
+	return cluster, true, nil
+}
+
+// Get returns a cluster
+func (c *Clusters) Get(id string) (UnmatchedCluster, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cluster, ok := c.clusters[id]
+	return cluster, ok
+}
+
+// Unmatched returns the unassigned clusters of a source, or of every source
+// when source is empty, by source and then oldest first
+func (c *Clusters) Unmatched(source string) []UnmatchedCluster {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var clusters []UnmatchedCluster
+	for _, cluster := range c.clusters {
+		if cluster.Status == ClusterOpen && (source == "" || cluster.Source == source) {
+			clusters = append(clusters, cluster)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Source != clusters[j].Source {
+			return clusters[i].Source < clusters[j].Source
+		}
+		if !clusters[i].Created.Equal(clusters[j].Created) {
+			return clusters[i].Created.Before(clusters[j].Created)
+		}
+		return clusters[i].ID < clusters[j].ID
+	})
+	return clusters
+}
+
+// ClearSource removes the unassigned clusters of a source, e.g. before the
+// source is rescanned
+func (c *Clusters) ClearSource(source string) error {
+	for _, cluster := range c.Unmatched(source) {
+		cluster.Status = clusterRemoved
+		cluster.Updated = time.Now()
+		if err := c.put(cluster); err != nil {
+			return err
+		}
+		if err := os.Remove(c.ThumbnailPath(cluster.ID)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete cluster thumbnail: %w", err)
+		}
+	}
+	return nil
+}
+
+// Assign records the performer a cluster was assigned to and deletes its
+// thumbnail. The cluster stays recorded, so rescans do not list it again.
+func (c *Clusters) Assign(id string, performerID string) error {
+	cluster, ok := c.Get(id)
+	if !ok {
+		return fmt.Errorf("unmatched cluster %s not found", id)
+	}
+	cluster.Status = ClusterAssigned
+	cluster.PerformerID = performerID
+	cluster.Updated = time.Now()
+	if err := c.put(cluster); err != nil {
+		return err
+	}
+	if err := os.Remove(c.ThumbnailPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete cluster thumbnail: %w", err)
+	}
+	return nil
+}
+
+// Thumbnail returns the stored thumbnail of a cluster
+func (c *Clusters) Thumbnail(id string) ([]byte, error) {
+	thumbnail, err := os.ReadFile(c.ThumbnailPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster thumbnail: %w", err)
+	}
+	return thumbnail, nil
+}
+
+// ThumbnailPath returns the path of a cluster's thumbnail
+func (c *Clusters) ThumbnailPath(id string) string {
+	return filepath.Join(c.thumbsDir, id+".jpg")
+}
+
+// put writes a cluster record and applies it to the index
+func (c *Clusters) put(cluster UnmatchedCluster) error {
+	line, err := json.Marshal(cluster)
+	if err != nil {
+		return fmt.Errorf("failed to encode unmatched cluster: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write unmatched cluster: %w", err)
+	}
+	c.apply(cluster)
+	return nil
+}
+
+// apply updates the index with a cluster record; callers hold the lock or own c
+func (c *Clusters) apply(cluster UnmatchedCluster) {
+	if cluster.Status == clusterRemoved {
+		delete(c.clusters, cluster.ID)
+		return
+	}
+	c.clusters[cluster.ID] = cluster
+}
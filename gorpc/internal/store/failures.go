@@ -0,0 +1,117 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Item Failure Counts
+// ============================================================================
+//
+// Batch tasks count, per item, the runs in which processing it failed, so
+// the failures batch order can put items that keep failing behind the rest
+// instead of retrying them at the head of every run. A success clears the
+// item's count. The file is append-only JSON lines like the checkpoints;
+// later lines supersede earlier ones for the same item.
+//
+// ============================================================================
+
+// FailureCount is the number of failed attempts at an item
+type FailureCount struct {
+	Item    string    `json:"item"` // Kind and ID, e.g. "image:42"
+	Count   int       `json:"count"`
+	Updated time.Time `json:"updated"`
+}
+
+// Failures is a persistent index of failure counts by item
+type Failures struct {
+	mu     sync.RWMutex
+	file   *os.File
+	counts map[string]int
+}
+
+// OpenFailures loads the failure counts at path, creating the file (and its
+// directory) if missing
+func OpenFailures(path string) (*Failures, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create failure count directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open failure counts: %w", err)
+	}
+
+	f := &Failures{file: file, counts: map[string]int{}}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var count FailureCount
+		if err := json.Unmarshal(scanner.Bytes(), &count); err != nil {
+			continue // Torn line from an interrupted write
+		}
+		f.apply(count)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read failure counts: %w", err)
+	}
+	return f, nil
+}
+
+// Close closes the failure count file
+func (f *Failures) Close() error {
+	return f.file.Close()
+}
+
+// Count returns the number of failed attempts at an item
+func (f *Failures) Count(item string) int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.counts[item]
+}
+
+// Record counts a failed attempt at an item
+func (f *Failures) Record(item string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.put(FailureCount{Item: item, Count: f.counts[item] + 1})
+}
+
+// Clear forgets an item's failures, e.g. once it has been processed
+func (f *Failures) Clear(item string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.counts[item] == 0 {
+		return nil
+	}
+	return f.put(FailureCount{Item: item})
+}
+
+// put writes and applies a failure count; callers hold the lock
+func (f *Failures) put(count FailureCount) error {
+	count.Updated = time.Now()
+	line, err := json.Marshal(count)
+	if err != nil {
+		return fmt.Errorf("failed to encode failure count: %w", err)
+	}
+	if _, err := f.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write failure count: %w", err)
+	}
+	f.apply(count)
+	return nil
+}
+
+// apply updates the index with a failure count; callers hold the lock or own f
+func (f *Failures) apply(count FailureCount) {
+	if count.Count <= 0 {
+		delete(f.counts, count.Item)
+		return
+	}
+	f.counts[count.Item] = count.Count
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Processed Gallery Images
+// ============================================================================
+//
+// Galleries gain images over time, and identifyGallery used to reprocess
+// every image on each run. Gallery runs record the images they identified,
+// per gallery, so later runs only process the images added since. The file
+// is append-only JSON lines like the checkpoints: each line adds the images
+// of one run, and a reset line forgets a gallery's images.
+//
+// ============================================================================
+
+// GalleryImages is one line of the processed gallery image file
+type GalleryImages struct {
+	Gallery string    `json:"gallery"`
+	Images  []string  `json:"images,omitempty"` // Image IDs identified by a run
+	Reset   bool      `json:"reset,omitempty"`  // Forget the gallery's images
+	Updated time.Time `json:"updated"`
+}
+
+// ProcessedGalleries is a persistent index of the identified images of each
+// gallery
+type ProcessedGalleries struct {
+	mu        sync.RWMutex
+	file      *os.File
+	galleries map[string]map[string]bool
+}
+
+// OpenProcessedGalleries loads the processed gallery images at path, creating
+// the file (and its directory) if missing
+func OpenProcessedGalleries(path string) (*ProcessedGalleries, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create processed gallery directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open processed galleries: %w", err)
+	}
+
+	p := &ProcessedGalleries{file: file, galleries: map[string]map[string]bool{}}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var line GalleryImages
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue // Torn line from an interrupted write
+		}
+		p.apply(line)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read processed galleries: %w", err)
+	}
+	return p, nil
+}
+
+// Close closes the processed gallery file
+func (p *ProcessedGalleries) Close() error {
+	return p.file.Close()
+}
+
+// Processed reports whether a gallery's image was identified by an earlier run
+func (p *ProcessedGalleries) Processed(galleryID string, imageID string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.galleries[galleryID][imageID]
+}
+
+// Count returns the number of identified images of a gallery
+func (p *ProcessedGalleries) Count(galleryID string) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.galleries[galleryID])
+}
+
+// Add records images of a gallery as identified
+func (p *ProcessedGalleries) Add(galleryID string, imageIDs []string) error {
+	if len(imageIDs) == 0 {
+		return nil
+	}
+	return p.put(GalleryImages{Gallery: galleryID, Images: imageIDs})
+}
+
+// Reset forgets a gallery's identified images, so its next run processes
+// every image
+func (p *ProcessedGalleries) Reset(galleryID string) error {
+	if p.Count(galleryID) == 0 {
+		return nil
+	}
+	return p.put(GalleryImages{Gallery: galleryID, Reset: true})
+}
+
+// put writes and applies a line
+func (p *ProcessedGalleries) put(line GalleryImages) error {
+	line.Updated = time.Now()
+	data, err := json.Marshal(line)
+	if err != nil {
+		return fmt.Errorf("failed to encode processed gallery images: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write processed gallery images: %w", err)
+	}
+	p.apply(line)
+	return nil
+}
+
+// apply updates the index with a line; callers hold the lock or own p
+func (p *ProcessedGalleries) apply(line GalleryImages) {
+	if line.Reset {
+		delete(p.galleries, line.Gallery)
+		return
+	}
+	images := p.galleries[line.Gallery]
+	if images == nil {
+		images = map[string]bool{}
+		p.galleries[line.Gallery] = images
+	}
+	for _, imageID := range line.Images {
+		images[imageID] = true
+	}
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ============================================================================
+// Subject Mapping Exports
+// ============================================================================
+//
+// exportSubjectMappings writes every Compreface subject with the Stash
+// performer it maps to and the image IDs of its examples (and, unless
+// anonymized, the examples themselves) to a single JSON document, so the
+// mapping survives a move to a new Compreface instance or a database reset.
+// Mappings are streamed to a temporary file that only replaces the export
+// once complete; an interrupted export leaves no file behind.
+//
+// ============================================================================
+
+// MappingExportVersion is the format version written to exports
+const MappingExportVersion = 1
+
+// SubjectMapping maps a Compreface subject to its Stash performer
+type SubjectMapping struct {
+	Subject       string   `json:"subject"`
+	PerformerID   string   `json:"performer_id,omitempty"`   // Empty for subjects without a performer
+	PerformerName string   `json:"performer_name,omitempty"` // Recognizes the performer when IDs changed
+	Faces         []string `json:"faces,omitempty"`          // Compreface image IDs of the subject's examples
+	Examples      [][]byte `json:"examples,omitempty"`       // The examples themselves, base64-encoded
+}
+
+// MappingExport is an export of subject mappings
+type MappingExport struct {
+	Version  int              `json:"version"`
+	Exported time.Time        `json:"exported"`
+	Mappings []SubjectMapping `json:"mappings"`
+}
+
+// MappingWriter streams subject mappings to an export file
+type MappingWriter struct {
+	path  string
+	file  *os.File
+	buf   *bufio.Writer
+	count int
+}
+
+// CreateMappingExport starts an export to path, creating its directory
+func CreateMappingExport(path string) (*MappingWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export: %w", err)
+	}
+
+	w := &MappingWriter{path: path, file: file, buf: bufio.NewWriter(file)}
+	header, err := json.Marshal(time.Now())
+	if err != nil {
+		w.Discard()
+		return nil, fmt.Errorf("failed to encode export header: %w", err)
+	}
+	if _, err := fmt.Fprintf(w.buf, "{\"version\":%d,\"exported\":%s,\"mappings\":[", MappingExportVersion, header); err != nil {
+		w.Discard()
+		return nil, fmt.Errorf("failed to write export: %w", err)
+	}
+	return w, nil
+}
+
+// Path returns the export's final path
+func (w *MappingWriter) Path() string {
+	return w.path
+}
+
+// Count returns the number of mappings written
+func (w *MappingWriter) Count() int {
+	return w.count
+}
+
+// Add appends a mapping
+func (w *MappingWriter) Add(mapping SubjectMapping) error {
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		return fmt.Errorf("failed to encode mapping of %s: %w", mapping.Subject, err)
+	}
+	if w.count > 0 {
+		w.buf.WriteByte(',')
+	}
+	w.buf.WriteByte('\n')
+	if _, err := w.buf.Write(data); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	w.count++
+	return nil
+}
+
+// Close finishes the export and moves it into place
+func (w *MappingWriter) Close() error {
+	w.buf.WriteString("\n]}\n")
+	err := w.buf.Flush()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(w.file.Name())
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	if err := os.Rename(w.file.Name(), w.path); err != nil {
+		os.Remove(w.file.Name())
+		return fmt.Errorf("failed to move export into place: %w", err)
+	}
+	return nil
+}
+
+// Discard closes and deletes an unfinished export
+func (w *MappingWriter) Discard() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// ReadMappingExport reads an export written by MappingWriter
+func ReadMappingExport(path string) (*MappingExport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export: %w", err)
+	}
+	var export MappingExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("failed to parse export: %w", err)
+	}
+	if export.Version > MappingExportVersion {
+		return nil, fmt.Errorf("export version %d is newer than supported version %d", export.Version, MappingExportVersion)
+	}
+	return &export, nil
+}
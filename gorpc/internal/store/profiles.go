@@ -0,0 +1,99 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Vision Job Profiles
+// ============================================================================
+//
+// Some scenes make the Vision Service fail (out of memory, decode errors)
+// with the default parameters but succeed with safer ones. The parameters a
+// retry succeeded with are remembered per source, so later runs start with
+// them instead of failing first. The file is append-only JSON lines like the
+// face store; later lines supersede earlier ones for the same source.
+//
+// ============================================================================
+
+// JobProfile is the Vision job parameters that succeeded for a source
+type JobProfile struct {
+	Source           string    `json:"source"` // e.g. "scene:7"
+	SamplingInterval float64   `json:"sampling_interval"`
+	MaxFaces         int       `json:"max_faces"`
+	Enhancement      bool      `json:"enhancement"`
+	Updated          time.Time `json:"updated"`
+}
+
+// JobProfiles is a persistent index of job profiles by source
+type JobProfiles struct {
+	mu       sync.RWMutex
+	file     *os.File
+	profiles map[string]JobProfile
+}
+
+// OpenJobProfiles loads the job profiles at path, creating the file (and its
+// directory) if missing
+func OpenJobProfiles(path string) (*JobProfiles, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create job profile directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job profiles: %w", err)
+	}
+
+	p := &JobProfiles{file: file, profiles: map[string]JobProfile{}}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var profile JobProfile
+		if err := json.Unmarshal(scanner.Bytes(), &profile); err != nil {
+			continue // Torn line from an interrupted write
+		}
+		p.profiles[profile.Source] = profile
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read job profiles: %w", err)
+	}
+	return p, nil
+}
+
+// Close closes the job profile file
+func (p *JobProfiles) Close() error {
+	return p.file.Close()
+}
+
+// Get returns the profile remembered for a source
+func (p *JobProfiles) Get(source string) (JobProfile, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	profile, ok := p.profiles[source]
+	return profile, ok
+}
+
+// Put remembers the profile a source's job succeeded with
+func (p *JobProfiles) Put(profile JobProfile) error {
+	if profile.Updated.IsZero() {
+		profile.Updated = time.Now()
+	}
+	line, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to encode job profile: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write job profile: %w", err)
+	}
+	p.profiles[profile.Source] = profile
+	return nil
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Face Review Queue
+// ============================================================================
+//
+// With review enabled, unmatched faces that would have become new subjects
+// and performers are queued instead, so new identities are only created once
+// someone approves them. Each pending face keeps its crop (a JPEG next to the
+// queue file) and the demographics needed to create the performer later.
+// The file is append-only JSON lines like the face store; later lines
+// supersede earlier ones for the same face.
+//
+// ============================================================================
+
+// Pending face statuses
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// PendingFace is an unmatched or ambiguously matched face queued for review
+type PendingFace struct {
+	ID          string    `json:"id"`     // PendingFaceID of the source and face
+	Source      string    `json:"source"` // e.g. "image:12" or "scene:7"
+	Face        string    `json:"face"`   // Face within the source (Vision face ID or detection index)
+	Status      string    `json:"status"`
+	AgeLow      int       `json:"age_low,omitempty"`
+	AgeHigh     int       `json:"age_high,omitempty"`
+	Gender      string    `json:"gender,omitempty"`
+	Candidates  []string  `json:"candidates,omitempty"`   // Subjects an ambiguous match was between; empty for unmatched faces
+	PerformerID string    `json:"performer_id,omitempty"` // Performer created or assigned on approval
+	Created     time.Time `json:"created"`
+	Updated     time.Time `json:"updated"`
+}
+
+// ReviewQueue is a persistent queue of faces awaiting review
+type ReviewQueue struct {
+	mu       sync.RWMutex
+	file     *os.File
+	cropsDir string
+	faces    map[string]PendingFace
+}
+
+// OpenReviewQueue loads the review queue at path, creating the file (and its
+// directory) if missing. Crops are kept in a "review" directory beside it.
+func OpenReviewQueue(path string) (*ReviewQueue, error) {
+	cropsDir := filepath.Join(filepath.Dir(path), "review")
+	if err := os.MkdirAll(cropsDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create review directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open review queue: %w", err)
+	}
+
+	q := &ReviewQueue{file: file, cropsDir: cropsDir, faces: map[string]PendingFace{}}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var face PendingFace
+		if err := json.Unmarshal(scanner.Bytes(), &face); err != nil {
+			continue // Torn line from an interrupted write
+		}
+		q.faces[face.ID] = face
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read review queue: %w", err)
+	}
+	return q, nil
+}
+
+// Close closes the review queue file
+func (q *ReviewQueue) Close() error {
+	return q.file.Close()
+}
+
+// PendingFaceID identifies a face of a source in the queue. The ID is stable,
+// so a rescan queues the same face only once.
+func PendingFaceID(source string, face string) string {
+	sum := sha256.Sum256([]byte(source + "/" + face))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Add queues a face with its crop. A face already in the queue, whatever its
+// status, is left as it is; Add then reports false.
+func (q *ReviewQueue) Add(face PendingFace, crop []byte) (PendingFace, bool, error) {
+	face.ID = PendingFaceID(face.Source, face.Face)
+	if existing, ok := q.Get(face.ID); ok {
+		return existing, false, nil
+	}
+
+	if err := os.WriteFile(q.CropPath(face.ID), crop, 0o644); err != nil {
+		return PendingFace{}, false, fmt.Errorf("failed to write face crop: %w", err)
+	}
+	face.Status = ReviewPending
+	if face.Created.IsZero() {
+		face.Created = time.Now()
+	}
+	face.Updated = face.Created
+	if err := q.put(face); err != nil {
+		return PendingFace{}, false, err
+	}
+	return face, true, nil
+}
+
+// Get returns a queued face
+func (q *ReviewQueue) Get(id string) (PendingFace, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	face, ok := q.faces[id]
+	return face, ok
+}
+
+// Pending returns the faces awaiting review, oldest first
+func (q *ReviewQueue) Pending() []PendingFace {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var faces []PendingFace
+	for _, face := range q.faces {
+		if face.Status == ReviewPending {
+			faces = append(faces, face)
+		}
+	}
+	sort.Slice(faces, func(i, j int) bool {
+		if !faces[i].Created.Equal(faces[j].Created) {
+			return faces[i].Created.Before(faces[j].Created)
+		}
+		return faces[i].ID < faces[j].ID
+	})
+	return faces
+}
+
+// PendingForSource returns the number of faces of a source awaiting review
+func (q *ReviewQueue) PendingForSource(source string) int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	n := 0
+	for _, face := range q.faces {
+		if face.Source == source && face.Status == ReviewPending {
+			n++
+		}
+	}
+	return n
+}
+
+// Resolve records the review decision for a face and deletes its crop. The
+// face stays in the queue, so rescans do not queue a rejected face again.
+func (q *ReviewQueue) Resolve(id string, status string, performerID string) error {
+	face, ok := q.Get(id)
+	if !ok {
+		return fmt.Errorf("pending face %s not found", id)
+	}
+	face.Status = status
+	face.PerformerID = performerID
+	face.Updated = time.Now()
+	if err := q.put(face); err != nil {
+		return err
+	}
+	if err := os.Remove(q.CropPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete face crop: %w", err)
+	}
+	return nil
+}
+
+// Crop returns the stored crop of a queued face
+func (q *ReviewQueue) Crop(id string) ([]byte, error) {
+	crop, err := os.ReadFile(q.CropPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read face crop: %w", err)
+	}
+	return crop, nil
+}
+
+// CropPath returns the path of a queued face's crop
+func (q *ReviewQueue) CropPath(id string) string {
+	return filepath.Join(q.cropsDir, id+".jpg")
+}
+
+// put writes a face record and applies it to the index
+func (q *ReviewQueue) put(face PendingFace) error {
+	line, err := json.Marshal(face)
+	if err != nil {
+		return fmt.Errorf("failed to encode pending face: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, err := q.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write pending face: %w", err)
+	}
+	q.faces[face.ID] = face
+	return nil
+}
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Local Face Store
+// ============================================================================
+//
+// Every run used to re-submit face crops to Compreface, even for faces whose
+// match was decided by an earlier run. The store remembers, per source (image
+// or scene) and face hash, the face's embedding and the performer it was
+// matched to, so re-runs reuse the decision and new faces can be matched
+// against known embeddings locally before Compreface is asked.
+//
+// Records are appended to a JSON-lines file; later lines supersede earlier
+// ones for the same face. Appending keeps concurrent task processes from
+// overwriting each other's records.
+//
+// ============================================================================
+
+// Face is a remembered match decision for one face of one source
+type Face struct {
+	Source      string    `json:"source"`              // e.g. "image:12" or "scene:7"
+	Hash        string    `json:"hash"`                // FaceHash of the face's embedding
+	Embedding   []float64 `json:"embedding,omitempty"` // Vision Service embedding
+	PerformerID string    `json:"performer_id"`
+	Similarity  float64   `json:"similarity"`
+	Updated     time.Time `json:"updated"`
+}
+
+// record is one line of the store file: a face, or a performer to forget
+type record struct {
+	Face
+	Forget string `json:"forget,omitempty"` // Performer ID whose faces are dropped
+}
+
+// Store is a persistent index of face match decisions
+type Store struct {
+	mu    sync.RWMutex
+	file  *os.File
+	faces map[string]Face // Keyed by source and hash
+}
+
+// Open loads the store at path, creating it (and its directory) if missing
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+
+	s := &Store{file: file, faces: map[string]Face{}}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue // Torn line from an interrupted write
+		}
+		s.apply(rec)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+	return s, nil
+}
+
+// Close closes the store file
+func (s *Store) Close() error {
+	return s.file.Close()
+}
+
+// Len returns the number of remembered faces
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.faces)
+}
+
+// Get returns the remembered decision for a face of a source
+func (s *Store) Get(source string, hash string) (Face, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	face, ok := s.faces[key(source, hash)]
+	return face, ok
+}
+
+// Put remembers a face decision
+func (s *Store) Put(face Face) error {
+	if face.Updated.IsZero() {
+		face.Updated = time.Now()
+	}
+	return s.append(record{Face: face})
+}
+
+// ForgetPerformer drops every face matched to a performer, e.g. after the
+// performer or its subject is deleted
+func (s *Store) ForgetPerformer(performerID string) error {
+	return s.append(record{Forget: performerID})
+}
+
+// Performers returns the distinct performers remembered for a source's faces
+func (s *Store) Performers(source string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := map[string]bool{}
+	var ids []string
+	for _, face := range s.faces {
+		if face.Source != source || face.PerformerID == "" || seen[face.PerformerID] {
+			continue
+		}
+		seen[face.PerformerID] = true
+		ids = append(ids, face.PerformerID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Nearest returns the remembered face whose embedding is most similar to
+// embedding, if its cosine similarity is at least minSimilarity
+func (s *Store) Nearest(embedding []float64, minSimilarity float64) (Face, float64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var best Face
+	bestSimilarity := -1.0
+	for _, face := range s.faces {
+		if face.PerformerID == "" || len(face.Embedding) != len(embedding) {
+			continue
+		}
+		if similarity := CosineSimilarity(face.Embedding, embedding); similarity > bestSimilarity {
+			best, bestSimilarity = face, similarity
+		}
+	}
+	if bestSimilarity < minSimilarity {
+		return Face{}, 0, false
+	}
+	return best, bestSimilarity, true
+}
+
+// append writes a record and applies it to the index
+func (s *Store) append(rec record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode store record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write store record: %w", err)
+	}
+	s.apply(rec)
+	return nil
+}
+
+// apply updates the index with a record; callers hold the lock or own s
+func (s *Store) apply(rec record) {
+	if rec.Forget != "" {
+		for k, face := range s.faces {
+			if face.PerformerID == rec.Forget {
+				delete(s.faces, k)
+			}
+		}
+		return
+	}
+	s.faces[key(rec.Source, rec.Hash)] = rec.Face
+}
+
+// key identifies a face of a source
+func key(source string, hash string) string {
+	return source + "/" + hash
+}
+
+// FaceHash identifies a face by its embedding, rounded so re-detections of
+// the same face with float noise hash alike
+func FaceHash(embedding []float64) string {
+	h := sha256.New()
+	var buf [8]byte
+	for _, v := range embedding {
+		binary.LittleEndian.PutUint64(buf[:], uint64(int64(math.Round(v*1000))))
+		h.Write(buf[:])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// CosineSimilarity returns the cosine similarity of two equal-length vectors
+// (0 when either is zero)
+func CosineSimilarity(a, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package throttle
+
+// LowerProcessPriority is a no-op where process niceness is unsupported
+func LowerProcessPriority() error {
+	return nil
+}
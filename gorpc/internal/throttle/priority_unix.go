@@ -0,0 +1,14 @@
+//go:build unix
+
+package throttle
+
+import "syscall"
+
+// backgroundNice is the niceness of a background-priority process
+const backgroundNice = 10
+
+// LowerProcessPriority renices the current process so the scheduler favors
+// other work on the host. On Linux the I/O priority follows the niceness.
+func LowerProcessPriority() error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, 0, backgroundNice)
+}
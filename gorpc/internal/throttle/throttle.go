@@ -0,0 +1,120 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Per-Service Throttling
+// ============================================================================
+//
+// Compreface, the Vision Service and Stash have different capacities, so each
+// is throttled independently rather than pausing every task between batches.
+//
+// ============================================================================
+
+// RateLimiter spaces calls evenly so at most perSecond calls start per
+// second. A nil RateLimiter never waits.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewRateLimiter creates a limiter allowing perSecond calls per second.
+// Returns nil (unlimited) when perSecond is not positive.
+func NewRateLimiter(perSecond float64) *RateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &RateLimiter{
+		interval: time.Duration(float64(time.Second) / perSecond),
+	}
+}
+
+// Wait blocks until the next call is allowed
+func (r *RateLimiter) Wait() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	wait := r.next.Sub(now)
+	r.next = r.next.Add(r.interval)
+	r.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// Semaphore bounds the number of operations in flight. A nil Semaphore never
+// blocks.
+type Semaphore chan struct{}
+
+// NewSemaphore creates a semaphore allowing size concurrent holders.
+// Returns nil (unbounded) when size is not positive.
+func NewSemaphore(size int) Semaphore {
+	if size <= 0 {
+		return nil
+	}
+	return make(Semaphore, size)
+}
+
+// Acquire blocks until a slot is free
+func (s Semaphore) Acquire() {
+	if s != nil {
+		s <- struct{}{}
+	}
+}
+
+// TryAcquire takes a slot if one is free, without blocking. A nil Semaphore
+// has no slots to take.
+func (s Semaphore) TryAcquire() bool {
+	if s == nil {
+		return false
+	}
+	select {
+	case s <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release frees a slot taken by Acquire or TryAcquire
+func (s Semaphore) Release() {
+	if s != nil {
+		<-s
+	}
+}
+
+// ForEach calls fn for every item with at most workers calls running at once,
+// returning when all calls have finished. Items are started in order; with
+// one worker (or fewer) they run sequentially on the calling goroutine.
+func ForEach[T any](items []T, workers int, fn func(T)) {
+	if workers <= 1 {
+		for _, item := range items {
+			fn(item)
+		}
+		return
+	}
+
+	slots := NewSemaphore(workers)
+	var wg sync.WaitGroup
+	for _, item := range items {
+		slots.Acquire()
+		wg.Add(1)
+		go func(item T) {
+			defer wg.Done()
+			defer slots.Release()
+			fn(item)
+		}(item)
+	}
+	wg.Wait()
+}
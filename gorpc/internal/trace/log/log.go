@@ -0,0 +1,158 @@
+// Package log wraps the Stash plugin logger, prefixing each line with the
+// active processing trace ID and redacting registered secrets (API keys,
+// tokens). It mirrors the Stash log API so packages only swap the import.
+package log
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	stashlog "github.com/stashapp/stash/pkg/plugin/common/log"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace"
+)
+
+// Logger logs with a fixed trace ID, for work that runs outside the active
+// trace (e.g. queued Stash writes)
+type Logger struct {
+	traceID string
+	active  bool // Follow the active trace instead
+}
+
+// With returns a logger bound to traceID
+func With(traceID string) Logger {
+	return Logger{traceID: traceID}
+}
+
+// Context returns a logger bound to the trace ID carried by ctx, or one
+// following the active trace when ctx carries none
+func Context(ctx context.Context) Logger {
+	if traceID := trace.FromContext(ctx); traceID != "" {
+		return With(traceID)
+	}
+	return Logger{active: true}
+}
+
+// Redacted replaces secrets in log output
+const Redacted = "[REDACTED]"
+
+// minSecretLength keeps short values (e.g. placeholder keys) from redacting
+// unrelated text
+const minSecretLength = 8
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// RegisterSecret redacts secret from all subsequent log output and from
+// strings passed to Scrub. Values shorter than 8 characters are ignored.
+func RegisterSecret(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, s := range secrets {
+		if s == secret {
+			return
+		}
+	}
+	secrets = append(secrets, secret)
+}
+
+// Scrub replaces every registered secret in msg with Redacted
+func Scrub(msg string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for _, secret := range secrets {
+		msg = strings.ReplaceAll(msg, secret, Redacted)
+	}
+	return msg
+}
+
+// prefix prepends the trace ID to a log message and redacts secrets
+func prefix(traceID string, msg string) string {
+	msg = Scrub(msg)
+	if traceID == "" {
+		return msg
+	}
+	return "[" + traceID + "] " + msg
+}
+
+// current prefixes msg with the active trace ID
+func current(msg string) string {
+	return prefix(trace.Current(), msg)
+}
+
+func Trace(args ...interface{}) {
+	stashlog.Trace(current(fmt.Sprint(args...)))
+}
+
+func Tracef(format string, args ...interface{}) {
+	stashlog.Trace(current(fmt.Sprintf(format, args...)))
+}
+
+func Debug(args ...interface{}) {
+	stashlog.Debug(current(fmt.Sprint(args...)))
+}
+
+func Debugf(format string, args ...interface{}) {
+	stashlog.Debug(current(fmt.Sprintf(format, args...)))
+}
+
+func Info(args ...interface{}) {
+	stashlog.Info(current(fmt.Sprint(args...)))
+}
+
+func Infof(format string, args ...interface{}) {
+	stashlog.Info(current(fmt.Sprintf(format, args...)))
+}
+
+func Warn(args ...interface{}) {
+	stashlog.Warn(current(fmt.Sprint(args...)))
+}
+
+func Warnf(format string, args ...interface{}) {
+	stashlog.Warn(current(fmt.Sprintf(format, args...)))
+}
+
+func Error(args ...interface{}) {
+	stashlog.Error(current(fmt.Sprint(args...)))
+}
+
+func Errorf(format string, args ...interface{}) {
+	stashlog.Error(current(fmt.Sprintf(format, args...)))
+}
+
+// Progress reports task progress; progress lines carry no trace
+func Progress(progress float64) {
+	stashlog.Progress(progress)
+}
+
+// prefix prepends the logger's trace ID to a log message
+func (l Logger) prefix(msg string) string {
+	if l.active {
+		return current(msg)
+	}
+	return prefix(l.traceID, msg)
+}
+
+func (l Logger) Tracef(format string, args ...interface{}) {
+	stashlog.Trace(l.prefix(fmt.Sprintf(format, args...)))
+}
+
+func (l Logger) Debugf(format string, args ...interface{}) {
+	stashlog.Debug(l.prefix(fmt.Sprintf(format, args...)))
+}
+
+func (l Logger) Infof(format string, args ...interface{}) {
+	stashlog.Info(l.prefix(fmt.Sprintf(format, args...)))
+}
+
+func (l Logger) Warnf(format string, args ...interface{}) {
+	stashlog.Warn(l.prefix(fmt.Sprintf(format, args...)))
+}
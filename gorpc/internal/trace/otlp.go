@@ -0,0 +1,314 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ============================================================================
+// OpenTelemetry Span Export
+// ============================================================================
+//
+// With otlpEndpoint set, the pipeline stages of each item (fetch, detect,
+// crop, recognize, mutate) are recorded as spans and sent to an OpenTelemetry
+// collector over OTLP/HTTP with JSON encoding, so no SDK is needed. Each item
+// is one OpenTelemetry trace whose ID is derived from the item's trace ID, so
+// the stages of its faces ("img-42-3f9a1c.f2") and the Stash writes queued
+// for it nest under the item's root span even when they end after it. Items
+// processed concurrently time their stages from the trace carried by their
+// context, never from another item's active trace.
+//
+// Spans are buffered and sent in batches of exportBatchSize; the remainder is
+// sent when the task ends. Export failures are reported once at the end and
+// never fail a task.
+//
+// ============================================================================
+
+// Pipeline stages recorded as spans
+const (
+	StageFetch     = "fetch"     // Loading image bytes or scene frames
+	StageDetect    = "detect"    // Face detection (Compreface or a Vision job)
+	StageCrop      = "crop"      // Cropping a face from its image or frame
+	StageRecognize = "recognize" // Matching a face against Compreface subjects
+	StageMutate    = "mutate"    // Writing results to Stash
+)
+
+// exportBatchSize is the number of buffered spans that triggers a send
+const exportBatchSize = 256
+
+// serviceName identifies the plugin's spans in the collector
+const serviceName = "stash-compreface-plugin"
+
+// exporter buffers finished spans and sends them to the collector
+type exporter struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	spans   []otlpSpan
+	err     error // First send failure
+	sending sync.WaitGroup
+}
+
+// active is the exporter in use, nil while export is disabled
+var active atomic.Pointer[exporter]
+
+// EnableExport starts exporting spans to the OTLP/HTTP collector at endpoint,
+// e.g. "http://otel-collector:4318" ("/v1/traces" is appended when missing)
+func EnableExport(endpoint string) {
+	url := strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	active.Store(&exporter{url: url, client: &http.Client{Timeout: 10 * time.Second}})
+}
+
+// StopExport sends the buffered spans and stops exporting. Returns the first
+// failure to send spans since export was enabled.
+func StopExport() error {
+	e := active.Swap(nil)
+	if e == nil {
+		return nil
+	}
+
+	e.mu.Lock()
+	batch := e.spans
+	e.spans = nil
+	e.mu.Unlock()
+	if len(batch) > 0 {
+		e.send(batch)
+	}
+	e.sending.Wait()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// Span is a pipeline stage in progress. A nil Span, returned while export
+// is disabled, ignores every call.
+type Span struct {
+	exporter *exporter
+	traceID  string // Plugin trace ID of the item or face
+	name     string
+	root     bool
+	start    time.Time
+	attrs    []otlpAttribute
+}
+
+// StartSpan starts a span for a stage of the active item
+func StartSpan(stage string) *Span {
+	return StartSpanIn(Current(), stage)
+}
+
+// StartSpanContext starts a span for a stage of the item traced by ctx, or
+// of the active item when ctx carries no trace. Stages that yield the item's
+// turn (e.g. waiting on a Vision job) keep their item this way.
+func StartSpanContext(ctx context.Context, stage string) *Span {
+	if traceID := FromContext(ctx); traceID != "" {
+		return StartSpanIn(traceID, stage)
+	}
+	return StartSpan(stage)
+}
+
+// StartSpanIn starts a span for a stage of the item or face traced by
+// traceID, for work running outside its trace (e.g. queued Stash writes)
+func StartSpanIn(traceID string, stage string) *Span {
+	e := active.Load()
+	if e == nil {
+		return nil
+	}
+	return &Span{exporter: e, traceID: traceID, name: stage, start: time.Now()}
+}
+
+// startRoot starts the root span of an item, named after its kind
+func startRoot(traceID string) *Span {
+	span := StartSpanIn(traceID, "item")
+	if span == nil {
+		return nil
+	}
+	span.root = true
+	if kind, rest, ok := strings.Cut(traceID, "-"); ok {
+		span.name = kind
+		if id, _, ok := strings.Cut(rest, "-"); ok {
+			span.SetAttribute("item.id", id)
+		}
+	}
+	return span
+}
+
+// SetAttribute adds an attribute to the span
+func (s *Span) SetAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, otlpAttribute{Key: key, Value: otlpValue{StringValue: value}})
+}
+
+// End finishes the span, marking it failed when err is not nil
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+
+	item, face, isFace := strings.Cut(s.traceID, ".f")
+	span := otlpSpan{
+		Name:              s.name,
+		Kind:              1, // SPAN_KIND_INTERNAL
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		Attributes:        s.attrs,
+	}
+	if item == "" {
+		// Outside any item: a trace of its own
+		span.TraceID = randomHex(16)
+		span.SpanID = randomHex(8)
+	} else {
+		span.TraceID, span.SpanID = itemIDs(item)
+		if !s.root {
+			span.ParentSpanID = span.SpanID
+			span.SpanID = randomHex(8)
+		}
+		span.Attributes = append(span.Attributes, otlpAttribute{Key: "plugin.trace_id", Value: otlpValue{StringValue: s.traceID}})
+		if isFace {
+			span.Attributes = append(span.Attributes, otlpAttribute{Key: "face.index", Value: otlpValue{StringValue: face}})
+		}
+	}
+	if err != nil {
+		span.Status = &otlpStatus{Code: 2, Message: err.Error()} // STATUS_CODE_ERROR
+	}
+
+	s.exporter.add(span)
+}
+
+// add buffers a finished span, sending a full batch in the background
+func (e *exporter) add(span otlpSpan) {
+	e.mu.Lock()
+	e.spans = append(e.spans, span)
+	if len(e.spans) < exportBatchSize {
+		e.mu.Unlock()
+		return
+	}
+	batch := e.spans
+	e.spans = nil
+	e.sending.Add(1)
+	e.mu.Unlock()
+
+	go func() {
+		defer e.sending.Done()
+		e.send(batch)
+	}()
+}
+
+// send posts a batch of spans to the collector, recording the first failure
+func (e *exporter) send(batch []otlpSpan) {
+	err := e.post(batch)
+	if err != nil {
+		e.mu.Lock()
+		if e.err == nil {
+			e.err = err
+		}
+		e.mu.Unlock()
+	}
+}
+
+// post encodes a batch of spans as an OTLP export request and posts it
+func (e *exporter) post(batch []otlpSpan) error {
+	request := otlpExportRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			{Key: "service.name", Value: otlpValue{StringValue: serviceName}},
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: serviceName},
+			Spans: batch,
+		}},
+	}}}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to export spans: collector returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// itemIDs derives an item's OpenTelemetry trace ID and root span ID from its
+// plugin trace ID, so spans ending after the item still join its trace
+func itemIDs(item string) (traceID string, rootSpanID string) {
+	sum := sha256.Sum256([]byte(item))
+	return hex.EncodeToString(sum[:16]), hex.EncodeToString(sum[16:24])
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// OTLP/HTTP JSON encoding of an export request
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
@@ -0,0 +1,141 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ============================================================================
+// Processing Trace IDs
+// ============================================================================
+//
+// Each media item and each face within it is given a trace ID while it is
+// processed. The active trace ID prefixes every plugin log line and is sent
+// to Compreface and the Vision Service as a request header, so a single
+// misrecognition can be followed across services.
+//
+// A single active trace is tracked process-wide. Items processed
+// concurrently (batch image recognition with maxConcurrency above 1) take
+// turns holding it with Begin, and carry their trace in a context so code
+// waiting on a service for them (e.g. polling a Vision job) can yield the
+// turn to another item and still log and send the right trace.
+//
+// ============================================================================
+
+// Header is the HTTP header carrying the trace ID on service requests
+const Header = "X-Trace-ID"
+
+// current holds the active trace ID (string)
+var current atomic.Value
+
+// turn is held by the concurrent item whose trace is active
+var turn sync.Mutex
+
+// holder is the trace ID of the item holding turn, "" while it is free
+var holder atomic.Value
+
+// contextKey carries a trace ID in a context
+type contextKey struct{}
+
+// New returns a trace ID for a media item, e.g. "img-42-3f9a1c"
+func New(kind string, id string) string {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("%s-%s", kind, id)
+	}
+	return fmt.Sprintf("%s-%s-%s", kind, id, hex.EncodeToString(suffix))
+}
+
+// Face returns the trace ID of the face at index within the item traced by parent
+func Face(parent string, index int) string {
+	return fmt.Sprintf("%s.f%d", parent, index)
+}
+
+// Current returns the active trace ID, or "" outside any trace
+func Current() string {
+	id, _ := current.Load().(string)
+	return id
+}
+
+// Set makes id the active trace ID
+func Set(id string) {
+	current.Store(id)
+}
+
+// Start makes id the active trace ID and, while spans are exported, starts
+// the item's root span. The returned function ends the span and restores the
+// previously active trace.
+func Start(id string) func() {
+	previous := Current()
+	Set(id)
+	root := startRoot(id)
+	return func() {
+		root.End(nil)
+		Set(previous)
+	}
+}
+
+// Begin waits for the turn of the item traced by ctx among items processed
+// concurrently, then makes its trace active and starts its root span. The
+// returned function ends the span and the item's turn.
+func Begin(ctx context.Context) func() {
+	id := FromContext(ctx)
+	turn.Lock()
+	holder.Store(id)
+	end := Start(id)
+	return func() {
+		end()
+		holder.Store("")
+		turn.Unlock()
+	}
+}
+
+// Yield gives up the turn of the item traced by ctx while it waits, e.g. on
+// a Vision job, so other items can run. The returned function waits for the
+// turn again. Yield does nothing unless the item holds the turn.
+func Yield(ctx context.Context) func() {
+	id, _, _ := strings.Cut(FromContext(ctx), ".f")
+	if held, _ := holder.Load().(string); id == "" || held != id {
+		return func() {}
+	}
+	previous := Current()
+	holder.Store("")
+	turn.Unlock()
+	return func() {
+		turn.Lock()
+		holder.Store(id)
+		Set(previous)
+	}
+}
+
+// NewContext returns a copy of ctx carrying trace ID id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the trace ID carried by ctx, or "" when it carries none
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// SetHeader adds the trace ID carried by the request's context, or else the
+// active trace ID, to an outgoing request
+func SetHeader(req *http.Request) {
+	id := FromContext(req.Context())
+	if id == "" {
+		id = Current()
+	}
+	if id != "" {
+		req.Header.Set(Header, id)
+	}
+}
@@ -0,0 +1,77 @@
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// Plugin Version and Tested Compatibility Matrix
+// ============================================================================
+
+// Version and Commit are overridden at build time via -ldflags "-X ..." (see build.sh)
+var (
+	Version = "2.0.0"
+	Commit  = "unknown"
+)
+
+// Service versions the plugin has been tested against, as version prefixes
+var (
+	TestedStashVersions      = []string{"0.28", "0.29"}
+	TestedVisionVersions     = []string{"1.0"}
+	TestedComprefaceVersions = []string{"1.2"}
+)
+
+// IsTested reports whether a service version matches one of the tested
+// version prefixes. A leading "v" is ignored and prefixes match whole
+// components only ("1.2" matches "1.2.0" but not "1.20").
+func IsTested(version string, tested []string) bool {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	for _, prefix := range tested {
+		prefix = strings.TrimPrefix(prefix, "v")
+		if version == prefix || strings.HasPrefix(version, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare compares two dotted versions numerically, returning -1, 0 or 1.
+// A leading "v" and any pre-release/build suffix ("-rc1", "+abc") are ignored.
+func Compare(a, b string) int {
+	pa, pb := parts(a), parts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// parts splits a version into numeric components
+func parts(version string) []int {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+ "); i >= 0 {
+		version = version[:i]
+	}
+
+	var nums []int
+	for _, p := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			break
+		}
+		nums = append(nums, n)
+	}
+	return nums
+}
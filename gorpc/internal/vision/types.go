@@ -1,15 +1,29 @@
 package vision
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"sync"
 	"time"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/throttle"
 )
 
 // VisionServiceClient handles communication with Vision Service
 type VisionServiceClient struct {
 	BaseURL        string
 	FrameServerURL string // Internal frame server container address
+	Token          string // Optional bearer token sent to Vision Service and frame server
 	HTTPClient     *http.Client
+	JobSlots       throttle.Semaphore // Optional bound on jobs in flight, may be shared between clients
+	Context        context.Context    // Cancels requests and job polling once done (nil = never)
+
+	batchUnsupported bool // Set once the frame server rejects batch extraction
+	slotsMu          sync.Mutex
+	heldSlots        map[string]bool // Jobs holding a JobSlots slot until WaitForCompletion returns
 }
 
 // ============================================================================
@@ -28,7 +42,8 @@ type AnalyzeRequest struct {
 
 // Modules configures which analysis modules to enable
 type Modules struct {
-	Faces FacesModule `json:"faces"`
+	Faces     FacesModule      `json:"faces"`
+	Semantics *SemanticsModule `json:"semantics,omitempty"` // Optional; requires the semantics module on the server
 }
 
 // FacesModule configuration
@@ -53,6 +68,43 @@ type FacesParameters struct {
 	Enhancement                  *EnhancementParameters `json:"enhancement,omitempty"`                    // Optional face enhancement settings
 }
 
+// SemanticsModule configuration
+type SemanticsModule struct {
+	Enabled    bool                `json:"enabled"`
+	Parameters SemanticsParameters `json:"parameters,omitempty"`
+}
+
+// SemanticsParameters configures on-screen text recognition (OCR)
+type SemanticsParameters struct {
+	OCR          bool      `json:"ocr"`
+	OCRLanguages []string  `json:"ocr_languages,omitempty"` // e.g. ["en", "ja"], server default: en
+	Timestamps   []float64 `json:"timestamps,omitempty"`    // Frames to read, in seconds; server default: sampled
+}
+
+// DegradedMaxFaces caps the faces extracted by a degraded retry
+const DegradedMaxFaces = 20
+
+// Degraded returns safer parameters for retrying a job the Vision Service
+// failed (e.g. out of memory or a decode error): double the sampling
+// interval, enhancement off and at most DegradedMaxFaces faces
+func (p FacesParameters) Degraded() FacesParameters {
+	degraded := p
+	interval := p.SamplingInterval
+	if interval <= 0 {
+		interval = 2.0 // Server default
+	}
+	degraded.SamplingInterval = interval * 2
+	if degraded.MaxFaces <= 0 || degraded.MaxFaces > DegradedMaxFaces {
+		degraded.MaxFaces = DegradedMaxFaces
+	}
+	if p.Enhancement != nil {
+		enhancement := *p.Enhancement
+		enhancement.Enabled = false
+		degraded.Enhancement = &enhancement
+	}
+	return degraded
+}
+
 // JobResponse represents job submission response
 type JobResponse struct {
 	JobID     string    `json:"job_id"`
@@ -77,14 +129,27 @@ type JobStatus struct {
 
 // AnalyzeResults represents the full analysis results from Vision API
 type AnalyzeResults struct {
-	JobID     string        `json:"job_id"`
-	SourceID  string        `json:"source_id"`
-	Status    string        `json:"status"`
-	Faces     *FacesResults `json:"faces,omitempty"`     // Faces module results
-	Scenes    interface{}   `json:"scenes,omitempty"`    // Scenes module results (not used yet)
-	Semantics interface{}   `json:"semantics,omitempty"` // Semantics module results (Phase 2)
-	Objects   interface{}   `json:"objects,omitempty"`   // Objects module results (Phase 3)
-	Metadata  interface{}   `json:"metadata,omitempty"`  // Processing metadata
+	JobID     string            `json:"job_id"`
+	SourceID  string            `json:"source_id"`
+	Status    string            `json:"status"`
+	Faces     *FacesResults     `json:"faces,omitempty"`     // Faces module results
+	Scenes    interface{}       `json:"scenes,omitempty"`    // Scenes module results (not used yet)
+	Semantics *SemanticsResults `json:"semantics,omitempty"` // Semantics module results
+	Objects   interface{}       `json:"objects,omitempty"`   // Objects module results (Phase 3)
+	Metadata  interface{}       `json:"metadata,omitempty"`  // Processing metadata
+}
+
+// SemanticsResults represents semantics module results
+type SemanticsResults struct {
+	Text []TextDetection `json:"text"` // Text read by OCR
+}
+
+// TextDetection is a line of on-screen text read from a frame
+type TextDetection struct {
+	Timestamp  float64 `json:"timestamp"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+	Language   string  `json:"language,omitempty"`
 }
 
 // FacesResults represents face analysis results from the Faces service
@@ -105,6 +170,36 @@ type VisionFace struct {
 	RepresentativeDetection VisionDetection   `json:"representative_detection"`
 }
 
+// Appearance is a time span in which a face is continuously on screen
+type Appearance struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Appearances merges the face's detection timestamps into spans, starting a
+// new span wherever consecutive detections are more than maxGap seconds apart
+func (f VisionFace) Appearances(maxGap float64) []Appearance {
+	timestamps := make([]float64, 0, len(f.Detections))
+	for _, det := range f.Detections {
+		timestamps = append(timestamps, det.Timestamp)
+	}
+	if len(timestamps) == 0 {
+		timestamps = append(timestamps, f.RepresentativeDetection.Timestamp)
+	}
+	sort.Float64s(timestamps)
+
+	appearances := []Appearance{{Start: timestamps[0], End: timestamps[0]}}
+	for _, ts := range timestamps[1:] {
+		last := &appearances[len(appearances)-1]
+		if ts-last.End > maxGap {
+			appearances = append(appearances, Appearance{Start: ts, End: ts})
+			continue
+		}
+		last.End = ts
+	}
+	return appearances
+}
+
 // Demographics represents age, gender, emotion detection
 type Demographics struct {
 	Age     int    `json:"age"`
@@ -153,6 +248,31 @@ type VisionBoundingBox struct {
 	YMax int `json:"y_max"`
 }
 
+// Scale returns the bounding box with every coordinate multiplied by factor,
+// e.g. to map detections on a downscaled image back to the original
+func (b VisionBoundingBox) Scale(factor float64) VisionBoundingBox {
+	return VisionBoundingBox{
+		XMin: int(math.Round(float64(b.XMin) * factor)),
+		YMin: int(math.Round(float64(b.YMin) * factor)),
+		XMax: int(math.Round(float64(b.XMax) * factor)),
+		YMax: int(math.Round(float64(b.YMax) * factor)),
+	}
+}
+
+// ScaleBoundingBoxes scales the bounding boxes of every face detection in results
+func (r *AnalyzeResults) ScaleBoundingBoxes(factor float64) {
+	if r.Faces == nil {
+		return
+	}
+	for i := range r.Faces.Faces {
+		face := &r.Faces.Faces[i]
+		face.RepresentativeDetection.BBox = face.RepresentativeDetection.BBox.Scale(factor)
+		for j := range face.Detections {
+			face.Detections[j].BBox = face.Detections[j].BBox.Scale(factor)
+		}
+	}
+}
+
 // ResultMetadata provides processing statistics
 type ResultMetadata struct {
 	Source                string                 `json:"source"` // Renamed from video_path (breaking change v1.0.0)
@@ -173,3 +293,142 @@ type EnhancementParameters struct {
 	Model          string  `json:"model,omitempty"`           // Enhancement model: "codeformer" or "gfpgan"
 	FidelityWeight float64 `json:"fidelity_weight,omitempty"` // Fidelity vs quality tradeoff (0.0-1.0, default: 0.5)
 }
+
+// BatchExtractRequest requests multiple frames from one video in a single call
+type BatchExtractRequest struct {
+	VideoPath      string    `json:"video_path"`
+	Timestamps     []float64 `json:"timestamps"`
+	OutputFormat   string    `json:"output_format"`
+	Quality        int       `json:"quality"`
+	Enhance        bool      `json:"enhance,omitempty"`
+	Model          string    `json:"model,omitempty"`
+	FidelityWeight float64   `json:"fidelity_weight,omitempty"`
+}
+
+// BatchExtractResponse holds the frames returned by batch extraction
+type BatchExtractResponse struct {
+	Frames []ExtractedFrame `json:"frames"`
+}
+
+// ExtractedFrame is a single frame from batch extraction
+type ExtractedFrame struct {
+	Timestamp float64 `json:"timestamp"`
+	Data      []byte  `json:"data"` // Base64-encoded JPEG in JSON
+}
+
+// FrameServerHealth is the frame server's health response, including the
+// optional capability fields used to decide whether enhancement is usable
+type FrameServerHealth struct {
+	Status       string                   `json:"status"`
+	Version      string                   `json:"version,omitempty"`
+	GPUAvailable *bool                    `json:"gpu_available,omitempty"`
+	Enhancement  *EnhancementCapabilities `json:"enhancement,omitempty"`
+}
+
+// BackendLoad is the resource use a Vision Service health response reports.
+// Fields are negative when the response does not report them.
+type BackendLoad struct {
+	GPUMemory  float64 // Fraction of GPU memory in use (0-1)
+	QueueDepth int     // Jobs queued or running
+}
+
+// ParseBackendLoad reads GPU memory and queue utilization from a Vision
+// Service health response. Accepted forms are a top-level
+// gpu_memory_utilization (fraction or percent) or gpu_memory_used and
+// gpu_memory_total, the same inside a "gpu" object (memory_utilization,
+// memory_used, memory_total, optionally suffixed _mb), and queue_length,
+// queue_depth or pending_jobs, or "pending" plus "active" inside a "queue"
+// object.
+func ParseBackendLoad(health map[string]interface{}) BackendLoad {
+	load := BackendLoad{GPUMemory: -1, QueueDepth: -1}
+
+	gpu, _ := health["gpu"].(map[string]interface{})
+	if used, ok := healthNumber(health, "gpu_memory_utilization"); ok {
+		load.GPUMemory = used
+	} else if used, ok := healthNumber(gpu, "memory_utilization"); ok {
+		load.GPUMemory = used
+	} else if used, total, ok := healthMemory(health, "gpu_memory_used", "gpu_memory_total"); ok {
+		load.GPUMemory = used / total
+	} else if used, total, ok := healthMemory(gpu, "memory_used", "memory_total"); ok {
+		load.GPUMemory = used / total
+	}
+	if load.GPUMemory > 1 {
+		load.GPUMemory /= 100 // Reported as a percentage
+	}
+
+	if depth, ok := healthNumber(health, "queue_length", "queue_depth", "pending_jobs"); ok {
+		load.QueueDepth = int(depth)
+	} else if queue, ok := health["queue"].(map[string]interface{}); ok {
+		if pending, ok := healthNumber(queue, "pending", "length", "depth"); ok {
+			active, _ := healthNumber(queue, "active", "running")
+			load.QueueDepth = int(pending + active)
+		}
+	}
+	return load
+}
+
+// Pressured reports whether the load is at or above either limit, with the
+// reason. Unreported fields never count as pressure.
+func (l BackendLoad) Pressured(gpuMemoryLimit float64, queueLimit int) (bool, string) {
+	if l.GPUMemory >= 0 && gpuMemoryLimit > 0 && l.GPUMemory >= gpuMemoryLimit {
+		return true, fmt.Sprintf("GPU memory %.0f%% used", l.GPUMemory*100)
+	}
+	if l.QueueDepth >= 0 && queueLimit > 0 && l.QueueDepth >= queueLimit {
+		return true, fmt.Sprintf("%d jobs queued", l.QueueDepth)
+	}
+	return false, ""
+}
+
+// healthNumber returns the first of keys holding a number in a health object
+func healthNumber(obj map[string]interface{}, keys ...string) (float64, bool) {
+	for _, key := range keys {
+		if value, ok := obj[key].(float64); ok {
+			return value, true
+		}
+	}
+	return 0, false
+}
+
+// healthMemory returns the used and total memory reported under usedKey and
+// totalKey, with or without an _mb suffix
+func healthMemory(obj map[string]interface{}, usedKey, totalKey string) (float64, float64, bool) {
+	used, ok := healthNumber(obj, usedKey, usedKey+"_mb")
+	if !ok {
+		return 0, 0, false
+	}
+	total, ok := healthNumber(obj, totalKey, totalKey+"_mb")
+	if !ok || total <= 0 {
+		return 0, 0, false
+	}
+	return used, total, true
+}
+
+// EnhancementCapabilities reports whether face enhancement models are loaded
+type EnhancementCapabilities struct {
+	Available bool     `json:"available"`
+	Models    []string `json:"models,omitempty"`
+}
+
+// SupportsEnhancement reports whether the frame server can run the given
+// enhancement model, with the reason when it cannot. Servers that do not
+// report capabilities are assumed to support enhancement.
+func (h *FrameServerHealth) SupportsEnhancement(model string) (bool, string) {
+	if h.Enhancement != nil {
+		if !h.Enhancement.Available {
+			return false, "enhancement models not available"
+		}
+		if len(h.Enhancement.Models) == 0 {
+			return true, ""
+		}
+		for _, m := range h.Enhancement.Models {
+			if m == model {
+				return true, ""
+			}
+		}
+		return false, fmt.Sprintf("model %s not loaded (available: %v)", model, h.Enhancement.Models)
+	}
+	if h.GPUAvailable != nil && !*h.GPUAvailable {
+		return false, "no GPU available"
+	}
+	return true, ""
+}
@@ -2,13 +2,17 @@ package vision
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"time"
 
-	"github.com/stashapp/stash/pkg/plugin/common/log"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace"
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
 )
 
 // ============================================================================
@@ -47,7 +51,9 @@ func NewVisionServiceClient(baseURL string, frameServerURL string) *VisionServic
 	}
 }
 
-// SubmitJob submits a face recognition job to the Vision Service
+// SubmitJob submits a face recognition job to the Vision Service. When
+// JobSlots is set, it blocks until a slot is free; the slot is held until
+// WaitForCompletion returns for the job.
 func (c *VisionServiceClient) SubmitJob(req AnalyzeRequest) (*JobResponse, error) {
 	url := fmt.Sprintf("%s/vision/analyze", c.BaseURL)
 
@@ -56,16 +62,26 @@ func (c *VisionServiceClient) SubmitJob(req AnalyzeRequest) (*JobResponse, error
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	log.Debugf("Submitting Vision Service job to %s: source_id=%s, source=%s", url, req.SourceID, req.Source)
+	resume := trace.Yield(c.Context)
+	c.JobSlots.Acquire()
+	resume()
+	held := false
+	defer func() {
+		if !held {
+			c.JobSlots.Release()
+		}
+	}()
+
+	c.logger().Debugf("Submitting Vision Service job to %s: source_id=%s, source=%s", url, req.SourceID, req.Source)
 
-	resp, err := c.HTTPClient.Post(url, "application/json", bytes.NewBuffer(body))
+	resp, err := c.post(url, "application/json", bytes.NewBuffer(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to submit job: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusAccepted {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, &StatusError{Op: "unexpected status code", StatusCode: resp.StatusCode}
 	}
 
 	var jobResp JobResponse
@@ -73,22 +89,48 @@ func (c *VisionServiceClient) SubmitJob(req AnalyzeRequest) (*JobResponse, error
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	log.Infof("Vision Service job submitted: job_id=%s", jobResp.JobID)
+	c.logger().Infof("Vision Service job submitted: job_id=%s", jobResp.JobID)
+	c.holdJobSlot(jobResp.JobID)
+	held = true
 	return &jobResp, nil
 }
 
+// holdJobSlot records that jobID holds a JobSlots slot
+func (c *VisionServiceClient) holdJobSlot(jobID string) {
+	if c.JobSlots == nil {
+		return
+	}
+	c.slotsMu.Lock()
+	defer c.slotsMu.Unlock()
+	if c.heldSlots == nil {
+		c.heldSlots = map[string]bool{}
+	}
+	c.heldSlots[jobID] = true
+}
+
+// releaseJobSlot frees the JobSlots slot held by jobID, if any
+func (c *VisionServiceClient) releaseJobSlot(jobID string) {
+	c.slotsMu.Lock()
+	held := c.heldSlots[jobID]
+	delete(c.heldSlots, jobID)
+	c.slotsMu.Unlock()
+	if held {
+		c.JobSlots.Release()
+	}
+}
+
 // GetJobStatus polls job status and progress
 func (c *VisionServiceClient) GetJobStatus(jobID string) (*JobStatus, error) {
 	url := fmt.Sprintf("%s/vision/jobs/%s/status", c.BaseURL, jobID)
 
-	resp, err := c.HTTPClient.Get(url)
+	resp, err := c.get(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get status: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, &StatusError{Op: "unexpected status code", StatusCode: resp.StatusCode}
 	}
 
 	var status JobStatus
@@ -103,7 +145,7 @@ func (c *VisionServiceClient) GetJobStatus(jobID string) (*JobStatus, error) {
 func (c *VisionServiceClient) GetResults(jobID string) (*AnalyzeResults, error) {
 	url := fmt.Sprintf("%s/vision/jobs/%s/results", c.BaseURL, jobID)
 
-	resp, err := c.HTTPClient.Get(url)
+	resp, err := c.get(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get results: %w", err)
 	}
@@ -114,7 +156,7 @@ func (c *VisionServiceClient) GetResults(jobID string) (*AnalyzeResults, error)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, &StatusError{Op: "unexpected status code", StatusCode: resp.StatusCode}
 	}
 
 	var results AnalyzeResults
@@ -125,26 +167,102 @@ func (c *VisionServiceClient) GetResults(jobID string) (*AnalyzeResults, error)
 	return &results, nil
 }
 
+// DefaultJobTimeout is how long WaitForCompletion waits when no timeout is given
+const DefaultJobTimeout = time.Hour
+
+// ErrJobTimeout is returned by WaitForCompletion when a job exceeds its
+// timeout; the job has been cancelled
+var ErrJobTimeout = errors.New("vision job timed out")
+
+// ErrJobFailed is returned by WaitForCompletion when the Vision Service
+// reports the job failed
+var ErrJobFailed = errors.New("vision job failed")
+
+// ErrServiceUnavailable is matched by errors from requests the Vision
+// Service or frame server could not serve: connection failures and 502, 503
+// and 504 responses
+var ErrServiceUnavailable = errors.New("vision service unavailable")
+
+// StatusError is an unexpected HTTP status from the Vision Service or frame
+// server
+type StatusError struct {
+	Op         string // What failed, e.g. "frame extraction failed"
+	StatusCode int
+}
+
+// Error implements error
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: status %d", e.Op, e.StatusCode)
+}
+
+// Is matches ErrServiceUnavailable for gateway and unavailable statuses
+func (e *StatusError) Is(target error) bool {
+	if target != ErrServiceUnavailable {
+		return false
+	}
+	switch e.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// CancelJob asks the Vision Service to stop a job
+// POST /vision/jobs/{job_id}/cancel
+func (c *VisionServiceClient) CancelJob(jobID string) error {
+	url := fmt.Sprintf("%s/vision/jobs/%s/cancel", c.BaseURL, jobID)
+
+	// Sent even once the client's context is done, to stop an abandoned job
+	req, err := http.NewRequestWithContext(context.Background(), "POST", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to cancel job: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to cancel job: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return &StatusError{Op: "unexpected status code", StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
 // WaitForCompletion polls until job completes or fails
 //
 // This method implements the job polling pattern with:
 // - 2-second polling interval
-// - 1-hour timeout
+// - Timeout (DefaultJobTimeout if <= 0), then cancel and ErrJobTimeout
+// - Client context done (task stopped), then cancel and the context's error
 // - Progress callback for UI updates
 // - Detailed status logging
-func (c *VisionServiceClient) WaitForCompletion(jobID string, progressCallback func(float64)) (*AnalyzeResults, error) {
+// - The turn of the item traced by the client's context yielded while waiting
+func (c *VisionServiceClient) WaitForCompletion(jobID string, timeout time.Duration, progressCallback func(float64)) (*AnalyzeResults, error) {
+	defer trace.Yield(c.Context)()
+	defer c.releaseJobSlot(jobID)
+
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
-	timeout := time.After(1 * time.Hour)
+	if timeout <= 0 {
+		timeout = DefaultJobTimeout
+	}
+	deadline := time.After(timeout)
+	ctx := c.requestContext()
 
-	log.Infof("Waiting for Vision Service job %s to complete", jobID)
+	c.logger().Infof("Waiting for Vision Service job %s to complete", jobID)
 
 	for {
 		select {
 		case <-ticker.C:
 			status, err := c.GetJobStatus(jobID)
 			if err != nil {
+				if ctx.Err() != nil {
+					return nil, c.abandonJob(jobID, ctx.Err())
+				}
 				return nil, err
 			}
 
@@ -155,59 +273,171 @@ func (c *VisionServiceClient) WaitForCompletion(jobID string, progressCallback f
 
 			// Log detailed status
 			if status.Stage != "" {
-				log.Debugf("Job %s: status=%s, stage=%s, progress=%.1f%%, message=%s",
+				c.logger().Debugf("Job %s: status=%s, stage=%s, progress=%.1f%%, message=%s",
 					jobID, status.Status, status.Stage, status.Progress*100, status.Message)
 			} else {
-				log.Debugf("Job %s: status=%s, progress=%.1f%%",
+				c.logger().Debugf("Job %s: status=%s, progress=%.1f%%",
 					jobID, status.Status, status.Progress*100)
 			}
 
 			// Check terminal status
 			switch status.Status {
 			case "completed":
-				log.Infof("Vision Service job %s completed successfully", jobID)
+				c.logger().Infof("Vision Service job %s completed successfully", jobID)
 				if status.Summary != nil {
-					log.Infof("Summary: %+v", status.Summary)
+					c.logger().Infof("Summary: %+v", status.Summary)
 				}
 				return c.GetResults(jobID)
 
 			case "failed":
-				return nil, fmt.Errorf("job failed: %s", status.Error)
+				return nil, fmt.Errorf("%w: %s", ErrJobFailed, status.Error)
+			}
+
+		case <-deadline:
+			if err := c.CancelJob(jobID); err != nil {
+				c.logger().Warnf("Failed to cancel timed out Vision Service job %s: %v", jobID, err)
 			}
+			return nil, fmt.Errorf("%w after %s", ErrJobTimeout, timeout)
 
-		case <-timeout:
-			return nil, fmt.Errorf("job timeout after 1 hour")
+		case <-ctx.Done():
+			return nil, c.abandonJob(jobID, ctx.Err())
 		}
 	}
 }
 
+// abandonJob cancels a job whose caller stopped waiting for it, returning
+// the reason as the job's error
+func (c *VisionServiceClient) abandonJob(jobID string, reason error) error {
+	c.logger().Infof("Cancelling Vision Service job %s: %v", jobID, reason)
+	if err := c.CancelJob(jobID); err != nil {
+		c.logger().Warnf("Failed to cancel Vision Service job %s: %v", jobID, err)
+	}
+	return fmt.Errorf("vision job %s abandoned: %w", jobID, reason)
+}
+
 // HealthCheck checks if Vision Service is available and healthy
 func (c *VisionServiceClient) HealthCheck() error {
+	health, err := c.Health()
+	if err != nil {
+		return err
+	}
+
+	c.logger().Debugf("Vision Service health: %+v", health)
+	return nil
+}
+
+// Health retrieves the Vision Service health response (status, version, ...)
+func (c *VisionServiceClient) Health() (map[string]interface{}, error) {
 	url := fmt.Sprintf("%s/vision/health", c.BaseURL)
 
-	resp, err := c.HTTPClient.Get(url)
+	resp, err := c.get(url)
 	if err != nil {
-		return fmt.Errorf("health check failed: %w", err)
+		return nil, fmt.Errorf("health check failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("service unhealthy: status %d", resp.StatusCode)
+		return nil, &StatusError{Op: "service unhealthy", StatusCode: resp.StatusCode}
 	}
 
 	var health map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
-		return fmt.Errorf("failed to decode health response: %w", err)
+		return nil, fmt.Errorf("failed to decode health response: %w", err)
 	}
 
-	log.Debugf("Vision Service health: %+v", health)
-	return nil
+	return health, nil
+}
+
+// FrameServerHealth retrieves the frame server health and capabilities
+func (c *VisionServiceClient) FrameServerHealth() (*FrameServerHealth, error) {
+	url := fmt.Sprintf("%s/health", c.FrameServerURL)
+
+	resp, err := c.get(url)
+	if err != nil {
+		return nil, fmt.Errorf("frame server health check failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Op: "frame server unhealthy", StatusCode: resp.StatusCode}
+	}
+
+	var health FrameServerHealth
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return nil, fmt.Errorf("failed to decode frame server health response: %w", err)
+	}
+
+	return &health, nil
 }
 
 // ============================================================================
 // Helper Methods
 // ============================================================================
 
+// requestContext returns the client's context, or a context that is never done
+func (c *VisionServiceClient) requestContext() context.Context {
+	if c.Context == nil {
+		return context.Background()
+	}
+	return c.Context
+}
+
+// logger logs with the trace carried by the client's context, or the active
+// trace
+func (c *VisionServiceClient) logger() log.Logger {
+	return log.Context(c.Context)
+}
+
+// WithContext returns a copy of the client using ctx, sharing its HTTP client
+// and job slots. A job is waited on through the client that submitted it.
+func (c *VisionServiceClient) WithContext(ctx context.Context) *VisionServiceClient {
+	return &VisionServiceClient{
+		BaseURL:          c.BaseURL,
+		FrameServerURL:   c.FrameServerURL,
+		Token:            c.Token,
+		HTTPClient:       c.HTTPClient,
+		JobSlots:         c.JobSlots,
+		Context:          ctx,
+		batchUnsupported: c.batchUnsupported,
+	}
+}
+
+// get issues a GET request, authenticated when a token is configured
+func (c *VisionServiceClient) get(url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(c.requestContext(), "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req)
+}
+
+// post issues a POST request, authenticated when a token is configured
+func (c *VisionServiceClient) post(url string, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(c.requestContext(), "POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.do(req)
+}
+
+// do sends the request, adding the Authorization header when a token is
+// configured and the active processing trace ID
+func (c *VisionServiceClient) do(req *http.Request) (*http.Response, error) {
+	trace.SetHeader(req)
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrServiceUnavailable, err)
+	}
+	return resp, nil
+}
+
 // BuildAnalyzeRequest creates a standard request for face recognition
 func BuildAnalyzeRequest(videoPath, sceneID string, facesParameters FacesParameters) AnalyzeRequest {
 	return AnalyzeRequest{
@@ -223,6 +453,26 @@ func BuildAnalyzeRequest(videoPath, sceneID string, facesParameters FacesParamet
 	}
 }
 
+// BuildOCRRequest creates a request reading on-screen text at the given
+// frames of a video with the semantics module, without face analysis
+func BuildOCRRequest(videoPath, sceneID string, languages []string, timestamps []float64) AnalyzeRequest {
+	return AnalyzeRequest{
+		Source:         videoPath,
+		SourceID:       sceneID,
+		ProcessingMode: "sequential",
+		Modules: Modules{
+			Semantics: &SemanticsModule{
+				Enabled: true,
+				Parameters: SemanticsParameters{
+					OCR:          true,
+					OCRLanguages: languages,
+					Timestamps:   timestamps,
+				},
+			},
+		},
+	}
+}
+
 // IsVisionServiceAvailable checks if Vision Service is configured and reachable
 func IsVisionServiceAvailable(baseURL string, frameServerURL string) bool {
 	if baseURL == "" || frameServerURL == "" {
@@ -263,16 +513,16 @@ func (c *VisionServiceClient) ExtractFrame(videoPath string, timestamp float64,
 		frameType = " enhanced"
 	}
 	url := fmt.Sprintf("%s?%s", baseUrl, params.Encode())
-	log.Debugf("Extracting%s frame from: %s ", frameType, url)
+	c.logger().Debugf("Extracting%s frame from: %s ", frameType, url)
 
-	resp, err := c.HTTPClient.Get(url)
+	resp, err := c.get(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract frame: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("frame extraction failed: status %d", resp.StatusCode)
+		return nil, &StatusError{Op: "frame extraction failed", StatusCode: resp.StatusCode}
 	}
 
 	// Read frame bytes
@@ -282,6 +532,72 @@ func (c *VisionServiceClient) ExtractFrame(videoPath string, timestamp float64,
 		return nil, fmt.Errorf("failed to read frame: %w", err)
 	}
 
-	log.Tracef("Frame extracted: %d bytes", buf.Len())
+	c.logger().Tracef("Frame extracted: %d bytes", buf.Len())
 	return buf.Bytes(), nil
 }
+
+// ErrBatchExtractionUnsupported is returned when the frame server has no
+// batch extraction endpoint; callers fall back to ExtractFrame
+var ErrBatchExtractionUnsupported = errors.New("frame server does not support batch extraction")
+
+// FrameKey identifies an extracted frame by timestamp, at the precision used
+// for frame server requests
+func FrameKey(timestamp float64) string {
+	return fmt.Sprintf("%.2f", timestamp)
+}
+
+// BatchExtractFrames extracts several frames from one video in a single request
+// Uses the frame-server's /extract-frames endpoint. Returns frames keyed by
+// FrameKey(timestamp), or ErrBatchExtractionUnsupported if the endpoint is missing.
+func (c *VisionServiceClient) BatchExtractFrames(videoPath string, timestamps []float64, enhancement *EnhancementParameters) (map[string][]byte, error) {
+	if c.batchUnsupported {
+		return nil, ErrBatchExtractionUnsupported
+	}
+
+	request := BatchExtractRequest{
+		VideoPath:    videoPath,
+		Timestamps:   timestamps,
+		OutputFormat: "jpeg",
+		Quality:      95,
+	}
+	if enhancement != nil && enhancement.Enabled {
+		request.Enhance = true
+		request.Model = enhancement.Model
+		request.FidelityWeight = enhancement.FidelityWeight
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/extract-frames", c.FrameServerURL)
+	c.logger().Debugf("Extracting %d frames (enhanced=%v) from %s via %s", len(timestamps), request.Enhance, videoPath, url)
+
+	resp, err := c.post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract frames: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		c.batchUnsupported = true
+		return nil, ErrBatchExtractionUnsupported
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Op: "batch frame extraction failed", StatusCode: resp.StatusCode}
+	}
+
+	var result BatchExtractResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode frames: %w", err)
+	}
+
+	frames := make(map[string][]byte, len(result.Frames))
+	for _, frame := range result.Frames {
+		frames[FrameKey(frame.Timestamp)] = frame.Data
+	}
+
+	c.logger().Tracef("Batch extracted %d/%d frames", len(frames), len(timestamps))
+	return frames, nil
+}
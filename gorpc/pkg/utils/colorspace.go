@@ -0,0 +1,463 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg" // Register JPEG format
+	_ "image/png"  // Register PNG format
+	"io"
+	"math"
+	"sort"
+)
+
+// ============================================================================
+// Color Space Normalization
+// ============================================================================
+//
+// Face crops sent to Compreface must be sRGB. Go's decoders ignore embedded
+// ICC profiles, so wide-gamut images (Adobe RGB, Display P3, ProPhoto) come
+// out desaturated, and CMYK JPEGs decode to an image.CMYK that some encoders
+// handle poorly.
+//
+// RGB matrix/TRC profiles (the common case for cameras and phones) are
+// converted to sRGB exactly. CMYK images are converted to RGB with the
+// standard naive transform; LUT-based CMYK profiles are not applied.
+// ============================================================================
+
+// xyzD50ToLinearSRGB converts PCS (D50) XYZ to linear sRGB (Bradford-adapted)
+var xyzD50ToLinearSRGB = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+// iccProfile holds the parts of an RGB matrix/TRC ICC profile needed for conversion
+type iccProfile struct {
+	colorSpace string
+	matrix     [3][3]float64   // Linear RGB -> XYZ (D50), columns are rXYZ, gXYZ, bXYZ
+	trc        [3][256]float64 // Per-channel 8-bit -> linear lookup
+	hasMatrix  bool
+}
+
+// DecodeImageSRGB decodes image bytes and converts the result to sRGB using
+// any embedded ICC profile. CMYK images are converted to RGB.
+func DecodeImageSRGB(data []byte) (image.Image, string, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, format, err
+	}
+	return ConvertToSRGB(img, ExtractICCProfile(data)), format, nil
+}
+
+// ConvertToSRGB converts img to sRGB using the given ICC profile (may be nil).
+// Returns img unchanged if no conversion is needed or the profile is unsupported.
+func ConvertToSRGB(img image.Image, icc []byte) image.Image {
+	if _, ok := img.(*image.CMYK); ok {
+		return toRGBA(img)
+	}
+	if len(icc) == 0 {
+		return img
+	}
+
+	profile, ok := parseICCProfile(icc)
+	if !ok || profile.colorSpace != "RGB " || !profile.hasMatrix {
+		return img
+	}
+	if profile.isSRGB() {
+		return img
+	}
+
+	return profile.transform(toRGBA(img))
+}
+
+// IsSRGBJPEG reports whether data is a JPEG that is already sRGB: not CMYK,
+// and without an embedded ICC profile other than sRGB. Only the headers are
+// read, so callers can skip decoding and re-encoding compatible images.
+func IsSRGBJPEG(data []byte) bool {
+	if len(data) < 3 || data[0] != 0xFF || data[1] != 0xD8 || data[2] != 0xFF {
+		return false
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != "jpeg" || cfg.ColorModel == color.CMYKModel {
+		return false
+	}
+
+	icc := ExtractICCProfile(data)
+	if len(icc) == 0 {
+		return true
+	}
+	profile, ok := parseICCProfile(icc)
+	return ok && profile.colorSpace == "RGB " && profile.hasMatrix && profile.isSRGB()
+}
+
+// ExtractICCProfile returns the embedded ICC profile from JPEG or PNG bytes,
+// or nil if none is present
+func ExtractICCProfile(data []byte) []byte {
+	switch {
+	case len(data) > 2 && data[0] == 0xFF && data[1] == 0xD8:
+		return extractJPEGICCProfile(data)
+	case len(data) > 8 && bytes.Equal(data[:8], []byte("\x89PNG\r\n\x1a\n")):
+		return extractPNGICCProfile(data)
+	default:
+		return nil
+	}
+}
+
+// extractJPEGICCProfile reassembles ICC_PROFILE chunks from JPEG APP2 segments
+func extractJPEGICCProfile(data []byte) []byte {
+	iccHeader := []byte("ICC_PROFILE\x00")
+	chunks := map[int][]byte{}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			pos++ // Fill byte
+			continue
+		}
+		if marker == 0xD9 || marker == 0xDA {
+			break // End of image or start of scan
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			pos += 2 // Standalone marker
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil
+		}
+		segment := data[pos+4 : pos+2+length]
+
+		if marker == 0xE2 && len(segment) > len(iccHeader)+2 && bytes.Equal(segment[:len(iccHeader)], iccHeader) {
+			seq := int(segment[len(iccHeader)])
+			chunks[seq] = segment[len(iccHeader)+2:]
+		}
+
+		pos += 2 + length
+	}
+
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	seqs := make([]int, 0, len(chunks))
+	for seq := range chunks {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+
+	var profile []byte
+	for _, seq := range seqs {
+		profile = append(profile, chunks[seq]...)
+	}
+	return profile
+}
+
+// extractPNGICCProfile decompresses the iCCP chunk from PNG bytes
+func extractPNGICCProfile(data []byte) []byte {
+	pos := 8
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		if length < 0 || pos+12+length > len(data) {
+			return nil
+		}
+		chunk := data[pos+8 : pos+8+length]
+
+		switch chunkType {
+		case "iCCP":
+			// Profile name (null-terminated), compression method, zlib data
+			nameEnd := bytes.IndexByte(chunk, 0)
+			if nameEnd < 0 || nameEnd+2 > len(chunk) {
+				return nil
+			}
+			reader, err := zlib.NewReader(bytes.NewReader(chunk[nameEnd+2:]))
+			if err != nil {
+				return nil
+			}
+			defer reader.Close()
+			profile, err := io.ReadAll(reader)
+			if err != nil {
+				return nil
+			}
+			return profile
+		case "IDAT", "IEND":
+			return nil
+		}
+
+		pos += 12 + length
+	}
+	return nil
+}
+
+// parseICCProfile parses the colour space, colorant matrix and tone curves
+// from an ICC profile
+func parseICCProfile(icc []byte) (*iccProfile, bool) {
+	if len(icc) < 132 {
+		return nil, false
+	}
+
+	profile := &iccProfile{colorSpace: string(icc[16:20])}
+	if string(icc[20:24]) != "XYZ " {
+		return profile, true // Lab PCS profiles are LUT-based; not supported
+	}
+
+	tagCount := int(binary.BigEndian.Uint32(icc[128:]))
+	tags := map[string][]byte{}
+	for i := 0; i < tagCount; i++ {
+		entry := 132 + i*12
+		if entry+12 > len(icc) {
+			return nil, false
+		}
+		sig := string(icc[entry : entry+4])
+		offset := int(binary.BigEndian.Uint32(icc[entry+4:]))
+		size := int(binary.BigEndian.Uint32(icc[entry+8:]))
+		if offset < 0 || size < 0 || offset+size > len(icc) {
+			continue
+		}
+		tags[sig] = icc[offset : offset+size]
+	}
+
+	// Colorant matrix
+	colorants := []string{"rXYZ", "gXYZ", "bXYZ"}
+	profile.hasMatrix = true
+	for col, sig := range colorants {
+		xyz, ok := parseXYZTag(tags[sig])
+		if !ok {
+			profile.hasMatrix = false
+			break
+		}
+		for row := 0; row < 3; row++ {
+			profile.matrix[row][col] = xyz[row]
+		}
+	}
+
+	// Tone reproduction curves
+	for ch, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+		curve, ok := parseCurveTag(tags[sig])
+		for i := 0; i < 256; i++ {
+			v := float64(i) / 255.0
+			if ok {
+				profile.trc[ch][i] = curve(v)
+			} else {
+				profile.trc[ch][i] = srgbToLinear(v)
+			}
+		}
+	}
+
+	return profile, true
+}
+
+// parseXYZTag parses an XYZType tag
+func parseXYZTag(tag []byte) ([3]float64, bool) {
+	var xyz [3]float64
+	if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+		return xyz, false
+	}
+	for i := 0; i < 3; i++ {
+		xyz[i] = s15Fixed16(tag[8+i*4:])
+	}
+	return xyz, true
+}
+
+// parseCurveTag parses a curveType or parametricCurveType tag into a
+// function mapping encoded [0,1] to linear [0,1]
+func parseCurveTag(tag []byte) (func(float64) float64, bool) {
+	if len(tag) < 12 {
+		return nil, false
+	}
+
+	switch string(tag[:4]) {
+	case "curv":
+		count := int(binary.BigEndian.Uint32(tag[8:]))
+		switch {
+		case count == 0:
+			return func(v float64) float64 { return v }, true
+		case count == 1 && len(tag) >= 14:
+			gamma := float64(binary.BigEndian.Uint16(tag[12:])) / 256.0
+			return func(v float64) float64 { return math.Pow(v, gamma) }, true
+		case len(tag) >= 12+count*2:
+			table := make([]float64, count)
+			for i := range table {
+				table[i] = float64(binary.BigEndian.Uint16(tag[12+i*2:])) / 65535.0
+			}
+			return func(v float64) float64 {
+				pos := v * float64(count-1)
+				i := int(pos)
+				if i >= count-1 {
+					return table[count-1]
+				}
+				frac := pos - float64(i)
+				return table[i]*(1-frac) + table[i+1]*frac
+			}, true
+		}
+
+	case "para":
+		funcType := int(binary.BigEndian.Uint16(tag[8:]))
+		paramCounts := []int{1, 3, 4, 5, 7}
+		if funcType >= len(paramCounts) || len(tag) < 12+paramCounts[funcType]*4 {
+			return nil, false
+		}
+		p := make([]float64, 7)
+		for i := 0; i < paramCounts[funcType]; i++ {
+			p[i] = s15Fixed16(tag[12+i*4:])
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+
+		return func(v float64) float64 {
+			switch funcType {
+			case 0:
+				return math.Pow(v, g)
+			case 1:
+				if v >= -b/a {
+					return math.Pow(a*v+b, g)
+				}
+				return 0
+			case 2:
+				if v >= -b/a {
+					return math.Pow(a*v+b, g) + c
+				}
+				return c
+			case 3:
+				if v >= d {
+					return math.Pow(a*v+b, g)
+				}
+				return c * v
+			default:
+				if v >= d {
+					return math.Pow(a*v+b, g) + e
+				}
+				return c*v + f
+			}
+		}, true
+	}
+
+	return nil, false
+}
+
+// isSRGB reports whether the profile is (close enough to) sRGB that no
+// conversion is needed
+func (p *iccProfile) isSRGB() bool {
+	combined := multiplyMatrix(xyzD50ToLinearSRGB, p.matrix)
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			expected := 0.0
+			if row == col {
+				expected = 1.0
+			}
+			if math.Abs(combined[row][col]-expected) > 0.02 {
+				return false
+			}
+		}
+	}
+
+	for ch := 0; ch < 3; ch++ {
+		for i := 0; i < 256; i += 15 {
+			if math.Abs(p.trc[ch][i]-srgbToLinear(float64(i)/255.0)) > 0.01 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// transform converts an RGBA image in the profile's colour space to sRGB in place
+func (p *iccProfile) transform(img *image.RGBA) *image.RGBA {
+	m := multiplyMatrix(xyzD50ToLinearSRGB, p.matrix)
+
+	// Linear -> sRGB encoding lookup (12-bit precision)
+	const encodeSteps = 4096
+	var encode [encodeSteps + 1]uint8
+	for i := range encode {
+		encode[i] = uint8(math.Round(linearToSRGB(float64(i)/encodeSteps) * 255))
+	}
+	toByte := func(v float64) uint8 {
+		if v <= 0 {
+			return 0
+		}
+		if v >= 1 {
+			return 255
+		}
+		return encode[int(v*encodeSteps+0.5)]
+	}
+
+	pix := img.Pix
+	for i := 0; i+3 < len(pix); i += 4 {
+		alpha := pix[i+3]
+		if alpha == 0 {
+			continue
+		}
+
+		r, g, b := pix[i], pix[i+1], pix[i+2]
+		if alpha != 255 {
+			// Un-premultiply
+			r = uint8(uint16(r) * 255 / uint16(alpha))
+			g = uint8(uint16(g) * 255 / uint16(alpha))
+			b = uint8(uint16(b) * 255 / uint16(alpha))
+		}
+
+		lr, lg, lb := p.trc[0][r], p.trc[1][g], p.trc[2][b]
+		nr := toByte(m[0][0]*lr + m[0][1]*lg + m[0][2]*lb)
+		ng := toByte(m[1][0]*lr + m[1][1]*lg + m[1][2]*lb)
+		nb := toByte(m[2][0]*lr + m[2][1]*lg + m[2][2]*lb)
+
+		if alpha != 255 {
+			nr = uint8(uint16(nr) * uint16(alpha) / 255)
+			ng = uint8(uint16(ng) * uint16(alpha) / 255)
+			nb = uint8(uint16(nb) * uint16(alpha) / 255)
+		}
+		pix[i], pix[i+1], pix[i+2] = nr, ng, nb
+	}
+
+	return img
+}
+
+// toRGBA returns a copy of img as *image.RGBA
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(bounds)
+	draw.Draw(rgba, bounds, img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// multiplyMatrix returns a × b
+func multiplyMatrix(a, b [3][3]float64) [3][3]float64 {
+	var result [3][3]float64
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			for k := 0; k < 3; k++ {
+				result[row][col] += a[row][k] * b[k][col]
+			}
+		}
+	}
+	return result
+}
+
+// s15Fixed16 decodes an ICC s15Fixed16Number
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536.0
+}
+
+// srgbToLinear applies the sRGB electro-optical transfer function
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB applies the inverse sRGB transfer function
+func linearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image/jpeg"
+	"path/filepath"
+	"strings"
+)
+
+// ============================================================================
+// RAW Preview Extraction
+// ============================================================================
+//
+// Camera RAW files (DNG, CR2, NEF, ARW, ...) cannot be decoded, but nearly
+// all of them embed a full- or near-full-size JPEG preview rendered by the
+// camera. Most are TIFF containers whose IFDs point at the preview (JPEG
+// interchange tags, JPEG-compressed strips, or sub-IFDs); Fujifilm RAF files
+// record its offset in their header. Containers that fit neither are scanned
+// for JPEG start markers. Of all candidates, the largest one that decodes as
+// a baseline or progressive JPEG wins.
+//
+// ============================================================================
+
+// rawExtensions are the file extensions of camera RAW formats
+var rawExtensions = map[string]bool{
+	".dng": true, ".cr2": true, ".cr3": true, ".crw": true, ".nef": true,
+	".nrw": true, ".arw": true, ".srf": true, ".sr2": true, ".orf": true,
+	".rw2": true, ".raf": true, ".pef": true, ".srw": true, ".erf": true,
+	".kdc": true, ".dcr": true, ".3fr": true, ".iiq": true, ".rwl": true,
+	".mrw": true, ".x3f": true,
+}
+
+// TIFF tags used to locate previews
+const (
+	tiffTagCompression      = 0x0103
+	tiffTagStripOffsets     = 0x0111
+	tiffTagOrientation      = 0x0112
+	tiffTagStripByteCounts  = 0x0117
+	tiffTagSubIFDs          = 0x014A
+	tiffTagJPEGOffset       = 0x0201
+	tiffTagJPEGLength       = 0x0202
+	tiffCompressionOldJPEG  = 6
+	tiffCompressionJPEG     = 7
+	maxRawIFDs              = 64 // Guards against IFD cycles in corrupt files
+	maxRawScannedCandidates = 32
+)
+
+// ErrNoRawPreview is returned when a RAW file holds no decodable JPEG preview
+var ErrNoRawPreview = errors.New("no decodable JPEG preview found")
+
+// IsRawImagePath reports whether a path has a camera RAW file extension
+func IsRawImagePath(path string) bool {
+	return rawExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// ExtractRawPreview returns the largest embedded JPEG preview of a RAW file
+// and the EXIF orientation (1-8) of the RAW image, 1 when unrecorded. The
+// preview itself is stored unrotated, so callers apply the orientation.
+// Previews found by scanning may be followed by unrelated trailing bytes,
+// which JPEG decoders ignore.
+func ExtractRawPreview(data []byte) ([]byte, int, error) {
+	var candidates [][]byte
+	orientation := 1
+
+	switch {
+	case bytes.HasPrefix(data, []byte("FUJIFILMCCD-RAW")):
+		if len(data) >= 92 {
+			offset := binary.BigEndian.Uint32(data[84:88])
+			length := binary.BigEndian.Uint32(data[88:92])
+			if preview := rawSlice(data, offset, length); preview != nil {
+				candidates = append(candidates, preview)
+			}
+		}
+	case bytes.HasPrefix(data, []byte("II")) || bytes.HasPrefix(data, []byte("MM")):
+		candidates, orientation = tiffPreviews(data)
+	}
+
+	best, bestArea := bestJPEG(candidates)
+	if best == nil {
+		best, bestArea = bestJPEG(scanJPEGs(data))
+	}
+	if best == nil || bestArea == 0 {
+		return nil, 1, ErrNoRawPreview
+	}
+	return best, orientation, nil
+}
+
+// bestJPEG returns the candidate decoding to the most pixels and its area
+func bestJPEG(candidates [][]byte) ([]byte, int) {
+	var best []byte
+	bestArea := 0
+	for _, candidate := range candidates {
+		config, err := jpeg.DecodeConfig(bytes.NewReader(candidate))
+		if err != nil {
+			continue // Lossless RAW data or a damaged preview
+		}
+		if area := config.Width * config.Height; area > bestArea {
+			best, bestArea = candidate, area
+		}
+	}
+	return best, bestArea
+}
+
+// scanJPEGs returns the data from each JPEG start marker to the end of data
+func scanJPEGs(data []byte) [][]byte {
+	var candidates [][]byte
+	marker := []byte{0xFF, 0xD8, 0xFF}
+	for i := 0; len(candidates) < maxRawScannedCandidates; {
+		next := bytes.Index(data[i:], marker)
+		if next < 0 {
+			break
+		}
+		candidates = append(candidates, data[i+next:])
+		i += next + len(marker)
+	}
+	return candidates
+}
+
+// tiffPreviews walks the IFD chains and sub-IFDs of a TIFF-based RAW file,
+// returning its JPEG preview candidates and IFD0's orientation. The magic
+// number is not checked, as several vendors (ORF, RW2) replace it.
+func tiffPreviews(data []byte) ([][]byte, int) {
+	if len(data) < 8 {
+		return nil, 1
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if data[0] == 'M' {
+		order = binary.BigEndian
+	}
+
+	var candidates [][]byte
+	orientation := 1
+	visited := map[uint32]bool{}
+	queue := []uint32{order.Uint32(data[4:8])}
+	for len(queue) > 0 && len(visited) < maxRawIFDs {
+		offset := queue[0]
+		queue = queue[1:]
+		if offset == 0 || visited[offset] || uint64(offset)+2 > uint64(len(data)) {
+			continue
+		}
+		visited[offset] = true
+
+		count := int(order.Uint16(data[offset:]))
+		entries := uint64(offset) + 2
+		if entries+uint64(count)*12+4 > uint64(len(data)) {
+			continue
+		}
+
+		var jpegOffset, jpegLength, compression uint32
+		var stripOffsets, stripCounts []uint32
+		for i := range count {
+			entry := data[entries+uint64(i)*12:]
+			tag := order.Uint16(entry[0:2])
+			values := tiffValues(data, order, entry)
+			if len(values) == 0 {
+				continue
+			}
+			switch tag {
+			case tiffTagJPEGOffset:
+				jpegOffset = values[0]
+			case tiffTagJPEGLength:
+				jpegLength = values[0]
+			case tiffTagCompression:
+				compression = values[0]
+			case tiffTagStripOffsets:
+				stripOffsets = values
+			case tiffTagStripByteCounts:
+				stripCounts = values
+			case tiffTagSubIFDs:
+				queue = append(queue, values...)
+			case tiffTagOrientation:
+				if len(visited) == 1 && values[0] >= 1 && values[0] <= 8 {
+					orientation = int(values[0])
+				}
+			}
+		}
+
+		if preview := rawSlice(data, jpegOffset, jpegLength); preview != nil {
+			candidates = append(candidates, preview)
+		}
+		if (compression == tiffCompressionOldJPEG || compression == tiffCompressionJPEG) &&
+			len(stripOffsets) == 1 && len(stripCounts) == 1 {
+			if preview := rawSlice(data, stripOffsets[0], stripCounts[0]); preview != nil {
+				candidates = append(candidates, preview)
+			}
+		}
+
+		queue = append(queue, order.Uint32(data[entries+uint64(count)*12:]))
+	}
+	return candidates, orientation
+}
+
+// tiffValues returns the SHORT, LONG or IFD values of an IFD entry, or nil
+// for other types and out-of-range data
+func tiffValues(data []byte, order binary.ByteOrder, entry []byte) []uint32 {
+	kind := order.Uint16(entry[2:4])
+	count := uint64(order.Uint32(entry[4:8]))
+	var size uint64
+	switch kind {
+	case 3: // SHORT
+		size = 2
+	case 4, 13: // LONG, IFD
+		size = 4
+	default:
+		return nil
+	}
+	if count == 0 || count > 1024 {
+		return nil
+	}
+
+	raw := entry[8:12]
+	if count*size > 4 {
+		offset := uint64(order.Uint32(entry[8:12]))
+		if offset+count*size > uint64(len(data)) {
+			return nil
+		}
+		raw = data[offset : offset+count*size]
+	}
+
+	values := make([]uint32, count)
+	for i := range values {
+		if size == 2 {
+			values[i] = uint32(order.Uint16(raw[uint64(i)*2:]))
+		} else {
+			values[i] = order.Uint32(raw[uint64(i)*4:])
+		}
+	}
+	return values
+}
+
+// rawSlice returns data[offset:offset+length] when in range and non-empty
+func rawSlice(data []byte, offset, length uint32) []byte {
+	end := uint64(offset) + uint64(length)
+	if length == 0 || end > uint64(len(data)) {
+		return nil
+	}
+	return data[offset:end]
+}
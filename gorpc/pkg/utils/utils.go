@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"math"
+	"time"
+
 	graphql "github.com/hasura/go-graphql-client"
 
 	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
@@ -40,6 +43,19 @@ func DeduplicateIDs(ids []graphql.ID) []graphql.ID {
 	return result
 }
 
+// DeduplicateStrings removes duplicate strings from a slice
+func DeduplicateStrings(values []string) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
 func Max(a, b int) int {
 	if a > b {
 		return a
@@ -53,3 +69,21 @@ func Min(a, b int) int {
 	}
 	return b
 }
+
+// Percentile returns the p-th percentile (0-100) of durations sorted in
+// ascending order, by the nearest-rank method. Returns 0 for no durations.
+//
+// Example: Percentile([1s 2s 3s 4s], 50) → 2s
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
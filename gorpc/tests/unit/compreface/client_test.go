@@ -0,0 +1,157 @@
+package compreface_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/fake"
+)
+
+// newPrefixedProxy mounts the fake Compreface server under prefix, rejecting
+// any request outside it like a path-based reverse proxy would
+func newPrefixedProxy(t *testing.T, target string, prefix string) *httptest.Server {
+	targetURL, err := url.Parse(target)
+	require.NoError(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(targetURL)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, prefix+"/") {
+			http.NotFound(w, r)
+			return
+		}
+		http.StripPrefix(prefix, proxy).ServeHTTP(w, r)
+	}))
+}
+
+func TestClient_PathPrefixedBaseURL(t *testing.T) {
+	server := fake.NewComprefaceServer()
+	defer server.Close()
+
+	proxy := newPrefixedProxy(t, server.URL(), "/compreface")
+	defer proxy.Close()
+
+	for _, baseURL := range []string{proxy.URL + "/compreface", proxy.URL + "/compreface/"} {
+		t.Run(baseURL, func(t *testing.T) {
+			client := compreface.NewClient(baseURL, "test", "test", "", 0.81)
+			face := []byte("face-" + baseURL)
+			subject := "Person " + strings.TrimPrefix(baseURL, proxy.URL)
+
+			added, err := client.AddSubjectFromBytes(subject, face, "a.jpg")
+			require.NoError(t, err)
+
+			resp, err := client.RecognizeFacesFromBytes(face, "a.jpg")
+			require.NoError(t, err)
+			require.Len(t, resp.Result[0].Subjects, 1)
+			assert.Equal(t, subject, resp.Result[0].Subjects[0].Subject)
+
+			faces, err := client.ListFaces(subject)
+			require.NoError(t, err)
+			assert.Len(t, faces, 1)
+
+			image, err := client.DownloadFaceImage(added.ImageID)
+			require.NoError(t, err)
+			assert.Equal(t, face, image)
+
+			detected, err := client.DetectFacesFromBytes(face, "a.jpg")
+			require.NoError(t, err)
+			assert.NotEmpty(t, detected.Result)
+
+			_, err = client.ListSubjects()
+			require.NoError(t, err)
+
+			require.NoError(t, client.DeleteFace(added.ImageID))
+			require.NoError(t, client.DeleteSubject(subject))
+		})
+	}
+}
+
+func TestClient_FaceImageURL_PathPrefix(t *testing.T) {
+	client := compreface.NewClient("http://proxy:8080/compreface/", "key", "key", "", 0.81)
+
+	assert.Equal(t, "http://proxy:8080/compreface/api/v1/recognition/faces/abc/img", client.FaceImageURL("abc"))
+}
+
+func TestClient_FaceImageURL_OmitsAPIKey(t *testing.T) {
+	client := compreface.NewClient("http://compreface:8000", "00000000-secret-recognition-key", "key", "", 0.81)
+
+	assert.NotContains(t, client.FaceImageURL("abc"), "secret")
+}
+
+func TestClient_TypedErrors(t *testing.T) {
+	status, body := http.StatusBadRequest, `{"message" : "No face is found in the given image", "code" : 28}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	client := compreface.NewClient(server.URL, "key", "key", "", 0.81)
+
+	_, err := client.DetectFacesFromBytes([]byte("face"), "a.jpg")
+	var apiErr *compreface.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 28, apiErr.Code)
+	assert.ErrorIs(t, err, compreface.ErrNoFaceFound)
+	assert.NotErrorIs(t, err, compreface.ErrServiceUnavailable)
+
+	status, body = http.StatusServiceUnavailable, "upstream down"
+	_, err = client.ListSubjects()
+	assert.ErrorIs(t, err, compreface.ErrServiceUnavailable)
+	assert.NotErrorIs(t, err, compreface.ErrNoFaceFound)
+
+	server.Close()
+	_, err = client.ListSubjects()
+	assert.ErrorIs(t, err, compreface.ErrServiceUnavailable)
+}
+
+func TestClient_FacePlugins(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(`{"result": []}`))
+	}))
+	defer server.Close()
+	client := compreface.NewClient(server.URL, "key", "key", "", 0.81)
+
+	_, err := client.RecognizeFacesFromBytes([]byte("face"), "a.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "landmarks,gender,age,calculator,mask", query.Get("face_plugins"))
+
+	client.FacePlugins = compreface.WithoutDemographics(client.FacePlugins)
+	_, err = client.RecognizeFacesFromBytes([]byte("face"), "a.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "landmarks,calculator,mask", query.Get("face_plugins"))
+
+	client.FacePlugins = []string{}
+	client.PredictionCount = 3
+	_, err = client.RecognizeFacesFromBytes([]byte("face"), "a.jpg")
+	require.NoError(t, err)
+	assert.False(t, query.Has("face_plugins"))
+	assert.Equal(t, "3", query.Get("prediction_count"))
+}
+
+func TestClient_EscapesPathSegments(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.EscapedPath())
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	client := compreface.NewClient(server.URL, "key", "key", "", 0.81)
+
+	require.NoError(t, client.DeleteSubject("AC/DC?live#1"))
+	require.NoError(t, client.RenameSubject("AC/DC", "ACDC"))
+	require.NoError(t, client.DeleteFace("face/1"))
+
+	assert.Equal(t, []string{
+		"DELETE /api/v1/recognition/subjects/AC%2FDC%3Flive%231",
+		"PUT /api/v1/recognition/subjects/AC%2FDC",
+		"DELETE /api/v1/recognition/faces/face%2F1",
+	}, paths)
+}
@@ -0,0 +1,56 @@
+package compreface_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+)
+
+func TestParseDoubleTakeExport(t *testing.T) {
+	export := `{
+		"matches": [
+			{"filename": "/.storage/matches/a.jpg", "response": [
+				{"detector": "compreface", "results": [
+					{"name": "jane_doe", "match": true, "confidence": 90},
+					{"name": "unknown", "match": false, "confidence": 40}
+				]},
+				{"detector": "deepstack", "results": [
+					{"name": "jane_doe", "match": true, "confidence": 95}
+				]}
+			]},
+			{"file": {"filename": "b.jpg"}, "response": [
+				{"detector": "compreface", "results": [
+					{"name": "john", "match": false, "confidence": 60}
+				]}
+			]}
+		],
+		"train": [
+			{"name": "john", "filename": "C:\\train\\c.png"}
+		]
+	}`
+
+	files, err := compreface.ParseDoubleTakeExport([]byte(export))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	assert.Equal(t, "a.jpg", files[0].Filename)
+	assert.Equal(t, 2, files[0].Faces)
+	require.Len(t, files[0].Subjects, 1)
+	assert.Equal(t, "jane_doe", files[0].Subjects[0].Name)
+	assert.InDelta(t, 0.95, files[0].Subjects[0].Similarity, 1e-9)
+
+	assert.Equal(t, "c.png", files[1].Filename)
+	assert.Equal(t, []compreface.DoubleTakeSubject{{Name: "john", Similarity: 1}}, files[1].Subjects)
+
+	_, err = compreface.ParseDoubleTakeExport([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestDoubleTakeDisplayName(t *testing.T) {
+	assert.Equal(t, "Jane Doe", compreface.DoubleTakeDisplayName("jane_doe"))
+	assert.Equal(t, "Mary Kate McDonald", compreface.DoubleTakeDisplayName("mary_kate McDonald"))
+	assert.Equal(t, "Alice", compreface.DoubleTakeDisplayName("alice"))
+}
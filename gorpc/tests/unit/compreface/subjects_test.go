@@ -187,3 +187,110 @@ func TestCreateSubjectName_Uniqueness(t *testing.T) {
 	// Verify we generated exactly `iterations` unique names
 	assert.Len(t, names, iterations, "should have generated %d unique names", iterations)
 }
+
+func TestIsGeneratedSubjectName(t *testing.T) {
+	assert.True(t, compreface.IsGeneratedSubjectName(compreface.CreateSubjectName("42")))
+	assert.True(t, compreface.IsGeneratedSubjectName("Person 12 ABC"))
+	assert.False(t, compreface.IsGeneratedSubjectName("Jane Doe"))
+	assert.False(t, compreface.IsGeneratedSubjectName("Personal Trainer"))
+}
+
+func TestSubjectSourceID(t *testing.T) {
+	id, ok := compreface.SubjectSourceID(compreface.CreateSubjectName("42"))
+	assert.True(t, ok)
+	assert.Equal(t, "42", id)
+
+	_, ok = compreface.SubjectSourceID("Person Jane ABC")
+	assert.False(t, ok)
+	_, ok = compreface.SubjectSourceID("Jane Doe")
+	assert.False(t, ok)
+}
+
+func TestSetNameGenerator_Sequential(t *testing.T) {
+	compreface.SetNameGenerator(compreface.NewSequentialNameGenerator())
+	t.Cleanup(func() { compreface.SetNameGenerator(nil) })
+
+	assert.Equal(t, "Person 42 0000000000000001", compreface.CreateSubjectName("42"))
+	assert.Equal(t, "Person 7 0000000000000002", compreface.CreateSubjectName("7"))
+
+	id, ok := compreface.SubjectSourceID(compreface.CreateSubjectName("42"))
+	assert.True(t, ok)
+	assert.Equal(t, "42", id)
+}
+
+func TestSetNameGenerator_Seeded(t *testing.T) {
+	t.Cleanup(func() { compreface.SetNameGenerator(nil) })
+
+	compreface.SetNameGenerator(compreface.NewRandomNameGenerator(1234))
+	first := []string{compreface.CreateSubjectName("1"), compreface.CreateSubjectName("2")}
+
+	compreface.SetNameGenerator(compreface.NewRandomNameGenerator(1234))
+	second := []string{compreface.CreateSubjectName("1"), compreface.CreateSubjectName("2")}
+
+	assert.Equal(t, first, second, "the same seed should generate the same names")
+	assert.NotEqual(t, first[0][len("Person 1 "):], first[1][len("Person 2 "):])
+	testutil.AssertSubjectNameFormat(t, first[0], "1")
+}
+
+func TestCloseSubjects(t *testing.T) {
+	face := func(subject string, similarity float64) compreface.FaceRecognition {
+		return compreface.FaceRecognition{Subject: subject, Similarity: similarity}
+	}
+
+	tests := []struct {
+		name     string
+		subjects []compreface.FaceRecognition
+		expected []string
+	}{
+		{
+			name:     "No subjects",
+			subjects: nil,
+			expected: nil,
+		},
+		{
+			name:     "Best below threshold",
+			subjects: []compreface.FaceRecognition{face("A", 0.80), face("B", 0.79)},
+			expected: nil,
+		},
+		{
+			name:     "Single subject",
+			subjects: []compreface.FaceRecognition{face("A", 0.95)},
+			expected: []string{"A"},
+		},
+		{
+			name:     "Runner-up within margin but below threshold",
+			subjects: []compreface.FaceRecognition{face("A", 0.83), face("B", 0.80)},
+			expected: []string{"A"},
+		},
+		{
+			name:     "Runner-up outside margin",
+			subjects: []compreface.FaceRecognition{face("A", 0.97), face("B", 0.90)},
+			expected: []string{"A"},
+		},
+		{
+			name:     "Same subject repeated for several examples",
+			subjects: []compreface.FaceRecognition{face("A", 0.97), face("A", 0.96), face("A", 0.95)},
+			expected: []string{"A"},
+		},
+		{
+			name:     "Runner-up within margin and above threshold",
+			subjects: []compreface.FaceRecognition{face("A", 0.95), face("B", 0.93), face("C", 0.85)},
+			expected: []string{"A", "B"},
+		},
+		{
+			name:     "Repeats keep their best similarity",
+			subjects: []compreface.FaceRecognition{face("B", 0.92), face("A", 0.94), face("B", 0.93)},
+			expected: []string{"A", "B"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for _, subject := range compreface.CloseSubjects(tt.subjects, 0.81, 0.03) {
+				names = append(names, subject.Subject)
+			}
+			assert.Equal(t, tt.expected, names)
+		})
+	}
+}
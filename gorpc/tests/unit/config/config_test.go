@@ -15,7 +15,9 @@ import (
 func TestPluginConfig_Defaults(t *testing.T) {
 	// Test that PluginConfig struct can be created with expected defaults
 	cfg := &config.PluginConfig{
-		CooldownSeconds:           10,
+		ComprefaceRequestsPerSecond: 10,
+		VisionMaxConcurrentJobs:     1,
+		StashWritesPerSecond:        10,
 		MaxBatchSize:              20,
 		MinSimilarity:             0.81,
 		MinFaceSize:               64,
@@ -30,7 +32,9 @@ func TestPluginConfig_Defaults(t *testing.T) {
 		SyncedTagName:             "Compreface Synced",
 	}
 
-	assert.Equal(t, 10, cfg.CooldownSeconds)
+	assert.Equal(t, 10.0, cfg.ComprefaceRequestsPerSecond)
+	assert.Equal(t, 1, cfg.VisionMaxConcurrentJobs)
+	assert.Equal(t, 10.0, cfg.StashWritesPerSecond)
 	assert.Equal(t, 20, cfg.MaxBatchSize)
 	assert.Equal(t, 0.81, cfg.MinSimilarity)
 	assert.Equal(t, 64, cfg.MinFaceSize)
@@ -53,7 +57,9 @@ func TestPluginConfig_Fields(t *testing.T) {
 		DetectionAPIKey:            "test-detection-key",
 		VerificationAPIKey:         "test-verification-key",
 		VisionServiceURL:           "http://vision:5010",
-		CooldownSeconds:            15,
+		ComprefaceRequestsPerSecond: 5,
+		VisionMaxConcurrentJobs:     2,
+		StashWritesPerSecond:        25,
 		MaxBatchSize:               30,
 		MinSimilarity:              0.95,
 		MinFaceSize:                128,
@@ -74,7 +80,9 @@ func TestPluginConfig_Fields(t *testing.T) {
 	assert.Equal(t, "test-detection-key", cfg.DetectionAPIKey)
 	assert.Equal(t, "test-verification-key", cfg.VerificationAPIKey)
 	assert.Equal(t, "http://vision:5010", cfg.VisionServiceURL)
-	assert.Equal(t, 15, cfg.CooldownSeconds)
+	assert.Equal(t, 5.0, cfg.ComprefaceRequestsPerSecond)
+	assert.Equal(t, 2, cfg.VisionMaxConcurrentJobs)
+	assert.Equal(t, 25.0, cfg.StashWritesPerSecond)
 	assert.Equal(t, 30, cfg.MaxBatchSize)
 	assert.Equal(t, 0.95, cfg.MinSimilarity)
 	assert.Equal(t, 128, cfg.MinFaceSize)
@@ -92,3 +100,107 @@ func TestPluginConfig_Fields(t *testing.T) {
 // Note: Testing resolveServiceURL function requires access to unexported functions
 // This would need to be refactored to make it testable, or we test it through
 // integration tests with actual service resolution
+
+func TestParseSceneFaceRules(t *testing.T) {
+	rules := config.ParseSceneFaceRules("1:maxFaces=10,samplingInterval=4; 4+:maxFaces=100,minConfidence=0.6")
+
+	assert.Equal(t, []config.SceneFaceRule{
+		{Performers: 1, MaxFaces: 10, SamplingInterval: 4},
+		{Performers: 4, OrMore: true, MaxFaces: 100, MinConfidence: 0.6},
+	}, rules)
+}
+
+func TestParseSceneFaceRules_SkipsMalformed(t *testing.T) {
+	rules := config.ParseSceneFaceRules("maxFaces=10; x:maxFaces=5; 2:maxFaces=0; 3:speed=2; 0:maxFaces=20")
+
+	assert.Equal(t, []config.SceneFaceRule{{Performers: 0, MaxFaces: 20}}, rules)
+}
+
+func TestSceneFaceRule_Matches(t *testing.T) {
+	exact := config.SceneFaceRule{Performers: 1}
+	orMore := config.SceneFaceRule{Performers: 4, OrMore: true}
+
+	assert.True(t, exact.Matches(1))
+	assert.False(t, exact.Matches(2))
+	assert.False(t, orMore.Matches(3))
+	assert.True(t, orMore.Matches(4))
+	assert.True(t, orMore.Matches(9))
+}
+
+func TestPluginConfig_ApplyBackgroundFriendly(t *testing.T) {
+	cfg := &config.PluginConfig{MaxConcurrency: 4, VisionMaxConcurrentJobs: 3, MaxImageDimension: 4096}
+	cfg.ApplyBackgroundFriendly()
+	assert.Equal(t, 1, cfg.MaxConcurrency)
+	assert.Equal(t, 1, cfg.VisionMaxConcurrentJobs)
+	assert.Equal(t, config.BackgroundMaxImageDimension, cfg.MaxImageDimension)
+
+	cfg = &config.PluginConfig{MaxImageDimension: 1024}
+	cfg.ApplyBackgroundFriendly()
+	assert.Equal(t, 1024, cfg.MaxImageDimension)
+}
+
+func TestPluginConfig_CreatesFromMedia(t *testing.T) {
+	rating := func(r int) *int { return &r }
+
+	tests := []struct {
+		name      string
+		minRating int
+		organized bool // CreationOrganized
+		rating    *int
+		isOrg     bool // Item is organized
+		expected  bool
+	}{
+		{name: "Gate disabled, unrated", expected: true},
+		{name: "Gate disabled, low rating", rating: rating(10), expected: true},
+		{name: "Rating at minimum", minRating: 60, rating: rating(60), expected: true},
+		{name: "Rating above minimum", minRating: 60, rating: rating(80), expected: true},
+		{name: "Rating below minimum", minRating: 60, rating: rating(40), expected: false},
+		{name: "Unrated with only min rating set", minRating: 60, expected: false},
+		{name: "Unrated organized item with only min rating set", minRating: 60, isOrg: true, expected: false},
+		{name: "Organized with creationOrganized", organized: true, isOrg: true, expected: true},
+		{name: "Not organized with creationOrganized", organized: true, rating: rating(100), expected: false},
+		{name: "Both set, passes on rating", minRating: 60, organized: true, rating: rating(70), expected: true},
+		{name: "Both set, passes on organized", minRating: 60, organized: true, rating: rating(20), isOrg: true, expected: true},
+		{name: "Both set, fails both", minRating: 60, organized: true, rating: rating(20), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.PluginConfig{CreationMinRating: tt.minRating, CreationOrganized: tt.organized}
+			assert.Equal(t, tt.expected, cfg.CreatesFromMedia(tt.rating, tt.isOrg))
+		})
+	}
+}
+
+func TestParseSimilarityPresets(t *testing.T) {
+	presets := config.ParseSimilarityPresets("arcface-r100=0.75; facenet = 0.81; mobilenet; x=2; =0.5")
+
+	assert.Equal(t, []config.SimilarityPreset{
+		{Model: "arcface-r100", MinSimilarity: 0.75},
+		{Model: "facenet", MinSimilarity: 0.81},
+	}, presets)
+}
+
+func TestSimilarityPreset_Matches(t *testing.T) {
+	preset := config.SimilarityPreset{Model: "ArcFace-R100"}
+
+	assert.True(t, preset.Matches("insightface.Calculator@arcface-r100-msfdb"))
+	assert.False(t, preset.Matches("facenet.Calculator"))
+}
+
+func TestParseExternalStashURL(t *testing.T) {
+	for input, want := range map[string]string{
+		"https://stash.example.com":         "https://stash.example.com",
+		" https://stash.example.com:8443/ ": "https://stash.example.com:8443",
+		"http://example.com/stash/":         "http://example.com/stash",
+	} {
+		got, err := config.ParseExternalStashURL(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	for _, input := range []string{"stash.example.com", "ftp://example.com", "https://", "https://example.com/?a=1", "https://example.com/#top"} {
+		_, err := config.ParseExternalStashURL(input)
+		assert.Error(t, err, input)
+	}
+}
@@ -0,0 +1,200 @@
+package fake_test
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/compreface"
+	"github.com/smegmarip/stash-compreface-plugin/internal/fake"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+func TestComprefaceServer_AddAndRecognize(t *testing.T) {
+	server := fake.NewComprefaceServer()
+	defer server.Close()
+
+	client := compreface.NewClient(server.URL(), "test", "test", "", 0.81)
+	faceA := []byte("face-a")
+	faceB := []byte("face-b")
+
+	// Unknown face returns no subjects
+	resp, err := client.RecognizeFacesFromBytes(faceA, "a.jpg")
+	require.NoError(t, err)
+	require.Len(t, resp.Result, 1)
+	assert.Empty(t, resp.Result[0].Subjects)
+
+	// Add face A to a subject
+	added, err := client.AddSubjectFromBytes("Person 1 ABCDEFGHIJKLMNOP", faceA, "a.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "Person 1 ABCDEFGHIJKLMNOP", added.Subject)
+	assert.NotEmpty(t, added.ImageID)
+
+	// Same face now matches, different face does not
+	resp, err = client.RecognizeFacesFromBytes(faceA, "a.jpg")
+	require.NoError(t, err)
+	require.Len(t, resp.Result[0].Subjects, 1)
+	assert.Equal(t, "Person 1 ABCDEFGHIJKLMNOP", resp.Result[0].Subjects[0].Subject)
+	assert.Equal(t, fake.FakeSimilarity, resp.Result[0].Subjects[0].Similarity)
+
+	resp, err = client.RecognizeFacesFromBytes(faceB, "b.jpg")
+	require.NoError(t, err)
+	assert.Empty(t, resp.Result[0].Subjects)
+
+	subjects, err := client.ListSubjects()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Person 1 ABCDEFGHIJKLMNOP"}, subjects)
+
+	// Deleting the subject removes the match
+	require.NoError(t, client.DeleteSubject("Person 1 ABCDEFGHIJKLMNOP"))
+	resp, err = client.RecognizeFacesFromBytes(faceA, "a.jpg")
+	require.NoError(t, err)
+	assert.Empty(t, resp.Result[0].Subjects)
+}
+
+func TestComprefaceServer_VerifyFace(t *testing.T) {
+	server := fake.NewComprefaceServer()
+	defer server.Close()
+
+	client := compreface.NewClient(server.URL(), "test", "test", "", 0.81)
+
+	exampleA, err := client.AddSubjectFromBytes("Person 2 ABCDEFGHIJKLMNOP", []byte("face-a"), "a.jpg")
+	require.NoError(t, err)
+	exampleB, err := client.AddSubjectFromBytes("Person 2 ABCDEFGHIJKLMNOP", []byte("face-b"), "b.jpg")
+	require.NoError(t, err)
+
+	similarity, err := client.VerifyFaceFromBytes(exampleB.ImageID, []byte("face-b"), "probe.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, fake.FakeSimilarity, similarity)
+
+	similarity, err = client.VerifyFaceFromBytes(exampleA.ImageID, []byte("face-b"), "probe.jpg")
+	require.NoError(t, err)
+	assert.Zero(t, similarity)
+
+	_, err = client.VerifyFaceFromBytes("missing", []byte("face-b"), "probe.jpg")
+	assert.Error(t, err)
+}
+
+func TestVisionServer_JobLifecycle(t *testing.T) {
+	server := fake.NewVisionServer()
+	defer server.Close()
+
+	client := vision.NewVisionServiceClient(server.URL(), server.FrameServerURL())
+	require.NoError(t, client.HealthCheck())
+
+	req := vision.BuildAnalyzeRequest("/media/scene.mp4", "42", vision.FacesParameters{})
+	job, err := client.SubmitJob(req)
+	require.NoError(t, err)
+	require.NotEmpty(t, job.JobID)
+
+	status, err := client.GetJobStatus(job.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, "completed", status.Status)
+
+	results, err := client.GetResults(job.JobID)
+	require.NoError(t, err)
+	require.NotNil(t, results.Faces)
+	require.Len(t, results.Faces.Faces, 1)
+
+	face := results.Faces.Faces[0]
+	assert.Len(t, face.Embedding, 512)
+	require.NotNil(t, face.Demographics)
+	assert.Equal(t, "F", face.Demographics.Gender)
+	assert.Greater(t, face.RepresentativeDetection.BBox.XMax, face.RepresentativeDetection.BBox.XMin)
+}
+
+func TestVisionServer_ExtractFrame(t *testing.T) {
+	server := fake.NewVisionServer()
+	defer server.Close()
+
+	client := vision.NewVisionServiceClient(server.URL(), server.FrameServerURL())
+
+	frameA, err := client.ExtractFrame("/media/a.mp4", 1.0, nil)
+	require.NoError(t, err)
+	frameB, err := client.ExtractFrame("/media/b.mp4", 1.0, nil)
+	require.NoError(t, err)
+	frameA2, err := client.ExtractFrame("/media/a.mp4", 5.0, nil)
+	require.NoError(t, err)
+
+	img, err := jpeg.Decode(bytes.NewReader(frameA))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 640, 480), img.Bounds())
+
+	assert.Equal(t, frameA, frameA2, "frames should be stable per video")
+	assert.NotEqual(t, frameA, frameB, "frames should differ between videos")
+}
+
+func TestVisionServer_BatchExtractFrames(t *testing.T) {
+	server := fake.NewVisionServer()
+	defer server.Close()
+
+	client := vision.NewVisionServiceClient(server.URL(), server.FrameServerURL())
+
+	frames, err := client.BatchExtractFrames("/media/a.mp4", []float64{1.0, 5.256}, nil)
+	require.NoError(t, err)
+	require.Len(t, frames, 2)
+
+	single, err := client.ExtractFrame("/media/a.mp4", 1.0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, single, frames[vision.FrameKey(1.0)])
+	assert.Contains(t, frames, vision.FrameKey(5.256))
+}
+
+func TestComprefaceServer_RenameSubjectMerges(t *testing.T) {
+	server := fake.NewComprefaceServer()
+	defer server.Close()
+
+	client := compreface.NewClient(server.URL(), "test", "test", "", 0.81)
+
+	_, err := client.AddSubjectFromBytes("Person 3 ABCDEFGHIJKLMNOP", []byte("face-a"), "a.jpg")
+	require.NoError(t, err)
+	_, err = client.AddSubjectFromBytes("Person 4 ABCDEFGHIJKLMNOP", []byte("face-b"), "b.jpg")
+	require.NoError(t, err)
+
+	require.NoError(t, client.RenameSubject("Person 3 ABCDEFGHIJKLMNOP", "Person 4 ABCDEFGHIJKLMNOP"))
+
+	subjects, err := client.ListSubjects()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Person 4 ABCDEFGHIJKLMNOP"}, subjects)
+
+	resp, err := client.RecognizeFacesFromBytes([]byte("face-a"), "a.jpg")
+	require.NoError(t, err)
+	require.Len(t, resp.Result[0].Subjects, 1)
+	assert.Equal(t, "Person 4 ABCDEFGHIJKLMNOP", resp.Result[0].Subjects[0].Subject)
+
+	assert.Error(t, client.RenameSubject("Person 3 ABCDEFGHIJKLMNOP", "Person 5 ABCDEFGHIJKLMNOP"))
+}
+
+func TestComprefaceServer_VerifyFaces(t *testing.T) {
+	server := fake.NewComprefaceServer()
+	defer server.Close()
+
+	client := compreface.NewClient(server.URL(), "test", "test", "test", 0.81)
+
+	similarity, err := client.VerifyFaces([]byte("face-a"), []byte("face-a"))
+	require.NoError(t, err)
+	assert.Equal(t, fake.FakeSimilarity, similarity)
+
+	similarity, err = client.VerifyFaces([]byte("face-a"), []byte("face-b"))
+	require.NoError(t, err)
+	assert.Zero(t, similarity)
+
+	noKey := compreface.NewClient(server.URL(), "test", "test", "", 0.81)
+	_, err = noKey.VerifyFaces([]byte("face-a"), []byte("face-a"))
+	assert.Error(t, err)
+}
+
+func TestComprefaceServer_DetectModel(t *testing.T) {
+	server := fake.NewComprefaceServer()
+	defer server.Close()
+
+	client := compreface.NewClient(server.URL(), "test", "test", "", 0.81)
+
+	model, err := client.DetectModel()
+	require.NoError(t, err)
+	assert.Equal(t, fake.FakeModel, model)
+}
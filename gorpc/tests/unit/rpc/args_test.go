@@ -0,0 +1,136 @@
+package rpc_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/rpc"
+)
+
+func TestValidateTaskArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     map[string]interface{}
+		mode    string
+		wantErr string
+	}{
+		{
+			name: "Defaults only",
+			raw:  map[string]interface{}{"mode": "recognizeImages"},
+			mode: "recognizeImages",
+		},
+		{
+			name: "Loosely typed values",
+			raw: map[string]interface{}{
+				"mode":              "recognizeImages",
+				"limit":             float64(50),
+				"createNewSubjects": "false",
+				"resume":            float64(1),
+				"studioId":          "12",
+				"minSimilarity":     "0.9",
+				"createdAfter":      "2024-06-01",
+			},
+			mode: "recognizeImages",
+		},
+		{
+			name: "Empty values fall back to defaults",
+			raw:  map[string]interface{}{"mode": "identifyGallery", "galleryId": float64(3), "limit": ""},
+			mode: "identifyGallery",
+		},
+		{
+			name:    "Missing mode",
+			raw:     map[string]interface{}{"limit": float64(1)},
+			wantErr: "missing required argument mode",
+		},
+		{
+			name:    "Unknown mode",
+			raw:     map[string]interface{}{"mode": "recognizeEverything"},
+			wantErr: `invalid mode "recognizeEverything"`,
+		},
+		{
+			name:    "Missing required argument",
+			raw:     map[string]interface{}{"mode": "identifyImage"},
+			wantErr: "missing required argument imageId",
+		},
+		{
+			name:    "Fractional integer",
+			raw:     map[string]interface{}{"mode": "recognizeImages", "limit": 2.5},
+			wantErr: "invalid limit 2.5: expected a whole number",
+		},
+		{
+			name:    "Integer of the wrong type",
+			raw:     map[string]interface{}{"mode": "recognizeImages", "limit": true},
+			wantErr: "invalid limit: expected a number, got bool",
+		},
+		{
+			name:    "Non-numeric integer string",
+			raw:     map[string]interface{}{"mode": "recognizeImages", "limit": "ten"},
+			wantErr: `invalid limit "ten": expected a whole number`,
+		},
+		{
+			name:    "Boolean out of range",
+			raw:     map[string]interface{}{"mode": "recognizeImages", "resume": float64(2)},
+			wantErr: "invalid resume 2: expected true or false",
+		},
+		{
+			name:    "Non-numeric ID",
+			raw:     map[string]interface{}{"mode": "identifyImage", "imageId": "abc"},
+			wantErr: `invalid imageId "abc": expected a numeric ID`,
+		},
+		{
+			name:    "Non-positive ID",
+			raw:     map[string]interface{}{"mode": "identifyImage", "imageId": float64(0)},
+			wantErr: "invalid imageId 0: must be positive",
+		},
+		{
+			name:    "Integer below minimum",
+			raw:     map[string]interface{}{"mode": "recognizeImages", "limit": float64(-1)},
+			wantErr: "invalid limit -1: must be at least 0",
+		},
+		{
+			name:    "Integer below schema minimum",
+			raw:     map[string]interface{}{"mode": "trainPerformerFaces", "examples": float64(1)},
+			wantErr: "invalid examples 1: must be at least 2",
+		},
+		{
+			name:    "Number above maximum",
+			raw:     map[string]interface{}{"mode": "recognizeImages", "minSimilarity": 1.5},
+			wantErr: "invalid minSimilarity 1.5: must be at most 1",
+		},
+		{
+			name:    "Malformed date",
+			raw:     map[string]interface{}{"mode": "recognizeImages", "createdAfter": "June 2024"},
+			wantErr: `invalid createdAfter "June 2024": expected a date`,
+		},
+		{
+			name:    "Blank string",
+			raw:     map[string]interface{}{"mode": "benchmark", "path": "   "},
+			wantErr: "invalid path: must not be blank",
+		},
+		{
+			name:    "Unknown argument",
+			raw:     map[string]interface{}{"mode": "recognizeImages", "limt": float64(10)},
+			wantErr: "unknown argument(s) limt for mode recognizeImages",
+		},
+		{
+			name:    "Argument of another mode",
+			raw:     map[string]interface{}{"mode": "synchronizePerformers", "limit": float64(10), "resume": true, "dryRun": true},
+			wantErr: "unknown argument(s) dryRun, resume for mode synchronizePerformers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, err := rpc.ValidateTaskArgs(tt.raw)
+			if tt.wantErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tt.wantErr)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.mode, mode)
+		})
+	}
+}
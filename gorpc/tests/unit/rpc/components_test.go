@@ -0,0 +1,70 @@
+package rpc_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stashapp/stash/pkg/plugin/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/rpc"
+)
+
+type stubStatus struct {
+	clients rpc.Clients
+}
+
+func (s stubStatus) Status() (string, error) {
+	return "stub status", nil
+}
+
+func TestRun_RoutesToInjectedComponent(t *testing.T) {
+	// Stash serving only the plugin settings
+	stashServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"configuration":{"plugins":{"compreface-rpc":{"recognitionApiKey":"stub-key","detectionApiKey":"stub-key"}}}}}`))
+	}))
+	defer stashServer.Close()
+	stashURL, err := url.Parse(stashServer.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(stashURL.Port())
+	require.NoError(t, err)
+
+	var built *stubStatus
+	service := rpc.NewServiceWithComponents(rpc.Components{
+		Status: func(clients rpc.Clients) rpc.StatusReporter {
+			built = &stubStatus{clients: clients}
+			return built
+		},
+	})
+
+	input := common.PluginInput{
+		ServerConnection: common.StashServerConnection{
+			Scheme:    "http",
+			Host:      stashURL.Hostname(),
+			Port:      port,
+			PluginDir: t.TempDir(),
+		},
+		Args: common.ArgsMap{"mode": "status"},
+	}
+	var output common.PluginOutput
+	require.NoError(t, service.Run(input, &output))
+
+	require.Nil(t, output.Error)
+	var result rpc.TaskResult
+	require.NoError(t, json.Unmarshal([]byte(*output.Output.(*string)), &result))
+	assert.True(t, result.Success)
+	assert.Equal(t, "stub status", result.Message)
+
+	require.NotNil(t, built)
+	assert.NotNil(t, built.clients.Stash)
+	assert.NotNil(t, built.clients.Compreface)
+	assert.NotNil(t, built.clients.Vision)
+	if assert.NotNil(t, built.clients.Config) {
+		assert.Equal(t, 20, built.clients.Config.MaxBatchSize)
+	}
+}
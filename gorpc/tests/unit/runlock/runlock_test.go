@@ -0,0 +1,62 @@
+package runlock_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/runlock"
+)
+
+func TestAcquire_SecondRunIsLocked(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := runlock.Acquire(dir, "recognizeImages")
+	require.NoError(t, err)
+	defer lock.Release()
+
+	_, err = runlock.Acquire(dir, "recognizeImages")
+	assert.ErrorIs(t, err, runlock.ErrLocked)
+	assert.Contains(t, err.Error(), "pid")
+}
+
+func TestAcquire_DifferentNamesDoNotConflict(t *testing.T) {
+	dir := t.TempDir()
+
+	a, err := runlock.Acquire(dir, "recognizeImages")
+	require.NoError(t, err)
+	defer a.Release()
+
+	b, err := runlock.Acquire(dir, "recognizeNewScenes")
+	require.NoError(t, err)
+	b.Release()
+}
+
+func TestRelease_AllowsNextRun(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := runlock.Acquire(dir, "recognizeImages")
+	require.NoError(t, err)
+	lock.Release()
+	lock.Release()
+
+	lock, err = runlock.Acquire(dir, "recognizeImages")
+	require.NoError(t, err)
+	lock.Release()
+}
+
+func TestAcquire_TakesOverStaleLease(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "recognizeImages.lock")
+	require.NoError(t, os.WriteFile(path, []byte("pid 1"), 0o644))
+	stale := time.Now().Add(-runlock.StaleAfter - time.Minute)
+	require.NoError(t, os.Chtimes(path, stale, stale))
+
+	lock, err := runlock.Acquire(dir, "recognizeImages")
+	require.NoError(t, err)
+	lock.Release()
+}
@@ -0,0 +1,205 @@
+package sprite_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/sprite"
+)
+
+const stashVTT = `WEBVTT
+
+00:00:00.000 --> 00:00:05.000
+scene_sprite.jpg#xywh=0,0,160,90
+
+00:00:05.000 --> 00:00:10.000
+scene_sprite.jpg#xywh=160,0,160,90
+`
+
+func TestParseVTT_StashFormat(t *testing.T) {
+	cues, err := sprite.ParseVTT(stashVTT)
+	require.NoError(t, err)
+	require.Len(t, cues, 2)
+
+	assert.Equal(t, sprite.Cue{StartTime: 5, EndTime: 10, Image: "scene_sprite.jpg", X: 160, Y: 0, Width: 160, Height: 90}, cues[1])
+}
+
+func TestParseVTT_IdentifiersNotesAndShortTimings(t *testing.T) {
+	content := "\ufeffWEBVTT - sprites\r\n\r\n" +
+		"NOTE generated by test\r\nspanning lines\r\n\r\n" +
+		"cue-1\r\n00:01.500 --> 00:03.000 align:start\r\n#xywh=pixel:10,20,30,40\r\n\r\n" +
+		"cue-2\r\n01:00:00.000 --> 01:00:02.000\r\nsheet.png#xywh=1,2,3,4\r\n"
+
+	cues, err := sprite.ParseVTT(content)
+	require.NoError(t, err)
+	require.Len(t, cues, 2)
+
+	assert.Equal(t, sprite.Cue{StartTime: 1.5, EndTime: 3, Image: "", X: 10, Y: 20, Width: 30, Height: 40}, cues[0])
+	assert.Equal(t, 3600.0, cues[1].StartTime)
+	assert.Equal(t, "sheet.png", cues[1].Image)
+}
+
+func TestParseVTT_SkipsCuesWithoutFragment(t *testing.T) {
+	cues, err := sprite.ParseVTT("WEBVTT\n\n00:00.000 --> 00:01.000\nplain caption\n")
+	require.NoError(t, err)
+	assert.Empty(t, cues)
+}
+
+func TestParseVTT_MissingHeader(t *testing.T) {
+	_, err := sprite.ParseVTT("00:00.000 --> 00:01.000\na.jpg#xywh=0,0,1,1\n")
+	assert.Error(t, err)
+}
+
+func TestFindCue(t *testing.T) {
+	cues, err := sprite.ParseVTT(stashVTT)
+	require.NoError(t, err)
+
+	cue, err := sprite.FindCue(cues, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 160, cue.X)
+
+	cue, err = sprite.FindCue(cues, 10)
+	require.NoError(t, err, "end of last cue resolves to last cue")
+	assert.Equal(t, 160, cue.X)
+
+	_, err = sprite.FindCue(cues, 11)
+	assert.Error(t, err)
+}
+
+func TestResolveSource(t *testing.T) {
+	resolved, err := sprite.ResolveSource("http://stash:9999/scene/1/vtt/thumbs", "sprite_2.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "http://stash:9999/scene/1/vtt/sprite_2.jpg", resolved)
+
+	resolved, err = sprite.ResolveSource("/data/vtt/scene.vtt", "sprite_2.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "/data/vtt/sprite_2.jpg", resolved)
+
+	resolved, err = sprite.ResolveSource("/data/vtt/scene.vtt", "http://cdn/sprite.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "http://cdn/sprite.jpg", resolved)
+}
+
+// spriteSheet builds a 2x1 sprite with a red left tile and a blue right tile
+func spriteSheet() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 320, 90))
+	for y := 0; y < 90; y++ {
+		for x := 0; x < 320; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= 160 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// decodeThumbnail decodes a thumbnail and returns its size and centre colour
+func decodeThumbnail(t *testing.T, data []byte) (image.Rectangle, color.RGBA) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	r, g, b, _ := img.At(img.Bounds().Dx()/2, img.Bounds().Dy()/2).RGBA()
+	return img.Bounds(), color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 255}
+}
+
+func TestExtractor_HTTPWithCaching(t *testing.T) {
+	var vttHits, spriteHits int32
+	sheet := encodePNG(t, spriteSheet())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/scene/1/vtt/thumbs":
+			atomic.AddInt32(&vttHits, 1)
+			w.Write([]byte(stashVTT))
+		case "/scene/1/vtt/sprite":
+			atomic.AddInt32(&spriteHits, 1)
+			w.Write(sheet)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	extractor := sprite.NewExtractor(nil)
+	vttURL := server.URL + "/scene/1/vtt/thumbs"
+	spriteURL := server.URL + "/scene/1/vtt/sprite"
+
+	thumb, err := extractor.Extract(spriteURL, vttURL, 2)
+	require.NoError(t, err)
+	bounds, c := decodeThumbnail(t, thumb)
+	assert.Equal(t, 160, bounds.Dx())
+	assert.Equal(t, 90, bounds.Dy())
+	assert.Greater(t, c.R, uint8(200))
+
+	thumb, err = extractor.Extract(spriteURL, vttURL, 7)
+	require.NoError(t, err)
+	_, c = decodeThumbnail(t, thumb)
+	assert.Greater(t, c.B, uint8(200))
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&vttHits), "VTT should be cached")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&spriteHits), "sprite image should be cached")
+}
+
+func TestExtractor_MultiFileLocalSprites(t *testing.T) {
+	dir := t.TempDir()
+	sheet := spriteSheet()
+
+	// Each sprite file holds one tile; the second is the blue half of the sheet
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sprite_1.png"), encodePNG(t, sheet.SubImage(image.Rect(0, 0, 160, 90))), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sprite_2.png"), encodePNG(t, sheet.SubImage(image.Rect(160, 0, 320, 90))), 0o644))
+
+	vtt := `WEBVTT
+
+00:00:00.000 --> 00:00:05.000
+sprite_1.png#xywh=0,0,160,90
+
+00:00:05.000 --> 00:00:10.000
+sprite_2.png#xywh=0,0,160,90
+`
+	vttPath := filepath.Join(dir, "scene.vtt")
+	require.NoError(t, os.WriteFile(vttPath, []byte(vtt), 0o644))
+
+	extractor := sprite.NewExtractor(nil)
+
+	// Sprite URL is ignored when cues reference multiple images
+	thumb, err := extractor.Extract("/does/not/exist.png", vttPath, 6)
+	require.NoError(t, err)
+	_, c := decodeThumbnail(t, thumb)
+	assert.Greater(t, c.B, uint8(200))
+
+	thumb, err = extractor.Extract("", "file://"+vttPath, 1)
+	require.NoError(t, err)
+	_, c = decodeThumbnail(t, thumb)
+	assert.Greater(t, c.R, uint8(200))
+}
+
+func TestCropThumbnail_ClipsToBounds(t *testing.T) {
+	sheet := spriteSheet()
+
+	thumb, err := sprite.CropThumbnail(sheet, sprite.Cue{X: 300, Y: 0, Width: 160, Height: 90})
+	require.NoError(t, err)
+	bounds, _ := decodeThumbnail(t, thumb)
+	assert.Equal(t, 20, bounds.Dx())
+
+	_, err = sprite.CropThumbnail(sheet, sprite.Cue{X: 400, Y: 0, Width: 10, Height: 10})
+	assert.Error(t, err)
+}
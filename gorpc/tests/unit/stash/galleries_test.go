@@ -0,0 +1,31 @@
+package stash_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+)
+
+func TestReplaceDetailsSection_Empty(t *testing.T) {
+	assert.Equal(t, "[Compreface]\nImages: 3\n[/Compreface]",
+		stash.ReplaceDetailsSection("", "Compreface", "Images: 3\n"))
+}
+
+func TestReplaceDetailsSection_AppendsAfterUserText(t *testing.T) {
+	assert.Equal(t, "Holiday photos\n\n[Compreface]\nImages: 3\n[/Compreface]",
+		stash.ReplaceDetailsSection("Holiday photos\n", "Compreface", "Images: 3"))
+}
+
+func TestReplaceDetailsSection_ReplacesExisting(t *testing.T) {
+	details := "Before\n\n[Compreface]\nImages: 3\n[/Compreface]\nAfter"
+	assert.Equal(t, "Before\n\n[Compreface]\nImages: 5\n[/Compreface]\nAfter",
+		stash.ReplaceDetailsSection(details, "Compreface", "Images: 5"))
+}
+
+func TestReplaceDetailsSection_UnterminatedAppends(t *testing.T) {
+	details := "Notes [Compreface] mentioned"
+	assert.Equal(t, details+"\n\n[Compreface]\nImages: 1\n[/Compreface]",
+		stash.ReplaceDetailsSection(details, "Compreface", "Images: 1"))
+}
@@ -0,0 +1,54 @@
+package stash_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+)
+
+func imageWithSize(width, height int) *stash.Image {
+	return &stash.Image{VisualFiles: []stash.VisualFile{
+		{ImageFile: stash.Dimensions{Width: width, Height: height}},
+	}}
+}
+
+func TestImageDimensions_ImageFile(t *testing.T) {
+	assert.Equal(t, stash.Dimensions{Width: 1920, Height: 1080}, imageWithSize(1920, 1080).Dimensions())
+}
+
+func TestImageDimensions_VideoFile(t *testing.T) {
+	img := &stash.Image{VisualFiles: []stash.VisualFile{
+		{VideoFile: stash.Dimensions{Width: 640, Height: 480}},
+	}}
+
+	assert.Equal(t, stash.Dimensions{Width: 640, Height: 480}, img.Dimensions())
+}
+
+func TestImageDimensions_NoFiles(t *testing.T) {
+	assert.Equal(t, stash.Dimensions{}, (&stash.Image{}).Dimensions())
+}
+
+func TestImageOrientation(t *testing.T) {
+	assert.Equal(t, stash.OrientationLandscape, imageWithSize(1920, 1080).Orientation())
+	assert.Equal(t, stash.OrientationPortrait, imageWithSize(1080, 1920).Orientation())
+	assert.Equal(t, stash.OrientationSquare, imageWithSize(512, 512).Orientation())
+	assert.Equal(t, stash.OrientationUnknown, (&stash.Image{}).Orientation())
+}
+
+func TestImagePrimaryPath_Clip(t *testing.T) {
+	clip := &stash.Image{VisualFiles: []stash.VisualFile{
+		{Typename: "VideoFile", File: stash.BaseFile{Path: "/media/clips/loop.webm"}},
+	}}
+	assert.True(t, clip.IsClip())
+	assert.Equal(t, "/media/clips/loop.webm", clip.PrimaryPath())
+
+	still := &stash.Image{
+		Files:       []stash.ImageFile{{Path: "/media/photos/a.jpg"}},
+		VisualFiles: []stash.VisualFile{{Typename: "ImageFile", File: stash.BaseFile{Path: "/media/photos/a.jpg"}}},
+	}
+	assert.False(t, still.IsClip())
+	assert.Equal(t, "/media/photos/a.jpg", still.PrimaryPath())
+	assert.Empty(t, (&stash.Image{}).PrimaryPath())
+}
@@ -0,0 +1,123 @@
+package stash_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+)
+
+// newPagedImageServer serves findImages queries over total images, recording
+// each requested page
+func newPagedImageServer(t *testing.T, total int, pages *[]int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables struct {
+				Filter struct {
+					Page    int `json:"page"`
+					PerPage int `json:"per_page"`
+				} `json:"filter"`
+			} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		page, perPage := req.Variables.Filter.Page, req.Variables.Filter.PerPage
+		*pages = append(*pages, page)
+
+		images := []map[string]interface{}{}
+		for i := (page - 1) * perPage; i < page*perPage && i < total; i++ {
+			images = append(images, map[string]interface{}{"id": fmt.Sprintf("%d", i+1)})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"findImages": map[string]interface{}{
+					"count":  total,
+					"images": images,
+				},
+			},
+		})
+	}))
+}
+
+func TestFindAllImages_VisitsEveryPage(t *testing.T) {
+	var pages []int
+	server := newPagedImageServer(t, 25, &pages)
+	defer server.Close()
+
+	client := stash.TestClient(server.URL, http.DefaultClient)
+
+	var ids []string
+	err := stash.FindAllImages(client, nil, 10, func(images []stash.Image, count int) error {
+		assert.Equal(t, 25, count)
+		for _, image := range images {
+			ids = append(ids, fmt.Sprintf("%v", image.ID))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Len(t, ids, 25)
+	assert.Equal(t, "1", ids[0])
+	assert.Equal(t, "25", ids[24])
+	assert.Equal(t, []int{1, 2, 3}, pages)
+}
+
+func TestFindAllImages_StopPaging(t *testing.T) {
+	var pages []int
+	server := newPagedImageServer(t, 50, &pages)
+	defer server.Close()
+
+	client := stash.TestClient(server.URL, http.DefaultClient)
+
+	visited := 0
+	err := stash.FindAllImages(client, nil, 10, func(images []stash.Image, count int) error {
+		visited += len(images)
+		if visited >= 20 {
+			return stash.ErrStopPaging
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 20, visited)
+	assert.Equal(t, []int{1, 2}, pages)
+}
+
+func TestFindAllImages_CallbackError(t *testing.T) {
+	var pages []int
+	server := newPagedImageServer(t, 50, &pages)
+	defer server.Close()
+
+	client := stash.TestClient(server.URL, http.DefaultClient)
+
+	err := stash.FindAllImages(client, nil, 10, func(images []stash.Image, count int) error {
+		return fmt.Errorf("boom")
+	})
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, []int{1}, pages)
+}
+
+func TestFindAllImages_Empty(t *testing.T) {
+	var pages []int
+	server := newPagedImageServer(t, 0, &pages)
+	defer server.Close()
+
+	client := stash.TestClient(server.URL, http.DefaultClient)
+
+	called := false
+	err := stash.FindAllImages(client, nil, 0, func(images []stash.Image, count int) error {
+		called = true
+		return nil
+	})
+	require.NoError(t, err)
+	assert.False(t, called)
+	assert.Equal(t, []int{1}, pages)
+}
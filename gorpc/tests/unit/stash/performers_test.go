@@ -0,0 +1,53 @@
+package stash_test
+
+import (
+	"testing"
+
+	graphql "github.com/hasura/go-graphql-client"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+)
+
+func TestDedupeAliases_CaseInsensitive(t *testing.T) {
+	aliases := []string{"Person 12 ABC", "Jane", "person 12 abc", "JANE", "Person 12 ABC"}
+
+	assert.Equal(t, []string{"Person 12 ABC", "Jane"}, stash.DedupeAliases(aliases))
+}
+
+func TestDedupeAliases_DropsBlank(t *testing.T) {
+	assert.Equal(t, []string{"Jane"}, stash.DedupeAliases([]string{"", " Jane ", "  "}))
+}
+
+func TestDedupeAliases_Empty(t *testing.T) {
+	assert.Empty(t, stash.DedupeAliases(nil))
+}
+
+func TestReplacePerformerID(t *testing.T) {
+	performers := []stash.Performer{{ID: "1"}, {ID: "2"}, {ID: "3"}}
+
+	assert.Equal(t, []graphql.ID{"1", "4", "3"}, stash.ReplacePerformerID(performers, "2", "4"))
+	assert.Equal(t, []graphql.ID{"1", "3"}, stash.ReplacePerformerID(performers, "2", "3"))
+	assert.Equal(t, []graphql.ID{"1", "2", "3"}, stash.ReplacePerformerID(performers, "9", "4"))
+}
+
+func TestAgeRangeDetails(t *testing.T) {
+	assert.Equal(t, "Estimated age: 25-32 (Compreface)", stash.AgeRangeDetails(25, 32))
+	assert.Equal(t, "Estimated age: 25-32 (Compreface)", stash.AgeRangeDetails(32, 25))
+	assert.Equal(t, "Estimated age: 31 (Compreface)", stash.AgeRangeDetails(31, 31))
+	assert.Equal(t, "Estimated age: 40 (Compreface)", stash.AgeRangeDetails(0, 40))
+	assert.Empty(t, stash.AgeRangeDetails(0, 0))
+}
+
+func TestMatchSubjectName_IgnoresCaseAndSpacing(t *testing.T) {
+	performer := stash.Performer{Name: "Jane Doe", AliasList: []string{" person 12 ABCDEFGHIJKLMNOP "}}
+
+	name, ok := stash.MatchSubjectName(performer, "Person 12 abcdefghijklmnop")
+	assert.True(t, ok)
+	assert.Equal(t, " person 12 ABCDEFGHIJKLMNOP ", name)
+
+	_, ok = stash.MatchSubjectName(performer, "Person 12 ABCDEFGHIJKLMNO")
+	assert.False(t, ok, "prefixes must not match")
+	_, ok = stash.MatchSubjectName(performer, "  ")
+	assert.False(t, ok)
+}
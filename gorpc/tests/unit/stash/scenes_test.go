@@ -0,0 +1,35 @@
+package stash_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+)
+
+func TestCanonicalVideoFile_Longest(t *testing.T) {
+	scene := &stash.Scene{Files: []stash.VideoFile{
+		{Path: "/media/trailer.mp4", Duration: 90},
+		{Path: "/media/full.mp4", Duration: 1800},
+		{Path: "/media/preview.mp4", Duration: 30},
+	}}
+
+	file := stash.CanonicalVideoFile(scene)
+	require.NotNil(t, file)
+	assert.Equal(t, "/media/full.mp4", file.Path)
+}
+
+func TestCanonicalVideoFile_TieUsesFirst(t *testing.T) {
+	scene := &stash.Scene{Files: []stash.VideoFile{
+		{Path: "/media/a.mp4", Duration: 60},
+		{Path: "/media/b.mp4", Duration: 60},
+	}}
+
+	assert.Equal(t, "/media/a.mp4", stash.CanonicalVideoFile(scene).Path)
+}
+
+func TestCanonicalVideoFile_NoFiles(t *testing.T) {
+	assert.Nil(t, stash.CanonicalVideoFile(&stash.Scene{}))
+}
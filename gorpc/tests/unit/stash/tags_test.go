@@ -0,0 +1,138 @@
+package stash_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	graphql "github.com/hasura/go-graphql-client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/stash"
+)
+
+func TestMergeTagIDs_NoOpWhenAlreadyTagged(t *testing.T) {
+	current := []stash.Tag{{ID: "1", Name: "Scanned"}, {ID: "2", Name: "Matched"}}
+
+	tagIDs, changed := stash.MergeTagIDs(current, []graphql.ID{"1"}, []graphql.ID{"3"})
+	assert.False(t, changed)
+	assert.Equal(t, []graphql.ID{"1", "2"}, tagIDs)
+}
+
+func TestMergeTagIDs_AddAndRemove(t *testing.T) {
+	current := []stash.Tag{{ID: "1"}, {ID: "2"}}
+
+	tagIDs, changed := stash.MergeTagIDs(current, []graphql.ID{"3", "3", ""}, []graphql.ID{"2"})
+	assert.True(t, changed)
+	assert.Equal(t, []graphql.ID{"1", "3"}, tagIDs)
+}
+
+func TestMergeTagIDs_RemoveOnly(t *testing.T) {
+	current := []stash.Tag{{ID: "1"}}
+
+	tagIDs, changed := stash.MergeTagIDs(current, nil, []graphql.ID{"1"})
+	assert.True(t, changed)
+	assert.Empty(t, tagIDs)
+}
+
+func TestFindTagIDs_SkipsMissingWithoutCreating(t *testing.T) {
+	existing := map[string]string{"AI: Exclude": "42"}
+	mutations := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string `json:"query"`
+			Variables struct {
+				Filter struct {
+					Name struct {
+						Value string `json:"value"`
+					} `json:"name"`
+				} `json:"filter"`
+			} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if strings.HasPrefix(req.Query, "mutation") {
+			mutations++
+		}
+
+		tags := []map[string]interface{}{}
+		if id, ok := existing[req.Variables.Filter.Name.Value]; ok {
+			tags = append(tags, map[string]interface{}{"id": id, "name": req.Variables.Filter.Name.Value})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"findTags": map[string]interface{}{
+					"count": len(tags),
+					"tags":  tags,
+				},
+			},
+		})
+	}))
+	defer server.Close()
+
+	client := stash.TestClient(server.URL, http.DefaultClient)
+
+	tagIDs, err := stash.FindTagIDs(client, stash.NewTagCache(), []string{"AI: Exclude", "Not A Tag"})
+	require.NoError(t, err)
+	assert.Equal(t, []graphql.ID{"42"}, tagIDs)
+	assert.Zero(t, mutations)
+}
+
+func TestGetOrCreateTag_NestsUnderParent(t *testing.T) {
+	existing := map[string]string{"Compreface Scanned": "7"}
+	var updates []map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		var data map[string]interface{}
+		switch {
+		case strings.Contains(req.Query, "findTags"):
+			name := req.Variables["filter"].(map[string]interface{})["name"].(map[string]interface{})["value"].(string)
+			tags := []map[string]interface{}{}
+			if id, ok := existing[name]; ok {
+				tags = append(tags, map[string]interface{}{"id": id, "name": name})
+			}
+			data = map[string]interface{}{"findTags": map[string]interface{}{"count": len(tags), "tags": tags}}
+		case strings.Contains(req.Query, "tagCreate"):
+			name := req.Variables["input"].(map[string]interface{})["name"].(string)
+			existing[name] = "99"
+			data = map[string]interface{}{"tagCreate": map[string]interface{}{"id": "99", "name": name}}
+		case strings.Contains(req.Query, "findTag("):
+			data = map[string]interface{}{"findTag": map[string]interface{}{
+				"parents": []map[string]interface{}{{"id": "3"}},
+			}}
+		case strings.Contains(req.Query, "tagUpdate"):
+			updates = append(updates, req.Variables["input"].(map[string]interface{}))
+			data = map[string]interface{}{"tagUpdate": map[string]interface{}{"id": "7"}}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer server.Close()
+
+	client := stash.TestClient(server.URL, http.DefaultClient)
+	cache := stash.NewTagCache()
+	cache.SetParentTag("Compreface")
+
+	for i := 0; i < 2; i++ {
+		tagID, err := stash.GetOrCreateTag(client, cache, "", "Compreface Scanned")
+		require.NoError(t, err)
+		assert.Equal(t, graphql.ID("7"), tagID)
+	}
+
+	// The parent is created once and added beside the tag's existing parent
+	require.Len(t, updates, 1)
+	assert.Equal(t, "7", updates[0]["id"])
+	assert.Equal(t, []interface{}{"3", "99"}, updates[0]["parent_ids"])
+}
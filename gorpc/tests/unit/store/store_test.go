@@ -0,0 +1,374 @@
+package store_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/store"
+)
+
+func openStore(t *testing.T, path string) *store.Store {
+	t.Helper()
+	s, err := store.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestStore_PersistsAcrossOpens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "faces.jsonl")
+	embedding := []float64{0.1, 0.2, 0.3}
+	hash := store.FaceHash(embedding)
+
+	s := openStore(t, path)
+	require.NoError(t, s.Put(store.Face{Source: "image:1", Hash: hash, Embedding: embedding, PerformerID: "7", Similarity: 0.9}))
+	require.NoError(t, s.Close())
+
+	reopened := openStore(t, path)
+	face, ok := reopened.Get("image:1", hash)
+	require.True(t, ok)
+	assert.Equal(t, "7", face.PerformerID)
+	assert.Equal(t, 0.9, face.Similarity)
+}
+
+func TestStore_LaterRecordWins(t *testing.T) {
+	s := openStore(t, filepath.Join(t.TempDir(), "faces.jsonl"))
+
+	require.NoError(t, s.Put(store.Face{Source: "scene:3", Hash: "h", PerformerID: "1"}))
+	require.NoError(t, s.Put(store.Face{Source: "scene:3", Hash: "h", PerformerID: "2"}))
+
+	face, _ := s.Get("scene:3", "h")
+	assert.Equal(t, "2", face.PerformerID)
+	assert.Equal(t, 1, s.Len())
+}
+
+func TestStore_ForgetPerformer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faces.jsonl")
+	s := openStore(t, path)
+	require.NoError(t, s.Put(store.Face{Source: "image:1", Hash: "a", PerformerID: "1"}))
+	require.NoError(t, s.Put(store.Face{Source: "image:2", Hash: "b", PerformerID: "2"}))
+
+	require.NoError(t, s.ForgetPerformer("1"))
+	_, ok := s.Get("image:1", "a")
+	assert.False(t, ok)
+	require.NoError(t, s.Close())
+
+	reopened := openStore(t, path)
+	assert.Equal(t, 1, reopened.Len())
+}
+
+func TestStore_Performers(t *testing.T) {
+	s := openStore(t, filepath.Join(t.TempDir(), "faces.jsonl"))
+	require.NoError(t, s.Put(store.Face{Source: "image:1", Hash: "a", PerformerID: "2"}))
+	require.NoError(t, s.Put(store.Face{Source: "image:1", Hash: "b", PerformerID: "1"}))
+	require.NoError(t, s.Put(store.Face{Source: "image:1", Hash: "c", PerformerID: "2"}))
+	require.NoError(t, s.Put(store.Face{Source: "scene:1", Hash: "d", PerformerID: "3"}))
+
+	assert.Equal(t, []string{"1", "2"}, s.Performers("image:1"))
+	assert.Empty(t, s.Performers("image:9"))
+}
+
+func TestStore_SkipsTornLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faces.jsonl")
+	content := `{"source":"image:1","hash":"a","performer_id":"1"}` + "\n" + `{"source":"image:2","ha`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	s := openStore(t, path)
+	assert.Equal(t, 1, s.Len())
+}
+
+func TestStore_Nearest(t *testing.T) {
+	s := openStore(t, filepath.Join(t.TempDir(), "faces.jsonl"))
+	require.NoError(t, s.Put(store.Face{Source: "image:1", Hash: "a", Embedding: []float64{1, 0, 0}, PerformerID: "1"}))
+	require.NoError(t, s.Put(store.Face{Source: "image:2", Hash: "b", Embedding: []float64{0, 1, 0}, PerformerID: "2"}))
+
+	face, similarity, ok := s.Nearest([]float64{0.1, 0.95, 0}, 0.8)
+	require.True(t, ok)
+	assert.Equal(t, "2", face.PerformerID)
+	assert.Greater(t, similarity, 0.99)
+
+	_, _, ok = s.Nearest([]float64{0, 0, 1}, 0.8)
+	assert.False(t, ok)
+}
+
+func TestFaceHash_ToleratesNoise(t *testing.T) {
+	assert.Equal(t, store.FaceHash([]float64{0.1234, -0.5}), store.FaceHash([]float64{0.12341, -0.50001}))
+	assert.NotEqual(t, store.FaceHash([]float64{0.1234, -0.5}), store.FaceHash([]float64{0.2234, -0.5}))
+}
+
+func TestJobProfiles_PersistAcrossOpens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "job_profiles.jsonl")
+
+	profiles, err := store.OpenJobProfiles(path)
+	require.NoError(t, err)
+	require.NoError(t, profiles.Put(store.JobProfile{Source: "scene:1", SamplingInterval: 4, MaxFaces: 50, Enhancement: true}))
+	require.NoError(t, profiles.Put(store.JobProfile{Source: "scene:1", SamplingInterval: 8, MaxFaces: 20}))
+	require.NoError(t, profiles.Close())
+
+	reopened, err := store.OpenJobProfiles(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	profile, ok := reopened.Get("scene:1")
+	require.True(t, ok)
+	assert.Equal(t, 8.0, profile.SamplingInterval)
+	assert.Equal(t, 20, profile.MaxFaces)
+	assert.False(t, profile.Enhancement)
+
+	_, ok = reopened.Get("scene:2")
+	assert.False(t, ok)
+}
+
+func TestCheckpoints_PersistAndClear(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "checkpoints.jsonl")
+
+	checkpoints, err := store.OpenCheckpoints(path)
+	require.NoError(t, err)
+	require.NoError(t, checkpoints.Put(store.Checkpoint{Task: "recognizeImages", Pass: 1, Page: 3, Processed: 60}))
+	require.NoError(t, checkpoints.Put(store.Checkpoint{Task: "recognizeAllScenes", LastID: 42, Processed: 5}))
+	require.NoError(t, checkpoints.Clear("recognizeAllScenes"))
+	require.NoError(t, checkpoints.Close())
+
+	reopened, err := store.OpenCheckpoints(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	checkpoint, ok := reopened.Get("recognizeImages")
+	require.True(t, ok)
+	assert.Equal(t, 1, checkpoint.Pass)
+	assert.Equal(t, 3, checkpoint.Page)
+	assert.Equal(t, 60, checkpoint.Processed)
+
+	_, ok = reopened.Get("recognizeAllScenes")
+	assert.False(t, ok, "cleared checkpoint must not survive a reopen")
+	assert.NoError(t, reopened.Clear("identifyImagesAll"), "clearing a missing checkpoint is a no-op")
+}
+
+func TestReviewQueue_AddResolveAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "review.jsonl")
+
+	queue, err := store.OpenReviewQueue(path)
+	require.NoError(t, err)
+	face, added, err := queue.Add(store.PendingFace{Source: "image:1", Face: "0", AgeLow: 25, AgeHigh: 32, Gender: "female"}, []byte("crop"))
+	require.NoError(t, err)
+	require.True(t, added)
+	assert.Equal(t, store.ReviewPending, face.Status)
+	assert.Equal(t, store.PendingFaceID("image:1", "0"), face.ID)
+
+	_, added, err = queue.Add(store.PendingFace{Source: "image:1", Face: "0"}, []byte("crop"))
+	require.NoError(t, err)
+	assert.False(t, added, "a queued face must not be queued twice")
+
+	other, _, err := queue.Add(store.PendingFace{Source: "scene:2", Face: "face_1"}, []byte("other"))
+	require.NoError(t, err)
+	crop, err := queue.Crop(other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("other"), crop)
+
+	require.NoError(t, queue.Resolve(face.ID, store.ReviewRejected, ""))
+	assert.Equal(t, 0, queue.PendingForSource("image:1"))
+	require.NoError(t, queue.Close())
+
+	reopened, err := store.OpenReviewQueue(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	pending := reopened.Pending()
+	require.Len(t, pending, 1)
+	assert.Equal(t, "scene:2", pending[0].Source)
+
+	rejected, ok := reopened.Get(face.ID)
+	require.True(t, ok)
+	assert.Equal(t, store.ReviewRejected, rejected.Status)
+	_, err = os.Stat(reopened.CropPath(face.ID))
+	assert.True(t, os.IsNotExist(err), "resolved faces must not keep their crop")
+
+	_, added, err = reopened.Add(store.PendingFace{Source: "image:1", Face: "0"}, []byte("crop"))
+	require.NoError(t, err)
+	assert.False(t, added, "a rejected face must not be queued again")
+}
+
+func TestBackup_ChunksAndRestoresInOrder(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "backups")
+
+	unfinished, err := store.CreateBackup(root, "resetUnmatchedImages")
+	require.NoError(t, err)
+	require.NoError(t, unfinished.Add(store.BackupRecord{Kind: store.BackupImage, ID: "1"}))
+
+	backup, err := store.CreateBackup(root, "mergeDuplicatePerformers")
+	require.NoError(t, err)
+	require.NoError(t, backup.Add(store.BackupRecord{Kind: store.BackupPerformer, ID: "7", Name: "Person 1"}))
+	for i := 0; i < 1200; i++ {
+		require.NoError(t, backup.Add(store.BackupRecord{Kind: store.BackupImage, ID: fmt.Sprint(i), Performers: []string{"7"}}))
+	}
+	require.NoError(t, backup.AddFace("abc", []byte("face")))
+	require.NoError(t, backup.Close())
+
+	manifests, err := store.ListBackups(root)
+	require.NoError(t, err)
+	require.Len(t, manifests, 1, "unfinished backups must not be listed")
+	assert.Equal(t, 1201, manifests[0].Records)
+	assert.Equal(t, 3, manifests[0].Chunks)
+
+	opened, err := store.OpenBackup(root, "")
+	require.NoError(t, err)
+	assert.Equal(t, backup.Name(), opened.Manifest.Name)
+
+	var records []store.BackupRecord
+	require.NoError(t, opened.Each(func(chunk []store.BackupRecord) error {
+		records = append(records, chunk...)
+		return nil
+	}))
+	require.Len(t, records, 1201)
+	assert.Equal(t, store.BackupPerformer, records[0].Kind)
+	assert.Equal(t, "1199", records[1200].ID)
+
+	face, err := opened.Face("abc")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("face"), face)
+
+	require.NoError(t, opened.MarkRestored())
+	reopened, err := store.OpenBackup(root, backup.Name())
+	require.NoError(t, err)
+	assert.NotNil(t, reopened.Manifest.Restored)
+
+	_, err = store.OpenBackup(root, unfinished.Name())
+	assert.Error(t, err)
+}
+
+func TestFailures_CountPersistAndClear(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failures.jsonl")
+
+	failures, err := store.OpenFailures(path)
+	require.NoError(t, err)
+	require.NoError(t, failures.Record("image:1"))
+	require.NoError(t, failures.Record("image:1"))
+	require.NoError(t, failures.Record("scene:7"))
+	require.NoError(t, failures.Clear("scene:7"))
+	require.NoError(t, failures.Clear("scene:8")) // Never failed
+	require.NoError(t, failures.Close())
+
+	reopened, err := store.OpenFailures(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	assert.Equal(t, 2, reopened.Count("image:1"))
+	assert.Zero(t, reopened.Count("scene:7"))
+	assert.Zero(t, reopened.Count("scene:8"))
+}
+
+func TestProcessedGalleries_AddResetAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "galleries.jsonl")
+
+	galleries, err := store.OpenProcessedGalleries(path)
+	require.NoError(t, err)
+	require.NoError(t, galleries.Add("3", []string{"10", "11"}))
+	require.NoError(t, galleries.Add("3", []string{"12"}))
+	require.NoError(t, galleries.Add("4", []string{"20"}))
+	require.NoError(t, galleries.Reset("4"))
+	require.NoError(t, galleries.Reset("5")) // Never processed
+	require.NoError(t, galleries.Close())
+
+	reopened, err := store.OpenProcessedGalleries(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	assert.Equal(t, 3, reopened.Count("3"))
+	assert.True(t, reopened.Processed("3", "12"))
+	assert.False(t, reopened.Processed("3", "13"))
+	assert.Zero(t, reopened.Count("4"))
+	assert.False(t, reopened.Processed("4", "20"))
+}
+
+func TestMappingExport_WritesAndReadsBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exports", "mappings.json")
+
+	writer, err := store.CreateMappingExport(path)
+	require.NoError(t, err)
+	require.NoError(t, writer.Add(store.SubjectMapping{
+		Subject:       "Person 12 ABCDEF",
+		PerformerID:   "42",
+		PerformerName: "Jane Doe",
+		Faces:         []string{"face-1", "face-2"},
+		Examples:      [][]byte{{0xFF, 0xD8, 0x01}},
+	}))
+	require.NoError(t, writer.Add(store.SubjectMapping{Subject: "Unlinked"}))
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "export must not appear before it is complete")
+	require.NoError(t, writer.Close())
+
+	export, err := store.ReadMappingExport(path)
+	require.NoError(t, err)
+	assert.Equal(t, store.MappingExportVersion, export.Version)
+	assert.False(t, export.Exported.IsZero())
+	require.Len(t, export.Mappings, 2)
+	assert.Equal(t, "42", export.Mappings[0].PerformerID)
+	assert.Equal(t, []string{"face-1", "face-2"}, export.Mappings[0].Faces)
+	assert.Equal(t, [][]byte{{0xFF, 0xD8, 0x01}}, export.Mappings[0].Examples)
+	assert.Equal(t, "Unlinked", export.Mappings[1].Subject)
+}
+
+func TestMappingExport_DiscardLeavesNoFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mappings.json")
+
+	writer, err := store.CreateMappingExport(path)
+	require.NoError(t, err)
+	require.NoError(t, writer.Add(store.SubjectMapping{Subject: "Person 1 A"}))
+	writer.Discard()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestClusters_AddAssignAndClearSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clusters.jsonl")
+	clusters, err := store.OpenClusters(path)
+	require.NoError(t, err)
+
+	detection := func(ts float64) []store.ClusterDetection {
+		return []store.ClusterDetection{{Timestamp: ts, XMin: 10, YMin: 10, XMax: 60, YMax: 70, Quality: 0.8}}
+	}
+	first, added, err := clusters.Add(store.UnmatchedCluster{Source: "scene:7", Face: "face_0", Detections: detection(12)}, []byte("thumb"))
+	require.NoError(t, err)
+	assert.True(t, added)
+	assert.Equal(t, store.ClusterOpen, first.Status)
+	second, _, err := clusters.Add(store.UnmatchedCluster{Source: "scene:7", Face: "face_1", Detections: detection(30)}, []byte("thumb"))
+	require.NoError(t, err)
+	_, _, err = clusters.Add(store.UnmatchedCluster{Source: "scene:8", Face: "face_0", Detections: detection(5)}, []byte("thumb"))
+	require.NoError(t, err)
+
+	thumbnail, err := clusters.Thumbnail(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("thumb"), thumbnail)
+	assert.Len(t, clusters.Unmatched("scene:7"), 2)
+	assert.Len(t, clusters.Unmatched(""), 3)
+
+	require.NoError(t, clusters.Assign(first.ID, "42"))
+	_, err = os.Stat(clusters.ThumbnailPath(first.ID))
+	assert.True(t, os.IsNotExist(err))
+
+	// A rescan replaces the unassigned clusters but keeps the assignment
+	require.NoError(t, clusters.ClearSource("scene:7"))
+	_, added, err = clusters.Add(store.UnmatchedCluster{Source: "scene:7", Face: "face_3", Detections: detection(12)}, []byte("thumb"))
+	require.NoError(t, err)
+	assert.False(t, added)
+	require.NoError(t, clusters.Close())
+
+	reopened, err := store.OpenClusters(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	assigned, ok := reopened.Get(first.ID)
+	require.True(t, ok)
+	assert.Equal(t, store.ClusterAssigned, assigned.Status)
+	assert.Equal(t, "42", assigned.PerformerID)
+	_, ok = reopened.Get(second.ID)
+	assert.False(t, ok)
+	assert.Empty(t, reopened.Unmatched("scene:7"))
+	assert.Len(t, reopened.Unmatched("scene:8"), 1)
+}
@@ -0,0 +1,102 @@
+package throttle_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/throttle"
+)
+
+func TestRateLimiter_SpacesCalls(t *testing.T) {
+	limiter := throttle.NewRateLimiter(50) // 20ms apart
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		limiter.Wait()
+	}
+
+	// First call is immediate, the next three wait one interval each
+	assert.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+}
+
+func TestRateLimiter_DisabledNeverWaits(t *testing.T) {
+	limiter := throttle.NewRateLimiter(0)
+	assert.Nil(t, limiter)
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		limiter.Wait()
+	}
+	assert.Less(t, time.Since(start), 10*time.Millisecond)
+}
+
+func TestSemaphore_BoundsHolders(t *testing.T) {
+	sem := throttle.NewSemaphore(1)
+	sem.Acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		sem.Acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second holder acquired a full semaphore")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	sem.Release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("slot was not handed over after release")
+	}
+}
+
+func TestSemaphore_TryAcquire(t *testing.T) {
+	sem := throttle.NewSemaphore(2)
+	assert.True(t, sem.TryAcquire())
+	assert.True(t, sem.TryAcquire())
+	assert.False(t, sem.TryAcquire(), "full semaphore must not block or hand out a slot")
+
+	sem.Release()
+	assert.True(t, sem.TryAcquire())
+
+	var unbounded throttle.Semaphore
+	assert.False(t, unbounded.TryAcquire())
+}
+
+func TestForEach_BoundsConcurrency(t *testing.T) {
+	var running, peak, calls int32
+	items := make([]int, 20)
+
+	throttle.ForEach(items, 3, func(int) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&calls, 1)
+	})
+
+	assert.Equal(t, int32(20), calls)
+	assert.LessOrEqual(t, peak, int32(3))
+	assert.Greater(t, peak, int32(1))
+}
+
+func TestForEach_SingleWorkerIsSequential(t *testing.T) {
+	var order []int
+	throttle.ForEach([]int{1, 2, 3}, 1, func(i int) {
+		order = append(order, i)
+	})
+
+	assert.Equal(t, []int{1, 2, 3}, order)
+}
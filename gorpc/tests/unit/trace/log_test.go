@@ -0,0 +1,25 @@
+package trace_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace/log"
+)
+
+func TestScrub_RedactsRegisteredSecrets(t *testing.T) {
+	log.RegisterSecret("3f9a1c2e-recognition-key")
+	log.RegisterSecret("vision-bearer-token")
+
+	msg := log.Scrub("GET http://compreface:8000/api/v1/static/3f9a1c2e-recognition-key/images/1 (token vision-bearer-token)")
+
+	assert.Equal(t, "GET http://compreface:8000/api/v1/static/[REDACTED]/images/1 (token [REDACTED])", msg)
+}
+
+func TestRegisterSecret_IgnoresShortValues(t *testing.T) {
+	log.RegisterSecret("")
+	log.RegisterSecret("test")
+
+	assert.Equal(t, "test mode enabled", log.Scrub("test mode enabled"))
+}
@@ -0,0 +1,126 @@
+package trace_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace"
+)
+
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Status       *struct {
+		Code int `json:"code"`
+	} `json:"status"`
+}
+
+func TestExport_NestsStagesUnderItem(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	var spans []exportedSpan
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, r.URL.Path)
+		for _, resource := range request.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				spans = append(spans, scope.Spans...)
+			}
+		}
+	}))
+	defer server.Close()
+
+	trace.EnableExport(server.URL)
+	end := trace.Start("img-42-3f9a1c")
+	trace.StartSpan(trace.StageDetect).End(nil)
+	trace.Set(trace.Face("img-42-3f9a1c", 0))
+	trace.StartSpan(trace.StageCrop).End(errors.New("bad box"))
+	end()
+	// A write queued for the item that finishes after it
+	trace.StartSpanIn("img-42-3f9a1c", trace.StageMutate).End(nil)
+	require.NoError(t, trace.StopExport())
+
+	assert.Equal(t, []string{"/v1/traces"}, paths)
+	require.Len(t, spans, 4)
+	byName := map[string]exportedSpan{}
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+	root := byName["img"]
+	assert.Empty(t, root.ParentSpanID)
+	for _, stage := range []string{trace.StageDetect, trace.StageCrop, trace.StageMutate} {
+		assert.Equal(t, root.TraceID, byName[stage].TraceID, stage)
+		assert.Equal(t, root.SpanID, byName[stage].ParentSpanID, stage)
+	}
+	require.NotNil(t, byName[trace.StageCrop].Status)
+	assert.Equal(t, 2, byName[trace.StageCrop].Status.Code)
+	assert.Nil(t, byName[trace.StageDetect].Status)
+}
+
+func TestStartSpan_NoopWhenExportDisabled(t *testing.T) {
+	span := trace.StartSpan(trace.StageFetch)
+	assert.Nil(t, span)
+	span.SetAttribute("key", "value")
+	span.End(nil)
+	assert.NoError(t, trace.StopExport())
+}
+
+func TestStartSpanContext_UsesItemTraceOverActive(t *testing.T) {
+	var mu sync.Mutex
+	var spans []exportedSpan
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		mu.Lock()
+		defer mu.Unlock()
+		for _, resource := range request.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				spans = append(spans, scope.Spans...)
+			}
+		}
+	}))
+	defer server.Close()
+
+	trace.EnableExport(server.URL)
+	// Another image holds the active trace while img-1 is detected
+	end := trace.Start("img-2-bbbbbb")
+	trace.StartSpanContext(trace.NewContext(context.Background(), "img-1-aaaaaa"), trace.StageDetect).End(nil)
+	trace.StartSpanContext(context.Background(), trace.StageFetch).End(nil)
+	end()
+	trace.StartSpanIn("img-1-aaaaaa", trace.StageMutate).End(nil)
+	require.NoError(t, trace.StopExport())
+
+	require.Len(t, spans, 4)
+	byName := map[string]exportedSpan{}
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+	assert.Equal(t, byName[trace.StageMutate].TraceID, byName[trace.StageDetect].TraceID)
+	assert.Equal(t, byName["img"].TraceID, byName[trace.StageFetch].TraceID)
+	assert.NotEqual(t, byName[trace.StageDetect].TraceID, byName[trace.StageFetch].TraceID)
+}
@@ -0,0 +1,102 @@
+package trace_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/trace"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+func TestNew_UniquePerItem(t *testing.T) {
+	a := trace.New("img", "42")
+	b := trace.New("img", "42")
+
+	assert.Regexp(t, `^img-42-[0-9a-f]{6}$`, a)
+	assert.NotEqual(t, a, b)
+	assert.Equal(t, a+".f2", trace.Face(a, 2))
+}
+
+func TestStart_RestoresPreviousTrace(t *testing.T) {
+	endOuter := trace.Start("scn-1-aaaaaa")
+	endInner := trace.Start("img-2-bbbbbb")
+	assert.Equal(t, "img-2-bbbbbb", trace.Current())
+
+	endInner()
+	assert.Equal(t, "scn-1-aaaaaa", trace.Current())
+
+	endOuter()
+	assert.Empty(t, trace.Current())
+}
+
+func TestVisionServiceClient_SendsTraceHeader(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(trace.Header))
+		w.Write([]byte(`{"status":"healthy"}`))
+	}))
+	defer server.Close()
+
+	client := vision.NewVisionServiceClient(server.URL, server.URL)
+
+	require.NoError(t, client.HealthCheck())
+
+	end := trace.Start("img-7-cccccc.f0")
+	require.NoError(t, client.HealthCheck())
+	end()
+
+	assert.Equal(t, []string{"", "img-7-cccccc.f0"}, received)
+}
+
+func TestBegin_YieldLetsAnotherItemRun(t *testing.T) {
+	first := trace.NewContext(context.Background(), "img-1-aaaaaa")
+	second := trace.NewContext(context.Background(), "img-2-bbbbbb")
+
+	endFirst := trace.Begin(first)
+	resume := trace.Yield(trace.NewContext(context.Background(), trace.Face("img-1-aaaaaa", 0)))
+
+	done := make(chan string)
+	go func() {
+		defer trace.Begin(second)()
+		done <- trace.Current()
+	}()
+	assert.Equal(t, "img-2-bbbbbb", <-done)
+
+	resume()
+	assert.Equal(t, "img-1-aaaaaa", trace.Current())
+	endFirst()
+	assert.Empty(t, trace.Current())
+}
+
+func TestYield_IgnoresItemsWithoutTheTurn(t *testing.T) {
+	end := trace.Begin(trace.NewContext(context.Background(), "img-3-cccccc"))
+	defer end()
+
+	trace.Yield(context.Background())()
+	trace.Yield(trace.NewContext(context.Background(), "img-4-dddddd"))()
+	assert.Equal(t, "img-3-cccccc", trace.Current())
+}
+
+func TestVisionServiceClient_SendsContextTraceHeader(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(trace.Header))
+		w.Write([]byte(`{"status":"healthy"}`))
+	}))
+	defer server.Close()
+
+	client := vision.NewVisionServiceClient(server.URL, server.URL)
+	itemClient := client.WithContext(trace.NewContext(context.Background(), "img-8-dddddd"))
+
+	end := trace.Start("img-9-eeeeee")
+	require.NoError(t, itemClient.HealthCheck())
+	require.NoError(t, client.HealthCheck())
+	end()
+
+	assert.Equal(t, []string{"img-8-dddddd", "img-9-eeeeee"}, received)
+}
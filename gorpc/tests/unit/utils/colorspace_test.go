@@ -0,0 +1,254 @@
+package utils_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/pkg/utils"
+)
+
+// Colorant columns (D50-adapted XYZ) for test profiles
+var (
+	srgbColorants = [3][3]float64{
+		{0.4361, 0.2225, 0.0139}, // rXYZ
+		{0.3851, 0.7169, 0.0971}, // gXYZ
+		{0.1431, 0.0606, 0.7141}, // bXYZ
+	}
+	adobeRGBColorants = [3][3]float64{
+		{0.6097, 0.3111, 0.0195},
+		{0.2053, 0.6257, 0.0609},
+		{0.1492, 0.0632, 0.7446},
+	}
+)
+
+// buildICCProfile builds a minimal RGB matrix/TRC ICC profile
+func buildICCProfile(colorants [3][3]float64, trc []byte) []byte {
+	type tag struct {
+		sig  string
+		data []byte
+	}
+	xyzTag := func(xyz [3]float64) []byte {
+		b := append([]byte("XYZ "), 0, 0, 0, 0)
+		for _, v := range xyz {
+			b = binary.BigEndian.AppendUint32(b, uint32(int32(v*65536)))
+		}
+		return b
+	}
+	tags := []tag{
+		{"rXYZ", xyzTag(colorants[0])},
+		{"gXYZ", xyzTag(colorants[1])},
+		{"bXYZ", xyzTag(colorants[2])},
+		{"rTRC", trc},
+		{"gTRC", trc},
+		{"bTRC", trc},
+	}
+
+	header := make([]byte, 128)
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	copy(header[36:], "acsp")
+
+	table := binary.BigEndian.AppendUint32(nil, uint32(len(tags)))
+	offset := 128 + 4 + len(tags)*12
+	var body []byte
+	for _, t := range tags {
+		table = append(table, t.sig...)
+		table = binary.BigEndian.AppendUint32(table, uint32(offset+len(body)))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(t.data)))
+		body = append(body, t.data...)
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+	}
+
+	profile := append(append(header, table...), body...)
+	binary.BigEndian.PutUint32(profile[0:], uint32(len(profile)))
+	return profile
+}
+
+// gammaCurve builds a curv tag with a single gamma value
+func gammaCurve(gamma float64) []byte {
+	b := append([]byte("curv"), 0, 0, 0, 0)
+	b = binary.BigEndian.AppendUint32(b, 1)
+	return binary.BigEndian.AppendUint16(b, uint16(gamma*256))
+}
+
+// srgbCurve builds a para tag (function type 3) matching the sRGB transfer function
+func srgbCurve() []byte {
+	b := append([]byte("para"), 0, 0, 0, 0)
+	b = binary.BigEndian.AppendUint16(b, 3)
+	b = append(b, 0, 0)
+	for _, v := range []float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045} {
+		b = binary.BigEndian.AppendUint32(b, uint32(int32(v*65536)))
+	}
+	return b
+}
+
+// embedJPEGICC encodes img as JPEG with the profile split across APP2 chunks of chunkSize
+func embedJPEGICC(t *testing.T, img image.Image, profile []byte, chunkSize int) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}))
+	encoded := buf.Bytes()
+
+	var chunks [][]byte
+	for i := 0; i < len(profile); i += chunkSize {
+		end := i + chunkSize
+		if end > len(profile) {
+			end = len(profile)
+		}
+		chunks = append(chunks, profile[i:end])
+	}
+
+	out := append([]byte{}, encoded[:2]...)
+	for i, chunk := range chunks {
+		segment := append([]byte("ICC_PROFILE\x00"), byte(i+1), byte(len(chunks)))
+		segment = append(segment, chunk...)
+		out = append(out, 0xFF, 0xE2)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+		out = append(out, segment...)
+	}
+	return append(out, encoded[2:]...)
+}
+
+// embedPNGICC encodes img as PNG with an iCCP chunk
+func embedPNGICC(t *testing.T, img image.Image, profile []byte) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	encoded := buf.Bytes()
+
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	w.Write(profile)
+	w.Close()
+
+	data := append([]byte("test\x00\x00"), compressed.Bytes()...)
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, "iCCP"...)
+	chunk = append(chunk, data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(append([]byte("iCCP"), data...)))
+
+	// Insert after signature + IHDR (8 + 25 bytes)
+	out := append([]byte{}, encoded[:33]...)
+	out = append(out, chunk...)
+	return append(out, encoded[33:]...)
+}
+
+func solidImage(c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestExtractICCProfile_JPEGMultiChunk(t *testing.T) {
+	profile := buildICCProfile(adobeRGBColorants, gammaCurve(2.2))
+	data := embedJPEGICC(t, solidImage(color.RGBA{100, 150, 200, 255}), profile, 64)
+
+	assert.Equal(t, profile, utils.ExtractICCProfile(data))
+}
+
+func TestExtractICCProfile_PNG(t *testing.T) {
+	profile := buildICCProfile(adobeRGBColorants, gammaCurve(2.2))
+	data := embedPNGICC(t, solidImage(color.RGBA{100, 150, 200, 255}), profile)
+
+	assert.Equal(t, profile, utils.ExtractICCProfile(data))
+}
+
+func TestExtractICCProfile_None(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, solidImage(color.RGBA{1, 2, 3, 255}), nil))
+
+	assert.Nil(t, utils.ExtractICCProfile(buf.Bytes()))
+	assert.Nil(t, utils.ExtractICCProfile([]byte("not an image")))
+}
+
+func TestConvertToSRGB_SRGBProfileUnchanged(t *testing.T) {
+	img := solidImage(color.RGBA{100, 150, 200, 255})
+	profile := buildICCProfile(srgbColorants, srgbCurve())
+
+	result := utils.ConvertToSRGB(img, profile)
+	assert.Same(t, img, result, "sRGB profile should not trigger conversion")
+}
+
+func TestConvertToSRGB_NoProfileUnchanged(t *testing.T) {
+	img := solidImage(color.RGBA{100, 150, 200, 255})
+	assert.Same(t, img, utils.ConvertToSRGB(img, nil))
+}
+
+func TestConvertToSRGB_AdobeRGB(t *testing.T) {
+	profile := buildICCProfile(adobeRGBColorants, gammaCurve(2.2))
+
+	// Neutral grey stays neutral
+	grey := utils.ConvertToSRGB(solidImage(color.RGBA{128, 128, 128, 255}), profile)
+	r, g, b, _ := grey.At(0, 0).RGBA()
+	assert.InDelta(t, r>>8, g>>8, 2)
+	assert.InDelta(t, g>>8, b>>8, 2)
+	assert.InDelta(t, 128, int(g>>8), 6)
+
+	// Saturated Adobe RGB green is more saturated than sRGB can represent:
+	// red and blue drop, green is preserved or increased
+	green := utils.ConvertToSRGB(solidImage(color.RGBA{40, 180, 40, 255}), profile)
+	r, g, b, _ = green.At(0, 0).RGBA()
+	assert.Less(t, int(r>>8), 40)
+	assert.GreaterOrEqual(t, int(g>>8), 180)
+	assert.Less(t, int(b>>8), 40)
+}
+
+func TestDecodeImageSRGB_ConvertsEmbeddedProfile(t *testing.T) {
+	profile := buildICCProfile(adobeRGBColorants, gammaCurve(2.2))
+	data := embedPNGICC(t, solidImage(color.RGBA{40, 180, 40, 255}), profile)
+
+	img, format, err := utils.DecodeImageSRGB(data)
+	require.NoError(t, err)
+	assert.Equal(t, "png", format)
+
+	r, _, _, _ := img.At(0, 0).RGBA()
+	assert.Less(t, int(r>>8), 40, "decoded image should be converted to sRGB")
+}
+
+func TestConvertToSRGB_CMYK(t *testing.T) {
+	cmyk := image.NewCMYK(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(cmyk.Pix); i += 4 {
+		cmyk.Pix[i], cmyk.Pix[i+1], cmyk.Pix[i+2], cmyk.Pix[i+3] = 0, 255, 255, 0 // Pure red
+	}
+
+	result := utils.ConvertToSRGB(cmyk, nil)
+	_, isCMYK := result.(*image.CMYK)
+	assert.False(t, isCMYK)
+
+	r, g, b, _ := result.At(0, 0).RGBA()
+	assert.Equal(t, uint32(255), r>>8)
+	assert.Equal(t, uint32(0), g>>8)
+	assert.Equal(t, uint32(0), b>>8)
+}
+
+func TestIsSRGBJPEG(t *testing.T) {
+	var plain bytes.Buffer
+	require.NoError(t, jpeg.Encode(&plain, solidImage(color.RGBA{100, 150, 200, 255}), nil))
+	assert.True(t, utils.IsSRGBJPEG(plain.Bytes()))
+
+	srgb := embedJPEGICC(t, solidImage(color.RGBA{100, 150, 200, 255}), buildICCProfile(srgbColorants, srgbCurve()), 1024)
+	assert.True(t, utils.IsSRGBJPEG(srgb))
+
+	adobe := embedJPEGICC(t, solidImage(color.RGBA{100, 150, 200, 255}), buildICCProfile(adobeRGBColorants, gammaCurve(2.2)), 1024)
+	assert.False(t, utils.IsSRGBJPEG(adobe))
+
+	var pngBuf bytes.Buffer
+	require.NoError(t, png.Encode(&pngBuf, solidImage(color.RGBA{100, 150, 200, 255})))
+	assert.False(t, utils.IsSRGBJPEG(pngBuf.Bytes()))
+	assert.False(t, utils.IsSRGBJPEG([]byte("not an image")))
+}
@@ -0,0 +1,112 @@
+package utils_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/pkg/utils"
+)
+
+// encodeTestJPEG encodes a blank JPEG of the given size
+func encodeTestJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)), nil))
+	return buf.Bytes()
+}
+
+// buildTestRaw builds a little-endian TIFF whose IFD0 holds an orientation
+// and a thumbnail via the JPEG interchange tags, and whose sub-IFD holds a
+// larger preview as a JPEG-compressed strip
+func buildTestRaw(orientation uint16, thumbnail, preview []byte) []byte {
+	const ifd0Offset = 8
+	const ifd0Entries = 4
+	subIFDOffset := ifd0Offset + 2 + ifd0Entries*12 + 4
+	const subEntries = 3
+	dataOffset := subIFDOffset + 2 + subEntries*12 + 4
+
+	buf := new(bytes.Buffer)
+	le := binary.LittleEndian
+	write := func(v any) { _ = binary.Write(buf, le, v) }
+	entry := func(tag, kind uint16, count, value uint32) {
+		write(tag)
+		write(kind)
+		write(count)
+		write(value)
+	}
+
+	buf.WriteString("II")
+	write(uint16(42))
+	write(uint32(ifd0Offset))
+
+	write(uint16(ifd0Entries))
+	entry(0x0112, 3, 1, uint32(orientation))
+	entry(0x014A, 4, 1, uint32(subIFDOffset))
+	entry(0x0201, 4, 1, uint32(dataOffset))
+	entry(0x0202, 4, 1, uint32(len(thumbnail)))
+	write(uint32(0))
+
+	write(uint16(subEntries))
+	entry(0x0103, 3, 1, 6)
+	entry(0x0111, 4, 1, uint32(dataOffset+len(thumbnail)))
+	entry(0x0117, 4, 1, uint32(len(preview)))
+	write(uint32(0))
+
+	buf.Write(thumbnail)
+	buf.Write(preview)
+	return buf.Bytes()
+}
+
+func TestIsRawImagePath(t *testing.T) {
+	assert.True(t, utils.IsRawImagePath("/photos/IMG_0001.CR2"))
+	assert.True(t, utils.IsRawImagePath("/photos/DSC_0001.nef"))
+	assert.True(t, utils.IsRawImagePath("/photos/shot.dng"))
+	assert.False(t, utils.IsRawImagePath("/photos/shot.jpg"))
+	assert.False(t, utils.IsRawImagePath("/photos/clip.mp4"))
+}
+
+func TestExtractRawPreview_TIFFPicksLargestPreview(t *testing.T) {
+	thumbnail := encodeTestJPEG(t, 16, 12)
+	preview := encodeTestJPEG(t, 160, 120)
+
+	got, orientation, err := utils.ExtractRawPreview(buildTestRaw(6, thumbnail, preview))
+	require.NoError(t, err)
+	assert.Equal(t, preview, got)
+	assert.Equal(t, 6, orientation)
+}
+
+func TestExtractRawPreview_RAFHeader(t *testing.T) {
+	preview := encodeTestJPEG(t, 64, 48)
+	data := make([]byte, 100)
+	copy(data, "FUJIFILMCCD-RAW 0201FF383501")
+	binary.BigEndian.PutUint32(data[84:88], uint32(len(data)))
+	binary.BigEndian.PutUint32(data[88:92], uint32(len(preview)))
+	data = append(data, preview...)
+
+	got, orientation, err := utils.ExtractRawPreview(data)
+	require.NoError(t, err)
+	assert.Equal(t, preview, got)
+	assert.Equal(t, 1, orientation)
+}
+
+func TestExtractRawPreview_ScansUnknownContainers(t *testing.T) {
+	preview := encodeTestJPEG(t, 32, 32)
+	data := append([]byte("ftypcrx unknown container header"), preview...)
+
+	got, _, err := utils.ExtractRawPreview(data)
+	require.NoError(t, err)
+	config, err := jpeg.DecodeConfig(bytes.NewReader(got))
+	require.NoError(t, err)
+	assert.Equal(t, 32, config.Width)
+}
+
+func TestExtractRawPreview_NoPreview(t *testing.T) {
+	_, _, err := utils.ExtractRawPreview(buildTestRaw(1, nil, nil))
+	assert.ErrorIs(t, err, utils.ErrNoRawPreview)
+}
@@ -2,6 +2,7 @@ package utils_test
 
 import (
 	"testing"
+	"time"
 
 	graphql "github.com/hasura/go-graphql-client"
 	"github.com/stretchr/testify/assert"
@@ -200,3 +201,18 @@ func TestDeduplicateIDs(t *testing.T) {
 		})
 	}
 }
+
+func TestPercentile(t *testing.T) {
+	durations := []time.Duration{
+		10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond,
+		60 * time.Millisecond, 70 * time.Millisecond, 80 * time.Millisecond, 90 * time.Millisecond, 100 * time.Millisecond,
+	}
+
+	assert.Equal(t, 50*time.Millisecond, utils.Percentile(durations, 50))
+	assert.Equal(t, 90*time.Millisecond, utils.Percentile(durations, 90))
+	assert.Equal(t, 100*time.Millisecond, utils.Percentile(durations, 99))
+	assert.Equal(t, 10*time.Millisecond, utils.Percentile(durations, 0))
+	assert.Equal(t, 100*time.Millisecond, utils.Percentile(durations, 100))
+	assert.Equal(t, time.Duration(0), utils.Percentile(nil, 50))
+	assert.Equal(t, 5*time.Millisecond, utils.Percentile([]time.Duration{5 * time.Millisecond}, 90))
+}
@@ -0,0 +1,29 @@
+package version_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/version"
+)
+
+func TestIsTested(t *testing.T) {
+	tested := []string{"0.28", "0.29"}
+
+	assert.True(t, version.IsTested("v0.29.3", tested))
+	assert.True(t, version.IsTested("0.28.0", tested))
+	assert.True(t, version.IsTested("0.29", tested))
+	assert.False(t, version.IsTested("v0.27.2", tested))
+	assert.False(t, version.IsTested("v0.290.0", tested), "prefix must match whole components")
+	assert.False(t, version.IsTested("", tested))
+}
+
+func TestCompare(t *testing.T) {
+	assert.Equal(t, 0, version.Compare("v2.0.0", "2.0.0"))
+	assert.Equal(t, 1, version.Compare("v2.1.0", "2.0.0"))
+	assert.Equal(t, -1, version.Compare("2.0.0", "2.0.1"))
+	assert.Equal(t, 1, version.Compare("2.10.0", "2.9.0"))
+	assert.Equal(t, 0, version.Compare("2.0", "2.0.0"))
+	assert.Equal(t, 0, version.Compare("v2.0.0-rc1", "2.0.0"))
+}
@@ -0,0 +1,36 @@
+package vision_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+func faceAt(timestamps ...float64) vision.VisionFace {
+	face := vision.VisionFace{}
+	for _, ts := range timestamps {
+		face.Detections = append(face.Detections, vision.VisionDetection{Timestamp: ts})
+	}
+	return face
+}
+
+func TestAppearances_MergesWithinGap(t *testing.T) {
+	face := faceAt(30, 2, 4, 6, 34, 32)
+
+	assert.Equal(t, []vision.Appearance{
+		{Start: 2, End: 6},
+		{Start: 30, End: 34},
+	}, face.Appearances(4))
+}
+
+func TestAppearances_SingleDetection(t *testing.T) {
+	assert.Equal(t, []vision.Appearance{{Start: 12, End: 12}}, faceAt(12).Appearances(4))
+}
+
+func TestAppearances_FallsBackToRepresentative(t *testing.T) {
+	face := vision.VisionFace{RepresentativeDetection: vision.VisionDetection{Timestamp: 8}}
+
+	assert.Equal(t, []vision.Appearance{{Start: 8, End: 8}}, face.Appearances(4))
+}
@@ -0,0 +1,106 @@
+package vision_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/throttle"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+// newAuthRecordingServer answers Vision and frame server endpoints, recording
+// the Authorization header of every request
+func newAuthRecordingServer(headers *[]string) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*headers = append(*headers, r.Header.Get("Authorization"))
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/vision/health":
+			w.Write([]byte(`{"status":"healthy"}`))
+		case "/vision/analyze":
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"job_id":"job-1","status":"queued"}`))
+		case "/extract-frame":
+			w.Write([]byte("frame"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestVisionServiceClient_SendsBearerToken(t *testing.T) {
+	var headers []string
+	server := newAuthRecordingServer(&headers)
+	defer server.Close()
+
+	client := vision.NewVisionServiceClient(server.URL, server.URL)
+	client.Token = "secret"
+
+	require.NoError(t, client.HealthCheck())
+	_, err := client.SubmitJob(vision.AnalyzeRequest{Source: "/media/video.mp4", SourceID: "1"})
+	require.NoError(t, err)
+	_, err = client.ExtractFrame("/media/video.mp4", 1.0, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Bearer secret", "Bearer secret", "Bearer secret"}, headers)
+}
+
+func TestVisionServiceClient_NoTokenNoHeader(t *testing.T) {
+	var headers []string
+	server := newAuthRecordingServer(&headers)
+	defer server.Close()
+
+	client := vision.NewVisionServiceClient(server.URL, server.URL)
+
+	require.NoError(t, client.HealthCheck())
+	assert.Equal(t, []string{""}, headers)
+}
+
+func TestSubmitJob_FailedSubmissionReleasesJobSlot(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := vision.NewVisionServiceClient(server.URL, server.URL)
+	client.JobSlots = throttle.NewSemaphore(1)
+
+	// A leaked slot would block the second submission forever
+	for i := 0; i < 2; i++ {
+		_, err := client.SubmitJob(vision.AnalyzeRequest{Source: "/media/video.mp4", SourceID: "1"})
+		assert.Error(t, err)
+	}
+	assert.Len(t, client.JobSlots, 0)
+}
+
+func TestVisionServiceClient_TypedErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/extract-frame" {
+			http.Error(w, "bad timestamp", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}))
+	client := vision.NewVisionServiceClient(server.URL, server.URL)
+
+	_, err := client.SubmitJob(vision.AnalyzeRequest{Source: "/media/video.mp4", SourceID: "1"})
+	var statusErr *vision.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+	assert.ErrorIs(t, err, vision.ErrServiceUnavailable)
+
+	_, err = client.ExtractFrame("/media/video.mp4", 1.0, nil)
+	require.ErrorAs(t, err, &statusErr)
+	assert.NotErrorIs(t, err, vision.ErrServiceUnavailable)
+
+	server.Close()
+	assert.ErrorIs(t, client.HealthCheck(), vision.ErrServiceUnavailable)
+}
@@ -0,0 +1,98 @@
+package vision_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+func TestBatchExtractFrames_UnsupportedIsRemembered(t *testing.T) {
+	batchCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/extract-frames" {
+			batchCalls++
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	client := vision.NewVisionServiceClient(server.URL, server.URL)
+
+	_, err := client.BatchExtractFrames("/media/a.mp4", []float64{1, 2}, nil)
+	assert.ErrorIs(t, err, vision.ErrBatchExtractionUnsupported)
+
+	_, err = client.BatchExtractFrames("/media/a.mp4", []float64{3, 4}, nil)
+	assert.ErrorIs(t, err, vision.ErrBatchExtractionUnsupported)
+	assert.Equal(t, 1, batchCalls, "unsupported endpoint should only be probed once")
+}
+
+func TestBatchExtractFrames_SendsEnhancement(t *testing.T) {
+	var received vision.BatchExtractRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.Write([]byte(`{"frames":[{"timestamp":2.5,"data":"ZnJhbWU="}]}`))
+	}))
+	defer server.Close()
+
+	client := vision.NewVisionServiceClient(server.URL, server.URL)
+	enhancement := &vision.EnhancementParameters{Enabled: true, Model: "codeformer", FidelityWeight: 0.25}
+
+	frames, err := client.BatchExtractFrames("/media/a.mp4", []float64{2.5}, enhancement)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("frame"), frames[vision.FrameKey(2.5)])
+
+	assert.Equal(t, "/media/a.mp4", received.VideoPath)
+	assert.True(t, received.Enhance)
+	assert.Equal(t, "codeformer", received.Model)
+}
+
+func TestFrameServerHealth_SupportsEnhancement(t *testing.T) {
+	noGPU := false
+
+	tests := []struct {
+		name      string
+		health    vision.FrameServerHealth
+		supported bool
+	}{
+		{"no capabilities reported", vision.FrameServerHealth{Status: "healthy"}, true},
+		{"models unavailable", vision.FrameServerHealth{Enhancement: &vision.EnhancementCapabilities{Available: false}}, false},
+		{"model loaded", vision.FrameServerHealth{Enhancement: &vision.EnhancementCapabilities{Available: true, Models: []string{"codeformer"}}}, true},
+		{"other model only", vision.FrameServerHealth{Enhancement: &vision.EnhancementCapabilities{Available: true, Models: []string{"gfpgan"}}}, false},
+		{"available without model list", vision.FrameServerHealth{Enhancement: &vision.EnhancementCapabilities{Available: true}}, true},
+		{"no GPU", vision.FrameServerHealth{GPUAvailable: &noGPU}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			supported, reason := tt.health.SupportsEnhancement("codeformer")
+			assert.Equal(t, tt.supported, supported)
+			if !supported {
+				assert.NotEmpty(t, reason)
+			}
+		})
+	}
+}
+
+func TestFrameServerHealth_Decodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/health", r.URL.Path)
+		w.Write([]byte(`{"status":"healthy","gpu_available":true,"enhancement":{"available":false}}`))
+	}))
+	defer server.Close()
+
+	client := vision.NewVisionServiceClient(server.URL, server.URL)
+
+	health, err := client.FrameServerHealth()
+	require.NoError(t, err)
+	require.NotNil(t, health.GPUAvailable)
+	assert.True(t, *health.GPUAvailable)
+
+	supported, _ := health.SupportsEnhancement("codeformer")
+	assert.False(t, supported)
+}
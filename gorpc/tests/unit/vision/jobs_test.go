@@ -0,0 +1,108 @@
+package vision_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smegmarip/stash-compreface-plugin/internal/throttle"
+	"github.com/smegmarip/stash-compreface-plugin/internal/vision"
+)
+
+func TestWaitForCompletion_TimeoutCancelsJob(t *testing.T) {
+	var mu sync.Mutex
+	var cancelled []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/vision/analyze":
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"job_id":"job-1","status":"queued"}`))
+		case "/vision/jobs/job-1/status":
+			w.Write([]byte(`{"job_id":"job-1","status":"processing","progress":0.1}`))
+		case "/vision/jobs/job-1/cancel":
+			mu.Lock()
+			cancelled = append(cancelled, r.Method)
+			mu.Unlock()
+			w.Write([]byte(`{"job_id":"job-1","status":"cancelled"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := vision.NewVisionServiceClient(server.URL, server.URL)
+	client.JobSlots = throttle.NewSemaphore(1)
+
+	job, err := client.SubmitJob(vision.AnalyzeRequest{Source: "/media/video.mp4", SourceID: "1"})
+	require.NoError(t, err)
+
+	_, err = client.WaitForCompletion(job.JobID, 10*time.Millisecond, nil)
+	assert.ErrorIs(t, err, vision.ErrJobTimeout)
+	assert.Equal(t, []string{http.MethodPost}, cancelled)
+	assert.Len(t, client.JobSlots, 0, "timed out job must release its slot")
+}
+
+func TestWaitForCompletion_ContextCancelsJob(t *testing.T) {
+	var mu sync.Mutex
+	var cancelled []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/vision/analyze":
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"job_id":"job-2","status":"queued"}`))
+		case "/vision/jobs/job-2/status":
+			w.Write([]byte(`{"job_id":"job-2","status":"processing","progress":0.1}`))
+		case "/vision/jobs/job-2/cancel":
+			mu.Lock()
+			cancelled = append(cancelled, r.Method)
+			mu.Unlock()
+			w.Write([]byte(`{"job_id":"job-2","status":"cancelled"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := vision.NewVisionServiceClient(server.URL, server.URL)
+	client.JobSlots = throttle.NewSemaphore(1)
+	client.Context = ctx
+
+	job, err := client.SubmitJob(vision.AnalyzeRequest{Source: "/media/video.mp4", SourceID: "2"})
+	require.NoError(t, err)
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	started := time.Now()
+	_, err = client.WaitForCompletion(job.JobID, time.Minute, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(started), time.Second, "cancellation must not wait for the next poll")
+	assert.Equal(t, []string{http.MethodPost}, cancelled)
+	assert.Len(t, client.JobSlots, 0, "cancelled job must release its slot")
+
+	// Requests after cancellation fail without reaching the service
+	_, err = client.GetJobStatus(job.JobID)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestFacesParameters_Degraded(t *testing.T) {
+	enhancement := &vision.EnhancementParameters{Enabled: true, Model: "codeformer"}
+	parameters := vision.FacesParameters{SamplingInterval: 2.0, MaxFaces: 50, Enhancement: enhancement}
+
+	degraded := parameters.Degraded()
+	assert.Equal(t, 4.0, degraded.SamplingInterval)
+	assert.Equal(t, vision.DegradedMaxFaces, degraded.MaxFaces)
+	require.NotNil(t, degraded.Enhancement)
+	assert.False(t, degraded.Enhancement.Enabled)
+	assert.Equal(t, "codeformer", degraded.Enhancement.Model)
+	assert.True(t, enhancement.Enabled, "original enhancement must be unchanged")
+
+	small := vision.FacesParameters{MaxFaces: 5}.Degraded()
+	assert.Equal(t, 5, small.MaxFaces)
+	assert.Equal(t, 4.0, small.SamplingInterval)
+}
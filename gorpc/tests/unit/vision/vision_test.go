@@ -1,6 +1,7 @@
 package vision_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -114,6 +115,34 @@ func TestBuildAnalyzeRequest_SpecialCharacters(t *testing.T) {
 	assert.True(t, req.Modules.Faces.Enabled, "faces module should be enabled")
 }
 
+func TestBuildOCRRequest(t *testing.T) {
+	req := vision.BuildOCRRequest("/path/to/video.mp4", "scene123", []string{"en", "ja"}, []float64{12.5, 40})
+
+	assert.Equal(t, "/path/to/video.mp4", req.Source)
+	assert.False(t, req.Modules.Faces.Enabled, "faces module should be disabled")
+	if assert.NotNil(t, req.Modules.Semantics) {
+		assert.True(t, req.Modules.Semantics.Enabled)
+		assert.True(t, req.Modules.Semantics.Parameters.OCR)
+		assert.Equal(t, []string{"en", "ja"}, req.Modules.Semantics.Parameters.OCRLanguages)
+		assert.Equal(t, []float64{12.5, 40}, req.Modules.Semantics.Parameters.Timestamps)
+	}
+
+	faces := vision.BuildAnalyzeRequest("/path/to/video.mp4", "scene123", getParams(false, "", ""))
+	data, err := json.Marshal(faces)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "semantics", "face jobs should not request the semantics module")
+}
+
+func TestAnalyzeResults_DecodesSemanticsText(t *testing.T) {
+	var results vision.AnalyzeResults
+	err := json.Unmarshal([]byte(`{"job_id":"j1","semantics":{"text":[{"timestamp":12.5,"text":"Jane Doe","confidence":0.91,"language":"en"}]}}`), &results)
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, results.Semantics) {
+		assert.Equal(t, []vision.TextDetection{{Timestamp: 12.5, Text: "Jane Doe", Confidence: 0.91, Language: "en"}}, results.Semantics.Text)
+	}
+}
+
 func TestNewVisionServiceClient(t *testing.T) {
 	baseURL := "http://localhost:5010"
 	frameServerURL := "http://localhost:5001"
@@ -126,3 +155,70 @@ func TestNewVisionServiceClient(t *testing.T) {
 // WaitForCompletion, HealthCheck, ExtractFrame) require HTTP client and
 // are tested in integration tests. This unit test file focuses on functions
 // that can be tested without external dependencies.
+
+func TestVisionBoundingBox_Scale(t *testing.T) {
+	box := vision.VisionBoundingBox{XMin: 10, YMin: 20, XMax: 101, YMax: 203}
+
+	assert.Equal(t, vision.VisionBoundingBox{XMin: 25, YMin: 50, XMax: 253, YMax: 508}, box.Scale(2.5))
+	assert.Equal(t, box, box.Scale(1))
+}
+
+func TestAnalyzeResults_ScaleBoundingBoxes(t *testing.T) {
+	box := vision.VisionBoundingBox{XMin: 1, YMin: 2, XMax: 3, YMax: 4}
+	results := &vision.AnalyzeResults{
+		Faces: &vision.FacesResults{
+			Faces: []vision.VisionFace{{
+				RepresentativeDetection: vision.VisionDetection{BBox: box},
+				Detections:              []vision.VisionDetection{{BBox: box}, {BBox: box}},
+			}},
+		},
+	}
+
+	results.ScaleBoundingBoxes(2)
+
+	doubled := vision.VisionBoundingBox{XMin: 2, YMin: 4, XMax: 6, YMax: 8}
+	face := results.Faces.Faces[0]
+	assert.Equal(t, doubled, face.RepresentativeDetection.BBox)
+	assert.Equal(t, doubled, face.Detections[0].BBox)
+	assert.Equal(t, doubled, face.Detections[1].BBox)
+
+	// Results without a faces module are left alone
+	(&vision.AnalyzeResults{}).ScaleBoundingBoxes(2)
+}
+
+func TestParseBackendLoad_NestedObjects(t *testing.T) {
+	health := map[string]interface{}{
+		"status": "healthy",
+		"gpu":    map[string]interface{}{"memory_used_mb": 7372.8, "memory_total_mb": 8192.0},
+		"queue":  map[string]interface{}{"pending": 3.0, "active": 1.0},
+	}
+
+	load := vision.ParseBackendLoad(health)
+	assert.InDelta(t, 0.9, load.GPUMemory, 0.001)
+	assert.Equal(t, 4, load.QueueDepth)
+
+	pressured, reason := load.Pressured(0.9, 10)
+	assert.True(t, pressured)
+	assert.Equal(t, "GPU memory 90% used", reason)
+}
+
+func TestParseBackendLoad_TopLevelPercent(t *testing.T) {
+	load := vision.ParseBackendLoad(map[string]interface{}{
+		"gpu_memory_utilization": 45.0,
+		"queue_length":           6.0,
+	})
+	assert.InDelta(t, 0.45, load.GPUMemory, 0.001)
+	assert.Equal(t, 6, load.QueueDepth)
+
+	pressured, reason := load.Pressured(0.9, 4)
+	assert.True(t, pressured)
+	assert.Equal(t, "6 jobs queued", reason)
+}
+
+func TestParseBackendLoad_UnreportedNeverPressured(t *testing.T) {
+	load := vision.ParseBackendLoad(map[string]interface{}{"status": "healthy", "version": "1.0.0"})
+	assert.Equal(t, vision.BackendLoad{GPUMemory: -1, QueueDepth: -1}, load)
+
+	pressured, _ := load.Pressured(0.9, 1)
+	assert.False(t, pressured)
+}